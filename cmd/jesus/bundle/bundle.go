@@ -0,0 +1,54 @@
+// Package bundle holds the scripts and the profile compiled into the jesus binary, so an app
+// finished in the playground ships as one executable. Replace scripts/ with the scripts of
+// the app and put its serve settings into profile.yaml, then build the binary:
+//
+//	make build-embedded
+//	./dist/jesus-app serve
+//
+// Binaries built otherwise serve the bundle with serve --embedded.
+package bundle
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed all:scripts
+var scripts embed.FS
+
+//go:embed profile.yaml
+var profile []byte
+
+// Embedded is "true" in binaries built to serve the bundle without --embedded, set with
+// -ldflags "-X github.com/go-go-golems/jesus/cmd/jesus/bundle.Embedded=true"
+var Embedded = "false"
+
+// ServeByDefault reports whether serve runs the bundle unless --embedded=false is passed
+func ServeByDefault() bool {
+	on, _ := strconv.ParseBool(Embedded)
+	return on
+}
+
+// Scripts returns the scripts of the bundle, loaded like the files of --scripts
+func Scripts() fs.FS {
+	sub, err := fs.Sub(scripts, "scripts")
+	if err != nil {
+		// scripts is a valid path of the embedded file system
+		panic(err)
+	}
+	return sub
+}
+
+// Profile returns the field values of profile.yaml by section, like a profile of the jesus
+// profiles file. It is nil if the profile sets nothing.
+func Profile() (map[string]map[string]interface{}, error) {
+	var values map[string]map[string]interface{}
+	if err := yaml.Unmarshal(profile, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse embedded profile.yaml: %w", err)
+	}
+	return values, nil
+}
@@ -0,0 +1,14 @@
+// Template app of the embedded bundle. Replace this directory with the scripts of your
+// app; every .js and .ts file in it runs on startup, in directory order.
+
+app.get('/', (req, res) => {
+    res.json({
+        app: 'jesus',
+        message: 'Served from the scripts embedded in this binary',
+        time: new Date().toISOString(),
+    });
+});
+
+app.get('/health', (req, res) => {
+    res.json({ ok: true });
+});
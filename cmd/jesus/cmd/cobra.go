@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	embeddings_config "github.com/go-go-golems/geppetto/pkg/embeddings/config"
 	"github.com/go-go-golems/geppetto/pkg/steps/ai/settings"
@@ -16,9 +17,11 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cmds/sources"
 	"github.com/go-go-golems/glazed/pkg/cmds/values"
 	appconfig "github.com/go-go-golems/glazed/pkg/config"
+	"github.com/go-go-golems/jesus/cmd/jesus/bundle"
 	"github.com/go-go-golems/pinocchio/pkg/cmds/cmdlayers"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -71,6 +74,12 @@ func GetServeCommandMiddlewares(
 		return nil, err
 	}
 
+	// The profile of the bundle comes right above the built-in defaults
+	embeddedProfile, err := embeddedProfileMiddleware(cmd)
+	if err != nil {
+		return nil, err
+	}
+
 	middlewares_ := []sources.Middleware{
 		sources.FromCobra(cmd,
 			fields.WithSource("cobra"),
@@ -84,27 +93,11 @@ func GetServeCommandMiddlewares(
 	}
 
 	// Profile support with layered configuration: pinocchio first, then jesus overrides
-	xdgConfigPath, err := os.UserConfigDir()
-	if err != nil {
-		log.Warn().Err(err).Msg("Could not get user config directory, using current directory")
-		xdgConfigPath = "."
-	}
+	xdgConfigPath := userConfigDir()
 
 	// Set up profile files: pinocchio as base, jesus as override
 	pinocchioProfileFile := fmt.Sprintf("%s/pinocchio/profiles.yaml", xdgConfigPath)
-	jesusProfileFile := fmt.Sprintf("%s/jesus/profiles.yaml", xdgConfigPath)
-
-	// Use specified profile file or default to jesus
-	targetProfileFile := profileSettings.ProfileFile
-	if targetProfileFile == "" {
-		targetProfileFile = jesusProfileFile
-	}
-
-	// Default to development profile for jesus
-	profile := profileSettings.Profile
-	if profile == "" {
-		profile = "default"
-	}
+	targetProfileFile, profile := selectedProfile(profileSettings)
 
 	middlewares_ = append(middlewares_,
 		sources.GatherFlagsFromCustomProfiles(
@@ -148,6 +141,9 @@ func GetServeCommandMiddlewares(
 				sources.WithParseOptions(fields.WithSource("jesus-config"))),
 		)
 	}
+	if embeddedProfile != nil {
+		aiSectionMiddlewares = append(aiSectionMiddlewares, embeddedProfile)
+	}
 	aiSectionMiddlewares = append(aiSectionMiddlewares,
 		sources.FromDefaults(fields.WithSource(fields.SourceDefaults)),
 	)
@@ -176,6 +172,9 @@ func GetServeCommandMiddlewares(
 				sources.WithParseOptions(fields.WithSource("jesus-config"))),
 		)
 	}
+	if embeddedProfile != nil {
+		defaultSectionMiddlewares = append(defaultSectionMiddlewares, embeddedProfile)
+	}
 	defaultSectionMiddlewares = append(defaultSectionMiddlewares,
 		sources.FromDefaults(fields.WithSource(fields.SourceDefaults)),
 	)
@@ -192,6 +191,88 @@ func GetServeCommandMiddlewares(
 	return middlewares_, nil
 }
 
+// embeddedProfileMiddleware returns the source of the values of the bundle's profile.yaml
+// when the command serves the bundle, nil otherwise. --embedded is read from the flag itself,
+// as the sources are set up before the command's values are parsed.
+func embeddedProfileMiddleware(cmd *cobra.Command) (sources.Middleware, error) {
+	flag := cmd.Flags().Lookup("embedded")
+	if flag == nil {
+		return nil, nil
+	}
+	if on, err := strconv.ParseBool(flag.Value.String()); err != nil || !on {
+		return nil, nil
+	}
+	profile, err := bundle.Profile()
+	if err != nil || profile == nil {
+		return nil, err
+	}
+	return sources.FromMap(profile, fields.WithSource("embedded-profile")), nil
+}
+
+// userConfigDir returns the directory of the user's configuration, or the current directory
+func userConfigDir() string {
+	xdgConfigPath, err := os.UserConfigDir()
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not get user config directory, using current directory")
+		return "."
+	}
+	return xdgConfigPath
+}
+
+// selectedProfile returns the jesus profiles file and the profile selected by the profile
+// settings, defaulting to the default profile of the user's jesus profiles file
+func selectedProfile(profileSettings *cli.ProfileSettings) (string, string) {
+	// Use specified profile file or default to jesus
+	profileFile := profileSettings.ProfileFile
+	if profileFile == "" {
+		profileFile = fmt.Sprintf("%s/jesus/profiles.yaml", userConfigDir())
+	}
+
+	// Default to development profile for jesus
+	profile := profileSettings.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	return profileFile, profile
+}
+
+// loadProfileSecrets reads the secrets of a section of the selected profile, such as
+//
+//	production:
+//	  secrets:
+//	    STRIPE_KEY: sk_live_...
+//
+// A missing profiles file, profile or section has no secrets.
+func loadProfileSecrets(parsedValues *values.Values, section string) (map[string]string, error) {
+	profileSettings := &cli.ProfileSettings{}
+	if err := parsedValues.DecodeSectionInto(cli.ProfileSettingsSlug, profileSettings); err != nil {
+		return nil, err
+	}
+	profileFile, profile := selectedProfile(profileSettings)
+
+	data, err := os.ReadFile(profileFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read profiles file: %w", err)
+	}
+
+	var profiles map[string]map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to parse profiles file %s: %w", profileFile, err)
+	}
+	node, ok := profiles[profile][section]
+	if !ok {
+		return nil, nil
+	}
+	var secrets map[string]string
+	if err := node.Decode(&secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse section %s of profile %s in %s: %w", section, profile, profileFile, err)
+	}
+	return secrets, nil
+}
+
 func resolveConfigFiles(appName string, explicit string) ([]string, error) {
 	if appName == "" && explicit == "" {
 		return nil, nil
@@ -24,6 +24,7 @@ type ExecuteCmd struct {
 // ExecuteSettings holds the configuration for the execute command
 type ExecuteSettings struct {
 	URL   string `glazed:"url"`
+	Token string `glazed:"token"`
 	Input string `glazed:"input"`
 }
 
@@ -52,6 +53,7 @@ Examples:
   execute "console.log('Hello World')"
   execute ./scripts/test.js
   execute --url http://localhost:9090 "globalState.counter++"
+  execute --token $EXECUTE_TOKEN ./scripts/test.js
 			`),
 			cmds.WithFlags(
 				fields.New(
@@ -61,6 +63,12 @@ Examples:
 					fields.WithDefault("http://localhost:9090"),
 					fields.WithShortFlag("u"),
 				),
+				fields.New(
+					"token",
+					fields.TypeString,
+					fields.WithHelp("Execute token of a server started with --execute-token, sent as bearer token"),
+					fields.WithDefault(""),
+				),
 			),
 			cmds.WithArguments(
 				fields.New(
@@ -104,7 +112,15 @@ func (c *ExecuteCmd) Run(ctx context.Context, parsedValues *values.Values) error
 	log.Debug().Str("url", executeURL).Msg("Sending request to server")
 
 	// Send POST request to the server
-	resp, err := http.Post(executeURL, "application/javascript", strings.NewReader(code))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, executeURL, strings.NewReader(code))
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("Content-Type", "application/javascript")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return errors.Wrapf(err, "failed to execute code on server: %s", executeURL)
 	}
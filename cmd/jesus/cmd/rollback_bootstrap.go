@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/fields"
+	"github.com/go-go-golems/glazed/pkg/cmds/schema"
+	"github.com/go-go-golems/glazed/pkg/cmds/values"
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// RollbackBootstrapCmd represents the rollback-bootstrap command
+type RollbackBootstrapCmd struct {
+	*cmds.CommandDescription
+}
+
+// RollbackBootstrapSettings holds the configuration for the rollback-bootstrap command
+type RollbackBootstrapSettings struct {
+	Bootstrap string `glazed:"bootstrap"`
+	SystemDB  string `glazed:"system-db"`
+	ID        int    `glazed:"id"`
+	List      bool   `glazed:"list"`
+}
+
+// Ensure RollbackBootstrapCmd implements BareCommand
+var _ cmds.BareCommand = &RollbackBootstrapCmd{}
+
+// NewRollbackBootstrapCmd creates a new rollback-bootstrap command
+func NewRollbackBootstrapCmd() (*RollbackBootstrapCmd, error) {
+	return &RollbackBootstrapCmd{
+		CommandDescription: cmds.NewCommandDescription(
+			"rollback-bootstrap",
+			cmds.WithShort("Restore a previous version of the bootstrap file"),
+			cmds.WithLong(`Restore a previous version of the bootstrap file from the backups in the system database.
+
+The server backs up the bootstrap file whenever new content of it runs, and records
+whether it ran without error. Without --id, the latest working version that differs
+from the current file is restored. The current file is backed up first, so a rollback
+can be undone. Restart the server, or use POST /admin/bootstrap/rollback on a running
+server, to run the restored file.
+
+Examples:
+  rollback-bootstrap
+  rollback-bootstrap --list
+  rollback-bootstrap --id 12 --system-db system.sqlite`),
+			cmds.WithFlags(
+				fields.New(
+					"bootstrap",
+					fields.TypeString,
+					fields.WithHelp("Bootstrap file to restore"),
+					fields.WithDefault("bootstrap.js"),
+				),
+				fields.New(
+					"system-db",
+					fields.TypeString,
+					fields.WithHelp("System database holding the backups"),
+					fields.WithDefault("system.sqlite"),
+				),
+				fields.New(
+					"id",
+					fields.TypeInteger,
+					fields.WithHelp("Backup to restore (default: latest working backup)"),
+					fields.WithDefault(0),
+				),
+				fields.New(
+					"list",
+					fields.TypeBool,
+					fields.WithHelp("List the backups instead of restoring one"),
+					fields.WithDefault(false),
+				),
+			),
+		),
+	}, nil
+}
+
+// Run executes the rollback-bootstrap command
+func (cmd *RollbackBootstrapCmd) Run(ctx context.Context, parsedValues *values.Values) error {
+	var s RollbackBootstrapSettings
+	if err := parsedValues.DecodeSectionInto(schema.DefaultSlug, &s); err != nil {
+		return errors.Wrap(err, "failed to parse settings")
+	}
+
+	repos, err := repository.NewSQLiteRepositoryManager(s.SystemDB)
+	if err != nil {
+		return errors.Wrap(err, "failed to open system database")
+	}
+	defer func() {
+		if err := repos.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close system database")
+		}
+	}()
+
+	if s.List {
+		file, err := filepath.Abs(s.Bootstrap)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve bootstrap file")
+		}
+		backups, err := repos.BootstrapBackups().ListBackups(ctx, file)
+		if err != nil {
+			return errors.Wrap(err, "failed to list bootstrap backups")
+		}
+		if len(backups) == 0 {
+			fmt.Printf("No backups of %s\n", file)
+			return nil
+		}
+		for _, backup := range backups {
+			status := backup.Status
+			if status == "" {
+				status = "not run"
+			}
+			fmt.Printf("%5d  %s  %-8s %6d bytes  %s\n", backup.ID, backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), status, len(backup.Content), backup.Hash[:12])
+		}
+		return nil
+	}
+
+	backup, err := engine.RestoreBootstrapBackup(repos, s.Bootstrap, s.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to roll back bootstrap file")
+	}
+	fmt.Printf("Restored backup %d from %s to %s\n", backup.ID, backup.CreatedAt.Local().Format("2006-01-02 15:04:05"), s.Bootstrap)
+	return nil
+}
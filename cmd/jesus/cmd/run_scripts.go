@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/fields"
@@ -21,8 +22,10 @@ type RunScriptsCmd struct {
 
 // RunScriptsSettings holds the configuration for the run-scripts command
 type RunScriptsSettings struct {
-	ScriptsDir string   `glazed:"scripts"`
-	Files      []string `glazed:"files"`
+	ScriptsDir   string   `glazed:"scripts"`
+	Files        []string `glazed:"files"`
+	Report       string   `glazed:"report"`
+	ReportFormat string   `glazed:"report-format"`
 }
 
 // Ensure RunScriptsCmd implements BareCommand
@@ -41,12 +44,17 @@ The command is useful for:
 • Executing batch operations
 • Testing route registration and runtime state
 • Running standalone JavaScript with database bindings
+• Gating script bundles in CI: the command exits non-zero when a script fails
+
+--report writes a summary with the status, duration, console excerpt and
+first error of every script, as JSON or markdown.
 
 Examples:
   run-scripts --scripts ./tests
   run-scripts --files test1.js,test2.js
   run-scripts --scripts ./jobs
-  run-scripts --files seed.js,migrate.js`),
+  run-scripts --files seed.js,migrate.js
+  run-scripts --scripts ./tests --report report.md --report-format markdown`),
 			cmds.WithFlags(
 				fields.New(
 					"scripts",
@@ -61,6 +69,19 @@ Examples:
 					fields.WithHelp("Specific JavaScript files to execute (if not provided, all .js files in scripts directory)"),
 					fields.WithShortFlag("f"),
 				),
+				fields.New(
+					"report",
+					fields.TypeString,
+					fields.WithHelp("File the summary report is written to, - for stdout (empty for none)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"report-format",
+					fields.TypeChoice,
+					fields.WithHelp("Format of the summary report"),
+					fields.WithChoices("json", "markdown"),
+					fields.WithDefault("json"),
+				),
 			),
 		),
 	}, nil
@@ -115,20 +136,26 @@ func (cmd *RunScriptsCmd) Run(ctx context.Context, parsedValues *values.Values)
 	}
 
 	// Execute each file
+	report := &ScriptRunReport{Started: time.Now()}
 	for _, filePath := range filesToExecute {
 		log.Info().Str("file", filePath).Msg("Executing JavaScript file")
+		start := time.Now()
 
 		// Read file content
 		content, err := os.ReadFile(filePath)
 		if err != nil {
 			log.Error().Err(err).Str("file", filePath).Msg("Failed to read file")
+			report.add(ScriptRunResult{File: filePath, Status: ScriptFailed, Error: err.Error()}, nil)
 			continue
 		}
 
 		// Execute the script and capture results
 		result, err := jsEngine.ExecuteScript(string(content))
+		scriptResult := ScriptRunResult{File: filePath, Status: ScriptPassed, DurationMs: time.Since(start).Milliseconds()}
 		if err != nil {
 			log.Error().Err(err).Str("file", filePath).Msg("Failed to execute file")
+			scriptResult.Status, scriptResult.Error = ScriptFailed, err.Error()
+			report.add(scriptResult, result.ConsoleLog)
 			continue
 		}
 
@@ -143,9 +170,20 @@ func (cmd *RunScriptsCmd) Run(ctx context.Context, parsedValues *values.Values)
 		}
 		if result.Error != nil {
 			log.Error().Err(result.Error).Str("file", filePath).Msg("Script execution error")
+			scriptResult.Status, scriptResult.Error = ScriptFailed, result.Error.Error()
 		}
+		report.add(scriptResult, result.ConsoleLog)
 	}
+	report.DurationMs = time.Since(report.Started).Milliseconds()
 
-	log.Info().Msg("JavaScript script execution completed")
+	log.Info().Int("passed", report.Passed).Int("failed", report.Failed).Msg("JavaScript script execution completed")
+	if runSettings.Report != "" {
+		if err := report.write(runSettings.Report, runSettings.ReportFormat); err != nil {
+			return errors.Wrap(err, "failed to write report")
+		}
+	}
+	if report.Failed > 0 {
+		return errors.Errorf("%d of %d scripts failed", report.Failed, len(report.Scripts))
+	}
 	return nil
 }
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxReportConsoleLines bounds the console lines a report keeps per script
+const maxReportConsoleLines = 20
+
+// Script statuses of a run-scripts report
+const (
+	ScriptPassed = "passed"
+	ScriptFailed = "failed"
+)
+
+// ScriptRunReport summarizes a run-scripts invocation, so it can serve as a CI gate
+type ScriptRunReport struct {
+	Started    time.Time         `json:"started"`
+	DurationMs int64             `json:"durationMs"`
+	Passed     int               `json:"passed"`
+	Failed     int               `json:"failed"`
+	Scripts    []ScriptRunResult `json:"scripts"`
+}
+
+// ScriptRunResult is the outcome of one script
+type ScriptRunResult struct {
+	File       string   `json:"file"`
+	Status     string   `json:"status"` // ScriptPassed or ScriptFailed
+	DurationMs int64    `json:"durationMs"`
+	Console    []string `json:"console,omitempty"`    // First lines of the console output
+	ConsoleCut int      `json:"consoleCut,omitempty"` // Console lines left out of Console
+	Error      string   `json:"error,omitempty"`      // First error the script failed with
+}
+
+// add records the outcome of a script
+func (r *ScriptRunReport) add(result ScriptRunResult, console []string) {
+	if len(console) > maxReportConsoleLines {
+		result.ConsoleCut = len(console) - maxReportConsoleLines
+		console = console[:maxReportConsoleLines]
+	}
+	result.Console = console
+	if result.Status == ScriptPassed {
+		r.Passed++
+	} else {
+		r.Failed++
+	}
+	r.Scripts = append(r.Scripts, result)
+}
+
+// write stores the report as json or markdown in a file, "-" for stdout
+func (r *ScriptRunReport) write(path, format string) error {
+	out := io.Writer(os.Stdout)
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrap(err, "failed to create report")
+		}
+		defer func() { _ = f.Close() }()
+		out = f
+	}
+
+	if format == "markdown" {
+		_, err := io.WriteString(out, r.markdown())
+		return err
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *ScriptRunReport) markdown() string {
+	var b strings.Builder
+	b.WriteString("# run-scripts report\n\n")
+	fmt.Fprintf(&b, "%d scripts: %d passed, %d failed in %dms (started %s)\n\n",
+		len(r.Scripts), r.Passed, r.Failed, r.DurationMs, r.Started.Format(time.RFC3339))
+
+	b.WriteString("| File | Status | Duration | Error |\n|---|---|---|---|\n")
+	for _, s := range r.Scripts {
+		fmt.Fprintf(&b, "| `%s` | %s | %dms | %s |\n", s.File, s.Status, s.DurationMs, markdownCell(s.Error))
+	}
+
+	for _, s := range r.Scripts {
+		if s.Status == ScriptPassed && len(s.Console) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n## %s\n\n", s.File)
+		if s.Error != "" {
+			fmt.Fprintf(&b, "Failed with `%s`\n\n", s.Error)
+		}
+		if len(s.Console) > 0 {
+			b.WriteString("```\n" + strings.Join(s.Console, "\n") + "\n```\n")
+			if s.ConsoleCut > 0 {
+				fmt.Fprintf(&b, "\n%d more console lines\n", s.ConsoleCut)
+			}
+		}
+	}
+	return b.String()
+}
+
+// markdownCell keeps a value on one table row
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -4,19 +4,17 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/fields"
 	"github.com/go-go-golems/glazed/pkg/cmds/values"
-	"github.com/go-go-golems/jesus/pkg/api"
+	"github.com/go-go-golems/jesus"
+	"github.com/go-go-golems/jesus/cmd/jesus/bundle"
 	"github.com/go-go-golems/jesus/pkg/engine"
-	"github.com/go-go-golems/jesus/pkg/web"
+	"github.com/go-go-golems/jesus/pkg/storage"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
@@ -28,11 +26,91 @@ type ServeCmd struct {
 
 // ServeSettings holds the configuration for the serve command
 type ServeSettings struct {
-	Port       string `glazed:"port"`
-	AdminPort  string `glazed:"admin-port"`
-	AppDB      string `glazed:"app-db"`
-	SystemDB   string `glazed:"system-db"`
-	ScriptsDir string `glazed:"scripts"`
+	Port        string `glazed:"port"`
+	AdminPort   string `glazed:"admin-port"`
+	SinglePort  bool   `glazed:"single-port"`
+	Listen      string `glazed:"listen"`
+	AdminListen string `glazed:"admin-listen"`
+	AppDB       string `glazed:"app-db"`
+	SystemDB    string `glazed:"system-db"`
+	ScriptsDir  string `glazed:"scripts"`
+	Embedded    bool   `glazed:"embedded"`
+	FilesDir    string `glazed:"files-dir"`
+	Env         string `glazed:"env"`
+	EnvConfig   string `glazed:"env-config"`
+	Templates   string `glazed:"execution-templates"`
+	Policies    string `glazed:"source-policies"`
+
+	HTTPTimeout    string `glazed:"http-timeout"`
+	HTTPMaxPerHost int    `glazed:"http-max-per-host"`
+	HTTPProxy      string `glazed:"http-proxy"`
+	HTTPCassette   string `glazed:"http-cassette"`
+	CassetteMode   string `glazed:"cassette-mode"`
+
+	AllowHosts      []string `glazed:"allow-hosts"`
+	DenyHosts       []string `glazed:"deny-hosts"`
+	BlockPrivateIPs bool     `glazed:"block-private-ips"`
+
+	RuntimePoolSize  int    `glazed:"runtime-pool-size"`
+	ExecutionTimeout string `glazed:"execution-timeout"`
+	DBStatementCache int    `glazed:"db-statement-cache"`
+
+	MaxResultSize     int    `glazed:"max-result-size"`
+	MaxConsoleLogSize int    `glazed:"max-console-log-size"`
+	PageResultSize    int    `glazed:"page-result-size"`
+	MaxUploadSize     int    `glazed:"max-upload-size"`
+	MaxUploadFiles    int    `glazed:"max-upload-files"`
+	UploadDir         string `glazed:"upload-dir"`
+	TmpDir            string `glazed:"tmp-dir"`
+	TmpQuota          int    `glazed:"tmp-quota"`
+	OutputDir         string `glazed:"output-dir"`
+	Storage           string `glazed:"storage"`
+
+	LogArchiveDir string `glazed:"log-archive-dir"`
+	LogRetention  string `glazed:"log-retention"`
+
+	EventLog       bool   `glazed:"event-log"`
+	EventRetention string `glazed:"event-retention"`
+
+	LogAnonymizeIPs    string   `glazed:"log-anonymize-ips"`
+	LogAnonymizeFields []string `glazed:"log-anonymize-fields"`
+	LogAnonymizeSalt   string   `glazed:"log-anonymize-salt"`
+
+	MaxCallStackSize int `glazed:"max-call-stack-size"`
+	MaxStringLength  int `glazed:"max-string-length"`
+	MaxArrayLength   int `glazed:"max-array-length"`
+
+	Maintenance bool     `glazed:"maintenance"`
+	ReadOnly    bool     `glazed:"read-only"`
+	Compress    bool     `glazed:"compress"`
+	SelfCheck   string   `glazed:"self-check"`
+	WarmUp      bool     `glazed:"warm-up"`
+	APIKeys     []string `glazed:"api-keys"`
+	SyncState   bool     `glazed:"sync-state"`
+
+	TrustedProxies []string `glazed:"trusted-proxies"`
+	CookieSecrets  []string `glazed:"cookie-secrets"`
+	ExecuteToken   string   `glazed:"execute-token"`
+
+	SecretsEnvPrefix      string `glazed:"secrets-env-prefix"`
+	SecretsFile           string `glazed:"secrets-file"`
+	SecretsProfileSection string `glazed:"secrets-profile-section"`
+
+	AdminCORSOrigins     []string `glazed:"admin-cors-origins"`
+	AdminCORSCredentials bool     `glazed:"admin-cors-credentials"`
+
+	ExecuteRateLimit   string `glazed:"execute-rate-limit"`
+	SanitizeHTML       string `glazed:"sanitize-html"`
+	ResponseValidation string `glazed:"response-validation"`
+
+	LocalesDir    string `glazed:"locales-dir"`
+	DefaultLocale string `glazed:"default-locale"`
+
+	TLSCert          string   `glazed:"tls-cert"`
+	TLSKey           string   `glazed:"tls-key"`
+	AutocertDomains  []string `glazed:"autocert-domains"`
+	AutocertCacheDir string   `glazed:"autocert-cache-dir"`
+	AutocertEmail    string   `glazed:"autocert-email"`
 }
 
 // Ensure ServeCmd implements BareCommand
@@ -56,6 +134,22 @@ The server provides:
 Examples:
   serve --port 9922 --scripts ./scripts
   serve --app-db app.db --system-db system.db --admin-port 9090
+  serve --port 8080 --single-port
+  serve --listen unix:///run/jesus/app.sock --admin-listen unix:///run/jesus/admin.sock
+  serve --env prod --env-config environments.yaml
+  serve --http-proxy http://proxy.internal:3128 --http-max-per-host 4
+  serve --http-cassette demo --cassette-mode record
+  serve --block-private-ips --deny-hosts '*.internal' --allow-hosts api.github.com,10.0.5.0/24
+  serve --log-anonymize-ips truncate --log-anonymize-fields userId,email
+  serve --secrets-env-prefix APP_SECRET_ --secrets-file secrets.yaml --secrets-profile-section secrets
+  serve --scripts ./scripts --warm-up
+  serve --scripts ./scripts --read-only
+  serve --source-policies policies.yaml
+  serve --event-retention 720h
+  serve --storage s3://my-bucket/jesus?region=eu-west-1
+  serve --response-validation strict
+  serve --db-statement-cache 500
+  serve --embedded
 			`),
 			cmds.WithFlags(
 				fields.New(
@@ -71,6 +165,24 @@ Examples:
 					fields.WithHelp("HTTP port for admin/system interface"),
 					fields.WithDefault("9090"),
 				),
+				fields.New(
+					"listen",
+					fields.TypeString,
+					fields.WithHelp("Address of the JavaScript web server instead of --port: host:port, or unix:///path/to/socket for a unix domain socket"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"admin-listen",
+					fields.TypeString,
+					fields.WithHelp("Address of the admin interface instead of --admin-port: host:port, or unix:///path/to/socket for a unix domain socket"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"single-port",
+					fields.TypeBool,
+					fields.WithHelp("Serve the admin interface and /v1/execute on the JavaScript web server's port, under /admin, /playground, /v1/execute and a few more paths, instead of on --admin-port"),
+					fields.WithDefault(false),
+				),
 				fields.New(
 					"app-db",
 					fields.TypeString,
@@ -91,6 +203,374 @@ Examples:
 					fields.WithDefault(""),
 					fields.WithShortFlag("s"),
 				),
+				fields.New(
+					"embedded",
+					fields.TypeBool,
+					fields.WithHelp("Serve the scripts and profile.yaml compiled into the binary from cmd/jesus/bundle instead of bootstrap.js, for apps shipped as a single executable"),
+					fields.WithDefault(bundle.ServeByDefault()),
+				),
+				fields.New(
+					"files-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory res.sendFile() and app.static() may serve files from (empty to serve no files)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"env",
+					fields.TypeString,
+					fields.WithHelp("Execution environment name exposed to JavaScript as env.name (e.g. dev, prod)"),
+					fields.WithDefault(engine.DefaultEnvironmentName),
+				),
+				fields.New(
+					"env-config",
+					fields.TypeString,
+					fields.WithHelp("YAML file with per-environment configuration exposed as env.config"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"execution-templates",
+					fields.TypeString,
+					fields.WithHelp("YAML file with execution templates selectable with /v1/execute?env=<name>"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"source-policies",
+					fields.TypeString,
+					fields.WithHelp("YAML file restricting what code of each execution source (api, playground, mcp, file, ...) may do: denyRoutes, denyDbWrites, denyNetwork, timeout"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"http-timeout",
+					fields.TypeString,
+					fields.WithHelp("Default timeout of fetch() and HTTP.* requests made from JavaScript"),
+					fields.WithDefault(engine.DefaultHTTPClientConfig().Timeout.String()),
+				),
+				fields.New(
+					"http-max-per-host",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum concurrent fetch() and HTTP.* requests per host (0 for no limit)"),
+					fields.WithDefault(engine.DefaultHTTPClientConfig().MaxPerHost),
+				),
+				fields.New(
+					"http-proxy",
+					fields.TypeString,
+					fields.WithHelp("Proxy URL for fetch() and HTTP.* requests (defaults to HTTP_PROXY/HTTPS_PROXY)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"http-cassette",
+					fields.TypeString,
+					fields.WithHelp("Record the responses of fetch(), HTTP.* and proxy() requests to this cassette of the system database and replay them, for demos and tests without network access or API keys"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"cassette-mode",
+					fields.TypeChoice,
+					fields.WithChoices(engine.CassetteAuto, engine.CassetteRecord, engine.CassetteReplay),
+					fields.WithHelp("How --http-cassette is used: auto replays recorded responses and records the other requests, record starts the cassette over, replay never sends requests"),
+					fields.WithDefault(engine.CassetteAuto),
+				),
+				fields.New(
+					"allow-hosts",
+					fields.TypeStringList,
+					fields.WithHelp("Only let fetch(), HTTP.* and proxy() reach these hosts (api.example.com, *.example.com or a CIDR such as 10.0.0.0/8)"),
+				),
+				fields.New(
+					"deny-hosts",
+					fields.TypeStringList,
+					fields.WithHelp("Never let fetch(), HTTP.* and proxy() reach these hosts, even if allowed (api.example.com, *.example.com or a CIDR)"),
+				),
+				fields.New(
+					"block-private-ips",
+					fields.TypeBool,
+					fields.WithHelp("Block outbound requests to loopback, private and link-local addresses such as 169.254.169.254, unless allowed with --allow-hosts"),
+					fields.WithDefault(false),
+				),
+				fields.New(
+					"runtime-pool-size",
+					fields.TypeInteger,
+					fields.WithHelp("Number of JavaScript runtimes serving routes registered by bootstrap.js and --scripts with replicate: true concurrently"),
+					fields.WithDefault(1),
+				),
+				fields.New(
+					"db-statement-cache",
+					fields.TypeInteger,
+					fields.WithHelp("Number of db.prepare() statements kept prepared, least recently used out first (0 to prepare them on every use)"),
+					fields.WithDefault(engine.DefaultStatementCacheSize),
+				),
+				fields.New(
+					"execution-timeout",
+					fields.TypeString,
+					fields.WithHelp("Time a route handler or code execution may run before it is interrupted, unless the route sets a timeout option (0 for no limit)"),
+					fields.WithDefault("0"),
+				),
+				fields.New(
+					"max-result-size",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum bytes of an execution result stored in the system database (0 for no limit)"),
+					fields.WithDefault(engine.DefaultOutputLimits().MaxResultBytes),
+				),
+				fields.New(
+					"max-console-log-size",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum bytes of an execution's console output stored in the system database (0 for no limit)"),
+					fields.WithDefault(engine.DefaultOutputLimits().MaxConsoleLogBytes),
+				),
+				fields.New(
+					"page-result-size",
+					fields.TypeInteger,
+					fields.WithHelp("Bytes above which array and object results are stored in pages the history fetches on demand (0 to never page)"),
+					fields.WithDefault(engine.DefaultOutputLimits().PageResultBytes),
+				),
+				fields.New(
+					"output-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory keeping the full output of executions whose stored output was truncated (empty to drop it)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"storage",
+					fields.TypeString,
+					fields.WithHelp("Where execution attachments, full outputs and request log archives are kept: a directory, file:// or s3://bucket/prefix?region=&endpoint= URL with AWS_* credentials (empty for the system database and the local directories)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"log-archive-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory request logs are archived to, as daily gzipped JSON Lines files, once they leave the in-memory log (empty to drop them)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"log-retention",
+					fields.TypeString,
+					fields.WithHelp("Age after which request log archives are deleted (0 to keep them)"),
+					fields.WithDefault("168h"),
+				),
+				fields.New(
+					"event-log",
+					fields.TypeBool,
+					fields.WithHelp("Keep executions, route changes and errors in the system database for consumers of /v1/events"),
+					fields.WithDefault(true),
+				),
+				fields.New(
+					"event-retention",
+					fields.TypeString,
+					fields.WithHelp("Age after which events are pruned from the event log (0 to keep them)"),
+					fields.WithDefault("168h"),
+				),
+				fields.New(
+					"log-anonymize-ips",
+					fields.TypeChoice,
+					fields.WithHelp("Hide client IPs in request logs and archives: truncate to the /24 (IPv4) or /48 (IPv6) network, or replace with a keyed hash"),
+					fields.WithChoices("none", engine.AnonymizeIPTruncate, engine.AnonymizeIPHash),
+					fields.WithDefault("none"),
+				),
+				fields.New(
+					"log-anonymize-fields",
+					fields.TypeStringList,
+					fields.WithHelp("Headers, query parameters and JSON body fields whose values are hashed in request logs, e.g. userId,email,X-User-Id"),
+				),
+				fields.New(
+					"log-anonymize-salt",
+					fields.TypeString,
+					fields.WithHelp("Key of the anonymization hashes, so they stay the same across restarts; random if empty"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"max-upload-size",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum bytes of each file uploaded with a multipart request (0 for no limit)"),
+					fields.WithDefault(int(engine.DefaultUploadLimits().MaxFileBytes)),
+				),
+				fields.New(
+					"max-upload-files",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum number of files of a multipart request (0 for no limit)"),
+					fields.WithDefault(engine.DefaultUploadLimits().MaxFiles),
+				),
+				fields.New(
+					"upload-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory of the temporary files of uploads (empty for the system temp directory)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"tmp-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory the temp directories of tmp.* are created in, one per execution or request (empty for the system temp directory)"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"tmp-quota",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum total bytes an execution or request may write with tmp.* (0 for no limit)"),
+					fields.WithDefault(int(engine.DefaultTempLimits().MaxBytes)),
+				),
+				fields.New(
+					"max-call-stack-size",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum JavaScript call depth before a script is aborted (0 for no limit)"),
+					fields.WithDefault(engine.DefaultRuntimeLimits().MaxCallStackSize),
+				),
+				fields.New(
+					"max-string-length",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum length of a string returned by a script or sent as a response (0 for no limit)"),
+					fields.WithDefault(engine.DefaultRuntimeLimits().MaxStringLength),
+				),
+				fields.New(
+					"max-array-length",
+					fields.TypeInteger,
+					fields.WithHelp("Maximum length of an array returned by a script or sent as a response (0 for no limit)"),
+					fields.WithDefault(engine.DefaultRuntimeLimits().MaxArrayLength),
+				),
+				fields.New(
+					"maintenance",
+					fields.TypeBool,
+					fields.WithHelp("Start in maintenance mode: JavaScript routes answer 503 until it is turned off in the admin console"),
+					fields.WithDefault(false),
+				),
+				fields.New(
+					"read-only",
+					fields.TypeBool,
+					fields.WithHelp("Start in read-only mode once the scripts are loaded: routes keep serving, but /v1/execute, the REPL, MCP executeJS and route registration are refused until it is turned off at /admin/readonly"),
+					fields.WithDefault(false),
+				),
+				fields.New(
+					"compress",
+					fields.TypeBool,
+					fields.WithHelp("Gzip text responses of JavaScript routes over 1 KB for clients accepting it; routes opt out with compress: false"),
+					fields.WithDefault(false),
+				),
+				fields.New(
+					"sanitize-html",
+					fields.TypeString,
+					fields.WithHelp("Sanitize HTML sent with res.send() against XSS: ugc keeps formatting, links and images, strict keeps text only; empty to send HTML as is"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"response-validation",
+					fields.TypeChoice,
+					fields.WithHelp("What a response not matching its route's responseSchema does: warn logs it, strict answers 500 with the errors, off skips the check"),
+					fields.WithChoices("warn", "strict", "off"),
+					fields.WithDefault("warn"),
+				),
+				fields.New(
+					"locales-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory of the message catalogs of t(), one en.json, de.yaml, ... per locale"),
+					fields.WithDefault("locales"),
+				),
+				fields.New(
+					"default-locale",
+					fields.TypeString,
+					fields.WithHelp("Locale t() translates into when i18n.middleware() negotiates none"),
+					fields.WithDefault(engine.DefaultI18nLocale),
+				),
+				fields.New(
+					"self-check",
+					fields.TypeChoice,
+					fields.WithHelp("Check the db, fetch, console and AI bindings at startup: warn about problems, fail to start, or skip the check"),
+					fields.WithChoices("warn", "fail", "off"),
+					fields.WithDefault("warn"),
+				),
+				fields.New(
+					"warm-up",
+					fields.TypeBool,
+					fields.WithHelp("Call every GET route of the scripts once after loading them, at startup and on upload, and report routes that fail. Warm-up requests carry X-Warm-Up and their db.exec() changes nothing"),
+					fields.WithDefault(false),
+				),
+				fields.New(
+					"api-keys",
+					fields.TypeStringList,
+					fields.WithHelp("API keys accepted by routes registered with auth: 'apiKey', sent as X-API-Key header or bearer token"),
+				),
+				fields.New(
+					"execute-token",
+					fields.TypeString,
+					fields.WithHelp("Bearer token /v1/execute and the REPL endpoint require (Authorization: Bearer <token>); requests without it get 401. Empty leaves them open"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"cookie-secrets",
+					fields.TypeStringList,
+					fields.WithHelp("Secrets signing the cookies set with res.cookie(name, value, { signed: true }); the first signs, all verify"),
+				),
+				fields.New(
+					"secrets-env-prefix",
+					fields.TypeString,
+					fields.WithHelp("Expose environment variables with this prefix to secrets.get() without it, e.g. APP_SECRET_ for secrets.get('DB_PASSWORD'); other variables stay hidden"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"secrets-file",
+					fields.TypeString,
+					fields.WithHelp("YAML, JSON or .env file of secrets.get() names and values"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"secrets-profile-section",
+					fields.TypeString,
+					fields.WithHelp("Section of the selected --profile whose names and values secrets.get() returns, e.g. secrets"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"admin-cors-origins",
+					fields.TypeStringList,
+					fields.WithHelp("Origins of external dashboards allowed to call the admin server and /v1/execute from a browser; other cross-origin requests are refused"),
+				),
+				fields.New(
+					"admin-cors-credentials",
+					fields.TypeBool,
+					fields.WithHelp("Let the origins of --admin-cors-origins send cookies and authorization headers"),
+					fields.WithDefault(false),
+				),
+				fields.New(
+					"execute-rate-limit",
+					fields.TypeString,
+					fields.WithHelp("Requests per window a client may send to /v1/execute, e.g. 30/1m; counted per API key when the request has a valid one, else per IP. Empty for no limit"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"tls-cert",
+					fields.TypeString,
+					fields.WithHelp("PEM certificate file serving the JavaScript web server and the admin interface over HTTPS, with --tls-key"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"tls-key",
+					fields.TypeString,
+					fields.WithHelp("PEM private key file of --tls-cert"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"autocert-domains",
+					fields.TypeStringList,
+					fields.WithHelp("Domains to serve over HTTPS with certificates obtained from Let's Encrypt, instead of --tls-cert (needs --port 443 reachable from the internet)"),
+				),
+				fields.New(
+					"autocert-cache-dir",
+					fields.TypeString,
+					fields.WithHelp("Directory the Let's Encrypt certificates are kept in between restarts"),
+					fields.WithDefault("autocert"),
+				),
+				fields.New(
+					"autocert-email",
+					fields.TypeString,
+					fields.WithHelp("Contact email of the Let's Encrypt account, for expiry notices"),
+					fields.WithDefault(""),
+				),
+				fields.New(
+					"trusted-proxies",
+					fields.TypeStringList,
+					fields.WithHelp("IP addresses or CIDR ranges of reverse proxies whose X-Forwarded-For header names the client for req.ip and rate limits"),
+				),
+				fields.New(
+					"sync-state",
+					fields.TypeBool,
+					fields.WithHelp("Share globalState and route registrations with an MCP server using the same system database"),
+					fields.WithDefault(false),
+				),
 			),
 		),
 	}, nil
@@ -106,111 +586,220 @@ func (c *ServeCmd) Run(ctx context.Context, parsedValues *values.Values) error {
 		return errors.Wrap(err, "failed to parse serve settings")
 	}
 
-	// Find free ports
-	requestedPort, err := strconv.Atoi(s.Port)
-	if err != nil {
-		return errors.Wrapf(err, "invalid port number: %s", s.Port)
+	// Find free ports, unless addresses to listen on are given
+	addr := s.Listen
+	if addr == "" {
+		requestedPort, err := strconv.Atoi(s.Port)
+		if err != nil {
+			return errors.Wrapf(err, "invalid port number: %s", s.Port)
+		}
+
+		actualPort, err := findFreePort(requestedPort)
+		if err != nil {
+			return errors.Wrap(err, "failed to find free port")
+		}
+
+		if actualPort != requestedPort {
+			log.Info().Int("requested_port", requestedPort).Int("actual_port", actualPort).Msg("Requested port was unavailable, using alternative port")
+		}
+		addr = ":" + strconv.Itoa(actualPort)
 	}
 
-	actualPort, err := findFreePort(requestedPort)
-	if err != nil {
-		return errors.Wrap(err, "failed to find free port")
+	// In single-port mode the admin interface shares the port of the JavaScript web server
+	adminAddr := s.AdminListen
+	if !s.SinglePort && adminAddr == "" {
+		requestedAdminPort, err := strconv.Atoi(s.AdminPort)
+		if err != nil {
+			return errors.Wrapf(err, "invalid admin port number: %s", s.AdminPort)
+		}
+
+		actualAdminPort, err := findFreePort(requestedAdminPort)
+		if err != nil {
+			return errors.Wrap(err, "failed to find free admin port")
+		}
+
+		if actualAdminPort != requestedAdminPort {
+			log.Info().Int("requested_admin_port", requestedAdminPort).Int("actual_admin_port", actualAdminPort).Msg("Requested admin port was unavailable, using alternative port")
+		}
+		adminAddr = ":" + strconv.Itoa(actualAdminPort)
 	}
 
-	if actualPort != requestedPort {
-		log.Info().Int("requested_port", requestedPort).Int("actual_port", actualPort).Msg("Requested port was unavailable, using alternative port")
+	// Ensure scripts directory exists, unless the scripts come with the binary
+	if !s.Embedded {
+		if err := os.MkdirAll("scripts", 0755); err != nil {
+			return errors.Wrap(err, "failed to create scripts directory")
+		}
+		log.Debug().Msg("Scripts directory ready")
 	}
 
-	requestedAdminPort, err := strconv.Atoi(s.AdminPort)
+	env, err := engine.LoadEnvironment(s.Env, s.EnvConfig)
 	if err != nil {
-		return errors.Wrapf(err, "invalid admin port number: %s", s.AdminPort)
+		return errors.Wrap(err, "failed to load environment configuration")
 	}
 
-	actualAdminPort, err := findFreePort(requestedAdminPort)
-	if err != nil {
-		return errors.Wrap(err, "failed to find free admin port")
+	var templates map[string]*engine.ExecutionTemplate
+	if s.Templates != "" {
+		if templates, err = engine.LoadExecutionTemplates(s.Templates); err != nil {
+			return errors.Wrap(err, "failed to load execution templates")
+		}
 	}
 
-	if actualAdminPort != requestedAdminPort {
-		log.Info().Int("requested_admin_port", requestedAdminPort).Int("actual_admin_port", actualAdminPort).Msg("Requested admin port was unavailable, using alternative port")
+	var sourcePolicies map[string]engine.ExecutionPolicy
+	if s.Policies != "" {
+		if sourcePolicies, err = engine.LoadSourcePolicies(s.Policies); err != nil {
+			return errors.Wrap(err, "failed to load source policies")
+		}
 	}
 
-	// Ensure scripts directory exists
-	if err := os.MkdirAll("scripts", 0755); err != nil {
-		return errors.Wrap(err, "failed to create scripts directory")
+	var artifactStorage storage.Storage
+	if s.Storage != "" {
+		if artifactStorage, err = storage.Open(s.Storage); err != nil {
+			return errors.Wrap(err, "failed to open storage")
+		}
 	}
-	log.Debug().Msg("Scripts directory ready")
 
-	// Initialize the JavaScript engine.
-	log.Debug().Str("appDatabase", s.AppDB).Str("systemDatabase", s.SystemDB).Msg("Initializing JavaScript engine")
-	jsEngine := engine.NewEngine(s.AppDB, s.SystemDB)
+	httpTimeout, err := time.ParseDuration(s.HTTPTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "invalid HTTP timeout: %s", s.HTTPTimeout)
+	}
 
-	if err := jsEngine.Init("bootstrap.js"); err != nil {
-		log.Warn().Err(err).Msg("Failed to load bootstrap.js")
+	executionTimeout, err := time.ParseDuration(s.ExecutionTimeout)
+	if err != nil {
+		return errors.Wrapf(err, "invalid execution timeout: %s", s.ExecutionTimeout)
 	}
 
-	// Start dispatcher goroutine
-	log.Debug().Msg("Starting JavaScript dispatcher")
-	jsEngine.StartDispatcher()
+	logRetention, err := time.ParseDuration(s.LogRetention)
+	if err != nil {
+		return errors.Wrapf(err, "invalid log retention: %s", s.LogRetention)
+	}
 
-	// Give dispatcher time to start
-	time.Sleep(100 * time.Millisecond)
+	eventRetention, err := time.ParseDuration(s.EventRetention)
+	if err != nil {
+		return errors.Wrapf(err, "invalid event retention: %s", s.EventRetention)
+	}
 
-	// Load scripts from directory if specified
-	if s.ScriptsDir != "" {
-		log.Info().Str("directory", s.ScriptsDir).Msg("Loading scripts from directory")
-		if err := loadScriptsFromDir(jsEngine, s.ScriptsDir); err != nil {
-			return errors.Wrapf(err, "failed to load scripts from directory: %s", s.ScriptsDir)
+	secretSources := engine.SecretSources{EnvPrefix: s.SecretsEnvPrefix, File: s.SecretsFile}
+	if s.SecretsProfileSection != "" {
+		if secretSources.Profile, err = loadProfileSecrets(parsedValues, s.SecretsProfileSection); err != nil {
+			return errors.Wrap(err, "failed to load profile secrets")
 		}
-		log.Info().Msg("Finished loading scripts")
+	}
+	secrets, err := engine.LoadSecrets(secretSources)
+	if err != nil {
+		return errors.Wrap(err, "failed to load secrets")
 	}
 
-	// Setup HTTP routers
-	log.Debug().Msg("Setting up HTTP routers")
-
-	// JS Server router (user-facing, JavaScript endpoints)
-	jsRouter := web.SetupJSRoutes(jsEngine)
-
-	// Admin router (system interface, playground, API)
-	adminRouter := web.SetupRoutesWithAPI(jsEngine, api.ExecuteHandler(jsEngine))
-	log.Debug().Msg("Registered API endpoint: POST /v1/execute")
-
-	// Configure server addresses
-	jsAddr := ":" + strconv.Itoa(actualPort)
-	adminAddr := ":" + strconv.Itoa(actualAdminPort)
-	jsBaseURL := fmt.Sprintf("http://localhost:%d", actualPort)
-	adminBaseURL := fmt.Sprintf("http://localhost:%d", actualAdminPort)
+	opts := jesus.DefaultOptions()
+	opts.Addr = addr
+	opts.AdminAddr = adminAddr
+	opts.SinglePort = s.SinglePort
+	opts.AppDB = s.AppDB
+	opts.SystemDB = s.SystemDB
+	opts.LogArchiveDir = s.LogArchiveDir
+	opts.Storage = artifactStorage
+	opts.LogRetention = logRetention
+	opts.EventLog = s.EventLog
+	opts.EventRetention = eventRetention
+	opts.LogAnonymization = engine.LogAnonymization{
+		Fields: s.LogAnonymizeFields,
+		Salt:   s.LogAnonymizeSalt,
+	}
+	if s.LogAnonymizeIPs != "none" {
+		opts.LogAnonymization.IPs = s.LogAnonymizeIPs
+	}
+	opts.ScriptsDir = s.ScriptsDir
+	if s.Embedded {
+		// The bundle is the whole app, no bootstrap.js is created next to the binary
+		opts.ScriptsFS = bundle.Scripts()
+		opts.BootstrapFile = ""
+	}
+	opts.FilesDir = s.FilesDir
+	opts.Environment = env
+	opts.ExecutionTemplates = templates
+	opts.SourcePolicies = sourcePolicies
+	opts.HTTPClient.Timeout = httpTimeout
+	opts.HTTPClient.MaxPerHost = s.HTTPMaxPerHost
+	opts.HTTPClient.Proxy = s.HTTPProxy
+	opts.HTTPClient.Cassette = s.HTTPCassette
+	opts.HTTPClient.CassetteMode = s.CassetteMode
+	opts.HTTPClient.Network = engine.NetworkPolicy{
+		AllowHosts:   s.AllowHosts,
+		DenyHosts:    s.DenyHosts,
+		BlockPrivate: s.BlockPrivateIPs,
+	}
+	opts.OutputLimits = engine.OutputLimits{
+		MaxResultBytes:     s.MaxResultSize,
+		MaxConsoleLogBytes: s.MaxConsoleLogSize,
+		OverflowDir:        s.OutputDir,
+		PageResultBytes:    s.PageResultSize,
+	}
+	opts.UploadLimits = engine.UploadLimits{
+		MaxFileBytes: int64(s.MaxUploadSize),
+		MaxFiles:     s.MaxUploadFiles,
+		Dir:          s.UploadDir,
+	}
+	opts.TempLimits = engine.TempLimits{
+		Dir:      s.TmpDir,
+		MaxBytes: int64(s.TmpQuota),
+	}
+	opts.RuntimeLimits = engine.RuntimeLimits{
+		MaxCallStackSize: s.MaxCallStackSize,
+		MaxStringLength:  s.MaxStringLength,
+		MaxArrayLength:   s.MaxArrayLength,
+	}
+	opts.ExecutionTimeout = executionTimeout
+	opts.RuntimePoolSize = s.RuntimePoolSize
+	opts.StatementCache = s.DBStatementCache
+	opts.Maintenance = s.Maintenance
+	opts.ReadOnly = s.ReadOnly
+	opts.Compress = s.Compress
+	opts.SanitizeHTML = s.SanitizeHTML
+	opts.ResponseValidation = s.ResponseValidation
+	opts.LocalesDir = s.LocalesDir
+	opts.DefaultLocale = s.DefaultLocale
+	opts.SelfCheck = jesus.SelfCheckMode(s.SelfCheck)
+	opts.WarmUp = s.WarmUp
+	opts.APIKeys = s.APIKeys
+	opts.ExecuteToken = s.ExecuteToken
+	opts.CookieSecrets = s.CookieSecrets
+	opts.Secrets = secrets
+	opts.SyncState = s.SyncState
+	opts.TrustedProxies = s.TrustedProxies
+	opts.TLSCertFile = s.TLSCert
+	opts.TLSKeyFile = s.TLSKey
+	opts.AutocertDomains = s.AutocertDomains
+	opts.AutocertCacheDir = s.AutocertCacheDir
+	opts.AutocertEmail = s.AutocertEmail
+	if len(s.AdminCORSOrigins) > 0 {
+		opts.AdminCORS = &engine.CORSPolicy{Origins: s.AdminCORSOrigins, Credentials: s.AdminCORSCredentials}
+	}
+	if s.ExecuteRateLimit != "" {
+		limiter, err := engine.ParseRateLimit(s.ExecuteRateLimit)
+		if err != nil {
+			return errors.Wrap(err, "invalid execute rate limit")
+		}
+		opts.ExecuteRateLimit = limiter
+	}
 
 	log.Info().
-		Str("js_address", jsAddr).
-		Str("admin_address", adminAddr).
+		Str("js_address", opts.Addr).
+		Str("admin_address", opts.AdminAddr).
 		Str("app_database", s.AppDB).
 		Str("system_database", s.SystemDB).
+		Str("environment", env.Name).
 		Msg("Server configuration")
 
-	if s.ScriptsDir != "" {
-		log.Info().Str("scripts", s.ScriptsDir).Msg("Scripts directory configured")
+	server, err := jesus.New(opts)
+	if err != nil {
+		return errors.Wrap(err, "failed to start JavaScript engine")
 	}
-
-	log.Info().Str("execute_endpoint", adminBaseURL+"/v1/execute").Msg("API endpoint ready")
-	log.Info().Str("js_server", jsBaseURL).Msg("JavaScript web server available")
-	log.Info().Str("admin_interface", adminBaseURL).Msg("Admin interface available")
-	log.Info().Str("admin_logs", adminBaseURL+"/admin/logs").Msg("Admin logs available")
-
-	// Start servers concurrently
-	log.Info().Str("js_address", jsAddr).Msg("Starting JavaScript web server")
-	go func() {
-		if err := http.ListenAndServe(jsAddr, jsRouter); err != nil {
-			log.Fatal().Err(err).Msg("JavaScript web server failed")
+	defer func() {
+		if err := server.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close JavaScript engine")
 		}
 	}()
 
-	log.Info().Str("admin_address", adminAddr).Msg("Starting admin interface server")
-	if err := http.ListenAndServe(adminAddr, adminRouter); err != nil {
-		return errors.Wrap(err, "admin interface server failed")
-	}
-
-	return nil
+	return server.Serve(ctx)
 }
 
 // findFreePort finds a free port starting from the given port
@@ -224,52 +813,3 @@ func findFreePort(startPort int) (int, error) {
 	}
 	return 0, fmt.Errorf("no free port found in range %d-%d", startPort, startPort+99)
 }
-
-// loadScriptsFromDir loads JavaScript files from a directory
-func loadScriptsFromDir(jsEngine *engine.Engine, dir string) error {
-	log.Info().Str("directory", dir).Msg("Loading JavaScript files")
-
-	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Error().Err(err).Str("path", path).Msg("Error accessing file")
-			return err
-		}
-
-		if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".js") {
-			log.Info().Str("file", path).Msg("Loading JavaScript file")
-			data, err := os.ReadFile(path)
-			if err != nil {
-				log.Error().Err(err).Str("file", path).Msg("Failed to read file")
-				return nil // Continue with other files
-			}
-
-			log.Debug().Str("file", path).Int("bytes", len(data)).Msg("Read JavaScript file")
-
-			// Submit to engine with timeout
-			done := make(chan error, 1)
-			job := engine.EvalJob{
-				Code:      string(data),
-				Done:      done,
-				SessionID: "startup-" + filepath.Base(path),
-				Source:    "file",
-			}
-
-			log.Debug().Str("file", path).Msg("Submitting job to engine")
-			jsEngine.SubmitJob(job)
-
-			// Wait for completion with timeout
-			select {
-			case err := <-done:
-				if err != nil {
-					log.Error().Err(err).Str("file", path).Msg("Failed to execute file")
-				} else {
-					log.Info().Str("file", path).Msg("Successfully loaded JavaScript file")
-				}
-			case <-time.After(10 * time.Second):
-				log.Error().Str("file", path).Msg("Timeout waiting for file execution")
-			}
-		}
-
-		return nil
-	})
-}
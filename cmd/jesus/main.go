@@ -111,8 +111,21 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Rollback Bootstrap command
+	rollbackBootstrapCmd, err := cmd.NewRollbackBootstrapCmd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating rollback-bootstrap command: %v\n", err)
+		os.Exit(1)
+	}
+
+	rollbackBootstrapCobraCmd, err := cli.BuildCobraCommandFromCommand(rollbackBootstrapCmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error building rollback-bootstrap command: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Add commands to root
-	rootCmd.AddCommand(serveCobraCmd, executeCobraCmd, testCobraCmd, runScriptsCobraCmd, replCobraCmd)
+	rootCmd.AddCommand(serveCobraCmd, executeCobraCmd, testCobraCmd, runScriptsCobraCmd, replCobraCmd, rollbackBootstrapCobraCmd)
 
 	// Add profiles command for configuration management
 	profilesCmd, err := clay_profiles.NewProfilesCommand("jesus", jesusInitialProfilesContent)
@@ -0,0 +1,477 @@
+// Package jesus embeds the JavaScript web server in Go programs.
+//
+// A Server bundles a JavaScript engine with the user-facing web server, which serves the
+// routes registered by JavaScript, and the admin interface with the /v1/execute API:
+//
+//	opts := jesus.DefaultOptions()
+//	opts.ScriptsDir = "scripts"
+//	server, err := jesus.New(opts)
+//	if err != nil {
+//		return err
+//	}
+//	defer server.Close()
+//	return server.Serve(ctx)
+//
+// Programs that run their own HTTP server can mount Handler and AdminHandler instead of
+// calling Serve. Engine gives access to the engine for everything else, like submitting
+// code or subscribing to engine events.
+package jesus
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/api"
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/storage"
+	"github.com/go-go-golems/jesus/pkg/web"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// SelfCheckMode selects what a failed startup self-check does
+type SelfCheckMode string
+
+const (
+	SelfCheckWarn SelfCheckMode = "warn" // Log problems and serve anyway
+	SelfCheckFail SelfCheckMode = "fail" // Refuse to serve
+	SelfCheckOff  SelfCheckMode = "off"  // Skip the check
+)
+
+// shutdownTimeout is how long Serve waits for in-flight requests when its context ends
+const shutdownTimeout = 5 * time.Second
+
+// Options configures a Server. Start from DefaultOptions and override what you need.
+type Options struct {
+	Addr       string             // Address of the JavaScript web server, host:port or unix:///path/to/socket
+	AdminAddr  string             // Address of the admin interface and /v1/execute like Addr, "" to not serve it
+	SinglePort bool               // Serve the admin interface on Addr too, under web.AdminPaths, instead of on AdminAddr
+	AdminCORS  *engine.CORSPolicy // Cross-origin access to the admin server from browsers, nil for none
+
+	ExecuteRateLimit *engine.RateLimiter // Requests a client may send to /v1/execute, nil for no limit, see engine.ParseRateLimit
+
+	TLSCertFile      string   // PEM certificate serving both servers over HTTPS, with TLSKeyFile
+	TLSKeyFile       string   // PEM private key of TLSCertFile
+	AutocertDomains  []string // Domains to serve with certificates of Let's Encrypt, instead of TLSCertFile
+	AutocertCacheDir string   // Directory the certificates of Let's Encrypt are kept in, "autocert" if ""
+	AutocertEmail    string   // Contact address of the Let's Encrypt account, optional
+
+	AppDB    string // SQLite database exposed to JavaScript as db
+	SystemDB string // SQLite database of execution and request logs
+
+	LogArchiveDir    string                  // Directory request logs are archived to once they leave memory, "" to drop them
+	Storage          storage.Storage         // Keeps attachments, full outputs and log archives instead of SystemDB and the directories, see storage.Open
+	LogRetention     time.Duration           // Age after which log archives are deleted, 0 to keep them
+	EventLog         bool                    // Keep executions, route changes and errors in SystemDB for /v1/events
+	EventRetention   time.Duration           // Age after which events are pruned from the event log, 0 to keep them
+	LogAnonymization engine.LogAnonymization // IPs and user identifiers hidden in request logs
+
+	BootstrapFile string // Run before the scripts; created with default routes if missing, "" to skip
+	ScriptsDir    string // Directory of .js and .ts files loaded on startup, "" for none
+	ScriptsFS     fs.FS  // Scripts loaded on startup before ScriptsDir, such as an embed.FS bundled into the binary, nil for none
+	FilesDir      string // Directory res.sendFile() and app.static() serve from, "" to serve no files
+	LocalesDir    string // Directory of the message catalogs of t(), loaded if it exists
+	DefaultLocale string // Locale t() translates into when none is negotiated, engine.DefaultI18nLocale if ""
+
+	Environment        *engine.Environment                  // Exposed to JavaScript as env, nil for the default environment
+	ExecutionTemplates map[string]*engine.ExecutionTemplate // Runtime templates /v1/execute?env=<name> selects
+	SourcePolicies     map[string]engine.ExecutionPolicy    // Restrictions of code by execution source, see engine.LoadSourcePolicies
+	Secrets            map[string]string                    // Values of secrets.get() by name, see engine.LoadSecrets
+	HTTPClient         engine.HTTPClientConfig
+	OutputLimits       engine.OutputLimits
+	UploadLimits       engine.UploadLimits
+	TempLimits         engine.TempLimits // Location and quota of the temp directory tmp gives each job
+	RuntimeLimits      engine.RuntimeLimits
+	ExecutionTimeout   time.Duration // Time a handler or execution may run, 0 for no limit
+	RuntimePoolSize    int           // Number of runtimes serving requests concurrently
+	StatementCache     int           // Prepared statements of db.prepare() kept, 0 to prepare them on every use
+	Maintenance        bool          // Start with JavaScript routes answering 503
+	ReadOnly           bool          // Refuse code execution and route changes once the scripts are loaded, see engine.SetReadOnly
+	Compress           bool          // Gzip large text responses of routes, unless a route sets compress: false
+	SanitizeHTML       string        // Policy res.send() sanitizes HTML with, engine.SanitizeUGC or engine.SanitizeStrict, "" for none
+	ResponseValidation string        // What responses not matching their route's responseSchema do, engine.ResponseValidationWarn if ""
+	APIKeys            []string      // Keys accepted by routes registered with auth: 'apiKey'
+	ExecuteToken       string        // Bearer token /v1/execute and the REPL endpoint require, "" to leave them open
+	TrustedProxies     []string      // Proxy IPs or CIDR ranges whose X-Forwarded-For names the client
+	CookieSecrets      []string      // Secrets of signed cookies, the first one signing new cookies
+	SelfCheck          SelfCheckMode // Startup check of the bindings once the web server listens
+	WarmUp             bool          // Call the GET routes of the scripts once after loading them, see engine.WarmUp
+	SyncState          bool          // Share globalState and routes with other engines using SystemDB
+}
+
+// DefaultOptions returns the options the serve command uses by default
+func DefaultOptions() Options {
+	return Options{
+		Addr:            ":9922",
+		AdminAddr:       ":9090",
+		AppDB:           "data.sqlite",
+		SystemDB:        "system.sqlite",
+		BootstrapFile:   "bootstrap.js",
+		LocalesDir:      "locales",
+		HTTPClient:      engine.DefaultHTTPClientConfig(),
+		OutputLimits:    engine.DefaultOutputLimits(),
+		UploadLimits:    engine.DefaultUploadLimits(),
+		TempLimits:      engine.DefaultTempLimits(),
+		RuntimeLimits:   engine.DefaultRuntimeLimits(),
+		RuntimePoolSize: 1,
+		StatementCache:  engine.DefaultStatementCacheSize,
+		LogRetention:    7 * 24 * time.Hour,
+		EventLog:        true,
+		EventRetention:  engine.DefaultEventRetention,
+		SelfCheck:       SelfCheckWarn,
+	}
+}
+
+// Server is an embedded JavaScript web server
+type Server struct {
+	opts        Options
+	engine      *engine.Engine
+	handler     http.Handler
+	adminRouter http.Handler
+	tlsConfig   *tls.Config // Serves both servers over HTTPS, nil for plain HTTP
+}
+
+// New creates the engine, runs the bootstrap file and loads the scripts. The engine's
+// dispatcher is running when New returns, so routes can be registered right away.
+func New(opts Options) (*Server, error) {
+	switch opts.SelfCheck {
+	case SelfCheckWarn, SelfCheckFail, SelfCheckOff:
+	case "":
+		opts.SelfCheck = SelfCheckOff
+	default:
+		return nil, fmt.Errorf("invalid self-check mode %q", opts.SelfCheck)
+	}
+
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	jsEngine := engine.NewEngine(opts.AppDB, opts.SystemDB)
+	if err := configureEngine(jsEngine, opts); err != nil {
+		_ = jsEngine.Close()
+		return nil, err
+	}
+
+	// Enabled before any code runs, so the routes of the bootstrap file are logged as well
+	if opts.EventLog {
+		if err := jsEngine.EnableEventLog(opts.EventRetention); err != nil {
+			_ = jsEngine.Close()
+			return nil, fmt.Errorf("failed to enable event log: %w", err)
+		}
+	}
+
+	if opts.BootstrapFile != "" {
+		if err := jsEngine.Init(opts.BootstrapFile); err != nil {
+			log.Warn().Err(err).Str("file", opts.BootstrapFile).Msg("Failed to load bootstrap file")
+		}
+	}
+
+	log.Debug().Msg("Starting JavaScript dispatcher")
+	jsEngine.StartDispatcher()
+
+	if opts.ScriptsFS != nil {
+		if err := jsEngine.LoadScriptsFS(opts.ScriptsFS); err != nil {
+			_ = jsEngine.Close()
+			return nil, fmt.Errorf("failed to load embedded scripts: %w", err)
+		}
+	}
+	if opts.ScriptsDir != "" {
+		log.Info().Str("directory", opts.ScriptsDir).Msg("Loading scripts from directory")
+		if err := jsEngine.LoadScripts(opts.ScriptsDir); err != nil {
+			_ = jsEngine.Close()
+			return nil, fmt.Errorf("failed to load scripts from directory %s: %w", opts.ScriptsDir, err)
+		}
+		log.Info().Msg("Finished loading scripts")
+	}
+
+	// Routes failing their first request are reported before the servers listen
+	if opts.WarmUp {
+		warmUpRoutes(jsEngine)
+	}
+
+	if opts.SyncState {
+		if err := jsEngine.EnableStateSync(); err != nil {
+			_ = jsEngine.Close()
+			return nil, fmt.Errorf("failed to enable state sync: %w", err)
+		}
+	}
+
+	// The bootstrap file and the scripts have registered their routes, freeze them
+	if opts.ReadOnly {
+		jsEngine.SetReadOnly(true, "")
+	}
+
+	return &Server{
+		opts:        opts,
+		engine:      jsEngine,
+		handler:     web.SetupJSRoutes(jsEngine),
+		adminRouter: web.SetupRoutesWithAPI(jsEngine, api.ExecuteHandler(jsEngine)),
+		tlsConfig:   tlsConfig,
+	}, nil
+}
+
+// configureEngine applies the options that must be set before code runs
+func configureEngine(jsEngine *engine.Engine, opts Options) error {
+	if opts.Environment != nil {
+		jsEngine.SetEnvironment(opts.Environment)
+	}
+	if err := jsEngine.SetHTTPClientConfig(opts.HTTPClient); err != nil {
+		return fmt.Errorf("failed to configure HTTP client: %w", err)
+	}
+	if err := jsEngine.SetOutputLimits(opts.OutputLimits); err != nil {
+		return fmt.Errorf("failed to configure output limits: %w", err)
+	}
+	if opts.Storage != nil {
+		if err := jsEngine.SetStorage(opts.Storage); err != nil {
+			return fmt.Errorf("failed to configure storage: %w", err)
+		}
+		if opts.LogArchiveDir != "" {
+			log.Warn().Str("dir", opts.LogArchiveDir).Msg("Archiving request logs to the storage instead of the log archive directory")
+		}
+		jsEngine.GetRequestLogger().SetArchiveStorage(storage.WithPrefix(opts.Storage, "request-logs"), opts.LogRetention)
+	} else if err := jsEngine.GetRequestLogger().SetArchive(opts.LogArchiveDir, opts.LogRetention); err != nil {
+		return fmt.Errorf("failed to configure request log archive: %w", err)
+	}
+	if err := jsEngine.GetRequestLogger().SetAnonymization(opts.LogAnonymization); err != nil {
+		return fmt.Errorf("failed to configure request log anonymization: %w", err)
+	}
+	if opts.FilesDir != "" {
+		if err := jsEngine.SetFilesDir(opts.FilesDir); err != nil {
+			return fmt.Errorf("failed to configure files directory: %w", err)
+		}
+	}
+	jsEngine.SetExecutionTimeout(opts.ExecutionTimeout)
+	if opts.Maintenance {
+		jsEngine.SetMaintenance(true, "")
+	}
+	jsEngine.SetResponseCompression(opts.Compress)
+	if err := jsEngine.SetSanitizeHTML(opts.SanitizeHTML); err != nil {
+		return fmt.Errorf("failed to configure HTML sanitization: %w", err)
+	}
+	if err := jsEngine.SetResponseValidation(opts.ResponseValidation); err != nil {
+		return fmt.Errorf("failed to configure response validation: %w", err)
+	}
+	if err := jsEngine.SetStatementCacheSize(opts.StatementCache); err != nil {
+		return fmt.Errorf("failed to configure the statement cache: %w", err)
+	}
+	if err := jsEngine.SetLocalesDir(opts.LocalesDir, opts.DefaultLocale); err != nil {
+		return fmt.Errorf("failed to configure message catalogs: %w", err)
+	}
+	jsEngine.SetAPIKeys(opts.APIKeys)
+	jsEngine.SetExecuteToken(opts.ExecuteToken)
+	jsEngine.SetWarmUp(opts.WarmUp)
+	jsEngine.SetCookieSecrets(opts.CookieSecrets)
+	if len(opts.Secrets) > 0 {
+		jsEngine.SetSecrets(opts.Secrets)
+	}
+	jsEngine.SetAdminCORS(opts.AdminCORS)
+	jsEngine.SetExecuteRateLimit(opts.ExecuteRateLimit)
+	if err := jsEngine.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return fmt.Errorf("failed to configure trusted proxies: %w", err)
+	}
+	if opts.ExecutionTemplates != nil {
+		if err := jsEngine.SetExecutionTemplates(opts.ExecutionTemplates); err != nil {
+			return fmt.Errorf("failed to configure execution templates: %w", err)
+		}
+	}
+	if opts.SourcePolicies != nil {
+		if err := jsEngine.SetSourcePolicies(opts.SourcePolicies); err != nil {
+			return fmt.Errorf("failed to configure source policies: %w", err)
+		}
+	}
+	if err := jsEngine.SetUploadLimits(opts.UploadLimits); err != nil {
+		return fmt.Errorf("failed to configure upload limits: %w", err)
+	}
+	if err := jsEngine.SetTempLimits(opts.TempLimits); err != nil {
+		return fmt.Errorf("failed to configure temp directories: %w", err)
+	}
+	if err := jsEngine.SetRuntimeLimits(opts.RuntimeLimits); err != nil {
+		return fmt.Errorf("failed to configure runtime limits: %w", err)
+	}
+
+	// The pool must exist before the bootstrap file and the scripts run, so they register
+	// their routes in every runtime
+	if err := jsEngine.SetRuntimePoolSize(opts.RuntimePoolSize); err != nil {
+		return fmt.Errorf("failed to configure runtime pool: %w", err)
+	}
+	return nil
+}
+
+// Engine returns the JavaScript engine of the server
+func (s *Server) Engine() *engine.Engine {
+	return s.engine
+}
+
+// Handler returns the handler serving the routes registered by JavaScript
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// AdminHandler returns the handler of the admin interface and the /v1/execute API
+func (s *Server) AdminHandler() http.Handler {
+	return s.adminRouter
+}
+
+// Serve runs the web server and the admin interface until ctx ends or one of them fails.
+// When ctx ends, in-flight requests get a few seconds to finish and Serve returns nil.
+func (s *Server) Serve(ctx context.Context) error {
+	jsListener, err := listen(s.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for the JavaScript web server: %w", err)
+	}
+	var adminListener net.Listener
+	if s.opts.AdminAddr != "" && !s.opts.SinglePort {
+		if adminListener, err = listen(s.opts.AdminAddr); err != nil {
+			_ = jsListener.Close()
+			return fmt.Errorf("failed to listen for the admin interface: %w", err)
+		}
+	}
+
+	scheme := "http"
+	if s.tlsConfig != nil {
+		scheme = "https"
+		jsListener = tls.NewListener(jsListener, s.tlsConfig)
+		if adminListener != nil {
+			adminListener = tls.NewListener(adminListener, s.tlsConfig)
+		}
+	}
+
+	jsBaseURL := baseURL(jsListener, scheme)
+	log.Info().Str("js_server", jsBaseURL).Msg("JavaScript web server available")
+	jsServer := &http.Server{Handler: s.handler}
+	adminServer := &http.Server{Handler: s.adminRouter}
+	if s.opts.SinglePort {
+		jsServer.Handler = web.SinglePortHandler(s.handler, s.adminRouter)
+		log.Info().Str("execute_endpoint", jsBaseURL+"/v1/execute").Msg("API endpoint ready")
+		log.Info().Str("admin_interface", jsBaseURL+"/admin/logs").Msg("Admin interface available on the JavaScript web server")
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		return serveHTTP(jsServer, jsListener, "JavaScript web server")
+	})
+
+	// The JavaScript web server is listening, so the fetch check can loop back to it, unless
+	// it serves HTTPS with a certificate that rarely names localhost, listens on a socket, or
+	// the network policy keeps scripts from reaching local addresses
+	if s.opts.SelfCheck != SelfCheckOff {
+		loopbackURL := jsBaseURL + web.OpenAPIPath
+		network := s.opts.HTTPClient.Network
+		if s.tlsConfig != nil || isUnixListener(jsListener) || network.BlockPrivate || len(network.AllowHosts) > 0 {
+			loopbackURL = ""
+		}
+		if err := s.runSelfCheck(loopbackURL); err != nil {
+			_ = jsServer.Close()
+			if adminListener != nil {
+				_ = adminListener.Close()
+			}
+			_ = g.Wait()
+			return err
+		}
+	}
+
+	if adminListener != nil {
+		adminBaseURL := baseURL(adminListener, scheme)
+		log.Info().Str("execute_endpoint", adminBaseURL+"/v1/execute").Msg("API endpoint ready")
+		log.Info().Str("admin_interface", adminBaseURL).Msg("Admin interface available")
+		g.Go(func() error {
+			return serveHTTP(adminServer, adminListener, "admin interface server")
+		})
+	}
+
+	g.Go(func() error {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = jsServer.Shutdown(shutdownCtx)
+		_ = adminServer.Shutdown(shutdownCtx)
+		return nil
+	})
+
+	return g.Wait()
+}
+
+// Close stops the engine and closes its databases
+func (s *Server) Close() error {
+	return s.engine.Close()
+}
+
+// runSelfCheck verifies the bindings and logs one line per check. In fail mode, a failed
+// check is returned as error.
+func (s *Server) runSelfCheck(loopbackURL string) error {
+	failFast := s.opts.SelfCheck == SelfCheckFail
+
+	checks, err := s.engine.SelfCheck(loopbackURL)
+	if err != nil {
+		if failFast {
+			return fmt.Errorf("startup self-check failed: %w", err)
+		}
+		log.Warn().Err(err).Msg("Startup self-check could not run")
+		return nil
+	}
+
+	var failed []string
+	for _, check := range checks {
+		switch check.Status {
+		case engine.SelfCheckOK:
+			log.Info().Str("check", check.Name).Msg("Self-check passed: " + check.Message)
+		case engine.SelfCheckWarn:
+			log.Warn().Str("check", check.Name).Msg("Self-check warning: " + check.Message)
+		default:
+			log.Error().Str("check", check.Name).Msg("Self-check failed: " + check.Message)
+			failed = append(failed, check.Name+": "+check.Message)
+		}
+	}
+
+	if len(failed) > 0 && failFast {
+		return fmt.Errorf("startup self-check failed:\n  %s", strings.Join(failed, "\n  "))
+	}
+	return nil
+}
+
+// warmUpRoutes calls every GET route once and logs how many failed; the routes log their
+// own failures
+func warmUpRoutes(jsEngine *engine.Engine) {
+	results := jsEngine.WarmUp("")
+	called, failed := 0, 0
+	for _, result := range results {
+		if result.Skipped == "" {
+			called++
+		}
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		log.Warn().Int("routes", called).Int("failed", failed).Msg("Some routes failed their warm-up request")
+		return
+	}
+	log.Info().Int("routes", called).Int("skipped", len(results)-called).Msg("Warmed up routes")
+}
+
+// serveHTTP serves a listener until the server is shut down
+func serveHTTP(server *http.Server, listener net.Listener, name string) error {
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// baseURL returns the local URL of a listener, or the socket address of a unix listener
+func baseURL(listener net.Listener, scheme string) string {
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		return fmt.Sprintf("%s://localhost:%d", scheme, addr.Port)
+	}
+	if isUnixListener(listener) {
+		return unixScheme + listener.Addr().String()
+	}
+	return scheme + "://" + listener.Addr().String()
+}
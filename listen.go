@@ -0,0 +1,65 @@
+package jesus
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixScheme prefixes the addresses of unix domain sockets, as in unix:///run/jesus.sock
+const unixScheme = "unix://"
+
+// listen opens the listener of an address: a TCP host:port, or a unix domain socket given
+// as unix:///path. The socket file of a server that is no longer running is replaced, and
+// the file is removed again when the listener closes.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixScheme)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("invalid unix socket address %q, use unix:///path/to/socket", addr)
+	}
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Let a reverse proxy in the same group, like nginx, connect
+	if err := os.Chmod(path, 0660); err != nil {
+		_ = listener.Close()
+		return nil, fmt.Errorf("failed to set the permissions of %s: %w", path, err)
+	}
+	return listener, nil
+}
+
+// removeStaleSocket removes a socket file left behind by a server that did not shut down
+// cleanly. A socket that still accepts connections, or a file that is no socket, is kept.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("another server is listening on %s", path)
+	}
+	return os.Remove(path)
+}
+
+// isUnixListener reports whether a listener is a unix domain socket, which loopback
+// requests over HTTP cannot reach
+func isUnixListener(listener net.Listener) bool {
+	return listener.Addr().Network() == "unix"
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// maxEventsLimit bounds the events of one /v1/events answer
+const maxEventsLimit = 1000
+
+// EventsResponse is the answer of /v1/events. Cursor is the after parameter of the next read:
+// the ID of the last event returned, or the cursor of the request if there were none.
+type EventsResponse struct {
+	Events  []repository.EventLogEntry `json:"events"`
+	Cursor  int64                      `json:"cursor"`
+	HasMore bool                       `json:"hasMore"` // More events follow right away
+}
+
+// EventsHandler returns the handler of GET /v1/events, which pages through the event log of
+// the engine, oldest first. Parameters:
+//
+//   - after: cursor of the previous answer, 0 (default) for the oldest kept event, or
+//     "latest" for events from now on
+//   - types: comma-separated event types, like error,route.registered; all if empty
+//   - limit: events per answer, 100 by default, at most 1000
+//   - wait: seconds to wait for new events if there are none yet, for long polling
+//
+// With an execute token set, see Engine.SetExecuteToken, the endpoint requires it too.
+func EventsHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeExecute(jsEngine, w, r) {
+			return
+		}
+
+		query := r.URL.Query()
+		var filter repository.EventLogFilter
+		switch after := query.Get("after"); after {
+		case "":
+		case "latest":
+			latest, err := jsEngine.LatestEventID(r.Context())
+			if err != nil {
+				writeEventsError(w, err)
+				return
+			}
+			filter.After = latest
+		default:
+			n, err := strconv.ParseInt(after, 10, 64)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid after: expected a cursor or latest", http.StatusBadRequest)
+				return
+			}
+			filter.After = n
+		}
+		for _, t := range strings.Split(query.Get("types"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+		filter.Limit = 100
+		if limit := query.Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n <= 0 {
+				http.Error(w, "Invalid limit", http.StatusBadRequest)
+				return
+			}
+			filter.Limit = min(n, maxEventsLimit)
+		}
+		var wait time.Duration
+		if seconds := query.Get("wait"); seconds != "" {
+			n, err := strconv.Atoi(seconds)
+			if err != nil || n < 0 {
+				http.Error(w, "Invalid wait: expected seconds", http.StatusBadRequest)
+				return
+			}
+			wait = time.Duration(n) * time.Second
+		}
+
+		// One more than asked for tells whether more events follow
+		limit := filter.Limit
+		filter.Limit++
+		events, err := jsEngine.Events(r.Context(), filter, wait)
+		if err != nil {
+			writeEventsError(w, err)
+			return
+		}
+
+		response := EventsResponse{Events: events, Cursor: filter.After}
+		if len(events) > limit {
+			response.Events = events[:limit]
+			response.HasMore = true
+		}
+		if n := len(response.Events); n > 0 {
+			response.Cursor = response.Events[n-1].ID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Error().Err(err).Msg("Failed to encode events response")
+		}
+	}
+}
+
+// writeEventsError answers a failed read of the event log
+func writeEventsError(w http.ResponseWriter, err error) {
+	log.Error().Err(err).Msg("Failed to read event log")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   err.Error(),
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode events error")
+	}
+}
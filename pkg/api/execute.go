@@ -4,17 +4,78 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"time"
 
 	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/typescript"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
-// ExecuteHandler returns an HTTP handler for the /v1/execute endpoint
+// SessionIDHeader names the header that selects a stateful session, as alternative to
+// the sessionId query parameter
+const SessionIDHeader = "X-Session-ID"
+
+// ExecuteHandler returns an HTTP handler for the /v1/execute endpoint.
+//
+// Without a session ID every execution gets a fresh tracking ID and runs in the shared
+// runtime. With ?sessionId=... (or the X-Session-ID header) executions run in a dedicated
+// runtime of that session, so variables persist between calls like in a REPL.
+// DELETE /v1/execute?sessionId=... drops the session runtime.
+//
+// With ?env=<name> the code runs in the runtime of an execution template, configured with
+// the serve --execution-templates file. A session started with a template is seeded from it.
+//
+// TypeScript is accepted with ?lang=ts or a TypeScript Content-Type; its type syntax is
+// stripped before the code runs.
+//
+// Executions are stored with source "api". Their actor is the API key the request was
+// authenticated with, see Engine.APIKeyActor; clients cannot name themselves.
+//
+// With an execute token set, see Engine.SetExecuteToken, requests without it as bearer token
+// are answered 401 and logged.
 func ExecuteHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return ExecuteHandlerWithSource(jsEngine, repository.SourceAPI)
+}
+
+// ExecuteHandlerWithSource returns an ExecuteHandler storing its executions with the given
+// source, for the internal endpoints sharing it
+func ExecuteHandlerWithSource(jsEngine *engine.Engine, source string) http.HandlerFunc {
+	return executeHandler(jsEngine, source, false)
+}
+
+// ExecuteREPLHandler returns the handler of the REPL endpoint. It takes the same requests as
+// ExecuteHandler, but stores nothing in the execution history: every line runs in a session
+// runtime, the one named by the session ID or a new one, under a short timeout. Clients
+// send the returned sessionID back to keep their variables; idle sessions expire.
+func ExecuteREPLHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return executeHandler(jsEngine, repository.SourceREPL, true)
+}
+
+func executeHandler(jsEngine *engine.Engine, source string, repl bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeExecute(jsEngine, w, r) {
+			return
+		}
+
+		requestedSession := r.URL.Query().Get("sessionId")
+		if requestedSession == "" {
+			requestedSession = r.Header.Get(SessionIDHeader)
+		}
+
+		if r.Method == http.MethodDelete {
+			closeSession(jsEngine, w, requestedSession)
+			return
+		}
+
+		if err := jsEngine.CheckWritable(); err != nil {
+			rejectReadOnly(w, err)
+			return
+		}
+
 		// Read JavaScript code from request body
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
@@ -29,8 +90,36 @@ func ExecuteHandler(jsEngine *engine.Engine) http.HandlerFunc {
 
 		code := string(body)
 
-		// Generate session ID for tracking
-		sessionID := uuid.New().String()
+		template := r.URL.Query().Get("env")
+		if template != "" {
+			if _, ok := jsEngine.ExecutionTemplate(template); !ok {
+				http.Error(w, fmt.Sprintf("unknown execution template %q", template), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Generate session ID for tracking unless the caller continues a session
+		sessionID := requestedSession
+		if sessionID == "" {
+			sessionID = uuid.New().String()
+		}
+
+		if isTypeScriptRequest(r) {
+			stripped, err := typescript.Strip(code)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				if encodeErr := json.NewEncoder(w).Encode(map[string]interface{}{
+					"success":   false,
+					"error":     err.Error(),
+					"sessionID": sessionID,
+				}); encodeErr != nil {
+					log.Error().Err(encodeErr).Msg("Failed to encode error response")
+				}
+				return
+			}
+			code = stripped
+		}
 
 		// Submit evaluation job with result capture
 		done := make(chan error, 1)
@@ -43,7 +132,11 @@ func ExecuteHandler(jsEngine *engine.Engine) http.HandlerFunc {
 			Done:      done,
 			Result:    resultChan,
 			SessionID: sessionID,
-			Source:    "api",
+			Stateful:  requestedSession != "" || repl,
+			Ephemeral: repl,
+			Template:  template,
+			Source:    source,
+			Actor:     jsEngine.APIKeyActor(r),
 		}
 
 		jsEngine.SubmitJob(job)
@@ -62,8 +155,12 @@ func ExecuteHandler(jsEngine *engine.Engine) http.HandlerFunc {
 
 			// Handle execution error
 			if executionErr != nil {
+				status := http.StatusInternalServerError
+				if engine.IsExecutionTimeout(executionErr) {
+					status = http.StatusGatewayTimeout
+				}
 				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
+				w.WriteHeader(status)
 				if encodeErr := json.NewEncoder(w).Encode(map[string]interface{}{
 					"success":   false,
 					"error":     fmt.Sprintf("JavaScript execution failed: %v", executionErr),
@@ -82,6 +179,18 @@ func ExecuteHandler(jsEngine *engine.Engine) http.HandlerFunc {
 				"sessionID":  sessionID,
 				"message":    "JavaScript code executed and stored in database",
 			}
+			if repl {
+				responseData["message"] = "JavaScript code executed"
+			}
+			if template != "" {
+				responseData["env"] = template
+			}
+			if len(result.Attachments) > 0 {
+				responseData["attachments"] = result.Attachments
+			}
+			if result.Changes != nil {
+				responseData["changes"] = result.Changes
+			}
 
 			// Return JSON response
 			w.Header().Set("Content-Type", "application/json")
@@ -104,3 +213,75 @@ func ExecuteHandler(jsEngine *engine.Engine) http.HandlerFunc {
 		}
 	}
 }
+
+// authorizeExecute checks the execute token of a request. Refused requests are answered
+// 401 and logged with the client and the reason, so attempts to run code or read the event
+// log can be audited.
+func authorizeExecute(jsEngine *engine.Engine, w http.ResponseWriter, r *http.Request) bool {
+	ok, reason := jsEngine.CheckExecuteToken(r)
+	if ok {
+		return true
+	}
+
+	log.Warn().
+		Str("client", jsEngine.ClientIP(r)).
+		Str("method", r.Method).
+		Str("path", r.URL.Path).
+		Str("userAgent", r.UserAgent()).
+		Str("reason", reason).
+		Msg("Rejected request without a valid execute token")
+
+	w.Header().Set("WWW-Authenticate", `Bearer realm="execute"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   "Unauthorized: send the execute token as Authorization: Bearer <token>",
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode unauthorized response")
+	}
+	return false
+}
+
+// rejectReadOnly answers a code execution request refused because read-only mode is on
+func rejectReadOnly(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  false,
+		"error":    "Code execution is disabled: " + err.Error(),
+		"readOnly": true,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode read-only response")
+	}
+}
+
+// isTypeScriptRequest reports whether the request body is TypeScript, selected by the lang
+// query parameter or the Content-Type header
+func isTypeScriptRequest(r *http.Request) bool {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		return typescript.IsTypeScript(lang)
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && typescript.IsTypeScript(mediaType)
+}
+
+// closeSession drops the dedicated runtime of a session
+func closeSession(jsEngine *engine.Engine, w http.ResponseWriter, sessionID string) {
+	if sessionID == "" {
+		http.Error(w, "sessionId is required", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	closed := jsEngine.CloseSession(sessionID)
+	if !closed {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   closed,
+		"sessionID": sessionID,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode session response")
+	}
+}
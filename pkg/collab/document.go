@@ -0,0 +1,277 @@
+package collab
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"unicode/utf16"
+
+	"github.com/google/uuid"
+)
+
+// Protocol
+//
+// A client joins a document and gets an init message with the text and its revision, the
+// number of operations applied to it. It then sends each edit as an op message with the
+// revision it was made at, and waits for the ack before sending the next one, buffering
+// the edits made meanwhile. The server transforms the operation against the ones applied
+// since that revision, applies it and sends it to the other clients, which transform it
+// against their own unacknowledged edits. Everybody ends up with the same text.
+
+// historyLimit is how many operations a document keeps to transform late edits against.
+// A client more revisions behind gets an error and has to join again.
+const historyLimit = 1000
+
+// Message types
+const (
+	MessageJoin      = "join"      // client: first message, with the name of the participant
+	MessageInit      = "init"      // server: the text, its revision and the participants
+	MessageOp        = "op"        // both: an edit, sent by the server to the other participants
+	MessageAck       = "ack"       // server: the edit of the client was applied
+	MessageSelection = "selection" // client: the cursor moved
+	MessagePresence  = "presence"  // server: the participants and their cursors changed
+	MessageSave      = "save"      // client: write the text to where it came from
+	MessageSaved     = "saved"     // server: the text was written
+	MessageError     = "error"     // server: a message was refused
+)
+
+// Message is a message of the editing protocol, sent as JSON
+type Message struct {
+	Type         string        `json:"type"`
+	ID           string        `json:"id,omitempty"`        // Participant who sent the edit; the client's own ID in init
+	Name         string        `json:"name,omitempty"`      // Name of the participant joining, or of who saved
+	Key          string        `json:"key,omitempty"`       // API key of the participant joining
+	Revision     int           `json:"revision"`            // Revision an op was made at; the new revision in ack, op sent by the server, init and saved
+	Op           *Operation    `json:"op,omitempty"`        // Edit of an op message
+	Selection    *Selection    `json:"selection,omitempty"` // Cursor after an op, or in a selection message
+	Text         *string       `json:"text,omitempty"`      // Text of init
+	Participants []Participant `json:"participants,omitempty"`
+	Dirty        bool          `json:"dirty"`           // The text differs from the saved one
+	Error        string        `json:"error,omitempty"` // Why a message was refused or a save failed
+}
+
+// Selection is a cursor or selected range, in UTF-16 code units from the start of the text
+type Selection struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// transform moves the selection along an operation
+func (s *Selection) transform(op Operation) *Selection {
+	if s == nil {
+		return nil
+	}
+	return &Selection{Anchor: op.TransformIndex(s.Anchor), Head: op.TransformIndex(s.Head)}
+}
+
+// Participant is a client editing a document
+type Participant struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Color     string     `json:"color"`
+	Selection *Selection `json:"selection,omitempty"`
+
+	send func(Message)
+}
+
+// participantColors tell participants apart in editors
+var participantColors = []string{"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4", "#f032e6", "#9a6324"}
+
+// Document is a text edited by several participants. It remembers the last saved text, so
+// writes made elsewhere can be merged with the unsaved edits.
+type Document struct {
+	mu           sync.Mutex
+	text         []uint16
+	revision     int
+	history      []Operation // Last operations applied, the last one giving revision
+	saved        []uint16    // Text last saved
+	savedRev     int         // Revision the saved text was the text of
+	participants []*Participant
+	colors       int
+}
+
+// NewDocument returns a document of the saved text
+func NewDocument(text string) *Document {
+	encoded := utf16.Encode([]rune(text))
+	return &Document{text: encoded, saved: encoded}
+}
+
+// Join adds a participant, which is sent the init message. send must not block, nor call
+// methods of the document.
+func (d *Document) Join(name string, send func(Message)) *Participant {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p := &Participant{
+		ID:    uuid.New().String(),
+		Name:  name,
+		Color: participantColors[d.colors%len(participantColors)],
+		send:  send,
+	}
+	d.colors++
+	d.participants = append(d.participants, p)
+
+	text := string(utf16.Decode(d.text))
+	send(Message{Type: MessageInit, ID: p.ID, Revision: d.revision, Text: &text, Participants: d.participantsLocked(), Dirty: d.dirtyLocked()})
+	d.broadcastLocked(p, Message{Type: MessagePresence, Participants: d.participantsLocked()})
+	return p
+}
+
+// Leave removes a participant and reports whether the document has none left
+func (d *Document) Leave(p *Participant) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.participants = slices.DeleteFunc(d.participants, func(other *Participant) bool { return other == p })
+	d.broadcastLocked(nil, Message{Type: MessagePresence, Participants: d.participantsLocked()})
+	return len(d.participants) == 0
+}
+
+// Edit applies an operation a participant made at a revision, acknowledging it to the
+// participant and sending it to the others
+func (d *Document) Edit(p *Participant, revision int, op Operation, selection *Selection) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	concurrent, err := d.sinceLocked(revision)
+	if err != nil {
+		return err
+	}
+	for _, other := range concurrent {
+		if op, _, err = Transform(op, other); err != nil {
+			return err
+		}
+		selection = selection.transform(other)
+	}
+	if err := d.applyLocked(op); err != nil {
+		return err
+	}
+	p.Selection = selection
+	p.send(Message{Type: MessageAck, Revision: d.revision, Dirty: d.dirtyLocked()})
+	d.broadcastLocked(p, Message{Type: MessageOp, ID: p.ID, Revision: d.revision, Op: &op, Selection: selection, Dirty: d.dirtyLocked()})
+	return nil
+}
+
+// Select moves the cursor of a participant, placed at a revision
+func (d *Document) Select(p *Participant, revision int, selection *Selection) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	concurrent, err := d.sinceLocked(revision)
+	if err != nil {
+		return err
+	}
+	for _, other := range concurrent {
+		selection = selection.transform(other)
+	}
+	p.Selection = selection
+	d.broadcastLocked(p, Message{Type: MessagePresence, Participants: d.participantsLocked()})
+	return nil
+}
+
+// Text returns the text and its revision
+func (d *Document) Text() (string, int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return string(utf16.Decode(d.text)), d.revision
+}
+
+// Saved records that the text of a revision was saved by who, telling the participants;
+// errMessage is why the saved text failed to run, if it did
+func (d *Document) Saved(text string, revision int, who, errMessage string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.saved = utf16.Encode([]rune(text))
+	d.savedRev = revision
+	d.broadcastLocked(nil, Message{Type: MessageSaved, Name: who, Revision: revision, Dirty: d.dirtyLocked(), Error: errMessage})
+}
+
+// Merge applies a text saved elsewhere by who: the changes it makes to the last saved text
+// are merged with the unsaved edits, like an edit of a participant made at the saved
+// revision. It returns whether the document now has the saved text.
+func (d *Document) Merge(text, who string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	encoded := utf16.Encode([]rune(text))
+	op := Diff(d.saved, encoded)
+	concurrent, err := d.sinceLocked(d.savedRev)
+	if err != nil {
+		// The saved revision is too old to transform from, replace the text instead
+		op, concurrent = Diff(d.text, encoded), nil
+	}
+	for _, other := range concurrent {
+		if op, _, err = Transform(op, other); err != nil {
+			return false, err
+		}
+	}
+	if !op.IsNoop() {
+		if err := d.applyLocked(op); err != nil {
+			return false, err
+		}
+	}
+	d.saved = encoded
+	d.savedRev = d.revision
+	if !op.IsNoop() {
+		d.broadcastLocked(nil, Message{Type: MessageOp, Name: who, Revision: d.revision, Op: &op, Dirty: d.dirtyLocked()})
+	}
+	d.broadcastLocked(nil, Message{Type: MessageSaved, Name: who, Revision: d.revision, Dirty: d.dirtyLocked()})
+	return !d.dirtyLocked(), nil
+}
+
+// Broadcast sends a message to all participants
+func (d *Document) Broadcast(msg Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.broadcastLocked(nil, msg)
+}
+
+// sinceLocked returns the operations applied after a revision, oldest first
+func (d *Document) sinceLocked(revision int) ([]Operation, error) {
+	if revision < 0 || revision > d.revision {
+		return nil, fmt.Errorf("revision %d is unknown, the document is at revision %d", revision, d.revision)
+	}
+	if d.revision-revision > len(d.history) {
+		return nil, fmt.Errorf("revision %d is too old, join the document again", revision)
+	}
+	return d.history[len(d.history)-(d.revision-revision):], nil
+}
+
+// applyLocked applies an operation, moving the cursors of the participants along
+func (d *Document) applyLocked(op Operation) error {
+	text, err := op.Apply(d.text)
+	if err != nil {
+		return err
+	}
+	d.text = text
+	d.revision++
+	d.history = append(d.history, op)
+	if len(d.history) > historyLimit {
+		d.history = slices.Clone(d.history[len(d.history)-historyLimit:])
+	}
+	for _, p := range d.participants {
+		p.Selection = p.Selection.transform(op)
+	}
+	return nil
+}
+
+// dirtyLocked reports whether the text differs from the saved one
+func (d *Document) dirtyLocked() bool {
+	return !slices.Equal(d.text, d.saved)
+}
+
+// participantsLocked returns a copy of the participants, for messages
+func (d *Document) participantsLocked() []Participant {
+	participants := make([]Participant, len(d.participants))
+	for i, p := range d.participants {
+		participants[i] = Participant{ID: p.ID, Name: p.Name, Color: p.Color, Selection: p.Selection}
+	}
+	return participants
+}
+
+// broadcastLocked sends a message to the participants but except
+func (d *Document) broadcastLocked(except *Participant, msg Message) {
+	for _, p := range d.participants {
+		if p != except {
+			p.send(msg)
+		}
+	}
+}
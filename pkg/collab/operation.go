@@ -0,0 +1,282 @@
+// Package collab lets several clients edit the same text at once. Edits are operations
+// transformed against the ones applied concurrently (operational transformation), with the
+// operation format and transformation rules of ot.js, so browsers and the server agree on
+// the resulting text.
+package collab
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Operation is an edit of a whole text: components retaining, deleting or inserting
+// characters, walking the text from its start. Lengths count UTF-16 code units like
+// JavaScript strings, so positions are the ones of the browser.
+//
+// In JSON it is the array of ot.js: a positive number retains that many characters, a
+// negative number deletes them and a string inserts itself, e.g. [5, -3, "abc", 12].
+type Operation struct {
+	components []component
+	baseLen    int // Length of the text the operation applies to
+	targetLen  int // Length of the text it produces
+}
+
+// component is one step of an operation: retain n > 0, delete -n for n < 0, else insert s
+type component struct {
+	n int
+	s string
+}
+
+func (c component) isRetain() bool { return c.n > 0 }
+func (c component) isDelete() bool { return c.n < 0 }
+func (c component) isInsert() bool { return c.n == 0 }
+
+// textLen returns the length of s in UTF-16 code units
+func textLen(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// BaseLen returns the length of the text the operation applies to
+func (op Operation) BaseLen() int {
+	return op.baseLen
+}
+
+// TargetLen returns the length of the text the operation produces
+func (op Operation) TargetLen() int {
+	return op.targetLen
+}
+
+// IsNoop reports whether the operation leaves the text as it is
+func (op Operation) IsNoop() bool {
+	return len(op.components) == 0 || len(op.components) == 1 && op.components[0].isRetain()
+}
+
+// Retain skips n characters
+func (op *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return op
+	}
+	op.baseLen += n
+	op.targetLen += n
+	if last := len(op.components) - 1; last >= 0 && op.components[last].isRetain() {
+		op.components[last].n += n
+	} else {
+		op.components = append(op.components, component{n: n})
+	}
+	return op
+}
+
+// Insert inserts s. An insert following a delete is moved before it, so equal edits have
+// the same components.
+func (op *Operation) Insert(s string) *Operation {
+	if s == "" {
+		return op
+	}
+	op.targetLen += textLen(s)
+	last := len(op.components) - 1
+	switch {
+	case last >= 0 && op.components[last].isInsert():
+		op.components[last].s += s
+	case last >= 0 && op.components[last].isDelete():
+		if last > 0 && op.components[last-1].isInsert() {
+			op.components[last-1].s += s
+		} else {
+			op.components = append(op.components, op.components[last])
+			op.components[last] = component{s: s}
+		}
+	default:
+		op.components = append(op.components, component{s: s})
+	}
+	return op
+}
+
+// Delete deletes n characters
+func (op *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return op
+	}
+	op.baseLen += n
+	if last := len(op.components) - 1; last >= 0 && op.components[last].isDelete() {
+		op.components[last].n -= n
+	} else {
+		op.components = append(op.components, component{n: -n})
+	}
+	return op
+}
+
+// Apply returns the text the operation turns text into
+func (op Operation) Apply(text []uint16) ([]uint16, error) {
+	if len(text) != op.baseLen {
+		return nil, fmt.Errorf("operation applies to a text of %d characters, not %d", op.baseLen, len(text))
+	}
+	result := make([]uint16, 0, op.targetLen)
+	pos := 0
+	for _, c := range op.components {
+		switch {
+		case c.isRetain():
+			result = append(result, text[pos:pos+c.n]...)
+			pos += c.n
+		case c.isDelete():
+			pos -= c.n
+		default:
+			result = append(result, utf16.Encode([]rune(c.s))...)
+		}
+	}
+	return result, nil
+}
+
+// Transform returns the operations a' and b' such that applying a then b' gives the text
+// of applying b then a'. Both must apply to the same text; at the same position, the
+// insert of a goes first.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.baseLen != b.baseLen {
+		return Operation{}, Operation{}, fmt.Errorf("operations apply to texts of %d and %d characters", a.baseLen, b.baseLen)
+	}
+
+	var aPrime, bPrime Operation
+	ops1, ops2 := a.components, b.components
+	i1, i2 := 0, 0
+	next := func(ops []component, i *int) (component, bool) {
+		if *i >= len(ops) {
+			return component{}, false
+		}
+		*i++
+		return ops[*i-1], true
+	}
+	op1, ok1 := next(ops1, &i1)
+	op2, ok2 := next(ops2, &i2)
+	for ok1 || ok2 {
+		if ok1 && op1.isInsert() {
+			aPrime.Insert(op1.s)
+			bPrime.Retain(textLen(op1.s))
+			op1, ok1 = next(ops1, &i1)
+			continue
+		}
+		if ok2 && op2.isInsert() {
+			aPrime.Retain(textLen(op2.s))
+			bPrime.Insert(op2.s)
+			op2, ok2 = next(ops2, &i2)
+			continue
+		}
+		if !ok1 || !ok2 {
+			return Operation{}, Operation{}, fmt.Errorf("operations do not cover the same text")
+		}
+
+		switch {
+		case op1.isRetain() && op2.isRetain():
+			n := min(op1.n, op2.n)
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+			op1.n -= n
+			op2.n -= n
+		case op1.isDelete() && op2.isDelete():
+			// Both delete the same characters
+			n := min(-op1.n, -op2.n)
+			op1.n += n
+			op2.n += n
+		case op1.isDelete() && op2.isRetain():
+			n := min(-op1.n, op2.n)
+			aPrime.Delete(n)
+			op1.n += n
+			op2.n -= n
+		default: // op1 retains, op2 deletes
+			n := min(op1.n, -op2.n)
+			bPrime.Delete(n)
+			op1.n -= n
+			op2.n += n
+		}
+		if op1.n == 0 {
+			op1, ok1 = next(ops1, &i1)
+		}
+		if op2.n == 0 {
+			op2, ok2 = next(ops2, &i2)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// TransformIndex moves a position of the text to where its character is once the operation
+// applied, for cursors
+func (op Operation) TransformIndex(index int) int {
+	newIndex := index
+	for _, c := range op.components {
+		switch {
+		case c.isRetain():
+			index -= c.n
+		case c.isInsert():
+			newIndex += textLen(c.s)
+		default:
+			newIndex -= min(index, -c.n)
+			index += c.n
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return newIndex
+}
+
+// Diff returns an operation turning from into to, replacing what lies between their common
+// prefix and suffix
+func Diff(from, to []uint16) Operation {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+
+	var op Operation
+	op.Retain(prefix)
+	op.Delete(len(from) - prefix - suffix)
+	op.Insert(string(utf16.Decode(to[prefix : len(to)-suffix])))
+	op.Retain(suffix)
+	return op
+}
+
+// MarshalJSON encodes the operation in the format of ot.js
+func (op Operation) MarshalJSON() ([]byte, error) {
+	components := make([]interface{}, len(op.components))
+	for i, c := range op.components {
+		if c.isInsert() {
+			components[i] = c.s
+		} else {
+			components[i] = c.n
+		}
+	}
+	return json.Marshal(components)
+}
+
+// UnmarshalJSON decodes an operation in the format of ot.js
+func (op *Operation) UnmarshalJSON(data []byte) error {
+	var components []interface{}
+	if err := json.Unmarshal(data, &components); err != nil {
+		return err
+	}
+	*op = Operation{}
+	for _, c := range components {
+		switch v := c.(type) {
+		case string:
+			op.Insert(v)
+		case float64:
+			if v != float64(int(v)) || v == 0 {
+				return fmt.Errorf("invalid operation component %v", v)
+			}
+			if v > 0 {
+				op.Retain(int(v))
+			} else {
+				op.Delete(int(-v))
+			}
+		default:
+			return fmt.Errorf("invalid operation component %v", c)
+		}
+	}
+	return nil
+}
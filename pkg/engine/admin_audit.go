@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Admin audit log
+//
+// Changes made through the admin server, such as clearing logs, setting globalState,
+// resetting the runtimes or running code from the playground, are recorded in the
+// admin_audit table of the system database: who made the change, when, the request it was
+// made with and the start of its payload. The audit page (/admin/audit) lists them.
+
+// maxAuditSummary bounds the payload summary stored with an admin action, in characters
+const maxAuditSummary = 240
+
+var errNoAuditStore = errors.New("the admin audit log needs the system database")
+
+// AdminActor names who sent an admin request: the API key it was authenticated with, else
+// the client IP
+func (e *Engine) AdminActor(r *http.Request) string {
+	if actor := e.APIKeyActor(r); actor != "" {
+		return actor
+	}
+	return "ip:" + e.ClientIP(r)
+}
+
+// RecordAdminAction stores an admin change in the audit log. Failures are logged, not
+// returned, so the audit log cannot break the admin interface.
+func (e *Engine) RecordAdminAction(r *http.Request, action string, payload []byte, status int) {
+	entry := repository.AdminAuditEntry{
+		Actor:   e.AdminActor(r),
+		Action:  action,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Summary: e.redactSecrets(summarizeAuditPayload(payload)),
+		Status:  status,
+	}
+	log.Info().Str("actor", entry.Actor).Str("action", action).Str("path", entry.Path).Int("status", status).Msg("Admin action")
+
+	if e.repos == nil {
+		return
+	}
+	if _, err := e.repos.AdminAudit().RecordAction(context.Background(), entry); err != nil {
+		log.Error().Err(err).Str("action", action).Msg("Failed to record admin action")
+	}
+}
+
+// AdminActions returns the recorded admin changes matching the filter, newest first
+func (e *Engine) AdminActions(filter repository.AdminAuditFilter) ([]repository.AdminAuditEntry, error) {
+	if e.repos == nil {
+		return nil, errNoAuditStore
+	}
+	return e.repos.AdminAudit().ListActions(context.Background(), filter)
+}
+
+// summarizeAuditPayload returns the start of a request payload on one line. The payload may
+// be cut short, even inside a character.
+func summarizeAuditPayload(payload []byte) string {
+	text := string(payload)
+	if !utf8.ValidString(text) {
+		valid := strings.ToValidUTF8(text, "")
+		if len(text)-len(valid) >= utf8.UTFMax {
+			return fmt.Sprintf("(%d bytes of binary data)", len(payload))
+		}
+		text = valid
+	}
+	summary := strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(summary) > maxAuditSummary {
+		runes := []rune(summary)
+		summary = string(runes[:maxAuditSummary]) + "…"
+	}
+	return summary
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+)
+
+// assertHTTP issues an in-process request to a registered route and throws when the
+// response does not match the expectations. It runs on the dispatcher goroutine, so the
+// handler is called directly instead of being queued.
+// Usage: assertHTTP(method, path, { status, body, contains, json, headers, request: { body, headers } })
+func (e *Engine) assertHTTP(method, path string, opts map[string]interface{}) map[string]interface{} {
+	method = strings.ToUpper(method)
+
+	req, err := newAssertRequest(method, path, opts["request"])
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("assertHTTP %s %s: %w", method, path, err)))
+	}
+
+	recorder := httptest.NewRecorder()
+	var handlerErr error
+	if handler, ok := e.GetHandler(method, req.URL.Path); ok {
+		// Keep the inner request out of the request log of the code being executed
+		outerReqID := e.currentReqID
+		e.currentReqID = ""
+		job := EvalJob{Handler: handler, W: recorder, R: req}
+		var pending *pendingHandler
+		pending, handlerErr = e.executeHandler(job)
+		if pending != nil && pending.promise != nil {
+			// The promise can only settle once the code calling assertHTTP returns
+			handlerErr = e.completeHandler(job, pending.resObj, 0, fmt.Errorf("handler awaits a promise, which assertHTTP cannot wait for"))
+		} else if pending != nil {
+			// Check what was streamed so far and close the response stream
+			handlerErr = e.completeHandler(job, pending.resObj, 0, nil)
+		}
+		e.currentReqID = outerReqID
+	} else {
+		http.NotFound(recorder, req)
+	}
+
+	body := recorder.Body.String()
+	headers := make(map[string]interface{})
+	for name := range recorder.Header() {
+		headers[name] = recorder.Header().Get(name)
+	}
+	response := map[string]interface{}{
+		"status":  recorder.Code,
+		"body":    body,
+		"headers": headers,
+	}
+	var parsed interface{}
+	if json.Unmarshal([]byte(body), &parsed) == nil {
+		response["json"] = parsed
+	}
+
+	if failures := checkAssertExpectations(opts, recorder, parsed); len(failures) > 0 {
+		message := fmt.Sprintf("assertHTTP %s %s failed:\n%s", method, path, strings.Join(failures, "\n"))
+		if handlerErr != nil {
+			message += "\n  handler error: " + handlerErr.Error()
+		}
+		panic(e.rt.NewGoError(fmt.Errorf("%s", message)))
+	}
+
+	log.Debug().Str("method", method).Str("path", path).Int("status", recorder.Code).Msg("assertHTTP passed")
+	return response
+}
+
+// newAssertRequest builds the request for assertHTTP from the optional request options
+func newAssertRequest(method, path string, requestOpts interface{}) (*http.Request, error) {
+	var body string
+	var headers map[string]interface{}
+	if opts, ok := requestOpts.(map[string]interface{}); ok {
+		switch b := opts["body"].(type) {
+		case nil:
+		case string:
+			body = b
+		default:
+			data, err := json.Marshal(b)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode request body: %w", err)
+			}
+			body = string(data)
+			headers = map[string]interface{}{"Content-Type": "application/json"}
+		}
+		if h, ok := opts["headers"].(map[string]interface{}); ok {
+			if headers == nil {
+				headers = make(map[string]interface{})
+			}
+			for name, value := range h {
+				headers[name] = value
+			}
+		}
+	}
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, value := range headers {
+		req.Header.Set(name, fmt.Sprint(value))
+	}
+	return req, nil
+}
+
+// checkAssertExpectations compares a response against the assertHTTP expectations and
+// returns one readable failure description per mismatch
+func checkAssertExpectations(opts map[string]interface{}, recorder *httptest.ResponseRecorder, parsed interface{}) []string {
+	var failures []string
+	body := recorder.Body.String()
+
+	if expected, ok := opts["status"]; ok {
+		if fmt.Sprint(expected) != fmt.Sprint(recorder.Code) {
+			failures = append(failures, fmt.Sprintf("  status: expected %v, got %d", expected, recorder.Code))
+		}
+	}
+
+	if expected, ok := opts["body"].(string); ok && expected != body {
+		failures = append(failures, "  body:\n"+textDiff(expected, body))
+	}
+
+	if expected, ok := opts["contains"].(string); ok && !strings.Contains(body, expected) {
+		failures = append(failures, fmt.Sprintf("  body: expected to contain %q, got %q", expected, body))
+	}
+
+	if expected, ok := opts["json"]; ok {
+		// Round-trip the expectation so numbers compare the same way as the decoded body
+		var normalized interface{}
+		if data, err := json.Marshal(expected); err == nil {
+			_ = json.Unmarshal(data, &normalized)
+		}
+		if parsed == nil && body != "null" {
+			failures = append(failures, fmt.Sprintf("  json: response body is not JSON: %q", body))
+		} else if !reflect.DeepEqual(normalized, parsed) {
+			failures = append(failures, "  json:\n"+textDiff(indentJSON(normalized), indentJSON(parsed)))
+		}
+	}
+
+	if expected, ok := opts["headers"].(map[string]interface{}); ok {
+		for name, value := range expected {
+			if actual := recorder.Header().Get(name); actual != fmt.Sprint(value) {
+				failures = append(failures, fmt.Sprintf("  header %s: expected %q, got %q", name, fmt.Sprint(value), actual))
+			}
+		}
+	}
+
+	return failures
+}
+
+// textDiff renders a unified diff of expected and actual text
+func textDiff(expected, actual string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(expected),
+		B:        difflib.SplitLines(actual),
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  2,
+	})
+	if err != nil {
+		return fmt.Sprintf("    expected %q\n    actual   %q", expected, actual)
+	}
+	return "    " + strings.ReplaceAll(strings.TrimRight(diff, "\n"), "\n", "\n    ")
+}
+
+// indentJSON pretty-prints a value for diffing
+func indentJSON(value interface{}) string {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
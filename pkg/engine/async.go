@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Async operations
+//
+// Async bindings such as fetchAsync return a Promise and do their Go work on a separate
+// goroutine, so the dispatcher keeps serving other jobs in the meantime. When the work is
+// done, a job queued on the dispatcher of the runtime that owns the promise settles it, which
+// runs the JavaScript continuations under the execution timeout. Route handlers may return a
+// promise (async functions); their response is finished once it settles.
+
+// asyncTask runs JavaScript for an event that happened outside the dispatcher, like a
+// finished async operation or a WebSocket message
+type asyncTask struct {
+	rt        *goja.Runtime // Runtime owning the promise
+	sessionID string        // Session whose runtime owns the promise
+	reqID     string        // Request the continuation belongs to, for the request log
+	origin    string        // Route or execution source that started the operation
+	run       func() error  // Runs on the dispatcher, e.g. settling the promise
+}
+
+// startAsync creates a promise and runs work on a separate goroutine. The value work returns
+// resolves the promise on the dispatcher; an error rejects it.
+func (e *Engine) startAsync(work func() (interface{}, error)) *goja.Promise {
+	promise, resolve, reject := e.rt.NewPromise()
+	task := &asyncTask{
+		rt:     e.rt,
+		reqID:  e.currentReqID,
+		origin: e.currentSource,
+	}
+	if e.activeSession != "" {
+		task.sessionID = e.activeSession
+	}
+
+	go func() {
+		value, err := work()
+		task.run = func() error {
+			if err != nil {
+				return reject(task.rt.NewGoError(err))
+			}
+			return resolve(value)
+		}
+		e.jobs <- EvalJob{async: task, SessionID: task.sessionID, Source: task.origin}
+	}()
+	return promise
+}
+
+// runAsync runs an async task. Only the dispatcher calls it.
+func (e *Engine) runAsync(task *asyncTask) error {
+	if task.sessionID != "" {
+		e.mu.RLock()
+		session, ok := e.sessions[task.sessionID]
+		e.mu.RUnlock()
+		if !ok || session.rt != task.rt {
+			log.Debug().Str("sessionID", task.sessionID).Msg("Dropped async result of closed session")
+			return nil
+		}
+		defer e.enterSession(task.sessionID)()
+	}
+
+	e.currentReqID = task.reqID
+	defer func() {
+		e.currentReqID = ""
+	}()
+
+	stopTimeout := e.interruptAfter(e.jobTimeout(EvalJob{}))
+	err := task.run()
+	stopTimeout()
+	err = e.limitError(err)
+	if err != nil {
+		log.Error().Err(err).Str("origin", task.origin).Msg("Async continuation failed")
+	}
+	return err
+}
+
+// pendingPromise returns the promise a handler returned if it has not settled yet
+func pendingPromise(v goja.Value) *goja.Promise {
+	if v == nil {
+		return nil
+	}
+	if promise, ok := v.Export().(*goja.Promise); ok && promise.State() == goja.PromiseStatePending {
+		return promise
+	}
+	return nil
+}
+
+// awaitHandler finishes the response of a handler once the promise it returned settles, or
+// answers 504 when the promise takes longer than the handler timeout. finish completes the
+// job with the handler's error.
+func (e *Engine) awaitHandler(job EvalJob, resObj *ExpressResponse, promise *goja.Promise, finish func(error)) {
+	timeout := e.jobTimeout(job)
+	settled := false
+	var timer *time.Timer
+
+	complete := func(err error) {
+		if settled {
+			return
+		}
+		settled = true
+		if timer != nil {
+			timer.Stop()
+		}
+		if err == nil && resObj.IsStreaming() {
+			// The handler left its response stream open; the job finishes once it closes
+			e.awaitStream(job, resObj, finish)
+			return
+		}
+		finish(e.completeHandler(job, resObj, timeout, err))
+	}
+
+	onFulfilled := func(goja.FunctionCall) goja.Value {
+		complete(nil)
+		return goja.Undefined()
+	}
+	onRejected := func(call goja.FunctionCall) goja.Value {
+		complete(rejectionError(call.Argument(0)))
+		return goja.Undefined()
+	}
+
+	promiseObj := e.rt.ToValue(promise).ToObject(e.rt)
+	then, ok := goja.AssertFunction(promiseObj.Get("then"))
+	if !ok {
+		complete(fmt.Errorf("handler returned a promise without then()"))
+		return
+	}
+	if _, err := then(promiseObj, e.rt.ToValue(onFulfilled), e.rt.ToValue(onRejected)); err != nil {
+		complete(err)
+		return
+	}
+
+	if timeout > 0 {
+		rt := e.rt
+		timer = time.AfterFunc(timeout, func() {
+			e.jobs <- EvalJob{async: &asyncTask{
+				rt:     rt,
+				origin: job.Handler.Doc.Method + " " + job.Handler.Doc.Path,
+				run: func() error {
+					complete(errSettleTimeout)
+					return nil
+				},
+			}}
+		})
+	}
+}
+
+// rejectionError converts the rejection reason of a promise into an error
+func rejectionError(reason goja.Value) error {
+	if reason == nil || goja.IsUndefined(reason) || goja.IsNull(reason) {
+		return errors.New("promise rejected")
+	}
+	if err, ok := reason.Export().(error); ok {
+		return err
+	}
+	return &scriptError{value: reason, message: fmt.Sprintf("promise rejected: %s", reason.String())}
+}
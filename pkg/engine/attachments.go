@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Execution attachments
+//
+// output.attach(name, data, mimeType) stores a file with the record of the running code
+// execution, e.g. a generated CSV or chart. The admin logs page lists the attachments of an
+// execution and offers them for download:
+//
+//	const csv = rows.map(r => [r.id, r.total].join(',')).join('\n');
+//	output.attach('totals.csv', csv, 'text/csv');
+//
+// Attachments only exist for code executions (/v1/execute, REPL, MCP); route handlers
+// have no execution record to attach to.
+
+const (
+	maxAttachmentBytes = 10 << 20 // Size limit of one attachment
+	maxAttachments     = 20       // Attachments per execution
+)
+
+// setupOutputBindings installs the output binding
+func (e *Engine) setupOutputBindings() {
+	if err := e.rt.Set("output", map[string]interface{}{
+		"attach": e.attach,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set output binding")
+	}
+}
+
+// attach implements output.attach(name, data [, mimeType]). data is a string, an
+// ArrayBuffer or a Uint8Array. Without a MIME type, it is guessed from the file extension.
+// Attaching a name again replaces the earlier attachment.
+func (e *Engine) attach(name string, data goja.Value, mimeType ...string) error {
+	if e.attachments == nil {
+		return fmt.Errorf("output.attach(): only stored code executions can attach files")
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "" || name == "." || name == "/" {
+		return fmt.Errorf("output.attach(): a file name is required")
+	}
+
+	var content []byte
+	text := false
+	switch v := data.Export().(type) {
+	case string:
+		content, text = []byte(v), true
+	case []byte:
+		content = v
+	case goja.ArrayBuffer:
+		content = v.Bytes()
+	default:
+		return fmt.Errorf("output.attach(%s): data must be a string, ArrayBuffer or Uint8Array, got %T", name, v)
+	}
+	if len(content) > maxAttachmentBytes {
+		return fmt.Errorf("output.attach(%s): %d bytes is over the limit of %d bytes", name, len(content), maxAttachmentBytes)
+	}
+
+	contentType := ""
+	if len(mimeType) > 0 {
+		contentType = mimeType[0]
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+		if text {
+			contentType = "text/plain; charset=utf-8"
+		}
+	}
+
+	attachment := repository.ExecutionAttachment{
+		Name:     name,
+		MimeType: contentType,
+		Size:     len(content),
+		Data:     append([]byte(nil), content...),
+	}
+	attachments := *e.attachments
+	for i, existing := range attachments {
+		if existing.Name == name {
+			attachments[i] = attachment
+			return nil
+		}
+	}
+	if len(attachments) >= maxAttachments {
+		return fmt.Errorf("output.attach(%s): an execution can attach at most %d files", name, maxAttachments)
+	}
+	*e.attachments = append(attachments, attachment)
+	return nil
+}
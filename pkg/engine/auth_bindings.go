@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Auth middleware
+//
+// auth.basic() and auth.bearer() return middleware that lets a request through only with
+// valid credentials, so scripts need not parse Authorization headers themselves:
+//
+//	app.get('/admin', auth.basic({ alice: 's3cret' }), (req, res) => res.send('hi ' + req.user));
+//	app.get('/api/me', auth.bearer(token => db.query('SELECT * FROM users WHERE token = ?', token)[0]), me);
+//
+// auth.basic(users) takes an object of user names and passwords, or a function of (user,
+// password) returning whether they are valid; req.user is the user name. auth.bearer(verify)
+// calls verify(token, req), which returns the user, a falsy value to refuse the token, or a
+// promise of either; req.user is what it returned. Both take { realm } as last argument.
+// Requests without valid credentials get 401 with a WWW-Authenticate challenge and never
+// reach the handler.
+
+// defaultAuthRealm is the realm of the challenges of auth middleware without a realm option
+const defaultAuthRealm = "jesus"
+
+// setupAuthBindings installs the auth object
+func (e *Engine) setupAuthBindings() {
+	if err := e.rt.Set("auth", map[string]interface{}{
+		"basic":  e.authBasic,
+		"bearer": e.authBearer,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set auth binding")
+	}
+}
+
+// authBasic implements auth.basic(users, [options])
+func (e *Engine) authBasic(call goja.FunctionCall) goja.Value {
+	realm := e.authRealm("auth.basic", call.Argument(1))
+
+	var check func(user, password string) bool
+	if fn, ok := goja.AssertFunction(call.Argument(0)); ok {
+		check = func(user, password string) bool {
+			valid, err := fn(goja.Undefined(), e.rt.ToValue(user), e.rt.ToValue(password))
+			if err != nil {
+				panic(err)
+			}
+			return valid.ToBoolean()
+		}
+	} else {
+		users, ok := call.Argument(0).Export().(map[string]interface{})
+		if !ok || len(users) == 0 {
+			panic(e.rt.NewTypeError("auth.basic() takes an object of user names and passwords or a function of (user, password)"))
+		}
+		hashes := make(map[string][32]byte, len(users))
+		for user, password := range users {
+			p, ok := password.(string)
+			if !ok {
+				panic(e.rt.NewTypeError(fmt.Sprintf("auth.basic(): the password of %s must be a string", user)))
+			}
+			hashes[user] = sha256.Sum256([]byte(p))
+		}
+		check = func(user, password string) bool {
+			// Compare hashes so the time taken says nothing about the password
+			expected, ok := hashes[user]
+			given := sha256.Sum256([]byte(password))
+			return subtle.ConstantTimeCompare(expected[:], given[:]) == 1 && ok
+		}
+	}
+
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, realm)
+	return e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		req, res := authMiddlewareArgs(e, "auth.basic()", fc)
+		user, password, ok := parseBasicAuth(req.headerString("authorization"))
+		if !ok {
+			e.refuseAuth(res, challenge, "Authentication required")
+			return goja.Undefined()
+		}
+		if !check(user, password) {
+			e.refuseAuth(res, challenge, "Invalid user name or password")
+			return goja.Undefined()
+		}
+		req.User = user
+		return goja.Undefined()
+	})
+}
+
+// authBearer implements auth.bearer(verify, [options])
+func (e *Engine) authBearer(call goja.FunctionCall) goja.Value {
+	verify, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(e.rt.NewTypeError("auth.bearer() takes a function of (token, req) returning the user"))
+	}
+	realm := e.authRealm("auth.bearer", call.Argument(1))
+	challenge := fmt.Sprintf("Bearer realm=%q", realm)
+
+	return e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		req, res := authMiddlewareArgs(e, "auth.bearer()", fc)
+		token, ok := parseBearerToken(req.headerString("authorization"))
+		if !ok {
+			e.refuseAuth(res, challenge, "Authentication required")
+			return goja.Undefined()
+		}
+
+		accept := func(user goja.Value) {
+			if !user.ToBoolean() {
+				e.refuseAuth(res, challenge+`, error="invalid_token"`, "Invalid token")
+				return
+			}
+			req.User = user.Export()
+		}
+		user, err := verify(goja.Undefined(), e.rt.ToValue(token), fc.Argument(0))
+		if err != nil {
+			panic(err)
+		}
+		promise, ok := user.Export().(*goja.Promise)
+		if !ok {
+			accept(user)
+			return goja.Undefined()
+		}
+		switch promise.State() {
+		case goja.PromiseStateFulfilled:
+			accept(promise.Result())
+			return goja.Undefined()
+		case goja.PromiseStateRejected:
+			panic(promise.Result())
+		}
+
+		// The chain continues once the returned promise resolves
+		promiseObj := user.ToObject(e.rt)
+		then, ok := goja.AssertFunction(promiseObj.Get("then"))
+		if !ok {
+			panic(e.rt.NewTypeError("auth.bearer(): verify returned a promise without then()"))
+		}
+		next, err := then(promiseObj, e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+			accept(fc.Argument(0))
+			return goja.Undefined()
+		}))
+		if err != nil {
+			panic(err)
+		}
+		return next
+	})
+}
+
+// authRealm reads the realm option of auth middleware
+func (e *Engine) authRealm(name string, options goja.Value) string {
+	if goja.IsUndefined(options) || goja.IsNull(options) {
+		return defaultAuthRealm
+	}
+	opts, ok := options.Export().(map[string]interface{})
+	if !ok {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s() takes an options object like { realm: 'admin' }", name)))
+	}
+	switch realm := opts["realm"].(type) {
+	case nil:
+		return defaultAuthRealm
+	case string:
+		return realm
+	default:
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s(): realm must be a string, got %T", name, realm)))
+	}
+}
+
+// authMiddlewareArgs returns the request and response auth middleware is called with
+func authMiddlewareArgs(e *Engine, name string, fc goja.FunctionCall) (*ExpressRequest, *ExpressResponse) {
+	req, _ := fc.Argument(0).Export().(*ExpressRequest)
+	res, _ := fc.Argument(1).Export().(*ExpressResponse)
+	if req == nil || res == nil {
+		panic(e.rt.NewTypeError(name + " is route middleware"))
+	}
+	return req, res
+}
+
+// refuseAuth answers 401 with a challenge telling the client how to authenticate
+func (e *Engine) refuseAuth(res *ExpressResponse, challenge, message string) {
+	res.Set("WWW-Authenticate", challenge)
+	if err := res.Status(http.StatusUnauthorized).Json(map[string]interface{}{"error": message}); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+}
+
+// parseBasicAuth reads the user name and password of a Basic Authorization header
+func parseBasicAuth(header string) (string, string, bool) {
+	scheme, credentials, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Basic") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+// parseBearerToken reads the token of a Bearer Authorization header
+func parseBearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
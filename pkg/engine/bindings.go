@@ -25,6 +25,65 @@ func (e *Engine) setupBindings() {
 	// HTTP request bindings
 	e.setupHTTPBindings()
 
+	// GraphQL client on top of the HTTP bindings
+	e.setupGraphQLBindings()
+
+	// XML and SOAP helpers
+	e.setupXMLBindings()
+
+	// JSON Schema validation of data and requests
+	e.setupValidateBindings()
+
+	// In-memory analytics over query results
+	e.setupDataFrameBindings()
+
+	// XSS-safe HTML from user content
+	e.setupSanitizeBindings()
+
+	// Message catalogs and locale negotiation
+	e.setupI18nBindings()
+
+	// Feature flags toggled from the admin page
+	e.setupFlagBindings()
+
+	// Basic and bearer token auth middleware
+	e.setupAuthBindings()
+
+	// AI conversations recorded for replay in the admin console
+	e.setupConversationBindings()
+
+	// In-process route assertions for self-tests
+	if err := e.rt.Set("assertHTTP", e.assertHTTP); err != nil {
+		log.Error().Err(err).Msg("Failed to set assertHTTP binding")
+	}
+
+	// Locale-aware number, currency and date formatting
+	e.setupIntlBindings()
+
+	// setTimeout/setInterval on the event loop
+	e.setupTimerBindings()
+
+	// SVG and PNG charts from Vega-Lite specs
+	e.setupChartBindings()
+
+	// Application metrics exported on /metrics
+	e.setupMetricsBindings()
+
+	// Files attached to the record of a code execution
+	e.setupOutputBindings()
+
+	// Temp directory of each job, with a size quota
+	e.setupTempBindings()
+
+	// Forwarding of requests to upstream servers
+	e.setupProxyBindings()
+
+	// Secrets from the sources configured at serve time
+	e.setupSecretsBindings()
+
+	// Cron expression validation and run time previews
+	e.setupCronBindings()
+
 	// Console logging
 	if err := e.rt.Set("console", map[string]interface{}{
 		"log":   e.consoleLog,
@@ -58,6 +117,7 @@ func (e *Engine) setupBindings() {
 
 // consoleLog provides console.log functionality
 func (e *Engine) consoleLog(args ...interface{}) {
+	args = e.redactArgs(args)
 	log.Info().Interface("args", args).Msg("JS console.log")
 	fmt.Fprint(os.Stderr, "[JS] ")
 	for i, arg := range args {
@@ -77,6 +137,7 @@ func (e *Engine) consoleLog(args ...interface{}) {
 
 // consoleError provides console.error functionality
 func (e *Engine) consoleError(args ...interface{}) {
+	args = e.redactArgs(args)
 	log.Error().Interface("args", args).Msg("JS console.error")
 	fmt.Fprint(os.Stderr, "[JS ERROR] ")
 	for i, arg := range args {
@@ -96,6 +157,7 @@ func (e *Engine) consoleError(args ...interface{}) {
 
 // consoleInfo provides console.info functionality
 func (e *Engine) consoleInfo(args ...interface{}) {
+	args = e.redactArgs(args)
 	log.Info().Interface("args", args).Msg("JS console.info")
 	fmt.Fprint(os.Stderr, "[JS INFO] ")
 	for i, arg := range args {
@@ -115,6 +177,7 @@ func (e *Engine) consoleInfo(args ...interface{}) {
 
 // consoleWarn provides console.warn functionality
 func (e *Engine) consoleWarn(args ...interface{}) {
+	args = e.redactArgs(args)
 	log.Warn().Interface("args", args).Msg("JS console.warn")
 	fmt.Fprint(os.Stderr, "[JS WARN] ")
 	for i, arg := range args {
@@ -134,6 +197,7 @@ func (e *Engine) consoleWarn(args ...interface{}) {
 
 // consoleDebug provides console.debug functionality
 func (e *Engine) consoleDebug(args ...interface{}) {
+	args = e.redactArgs(args)
 	log.Debug().Interface("args", args).Msg("JS console.debug")
 	fmt.Fprint(os.Stderr, "[JS DEBUG] ")
 	for i, arg := range args {
@@ -218,6 +282,7 @@ func (e *Engine) restoreConsole(original *ConsoleCapture) {
 
 // captureConsoleOutput captures console output to the result
 func (e *Engine) captureConsoleOutput(result *EvalResult, level string, args ...interface{}) {
+	args = e.redactArgs(args)
 	var parts []string
 	for _, arg := range args {
 		parts = append(parts, fmt.Sprint(arg))
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Bootstrap backups
+//
+// Whenever Init runs bootstrap content that differs from its latest backup, the content is
+// stored in the system database together with whether it ran. A broken bootstrap file can
+// then be rolled back to the last content that ran without error, with the
+// rollback-bootstrap command or POST /admin/bootstrap/rollback on a running server.
+
+// maxBootstrapBackups is the number of backups kept per bootstrap file
+const maxBootstrapBackups = 50
+
+// bootstrapBackupKey identifies a bootstrap file in the backups, independent of the working directory
+func bootstrapBackupKey(filename string) string {
+	if abs, err := filepath.Abs(filename); err == nil {
+		return abs
+	}
+	return filename
+}
+
+// backupBootstrap stores the content of a bootstrap file before it runs. It returns nil if
+// the backup could not be stored.
+func (e *Engine) backupBootstrap(filename, content string) *repository.BootstrapBackup {
+	if e.repos == nil {
+		return nil
+	}
+	ctx := context.Background()
+	backups := e.repos.BootstrapBackups()
+	key := bootstrapBackupKey(filename)
+
+	backup, err := backups.SaveBackup(ctx, key, content)
+	if err != nil {
+		log.Error().Err(err).Str("file", filename).Msg("Failed to back up bootstrap file")
+		return nil
+	}
+	if err := backups.PruneBackups(ctx, key, maxBootstrapBackups); err != nil {
+		log.Warn().Err(err).Str("file", filename).Msg("Failed to prune bootstrap backups")
+	}
+	return backup
+}
+
+// recordBootstrapResult records whether running a backed up bootstrap file succeeded
+func (e *Engine) recordBootstrapResult(backup *repository.BootstrapBackup, runErr error) {
+	if backup == nil {
+		return
+	}
+	status := repository.BootstrapStatusOK
+	var message *string
+	if runErr != nil {
+		status = repository.BootstrapStatusFailed
+		text := runErr.Error()
+		message = &text
+	}
+	if err := e.repos.BootstrapBackups().SetBackupStatus(context.Background(), backup.ID, status, message); err != nil {
+		log.Error().Err(err).Int("backupID", backup.ID).Msg("Failed to record bootstrap result")
+	}
+}
+
+// BootstrapFile returns the bootstrap file run by Init, "" if none ran
+func (e *Engine) BootstrapFile() string {
+	return e.bootstrapFile
+}
+
+// BootstrapBackups returns the backups of the bootstrap file, newest first
+func (e *Engine) BootstrapBackups() ([]repository.BootstrapBackup, error) {
+	if e.bootstrapFile == "" {
+		return []repository.BootstrapBackup{}, nil
+	}
+	return e.repos.BootstrapBackups().ListBackups(context.Background(), bootstrapBackupKey(e.bootstrapFile))
+}
+
+// RollbackBootstrap restores a backup of the bootstrap file and runs it in every runtime.
+// With id 0 it restores the latest backup that ran without error and differs from the file.
+// Routes registered only by the replaced content stay registered until the server restarts.
+func (e *Engine) RollbackBootstrap(id int) (*repository.BootstrapBackup, error) {
+	if e.bootstrapFile == "" {
+		return nil, fmt.Errorf("no bootstrap file is loaded")
+	}
+
+	backup, err := RestoreBootstrapBackup(e.repos, e.bootstrapFile, id)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	e.SubmitJob(EvalJob{
+		Code:      backup.Content,
+		Done:      done,
+		SessionID: "startup-" + filepath.Base(e.bootstrapFile),
+		Source:    repository.SourceFile,
+		Replicate: true,
+	})
+	runErr := <-done
+	// The restored content is the latest backup now
+	e.recordBootstrapResult(e.backupBootstrap(e.bootstrapFile, backup.Content), runErr)
+	if runErr != nil {
+		return backup, fmt.Errorf("restored bootstrap backup %d failed to run: %w", backup.ID, runErr)
+	}
+	log.Info().Str("file", e.bootstrapFile).Int("backupID", backup.ID).Msg("Rolled back bootstrap file")
+	return backup, nil
+}
+
+// RestoreBootstrapBackup writes a backup over a bootstrap file without running it. With id 0
+// it restores the latest backup that ran without error and differs from the file. The
+// current content of the file is backed up first, so the rollback can be undone.
+func RestoreBootstrapBackup(repos repository.RepositoryManager, filename string, id int) (*repository.BootstrapBackup, error) {
+	ctx := context.Background()
+	backups := repos.BootstrapBackups()
+	key := bootstrapBackupKey(filename)
+
+	var current *repository.BootstrapBackup
+	if data, err := os.ReadFile(filename); err == nil {
+		if current, err = backups.SaveBackup(ctx, key, string(data)); err != nil {
+			return nil, fmt.Errorf("failed to back up current bootstrap file: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read bootstrap file: %w", err)
+	}
+
+	var backup *repository.BootstrapBackup
+	if id != 0 {
+		b, err := backups.GetBackup(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if b.File != key {
+			return nil, fmt.Errorf("bootstrap backup %d belongs to %s", id, b.File)
+		}
+		backup = b
+	} else {
+		list, err := backups.ListBackups(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			if list[i].Status == repository.BootstrapStatusOK && (current == nil || list[i].Hash != current.Hash) {
+				backup = &list[i]
+				break
+			}
+		}
+		if backup == nil {
+			return nil, fmt.Errorf("no working bootstrap backup differs from %s", filename)
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte(backup.Content), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write bootstrap file: %w", err)
+	}
+	// Keep the restored content the latest backup, so the next start does not store it again
+	if _, err := backups.SaveBackup(ctx, key, backup.Content); err != nil {
+		log.Warn().Err(err).Str("file", filename).Msg("Failed to back up restored bootstrap file")
+	}
+	log.Info().Str("file", filename).Int("backupID", backup.ID).Msg("Restored bootstrap backup")
+	return backup, nil
+}
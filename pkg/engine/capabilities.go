@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"sort"
+)
+
+// Limits describes the resource limits JavaScript code runs under
+type Limits struct {
+	HTTPTimeout      string   `json:"httpTimeout"`              // Timeout of fetch() and HTTP.* requests
+	HTTPMaxPerHost   int      `json:"httpMaxPerHost"`           // Concurrent fetch() and HTTP.* requests per host, 0 if unlimited
+	HTTPAllowHosts   []string `json:"httpAllowHosts,omitempty"` // Hosts outbound requests may reach, all if empty
+	HTTPDenyHosts    []string `json:"httpDenyHosts,omitempty"`  // Hosts outbound requests may not reach
+	HTTPBlockPrivate bool     `json:"httpBlockPrivate"`         // Whether outbound requests to private addresses are blocked
+	RequestLogSize   int      `json:"requestLogSize"`           // Number of requests kept in the admin request log
+	JobQueueSize     int      `json:"jobQueueSize"`             // Number of jobs that can wait for the dispatcher
+	RuntimePool      int      `json:"runtimePool"`              // Runtimes serving requests concurrently
+	ExecutionTimeout string   `json:"executionTimeout"`         // Default time limit of handlers and executions, "0s" if unlimited
+	MaxCallStackSize int      `json:"maxCallStackSize"`         // JavaScript call depth limit, 0 if unlimited
+	MaxStringLength  int      `json:"maxStringLength"`          // Length limit of strings leaving the runtime, 0 if unlimited
+	MaxArrayLength   int      `json:"maxArrayLength"`           // Length limit of arrays leaving the runtime, 0 if unlimited
+}
+
+// Capabilities describes what the engine offers to JavaScript code
+type Capabilities struct {
+	Bindings    []string `json:"bindings"`    // Globals installed by the engine (db, fetch, app, ...)
+	Modules     []string `json:"modules"`     // Native modules available through require()
+	RouteCount  int      `json:"routeCount"`  // Registered method/path handler pairs
+	FileCount   int      `json:"fileCount"`   // Registered file handlers
+	AI          bool     `json:"ai"`          // Whether an AI binding is available
+	Environment string   `json:"environment"` // Execution environment name
+	Templates   []string `json:"templates"`   // Execution templates /v1/execute?env=<name> selects
+	Limits      Limits   `json:"limits"`
+}
+
+// recordBindings snapshots the globals installed during engine setup. Only called while the
+// engine is being constructed, before the dispatcher owns the runtime.
+func (e *Engine) recordBindings() {
+	bindings := e.rt.GlobalObject().Keys()
+	sort.Strings(bindings)
+	e.bindings = bindings
+}
+
+// GetCapabilities reports the bindings, modules, routes and limits of the engine
+func (e *Engine) GetCapabilities() Capabilities {
+	modules := make([]string, 0)
+	for name := range e.moduleRegistry.GetDocumentation() {
+		modules = append(modules, name)
+	}
+	sort.Strings(modules)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	routeCount := 0
+	for _, methods := range e.handlers {
+		routeCount += len(methods)
+	}
+
+	capabilities := Capabilities{
+		Bindings:   e.bindings,
+		Modules:    modules,
+		RouteCount: routeCount,
+		FileCount:  len(e.files),
+		Limits: Limits{
+			HTTPTimeout:      e.httpClient.config.Timeout.String(),
+			HTTPMaxPerHost:   e.httpClient.config.MaxPerHost,
+			HTTPAllowHosts:   e.httpClient.config.Network.AllowHosts,
+			HTTPDenyHosts:    e.httpClient.config.Network.DenyHosts,
+			HTTPBlockPrivate: e.httpClient.config.Network.BlockPrivate,
+			RequestLogSize:   requestLogCapacity,
+			JobQueueSize:     cap(e.jobs),
+			RuntimePool:      e.RuntimePoolSize(),
+			ExecutionTimeout: e.executionTimeout.String(),
+			MaxCallStackSize: e.runtimeLimits.MaxCallStackSize,
+			MaxStringLength:  e.runtimeLimits.MaxStringLength,
+			MaxArrayLength:   e.runtimeLimits.MaxArrayLength,
+		},
+	}
+	for _, binding := range e.bindings {
+		if binding == "ai" {
+			capabilities.AI = true
+		}
+	}
+	if e.env != nil {
+		capabilities.Environment = e.env.Name
+	}
+	capabilities.Templates = make([]string, 0, len(e.templates))
+	for name := range e.templates {
+		capabilities.Templates = append(capabilities.Templates, name)
+	}
+	sort.Strings(capabilities.Templates)
+
+	return capabilities
+}
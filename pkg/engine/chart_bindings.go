@@ -0,0 +1,438 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Charts
+//
+// chart.render(spec, options) draws a chart from a subset of Vega-Lite and returns the image
+// as an ArrayBuffer, SVG by default or PNG with {format: 'png'}:
+//
+//	const svg = chart.render({
+//	  title: 'Orders per month',
+//	  mark: 'bar',
+//	  data: { values: db.query('SELECT month, COUNT(*) AS orders FROM orders GROUP BY month') },
+//	  encoding: { x: { field: 'month', type: 'ordinal' }, y: { field: 'orders', type: 'quantitative' } },
+//	});
+//	output.attach('orders.svg', svg);
+//
+// The image can be sent with res.send(), attached with output.attach(), or returned from a
+// playground execution, which then shows it in the result pane.
+
+const (
+	defaultChartWidth  = 600
+	defaultChartHeight = 400
+	maxChartSize       = 2000 // Largest width or height in pixels
+	chartTicks         = 5    // Approximate number of ticks per quantitative axis
+)
+
+// Encoding types of Vega-Lite. Ordinal and temporal x values are drawn like nominal ones,
+// as categories in data order.
+const (
+	chartQuantitative = "quantitative"
+	chartNominal      = "nominal"
+)
+
+// chartSpec is the supported subset of a Vega-Lite specification
+type chartSpec struct {
+	Title  string    `json:"title"`
+	Mark   chartMark `json:"mark"`
+	Width  float64   `json:"width"`
+	Height float64   `json:"height"`
+	Data   struct {
+		Values []map[string]interface{} `json:"values"`
+	} `json:"data"`
+	Encoding struct {
+		X     *chartChannel `json:"x"`
+		Y     *chartChannel `json:"y"`
+		Color *chartChannel `json:"color"`
+	} `json:"encoding"`
+}
+
+// chartChannel is an encoding channel: the field it reads and how it is scaled
+type chartChannel struct {
+	Field string `json:"field"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+}
+
+// chartMark accepts both mark: 'bar' and mark: {type: 'bar'}
+type chartMark string
+
+func (m *chartMark) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*m = chartMark(name)
+		return nil
+	}
+	var def struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &def); err != nil {
+		return fmt.Errorf("mark must be a string or an object with a type")
+	}
+	*m = chartMark(def.Type)
+	return nil
+}
+
+// chartSeries is the data of one color of a chart, in data order
+type chartSeries struct {
+	name   string
+	xs     []string  // Categories, for band x scales
+	xNums  []float64 // Positions, for linear x scales
+	values []float64
+}
+
+// setupChartBindings installs the chart global
+func (e *Engine) setupChartBindings() {
+	if err := e.rt.Set("chart", map[string]interface{}{
+		"render": e.chartRender,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set chart binding")
+	}
+}
+
+// chartRender draws a chart: chart.render(spec, {format: 'svg' | 'png'})
+func (e *Engine) chartRender(spec map[string]interface{}, options ...map[string]interface{}) (goja.ArrayBuffer, error) {
+	format := "svg"
+	if len(options) > 0 && options[0] != nil {
+		if f, ok := options[0]["format"].(string); ok && f != "" {
+			format = strings.ToLower(f)
+		}
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return goja.ArrayBuffer{}, fmt.Errorf("chart.render(): invalid spec: %w", err)
+	}
+	var s chartSpec
+	if err := json.Unmarshal(data, &s); err != nil {
+		return goja.ArrayBuffer{}, fmt.Errorf("chart.render(): invalid spec: %w", err)
+	}
+
+	var canvas chartCanvas
+	width, height := chartDimension(s.Width, defaultChartWidth), chartDimension(s.Height, defaultChartHeight)
+	switch format {
+	case "svg":
+		canvas = newSVGCanvas(width, height)
+	case "png":
+		canvas = newPNGCanvas(width, height)
+	default:
+		return goja.ArrayBuffer{}, fmt.Errorf("chart.render(): unknown format %q, use svg or png", format)
+	}
+
+	if err := drawChart(canvas, &s, width, height); err != nil {
+		return goja.ArrayBuffer{}, fmt.Errorf("chart.render(): %w", err)
+	}
+	image, err := canvas.encode()
+	if err != nil {
+		return goja.ArrayBuffer{}, fmt.Errorf("chart.render(): failed to encode %s: %w", format, err)
+	}
+	return e.rt.NewArrayBuffer(image), nil
+}
+
+// chartDimension returns a width or height, bounded to maxChartSize
+func chartDimension(v, def float64) float64 {
+	if v <= 0 {
+		return def
+	}
+	return math.Min(math.Round(v), maxChartSize)
+}
+
+// drawChart lays out the axes, marks and legend of a chart on a canvas
+func drawChart(c chartCanvas, s *chartSpec, width, height float64) error {
+	mark := string(s.Mark)
+	switch mark {
+	case "bar", "line", "point", "area":
+	case "":
+		return fmt.Errorf("spec has no mark")
+	default:
+		return fmt.Errorf("unsupported mark %q, use bar, line, point or area", mark)
+	}
+	x, y := s.Encoding.X, s.Encoding.Y
+	if x == nil || x.Field == "" || y == nil || y.Field == "" {
+		return fmt.Errorf("encoding.x.field and encoding.y.field are required")
+	}
+	if y.Type != "" && y.Type != chartQuantitative {
+		return fmt.Errorf("encoding.y must be quantitative")
+	}
+	xType := x.Type
+	if xType == "" {
+		xType = chartNominal
+		if mark != "bar" && allNumbers(s.Data.Values, x.Field) {
+			xType = chartQuantitative
+		}
+	}
+	linearX := xType == chartQuantitative && mark != "bar"
+	colorField := ""
+	if s.Encoding.Color != nil {
+		colorField = s.Encoding.Color.Field
+	}
+
+	series, categories := chartData(s.Data.Values, x.Field, y.Field, colorField, linearX)
+
+	// Plot area, leaving room for the title, axes and legend
+	left, right, top, bottom := 64.0, width-20, 20.0, height-52
+	if s.Title != "" {
+		top = 44
+	}
+	if colorField != "" {
+		right = width - 140
+	}
+	if right-left < 40 || bottom-top < 40 {
+		return fmt.Errorf("chart of %gx%g is too small", width, height)
+	}
+
+	yMin, yMax := 0.0, 0.0
+	xMin, xMax := math.Inf(1), math.Inf(-1)
+	for _, se := range series {
+		for i, v := range se.values {
+			yMin, yMax = math.Min(yMin, v), math.Max(yMax, v)
+			if linearX {
+				xMin, xMax = math.Min(xMin, se.xNums[i]), math.Max(xMax, se.xNums[i])
+			}
+		}
+	}
+	yTicks := niceTicks(yMin, yMax)
+	yMin, yMax = yTicks[0], yTicks[len(yTicks)-1]
+	yPos := func(v float64) float64 {
+		return bottom - (v-yMin)/(yMax-yMin)*(bottom-top)
+	}
+
+	var xTicks []float64
+	if linearX {
+		if math.IsInf(xMin, 1) {
+			xMin, xMax = 0, 1
+		}
+		xTicks = niceTicks(xMin, xMax)
+		xMin, xMax = xTicks[0], xTicks[len(xTicks)-1]
+	}
+	band := (right - left) / math.Max(float64(len(categories)), 1)
+	categoryIndex := make(map[string]int, len(categories))
+	for i, category := range categories {
+		categoryIndex[category] = i
+	}
+	xPos := func(se *chartSeries, i int) float64 {
+		if linearX {
+			return left + (se.xNums[i]-xMin)/(xMax-xMin)*(right-left)
+		}
+		return left + (float64(categoryIndex[se.xs[i]])+0.5)*band
+	}
+
+	// Title, grid and axes
+	if s.Title != "" {
+		c.text(width/2, 26, s.Title, 16, "middle", chartTextColor, false)
+	}
+	for _, tick := range yTicks {
+		py := yPos(tick)
+		c.line(left, py, right, py, 1, chartGridColor)
+		c.text(left-8, py+4, formatTick(tick), 11, "end", chartTextColor, false)
+	}
+	if linearX {
+		for _, tick := range xTicks {
+			px := left + (tick-xMin)/(xMax-xMin)*(right-left)
+			c.line(px, bottom, px, bottom+5, 1, chartAxisColor)
+			c.text(px, bottom+18, formatTick(tick), 11, "middle", chartTextColor, false)
+		}
+	} else {
+		for i, category := range categories {
+			px := left + (float64(i)+0.5)*band
+			c.line(px, bottom, px, bottom+5, 1, chartAxisColor)
+			c.text(px, bottom+18, category, 11, "middle", chartTextColor, false)
+		}
+	}
+	c.line(left, bottom, right, bottom, 1, chartAxisColor)
+	c.line(left, top, left, bottom, 1, chartAxisColor)
+	c.text((left+right)/2, height-10, channelTitle(x), 12, "middle", chartTextColor, false)
+	c.text(18, (top+bottom)/2, channelTitle(y), 12, "middle", chartTextColor, true)
+
+	// Marks
+	zero := yPos(math.Max(yMin, math.Min(0, yMax)))
+	for n, se := range series {
+		color := chartPalette[n%len(chartPalette)]
+		switch mark {
+		case "bar":
+			barWidth := band * 0.8 / float64(len(series))
+			for i, v := range se.values {
+				px := left + float64(categoryIndex[se.xs[i]])*band + band*0.1 + float64(n)*barWidth
+				py := yPos(v)
+				c.rect(px, math.Min(py, zero), barWidth, math.Abs(zero-py), color)
+			}
+		case "point":
+			for i, v := range se.values {
+				c.circle(xPos(se, i), yPos(v), 4, color)
+			}
+		case "line", "area":
+			points := make([]chartPoint, len(se.values))
+			for i, v := range se.values {
+				points[i] = chartPoint{xPos(se, i), yPos(v)}
+			}
+			if linearX {
+				sort.SliceStable(points, func(i, j int) bool { return points[i].x < points[j].x })
+			}
+			if mark == "area" && len(points) > 0 {
+				polygon := append([]chartPoint{{points[0].x, zero}}, points...)
+				polygon = append(polygon, chartPoint{points[len(points)-1].x, zero})
+				fill := color
+				fill.A = 0x66
+				c.polygon(polygon, fill)
+			}
+			c.polyline(points, 2, color)
+		}
+	}
+
+	// Legend
+	if colorField != "" {
+		c.text(right+20, top+4, channelTitle(s.Encoding.Color), 12, "start", chartTextColor, false)
+		for n, se := range series {
+			ly := top + 24 + float64(n)*20
+			c.rect(right+20, ly-10, 12, 12, chartPalette[n%len(chartPalette)])
+			c.text(right+38, ly, se.name, 11, "start", chartTextColor, false)
+		}
+	}
+	return nil
+}
+
+// chartData splits the data into series by the color field. Rows without a numeric y value
+// are skipped. categories lists the x values in order of appearance.
+func chartData(rows []map[string]interface{}, xField, yField, colorField string, linearX bool) ([]*chartSeries, []string) {
+	var series []*chartSeries
+	byName := make(map[string]*chartSeries)
+	var categories []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		v, ok := chartNumber(row[yField])
+		if !ok {
+			continue
+		}
+		name := ""
+		if colorField != "" {
+			name = chartLabel(row[colorField])
+		}
+		se := byName[name]
+		if se == nil {
+			se = &chartSeries{name: name}
+			byName[name] = se
+			series = append(series, se)
+		}
+
+		if linearX {
+			xv, ok := chartNumber(row[xField])
+			if !ok {
+				continue
+			}
+			se.xNums = append(se.xNums, xv)
+		} else {
+			category := chartLabel(row[xField])
+			if !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+			se.xs = append(se.xs, category)
+		}
+		se.values = append(se.values, v)
+	}
+	return series, categories
+}
+
+// allNumbers reports whether every row has a number in a field
+func allNumbers(rows []map[string]interface{}, field string) bool {
+	for _, row := range rows {
+		if _, ok := row[field].(float64); !ok {
+			return false
+		}
+	}
+	return len(rows) > 0
+}
+
+// chartNumber reads a numeric value; numeric strings such as database decimals are accepted
+func chartNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, !math.IsNaN(n) && !math.IsInf(n, 0)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
+
+// chartLabel formats a value as a category label
+func chartLabel(v interface{}) string {
+	switch n := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return n
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// channelTitle returns the axis or legend title of a channel
+func channelTitle(ch *chartChannel) string {
+	if ch.Title != "" {
+		return ch.Title
+	}
+	return ch.Field
+}
+
+// niceTicks returns evenly spaced round tick values covering [min, max]
+func niceTicks(min, max float64) []float64 {
+	if max <= min {
+		max = min + 1
+	}
+	step := niceStep((max - min) / chartTicks)
+	start, end := math.Floor(min/step)*step, math.Ceil(max/step)*step
+	var ticks []float64
+	for v := start; v <= end+step/2; v += step {
+		ticks = append(ticks, math.Round(v/step)*step)
+	}
+	return ticks
+}
+
+// niceStep rounds a tick interval to 1, 2 or 5 times a power of ten
+func niceStep(raw float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	switch fraction := raw / magnitude; {
+	case fraction <= 1:
+		return magnitude
+	case fraction <= 2:
+		return 2 * magnitude
+	case fraction <= 5:
+		return 5 * magnitude
+	}
+	return 10 * magnitude
+}
+
+// formatTick formats a tick value without floating point noise
+func formatTick(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// imageDataURL turns the bytes of a PNG or SVG image into a data: URL, so the playground can
+// show images returned by an execution
+func imageDataURL(data []byte) (string, bool) {
+	contentType := http.DetectContentType(data)
+	switch {
+	case contentType == "image/png", contentType == "image/jpeg", contentType == "image/gif":
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("<svg")):
+		contentType = "image/svg+xml"
+	default:
+		return "", false
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data), true
+}
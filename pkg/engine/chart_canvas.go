@@ -0,0 +1,252 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Chart colors; the series palette is Vega's category10 scheme
+var (
+	chartTextColor = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	chartAxisColor = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	chartGridColor = color.RGBA{0xe6, 0xe6, 0xe6, 0xff}
+	chartPalette   = []color.RGBA{
+		{0x1f, 0x77, 0xb4, 0xff}, {0xff, 0x7f, 0x0e, 0xff}, {0x2c, 0xa0, 0x2c, 0xff}, {0xd6, 0x27, 0x28, 0xff},
+		{0x94, 0x67, 0xbd, 0xff}, {0x8c, 0x56, 0x4b, 0xff}, {0xe3, 0x77, 0xc2, 0xff}, {0x7f, 0x7f, 0x7f, 0xff},
+		{0xbc, 0xbd, 0x22, 0xff}, {0x17, 0xbe, 0xcf, 0xff},
+	}
+)
+
+type chartPoint struct {
+	x, y float64
+}
+
+// chartCanvas is the drawing surface of drawChart
+type chartCanvas interface {
+	rect(x, y, w, h float64, fill color.RGBA)
+	line(x1, y1, x2, y2, width float64, stroke color.RGBA)
+	polyline(points []chartPoint, width float64, stroke color.RGBA)
+	polygon(points []chartPoint, fill color.RGBA)
+	circle(cx, cy, r float64, fill color.RGBA)
+	// text draws a label; anchor is start, middle or end, and rotated text runs bottom to top
+	text(x, y float64, s string, size float64, anchor string, fill color.RGBA, rotated bool)
+	encode() ([]byte, error)
+}
+
+// svgCanvas draws a chart as SVG elements
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func newSVGCanvas(width, height float64) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif">`, width, height, width, height)
+	fmt.Fprintf(&c.buf, `<rect width="%g" height="%g" fill="#ffffff"/>`, width, height)
+	return c
+}
+
+// svgColor formats a color as fill or stroke attributes
+func svgColor(attr string, c color.RGBA) string {
+	s := fmt.Sprintf(`%s="#%02x%02x%02x"`, attr, c.R, c.G, c.B)
+	if c.A != 0xff {
+		s += fmt.Sprintf(` %s-opacity="%.2f"`, attr, float64(c.A)/0xff)
+	}
+	return s
+}
+
+func svgPoints(points []chartPoint) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = fmt.Sprintf("%.1f,%.1f", p.x, p.y)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" %s/>`, x, y, w, h, svgColor("fill", fill))
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2, width float64, stroke color.RGBA) {
+	fmt.Fprintf(&c.buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke-width="%g" %s/>`, x1, y1, x2, y2, width, svgColor("stroke", stroke))
+}
+
+func (c *svgCanvas) polyline(points []chartPoint, width float64, stroke color.RGBA) {
+	fmt.Fprintf(&c.buf, `<polyline points="%s" fill="none" stroke-width="%g" stroke-linejoin="round" %s/>`, svgPoints(points), width, svgColor("stroke", stroke))
+}
+
+func (c *svgCanvas) polygon(points []chartPoint, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<polygon points="%s" %s/>`, svgPoints(points), svgColor("fill", fill))
+}
+
+func (c *svgCanvas) circle(cx, cy, r float64, fill color.RGBA) {
+	fmt.Fprintf(&c.buf, `<circle cx="%.1f" cy="%.1f" r="%g" %s/>`, cx, cy, r, svgColor("fill", fill))
+}
+
+func (c *svgCanvas) text(x, y float64, s string, size float64, anchor string, fill color.RGBA, rotated bool) {
+	transform := ""
+	if rotated {
+		transform = fmt.Sprintf(` transform="rotate(-90 %.1f %.1f)"`, x, y)
+	}
+	fmt.Fprintf(&c.buf, `<text x="%.1f" y="%.1f" font-size="%g" text-anchor="%s"%s %s>%s</text>`, x, y, size, anchor, transform, svgColor("fill", fill), html.EscapeString(s))
+}
+
+func (c *svgCanvas) encode() ([]byte, error) {
+	c.buf.WriteString("</svg>")
+	return c.buf.Bytes(), nil
+}
+
+// pngCanvas rasterizes a chart. Text is drawn with a fixed 7x13 bitmap font, so the size
+// of a label is ignored.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height float64) *pngCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	return &pngCanvas{img: img}
+}
+
+// blend paints a pixel with a color over what is already drawn
+func (c *pngCanvas) blend(x, y int, col color.RGBA) {
+	if !(image.Point{x, y}.In(c.img.Rect)) {
+		return
+	}
+	i := c.img.PixOffset(x, y)
+	alpha := uint32(col.A)
+	for k, v := range []uint8{col.R, col.G, col.B} {
+		c.img.Pix[i+k] = uint8((uint32(v)*alpha + uint32(c.img.Pix[i+k])*(0xff-alpha)) / 0xff)
+	}
+	c.img.Pix[i+3] = 0xff
+}
+
+func (c *pngCanvas) rect(x, y, w, h float64, fill color.RGBA) {
+	for py := int(math.Round(y)); py < int(math.Round(y+h)); py++ {
+		for px := int(math.Round(x)); px < int(math.Round(x+w)); px++ {
+			c.blend(px, py, fill)
+		}
+	}
+}
+
+// line steps along the line in half pixels, painting a square of the line width at each
+// pixel it passes
+func (c *pngCanvas) line(x1, y1, x2, y2, width float64, stroke color.RGBA) {
+	size := int(math.Max(math.Round(width), 1))
+	steps := int(math.Ceil(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))*2)) + 1
+	painted := make(map[image.Point]bool)
+	for s := 0; s <= steps; s++ {
+		t := float64(s) / float64(steps)
+		cx, cy := int(math.Round(x1+(x2-x1)*t)), int(math.Round(y1+(y2-y1)*t))
+		for dy := -(size - 1) / 2; dy <= size/2; dy++ {
+			for dx := -(size - 1) / 2; dx <= size/2; dx++ {
+				p := image.Point{cx + dx, cy + dy}
+				if !painted[p] {
+					painted[p] = true
+					c.blend(p.X, p.Y, stroke)
+				}
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) polyline(points []chartPoint, width float64, stroke color.RGBA) {
+	for i := 1; i < len(points); i++ {
+		c.line(points[i-1].x, points[i-1].y, points[i].x, points[i].y, width, stroke)
+	}
+}
+
+// polygon fills the polygon with the even-odd rule, one pixel row at a time
+func (c *pngCanvas) polygon(points []chartPoint, fill color.RGBA) {
+	if len(points) < 3 {
+		return
+	}
+	minY, maxY := points[0].y, points[0].y
+	for _, p := range points {
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	for py := int(math.Floor(minY)); py <= int(math.Ceil(maxY)); py++ {
+		scan := float64(py) + 0.5
+		var crossings []float64
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			if (a.y <= scan) != (b.y <= scan) {
+				crossings = append(crossings, a.x+(scan-a.y)/(b.y-a.y)*(b.x-a.x))
+			}
+		}
+		sort.Float64s(crossings)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			for px := int(math.Round(crossings[i])); px < int(math.Round(crossings[i+1])); px++ {
+				c.blend(px, py, fill)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) circle(cx, cy, r float64, fill color.RGBA) {
+	for py := int(math.Floor(cy - r)); py <= int(math.Ceil(cy+r)); py++ {
+		for px := int(math.Floor(cx - r)); px <= int(math.Ceil(cx+r)); px++ {
+			if dx, dy := float64(px)+0.5-cx, float64(py)+0.5-cy; dx*dx+dy*dy <= r*r {
+				c.blend(px, py, fill)
+			}
+		}
+	}
+}
+
+// text draws the label into an alpha mask first, then blends the mask into the chart,
+// turned about the anchor point when rotated
+func (c *pngCanvas) text(x, y float64, s string, _ float64, anchor string, fill color.RGBA, rotated bool) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, s).Ceil()
+	if width == 0 {
+		return
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: fixed.P(0, face.Ascent)}
+	d.DrawString(s)
+
+	offset := 0
+	switch anchor {
+	case "middle":
+		offset = width / 2
+	case "end":
+		offset = width
+	}
+	ox, oy := int(math.Round(x)), int(math.Round(y))
+	for my := 0; my < face.Height; my++ {
+		for mx := 0; mx < width; mx++ {
+			a := mask.AlphaAt(mx, my).A
+			if a == 0 {
+				continue
+			}
+			col := fill
+			col.A = uint8(uint32(fill.A) * uint32(a) / 0xff)
+			dx, dy := mx-offset, my-face.Ascent
+			if rotated {
+				c.blend(ox+dy, oy-dx, col)
+			} else {
+				c.blend(ox+dx, oy+dy, col)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
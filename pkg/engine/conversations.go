@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// AI conversations
+//
+// Scripts calling AI models record their conversations in the system database, so the admin
+// console can replay them at /admin/conversations and continue them in the playground:
+//
+//	const conv = conversations.create({ title: 'Support chat', model: 'gpt-4o' });
+//	conversations.append(conv.id, { role: 'user', content: question });
+//	const reply = conversations.stream(conv.id);
+//	for (const chunk of chunks) reply.write(chunk);  // timing of each chunk is kept
+//	reply.toolCall({ id: 'call_1', name: 'lookup', arguments: { q: question } });
+//	reply.end();
+//	conversations.append(conv.id, { role: 'tool', tool_call_id: 'call_1', content: result });
+//
+// Messages use the field names of conversations.get(), so messages read from one
+// conversation can be appended to another.
+
+// errNoConversationStore is returned when the engine has no system database to keep
+// conversations in
+var errNoConversationStore = errors.New("conversations need the system database")
+
+// conversationRoles are the roles a message can have
+var conversationRoles = map[string]bool{"system": true, "user": true, "assistant": true, "tool": true}
+
+// setupConversationBindings installs conversations
+func (e *Engine) setupConversationBindings() {
+	if err := e.rt.Set("conversations", map[string]interface{}{
+		"create": e.conversationsCreate,
+		"append": e.conversationsAppend,
+		"stream": e.conversationsStream,
+		"get":    e.conversationsGet,
+		"list":   e.conversationsList,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set conversations binding")
+	}
+}
+
+// conversationsCreate implements conversations.create([options]) with the title and model
+// options
+func (e *Engine) conversationsCreate(options ...map[string]interface{}) *repository.Conversation {
+	conversation := repository.Conversation{SessionID: e.currentSession}
+	if len(options) > 0 && options[0] != nil {
+		conversation.Title, _ = options[0]["title"].(string)
+		conversation.Model, _ = options[0]["model"].(string)
+	}
+	if e.warmingUp {
+		panic(e.rt.NewGoError(errWarmUpWrite))
+	}
+	if e.repos == nil {
+		panic(e.rt.NewGoError(errNoConversationStore))
+	}
+	saved, err := e.repos.Conversations().CreateConversation(context.Background(), conversation)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return saved
+}
+
+// conversationsAppend implements conversations.append(id, message)
+func (e *Engine) conversationsAppend(id int, message goja.Value) *repository.ConversationMessage {
+	var parsed repository.ConversationMessage
+	data, err := json.Marshal(message.Export())
+	if err == nil {
+		err = json.Unmarshal(data, &parsed)
+	}
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("conversations.append(): invalid message: %v", err)))
+	}
+	parsed.ConversationID = id
+	return e.appendConversationMessage(parsed)
+}
+
+// conversationsStream implements conversations.stream(id, [role]): a message, by default of
+// the assistant, written chunk by chunk with write(text) and tool calls with toolCall(call),
+// and stored by end() with the time each chunk arrived
+func (e *Engine) conversationsStream(id int, role ...string) map[string]interface{} {
+	message := repository.ConversationMessage{ConversationID: id, Role: "assistant"}
+	if len(role) > 0 && role[0] != "" {
+		message.Role = role[0]
+	}
+	if !conversationRoles[message.Role] {
+		panic(e.rt.NewTypeError(fmt.Sprintf("conversations.stream(): invalid role %q, expected system, user, assistant or tool", message.Role)))
+	}
+
+	start := time.Now()
+	var content strings.Builder
+	var saved *repository.ConversationMessage
+	checkOpen := func(method string) {
+		if saved != nil {
+			panic(e.rt.NewTypeError(fmt.Sprintf("%s(): the message was already ended", method)))
+		}
+	}
+	return map[string]interface{}{
+		"write": func(text string) {
+			checkOpen("write")
+			content.WriteString(text)
+			message.Tokens = append(message.Tokens, repository.ConversationToken{Text: text, Ms: time.Since(start).Milliseconds()})
+		},
+		"toolCall": func(call map[string]interface{}) {
+			checkOpen("toolCall")
+			toolCall := repository.ConversationToolCall{Arguments: call["arguments"]}
+			toolCall.ID, _ = call["id"].(string)
+			toolCall.Name, _ = call["name"].(string)
+			if toolCall.Name == "" {
+				panic(e.rt.NewTypeError("toolCall(): a tool call needs a name"))
+			}
+			message.ToolCalls = append(message.ToolCalls, toolCall)
+		},
+		"end": func() *repository.ConversationMessage {
+			if saved == nil {
+				message.Content = content.String()
+				saved = e.appendConversationMessage(message)
+			}
+			return saved
+		},
+	}
+}
+
+// appendConversationMessage stores a message of a binding call, throwing on errors
+func (e *Engine) appendConversationMessage(message repository.ConversationMessage) *repository.ConversationMessage {
+	if !conversationRoles[message.Role] {
+		panic(e.rt.NewTypeError(fmt.Sprintf("invalid message role %q, expected system, user, assistant or tool", message.Role)))
+	}
+	if e.warmingUp {
+		panic(e.rt.NewGoError(errWarmUpWrite))
+	}
+	if e.repos == nil {
+		panic(e.rt.NewGoError(errNoConversationStore))
+	}
+	saved, err := e.repos.Conversations().AppendMessage(context.Background(), message)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return saved
+}
+
+// conversationsGet implements conversations.get(id)
+func (e *Engine) conversationsGet(id int) *repository.Conversation {
+	conversation, err := e.Conversation(id)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return conversation
+}
+
+// conversationsList implements conversations.list([limit])
+func (e *Engine) conversationsList(limit ...int) []repository.Conversation {
+	n := 0
+	if len(limit) > 0 {
+		n = limit[0]
+	}
+	conversations, err := e.Conversations(n)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return conversations
+}
+
+// Conversations returns the recorded conversations without their messages, most recently
+// updated first; limit 0 returns the latest 100
+func (e *Engine) Conversations(limit int) ([]repository.Conversation, error) {
+	if e.repos == nil {
+		return nil, errNoConversationStore
+	}
+	return e.repos.Conversations().ListConversations(context.Background(), limit)
+}
+
+// Conversation returns a recorded conversation with its messages
+func (e *Engine) Conversation(id int) (*repository.Conversation, error) {
+	if e.repos == nil {
+		return nil, errNoConversationStore
+	}
+	return e.repos.Conversations().GetConversation(context.Background(), id)
+}
+
+// DeleteConversation removes a recorded conversation and its messages
+func (e *Engine) DeleteConversation(id int) error {
+	if e.repos == nil {
+		return errNoConversationStore
+	}
+	if err := e.repos.Conversations().DeleteConversation(context.Background(), id); err != nil {
+		return err
+	}
+	log.Info().Int("conversation", id).Msg("Conversation deleted")
+	return nil
+}
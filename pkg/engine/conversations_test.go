@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// evalJSON runs code on the engine and decodes the JSON string it evaluates to into v
+func evalJSON(t *testing.T, e *Engine, code string, v interface{}) {
+	t.Helper()
+	result := make(chan *EvalResult, 1)
+	done := make(chan error, 1)
+	e.SubmitJob(EvalJob{Code: code, Done: done, Result: result})
+	if err := <-done; err != nil {
+		t.Fatalf("%s: %v", code, err)
+	}
+	value, ok := (<-result).Value.(string)
+	if !ok {
+		t.Fatalf("%s: did not evaluate to a string", code)
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		t.Fatalf("%s: %v", code, err)
+	}
+}
+
+func TestConversationsRecordStreamedMessages(t *testing.T) {
+	dir := t.TempDir()
+	e := NewEngine(filepath.Join(dir, "app.db"), filepath.Join(dir, "system.db"))
+	e.StartDispatcher()
+	t.Cleanup(func() {
+		_ = e.Close()
+	})
+
+	var recorded struct {
+		ID       int `json:"id"`
+		Title    string
+		Model    string
+		Messages []struct {
+			Role       string
+			Content    string
+			ToolCallID string `json:"tool_call_id"`
+			ToolCalls  []struct {
+				ID        string
+				Name      string
+				Arguments map[string]interface{}
+			} `json:"tool_calls"`
+			Tokens []struct {
+				Text string
+				Ms   int64
+			}
+		}
+	}
+	evalJSON(t, e, `
+		const conv = conversations.create({ title: 'Weather', model: 'gpt-4o' });
+		conversations.append(conv.id, { role: 'user', content: 'Weather in Paris?' });
+		const reply = conversations.stream(conv.id);
+		reply.write('Let me ');
+		const until = Date.now() + 30;
+		while (Date.now() < until) {}
+		reply.write('check.');
+		reply.toolCall({ id: 'call_1', name: 'weather', arguments: { city: 'Paris' } });
+		reply.end();
+		conversations.append(conv.id, { role: 'tool', tool_call_id: 'call_1', content: '18°C' });
+		JSON.stringify(conversations.get(conv.id));
+	`, &recorded)
+
+	if recorded.Title != "Weather" || recorded.Model != "gpt-4o" || len(recorded.Messages) != 3 {
+		t.Fatalf("recorded %+v", recorded)
+	}
+	assistant := recorded.Messages[1]
+	if assistant.Role != "assistant" || assistant.Content != "Let me check." {
+		t.Errorf("assistant message = %q from %s, want the chunks joined", assistant.Content, assistant.Role)
+	}
+	if len(assistant.Tokens) != 2 || assistant.Tokens[0].Text != "Let me " || assistant.Tokens[1].Ms < assistant.Tokens[0].Ms+25 {
+		t.Errorf("tokens = %+v, want both chunks with the second about 30ms after the first", assistant.Tokens)
+	}
+	if len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].Name != "weather" || assistant.ToolCalls[0].Arguments["city"] != "Paris" {
+		t.Errorf("tool calls = %+v", assistant.ToolCalls)
+	}
+	if tool := recorded.Messages[2]; tool.Role != "tool" || tool.ToolCallID != "call_1" {
+		t.Errorf("tool message = %+v", tool)
+	}
+
+	// Messages read from a conversation can be appended to another, as "continue from here" does
+	var copied struct {
+		Messages []struct {
+			Role      string
+			Content   string
+			ToolCalls []struct{ Name string } `json:"tool_calls"`
+		}
+	}
+	evalJSON(t, e, `
+		const source = conversations.get(`+jsonString(t, recorded.ID)+`);
+		const copy = conversations.create({ title: 'Copy' });
+		for (const message of source.messages.slice(0, 2)) {
+			conversations.append(copy.id, message);
+		}
+		JSON.stringify(conversations.get(copy.id));
+	`, &copied)
+	if len(copied.Messages) != 2 || copied.Messages[1].Content != "Let me check." || len(copied.Messages[1].ToolCalls) != 1 {
+		t.Errorf("copied messages = %+v", copied.Messages)
+	}
+
+	conversations, err := e.Conversations(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conversations) != 2 || conversations[0].Title != "Copy" || conversations[1].MessageCount != 3 {
+		t.Errorf("conversations = %+v, want the copy first and the original with 3 messages", conversations)
+	}
+}
+
+func TestConversationsRejectInvalidMessages(t *testing.T) {
+	dir := t.TempDir()
+	e := NewEngine(filepath.Join(dir, "app.db"), filepath.Join(dir, "system.db"))
+	e.StartDispatcher()
+	t.Cleanup(func() {
+		_ = e.Close()
+	})
+
+	for _, code := range []string{
+		`conversations.append(conversations.create().id, { role: 'robot', content: 'hi' })`,
+		`conversations.append(12345, { role: 'user', content: 'hi' })`,
+		`const s = conversations.stream(conversations.create().id); s.end(); s.write('late')`,
+		`conversations.stream(conversations.create().id).toolCall({ arguments: {} })`,
+	} {
+		done := make(chan error, 1)
+		e.SubmitJob(EvalJob{Code: code, Done: done})
+		if err := <-done; err == nil {
+			t.Errorf("%s: no error", code)
+		}
+	}
+}
+
+func jsonString(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
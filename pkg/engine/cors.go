@@ -0,0 +1,294 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// CORS
+//
+// cors(options) returns middleware letting browsers on other origins call a route:
+//
+//	app.get('/api/items', cors({ origin: 'https://app.example.com', credentials: true }), listItems);
+//	app.use(cors());  // every route: any origin
+//
+// Options: origin ('*', an origin, a list of origins or true to reflect the caller), methods,
+// allowedHeaders (default: the headers the preflight asks for), exposedHeaders, credentials
+// and maxAge (seconds or a duration string) for caching preflights. The server applies the
+// policy in Go before the handler runs, so it also answers preflight OPTIONS requests of the
+// route. A policy in the route's middleware wins over the one set with app.use.
+
+// corsPolicySymbol tags the middleware functions returned by cors() with their policy
+var corsPolicySymbol = goja.NewSymbol("cors.policy")
+
+// defaultCORSMethods are the methods allowed without a methods option, as in Express' cors
+var defaultCORSMethods = []string{"GET", "HEAD", "PUT", "PATCH", "POST", "DELETE"}
+
+// CORSPolicy says which cross-origin requests browsers may make
+type CORSPolicy struct {
+	Origins        []string      // Allowed origins, "*" for any
+	ReflectOrigin  bool          // Allow any origin by echoing it, needed with credentials
+	Methods        []string      // Methods allowed by preflights, nil for the defaults of cors()
+	AllowedHeaders []string      // Request headers allowed by preflights, nil to allow the requested ones
+	ExposedHeaders []string      // Response headers scripts may read
+	Credentials    bool          // Allow cookies and authorization headers
+	MaxAge         time.Duration // How long browsers may cache a preflight, 0 to not say
+}
+
+// ParseCORSOptions reads the options of cors()
+func ParseCORSOptions(options map[string]interface{}) (*CORSPolicy, error) {
+	p := &CORSPolicy{}
+
+	switch origin := options["origin"].(type) {
+	case nil:
+		p.Origins = []string{"*"}
+	case bool:
+		if !origin {
+			return nil, fmt.Errorf("origin false allows no origin, leave cors() out instead")
+		}
+		p.ReflectOrigin = true
+	default:
+		origins, err := stringListOption("origin", origin)
+		if err != nil {
+			return nil, err
+		}
+		if len(origins) == 0 {
+			return nil, fmt.Errorf("origin must name at least one origin")
+		}
+		p.Origins = origins
+	}
+
+	var err error
+	if value, ok := options["methods"]; ok && value != nil {
+		if p.Methods, err = stringListOption("methods", value); err != nil {
+			return nil, err
+		}
+		for i, method := range p.Methods {
+			p.Methods[i] = strings.ToUpper(method)
+		}
+	}
+	if value, ok := options["allowedHeaders"]; ok && value != nil {
+		if p.AllowedHeaders, err = stringListOption("allowedHeaders", value); err != nil {
+			return nil, err
+		}
+	}
+	if value, ok := options["exposedHeaders"]; ok && value != nil {
+		if p.ExposedHeaders, err = stringListOption("exposedHeaders", value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch credentials := options["credentials"].(type) {
+	case nil:
+	case bool:
+		p.Credentials = credentials
+	default:
+		return nil, fmt.Errorf("credentials must be a boolean, got %T", credentials)
+	}
+
+	if value, ok := options["maxAge"]; ok && value != nil {
+		if p.MaxAge, err = parseDurationOption("maxAge", value); err != nil {
+			return nil, err
+		}
+		if p.MaxAge < 0 {
+			return nil, fmt.Errorf("maxAge must not be negative")
+		}
+	}
+	return p, nil
+}
+
+// stringListOption reads an option given as a string, a comma separated string or a list
+func stringListOption(name string, value interface{}) ([]string, error) {
+	var items []string
+	switch v := value.(type) {
+	case string:
+		items = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must list strings, got %T", name, item)
+			}
+			items = append(items, s)
+		}
+	default:
+		return nil, fmt.Errorf("%s must be a string or a list of strings, got %T", name, value)
+	}
+
+	var list []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list, nil
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value answering origin, "" if the
+// origin is not allowed
+func (p *CORSPolicy) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	if p.ReflectOrigin {
+		return origin
+	}
+	for _, allowed := range p.Origins {
+		if allowed == "*" {
+			if p.Credentials {
+				// Browsers refuse credentials with a wildcard origin
+				return origin
+			}
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// Apply sets the CORS headers of a response to r and reports whether its origin is allowed
+func (p *CORSPolicy) Apply(h http.Header, r *http.Request) bool {
+	allowed := p.allowedOrigin(r.Header.Get("Origin"))
+	if allowed != "*" {
+		addVary(h, "Origin")
+	}
+	if allowed == "" {
+		return false
+	}
+	h.Set("Access-Control-Allow-Origin", allowed)
+	if p.Credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(p.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+	}
+	return true
+}
+
+// Preflight answers a preflight request, which IsPreflight recognizes
+func (p *CORSPolicy) Preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	if p.Apply(h, r) {
+		methods := p.Methods
+		if len(methods) == 0 {
+			methods = defaultCORSMethods
+		}
+		h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if p.AllowedHeaders != nil {
+			h.Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			h.Set("Access-Control-Allow-Headers", requested)
+			addVary(h, "Access-Control-Request-Headers")
+		}
+		if p.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+		}
+	}
+	h.Set("Content-Length", "0")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// IsPreflight reports whether r is a CORS preflight request
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// addVary adds a header name to the Vary header unless it is listed already
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// cors implements cors(options): middleware carrying its policy, see corsPolicyOf
+func (e *Engine) cors(options ...goja.Value) goja.Value {
+	opts := map[string]interface{}{}
+	if len(options) > 0 && !goja.IsUndefined(options[0]) && !goja.IsNull(options[0]) {
+		exported, ok := options[0].Export().(map[string]interface{})
+		if !ok {
+			panic(e.rt.NewTypeError("cors() takes an options object"))
+		}
+		opts = exported
+	}
+	policy, err := ParseCORSOptions(opts)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("cors(): %v", err)))
+	}
+
+	// The server applies the policy before the handler; calling the middleware again is harmless
+	fn := e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		if res, ok := fc.Argument(1).Export().(*ExpressResponse); ok && res.writer != nil && res.request != nil {
+			policy.Apply(res.writer.Header(), res.request)
+		}
+		return goja.Undefined()
+	}).ToObject(e.rt)
+	if err := fn.DefineDataPropertySymbol(corsPolicySymbol, e.rt.ToValue(policy), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return fn
+}
+
+// corsPolicyOf returns the policy of a middleware function returned by cors(), nil for others
+func corsPolicyOf(fn goja.Value) *CORSPolicy {
+	obj, ok := fn.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	value := obj.GetSymbol(corsPolicySymbol)
+	if value == nil {
+		return nil
+	}
+	policy, _ := value.Export().(*CORSPolicy)
+	return policy
+}
+
+// setGlobalCORS sets the policy app.use(cors()) applies to routes without their own
+func (e *Engine) setGlobalCORS(policy *CORSPolicy) {
+	root := e.root()
+	root.mu.Lock()
+	root.globalCORS = policy
+	root.mu.Unlock()
+	if e.primary == nil {
+		log.Info().Strs("origins", policy.Origins).Msg("Enabled CORS for all routes")
+	}
+}
+
+// CORSPolicy returns the CORS policy of a route: its own cors() middleware, else the one
+// set with app.use(cors()). Nil means cross-origin requests get no CORS headers.
+func (e *Engine) CORSPolicy(handler *HandlerInfo) *CORSPolicy {
+	if handler != nil && handler.Middleware != nil && handler.Middleware.CORS != nil {
+		return handler.Middleware.CORS
+	}
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.globalCORS
+}
+
+// SetAdminCORS sets the CORS policy of the admin server and /v1/execute, nil for none. It is
+// separate from the policies of JavaScript routes and read when the admin routes are set up.
+func (e *Engine) SetAdminCORS(policy *CORSPolicy) {
+	e.mu.Lock()
+	e.adminCORS = policy
+	e.mu.Unlock()
+}
+
+// AdminCORS returns the CORS policy of the admin server, nil if none is configured
+func (e *Engine) AdminCORS() *CORSPolicy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.adminCORS
+}
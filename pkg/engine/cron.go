@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Cron expressions
+//
+// cron.validate() and cron.next() check cron expressions and preview when they fire, for
+// scripts building scheduling UIs; the admin server answers the same at /admin/cron:
+//
+//	cron.validate('*/15 9-17 * * MON-FRI')    // { valid: true }
+//	cron.next('0 3 * * *', 5, { timeZone: 'Europe/Berlin' })
+//
+// Expressions have the five fields minute, hour, day of month, month and day of week, each
+// *, a value, a range a-b, a step */n or a-b/n, or a list of these separated by commas.
+// Months and days of the week may be given by name (JAN, MON); 7 is Sunday like 0. When
+// both day fields are restricted, a day matching either fires, as in Vixie cron. @yearly,
+// @monthly, @weekly, @daily and @hourly are accepted as well. Times are computed in the
+// server's time zone unless one is given.
+
+// maxCronPreview bounds the run times a preview returns
+const maxCronPreview = 100
+
+// cronMacros are the shorthands accepted for common schedules
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField describes the values of a field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min on, for months and days of the week
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// CronSchedule is a parsed cron expression
+type CronSchedule struct {
+	Expression string
+	minute     uint64 // Bit i is set if the field matches value i
+	hour       uint64
+	dom        uint64
+	month      uint64
+	dow        uint64
+	domAny     bool // Day of month is *, so only the day of week restricts days
+	dowAny     bool // Day of week is *, so only the day of month restricts days
+}
+
+// ParseCron parses a cron expression
+func ParseCron(expr string) (*CronSchedule, error) {
+	expression := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(expression)]; ok {
+		expression = macro
+	}
+
+	parts := strings.Fields(expression)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(parts))
+	}
+
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		var err error
+		if bits[i], err = parseCronField(part, cronFields[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sunday is 0 and 7
+	dow := bits[4]
+	if dow&(1<<7) != 0 {
+		dow |= 1
+	}
+	return &CronSchedule{
+		Expression: strings.TrimSpace(expr),
+		minute:     bits[0],
+		hour:       bits[1],
+		dom:        bits[2],
+		month:      bits[3],
+		dow:        dow,
+		domAny:     parts[2] == "*" || parts[2] == "?",
+		dowAny:     parts[4] == "*" || parts[4] == "?",
+	}, nil
+}
+
+// parseCronField parses one field into a bit set of the values it matches
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field %q", stepPart, field.name, value)
+			}
+			step = n
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			low, high = field.min, field.max
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = parseCronValue(from, field); err != nil {
+				return 0, err
+			}
+			if high, err = parseCronValue(to, field); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s field, the start is after the end", rangePart, field.name)
+			}
+		default:
+			n, err := parseCronValue(rangePart, field)
+			if err != nil {
+				return 0, err
+			}
+			low, high = n, n
+			if hasStep {
+				high = field.max
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue parses a number or name of a field
+func parseCronValue(value string, field cronField) (int, error) {
+	for i, name := range field.names {
+		if strings.EqualFold(value, name) {
+			return field.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", value, field.name)
+	}
+	if n < field.min || n > field.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d in %s field", n, field.min, field.max, field.name)
+	}
+	return n, nil
+}
+
+// matchesDay reports whether the schedule fires on the day of t
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first time after t the schedule fires, in the location of t. It returns
+// the zero time if the schedule never fires, like 0 0 30 2 *.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Preview returns the next count times after from the schedule fires
+func (s *CronSchedule) Preview(from time.Time, count int) []time.Time {
+	if count > maxCronPreview {
+		count = maxCronPreview
+	}
+	if count < 0 {
+		count = 0
+	}
+	times := make([]time.Time, 0, count)
+	for t := from; len(times) < count; {
+		if t = s.Next(t); t.IsZero() {
+			break
+		}
+		times = append(times, t)
+	}
+	return times
+}
+
+// CronPreview is the answer of /admin/cron: whether an expression is valid and when it fires
+type CronPreview struct {
+	Expression string   `json:"expression"`
+	Valid      bool     `json:"valid"`
+	Error      string   `json:"error,omitempty"`
+	TimeZone   string   `json:"timeZone"`
+	Next       []string `json:"next"` // RFC 3339 times in TimeZone
+}
+
+// PreviewCron validates a cron expression and lists its next count run times in a time
+// zone, the server's if timeZone is "". An invalid expression is reported in the preview;
+// the error is for an unknown time zone.
+func PreviewCron(expr string, count int, timeZone string, from time.Time) (CronPreview, error) {
+	loc := time.Local
+	if timeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timeZone); err != nil {
+			return CronPreview{}, fmt.Errorf("unknown time zone %q: %w", timeZone, err)
+		}
+	}
+
+	preview := CronPreview{Expression: expr, TimeZone: loc.String(), Next: []string{}}
+	schedule, err := ParseCron(expr)
+	if err != nil {
+		preview.Error = err.Error()
+		return preview, nil
+	}
+	preview.Valid = true
+	for _, t := range schedule.Preview(from.In(loc), count) {
+		preview.Next = append(preview.Next, t.Format(time.RFC3339))
+	}
+	return preview, nil
+}
+
+// setupCronBindings installs cron
+func (e *Engine) setupCronBindings() {
+	if err := e.rt.Set("cron", map[string]interface{}{
+		"validate": e.cronValidate,
+		"next":     e.cronNext,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set cron binding")
+	}
+}
+
+// cronValidate implements cron.validate(expr): { valid, error }
+func (e *Engine) cronValidate(expr string) map[string]interface{} {
+	if _, err := ParseCron(expr); err != nil {
+		return map[string]interface{}{"valid": false, "error": err.Error()}
+	}
+	return map[string]interface{}{"valid": true}
+}
+
+// cronNext implements cron.next(expr, [count], [options]): the next count (default 1) run
+// times as Date objects. Options: from (Date, timestamp or ISO string; default now) and
+// timeZone (IANA name; default the server's). Invalid expressions throw.
+func (e *Engine) cronNext(call goja.FunctionCall) goja.Value {
+	schedule, err := ParseCron(call.Argument(0).String())
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+
+	count := 1
+	if arg := call.Argument(1); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+		count = int(arg.ToInteger())
+	}
+
+	from := time.Now()
+	loc := time.Local
+	if opts, ok := call.Argument(2).Export().(map[string]interface{}); ok {
+		if value, ok := opts["from"]; ok && value != nil {
+			from = e.toTime(value)
+		}
+		if tz, ok := opts["timeZone"].(string); ok && tz != "" {
+			if loc, err = time.LoadLocation(tz); err != nil {
+				panic(e.rt.NewGoError(fmt.Errorf("unknown time zone %q: %w", tz, err)))
+			}
+		}
+	}
+
+	var dates []interface{}
+	for _, t := range schedule.Preview(from.In(loc), count) {
+		date, err := e.rt.New(e.rt.Get("Date"), e.rt.ToValue(t.UnixMilli()))
+		if err != nil {
+			panic(e.rt.NewGoError(err))
+		}
+		dates = append(dates, date)
+	}
+	return e.rt.ToValue(dates)
+}
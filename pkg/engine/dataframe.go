@@ -0,0 +1,662 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Data frames
+//
+// dataframe.fromQuery() loads query results into a table kept in Go, so report endpoints can
+// filter, group and aggregate them without looping over row objects in JavaScript:
+//
+//	const sales = dataframe.fromQuery('SELECT region, product, amount FROM sales WHERE year = ?', 2024);
+//	const byRegion = sales
+//	    .filter({ product: ['book', 'ebook'] })
+//	    .groupBy('region')
+//	    .aggregate({ total: 'sum(amount)', orders: 'count(*)', average: 'avg(amount)' })
+//	    .sortBy('total', 'desc');
+//	res.json(byRegion);                        // [{region: 'EU', total: 1200, orders: 31, average: 38.7}, ...]
+//	res.type('text/csv').send(byRegion.toCSV());
+//
+// Frames are immutable: every method returns a new frame. Aggregates are count(*),
+// count(col) (values that are not null), count(distinct col), sum, avg, min, max, first
+// and last, or a function receiving the rows of the group.
+
+// DataFrame is a table of rows with named columns
+type DataFrame struct {
+	e       *Engine
+	columns []string
+	rows    [][]interface{} // Cells in the order of columns
+}
+
+// GroupedFrame is a data frame split into groups by the values of some columns
+type GroupedFrame struct {
+	frame  *DataFrame
+	keys   []int   // Indexes of the grouping columns
+	groups [][]int // Rows of each group, groups in order of their first row
+}
+
+// aggregateSpecRegexp reads aggregates like sum(amount), count(*) or count(distinct user)
+var aggregateSpecRegexp = regexp.MustCompile(`^\s*(\w+)\s*\(\s*(distinct\s+)?([^()]*?)\s*\)\s*$`)
+
+// setupDataFrameBindings installs the dataframe object
+func (e *Engine) setupDataFrameBindings() {
+	if err := e.rt.Set("dataframe", map[string]interface{}{
+		"fromQuery": e.dataFrameFromQuery,
+		"fromRows":  e.dataFrameFromRows,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set dataframe binding")
+	}
+}
+
+// dataFrameFromQuery implements dataframe.fromQuery(sql, ...args). Columns come in the order
+// the query names them; those it does not name, like the columns of *, follow alphabetically.
+func (e *Engine) dataFrameFromQuery(query string, args ...interface{}) (*DataFrame, error) {
+	start := time.Now()
+	rows, err := e.dbModule.Query(query, args...)
+	e.recordDatabaseOperation("query", query, args, start, err, func(op *DatabaseOperation) {
+		op.Result = len(rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var columns []string
+	if len(rows) > 0 {
+		for column := range rows[0] {
+			columns = append(columns, column)
+		}
+	}
+	sql := strings.ToLower(query)
+	position := make(map[string]int, len(columns))
+	for _, column := range columns {
+		position[column] = len(sql)
+		if i := regexp.MustCompile(`\b` + regexp.QuoteMeta(strings.ToLower(column)) + `\b`).FindStringIndex(sql); i != nil {
+			position[column] = i[0]
+		}
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		pi, pj := position[columns[i]], position[columns[j]]
+		if pi != pj {
+			return pi < pj
+		}
+		return columns[i] < columns[j]
+	})
+
+	df := &DataFrame{e: e, columns: columns, rows: make([][]interface{}, len(rows))}
+	for i, row := range rows {
+		cells := make([]interface{}, len(columns))
+		for j, column := range columns {
+			cells[j] = dataFrameCell(row[column])
+		}
+		df.rows[i] = cells
+	}
+	return df, nil
+}
+
+// dataFrameFromRows implements dataframe.fromRows(rows): an array of objects, whose keys in
+// order of first appearance are the columns
+func (e *Engine) dataFrameFromRows(rows goja.Value) *DataFrame {
+	obj, ok := rows.(*goja.Object)
+	if !ok || obj.ClassName() != "Array" {
+		panic(e.rt.NewTypeError("dataframe.fromRows() takes an array of objects"))
+	}
+	length := int(obj.Get("length").ToInteger())
+
+	df := &DataFrame{e: e}
+	index := map[string]int{}
+	objects := make([]*goja.Object, length)
+	for i := 0; i < length; i++ {
+		row, ok := obj.Get(fmt.Sprint(i)).(*goja.Object)
+		if !ok {
+			panic(e.rt.NewTypeError(fmt.Sprintf("dataframe.fromRows(): row %d is not an object", i)))
+		}
+		objects[i] = row
+		for _, key := range row.Keys() {
+			if _, seen := index[key]; !seen {
+				index[key] = len(df.columns)
+				df.columns = append(df.columns, key)
+			}
+		}
+	}
+	df.rows = make([][]interface{}, length)
+	for i, row := range objects {
+		cells := make([]interface{}, len(df.columns))
+		for _, key := range row.Keys() {
+			cells[index[key]] = dataFrameCell(row.Get(key).Export())
+		}
+		df.rows[i] = cells
+	}
+	return df
+}
+
+// dataFrameCell normalizes a value read from the database or JavaScript
+func dataFrameCell(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case int:
+		return int64(v)
+	case float64:
+		// Whole numbers from JavaScript group and compare like integers from the database
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+	}
+	return value
+}
+
+// derive returns a frame with the columns of df and the given rows
+func (df *DataFrame) derive(rows [][]interface{}) *DataFrame {
+	return &DataFrame{e: df.e, columns: df.columns, rows: rows}
+}
+
+// column returns the index of a column, throwing for unknown ones
+func (df *DataFrame) column(name string) int {
+	for i, column := range df.columns {
+		if column == name {
+			return i
+		}
+	}
+	panic(df.e.rt.NewTypeError(fmt.Sprintf("dataframe: no column %q, the columns are %s", name, strings.Join(df.columns, ", "))))
+}
+
+// rowObject turns a row into a JavaScript object with the keys in column order
+func (df *DataFrame) rowObject(cells []interface{}) *goja.Object {
+	obj := df.e.rt.NewObject()
+	for i, column := range df.columns {
+		_ = obj.Set(column, cells[i])
+	}
+	return obj
+}
+
+// Columns implements df.columns(): the column names, in order
+func (df *DataFrame) Columns() []string {
+	return append([]string{}, df.columns...)
+}
+
+// Count implements df.count(): the number of rows
+func (df *DataFrame) Count() int {
+	return len(df.rows)
+}
+
+// Filter implements df.filter(predicate): the rows for which predicate(row, index) is truthy,
+// or whose columns equal the values of an object like { status: 'paid', region: ['EU', 'US'] },
+// arrays matching any of their values
+func (df *DataFrame) Filter(predicate goja.Value) *DataFrame {
+	rows := [][]interface{}{}
+	if fn, ok := goja.AssertFunction(predicate); ok {
+		for i, cells := range df.rows {
+			keep, err := fn(goja.Undefined(), df.rowObject(cells), df.e.rt.ToValue(i))
+			if err != nil {
+				panic(err)
+			}
+			if keep.ToBoolean() {
+				rows = append(rows, cells)
+			}
+		}
+		return df.derive(rows)
+	}
+
+	obj, ok := predicate.(*goja.Object)
+	if !ok {
+		panic(df.e.rt.NewTypeError("df.filter() takes a function of (row, index) or an object of column values"))
+	}
+	type condition struct {
+		column int
+		values []interface{}
+	}
+	var conditions []condition
+	for _, key := range obj.Keys() {
+		c := condition{column: df.column(key)}
+		switch value := obj.Get(key).Export().(type) {
+		case []interface{}:
+			for _, v := range value {
+				c.values = append(c.values, dataFrameCell(v))
+			}
+		default:
+			c.values = []interface{}{dataFrameCell(value)}
+		}
+		conditions = append(conditions, c)
+	}
+	for _, cells := range df.rows {
+		matches := true
+		for _, c := range conditions {
+			found := false
+			for _, v := range c.values {
+				if compareCells(cells[c.column], v) == 0 {
+					found = true
+					break
+				}
+			}
+			if !found {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			rows = append(rows, cells)
+		}
+	}
+	return df.derive(rows)
+}
+
+// Select implements df.select(...columns): the given columns, in that order
+func (df *DataFrame) Select(columns ...string) *DataFrame {
+	indexes := make([]int, len(columns))
+	for i, column := range columns {
+		indexes[i] = df.column(column)
+	}
+	rows := make([][]interface{}, len(df.rows))
+	for i, cells := range df.rows {
+		selected := make([]interface{}, len(indexes))
+		for j, index := range indexes {
+			selected[j] = cells[index]
+		}
+		rows[i] = selected
+	}
+	return &DataFrame{e: df.e, columns: append([]string{}, columns...), rows: rows}
+}
+
+// WithColumn implements df.withColumn(name, fn): adds or replaces a column with the values of
+// fn(row, index)
+func (df *DataFrame) WithColumn(name string, fn goja.Value) *DataFrame {
+	compute, ok := goja.AssertFunction(fn)
+	if !ok {
+		panic(df.e.rt.NewTypeError("df.withColumn() takes a column name and a function of (row, index)"))
+	}
+	columns := df.columns
+	index := -1
+	for i, column := range columns {
+		if column == name {
+			index = i
+		}
+	}
+	if index < 0 {
+		index = len(columns)
+		columns = append(append([]string{}, columns...), name)
+	}
+
+	rows := make([][]interface{}, len(df.rows))
+	for i, cells := range df.rows {
+		value, err := compute(goja.Undefined(), df.rowObject(cells), df.e.rt.ToValue(i))
+		if err != nil {
+			panic(err)
+		}
+		row := make([]interface{}, len(columns))
+		copy(row, cells)
+		row[index] = dataFrameCell(value.Export())
+		rows[i] = row
+	}
+	return &DataFrame{e: df.e, columns: columns, rows: rows}
+}
+
+// SortBy implements df.sortBy(column, [order]): rows ordered by a column, 'asc' (default) or
+// 'desc'. Nulls come first in ascending order; rows with equal values keep their order.
+func (df *DataFrame) SortBy(column string, order ...string) *DataFrame {
+	index := df.column(column)
+	desc := false
+	if len(order) > 0 {
+		switch strings.ToLower(order[0]) {
+		case "asc":
+		case "desc":
+			desc = true
+		default:
+			panic(df.e.rt.NewTypeError(fmt.Sprintf("df.sortBy(): order must be 'asc' or 'desc', got %q", order[0])))
+		}
+	}
+	rows := append([][]interface{}{}, df.rows...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		c := compareCells(rows[i][index], rows[j][index])
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	return df.derive(rows)
+}
+
+// Head implements df.head(n): the first n rows
+func (df *DataFrame) Head(n int) *DataFrame {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(df.rows) {
+		n = len(df.rows)
+	}
+	return df.derive(df.rows[:n])
+}
+
+// GroupBy implements df.groupBy(...columns): the rows grouped by the values of the columns,
+// to be aggregated
+func (df *DataFrame) GroupBy(columns ...string) *GroupedFrame {
+	if len(columns) == 0 {
+		panic(df.e.rt.NewTypeError("df.groupBy() needs at least one column"))
+	}
+	g := &GroupedFrame{frame: df}
+	for _, column := range columns {
+		g.keys = append(g.keys, df.column(column))
+	}
+	groupOf := map[string]int{}
+	for i, cells := range df.rows {
+		var key strings.Builder
+		for _, index := range g.keys {
+			fmt.Fprintf(&key, "%T:%v\x00", cells[index], cells[index])
+		}
+		n, ok := groupOf[key.String()]
+		if !ok {
+			n = len(g.groups)
+			groupOf[key.String()] = n
+			g.groups = append(g.groups, nil)
+		}
+		g.groups[n] = append(g.groups[n], i)
+	}
+	return g
+}
+
+// Count implements grouped.count(): the number of groups
+func (g *GroupedFrame) Count() int {
+	return len(g.groups)
+}
+
+// Aggregate implements grouped.aggregate(spec): one row per group with the grouping columns
+// and a column per aggregate of spec, like { total: 'sum(amount)', n: 'count(*)' }
+func (g *GroupedFrame) Aggregate(spec goja.Value) *DataFrame {
+	df := g.frame
+	aggregates := df.parseAggregates("grouped.aggregate()", spec)
+
+	columns := make([]string, 0, len(g.keys)+len(aggregates))
+	for _, index := range g.keys {
+		columns = append(columns, df.columns[index])
+	}
+	for _, a := range aggregates {
+		columns = append(columns, a.name)
+	}
+
+	rows := make([][]interface{}, len(g.groups))
+	for i, members := range g.groups {
+		row := make([]interface{}, 0, len(columns))
+		for _, index := range g.keys {
+			row = append(row, df.rows[members[0]][index])
+		}
+		for _, a := range aggregates {
+			row = append(row, a.compute(df, members))
+		}
+		rows[i] = row
+	}
+	return &DataFrame{e: df.e, columns: columns, rows: rows}
+}
+
+// Aggregate implements df.aggregate(spec): a single row aggregating every row, see
+// GroupedFrame.Aggregate
+func (df *DataFrame) Aggregate(spec goja.Value) *DataFrame {
+	aggregates := df.parseAggregates("df.aggregate()", spec)
+	members := make([]int, len(df.rows))
+	for i := range members {
+		members[i] = i
+	}
+	columns := make([]string, len(aggregates))
+	row := make([]interface{}, len(aggregates))
+	for i, a := range aggregates {
+		columns[i] = a.name
+		row[i] = a.compute(df, members)
+	}
+	return &DataFrame{e: df.e, columns: columns, rows: [][]interface{}{row}}
+}
+
+// dataFrameAggregate is one column of an aggregation
+type dataFrameAggregate struct {
+	name     string
+	fn       string        // count, sum, avg, min, max, first or last
+	column   int           // -1 for count(*)
+	distinct bool          // count(distinct column)
+	custom   goja.Callable // Function of the rows of the group, instead of fn
+}
+
+// parseAggregates reads the aggregates of an aggregation spec, in the order of its keys
+func (df *DataFrame) parseAggregates(name string, spec goja.Value) []dataFrameAggregate {
+	obj, ok := spec.(*goja.Object)
+	if !ok || len(obj.Keys()) == 0 {
+		panic(df.e.rt.NewTypeError(name + " takes an object like { total: 'sum(amount)', orders: 'count(*)' }"))
+	}
+	var aggregates []dataFrameAggregate
+	for _, key := range obj.Keys() {
+		value := obj.Get(key)
+		if fn, ok := goja.AssertFunction(value); ok {
+			aggregates = append(aggregates, dataFrameAggregate{name: key, custom: fn})
+			continue
+		}
+		match := aggregateSpecRegexp.FindStringSubmatch(value.String())
+		if match == nil {
+			panic(df.e.rt.NewTypeError(fmt.Sprintf("%s: %s must be like 'sum(column)' or a function of the rows, got %q", name, key, value.String())))
+		}
+		a := dataFrameAggregate{name: key, fn: strings.ToLower(match[1]), column: -1, distinct: match[2] != ""}
+		switch a.fn {
+		case "count", "sum", "avg", "min", "max", "first", "last":
+		default:
+			panic(df.e.rt.NewTypeError(fmt.Sprintf("%s: unknown aggregate %s(), use count, sum, avg, min, max, first or last", name, match[1])))
+		}
+		if match[3] == "*" {
+			if a.fn != "count" || a.distinct {
+				panic(df.e.rt.NewTypeError(fmt.Sprintf("%s: only count() takes *", name)))
+			}
+		} else {
+			a.column = df.column(match[3])
+		}
+		if a.distinct && a.fn != "count" {
+			panic(df.e.rt.NewTypeError(fmt.Sprintf("%s: only count() takes distinct", name)))
+		}
+		aggregates = append(aggregates, a)
+	}
+	return aggregates
+}
+
+// compute aggregates the given rows of df
+func (a dataFrameAggregate) compute(df *DataFrame, members []int) interface{} {
+	if a.custom != nil {
+		rows := make([]interface{}, len(members))
+		for i, member := range members {
+			rows[i] = df.rowObject(df.rows[member])
+		}
+		value, err := a.custom(goja.Undefined(), df.e.rt.ToValue(rows))
+		if err != nil {
+			panic(err)
+		}
+		return dataFrameCell(value.Export())
+	}
+	if a.column < 0 {
+		return int64(len(members))
+	}
+
+	switch a.fn {
+	case "first":
+		if len(members) == 0 {
+			return nil
+		}
+		return df.rows[members[0]][a.column]
+	case "last":
+		if len(members) == 0 {
+			return nil
+		}
+		return df.rows[members[len(members)-1]][a.column]
+	}
+
+	var values []interface{}
+	for _, member := range members {
+		if value := df.rows[member][a.column]; value != nil {
+			values = append(values, value)
+		}
+	}
+	switch a.fn {
+	case "count":
+		if !a.distinct {
+			return int64(len(values))
+		}
+		seen := map[string]bool{}
+		for _, value := range values {
+			seen[fmt.Sprintf("%T:%v", value, value)] = true
+		}
+		return int64(len(seen))
+	case "min", "max":
+		var best interface{}
+		for _, value := range values {
+			c := compareCells(value, best)
+			if best == nil || (a.fn == "min" && c < 0) || (a.fn == "max" && c > 0) {
+				best = value
+			}
+		}
+		return best
+	}
+
+	// sum and avg of the numeric values; integers sum to an integer
+	var intSum int64
+	var floatSum float64
+	count, integers := 0, true
+	for _, value := range values {
+		switch n := value.(type) {
+		case int64:
+			intSum += n
+			floatSum += float64(n)
+		case float64:
+			integers = false
+			floatSum += n
+		default:
+			continue
+		}
+		count++
+	}
+	if a.fn == "avg" {
+		if count == 0 {
+			return nil
+		}
+		return floatSum / float64(count)
+	}
+	if integers {
+		return intSum
+	}
+	return floatSum
+}
+
+// compareCells orders two cells: nulls first, numbers by value, times by instant, everything
+// else by its text
+func compareCells(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if na, ok := cellNumber(a); ok {
+		if nb, ok := cellNumber(b); ok {
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// cellNumber returns the value of a numeric cell
+func cellNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// ToJSON implements df.toJSON(): the rows as an array of objects
+func (df *DataFrame) ToJSON() []interface{} {
+	rows := make([]interface{}, len(df.rows))
+	for i, cells := range df.rows {
+		rows[i] = df.rowObject(cells)
+	}
+	return rows
+}
+
+// MarshalJSON encodes the frame as its rows, keys in column order, so res.json(df) and
+// JSON.stringify(df) send the rows
+func (df *DataFrame) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, cells := range df.rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		for j, column := range df.columns {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(column)
+			value, err := json.Marshal(cells[j])
+			if err != nil {
+				return nil, fmt.Errorf("column %s of row %d: %w", column, i, err)
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// ToCSV implements df.toCSV(): the frame as CSV with a header row. Nulls are empty fields.
+func (df *DataFrame) ToCSV() (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(df.columns); err != nil {
+		return "", err
+	}
+	record := make([]string, len(df.columns))
+	for _, cells := range df.rows {
+		for i, value := range cells {
+			switch v := value.(type) {
+			case nil:
+				record[i] = ""
+			case time.Time:
+				record[i] = v.Format(time.RFC3339)
+			case float64:
+				record[i] = fmt.Sprint(v)
+			case map[string]interface{}, []interface{}:
+				data, _ := json.Marshal(v)
+				record[i] = string(data)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	databasemod "github.com/go-go-golems/go-go-goja/modules/database"
+	"github.com/rs/zerolog/log"
+)
+
+// setupDatabaseBindings exposes the application database as the global `db` object, with the
+// configure() and close() of the database module.
+// Every query and exec is recorded on the current request log so the admin console can
+// show the database operations a handler performed. db.transaction() groups them, see
+// dbTransaction; db.prepare() returns statements kept prepared, see dbPrepare.
+func (e *Engine) setupDatabaseBindings(dbModule *databasemod.DBModule) {
+	if err := e.rt.Set("db", map[string]interface{}{
+		"query": func(query string, args ...interface{}) ([]map[string]interface{}, error) {
+			start := time.Now()
+			rows, err := e.dbQuery(dbModule, query, args...)
+			e.recordDatabaseOperation("query", query, args, start, err, func(op *DatabaseOperation) {
+				op.Result = len(rows)
+			})
+			return rows, err
+		},
+		"exec": func(query string, args ...interface{}) (map[string]interface{}, error) {
+			if e.warmingUp {
+				return nil, errWarmUpWrite
+			}
+			if err := e.checkDBWrite(); err != nil {
+				return nil, err
+			}
+			start := time.Now()
+			result, err := e.dbExec(dbModule, query, args...)
+			e.recordDatabaseOperation("exec", query, args, start, err, func(op *DatabaseOperation) {
+				if rowsAffected, ok := result["rowsAffected"].(int64); ok {
+					op.RowsAffected = rowsAffected
+				}
+				if lastInsertId, ok := result["lastInsertId"].(int64); ok {
+					op.LastInsertId = lastInsertId
+				}
+			})
+			return result, err
+		},
+		"configure": func(driverName, dataSourceName string) error {
+			if e.tx != nil {
+				return fmt.Errorf("db.configure() cannot switch databases inside db.transaction()")
+			}
+			// Cached statements belong to the connection that is replaced
+			e.clearStatements()
+			return dbModule.Configure(driverName, dataSourceName)
+		},
+		"close": func() error {
+			e.clearStatements()
+			return dbModule.Close()
+		},
+		"prepare":      e.dbPrepare,
+		"transaction":  e.dbTransaction,
+		"defineSchema": e.jsDefineSchema(dbModule),
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set db binding")
+	}
+}
+
+// recordDatabaseOperation adds a database operation to the current request log, if any, and
+// notes the tables a successful exec wrote to in the changes of the running code execution
+func (e *Engine) recordDatabaseOperation(opType, query string, args []interface{}, start time.Time, err error, fill func(op *DatabaseOperation)) {
+	if opType == "exec" && err == nil {
+		e.changes.tableWritten(query)
+	}
+	if e.currentReqID == "" {
+		return
+	}
+
+	op := DatabaseOperation{
+		Timestamp:  start,
+		Type:       opType,
+		SQL:        query,
+		Parameters: args,
+		Duration:   time.Since(start),
+	}
+	if err != nil {
+		op.Error = err.Error()
+	} else if fill != nil {
+		fill(&op)
+	}
+
+	e.reqLogger.AddDatabaseOperation(e.currentReqID, op)
+}
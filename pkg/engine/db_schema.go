@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	databasemod "github.com/go-go-golems/go-go-goja/modules/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Declarative schemas
+//
+// db.defineSchema() brings the application database up to a schema declared in the script,
+// instead of scattered CREATE TABLE IF NOT EXISTS statements:
+//
+//	db.defineSchema({
+//	    tables: {
+//	        users: {
+//	            columns: {
+//	                id: 'INTEGER PRIMARY KEY AUTOINCREMENT',
+//	                email: { type: 'TEXT', notNull: true, unique: true },
+//	                created_at: { type: 'TEXT', default: 'CURRENT_TIMESTAMP' },
+//	            },
+//	            indexes: { users_created: ['created_at'] },
+//	        },
+//	    },
+//	});
+//
+// Missing tables are created with their columns in declaration order. Existing tables get
+// the columns they lack added with ALTER TABLE, within SQLite's limits: an added column
+// cannot be a primary key or unique, and NOT NULL needs a default. Migrations are additive:
+// columns missing from the schema are kept and changed definitions are only reported, so
+// running the script again changes nothing. Indexes are created if missing. Every statement
+// applied is logged and returned as { applied: [...] }.
+
+// schemaColumn is a column of a declared table
+type schemaColumn struct {
+	name       string
+	definition string // Type and constraints, as in CREATE TABLE
+	declType   string // Declared type, compared with the existing column's
+}
+
+// schemaIndex is an index of a declared table
+type schemaIndex struct {
+	name    string
+	columns []string
+	unique  bool
+}
+
+// schemaTable is a table declared with db.defineSchema()
+type schemaTable struct {
+	name    string
+	columns []schemaColumn
+	indexes []schemaIndex
+}
+
+// jsDefineSchema implements db.defineSchema(schema)
+func (e *Engine) jsDefineSchema(dbModule *databasemod.DBModule) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		tables, err := parseSchema(call.Argument(0))
+		if err != nil {
+			panic(e.rt.NewTypeError(fmt.Sprintf("db.defineSchema(): %v", err)))
+		}
+
+		applied := []string{}
+		exec := func(ddl string) error {
+			start := time.Now()
+			_, err := dbModule.Exec(ddl)
+			e.recordDatabaseOperation("exec", ddl, nil, start, err, nil)
+			if err != nil {
+				return fmt.Errorf("%s: %w", ddl, err)
+			}
+			log.Info().Str("sql", ddl).Msg("Applied schema change")
+			applied = append(applied, ddl)
+			return nil
+		}
+
+		for _, table := range tables {
+			if err := migrateTable(dbModule, table, exec); err != nil {
+				panic(e.rt.NewGoError(fmt.Errorf("db.defineSchema(): table %s: %w", table.name, err)))
+			}
+		}
+		return e.rt.ToValue(map[string]interface{}{"applied": applied})
+	}
+}
+
+// migrateTable creates a table, or adds the columns and indexes it lacks
+func migrateTable(dbModule *databasemod.DBModule, table schemaTable, exec func(string) error) error {
+	existing, err := dbModule.Query("SELECT name, type FROM pragma_table_info(?)", table.name)
+	if err != nil {
+		return err
+	}
+
+	if len(existing) == 0 {
+		defs := make([]string, len(table.columns))
+		for i, column := range table.columns {
+			defs[i] = quoteIdentifier(column.name) + " " + column.definition
+		}
+		if err := exec(fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(table.name), strings.Join(defs, ", "))); err != nil {
+			return err
+		}
+	} else {
+		types := make(map[string]string, len(existing))
+		for _, row := range existing {
+			name, _ := row["name"].(string)
+			declType, _ := row["type"].(string)
+			types[strings.ToLower(name)] = declType
+		}
+		for _, column := range table.columns {
+			declType, ok := types[strings.ToLower(column.name)]
+			if !ok {
+				if err := exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdentifier(table.name), quoteIdentifier(column.name), column.definition)); err != nil {
+					return err
+				}
+				continue
+			}
+			if !strings.EqualFold(declType, column.declType) {
+				log.Warn().Str("table", table.name).Str("column", column.name).Str("existing", declType).Str("declared", column.declType).
+					Msg("Column type differs from the schema; defineSchema only adds columns")
+			}
+		}
+	}
+
+	for _, index := range table.indexes {
+		columns := make([]string, len(index.columns))
+		for i, column := range index.columns {
+			columns[i] = quoteIdentifier(column)
+		}
+		unique := ""
+		if index.unique {
+			unique = "UNIQUE "
+		}
+		rows, err := dbModule.Query("SELECT 1 FROM sqlite_master WHERE type = 'index' AND name = ?", index.name)
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			continue
+		}
+		if err := exec(fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", unique, quoteIdentifier(index.name), quoteIdentifier(table.name), strings.Join(columns, ", "))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSchema reads { tables: { name: { columns, indexes } } }, keeping declaration order
+func parseSchema(value goja.Value) ([]schemaTable, error) {
+	schema, ok := value.(*goja.Object)
+	if !ok || goja.IsNull(value) {
+		return nil, fmt.Errorf("schema must be an object like { tables: { ... } }")
+	}
+	tablesObj, ok := schema.Get("tables").(*goja.Object)
+	if !ok {
+		return nil, fmt.Errorf("schema.tables must map table names to { columns, indexes }")
+	}
+
+	var tables []schemaTable
+	for _, name := range tablesObj.Keys() {
+		spec, ok := tablesObj.Get(name).(*goja.Object)
+		if !ok {
+			return nil, fmt.Errorf("table %s must be an object with columns", name)
+		}
+		table := schemaTable{name: name}
+
+		columnsObj, ok := spec.Get("columns").(*goja.Object)
+		if !ok || len(columnsObj.Keys()) == 0 {
+			return nil, fmt.Errorf("table %s needs at least one column", name)
+		}
+		for _, columnName := range columnsObj.Keys() {
+			column, err := parseSchemaColumn(columnName, columnsObj.Get(columnName).Export())
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %w", name, err)
+			}
+			table.columns = append(table.columns, column)
+		}
+
+		if indexesObj, ok := spec.Get("indexes").(*goja.Object); ok {
+			for _, indexName := range indexesObj.Keys() {
+				index, err := parseSchemaIndex(indexName, indexesObj.Get(indexName).Export())
+				if err != nil {
+					return nil, fmt.Errorf("table %s: %w", name, err)
+				}
+				table.indexes = append(table.indexes, index)
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// parseSchemaColumn reads a column given as SQL ('TEXT NOT NULL') or as
+// { type, primaryKey, autoIncrement, notNull, unique, default, references }
+func parseSchemaColumn(name string, value interface{}) (schemaColumn, error) {
+	switch v := value.(type) {
+	case string:
+		definition := strings.TrimSpace(v)
+		if definition == "" {
+			return schemaColumn{}, fmt.Errorf("column %s needs a type", name)
+		}
+		return schemaColumn{name: name, definition: definition, declType: leadingType(definition)}, nil
+	case map[string]interface{}:
+		declType, _ := v["type"].(string)
+		if declType = strings.TrimSpace(declType); declType == "" {
+			return schemaColumn{}, fmt.Errorf("column %s needs a type", name)
+		}
+		parts := []string{declType}
+		if boolOption(v["primaryKey"]) {
+			parts = append(parts, "PRIMARY KEY")
+			if boolOption(v["autoIncrement"]) {
+				parts = append(parts, "AUTOINCREMENT")
+			}
+		}
+		if boolOption(v["notNull"]) {
+			parts = append(parts, "NOT NULL")
+		}
+		if boolOption(v["unique"]) {
+			parts = append(parts, "UNIQUE")
+		}
+		if def, ok := v["default"]; ok {
+			literal, err := sqlDefault(def)
+			if err != nil {
+				return schemaColumn{}, fmt.Errorf("column %s: %w", name, err)
+			}
+			parts = append(parts, "DEFAULT "+literal)
+		}
+		if references, ok := v["references"].(string); ok && references != "" {
+			// 'users' or 'users(id)'
+			parts = append(parts, "REFERENCES "+references)
+		}
+		return schemaColumn{name: name, definition: strings.Join(parts, " "), declType: declType}, nil
+	default:
+		return schemaColumn{}, fmt.Errorf("column %s must be a SQL definition or an object with a type, got %T", name, value)
+	}
+}
+
+// parseSchemaIndex reads an index given as a list of columns or as { columns, unique }
+func parseSchemaIndex(name string, value interface{}) (schemaIndex, error) {
+	index := schemaIndex{name: name}
+	columns := value
+	if v, ok := value.(map[string]interface{}); ok {
+		columns = v["columns"]
+		index.unique = boolOption(v["unique"])
+	}
+	list, err := stringListOption("index "+name+" columns", columns)
+	if err != nil {
+		return index, err
+	}
+	if len(list) == 0 {
+		return index, fmt.Errorf("index %s needs at least one column", name)
+	}
+	index.columns = list
+	return index, nil
+}
+
+// sqlDefault turns the default option of a column into a SQL literal. The strings
+// CURRENT_TIMESTAMP, CURRENT_DATE and CURRENT_TIME stay keywords.
+func sqlDefault(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		switch strings.ToUpper(v) {
+		case "CURRENT_TIMESTAMP", "CURRENT_DATE", "CURRENT_TIME":
+			return strings.ToUpper(v), nil
+		}
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	default:
+		return "", fmt.Errorf("default must be a string, number, boolean or null, got %T", value)
+	}
+}
+
+// leadingType returns the type of a SQL column definition, e.g. INTEGER for 'INTEGER PRIMARY KEY'
+func leadingType(definition string) string {
+	upper := strings.ToUpper(definition)
+	end := len(definition)
+	for _, keyword := range []string{" PRIMARY", " NOT", " NULL", " UNIQUE", " DEFAULT", " REFERENCES", " CHECK", " COLLATE", " GENERATED", " AS", " CONSTRAINT"} {
+		if i := strings.Index(upper, keyword); i >= 0 && i < end {
+			end = i
+		}
+	}
+	return strings.TrimSpace(definition[:end])
+}
+
+// boolOption reads a boolean option, false unless it is true
+func boolOption(value interface{}) bool {
+	b, _ := value.(bool)
+	return b
+}
+
+// quoteIdentifier quotes a table, column or index name for SQLite
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
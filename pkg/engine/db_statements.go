@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Prepared statements
+//
+// db.prepare(sql) returns a statement to run many times, parsed by SQLite once instead of on
+// every call:
+//
+//	const userByEmail = db.prepare('SELECT * FROM users WHERE email = ?');
+//	app.get('/users/by-email', (req, res) => res.json(userByEmail.get(req.query.email) ?? null));
+//
+//	stmt.all(...args)  the rows, [] without rows
+//	stmt.get(...args)  the first row, undefined without rows
+//	stmt.run(...args)  {success, rowsAffected, lastInsertId}, like db.exec()
+//
+// Statements are kept in a cache keyed by their SQL and shared by the runtimes of the pool,
+// so calling db.prepare() in a handler only prepares on the first request. The least
+// recently used statements are closed once the cache is full (SetStatementCacheSize), and
+// prepared again on their next use. Inside db.transaction() statements run in the
+// transaction.
+
+// DefaultStatementCacheSize is the number of prepared statements kept by default
+const DefaultStatementCacheSize = 100
+
+// statementCache keeps the prepared statements of the application database, least
+// recently used first out
+type statementCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List               // *cachedStatement elements, most recently used first
+	entries map[string]*list.Element // Elements of order by SQL
+}
+
+// cachedStatement is a prepared statement, closed once evicted and no longer running
+type cachedStatement struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int  // Calls running the statement
+	evicted bool // Out of the cache, closed when refs drops to 0
+}
+
+func newStatementCache(size int) *statementCache {
+	return &statementCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// acquire returns the statement of a query, preparing it if it is not cached. It must be
+// released once run.
+func (c *statementCache) acquire(db *sql.DB, query string) (*cachedStatement, error) {
+	c.mu.Lock()
+	if element, ok := c.entries[query]; ok {
+		c.order.MoveToFront(element)
+		cached := element.Value.(*cachedStatement)
+		cached.refs++
+		c.mu.Unlock()
+		return cached, nil
+	}
+	c.mu.Unlock()
+
+	// Prepared outside the lock, as it waits for a connection
+	stmt, err := db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	cached := &cachedStatement{query: query, stmt: stmt, refs: 1}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[query]; ok {
+		// Prepared concurrently, keep the cached one
+		_ = stmt.Close()
+		c.order.MoveToFront(element)
+		cached = element.Value.(*cachedStatement)
+		cached.refs++
+		return cached, nil
+	}
+	if c.size <= 0 {
+		cached.evicted = true
+		return cached, nil
+	}
+	c.entries[query] = c.order.PushFront(cached)
+	for c.order.Len() > c.size {
+		c.evictLocked(c.order.Back())
+	}
+	return cached, nil
+}
+
+// release ends a use of a statement, closing it if it was evicted meanwhile
+func (c *statementCache) release(cached *cachedStatement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached.refs--
+	if cached.evicted && cached.refs == 0 {
+		closeStatement(cached)
+	}
+}
+
+// evictLocked removes a statement from the cache, closing it unless it is running
+func (c *statementCache) evictLocked(element *list.Element) {
+	cached := c.order.Remove(element).(*cachedStatement)
+	delete(c.entries, cached.query)
+	cached.evicted = true
+	if cached.refs == 0 {
+		closeStatement(cached)
+	}
+}
+
+// resize changes the number of statements kept, evicting the least recently used ones
+func (c *statementCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size = size
+	for c.order.Len() > max(size, 0) {
+		c.evictLocked(c.order.Back())
+	}
+}
+
+// clear evicts every statement, keeping the size
+func (c *statementCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.order.Len() > 0 {
+		c.evictLocked(c.order.Back())
+	}
+}
+
+// len returns the number of statements kept
+func (c *statementCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func closeStatement(cached *cachedStatement) {
+	if err := cached.stmt.Close(); err != nil {
+		log.Debug().Err(err).Str("sql", cached.query).Msg("Failed to close prepared statement")
+	}
+}
+
+// SetStatementCacheSize sets how many prepared statements of db.prepare() are kept, 0 to
+// prepare them again on every use
+func (e *Engine) SetStatementCacheSize(size int) error {
+	if size < 0 {
+		return fmt.Errorf("statement cache size must not be negative, got %d", size)
+	}
+	e.statementCache().resize(size)
+	return nil
+}
+
+// PreparedStatements returns the number of prepared statements in the cache
+func (e *Engine) PreparedStatements() int {
+	return e.statementCache().len()
+}
+
+// statementCache returns the cache of prepared statements shared by the pool
+func (e *Engine) statementCache() *statementCache {
+	root := e.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.statements == nil {
+		root.statements = newStatementCache(DefaultStatementCacheSize)
+	}
+	return root.statements
+}
+
+// closeStatements closes the cached statements, before the database closes
+func (e *Engine) closeStatements() {
+	root := e.root()
+	root.mu.Lock()
+	cache := root.statements
+	root.mu.Unlock()
+	if cache != nil {
+		cache.resize(0)
+	}
+}
+
+// clearStatements drops the cached statements, when the connection they were prepared on is
+// replaced or closed
+func (e *Engine) clearStatements() {
+	e.statementCache().clear()
+}
+
+// dbPrepare implements db.prepare(sql). The statement is prepared right away, so errors in
+// the SQL are thrown by db.prepare().
+func (e *Engine) dbPrepare(query string) *goja.Object {
+	db := sqlDBOf(e.dbModule)
+	if db == nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.prepare(): the database module does not support prepared statements")))
+	}
+	cache := e.statementCache()
+	cached, err := cache.acquire(db, query)
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.prepare(): %w", err)))
+	}
+	cache.release(cached)
+
+	// run calls fn with the statement, in the open transaction if there is one. The database
+	// is looked up again, as db.configure() may have replaced it.
+	run := func(fn func(stmt *sql.Stmt) error) error {
+		db := sqlDBOf(e.dbModule)
+		if db == nil {
+			return fmt.Errorf("the database is closed")
+		}
+		cached, err := cache.acquire(db, query)
+		if err != nil {
+			return err
+		}
+		defer cache.release(cached)
+		stmt := cached.stmt
+		if e.tx != nil {
+			stmt = e.tx.Stmt(stmt)
+			defer func() { _ = stmt.Close() }()
+		}
+		return fn(stmt)
+	}
+	queryRows := func(limit int, args []interface{}) ([]map[string]interface{}, error) {
+		start := time.Now()
+		var result []map[string]interface{}
+		err := run(func(stmt *sql.Stmt) error {
+			rows, err := stmt.Query(flattenDBArgs(args)...)
+			if err != nil {
+				return err
+			}
+			result, err = scanRows(rows, limit)
+			return err
+		})
+		e.recordDatabaseOperation("query", query, args, start, err, func(op *DatabaseOperation) {
+			op.Result = len(result)
+		})
+		return result, err
+	}
+
+	obj := e.rt.NewObject()
+	set := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			log.Error().Err(err).Str("property", name).Msg("Failed to set statement property")
+		}
+	}
+	set("sql", query)
+	set("all", func(args ...interface{}) ([]map[string]interface{}, error) {
+		rows, err := queryRows(0, args)
+		if rows == nil && err == nil {
+			rows = []map[string]interface{}{}
+		}
+		return rows, err
+	})
+	set("get", func(args ...interface{}) (goja.Value, error) {
+		rows, err := queryRows(1, args)
+		if err != nil || len(rows) == 0 {
+			return goja.Undefined(), err
+		}
+		return e.rt.ToValue(rows[0]), nil
+	})
+	set("run", func(args ...interface{}) (map[string]interface{}, error) {
+		if e.warmingUp {
+			return nil, errWarmUpWrite
+		}
+		if err := e.checkDBWrite(); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		var result sql.Result
+		err := run(func(stmt *sql.Stmt) error {
+			var err error
+			result, err = stmt.Exec(flattenDBArgs(args)...)
+			return err
+		})
+		if err != nil {
+			e.recordDatabaseOperation("exec", query, args, start, err, nil)
+			return map[string]interface{}{"error": err.Error(), "success": false}, err
+		}
+		rowsAffected, _ := result.RowsAffected()
+		lastInsertId, _ := result.LastInsertId()
+		e.recordDatabaseOperation("exec", query, args, start, nil, func(op *DatabaseOperation) {
+			op.RowsAffected = rowsAffected
+			op.LastInsertId = lastInsertId
+		})
+		return map[string]interface{}{"success": true, "rowsAffected": rowsAffected, "lastInsertId": lastInsertId}, nil
+	})
+	return obj
+}
+
+// scanRows reads rows as column -> value maps like the database module does, up to limit
+// rows if it is positive, and closes them
+func scanRows(rows *sql.Rows, limit int) ([]map[string]interface{}, error) {
+	defer func() { _ = rows.Close() }()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		scan := make([]interface{}, len(cols))
+		for i := range values {
+			scan[i] = &values[i]
+		}
+		if err := rows.Scan(scan...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			row[col] = values[i]
+		}
+		result = append(result, row)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, rows.Err()
+}
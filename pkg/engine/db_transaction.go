@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+
+	"github.com/dop251/goja"
+	databasemod "github.com/go-go-golems/go-go-goja/modules/database"
+)
+
+// Database transactions
+//
+// db.transaction(fn) runs fn in a transaction of the application database. db.query() and
+// db.exec() called while fn runs take part in it; the transaction commits when fn returns and
+// rolls back when it throws, rethrowing the error. The value fn returns is returned:
+//
+//	const orderId = db.transaction(() => {
+//	    const { lastInsertId } = db.exec('INSERT INTO orders (customer) VALUES (?)', [customer]);
+//	    for (const item of items) {
+//	        db.exec('INSERT INTO order_items (order_id, sku) VALUES (?, ?)', [lastInsertId, item.sku]);
+//	    }
+//	    return lastInsertId;
+//	});
+//
+// A transaction inside another one is a savepoint: throwing rolls back the inner work only, if
+// the outer callback catches the error. fn must be synchronous, since the transaction would end
+// at the first await. Other runtimes writing while a transaction is open wait for it to finish.
+
+// sqlDBOf returns the connection pool of the database module, which keeps it unexported;
+// transactions need it to run their statements on one connection. nil if the module changed
+// and the pool cannot be found.
+func sqlDBOf(m *databasemod.DBModule) *sql.DB {
+	if m == nil {
+		return nil
+	}
+	field := reflect.ValueOf(m).Elem().FieldByName("db")
+	if !field.IsValid() || field.Type() != reflect.TypeOf((*sql.DB)(nil)) {
+		return nil
+	}
+	return *(**sql.DB)(unsafe.Pointer(field.UnsafeAddr()))
+}
+
+// dbTransaction implements db.transaction(fn)
+func (e *Engine) dbTransaction(call goja.FunctionCall) goja.Value {
+	fn, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(e.rt.NewTypeError("db.transaction() needs a function"))
+	}
+	if e.tx != nil {
+		return e.dbSavepoint(fn)
+	}
+
+	db := sqlDBOf(e.dbModule)
+	if db == nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.transaction(): the database module does not support transactions")))
+	}
+	start := time.Now()
+	tx, err := db.Begin()
+	e.recordDatabaseOperation("exec", "BEGIN", nil, start, err, nil)
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.transaction(): %w", err)))
+	}
+
+	e.tx = tx
+	mark := e.changes.tablesMark()
+	finished := false
+	defer func() {
+		// A panic passing through the callback must not leave the transaction open
+		if !finished {
+			e.tx = nil
+			_ = tx.Rollback()
+			e.changes.discardTables(mark)
+		}
+	}()
+	result, err := e.callTransaction(fn)
+	e.tx = nil
+	finished = true
+
+	start = time.Now()
+	if err != nil {
+		rollbackErr := tx.Rollback()
+		e.recordDatabaseOperation("exec", "ROLLBACK", nil, start, rollbackErr, nil)
+		e.changes.discardTables(mark)
+		e.throwTransactionError(err)
+	}
+	err = tx.Commit()
+	e.recordDatabaseOperation("exec", "COMMIT", nil, start, err, nil)
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.transaction(): %w", err)))
+	}
+	return result
+}
+
+// dbSavepoint runs a nested db.transaction() in a savepoint of the open transaction
+func (e *Engine) dbSavepoint(fn goja.Callable) goja.Value {
+	e.txDepth++
+	defer func() { e.txDepth-- }()
+	name := fmt.Sprintf("jesus_tx_%d", e.txDepth)
+
+	if _, err := e.txExec("SAVEPOINT " + name); err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.transaction(): %w", err)))
+	}
+	mark := e.changes.tablesMark()
+	result, err := e.callTransaction(fn)
+	if err != nil {
+		if _, rollbackErr := e.txExec("ROLLBACK TO " + name); rollbackErr == nil {
+			_, _ = e.txExec("RELEASE " + name)
+			e.changes.discardTables(mark)
+		}
+		e.throwTransactionError(err)
+	}
+	if _, err := e.txExec("RELEASE " + name); err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("db.transaction(): %w", err)))
+	}
+	return result
+}
+
+// callTransaction calls the callback of db.transaction(), refusing async ones
+func (e *Engine) callTransaction(fn goja.Callable) (goja.Value, error) {
+	result, err := fn(goja.Undefined())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := result.Export().(*goja.Promise); ok {
+		return nil, fmt.Errorf("db.transaction() callback must be synchronous, it returned a promise")
+	}
+	return result, nil
+}
+
+// throwTransactionError rethrows what ended a transaction callback: JavaScript exceptions and
+// interrupts as they are, other errors as TypeError
+func (e *Engine) throwTransactionError(err error) {
+	var exception *goja.Exception
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &exception) || errors.As(err, &interrupted) {
+		panic(err)
+	}
+	panic(e.rt.NewTypeError(err.Error()))
+}
+
+// txExec runs a statement of the open transaction, recording it on the request log
+func (e *Engine) txExec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := e.tx.Exec(query, flattenDBArgs(args)...)
+	e.recordDatabaseOperation("exec", query, args, start, err, nil)
+	return result, err
+}
+
+// dbQuery runs db.query() in the open transaction, or on the database module without one
+func (e *Engine) dbQuery(dbModule *databasemod.DBModule, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	if e.tx == nil {
+		return dbModule.Query(query, args...)
+	}
+	rows, err := e.tx.Query(query, flattenDBArgs(args)...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows(rows, 0)
+}
+
+// dbExec runs db.exec() in the open transaction, or on the database module without one
+func (e *Engine) dbExec(dbModule *databasemod.DBModule, query string, args ...interface{}) (map[string]interface{}, error) {
+	if e.tx == nil {
+		return dbModule.Exec(query, args...)
+	}
+	result, err := e.tx.Exec(query, flattenDBArgs(args)...)
+	if err != nil {
+		return map[string]interface{}{"error": err.Error(), "success": false}, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	lastInsertId, _ := result.LastInsertId()
+	return map[string]interface{}{
+		"success":      true,
+		"rowsAffected": rowsAffected,
+		"lastInsertId": lastInsertId,
+	}, nil
+}
+
+// flattenDBArgs spreads array arguments like the database module does, so db.query(sql, [a, b])
+// and db.query(sql, a, b) are the same
+func flattenDBArgs(args []interface{}) []interface{} {
+	var flat []interface{}
+	for _, arg := range args {
+		if slice, ok := arg.([]interface{}); ok {
+			flat = append(flat, slice...)
+		} else {
+			flat = append(flat, arg)
+		}
+	}
+	return flat
+}
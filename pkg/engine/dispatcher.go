@@ -3,25 +3,58 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/go-go-golems/jesus/pkg/repository"
 	"github.com/rs/zerolog/log"
 )
 
-// StartDispatcher starts the job processing dispatcher
+// StartDispatcher starts the job processing dispatcher, one per runtime of the pool
 func (e *Engine) StartDispatcher() {
-	log.Info().Msg("Starting JavaScript dispatcher")
+	log.Info().Int("runtimes", e.RuntimePoolSize()).Msg("Starting JavaScript dispatcher")
+	e.dispatching = true
 	go e.dispatcher()
+	for _, replica := range e.replicas {
+		replica.dispatching = true
+		go replica.dispatcher()
+	}
 }
 
-// dispatcher processes jobs from the job queue
+// dispatcher processes jobs from the job queue of this runtime and, on replicas, the shared
+// pool queue
 func (e *Engine) dispatcher() {
-	for job := range e.jobs {
-		e.processJob(job)
+	// The primary runtime leaves replicated routes to the replicas, whose globalState throws,
+	// so they behave the same whichever runtime serves them
+	var poolJobs chan EvalJob
+	if e.primary != nil {
+		poolJobs = e.poolJobs
+	}
+	for {
+		// Own jobs first, so replicated scripts run before requests for the routes they register
+		select {
+		case job, ok := <-e.jobs:
+			if !ok {
+				return
+			}
+			e.processJob(job)
+			continue
+		default:
+		}
+
+		select {
+		case job, ok := <-e.jobs:
+			if !ok {
+				return
+			}
+			e.processJob(job)
+		case job := <-poolJobs:
+			e.processPoolJob(job)
+		}
 	}
 }
 
@@ -35,10 +68,31 @@ func (e *Engine) processJob(job EvalJob) {
 			}
 		}
 	}()
+	defer e.closeStreams()
+
+	// Routes registered by replicated code can be served by any runtime of the pool
+	e.replicating = job.Replicate
+	e.currentScript = job.script
+	defer func() {
+		e.replicating = false
+		e.currentScript = ""
+	}()
+
+	e.warmingUp = job.warmUp
+	defer func() {
+		e.warmingUp = false
+	}()
 
 	// Start request logging if this is an HTTP request
 	var requestLog *RequestLog
-	if job.R != nil {
+	if job.R != nil && !job.warmUp {
+		// Capture status and body for the request log
+		if job.W != nil {
+			if _, ok := job.W.(*ResponseRecorder); !ok {
+				job.W = &ResponseRecorder{ResponseWriter: job.W, status: 200}
+			}
+		}
+
 		requestLog = e.reqLogger.StartRequest(job.R)
 		e.currentReqID = requestLog.ID
 		defer func() {
@@ -46,16 +100,85 @@ func (e *Engine) processJob(job EvalJob) {
 		}()
 	}
 
+	e.currentSource = job.Source
+	if job.Handler != nil {
+		e.currentSource = job.Handler.Doc.Method + " " + job.Handler.Doc.Path
+	}
+	defer func() {
+		e.currentSource = ""
+		e.currentLocale = ""
+	}()
+
+	// Files written with tmp go to a directory of the job, removed once the job finishes
+	scope := e.newTempScope()
+	e.tempScope = scope
+	pendingScope := false
+	defer func() {
+		e.tempScope = nil
+		if !pendingScope {
+			scope.remove()
+		}
+	}()
+
+	if job.reset != nil {
+		// Swap in a fresh runtime between jobs, see Reset
+		e.resetRuntime(job.reset)
+		if job.Done != nil {
+			job.Done <- nil
+		}
+		return
+	}
+
+	if job.async != nil {
+		// Settle the promise of a finished async operation or deliver a WebSocket event
+		e.currentPolicy = e.jobPolicy(job)
+		_ = e.runAsync(job.async)
+		e.currentPolicy = ExecutionPolicy{}
+		e.checkStateChanged()
+		return
+	}
+
 	var err error
+	start := time.Now()
 
-	if job.Handler != nil {
+	if job.timer != nil {
+		// Run the callback of a fired setTimeout/setInterval timer
+		e.currentPolicy = e.jobPolicy(job)
+		err = e.runTimer(job.timer)
+		e.currentPolicy = ExecutionPolicy{}
+	} else if job.Handler != nil {
 		// Execute pre-registered handler
-		err = e.executeHandler(job)
+		var pending *pendingHandler
+		e.currentPolicy = job.Handler.policy
+		pending, err = e.executeHandler(job)
+		e.currentPolicy = ExecutionPolicy{}
+		if pending != nil {
+			// The handler keeps its temp directory until it finishes
+			pendingScope = true
+			finish := func(err error) {
+				scope.remove()
+				e.finishJob(job, requestLog, start, err)
+			}
+			if pending.promise != nil {
+				// The handler returned a promise; the job finishes once it settles
+				e.awaitHandler(job, pending.resObj, pending.promise, finish)
+			} else {
+				// The handler left its response stream open; the job finishes once it closes
+				e.awaitStream(job, pending.resObj, finish)
+			}
+			return
+		}
 	} else {
 		// Execute code directly
 		err = e.executeDirectCode(job)
 	}
 
+	scope.remove()
+	e.finishJob(job, requestLog, start, err)
+}
+
+// finishJob completes the request log of a job, emits its events and signals completion
+func (e *Engine) finishJob(job EvalJob, requestLog *RequestLog, start time.Time, err error) {
 	// Finish request logging
 	if requestLog != nil {
 		status := 200
@@ -69,22 +192,48 @@ func (e *Engine) processJob(job EvalJob) {
 		e.reqLogger.FinishRequest(requestLog.ID, status, response, err)
 	}
 
+	if !job.warmUp {
+		e.emitJobEvents(job, err, time.Since(start))
+	}
+
 	if job.Done != nil {
 		job.Done <- err
 	}
 }
 
-// executeHandler executes a pre-registered JavaScript handler function
-func (e *Engine) executeHandler(job EvalJob) error {
+// pendingHandler is a handler that returned a promise which has not settled yet, or that
+// left its response stream open
+type pendingHandler struct {
+	promise *goja.Promise // nil for an open response stream
+	resObj  *ExpressResponse
+}
+
+// executeHandler executes a pre-registered JavaScript handler function. When the handler
+// returns a pending promise or leaves its response stream open, the response is left open and
+// returned as pendingHandler.
+func (e *Engine) executeHandler(job EvalJob) (*pendingHandler, error) {
 	if job.Handler == nil || job.Handler.Fn == nil {
-		return fmt.Errorf("no handler function provided")
+		return nil, fmt.Errorf("no handler function provided")
 	}
 
 	log.Debug().Str("path", job.R.URL.Path).Str("method", job.R.Method).Msg("Creating Express.js request/response objects")
 
 	// Create Express.js compatible request and response objects
 	reqObj := e.createExpressRequestObject(job.R)
-	resObj := e.createExpressResponseObject(job.W)
+	resObj := e.createExpressResponseObject(job.W, job.R)
+	resObj.uploads = reqObj.uploads
+	resObj.schema = job.Handler.response
+
+	var uploadErr *UploadError
+	if errors.As(reqObj.uploadErr, &uploadErr) {
+		// The multipart body broke the upload limits; the handler never sees it
+		log.Warn().Str("path", job.R.URL.Path).Err(uploadErr).Msg("Rejected file upload")
+		http.Error(job.W, uploadErr.Message, uploadErr.Status)
+		resObj.close()
+		return nil, nil
+	} else if reqObj.uploadErr != nil {
+		return nil, e.completeHandler(job, resObj, 0, reqObj.uploadErr)
+	}
 
 	log.Debug().
 		Interface("reqObj", map[string]interface{}{
@@ -104,14 +253,19 @@ func (e *Engine) executeHandler(job EvalJob) error {
 	// Add path parameters if available
 	if job.Handler.Options != nil {
 		if pathPattern, ok := job.Handler.Options["pathPattern"].(string); ok {
-			reqObj.Params = parsePathParams(pathPattern, job.R.URL.Path)
+			reqObj.Params = routeParams(job.Handler, job.R.URL.Path)
 			log.Debug().Str("pathPattern", pathPattern).Interface("params", reqObj.Params).Msg("Path parameters parsed")
+
+			if e.currentReqID != "" {
+				e.reqLogger.SetHandler(e.currentReqID, job.R.Method+" "+pathPattern)
+			}
 		}
 	}
 
 	// Convert to Goja values and log their types
 	reqValue := e.rt.ToValue(reqObj)
 	resValue := e.rt.ToValue(resObj)
+	resObj.req = reqValue
 
 	// Use JavaScript JSON.stringify to get proper string representation
 	reqJSON := e.stringifyJSValue(reqValue)
@@ -124,13 +278,84 @@ func (e *Engine) executeHandler(job EvalJob) error {
 
 	// Call the JavaScript handler function with Express.js style (req, res)
 	log.Debug().Msg("Calling JavaScript handler function")
-	v, err := job.Handler.Fn(goja.Undefined(), reqValue, resValue)
-	log.Debug().Interface("v", v.Export()).Msg("Handler execution result")
+	timeout := e.jobTimeout(job)
+	stopTimeout := e.interruptAfter(timeout)
+	v, err := e.callHandlerChain(job.Handler, reqValue, resValue)
+	stopTimeout()
+	err = e.limitError(err)
+	if v != nil {
+		log.Debug().Interface("v", v.Export()).Msg("Handler execution result")
+	}
+	if err == nil {
+		if promise, ok := v.Export().(*goja.Promise); ok && promise.State() == goja.PromiseStateRejected {
+			// An async handler that threw before its first await
+			err = rejectionError(promise.Result())
+		} else if promise := pendingPromise(v); promise != nil {
+			return &pendingHandler{promise: promise, resObj: resObj}, nil
+		} else if resObj.IsStreaming() {
+			return &pendingHandler{resObj: resObj}, nil
+		}
+	}
+	return nil, e.completeHandler(job, resObj, timeout, err)
+}
+
+// completeHandler answers the request of a finished handler: a 400 for a rejected
+// req.parse(), a 500 with the errors for a response refused by strict response validation,
+// a 504 for a timeout, the error middleware's answer or a 500 for other failures and an
+// empty 200 if the handler sent nothing. The response is closed afterwards, which runs its
+// onClose callbacks.
+func (e *Engine) completeHandler(job EvalJob, resObj *ExpressResponse, timeout time.Duration, err error) error {
+	// The response writer must not be used once the request finishes
+	defer resObj.close()
+
+	var parseErr *RequestParseError
+	if errors.As(err, &parseErr) {
+		// req.parse() rejected the request and the handler did not catch it
+		log.Warn().Str("path", job.R.URL.Path).Str("errors", formatValidationErrors(parseErr.Errors)).Msg("Request did not match req.parse() schema")
+		if e.currentReqID != "" {
+			e.reqLogger.AddLog(e.currentReqID, "warn", parseErr.Error(), parseErr.Errors)
+		}
+		if !resObj.sent {
+			if err := resObj.Status(http.StatusBadRequest).Json(map[string]interface{}{
+				"error":  "Invalid request",
+				"errors": parseErr.Errors,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var schemaErr *ResponseSchemaError
+	if errors.As(err, &schemaErr) {
+		// Strict response validation refused what the handler sent
+		log.Error().Str("path", job.R.URL.Path).Str("errors", formatValidationErrors(schemaErr.Errors)).Msg("Response did not match the route's schema")
+		if e.currentReqID != "" {
+			e.reqLogger.AddLog(e.currentReqID, "error", schemaErr.Error(), schemaErr.Errors)
+		}
+		if !resObj.sent {
+			if err := resObj.Status(http.StatusInternalServerError).Json(map[string]interface{}{
+				"error":  "Response does not match the route's schema",
+				"errors": schemaErr.Errors,
+			}); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	if IsExecutionTimeout(err) {
+		log.Error().Err(err).Str("path", job.R.URL.Path).Dur("timeout", timeout).Msg("Handler timed out")
+		if !resObj.sent {
+			http.Error(job.W, fmt.Sprintf("Gateway Timeout: handler did not finish within %s", timeout), http.StatusGatewayTimeout)
+		}
+		return err
+	}
 	if err != nil {
 		log.Error().Err(err).Str("path", job.R.URL.Path).Msg("Handler execution error")
 
 		// Send error response if not already sent
-		if !resObj.sent {
+		if !resObj.sent && e.runErrorHandlers(job, resObj, err) {
+			log.Debug().Msg("Error middleware answered the request")
+		} else if !resObj.sent {
 			log.Debug().Msg("Sending error response via http.Error")
 			http.Error(job.W, "Internal Server Error", http.StatusInternalServerError)
 		} else {
@@ -154,18 +379,65 @@ func (e *Engine) executeHandler(job EvalJob) error {
 
 // executeDirectCode executes JavaScript code directly and captures results
 func (e *Engine) executeDirectCode(job EvalJob) error {
-	result, err := e.executeCodeWithResult(job.Code)
+	e.currentPolicy = e.jobPolicy(job)
+	e.currentSession = job.SessionID
+	leaveSession := func() {}
+	var templateErr error
+	if job.Template != "" {
+		leaveSession, templateErr = e.enterJobTemplate(job)
+	} else if job.Stateful && job.SessionID != "" {
+		leaveSession = e.enterSession(job.SessionID)
+	}
+
+	var result *EvalResult
+	var err error
+	var attachments []repository.ExecutionAttachment
+	if templateErr != nil {
+		result, err = &EvalResult{ConsoleLog: []string{}, Error: templateErr}, templateErr
+	} else {
+		if !job.Ephemeral && !job.NoRecord {
+			e.attachments = &attachments
+		}
+		var synced []string
+		syncer := e.root().syncer.Load()
+		if syncer != nil && syncer.publishes(job) {
+			e.synced = &synced
+		}
+		trackChanges := job.Result != nil || job.SessionID != "" && !job.Ephemeral && !job.NoRecord
+		if trackChanges {
+			e.changes = e.trackChanges()
+			defer func() { e.changes = nil }()
+		}
+		stopTimeout := e.interruptAfter(e.jobTimeout(job))
+		result, err = e.executeCodeWithResult(job.Code)
+		stopTimeout()
+		if trackChanges {
+			// Compared before leaving the session, whose runtime holds the globalState the code changed
+			result.Changes = e.finishChanges(e.changes)
+		}
+		leaveSession()
+		e.attachments = nil
+		e.synced = nil
+		if len(synced) > 0 && err == nil {
+			syncer.publishRoutes(synced)
+		}
+		for _, attachment := range attachments {
+			result.Attachments = append(result.Attachments, attachment.Name)
+		}
+	}
+	e.currentPolicy = ExecutionPolicy{}
+	e.currentSession = ""
 	if err != nil {
 		log.Error().Err(err).Str("code", job.Code).Msg("Code execution error")
 	}
 
 	// Store execution result if we have session tracking
-	if job.SessionID != "" {
+	if job.SessionID != "" && !job.Ephemeral && !job.NoRecord {
 		var resultStr, consoleLogStr, errorStr *string
 
 		if result.Value != nil {
 			if data, marshalErr := json.Marshal(result.Value); marshalErr == nil {
-				s := string(data)
+				s := e.redactSecrets(string(data))
 				resultStr = &s
 			}
 		}
@@ -176,23 +448,45 @@ func (e *Engine) executeDirectCode(job EvalJob) error {
 		}
 
 		if result.Error != nil {
-			s := result.Error.Error()
+			s := e.redactSecrets(result.Error.Error())
 			errorStr = &s
 		}
 
 		req := repository.CreateExecutionRequest{
-			SessionID:  job.SessionID,
-			Code:       job.Code,
-			Result:     resultStr,
-			ConsoleLog: consoleLogStr,
-			Error:      errorStr,
-			Source:     job.Source,
+			SessionID:   job.SessionID,
+			Code:        job.Code,
+			Result:      resultStr,
+			ConsoleLog:  consoleLogStr,
+			Error:       errorStr,
+			Source:      job.Source,
+			Changes:     result.Changes,
+			Attachments: attachments,
+		}
+		if job.Actor != "" {
+			actor := job.Actor
+			req.Actor = &actor
+		}
+		if e.currentReqID != "" {
+			requestID := e.currentReqID
+			req.RequestID = &requestID
+		}
+		if job.SavedAs != "" {
+			savedAs := job.SavedAs
+			req.SavedAs = &savedAs
 		}
 
-		if _, storeErr := e.repos.Executions().CreateExecution(context.Background(), req); storeErr != nil {
+		e.pageExecutionResult(&req)
+		e.limitExecutionOutput(&req)
+		e.storeAttachments(&req)
+
+		if execution, storeErr := e.repos.Executions().CreateExecution(context.Background(), req); storeErr != nil {
 			log.Error().Err(storeErr).Msg("Failed to store script execution")
 		} else {
 			log.Debug().Str("sessionID", job.SessionID).Msg("Script execution stored via repository")
+			result.ExecutionID = execution.ID
+			if e.currentReqID != "" {
+				e.reqLogger.LinkExecution(e.currentReqID, job.SessionID, execution.ID)
+			}
 		}
 	}
 
@@ -1,39 +1,144 @@
 package engine
 
 import (
+	"database/sql"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/eventloop"
 	"github.com/dop251/goja_nodejs/require"
 	gogogojamodules "github.com/go-go-golems/go-go-goja/modules"
 	databasemod "github.com/go-go-golems/go-go-goja/modules/database"
+	_ "github.com/go-go-golems/jesus/pkg/modules/dates" // Registers require('dates')
+	_ "github.com/go-go-golems/jesus/pkg/modules/faker" // Registers require('faker')
+	_ "github.com/go-go-golems/jesus/pkg/modules/text"  // Registers require('text')
 	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/storage"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
 )
 
 // Engine wraps the JavaScript runtime and data repositories
 type Engine struct {
-	rt             *goja.Runtime
-	loop           *eventloop.EventLoop         // Event loop for async operations
-	repos          repository.RepositoryManager // Repository manager for data access
-	jobs           chan EvalJob
-	handlers       map[string]map[string]*HandlerInfo // [path][method] -> handler info
-	files          map[string]goja.Callable           // [path] -> file handler
-	mu             sync.RWMutex
-	reqLogger      *RequestLogger // Request logger for admin interface
-	currentReqID   string         // Track current request ID for logging
-	moduleRegistry *gogogojamodules.Registry
+	rt               *goja.Runtime
+	loop             *eventloop.EventLoop         // Event loop for async operations
+	repos            repository.RepositoryManager // Repository manager for data access
+	jobs             chan EvalJob
+	handlers         map[string]map[string]*HandlerInfo // [path][method] -> handler info
+	routeOrder       []*routePattern                    // Patterns of the handler paths, most specific first
+	files            map[string]goja.Callable           // [path] -> file handler
+	fileScripts      map[string]string                  // [path] -> script file that registered the file handler
+	errorHandlers    []errorHandler                     // Error middleware, in registration order
+	mu               sync.RWMutex
+	reqLogger        *RequestLogger             // Request logger for admin interface
+	currentReqID     string                     // Track current request ID for logging
+	currentPolicy    ExecutionPolicy            // Policy of the direct code execution or handler being run
+	sourcePolicies   map[string]ExecutionPolicy // Policies of the execution sources, see SetSourcePolicies
+	currentSession   string                     // Session of the direct code execution being run
+	currentSource    string                     // Route or source of the job being run, recorded by timers
+	currentScript    string                     // Script file run by the current job, recorded on the routes it registers
+	currentLocale    string                     // Locale i18n.middleware() negotiated for the request being served
+	warmingUp        bool                       // The current job is a warm-up request, see WarmUp
+	moduleRegistry   *gogogojamodules.Registry
+	env              *Environment      // Execution environment (dev, prod, ...)
+	bindings         []string          // Globals installed during setup, see recordBindings
+	httpClient       *httpClientPool   // Shared client of fetch() and HTTP.*
+	openStreams      []*httpStream     // Response streams opened by the current job
+	outputLimits     OutputLimits      // Size limits of the output stored per execution
+	runtimeLimits    RuntimeLimits     // Call stack, string and array limits, see SetRuntimeLimits
+	uploadLimits     UploadLimits      // Size and count limits of multipart uploads, see SetUploadLimits
+	tempLimits       TempLimits        // Location and quota of the temp directories of jobs, see SetTempLimits
+	metrics          *metricsRegistry  // Server and script metrics, nil on replicas which use the primary's
+	executionTimeout time.Duration     // Default time limit of handlers and executions, see SetExecutionTimeout
+	maintenance      MaintenanceStatus // Whether JavaScript routes are paused, see SetMaintenance
+	readOnly         ReadOnlyStatus    // Whether code execution and route changes are refused, see SetReadOnly
+	apiKeys          []string          // Keys accepted by routes with auth: 'apiKey', see SetAPIKeys
+	executeToken     string            // Bearer token /v1/execute and the REPL endpoint require, see SetExecuteToken
+	warmUp           bool              // Call the GET routes of scripts once they are loaded, see SetWarmUp
+	trustedProxies   []*net.IPNet      // Proxies whose forwarding headers name the client, see SetTrustedProxies
+	cookieSecrets    [][]byte          // Secrets of signed cookies, the first one signing, see SetCookieSecrets
+	secrets          map[string]string // Values of secrets.get() by name, see SetSecrets
+	secretRedactor   *strings.Replacer // Replaces the values of secrets in execution output, nil without secrets
+	globalCORS       *CORSPolicy       // CORS policy of routes without their own, see app.use(cors())
+	adminCORS        *CORSPolicy       // CORS policy of the admin server, see SetAdminCORS
+	globalRateLimit  *RateLimiter      // Rate limit of routes without their own, see app.use(rateLimit())
+	executeRateLimit *RateLimiter      // Rate limit of /v1/execute, see SetExecuteRateLimit
+	mirrorResults    []MirrorResult    // Outcomes of mirrored requests, see MirrorRequest
+	compression      bool              // Gzip route responses by default, see SetResponseCompression
+	htmlPolicy       string            // Policy res.send() sanitizes HTML with, see SetSanitizeHTML
+	schemaMode       string            // What responses not matching their route's schema do, see SetResponseValidation
+	locales          *localeCatalogs   // Message catalogs of t(), see SetLocalesDir
+	bootstrapFile    string            // Bootstrap file run by Init, see RollbackBootstrap
+	filesDir         string            // Directory res.sendFile() and app.static() serve from, see SetFilesDir
+	scriptsDir       string            // Directory loaded by LoadScripts, see ScriptsDir
+	statics          []*staticMount    // Directories mounted with app.static(), longest prefix first
+	storage          storage.Storage   // Storage of attachments and full outputs, nil to keep them local, see SetStorage
+	statements       *statementCache   // Prepared statements of db.prepare(), see SetStatementCacheSize
+
+	attachments *[]repository.ExecutionAttachment // Files of output.attach(), nil outside code executions
+	tempScope   *tempScope                        // Temp directory of tmp for the running job, nil between jobs
+	synced      *[]string                         // Registrations of the running code to publish, nil unless it is synced, see syncRegistration
+	changes     *changeTracker                    // Changes of the running code execution, nil outside code executions
+	tx          *sql.Tx                           // Transaction of db.transaction() being run, nil outside one
+	txDepth     int                               // Savepoints nested in tx
+	syncer      atomic.Pointer[stateSync]         // Sync with other engines, nil unless EnableStateSync was called
+	eventLog    atomic.Pointer[eventLog]          // Durable event log, nil unless EnableEventLog was called
+	sweeper     atomic.Pointer[storageSweeper]    // Sweep of orphaned attachments, nil unless SetStorage was called
+
+	requireRegistry *require.Registry             // Enables require() in new runtimes
+	dbModule        *databasemod.DBModule         // Application database behind the db binding
+	sessions        map[string]*sessionRuntime    // Dedicated runtimes of stateful sessions
+	activeSession   string                        // Session whose runtime is the current runtime, "" for the shared runtime
+	templates       map[string]*ExecutionTemplate // Templates executions can select, see SetExecutionTemplates
+	rateLimiters    map[string]*RateLimiter       // Limiters of rateLimit() by name, shared by the runtimes of the pool
+
+	replicas    []*Engine    // Pool runtimes next to this one, see SetRuntimePoolSize
+	primary     *Engine      // Runtime owning the pool, nil for the primary runtime itself
+	poolJobs    chan EvalJob // Requests any runtime of the pool can serve
+	replicating bool         // Whether the running code is executed in every pool runtime
+	dispatching bool         // Whether StartDispatcher was called
+
+	featureFlags map[string]repository.FeatureFlag // Cached flags of flags.isEnabled(), see featureFlag
+	flagsLoaded  time.Time                         // When featureFlags were read from the system database
+
+	hooks           []eventHook  // Go callbacks registered with OnEvent
+	hooksMu         sync.RWMutex // Guards hooks and nextHookID
+	nextHookID      int
+	lastGlobalState string // globalState JSON last reported by EventStateChanged
+
+	timers      map[int64]*scriptTimer // Active timers of this runtime, see setTimer
+	nextTimerID int64                  // Last timer ID handed out, used atomically on the primary runtime
+
+	replicatedTimers     []int64       // IDs of the timers replicated code set on the primary runtime, in order
+	mirroredTimers       map[int64]int // Handles replicated code got on a pool runtime -> index in replicatedTimers
+	replicatedTimerCount int           // Timers replicated code set on this pool runtime
 }
 
+// requestLogCapacity is the number of requests kept by the request logger
+const requestLogCapacity = 100
+
 // HandlerInfo contains handler function and metadata
 type HandlerInfo struct {
 	Fn          goja.Callable          // JavaScript function
+	Chain       []goja.Callable        // Middleware functions called before Fn, in order
 	ContentType string                 // MIME type override
 	Options     map[string]interface{} // Handler options (middleware, auth, etc.)
+	Doc         RouteDoc               // Documentation captured from the options
+	Timeout     time.Duration          // Time limit from the timeout option, 0 for the engine default
+	Middleware  *RouteMiddleware       // Auth, cache, rate limit and body limit from the options
+	route       *routePattern          // Compiled path the handler was registered for
+	replicated  bool                   // Registered in every runtime of the pool with replicate: true, served by the replicas
+	script      string                 // Script file that registered the handler, "" for other code
+	session     string                 // Session ID of the execution that registered the handler, "" for scripts; never a session runtime, which cannot register routes
+	origin      string                 // Source of that execution, one of the repository.Source* constants
+	policy      ExecutionPolicy        // Deny rules of the code that registered the handler, see SetSourcePolicies
+	response    *responseSchema        // Schema of the JSON responses from the responseSchema option, nil for none
 }
 
 // EvalJob represents a JavaScript evaluation job
@@ -45,7 +150,21 @@ type EvalJob struct {
 	Done      chan error          // completion signal
 	Result    chan *EvalResult    // result channel for capturing execution results
 	SessionID string              // session identifier for tracking
-	Source    string              // source of execution ('api', 'mcp', 'file')
+	Stateful  bool                // run in the dedicated runtime of SessionID, keeping variables between executions
+	Template  string              // execution template whose runtime runs the code, see SetExecutionTemplates
+	Source    string              // source of execution, one of the repository.Source* constants
+	Actor     string              // who submitted the job: API client, MCP client name, admin user
+	SavedAs   string              // file the code was saved to before it ran, recorded with the execution
+	NoRecord  bool                // do not store the execution record, for code that must not be kept
+	Policy    ExecutionPolicy     // restrictions for direct code execution
+	Replicate bool                // also run the code in every pool runtime (startup scripts defining routes)
+	Timeout   time.Duration       // interrupt the job after this long (0 = route option or engine default)
+	Ephemeral bool                // REPL code: not stored as execution, interrupted after ephemeralTimeout at most
+	script    string              // script file the code comes from, see UnloadScriptFile
+	timer     *scriptTimer        // fired timer whose callback the job runs
+	async     *asyncTask          // finished async operation whose promise the job settles
+	reset     *resetRequest       // replace the runtime with a fresh one, see Reset
+	warmUp    bool                // warm-up request of a route: sandboxed and not logged, see WarmUp
 }
 
 // EvalResult contains the result of JavaScript execution
@@ -53,6 +172,10 @@ type EvalResult struct {
 	Value      interface{} `json:"value"`           // The actual result value
 	ConsoleLog []string    `json:"consoleLog"`      // Captured console output
 	Error      error       `json:"error,omitempty"` // Execution error if any
+
+	Attachments []string                     `json:"attachments,omitempty"` // Names of the files attached with output.attach()
+	ExecutionID int                          `json:"executionId,omitempty"` // Stored execution record, 0 if it was not stored
+	Changes     *repository.ExecutionChanges `json:"changes,omitempty"`     // Routes, globalState keys and tables the code changed, nil if none
 }
 
 // NewEngine creates a new JavaScript engine with separate application and system databases
@@ -63,13 +186,13 @@ func NewEngine(appDBPath, systemDBPath string) *Engine {
 	loop := eventloop.NewEventLoop()
 	log.Debug().Msg("Event loop created")
 
-	rt := goja.New()
-	log.Debug().Msg("Goja runtime created")
-
 	moduleRegistry := gogogojamodules.DefaultRegistry
 	gojaRegistry := require.NewRegistry()
 	moduleRegistry.Enable(gojaRegistry)
-	gojaRegistry.Enable(rt)
+
+	// The field name mapper converts Go method names to JavaScript-style names
+	rt := newRuntime(gojaRegistry)
+	log.Debug().Msg("Goja runtime created")
 
 	dbModule, ok := moduleRegistry.GetModule("database").(*databasemod.DBModule)
 	if !ok || dbModule == nil {
@@ -79,9 +202,6 @@ func NewEngine(appDBPath, systemDBPath string) *Engine {
 		log.Fatal().Err(err).Msg("Failed to configure database module")
 	}
 
-	// Set up field name mapper to convert Go method names to JavaScript-style names
-	rt.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
-
 	// Create repository manager for system operations (system database)
 	repos, err := repository.NewSQLiteRepositoryManager(systemDBPath)
 	if err != nil {
@@ -96,8 +216,24 @@ func NewEngine(appDBPath, systemDBPath string) *Engine {
 		jobs:           make(chan EvalJob, 1024),
 		handlers:       make(map[string]map[string]*HandlerInfo),
 		files:          make(map[string]goja.Callable),
-		reqLogger:      NewRequestLogger(100), // Keep last 100 requests
+		fileScripts:    make(map[string]string),
+		reqLogger:      NewRequestLogger(requestLogCapacity),
 		moduleRegistry: moduleRegistry,
+		outputLimits:   DefaultOutputLimits(),
+		runtimeLimits:  DefaultRuntimeLimits(),
+		uploadLimits:   DefaultUploadLimits(),
+		tempLimits:     DefaultTempLimits(),
+		metrics:        newMetricsRegistry(),
+
+		requireRegistry: gojaRegistry,
+		dbModule:        dbModule,
+		sessions:        make(map[string]*sessionRuntime),
+		lastGlobalState: "{}",
+	}
+	e.applyRuntimeLimits(rt)
+	e.declareServerMetrics()
+	if err := e.SetHTTPClientConfig(DefaultHTTPClientConfig()); err != nil {
+		log.Fatal().Err(err).Msg("Failed to create HTTP client")
 	}
 	log.Debug().Msg("Engine struct initialized")
 
@@ -108,11 +244,11 @@ func NewEngine(appDBPath, systemDBPath string) *Engine {
 	// Setup JavaScript bindings
 	log.Debug().Msg("Setting up JavaScript bindings")
 	e.setupBindings()
+	e.SetEnvironment(nil)
 	log.Debug().Msg("JavaScript bindings setup complete")
 
-	if _, err := rt.RunString(`const db = require('database');`); err != nil {
-		log.Fatal().Err(err).Msg("Failed to bind db to global scope")
-	}
+	e.setupDatabaseBindings(e.dbModule)
+	e.recordBindings()
 
 	// Log runtime state after bindings setup
 	e.logJavaScriptRuntimeState("after-bindings-setup")
@@ -128,6 +264,7 @@ func (e *Engine) ExecuteScript(code string) (*EvalResult, error) {
 
 // Init loads and executes a bootstrap JavaScript file
 func (e *Engine) Init(filename string) error {
+	e.bootstrapFile = filename
 	log.Debug().Str("file", filename).Msg("Initializing JavaScript engine with bootstrap file")
 
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
@@ -156,7 +293,10 @@ console.log("Bootstrap complete - server ready");`
 
 		if err := os.WriteFile(filename, []byte(bootstrap), 0644); err == nil {
 			log.Debug().Str("file", filename).Msg("Created default bootstrap file")
-			return e.executeCode(bootstrap)
+			backup := e.backupBootstrap(filename, bootstrap)
+			err := e.runReplicated(bootstrap)
+			e.recordBootstrapResult(backup, err)
+			return err
 		}
 		log.Error().Err(err).Str("file", filename).Msg("Failed to create bootstrap file")
 		return err
@@ -170,9 +310,14 @@ console.log("Bootstrap complete - server ready");`
 	}
 
 	log.Debug().Str("file", filename).Int("size", len(data)).Msg("Bootstrap file loaded, executing JavaScript")
-	err = e.executeCode(string(data))
+	backup := e.backupBootstrap(filename, string(data))
+	err = e.runReplicated(string(data))
+	e.recordBootstrapResult(backup, err)
 	if err != nil {
 		log.Error().Err(err).Str("file", filename).Msg("Failed to execute bootstrap file")
+		if backup != nil {
+			log.Warn().Str("file", filename).Msg("Restore the last working bootstrap file with: jesus rollback-bootstrap")
+		}
 	} else {
 		log.Info().Str("file", filename).Msg("Bootstrap file executed successfully")
 	}
@@ -189,7 +334,7 @@ func (e *Engine) GetHandler(method, path string) (*HandlerInfo, bool) {
 	// First try exact match
 	if methods, exists := e.handlers[path]; exists {
 		log.Debug().Str("path", path).Msg("Found exact path match")
-		if handler, exists := methods[method]; exists {
+		if handler, exists := handlerForMethod(methods, method); exists {
 			log.Debug().Str("method", method).Str("path", path).Msg("Found exact handler match")
 			return handler, true
 		} else {
@@ -197,12 +342,12 @@ func (e *Engine) GetHandler(method, path string) (*HandlerInfo, bool) {
 		}
 	}
 
-	// Try pattern matching for path parameters
+	// Try the patterns, most specific first
 	log.Debug().Str("method", method).Str("path", path).Msg("Trying pattern matching for path parameters")
-	for pattern, methods := range e.handlers {
-		if handler, exists := methods[method]; exists {
-			if pathMatches(pattern, path) {
-				log.Debug().Str("method", method).Str("path", path).Str("pattern", pattern).Msg("Found pattern match")
+	for _, pattern := range e.routeOrder {
+		if handler, exists := handlerForMethod(e.handlers[pattern.path], method); exists {
+			if _, ok := pattern.match(path); ok {
+				log.Debug().Str("method", method).Str("path", path).Str("pattern", pattern.path).Msg("Found pattern match")
 				return handler, true
 			}
 		}
@@ -230,8 +375,16 @@ func (e *Engine) GetFileHandler(path string) (goja.Callable, bool) {
 	return handler, exists
 }
 
-// SubmitJob submits a job to the dispatcher
+// SubmitJob submits a job to the dispatcher. Requests for routes registered in every pool
+// runtime go to whichever runtime is free.
 func (e *Engine) SubmitJob(job EvalJob) {
+	switch {
+	case job.Handler != nil && job.Handler.replicated && e.poolJobs != nil:
+		e.poolJobs <- job
+		return
+	case job.Handler == nil && job.Replicate:
+		e.replicateJob(job)
+	}
 	e.jobs <- job
 }
 
@@ -287,6 +440,7 @@ func (e *Engine) executeCodeWithResult(code string) (*EvalResult, error) {
 
 	value, err := e.rt.RunString(code)
 	if err != nil {
+		err = e.limitError(err)
 		log.Error().Err(err).Str("code", code).Msg("JavaScript execution error with result capture")
 		result.Error = err
 		return result, err
@@ -294,7 +448,19 @@ func (e *Engine) executeCodeWithResult(code string) (*EvalResult, error) {
 
 	// Export the result to a Go-friendly format
 	if value != nil && !goja.IsUndefined(value) {
-		result.Value = value.Export()
+		exported := value.Export()
+		if err := e.checkValueLimits(exported); err != nil {
+			log.Error().Err(err).Msg("JavaScript execution result exceeds runtime limits")
+			result.Error = err
+			return result, err
+		}
+		if buf, ok := exported.(goja.ArrayBuffer); ok {
+			// Images such as chart.render() output are shown by the playground
+			if url, ok := imageDataURL(buf.Bytes()); ok {
+				exported = url
+			}
+		}
+		result.Value = exported
 		log.Debug().Interface("resultValue", result.Value).Msg("JavaScript execution result captured")
 	} else {
 		log.Debug().Msg("JavaScript execution returned undefined or null")
@@ -363,17 +529,19 @@ func (e *Engine) GetGlobalState() string {
 // SetGlobalState sets the globalState object from a JSON string
 func (e *Engine) SetGlobalState(jsonData string) error {
 	e.mu.Lock()
-	defer e.mu.Unlock()
 
 	// Parse JSON and set globalState
 	code := "globalState = " + jsonData
 	_, err := e.rt.RunString(code)
 	if err != nil {
+		e.mu.Unlock()
 		log.Error().Err(err).Str("json", jsonData).Msg("Failed to set globalState")
 		return err
 	}
+	e.mu.Unlock()
 
 	log.Debug().Str("json", jsonData).Msg("GlobalState updated")
+	e.checkStateChanged()
 	return nil
 }
 
@@ -422,6 +590,10 @@ func (e *Engine) stringifyJSValue(value goja.Value) string {
 // Close gracefully shuts down the engine
 func (e *Engine) Close() error {
 	log.Debug().Msg("Shutting down JavaScript engine")
+	e.stopStateSync()
+	e.stopEventLog()
+	e.stopStorageSweeper()
+	e.closeStatements()
 
 	// Stop the event loop
 	if e.loop != nil {
@@ -429,6 +601,11 @@ func (e *Engine) Close() error {
 		log.Debug().Msg("Event loop stopped")
 	}
 
+	// Archive the request logs still in memory
+	if err := e.reqLogger.Close(); err != nil {
+		log.Error().Err(err).Msg("Failed to archive request logs")
+	}
+
 	// Close repository manager
 	if e.repos != nil {
 		dbModule, ok := gogogojamodules.GetModule("database").(*databasemod.DBModule)
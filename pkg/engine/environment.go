@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultEnvironmentName is used when no environment is configured
+const DefaultEnvironmentName = "dev"
+
+// Environment describes the execution environment an engine instance runs in
+type Environment struct {
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// IsProduction reports whether the environment is a production environment
+func (env *Environment) IsProduction() bool {
+	return env != nil && (env.Name == "prod" || env.Name == "production")
+}
+
+// LoadEnvironment builds an environment by name, reading its configuration from an
+// optional YAML file that maps environment names to config objects:
+//
+//	dev:
+//	  aiModel: gpt-4o-mini
+//	prod:
+//	  aiModel: gpt-4
+//	  rateLimit: 100
+func LoadEnvironment(name, configPath string) (*Environment, error) {
+	if name == "" {
+		name = DefaultEnvironmentName
+	}
+
+	env := &Environment{
+		Name:   name,
+		Config: make(map[string]interface{}),
+	}
+
+	if configPath == "" {
+		return env, nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environment config: %w", err)
+	}
+
+	var environments map[string]map[string]interface{}
+	if err := yaml.Unmarshal(data, &environments); err != nil {
+		return nil, fmt.Errorf("failed to parse environment config: %w", err)
+	}
+
+	if config, ok := environments[name]; ok && config != nil {
+		env.Config = config
+	} else {
+		log.Warn().Str("environment", name).Str("file", configPath).Msg("No configuration found for environment")
+	}
+
+	return env, nil
+}
+
+// SetEnvironment tags the engine with an environment and exposes it as `env` in JavaScript
+func (e *Engine) SetEnvironment(env *Environment) {
+	if env == nil {
+		env = &Environment{Name: DefaultEnvironmentName, Config: make(map[string]interface{})}
+	}
+
+	e.mu.Lock()
+	e.env = env
+	e.mu.Unlock()
+
+	e.setupEnvironmentBinding(env)
+
+	for _, replica := range e.replicas {
+		replica.SetEnvironment(env)
+	}
+
+	log.Info().Str("environment", env.Name).Int("configKeys", len(env.Config)).Msg("Engine environment configured")
+}
+
+// setupEnvironmentBinding exposes the environment as `env` in the current runtime
+func (e *Engine) setupEnvironmentBinding(env *Environment) {
+	if err := e.rt.Set("env", map[string]interface{}{
+		"name":   env.Name,
+		"config": env.Config,
+		"isProduction": func() bool {
+			return env.IsProduction()
+		},
+		"get": func(key string, defaultValue ...interface{}) interface{} {
+			if value, ok := env.Config[key]; ok {
+				return value
+			}
+			if len(defaultValue) > 0 {
+				return defaultValue[0]
+			}
+			return nil
+		},
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set env binding")
+	}
+}
+
+// GetEnvironment returns the environment the engine is tagged with
+func (e *Engine) GetEnvironment() *Environment {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.env
+}
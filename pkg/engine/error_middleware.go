@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Error middleware
+//
+// app.use() with a function of four parameters registers error middleware. It is called when
+// a route handler or its middleware throws, rejects or calls next(err), and can render the
+// error page instead of the bare 500:
+//
+//	app.use((err, req, res, next) => {
+//	  res.status(err.status || 500).json({ error: err.message });
+//	});
+//
+// Error middleware runs in registration order until one sends the response; next(other)
+// replaces the error the following ones get. It runs synchronously, a promise it returns is
+// not awaited. Without an answer the request fails with a 500 as before. Timeouts (504) and
+// rejected req.parse() calls (400) keep their own answers.
+
+// errorHandler is error middleware registered with app.use
+type errorHandler struct {
+	fn     goja.Callable
+	script string // Script file that registered it, see UnloadScriptFile
+}
+
+// scriptError is a failure raised with a JavaScript value that is not an exception: the
+// argument of next(err) or the reason of a rejected promise
+type scriptError struct {
+	value   goja.Value
+	message string
+}
+
+func (err *scriptError) Error() string {
+	return err.message
+}
+
+// isErrorMiddleware reports whether app.use() got error middleware: (err, req, res, next)
+func (e *Engine) isErrorMiddleware(fn goja.Value) bool {
+	if _, ok := goja.AssertFunction(fn); !ok {
+		return false
+	}
+	return fn.ToObject(e.rt).Get("length").ToInteger() == 4
+}
+
+// registerErrorHandler adds error middleware to this runtime
+func (e *Engine) registerErrorHandler(fn goja.Value) {
+	callable, _ := goja.AssertFunction(fn)
+	e.syncRegistration("error middleware", "app.use", fn)
+	e.mu.Lock()
+	e.errorHandlers = append(e.errorHandlers, errorHandler{fn: callable, script: e.currentScript})
+	e.mu.Unlock()
+	log.Info().Msg("Registered error middleware")
+}
+
+// errorValue returns the JavaScript value a handler failed with
+func (e *Engine) errorValue(err error) goja.Value {
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return exception.Value()
+	}
+	var scriptErr *scriptError
+	if errors.As(err, &scriptErr) {
+		return scriptErr.value
+	}
+	return e.rt.NewGoError(err)
+}
+
+// runErrorHandlers passes the error of a failed handler to the error middleware and reports
+// whether one of them answered the request
+func (e *Engine) runErrorHandlers(job EvalJob, resObj *ExpressResponse, err error) bool {
+	e.mu.RLock()
+	handlers := append([]errorHandler(nil), e.errorHandlers...)
+	e.mu.RUnlock()
+	if len(handlers) == 0 || resObj.req == nil {
+		return false
+	}
+
+	errValue := e.errorValue(err)
+	next := e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		if arg := fc.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+			errValue = arg
+		}
+		return goja.Undefined()
+	})
+	resValue := e.rt.ToValue(resObj)
+
+	stopTimeout := e.interruptAfter(e.jobTimeout(job))
+	defer stopTimeout()
+	for _, handler := range handlers {
+		if _, callErr := handler.fn(goja.Undefined(), errValue, resObj.req, resValue, next); callErr != nil {
+			log.Error().Err(callErr).Str("path", job.R.URL.Path).Msg("Error middleware failed")
+			return resObj.sent
+		}
+		if resObj.sent || resObj.IsStreaming() {
+			return true
+		}
+	}
+	return false
+}
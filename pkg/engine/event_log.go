@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Event log
+//
+// With the event log enabled (the default of jesus serve), executions, route changes and
+// errors are kept in the event_log table of the system database, so dashboards and data
+// pipelines can follow what happens in the playground without scraping admin endpoints.
+// Consumers page through it with /v1/events, passing the cursor of the previous answer:
+//
+//	curl 'localhost:9090/v1/events?after=0'
+//	curl 'localhost:9090/v1/events?after=42&types=error&wait=30'
+//
+// Event IDs grow in the order the events happened, so no event is skipped between two reads.
+// Events are written in batches outside the dispatcher; if the database cannot keep up,
+// events are dropped and a warning is logged. Handled requests are not logged, the request
+// log covers them. Engines sharing the system database, like serve and the MCP server, write
+// to the same log, so either one's /v1/events lists the activity of both.
+
+// DefaultEventRetention is how long events are kept unless configured otherwise
+const DefaultEventRetention = 7 * 24 * time.Hour
+
+// eventLogTypes are the event types kept in the event log
+var eventLogTypes = []EventType{EventScriptExecuted, EventRouteRegistered, EventRouteRemoved, EventError}
+
+// eventLogBatch is how many events are written in one transaction at most
+const eventLogBatch = 100
+
+// maxEventsWait bounds how long a read of the event log waits for new events
+const maxEventsWait = time.Minute
+
+// eventsPollInterval is how often a waiting read looks for events other engines wrote
+const eventsPollInterval = time.Second
+
+var errNoEventLog = errors.New("the event log is not enabled")
+
+// eventLog writes engine events to the system database
+type eventLog struct {
+	repo      repository.EventLogRepository
+	retention time.Duration
+	outbox    chan repository.EventLogEntry // Events waiting to be stored, written outside the dispatcher
+	remove    func()                        // Removes the event hook
+	stop      chan struct{}                 // Closed to stop the writer
+	stopped   chan struct{}                 // Closed once the writer returned
+
+	mu      sync.Mutex
+	written chan struct{} // Closed and replaced after each write, wakes up waiting reads
+}
+
+// EnableEventLog keeps executions, route changes and errors in the event log of the system
+// database until the engine is closed. Events older than retention are pruned, none if 0.
+func (e *Engine) EnableEventLog(retention time.Duration) error {
+	if e.repos == nil {
+		return fmt.Errorf("the event log needs the system database")
+	}
+	if e.eventLog.Load() != nil {
+		return fmt.Errorf("the event log is already enabled")
+	}
+
+	l := &eventLog{
+		repo:      e.repos.EventLog(),
+		retention: retention,
+		outbox:    make(chan repository.EventLogEntry, 1000),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		written:   make(chan struct{}),
+	}
+	l.remove = e.OnEvent(l.record, eventLogTypes...)
+	e.eventLog.Store(l)
+
+	go l.run()
+	log.Info().Dur("retention", retention).Msg("Event log enabled")
+	return nil
+}
+
+// stopEventLog stops recording events and waits for the queued ones to be written
+func (e *Engine) stopEventLog() {
+	if l := e.eventLog.Load(); l != nil {
+		l.remove()
+		close(l.stop)
+		<-l.stopped
+	}
+}
+
+// Events returns the events of the log after the cursor of the filter, oldest first. If there
+// are none, it waits up to wait for new ones, or until the context is done.
+func (e *Engine) Events(ctx context.Context, filter repository.EventLogFilter, wait time.Duration) ([]repository.EventLogEntry, error) {
+	l := e.root().eventLog.Load()
+	if l == nil {
+		return nil, errNoEventLog
+	}
+	if wait > maxEventsWait {
+		wait = maxEventsWait
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	poll := time.NewTicker(eventsPollInterval)
+	defer poll.Stop()
+
+	for {
+		// Taken before the query, so a write between the query and the wait is not missed
+		written := l.writtenChan()
+		events, err := l.repo.ListEvents(ctx, filter)
+		if err != nil || len(events) > 0 || wait <= 0 {
+			return events, err
+		}
+		select {
+		case <-written:
+		case <-poll.C:
+		case <-deadline.C:
+			return events, nil
+		case <-ctx.Done():
+			return events, nil
+		}
+	}
+}
+
+// LatestEventID returns the cursor of the newest event in the log, for consumers that only
+// want events from now on
+func (e *Engine) LatestEventID(ctx context.Context) (int64, error) {
+	l := e.root().eventLog.Load()
+	if l == nil {
+		return 0, errNoEventLog
+	}
+	return l.repo.LatestEventID(ctx)
+}
+
+// record queues an event without blocking the dispatcher; events are dropped if the database
+// cannot keep up
+func (l *eventLog) record(event Event) {
+	entry := repository.EventLogEntry{
+		Type:       string(event.Type),
+		Source:     event.Source,
+		SessionID:  event.SessionID,
+		Method:     event.Method,
+		Path:       event.Path,
+		DurationMs: event.Duration.Milliseconds(),
+		CreatedAt:  event.Time,
+	}
+	if event.Err != nil {
+		entry.Error = event.Err.Error()
+	}
+	select {
+	case l.outbox <- entry:
+	default:
+		log.Warn().Str("type", entry.Type).Msg("Event log outbox full, dropping event")
+	}
+}
+
+func (l *eventLog) writtenChan() chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.written
+}
+
+func (l *eventLog) run() {
+	defer close(l.stopped)
+	ctx := context.Background()
+	var prune <-chan time.Time
+	if l.retention > 0 {
+		ticker := time.NewTicker(min(l.retention/4, time.Hour))
+		defer ticker.Stop()
+		prune = ticker.C
+		l.prune(ctx)
+	}
+
+	for {
+		select {
+		case <-l.stop:
+			// Write what was queued before the engine closed
+			for len(l.outbox) > 0 {
+				l.write(ctx, <-l.outbox)
+			}
+			return
+		case event := <-l.outbox:
+			l.write(ctx, event)
+		case <-prune:
+			l.prune(ctx)
+		}
+	}
+}
+
+// write stores an event together with the events queued behind it and wakes up waiting reads
+func (l *eventLog) write(ctx context.Context, first repository.EventLogEntry) {
+	batch := []repository.EventLogEntry{first}
+	for len(batch) < eventLogBatch && len(l.outbox) > 0 {
+		batch = append(batch, <-l.outbox)
+	}
+	if err := l.repo.AppendEvents(ctx, batch); err != nil {
+		log.Error().Err(err).Int("events", len(batch)).Msg("Failed to write event log")
+		return
+	}
+
+	l.mu.Lock()
+	close(l.written)
+	l.written = make(chan struct{})
+	l.mu.Unlock()
+}
+
+func (l *eventLog) prune(ctx context.Context) {
+	if err := l.repo.PruneEvents(ctx, time.Now().Add(-l.retention)); err != nil {
+		log.Warn().Err(err).Msg("Failed to prune event log")
+	}
+}
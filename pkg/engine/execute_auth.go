@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Execute token
+//
+// With an execute token set (jesus serve --execute-token), /v1/execute and the REPL endpoint
+// only run code of requests that send it as bearer token:
+//
+//	curl -H 'Authorization: Bearer $TOKEN' --data 'db.query("SELECT 1")' localhost:9090/v1/execute
+//
+// Other requests are answered 401 and never run JavaScript. Without a token the endpoints
+// are open, as before.
+
+// SetExecuteToken sets the token /v1/execute and the REPL endpoint require, "" for none
+func (e *Engine) SetExecuteToken(token string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.executeToken = strings.TrimSpace(token)
+}
+
+// ExecuteTokenRequired reports whether code execution endpoints require the execute token
+func (e *Engine) ExecuteTokenRequired() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.executeToken != ""
+}
+
+// CheckExecuteToken reports whether a request may execute code: always without an execute
+// token, else only with the token as bearer token. reason says why a request was refused.
+func (e *Engine) CheckExecuteToken(r *http.Request) (ok bool, reason string) {
+	e.mu.RLock()
+	token := e.executeToken
+	e.mu.RUnlock()
+	if token == "" {
+		return true, ""
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) <= 7 || !strings.EqualFold(auth[:7], "Bearer ") {
+		return false, "missing bearer token"
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(auth[7:])), []byte(token)) != 1 {
+		return false, "invalid bearer token"
+	}
+	return true, ""
+}
@@ -0,0 +1,246 @@
+package engine
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+)
+
+// Execution changes
+//
+// Code executions report what they changed next to their result: the routes they added,
+// replaced or removed, the top-level globalState keys they added, changed or removed and the
+// application database tables they wrote to. Routes and globalState are compared before and
+// after the code runs; tables are taken from the write statements that succeeded, minus the
+// ones a rolled back db.transaction() undid. Writes made later by timers or handlers the code
+// registered are not part of its execution.
+
+// changeTracker collects the changes of the running code execution
+type changeTracker struct {
+	routesBefore map[string]bool
+	registered   []string                   // Routes registered by the code, in order
+	stateBefore  map[string]json.RawMessage // nil if globalState was not an object
+	tables       []string                   // Tables written to, in order
+}
+
+// trackChanges starts recording the changes of a code execution
+func (e *Engine) trackChanges() *changeTracker {
+	return &changeTracker{routesBefore: e.routeKeys(), stateBefore: e.globalStateKeys()}
+}
+
+// finishChanges compares the routes and globalState with the ones at the start of the
+// execution and returns its changes, nil if it changed nothing
+func (e *Engine) finishChanges(t *changeTracker) *repository.ExecutionChanges {
+	changes := &repository.ExecutionChanges{TablesWritten: t.tables}
+
+	after := e.routeKeys()
+	for _, route := range t.registered {
+		if !after[route] {
+			continue
+		}
+		if t.routesBefore[route] {
+			changes.RoutesReplaced = append(changes.RoutesReplaced, route)
+		} else {
+			changes.RoutesAdded = append(changes.RoutesAdded, route)
+		}
+	}
+	for route := range t.routesBefore {
+		if !after[route] {
+			changes.RoutesRemoved = append(changes.RoutesRemoved, route)
+		}
+	}
+	slices.Sort(changes.RoutesRemoved)
+
+	if state := e.globalStateKeys(); state != nil && t.stateBefore != nil {
+		for key, value := range state {
+			before, ok := t.stateBefore[key]
+			switch {
+			case !ok:
+				changes.StateAdded = append(changes.StateAdded, key)
+			case string(before) != string(value):
+				changes.StateChanged = append(changes.StateChanged, key)
+			}
+		}
+		for key := range t.stateBefore {
+			if _, ok := state[key]; !ok {
+				changes.StateRemoved = append(changes.StateRemoved, key)
+			}
+		}
+		slices.Sort(changes.StateAdded)
+		slices.Sort(changes.StateChanged)
+		slices.Sort(changes.StateRemoved)
+	}
+
+	if changes.IsEmpty() {
+		return nil
+	}
+	return changes
+}
+
+// routeKeys returns the registered routes as "METHOD /path", "FILE /path" for file handlers
+func (e *Engine) routeKeys() map[string]bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	keys := make(map[string]bool, len(e.handlers)+len(e.files))
+	for path, methods := range e.handlers {
+		for method := range methods {
+			keys[method+" "+path] = true
+		}
+	}
+	for path := range e.files {
+		keys["FILE "+path] = true
+	}
+	return keys
+}
+
+// globalStateKeys returns the top-level entries of globalState as JSON, nil if it is not an
+// object
+func (e *Engine) globalStateKeys() map[string]json.RawMessage {
+	var state map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(e.GetGlobalState()), &state); err != nil {
+		return nil
+	}
+	if state == nil {
+		state = map[string]json.RawMessage{}
+	}
+	return state
+}
+
+// routeRegistered notes a route registered by the code
+func (t *changeTracker) routeRegistered(method, path string) {
+	if t == nil {
+		return
+	}
+	if route := method + " " + path; !slices.Contains(t.registered, route) {
+		t.registered = append(t.registered, route)
+	}
+}
+
+// tableWritten notes the tables a statement that succeeded wrote to
+func (t *changeTracker) tableWritten(query string) {
+	if t == nil {
+		return
+	}
+	for _, table := range writtenTables(query) {
+		if !slices.Contains(t.tables, table) {
+			t.tables = append(t.tables, table)
+		}
+	}
+}
+
+// tablesMark returns the number of tables written so far, for discardTables
+func (t *changeTracker) tablesMark() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.tables)
+}
+
+// discardTables forgets the tables first written after a mark, when a transaction rolls back
+func (t *changeTracker) discardTables(mark int) {
+	if t != nil && mark < len(t.tables) {
+		t.tables = t.tables[:mark]
+	}
+}
+
+// writeStatement matches the start of a statement writing to a table and captures the table
+var writeStatement = regexp.MustCompile(`(?is)^(?:INSERT(?:\s+OR\s+\w+)?\s+INTO|REPLACE\s+INTO|UPDATE(?:\s+OR\s+\w+)?|DELETE\s+FROM|CREATE\s+(?:TEMP(?:ORARY)?\s+)?(?:VIRTUAL\s+)?TABLE(?:\s+IF\s+NOT\s+EXISTS)?|DROP\s+TABLE(?:\s+IF\s+EXISTS)?|ALTER\s+TABLE)\s+((?:"[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\]|[\w$]+)(?:\s*\.\s*(?:"[^"]+"|` + "`[^`]+`" + `|\[[^\]]+\]|[\w$]+))?)`)
+
+// writtenTables returns the tables the statements of query write to, without their schema.
+// Statements starting with a WITH clause and writes made by triggers are not recognized.
+func writtenTables(query string) []string {
+	var tables []string
+	for _, statement := range splitStatements(query) {
+		m := writeStatement.FindStringSubmatch(statement)
+		if m == nil {
+			continue
+		}
+		name := m[1]
+		if dot := qualifiedDot(name); dot >= 0 {
+			name = strings.TrimSpace(name[dot+1:])
+		}
+		if len(name) >= 2 && strings.ContainsRune(`"`+"`[", rune(name[0])) {
+			name = name[1 : len(name)-1]
+		}
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// qualifiedDot returns the index of the dot between the schema and the table of a possibly
+// quoted name, -1 if it has no schema
+func qualifiedDot(name string) int {
+	var quote byte
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '.':
+			return i
+		}
+	}
+	return -1
+}
+
+// splitStatements splits SQL into its statements, trimmed and without comments. Semicolons
+// in quotes and comments do not split.
+func splitStatements(query string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			statements = append(statements, s)
+		}
+		current.Reset()
+	}
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end
+			}
+			current.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			current.WriteByte(' ')
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := strings.IndexByte(query[i+1:], closing)
+			if end < 0 {
+				current.WriteString(query[i:])
+				i = len(query)
+				continue
+			}
+			current.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return statements
+}
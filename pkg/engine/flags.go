@@ -0,0 +1,227 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Feature flags
+//
+// Flags are stored in the system database and toggled from the admin page at /admin/flags,
+// so experiments can be gated without editing code:
+//
+//	flags.define('new-checkout', { description: 'One page checkout', percentage: 20 })
+//	if (flags.isEnabled('new-checkout', { userId: req.user.id })) { ... }
+//
+// A flag is on when it is enabled and the key of the context falls into its percentage. The
+// key is the context itself if it is a string or number, else its key, userId or id field.
+// The same key always gets the same answer, and raising the percentage keeps the keys that
+// were in. Without a key a flag is only on at 100%. Unknown flags are off.
+
+// flagsTTL is how long flags are cached before they are read again, so changes made by
+// other engines sharing the system database apply
+const flagsTTL = 5 * time.Second
+
+// errNoFlagStore is returned when the engine has no system database to keep flags in
+var errNoFlagStore = errors.New("feature flags need the system database")
+
+// flagContextKeys are the context fields naming the key of a rollout, in order
+var flagContextKeys = []string{"key", "userId", "id"}
+
+// setupFlagBindings installs flags
+func (e *Engine) setupFlagBindings() {
+	if err := e.rt.Set("flags", map[string]interface{}{
+		"isEnabled": e.flagsIsEnabled,
+		"define":    e.flagsDefine,
+		"list":      e.flagsList,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set flags binding")
+	}
+}
+
+// flagsIsEnabled implements flags.isEnabled(name, [context])
+func (e *Engine) flagsIsEnabled(call goja.FunctionCall) goja.Value {
+	name := call.Argument(0)
+	if goja.IsUndefined(name) || goja.IsNull(name) {
+		panic(e.rt.NewTypeError("flags.isEnabled() takes the name of a flag"))
+	}
+	return e.rt.ToValue(e.IsFeatureEnabled(name.String(), flagKey(call.Argument(1).Export())))
+}
+
+// flagKey returns the rollout key of an isEnabled() context, "" for none
+func flagKey(context interface{}) string {
+	switch v := context.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		for _, field := range flagContextKeys {
+			if key := flagKey(v[field]); key != "" {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// flagsDefine implements flags.define(name, [defaults]): the flag, created from the
+// defaults if it does not exist yet. Existing flags keep the settings of the admin page.
+func (e *Engine) flagsDefine(name string, defaults ...map[string]interface{}) *repository.FeatureFlag {
+	if flag, ok := e.featureFlag(name); ok {
+		return &flag
+	}
+	flag := repository.FeatureFlag{Name: name, Percentage: 100}
+	if len(defaults) > 0 && defaults[0] != nil {
+		opts := defaults[0]
+		flag.Description, _ = opts["description"].(string)
+		flag.Enabled, _ = opts["enabled"].(bool)
+		switch p := opts["percentage"].(type) {
+		case nil:
+		case int64:
+			flag.Percentage = int(p)
+		case float64:
+			flag.Percentage = int(p)
+		default:
+			panic(e.rt.NewTypeError(fmt.Sprintf("flags.define(): percentage must be a number, got %T", p)))
+		}
+	}
+	saved, err := e.SaveFeatureFlag(flag)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return saved
+}
+
+// flagsList implements flags.list()
+func (e *Engine) flagsList() []repository.FeatureFlag {
+	flags, err := e.FeatureFlags()
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return flags
+}
+
+// IsFeatureEnabled reports whether a flag is on for a rollout key, "" for no key
+func (e *Engine) IsFeatureEnabled(name, key string) bool {
+	flag, ok := e.featureFlag(name)
+	if !ok || !flag.Enabled || flag.Percentage <= 0 {
+		return false
+	}
+	if flag.Percentage >= 100 {
+		return true
+	}
+	if key == "" {
+		return false
+	}
+	return rolloutBucket(name, key) < flag.Percentage
+}
+
+// rolloutBucket places a key of a flag in one of 100 buckets
+func rolloutBucket(name, key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+// FeatureFlags returns the flags of the system database, ordered by name
+func (e *Engine) FeatureFlags() ([]repository.FeatureFlag, error) {
+	if e.repos == nil {
+		return nil, errNoFlagStore
+	}
+	flags, err := e.repos.FeatureFlags().ListFlags(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	e.cacheFlags(flags)
+	return flags, nil
+}
+
+// SaveFeatureFlag creates or replaces a flag
+func (e *Engine) SaveFeatureFlag(flag repository.FeatureFlag) (*repository.FeatureFlag, error) {
+	if flag.Name == "" {
+		return nil, fmt.Errorf("a feature flag needs a name")
+	}
+	if flag.Percentage < 0 || flag.Percentage > 100 {
+		return nil, fmt.Errorf("the percentage of feature flag %s must be between 0 and 100, got %d", flag.Name, flag.Percentage)
+	}
+	if e.repos == nil {
+		return nil, errNoFlagStore
+	}
+	saved, err := e.repos.FeatureFlags().SaveFlag(context.Background(), flag)
+	if err != nil {
+		return nil, err
+	}
+	root := e.root()
+	root.mu.Lock()
+	if root.featureFlags != nil {
+		root.featureFlags[saved.Name] = *saved
+	}
+	root.mu.Unlock()
+	log.Info().Str("flag", saved.Name).Bool("enabled", saved.Enabled).Int("percentage", saved.Percentage).Msg("Feature flag saved")
+	return saved, nil
+}
+
+// DeleteFeatureFlag removes a flag, which is off from then on
+func (e *Engine) DeleteFeatureFlag(name string) error {
+	if e.repos == nil {
+		return errNoFlagStore
+	}
+	if err := e.repos.FeatureFlags().DeleteFlag(context.Background(), name); err != nil {
+		return err
+	}
+	root := e.root()
+	root.mu.Lock()
+	delete(root.featureFlags, name)
+	root.mu.Unlock()
+	log.Info().Str("flag", name).Msg("Feature flag deleted")
+	return nil
+}
+
+// featureFlag returns a flag from the cache, reading the flags again once it is stale
+func (e *Engine) featureFlag(name string) (repository.FeatureFlag, bool) {
+	root := e.root()
+	root.mu.RLock()
+	fresh := root.featureFlags != nil && time.Since(root.flagsLoaded) < flagsTTL
+	flag, ok := root.featureFlags[name]
+	root.mu.RUnlock()
+	if fresh {
+		return flag, ok
+	}
+
+	if _, err := e.FeatureFlags(); err != nil {
+		if !errors.Is(err, errNoFlagStore) {
+			log.Warn().Err(err).Msg("Failed to read feature flags, using the cached ones")
+		}
+		return flag, ok
+	}
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	flag, ok = root.featureFlags[name]
+	return flag, ok
+}
+
+// cacheFlags replaces the cached flags
+func (e *Engine) cacheFlags(flags []repository.FeatureFlag) {
+	byName := make(map[string]repository.FeatureFlag, len(flags))
+	for _, flag := range flags {
+		byName[flag.Name] = flag
+	}
+	root := e.root()
+	root.mu.Lock()
+	root.featureFlags = byName
+	root.flagsLoaded = time.Now()
+	root.mu.Unlock()
+}
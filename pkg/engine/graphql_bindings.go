@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// setupGraphQLBindings installs the graphql global:
+//
+//	const res = graphql.query('https://api.example.com/graphql',
+//	    'query($id: ID!) { user(id: $id) { name } }', {id: '1'}, {Authorization: 'Bearer ...'});
+//	res.data.user.name
+//
+// GraphQL errors are thrown unless {allowErrors: true} is passed as fifth argument, in which
+// case they are returned next to the partial data. {persisted: true} uses Apollo-style
+// automatic persisted queries. timeout, retries, backoff, retryOn and jar work as in fetch().
+func (e *Engine) setupGraphQLBindings() {
+	if err := e.rt.Set("graphql", map[string]interface{}{
+		"query": e.graphqlQuery,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set graphql binding")
+	}
+}
+
+// graphqlQuery posts a GraphQL operation and returns {data, errors, extensions, status}
+func (e *Engine) graphqlQuery(url, query string, args ...map[string]interface{}) map[string]interface{} {
+	var variables, headers, options map[string]interface{}
+	if len(args) > 0 {
+		variables = args[0]
+	}
+	if len(args) > 1 {
+		headers = args[1]
+	}
+	if len(args) > 2 {
+		options = args[2]
+	}
+
+	persisted, _ := options["persisted"].(bool)
+	allowErrors, _ := options["allowErrors"].(bool)
+
+	payload := map[string]interface{}{"query": query}
+	if len(variables) > 0 {
+		payload["variables"] = variables
+	}
+
+	var response map[string]interface{}
+	if persisted {
+		hash := sha256.Sum256([]byte(query))
+		payload["extensions"] = map[string]interface{}{
+			"persistedQuery": map[string]interface{}{
+				"version":    1,
+				"sha256Hash": hex.EncodeToString(hash[:]),
+			},
+		}
+
+		// Send only the hash first; the server asks for the full query if it does not know it
+		delete(payload, "query")
+		response = e.graphqlPost(url, payload, headers, options)
+		if graphqlPersistedQueryNotFound(response) {
+			payload["query"] = query
+			response = e.graphqlPost(url, payload, headers, options)
+		}
+	} else {
+		response = e.graphqlPost(url, payload, headers, options)
+	}
+
+	if errMsg, ok := response["error"].(string); ok && errMsg != "" {
+		panic(e.rt.NewGoError(fmt.Errorf("graphql request to %s failed: %s", url, errMsg)))
+	}
+
+	body, ok := response["json"].(map[string]interface{})
+	if !ok {
+		status, _ := response["status"].(int)
+		raw, _ := response["body"].(string)
+		panic(e.rt.NewGoError(fmt.Errorf("graphql request to %s returned status %d without a JSON body: %s", url, status, truncateString(raw, 200))))
+	}
+
+	result := map[string]interface{}{
+		"data":       body["data"],
+		"errors":     body["errors"],
+		"extensions": body["extensions"],
+		"status":     response["status"],
+	}
+
+	if messages := graphqlErrorMessages(body["errors"]); len(messages) > 0 && !allowErrors {
+		panic(e.rt.NewGoError(fmt.Errorf("graphql errors: %s", strings.Join(messages, "; "))))
+	}
+
+	return result
+}
+
+// graphqlPost sends the GraphQL payload as a JSON POST through the regular HTTP binding
+func (e *Engine) graphqlPost(url string, payload, headers, options map[string]interface{}) map[string]interface{} {
+	if errResponse := e.checkNetwork(); errResponse != nil {
+		return errResponse
+	}
+	req := HTTPRequest{
+		URL:     url,
+		Method:  "POST",
+		Headers: map[string]string{"Accept": "application/json"},
+	}
+	if options != nil {
+		e.parseHTTPOptions(&req, options)
+	}
+	for k, v := range headers {
+		req.Headers[k] = fmt.Sprint(v)
+	}
+	req.URL = url
+	req.Method = "POST"
+	req.Body = payload
+	req.Stream = false
+
+	return e.executeHTTPRequest(&req)
+}
+
+// graphqlErrorMessages extracts the messages of a GraphQL errors array, adding the path when present
+func graphqlErrorMessages(errors interface{}) []string {
+	list, ok := errors.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	messages := make([]string, 0, len(list))
+	for _, item := range list {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			messages = append(messages, fmt.Sprint(item))
+			continue
+		}
+		message := fmt.Sprint(entry["message"])
+		if path, ok := entry["path"].([]interface{}); ok && len(path) > 0 {
+			parts := make([]string, len(path))
+			for i, p := range path {
+				parts[i] = fmt.Sprint(p)
+			}
+			message += " (at " + strings.Join(parts, ".") + ")"
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// graphqlPersistedQueryNotFound reports whether the server does not know a persisted query hash
+func graphqlPersistedQueryNotFound(response map[string]interface{}) bool {
+	body, ok := response["json"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	list, _ := body["errors"].([]interface{})
+	for _, item := range list {
+		entry, _ := item.(map[string]interface{})
+		if message, _ := entry["message"].(string); message == "PersistedQueryNotFound" {
+			return true
+		}
+		if extensions, ok := entry["extensions"].(map[string]interface{}); ok {
+			if code, _ := extensions["code"].(string); code == "PERSISTED_QUERY_NOT_FOUND" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// truncateString shortens s to at most n bytes for error messages
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// Middleware functions
+//
+// Routes can list JavaScript middleware before their handler. Each middleware gets
+// (req, res, next) and runs in order; the chain stops once a middleware sends the response.
+// Values for later functions go in res.locals:
+//
+//	const requireUser = (req, res) => {
+//	  if (!req.headers['x-user']) return res.status(401).json({ error: 'Login required' });
+//	  res.locals.user = req.headers['x-user'];
+//	};
+//	app.get('/orders', requireUser, validateQuery, listOrders);
+//
+// Calling next() is optional. next(err) fails the request like throwing does, see error
+// middleware. A middleware returning a promise continues the chain once it resolves.
+
+// callHandlerChain calls the middleware functions of a route, then its handler. It returns
+// what the handler returned, or a promise of it when a middleware returned a pending promise.
+func (e *Engine) callHandlerChain(handler *HandlerInfo, req, res goja.Value) (goja.Value, error) {
+	resObj, _ := res.Export().(*ExpressResponse)
+
+	var call func(i int) (goja.Value, error)
+	call = func(i int) (goja.Value, error) {
+		if i == len(handler.Chain) {
+			return handler.Fn(goja.Undefined(), req, res)
+		}
+
+		var nextErr goja.Value
+		next := e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+			if arg := fc.Argument(0); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+				nextErr = arg
+			}
+			return goja.Undefined()
+		})
+		v, err := handler.Chain[i](goja.Undefined(), req, res, next)
+		if err != nil {
+			return nil, err
+		}
+
+		proceed := func() (goja.Value, error) {
+			if nextErr != nil {
+				return nil, &scriptError{value: nextErr, message: fmt.Sprintf("middleware failed: %s", nextErr.String())}
+			}
+			if resObj != nil && (resObj.sent || resObj.IsStreaming()) {
+				// The middleware answered the request
+				return goja.Undefined(), nil
+			}
+			return call(i + 1)
+		}
+
+		promise, ok := v.Export().(*goja.Promise)
+		if !ok || promise.State() == goja.PromiseStateFulfilled {
+			return proceed()
+		}
+		if promise.State() == goja.PromiseStateRejected {
+			return nil, rejectionError(promise.Result())
+		}
+
+		// Continue the chain once the promise resolves; a rejection skips the rest
+		promiseObj := e.rt.ToValue(promise).ToObject(e.rt)
+		then, ok := goja.AssertFunction(promiseObj.Get("then"))
+		if !ok {
+			return nil, fmt.Errorf("middleware returned a promise without then()")
+		}
+		return then(promiseObj, e.rt.ToValue(func(goja.FunctionCall) goja.Value {
+			v, err := proceed()
+			if err != nil {
+				if exception, ok := err.(*goja.Exception); ok {
+					panic(exception.Value())
+				}
+				panic(e.rt.NewGoError(err))
+			}
+			return v
+		}))
+	}
+	return call(0)
+}
@@ -29,20 +29,38 @@ type ExpressRequest struct {
 	Headers  map[string]interface{} `json:"headers"`
 	Body     interface{}            `json:"body"`
 	Cookies  map[string]string      `json:"cookies"`
+	Signed   map[string]interface{} `json:"signedCookies"` // Verified signed cookies, false for tampered ones
 	IP       string                 `json:"ip"`
 	Protocol string                 `json:"protocol"`
 	Hostname string                 `json:"hostname"`
+	Locale   string                 `json:"locale"` // Locale i18n.middleware() negotiated, "" otherwise
 	Params   map[string]string      `json:"params"`
+	Files    map[string]interface{} `json:"files"` // Files of a multipart request by field name
+	User     interface{}            `json:"user"`  // Who auth.basic() or auth.bearer() let in, null otherwise
+	engine   *Engine                `json:"-"`
+
+	uploads   []*UploadedFile // Temporary files of the request, removed when the response closes
+	uploadErr error           // Why the multipart body was rejected
+	withBody  bool            // Whether the request came with a body, see req.is()
 }
 
 // ExpressResponse represents an Express.js compatible response object
 type ExpressResponse struct {
-	StatusCode int                 `json:"statusCode"`
-	Headers    map[string]string   `json:"headers"`
-	Cookies    []*http.Cookie      `json:"cookies"`
-	writer     http.ResponseWriter `json:"-"`
-	engine     *Engine             `json:"-"`
-	sent       bool                `json:"-"`
+	StatusCode int                    `json:"statusCode"`
+	Headers    map[string]string      `json:"headers"`
+	Cookies    []*http.Cookie         `json:"cookies"`
+	Locals     map[string]interface{} `json:"locals"` // Values middleware passes on to the handler
+	writer     http.ResponseWriter    `json:"-"`
+	request    *http.Request          `json:"-"` // Request answered, for conditional and range requests
+	req        goja.Value             `json:"-"` // JavaScript request object, passed to error middleware
+	engine     *Engine                `json:"-"`
+	sent       bool                   `json:"-"`
+	stream     *responseStream        `json:"-"` // Body streamed with res.write() or res.sse()
+	onClose    []goja.Callable        `json:"-"` // Callbacks of res.onClose()
+	closeHooks []func()               `json:"-"` // Go callbacks of close, e.g. to stop a proxied response
+	closed     bool                   `json:"-"` // Whether the response is finished, see close
+	uploads    []*UploadedFile        `json:"-"` // Uploaded files of the request, removed by close
+	schema     *responseSchema        `json:"-"` // Schema the JSON body must match, see checkSchema
 }
 
 // Express.js response methods
@@ -61,6 +79,9 @@ func (r *ExpressResponse) Status(code interface{}) *ExpressResponse {
 	} else if statusCode, ok := code.(int); ok {
 		r.StatusCode = statusCode
 		log.Debug().Int("statusCode", r.StatusCode).Msg("Status set from int")
+	} else if statusCode, ok := code.(int64); ok {
+		r.StatusCode = int(statusCode)
+		log.Debug().Int("statusCode", r.StatusCode).Msg("Status set from int64")
 	} else {
 		log.Debug().Interface("code", code).Str("type", fmt.Sprintf("%T", code)).Msg("Unknown status code type")
 	}
@@ -75,12 +96,18 @@ func (r *ExpressResponse) Send(data interface{}) error {
 		log.Debug().Msg("Response already sent, ignoring Send call")
 		return nil
 	}
-	r.sent = true
+	if err := r.engine.checkValueLimits(data); err != nil {
+		return err
+	}
 
 	// Set default status if not set
 	if r.StatusCode == 0 {
 		r.StatusCode = 200
 	}
+	if err := r.checkSchema(data); err != nil {
+		return err
+	}
+	r.sent = true
 
 	// Set any pending headers
 	for key, value := range r.Headers {
@@ -109,6 +136,9 @@ func (r *ExpressResponse) Send(data interface{}) error {
 				log.Debug().Msg("Detected plain text content")
 			}
 		}
+		if strings.HasPrefix(r.writer.Header().Get("Content-Type"), "text/html") {
+			v = r.engine.sanitizeSentHTML(v)
+		}
 		r.writer.WriteHeader(r.StatusCode)
 		log.Debug().Int("statusCode", r.StatusCode).Str("content", v).Msg("Writing string response")
 		_, err := r.writer.Write([]byte(v))
@@ -141,11 +171,17 @@ func (r *ExpressResponse) Json(data interface{}) error {
 		log.Debug().Msg("Response already sent, ignoring JSON call")
 		return nil
 	}
-	r.sent = true
+	if err := r.engine.checkValueLimits(data); err != nil {
+		return err
+	}
 
 	if r.StatusCode == 0 {
 		r.StatusCode = 200
 	}
+	if err := r.checkSchema(data); err != nil {
+		return err
+	}
+	r.sent = true
 
 	// Set any pending headers
 	for key, value := range r.Headers {
@@ -209,11 +245,20 @@ func (r *ExpressResponse) Set(name, value string) *ExpressResponse {
 	return r
 }
 
-// Cookie sets a response cookie
+// Cookie sets a response cookie; the signed option signs its value, see SetCookieSecrets
 func (r *ExpressResponse) Cookie(name, value string, options ...interface{}) *ExpressResponse {
 	if r.sent {
 		return r
 	}
+	if len(options) > 0 {
+		if opts, ok := options[0].(map[string]interface{}); ok && opts["signed"] == true {
+			signed, ok := r.engine.signCookie(value)
+			if !ok {
+				panic(r.engine.rt.NewTypeError("res.cookie(): signed cookies need a secret, start the server with --cookie-secrets"))
+			}
+			value = signed
+		}
+	}
 
 	cookie := &http.Cookie{
 		Name:  name,
@@ -259,6 +304,15 @@ func (r *ExpressResponse) Cookie(name, value string, options ...interface{}) *Ex
 
 // End ends the response
 func (r *ExpressResponse) End(data ...interface{}) error {
+	if r.stream != nil {
+		if len(data) > 0 && !r.stream.sse {
+			if _, err := r.Write(data[0]); err != nil {
+				return err
+			}
+		}
+		r.close()
+		return nil
+	}
 	if r.sent {
 		return nil
 	}
@@ -287,12 +341,30 @@ func (r *ExpressResponse) End(data ...interface{}) error {
 }
 
 // registerHandler registers an HTTP handler function with enhanced request/response support
-// Usage: registerHandler(method, path, handler [, options])
+// Usage: registerHandler(method, path, [middleware...,] handler [, options])
 func (e *Engine) registerHandler(method, path string, handler goja.Value, args ...goja.Value) {
 	callable, ok := goja.AssertFunction(handler)
 	if !ok {
 		panic(e.rt.NewTypeError("Handler must be a function"))
 	}
+	e.checkRouteRegistration(method, path)
+
+	// Functions after the first one make it a middleware chain; the last function is the handler
+	var chain []goja.Callable
+	var fnArgs []goja.Value
+	for len(args) > 0 {
+		next, ok := goja.AssertFunction(args[0])
+		if !ok {
+			break
+		}
+		chain = append(chain, callable)
+		callable = next
+		fnArgs = append(fnArgs, args[0])
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s %s: middleware and handler must be functions, followed by an optional options object", method, path)))
+	}
 
 	// Parse optional options object
 	var options map[string]interface{}
@@ -315,6 +387,41 @@ func (e *Engine) registerHandler(method, path string, handler goja.Value, args .
 		}
 	}
 
+	timeout, err := parseRouteTimeout(options["timeout"])
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s %s: %v", method, path, err)))
+	}
+	middleware, err := parseRouteMiddleware(method, options)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s %s: %v", method, path, err)))
+	}
+	response, err := parseResponseSchema(options)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s %s: %v", method, path, err)))
+	}
+	replicate, ok := options["replicate"].(bool)
+	if _, set := options["replicate"]; set && !ok {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s %s: replicate must be a boolean", method, path)))
+	}
+	for _, fn := range append([]goja.Value{handler}, fnArgs...) {
+		if policy := corsPolicyOf(fn); policy != nil && middleware.CORS == nil {
+			middleware.CORS = policy
+		}
+		if limiter := rateLimiterOf(fn); limiter != nil && !limiter.byFunction && middleware.RateLimit == nil {
+			middleware.RateLimit = limiter
+		}
+	}
+	route, err := compileRoutePattern(path)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s %s: %v", method, path, err)))
+	}
+
+	syncArgs := []interface{}{method, path, handler}
+	for _, fn := range fnArgs {
+		syncArgs = append(syncArgs, fn)
+	}
+	e.syncRegistration(method+" "+path, "registerHandler", append(syncArgs, options)...)
+
 	// Store the original path pattern for parameter extraction
 	if options == nil {
 		options = make(map[string]interface{})
@@ -324,23 +431,37 @@ func (e *Engine) registerHandler(method, path string, handler goja.Value, args .
 	// XXX I don't think we need the ContentType and Options here any more since everything goes through app.get/*
 	handlerInfo := &HandlerInfo{
 		Fn:          callable,
+		Chain:       chain,
 		ContentType: contentType,
 		Options:     options,
+		Doc:         parseRouteDoc(method, path, options),
+		Timeout:     timeout,
+		Middleware:  middleware,
+		route:       route,
+		replicated:  e.replicating && replicate, // Served by the pool runtimes, which have no globalState
+		script:      e.currentScript,
+		policy:      e.currentPolicy.handlerPolicy(),
+		response:    response,
+	}
+	if e.currentScript == "" && e.currentSession != "" {
+		handlerInfo.session = e.currentSession
+		handlerInfo.origin = e.currentSource
 	}
 
 	e.mu.Lock()
-	defer e.mu.Unlock()
-
 	if e.handlers[path] == nil {
 		e.handlers[path] = make(map[string]*HandlerInfo)
+		e.addRoutePattern(route)
 	}
 	e.handlers[path][method] = handlerInfo
+	e.mu.Unlock()
 
 	if contentType != "" {
 		log.Info().Str("method", method).Str("path", path).Str("content-type", contentType).Msg("Registered HTTP handler with content type")
 	} else {
 		log.Info().Str("method", method).Str("path", path).Msg("Registered HTTP handler")
 	}
+	e.emitRouteRegistered(method, path)
 }
 
 // registerFile registers a file handler function
@@ -349,12 +470,27 @@ func (e *Engine) registerFile(path string, handler goja.Value) {
 	if !ok {
 		panic(e.rt.NewTypeError("File handler must be a function"))
 	}
+	e.checkRouteRegistration("FILE", path)
 
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	e.syncRegistration("FILE "+path, "registerFile", path, handler)
 
+	e.mu.Lock()
 	e.files[path] = callable
+	e.fileScripts[path] = e.currentScript
+	e.mu.Unlock()
+
 	log.Info().Str("path", path).Msg("Registered file handler")
+	e.emitRouteRegistered("FILE", path)
+}
+
+// emitRouteRegistered reports a new route; pool runtimes registering their copy of a
+// replicated route stay silent
+func (e *Engine) emitRouteRegistered(method, path string) {
+	e.changes.routeRegistered(method, path)
+	if e.primary != nil {
+		return
+	}
+	e.emit(Event{Type: EventRouteRegistered, Method: method, Path: path, SessionID: e.currentSession})
 }
 
 // Helper functions for content type detection
@@ -372,35 +508,63 @@ func isJSON(s string) bool {
 }
 
 // appGet registers a GET route handler (Express.js style)
-func (e *Engine) appGet(path string, handler goja.Value) {
-	e.registerHandler("GET", path, handler)
+func (e *Engine) appGet(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler("GET", path, handler, args...)
 }
 
 // appPost registers a POST route handler (Express.js style)
-func (e *Engine) appPost(path string, handler goja.Value) {
-	e.registerHandler("POST", path, handler)
+func (e *Engine) appPost(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler("POST", path, handler, args...)
 }
 
 // appPut registers a PUT route handler (Express.js style)
-func (e *Engine) appPut(path string, handler goja.Value) {
-	e.registerHandler("PUT", path, handler)
+func (e *Engine) appPut(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler("PUT", path, handler, args...)
 }
 
 // appDelete registers a DELETE route handler (Express.js style)
-func (e *Engine) appDelete(path string, handler goja.Value) {
-	e.registerHandler("DELETE", path, handler)
+func (e *Engine) appDelete(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler("DELETE", path, handler, args...)
 }
 
 // appPatch registers a PATCH route handler (Express.js style)
-func (e *Engine) appPatch(path string, handler goja.Value) {
-	e.registerHandler("PATCH", path, handler)
+func (e *Engine) appPatch(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler("PATCH", path, handler, args...)
+}
+
+// appAll registers a route handler for every method (Express.js style)
+func (e *Engine) appAll(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler(AllMethods, path, handler, args...)
 }
 
 // appUse registers middleware or route handler (Express.js style)
 func (e *Engine) appUse(args ...goja.Value) {
+	// app.use(prefix, ...middleware, router) mounts a sub-router
+	if prefix, middleware, router, ok := routerUseArgs(args); ok {
+		for _, fn := range middleware {
+			if _, ok := goja.AssertFunction(fn); !ok {
+				panic(e.rt.NewTypeError("app.use() takes a path, middleware functions and a router"))
+			}
+		}
+		router.mount(routerMount{prefix: prefix, middleware: middleware})
+		return
+	}
+
+	// Error middleware, cors() and rateLimit() apply to every route, so they are checked like
+	// routes: functions of a session runtime must not end up in the shared runtime
+	if len(args) == 1 {
+		e.checkRouteRegistration("USE", "/*")
+	}
+
 	// Basic implementation - if only one argument, it's a middleware for all routes
 	// If two arguments, first is path and second is handler
-	if len(args) == 1 {
+	if len(args) == 1 && e.isErrorMiddleware(args[0]) {
+		e.registerErrorHandler(args[0])
+	} else if len(args) == 1 && corsPolicyOf(args[0]) != nil {
+		e.setGlobalCORS(corsPolicyOf(args[0]))
+	} else if len(args) == 1 && rateLimiterOf(args[0]) != nil {
+		e.setGlobalRateLimit(rateLimiterOf(args[0]))
+	} else if len(args) == 1 {
 		// Global middleware (simplified implementation)
 		handler := args[0]
 		// Register for common HTTP methods
@@ -431,10 +595,22 @@ func (e *Engine) setupHTTPUtilities() {
 		"put":    e.appPut,
 		"delete": e.appDelete,
 		"patch":  e.appPatch,
+		"all":    e.appAll,
 		"use":    e.appUse,
+		"ws":     e.appWs,
+		"static": e.appStatic,
 	}); err != nil {
 		log.Error().Err(err).Msg("Failed to set app binding")
 	}
+	if err := e.rt.Set("Router", e.newRouter); err != nil {
+		log.Error().Err(err).Msg("Failed to set Router binding")
+	}
+	if err := e.rt.Set("cors", e.cors); err != nil {
+		log.Error().Err(err).Msg("Failed to set cors binding")
+	}
+	if err := e.rt.Set("rateLimit", e.rateLimit); err != nil {
+		log.Error().Err(err).Msg("Failed to set rateLimit binding")
+	}
 
 	// Legacy registerHandler for backward compatibility
 	if err := e.rt.Set("registerHandler", e.registerHandler); err != nil {
@@ -469,46 +645,6 @@ func (e *Engine) setupHTTPUtilities() {
 
 }
 
-// pathMatches checks if a URL path matches a pattern with parameters
-func pathMatches(pattern, path string) bool {
-	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-
-	if len(patternParts) != len(pathParts) {
-		return false
-	}
-
-	for i, part := range patternParts {
-		if !strings.HasPrefix(part, ":") && part != pathParts[i] {
-			return false
-		}
-	}
-
-	return true
-}
-
-// parsePathParams extracts path parameters from URL (basic implementation)
-// This is a simplified version - in production you'd want a more robust router
-func parsePathParams(pattern, path string) map[string]string {
-	params := make(map[string]string)
-
-	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
-	pathParts := strings.Split(strings.Trim(path, "/"), "/")
-
-	if len(patternParts) != len(pathParts) {
-		return params
-	}
-
-	for i, part := range patternParts {
-		if strings.HasPrefix(part, ":") {
-			paramName := part[1:]
-			params[paramName] = pathParts[i]
-		}
-	}
-
-	return params
-}
-
 // createExpressRequestObject creates an Express.js compatible request object
 func (e *Engine) createExpressRequestObject(r *http.Request) *ExpressRequest {
 	log.Debug().
@@ -543,19 +679,20 @@ func (e *Engine) createExpressRequestObject(r *http.Request) *ExpressRequest {
 	for _, cookie := range r.Cookies() {
 		cookies[cookie.Name] = cookie.Value
 	}
+	signedCookies := e.splitSignedCookies(cookies)
 
-	// Extract client IP
-	ip := r.RemoteAddr
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		if parts := strings.Split(xff, ","); len(parts) > 0 {
-			ip = strings.TrimSpace(parts[0])
-		}
-	} else if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		ip = xri
-	}
+	// Extract client IP, from the forwarding headers of trusted proxies only
+	ip := e.ClientIP(r)
 
-	// Extract and parse request body
-	body := extractRequestBody(r)
+	// Extract and parse request body; multipart files are written to temporary files
+	var body interface{}
+	var uploads []*UploadedFile
+	var uploadErr error
+	if boundary, ok := isMultipartRequest(r); ok {
+		body, uploads, uploadErr = e.parseMultipartBody(r, boundary)
+	} else {
+		body = extractRequestBody(r)
+	}
 	log.Debug().
 		Interface("body", body).
 		Str("bodyType", fmt.Sprintf("%T", body)).
@@ -581,20 +718,29 @@ func (e *Engine) createExpressRequestObject(r *http.Request) *ExpressRequest {
 		Headers:  headers,
 		Body:     body,
 		Cookies:  cookies,
+		Signed:   signedCookies,
 		IP:       ip,
 		Protocol: protocol,
 		Hostname: hostname,
 		Params:   make(map[string]string), // will be populated by path matching
+		Files:    uploadedFilesByField(uploads),
+		engine:   e,
+
+		uploads:   uploads,
+		uploadErr: uploadErr,
+		withBody:  r.ContentLength != 0, // -1 for chunked bodies
 	}
 }
 
 // createExpressResponseObject creates an Express.js compatible response object
-func (e *Engine) createExpressResponseObject(w http.ResponseWriter) *ExpressResponse {
+func (e *Engine) createExpressResponseObject(w http.ResponseWriter, r *http.Request) *ExpressResponse {
 	return &ExpressResponse{
 		StatusCode: 200,
 		Headers:    make(map[string]string),
 		Cookies:    make([]*http.Cookie, 0),
+		Locals:     make(map[string]interface{}),
 		writer:     w,
+		request:    r,
 		engine:     e,
 		sent:       false,
 	}
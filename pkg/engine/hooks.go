@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventType names an engine event Go embedders can subscribe to with OnEvent
+type EventType string
+
+const (
+	// EventScriptExecuted fires after code submitted for direct execution ran, failed or not
+	EventScriptExecuted EventType = "script.executed"
+	// EventRequestHandled fires after a route or file handler served a request
+	EventRequestHandled EventType = "request.handled"
+	// EventRouteRegistered fires when JavaScript registers a route or file handler
+	EventRouteRegistered EventType = "route.registered"
+	// EventRouteRemoved fires for each route or file handler of a script file that is unloaded
+	EventRouteRemoved EventType = "route.removed"
+	// EventError fires when a script or handler throws or is aborted
+	EventError EventType = "error"
+	// EventStateChanged fires when globalState differs after a job, or is set from Go
+	EventStateChanged EventType = "state.changed"
+)
+
+// Event describes something that happened in the engine. Fields that do not apply to the
+// event type are left empty.
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Source    string        // Execution source (api, mcp, file, self-check, ...)
+	SessionID string        // Session of the execution
+	Method    string        // Route method, FILE for file handlers
+	Path      string        // Route path
+	Duration  time.Duration // Run time of the execution or request
+	Err       error         // Error thrown by the script or handler
+	State     string        // globalState as JSON, for EventStateChanged
+}
+
+// EventHook receives engine events. Hooks run synchronously on the goroutine raising the
+// event, usually the dispatcher, so they must return quickly and must not submit jobs and
+// wait for them.
+type EventHook func(Event)
+
+type eventHook struct {
+	id    int
+	hook  EventHook
+	types map[EventType]bool // nil for every type
+}
+
+// OnEvent registers a hook for the given event types, or for every event when none are
+// given. The returned function removes the hook again.
+func (e *Engine) OnEvent(hook EventHook, types ...EventType) func() {
+	if e.primary != nil {
+		return e.primary.OnEvent(hook, types...)
+	}
+
+	entry := eventHook{hook: hook}
+	if len(types) > 0 {
+		entry.types = make(map[EventType]bool, len(types))
+		for _, t := range types {
+			entry.types[t] = true
+		}
+	}
+
+	e.hooksMu.Lock()
+	e.nextHookID++
+	entry.id = e.nextHookID
+	e.hooks = append(e.hooks, entry)
+	e.hooksMu.Unlock()
+
+	return func() {
+		e.hooksMu.Lock()
+		defer e.hooksMu.Unlock()
+		for i, h := range e.hooks {
+			if h.id == entry.id {
+				e.hooks = append(e.hooks[:i], e.hooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// hasHooks reports whether any hook listens to the event type
+func (e *Engine) hasHooks(t EventType) bool {
+	if e.primary != nil {
+		return e.primary.hasHooks(t)
+	}
+
+	e.hooksMu.RLock()
+	defer e.hooksMu.RUnlock()
+	for _, h := range e.hooks {
+		if h.types == nil || h.types[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// emit passes an event to the hooks listening to its type. Pool runtimes emit through the
+// primary runtime, so embedders register their hooks once.
+func (e *Engine) emit(event Event) {
+	if e.primary != nil {
+		e.primary.emit(event)
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	e.hooksMu.RLock()
+	hooks := make([]EventHook, 0, len(e.hooks))
+	for _, h := range e.hooks {
+		if h.types == nil || h.types[event.Type] {
+			hooks = append(hooks, h.hook)
+		}
+	}
+	e.hooksMu.RUnlock()
+
+	for _, hook := range hooks {
+		callHook(hook, event)
+	}
+}
+
+func callHook(hook EventHook, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Str("event", string(event.Type)).Msg("Panic in engine event hook")
+		}
+	}()
+	hook(event)
+}
+
+// emitJobEvents raises the events of a finished job and records its metrics. Replicas running startup code in the
+// background stay silent, the primary runtime reports it.
+func (e *Engine) emitJobEvents(job EvalJob, err error, duration time.Duration) {
+	if job.Handler == nil && e.primary != nil {
+		return
+	}
+	e.recordJobMetrics(job, err, duration)
+
+	event := Event{SessionID: job.SessionID, Source: job.Source, Duration: duration, Err: err}
+	if job.Handler != nil {
+		event.Type = EventRequestHandled
+		event.Method, event.Path = job.Handler.Doc.Method, job.Handler.Doc.Path
+		if job.R != nil {
+			event.Path = job.R.URL.Path
+			if event.Method == "" {
+				event.Method = job.R.Method
+			}
+		}
+	} else {
+		event.Type = EventScriptExecuted
+	}
+	e.emit(event)
+
+	if err != nil {
+		event.Type = EventError
+		e.emit(event)
+	}
+
+	e.checkStateChanged()
+}
+
+// checkStateChanged emits EventStateChanged when globalState differs from the last snapshot.
+// Serializing the state costs time, so it is skipped while nobody listens. Only the primary
+// runtime reports, as it owns globalState; pool runtimes only have a scratch copy.
+func (e *Engine) checkStateChanged() {
+	if e.primary != nil || !e.hasHooks(EventStateChanged) {
+		return
+	}
+
+	state := e.GetGlobalState()
+	e.mu.Lock()
+	changed := state != e.lastGlobalState
+	e.lastGlobalState = state
+	e.mu.Unlock()
+
+	if changed {
+		e.emit(Event{Type: EventStateChanged, State: state})
+	}
+}
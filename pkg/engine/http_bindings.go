@@ -2,6 +2,7 @@ package engine
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dop251/goja"
 	"github.com/rs/zerolog/log"
 )
 
@@ -21,6 +23,9 @@ type HTTPRequest struct {
 	Body    interface{}            `json:"body"`
 	Query   map[string]interface{} `json:"query"`
 	Timeout int                    `json:"timeout"` // seconds
+	Retry   HTTPRetry              `json:"retry"`
+	Jar     string                 `json:"jar"`    // cookie jar name, empty to send no stored cookies
+	Stream  bool                   `json:"stream"` // return a body reader instead of the body string
 }
 
 // HTTPResponse represents a JavaScript HTTP response
@@ -35,40 +40,47 @@ type HTTPResponse struct {
 	Error      string            `json:"error,omitempty"`
 }
 
+// httpClientTimeout is the default timeout of requests made from JavaScript
+const httpClientTimeout = 30 * time.Second
+
 // setupHTTPBindings configures HTTP request bindings for the JavaScript runtime
 func (e *Engine) setupHTTPBindings() {
-	// HTTP client with default timeout
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
 	// Main fetch function (modern browser-like API)
 	if err := e.rt.Set("fetch", func(urlOrOptions interface{}, options ...interface{}) map[string]interface{} {
-		return e.jsFetch(client, urlOrOptions, options...)
+		return e.jsFetch(urlOrOptions, options...)
 	}); err != nil {
 		log.Error().Err(err).Msg("Failed to set fetch binding")
 	}
 
+	// Promise-based fetch that does not block the dispatcher while the request is in flight
+	if err := e.rt.Set("fetchAsync", func(urlOrOptions interface{}, options ...interface{}) goja.Value {
+		return e.rt.ToValue(e.jsFetchAsync(urlOrOptions, options...))
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set fetchAsync binding")
+	}
+
 	// HTTP utility object with method shortcuts
 	if err := e.rt.Set("HTTP", map[string]interface{}{
 		"get": func(url string, options ...interface{}) map[string]interface{} {
-			return e.jsHTTPMethod(client, "GET", url, options...)
+			return e.jsHTTPMethod("GET", url, options...)
 		},
 		"post": func(url string, options ...interface{}) map[string]interface{} {
-			return e.jsHTTPMethod(client, "POST", url, options...)
+			return e.jsHTTPMethod("POST", url, options...)
 		},
 		"put": func(url string, options ...interface{}) map[string]interface{} {
-			return e.jsHTTPMethod(client, "PUT", url, options...)
+			return e.jsHTTPMethod("PUT", url, options...)
 		},
 		"delete": func(url string, options ...interface{}) map[string]interface{} {
-			return e.jsHTTPMethod(client, "DELETE", url, options...)
+			return e.jsHTTPMethod("DELETE", url, options...)
 		},
 		"patch": func(url string, options ...interface{}) map[string]interface{} {
-			return e.jsHTTPMethod(client, "PATCH", url, options...)
+			return e.jsHTTPMethod("PATCH", url, options...)
 		},
 		"head": func(url string, options ...interface{}) map[string]interface{} {
-			return e.jsHTTPMethod(client, "HEAD", url, options...)
+			return e.jsHTTPMethod("HEAD", url, options...)
 		},
+		"cookies":      e.jsHTTPCookies,
+		"clearCookies": e.jsHTTPClearCookies,
 	}); err != nil {
 		log.Error().Err(err).Msg("Failed to set HTTP utility binding")
 	}
@@ -77,7 +89,40 @@ func (e *Engine) setupHTTPBindings() {
 }
 
 // jsFetch implements a fetch-like API for JavaScript
-func (e *Engine) jsFetch(client *http.Client, urlOrOptions interface{}, options ...interface{}) map[string]interface{} {
+func (e *Engine) jsFetch(urlOrOptions interface{}, options ...interface{}) map[string]interface{} {
+	req, errResponse := e.parseFetchArguments(urlOrOptions, options...)
+	if errResponse != nil {
+		return errResponse
+	}
+	return e.executeHTTPRequest(req)
+}
+
+// jsFetchAsync implements fetchAsync: it takes the arguments of fetch and returns a promise
+// of the same response object. The request runs on its own goroutine.
+func (e *Engine) jsFetchAsync(urlOrOptions interface{}, options ...interface{}) *goja.Promise {
+	// Options are parsed on the dispatcher, since cookie jar names depend on the session
+	req, errResponse := e.parseFetchArguments(urlOrOptions, options...)
+	if errResponse == nil && req.Stream {
+		errResponse = map[string]interface{}{
+			"error": "fetchAsync does not support stream, use fetch",
+			"ok":    false,
+		}
+	}
+
+	return e.startAsync(func() (interface{}, error) {
+		if errResponse != nil {
+			return errResponse, nil
+		}
+		return e.executeHTTPRequest(req), nil
+	})
+}
+
+// parseFetchArguments parses the arguments of fetch(url), fetch(url, options) and
+// fetch(options), returning an error response for invalid arguments
+func (e *Engine) parseFetchArguments(urlOrOptions interface{}, options ...interface{}) (*HTTPRequest, map[string]interface{}) {
+	if errResponse := e.checkNetwork(); errResponse != nil {
+		return nil, errResponse
+	}
 	var req HTTPRequest
 
 	// Parse arguments (fetch can be called as fetch(url) or fetch(url, options) or fetch(options))
@@ -96,17 +141,20 @@ func (e *Engine) jsFetch(client *http.Client, urlOrOptions interface{}, options
 			req.Method = "GET"
 		}
 	default:
-		return map[string]interface{}{
+		return nil, map[string]interface{}{
 			"error": "Invalid fetch arguments",
 			"ok":    false,
 		}
 	}
 
-	return e.executeHTTPRequest(client, &req)
+	return &req, nil
 }
 
 // jsHTTPMethod implements HTTP method shortcuts (HTTP.get, HTTP.post, etc.)
-func (e *Engine) jsHTTPMethod(client *http.Client, method, url string, options ...interface{}) map[string]interface{} {
+func (e *Engine) jsHTTPMethod(method, url string, options ...interface{}) map[string]interface{} {
+	if errResponse := e.checkNetwork(); errResponse != nil {
+		return errResponse
+	}
 	req := HTTPRequest{
 		URL:    url,
 		Method: method,
@@ -118,7 +166,7 @@ func (e *Engine) jsHTTPMethod(client *http.Client, method, url string, options .
 		}
 	}
 
-	return e.executeHTTPRequest(client, &req)
+	return e.executeHTTPRequest(&req)
 }
 
 // parseHTTPOptions parses JavaScript options object into HTTPRequest
@@ -141,13 +189,28 @@ func (e *Engine) parseHTTPOptions(req *HTTPRequest, options map[string]interface
 	if query, ok := options["query"].(map[string]interface{}); ok {
 		req.Query = query
 	}
-	if timeout, ok := options["timeout"].(float64); ok {
+	req.Retry = parseHTTPRetry(options)
+	if stream, ok := options["stream"].(bool); ok {
+		req.Stream = stream
+	}
+	switch jar := options["jar"].(type) {
+	case string:
+		req.Jar = jar
+	case bool:
+		if jar {
+			req.Jar = e.sessionJarName()
+		}
+	}
+	switch timeout := options["timeout"].(type) {
+	case int64:
+		req.Timeout = int(timeout)
+	case float64:
 		req.Timeout = int(timeout)
 	}
 }
 
 // executeHTTPRequest performs the actual HTTP request
-func (e *Engine) executeHTTPRequest(client *http.Client, req *HTTPRequest) map[string]interface{} {
+func (e *Engine) executeHTTPRequest(req *HTTPRequest) map[string]interface{} {
 	log.Debug().Str("method", req.Method).Str("url", req.URL).Msg("Executing HTTP request")
 
 	// Build URL with query parameters
@@ -176,13 +239,13 @@ func (e *Engine) executeHTTPRequest(client *http.Client, req *HTTPRequest) map[s
 		finalURL = u.String()
 	}
 
-	// Prepare request body
-	var bodyReader io.Reader
+	// Prepare request body; it is kept as bytes so retries can resend it
+	var bodyData []byte
 	var contentType string
 	if req.Body != nil {
 		switch body := req.Body.(type) {
 		case string:
-			bodyReader = strings.NewReader(body)
+			bodyData = []byte(body)
 			contentType = "text/plain"
 		case map[string]interface{}:
 			jsonData, err := json.Marshal(body)
@@ -192,67 +255,77 @@ func (e *Engine) executeHTTPRequest(client *http.Client, req *HTTPRequest) map[s
 					"ok":    false,
 				}
 			}
-			bodyReader = bytes.NewReader(jsonData)
+			bodyData = jsonData
 			contentType = "application/json"
 		default:
 			// Try to convert to JSON
 			jsonData, err := json.Marshal(body)
 			if err != nil {
-				bodyReader = strings.NewReader(fmt.Sprint(body))
+				bodyData = []byte(fmt.Sprint(body))
 				contentType = "text/plain"
 			} else {
-				bodyReader = bytes.NewReader(jsonData)
+				bodyData = jsonData
 				contentType = "application/json"
 			}
 		}
 	}
 
-	// Create HTTP request
-	httpReq, err := http.NewRequest(req.Method, finalURL, bodyReader)
-	if err != nil {
-		return map[string]interface{}{
-			"error": fmt.Sprintf("Request creation error: %v", err),
-			"ok":    false,
-		}
-	}
+	e.mu.RLock()
+	pool := e.httpClient
+	e.mu.RUnlock()
 
-	// Set headers
-	if contentType != "" && (req.Headers == nil || req.Headers["Content-Type"] == "") {
-		httpReq.Header.Set("Content-Type", contentType)
+	// Execute the request, retrying failed attempts of idempotent requests if asked to
+	retry := req.Retry
+	if !isIdempotentMethod(req.Method) {
+		retry.Retries = 0
 	}
-	if req.Headers != nil {
-		for k, v := range req.Headers {
-			httpReq.Header.Set(k, v)
+
+	var attempts []map[string]interface{}
+	var resp *http.Response
+	var bodyBytes []byte
+	var stream *httpStream
+	var err error
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		if req.Stream {
+			var done context.CancelFunc
+			resp, done, err = e.openHTTPRequest(pool, req, finalURL, bodyData, contentType)
+			if err == nil {
+				stream = newHTTPStream(resp, done)
+			}
+		} else {
+			resp, bodyBytes, err = e.sendHTTPRequest(pool, req, finalURL, bodyData, contentType)
 		}
-	}
 
-	// Set timeout if specified
-	if req.Timeout > 0 {
-		client = &http.Client{
-			Timeout: time.Duration(req.Timeout) * time.Second,
+		record := map[string]interface{}{
+			"attempt":    attempt + 1,
+			"durationMs": time.Since(start).Milliseconds(),
 		}
-	}
+		if err != nil {
+			record["error"] = err.Error()
+		} else {
+			record["status"] = resp.StatusCode
+		}
+		attempts = append(attempts, record)
 
-	// Execute request
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		log.Error().Err(err).Str("url", finalURL).Msg("HTTP request failed")
-		return map[string]interface{}{
-			"error": fmt.Sprintf("Request failed: %v", err),
-			"ok":    false,
-			"url":   finalURL,
+		if attempt >= retry.Retries || !retry.shouldRetry(resp, err) {
+			break
 		}
+		if stream != nil {
+			stream.close()
+		}
+		delay := retry.delay(attempt)
+		log.Debug().Err(err).Str("url", finalURL).Int("attempt", attempt+1).Dur("delay", delay).Msg("Retrying HTTP request")
+		time.Sleep(delay)
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to read response body")
+		log.Error().Err(err).Str("url", finalURL).Msg("HTTP request failed")
 		return map[string]interface{}{
-			"error": fmt.Sprintf("Failed to read response: %v", err),
-			"ok":    false,
-			"url":   finalURL,
+			"error":    err.Error(),
+			"ok":       false,
+			"url":      finalURL,
+			"attempts": attempts,
 		}
 	}
 
@@ -264,16 +337,23 @@ func (e *Engine) executeHTTPRequest(client *http.Client, req *HTTPRequest) map[s
 		}
 	}
 
-	bodyStr := string(bodyBytes)
 	response := map[string]interface{}{
 		"status":     resp.StatusCode,
 		"statusText": resp.Status,
 		"headers":    headers,
-		"body":       bodyStr,
 		"ok":         resp.StatusCode >= 200 && resp.StatusCode < 300,
 		"url":        finalURL,
+		"attempts":   attempts,
 	}
 
+	if stream != nil {
+		e.trackStream(stream)
+		response["reader"] = e.streamObject(stream)
+		log.Debug().Int("status", resp.StatusCode).Str("url", finalURL).Msg("HTTP response stream opened")
+		return response
+	}
+	response["body"] = string(bodyBytes)
+
 	// Try to parse JSON if content type suggests it
 	contentType = resp.Header.Get("Content-Type")
 	if strings.Contains(contentType, "application/json") || strings.Contains(contentType, "text/json") {
@@ -283,6 +363,56 @@ func (e *Engine) executeHTTPRequest(client *http.Client, req *HTTPRequest) map[s
 		}
 	}
 
-	log.Debug().Int("status", resp.StatusCode).Str("url", finalURL).Msg("HTTP request completed")
+	log.Debug().Int("status", resp.StatusCode).Str("url", finalURL).Int("attempts", len(attempts)).Msg("HTTP request completed")
 	return response
 }
+
+// sendHTTPRequest performs a single attempt of a request and reads the whole response body
+func (e *Engine) sendHTTPRequest(pool *httpClientPool, req *HTTPRequest, finalURL string, bodyData []byte, contentType string) (*http.Response, []byte, error) {
+	resp, done, err := e.openHTTPRequest(pool, req, finalURL, bodyData, contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp, bodyBytes, nil
+}
+
+// openHTTPRequest performs a single attempt of a request without reading the response body.
+// The caller must close the body and then call the returned function.
+func (e *Engine) openHTTPRequest(pool *httpClientPool, req *HTTPRequest, finalURL string, bodyData []byte, contentType string) (*http.Response, context.CancelFunc, error) {
+	var bodyReader io.Reader
+	if bodyData != nil {
+		bodyReader = bytes.NewReader(bodyData)
+	}
+
+	httpReq, err := http.NewRequest(req.Method, finalURL, bodyReader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request creation error: %w", err)
+	}
+
+	// Set headers
+	if contentType != "" && (req.Headers == nil || req.Headers["Content-Type"] == "") {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	// Execute request through the shared pool, with the per-request timeout if specified.
+	// Streams are read for as long as the script wants, so they only time out when asked to.
+	timeout := time.Duration(req.Timeout) * time.Second
+	if req.Stream && timeout == 0 {
+		timeout = noTimeout
+	}
+	resp, done, err := pool.do(httpReq, timeout, req.Jar)
+	if err != nil {
+		return nil, nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, done, nil
+}
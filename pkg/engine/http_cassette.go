@@ -0,0 +1,345 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Cassettes
+//
+// A cassette records the responses to the outbound requests of fetch(), HTTP.*, graphql and
+// proxy() in the system database and replays them later, so demos and tests run without
+// network access or API keys. Requests are matched by method, URL and body; headers are not
+// part of the match, so a recording made with an API key replays without one. The values of
+// query parameters carrying credentials are neither matched nor stored. A request made more
+// often than recorded gets its responses in the recorded order, then the last one again.
+
+// Cassette modes of HTTPClientConfig.CassetteMode
+const (
+	CassetteRecord = "record" // Send requests and record the responses, replacing the previous recording
+	CassetteReplay = "replay" // Answer from the recording only, requests without a recorded response fail
+	CassetteAuto   = "auto"   // Replay recorded responses, send and record the other requests
+)
+
+// errNoCassetteStore is returned when the engine has no system database to keep cassettes in
+var errNoCassetteStore = errors.New("cassettes need the system database")
+
+// credentialParams are query parameters whose values are replaced in the recorded URLs
+var credentialParams = map[string]bool{
+	"key": true, "api_key": true, "apikey": true, "api-key": true, "access_token": true,
+	"token": true, "secret": true, "client_secret": true, "sig": true, "signature": true,
+}
+
+// cassette replays and records the responses of the HTTP client pool
+type cassette struct {
+	name string
+	mode string
+	repo repository.CassetteRepository
+
+	mu        sync.Mutex
+	responses map[string][]repository.CassetteInteraction // Request key -> responses in recorded order
+	played    map[string]int                              // Request key -> responses replayed so far
+}
+
+// newCassette opens a cassette of the system database; recording starts it over
+func newCassette(repo repository.CassetteRepository, name, mode string) (*cassette, error) {
+	if mode == "" {
+		mode = CassetteAuto
+	}
+	switch mode {
+	case CassetteRecord, CassetteReplay, CassetteAuto:
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q, expected %s, %s or %s", mode, CassetteRecord, CassetteReplay, CassetteAuto)
+	}
+
+	c := &cassette{name: name, mode: mode, repo: repo}
+	if mode == CassetteRecord {
+		if err := repo.ReplaceCassette(context.Background(), name, nil); err != nil {
+			return nil, fmt.Errorf("failed to clear cassette %s: %w", name, err)
+		}
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	log.Info().Str("cassette", name).Str("mode", mode).Msg("Outbound requests use a cassette")
+	return c, nil
+}
+
+// load reads the recorded responses and starts replaying them from the first
+func (c *cassette) load() error {
+	interactions, err := c.repo.ListInteractions(context.Background(), c.name)
+	if err != nil {
+		return fmt.Errorf("failed to load cassette %s: %w", c.name, err)
+	}
+	responses := make(map[string][]repository.CassetteInteraction)
+	for _, interaction := range interactions {
+		responses[interaction.RequestKey] = append(responses[interaction.RequestKey], interaction)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = responses
+	c.played = make(map[string]int)
+	return nil
+}
+
+// replay returns the next recorded response to the request with the key, nil if there is
+// none to replay and the request has to be sent
+func (c *cassette) replay(key string, req *http.Request) *http.Response {
+	if c.mode == CassetteRecord {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	responses := c.responses[key]
+	n := c.played[key]
+	if n < len(responses) {
+		c.played[key] = n + 1
+	} else if c.mode == CassetteReplay && len(responses) > 0 {
+		n = len(responses) - 1
+	} else {
+		return nil
+	}
+
+	interaction := responses[n]
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode:    interaction.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header(interaction.Headers).Clone(),
+		Body:          io.NopCloser(bytes.NewReader(interaction.Body)),
+		ContentLength: int64(len(interaction.Body)),
+		Request:       req,
+	}
+}
+
+// record saves the response to the request with the key once its body has been read
+func (c *cassette) record(key string, req *http.Request, resp *http.Response) {
+	resp.Body = &recordingBody{ReadCloser: resp.Body, save: func(body []byte) {
+		saved, err := c.repo.SaveInteraction(context.Background(), repository.CassetteInteraction{
+			Cassette:   c.name,
+			RequestKey: key,
+			Method:     req.Method,
+			URL:        redactCredentials(req.URL),
+			Status:     resp.StatusCode,
+			Headers:    resp.Header,
+			Body:       body,
+		})
+		if err != nil {
+			log.Error().Err(err).Str("cassette", c.name).Str("request", key).Msg("Failed to record response")
+			return
+		}
+
+		// In auto mode the next identical request is sent and recorded as well
+		c.mu.Lock()
+		c.responses[key] = append(c.responses[key], *saved)
+		c.played[key]++
+		c.mu.Unlock()
+		log.Debug().Str("cassette", c.name).Str("request", key).Int("status", saved.Status).Msg("Recorded response")
+	}}
+}
+
+// recordingBody keeps what is read from a response body and saves it once the body is read
+// to the end or closed. Bodies that failed to read are not saved; a stream closed early is
+// saved with what was read.
+type recordingBody struct {
+	io.ReadCloser
+	body   bytes.Buffer
+	failed bool
+	once   sync.Once
+	save   func(body []byte)
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.body.Write(p[:n])
+	switch {
+	case err == io.EOF:
+		b.finish()
+	case err != nil:
+		b.failed = true
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *recordingBody) finish() {
+	b.once.Do(func() {
+		if !b.failed {
+			b.save(b.body.Bytes())
+		}
+	})
+}
+
+// cassetteKey returns what a request is matched by: its method, its URL without credentials
+// and the hash of its body, if it has one
+func cassetteKey(req *http.Request) (string, error) {
+	key := req.Method + " " + redactCredentials(req.URL)
+
+	var body []byte
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody != nil:
+		reader, err := req.GetBody()
+		if err != nil {
+			return "", fmt.Errorf("failed to read request body: %w", err)
+		}
+		defer func() {
+			_ = reader.Close()
+		}()
+		if body, err = io.ReadAll(reader); err != nil {
+			return "", fmt.Errorf("failed to read request body: %w", err)
+		}
+	default:
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			return "", fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(data))
+		body = data
+	}
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		key += " sha256:" + hex.EncodeToString(sum[:])
+	}
+	return key, nil
+}
+
+// redactCredentials returns the URL with the values of credential query parameters replaced
+// and the query parameters sorted
+func redactCredentials(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.Fragment = ""
+	if u.RawQuery != "" {
+		values := u.Query()
+		for name := range values {
+			if credentialParams[strings.ToLower(name)] {
+				values[name] = []string{"REDACTED"}
+			}
+		}
+		redacted.RawQuery = values.Encode()
+	}
+	return redacted.String()
+}
+
+// setCassette opens the cassette of the config for the pool
+func (e *Engine) setCassette(pool *httpClientPool, config HTTPClientConfig) error {
+	if config.Cassette == "" {
+		return nil
+	}
+	if e.repos == nil {
+		return errNoCassetteStore
+	}
+	c, err := newCassette(e.repos.Cassettes(), config.Cassette, config.CassetteMode)
+	if err != nil {
+		return err
+	}
+	pool.cassette = c
+	return nil
+}
+
+// ActiveCassette returns the name and mode of the cassette outbound requests use, "" if none
+func (e *Engine) ActiveCassette() (string, string) {
+	e.mu.RLock()
+	pool := e.httpClient
+	e.mu.RUnlock()
+	if pool == nil || pool.cassette == nil {
+		return "", ""
+	}
+	return pool.cassette.name, pool.cassette.mode
+}
+
+// Cassettes returns the cassettes of the system database, ordered by name
+func (e *Engine) Cassettes() ([]repository.CassetteSummary, error) {
+	if e.repos == nil {
+		return nil, errNoCassetteStore
+	}
+	return e.repos.Cassettes().ListCassettes(context.Background())
+}
+
+// CassetteInteractions returns the recorded responses of a cassette, in recorded order
+func (e *Engine) CassetteInteractions(name string) ([]repository.CassetteInteraction, error) {
+	if e.repos == nil {
+		return nil, errNoCassetteStore
+	}
+	return e.repos.Cassettes().ListInteractions(context.Background(), name)
+}
+
+// ImportCassette replaces the responses of a cassette, such as with a fixture exported from
+// another server. Responses without a request key are matched by their method and URL, as
+// requests without a body. The cassette in use replays the new responses from the first.
+func (e *Engine) ImportCassette(name string, interactions []repository.CassetteInteraction) error {
+	if name == "" {
+		return fmt.Errorf("a cassette needs a name")
+	}
+	if e.repos == nil {
+		return errNoCassetteStore
+	}
+	for i := range interactions {
+		interaction := &interactions[i]
+		interaction.Method = strings.ToUpper(interaction.Method)
+		if interaction.Method == "" {
+			interaction.Method = http.MethodGet
+		}
+		if interaction.Status == 0 {
+			interaction.Status = http.StatusOK
+		}
+		if interaction.RequestKey == "" {
+			u, err := url.Parse(interaction.URL)
+			if err != nil {
+				return fmt.Errorf("invalid URL of response %d: %w", i+1, err)
+			}
+			interaction.URL = redactCredentials(u)
+			interaction.RequestKey = interaction.Method + " " + interaction.URL
+		}
+	}
+	if err := e.repos.Cassettes().ReplaceCassette(context.Background(), name, interactions); err != nil {
+		return err
+	}
+	log.Info().Str("cassette", name).Int("interactions", len(interactions)).Msg("Cassette imported")
+	return e.reloadCassette(name)
+}
+
+// DeleteCassette removes a cassette and its responses
+func (e *Engine) DeleteCassette(name string) error {
+	if e.repos == nil {
+		return errNoCassetteStore
+	}
+	if err := e.repos.Cassettes().DeleteCassette(context.Background(), name); err != nil {
+		return err
+	}
+	log.Info().Str("cassette", name).Msg("Cassette deleted")
+	return e.reloadCassette(name)
+}
+
+// reloadCassette reads the responses of the cassette in use again after it was changed
+func (e *Engine) reloadCassette(name string) error {
+	e.mu.RLock()
+	pool := e.httpClient
+	e.mu.RUnlock()
+	if pool == nil || pool.cassette == nil || pool.cassette.name != name {
+		return nil
+	}
+	return pool.cassette.load()
+}
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// HTTPClientConfig configures the shared HTTP client used by fetch() and HTTP.*
+type HTTPClientConfig struct {
+	Timeout         time.Duration // Default request timeout, overridable per request
+	MaxPerHost      int           // Concurrent requests per host; 0 means unlimited
+	MaxIdleConns    int           // Idle keep-alive connections across all hosts
+	IdleConnTimeout time.Duration // How long idle connections stay in the pool
+	Proxy           string        // Proxy URL; empty uses HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	Network         NetworkPolicy // Hosts requests may be sent to
+	Cassette        string        // Cassette of the system database responses are recorded to and replayed from, "" for none
+	CassetteMode    string        // CassetteRecord, CassetteReplay or CassetteAuto (the default)
+}
+
+// DefaultHTTPClientConfig returns the configuration used when none is set
+func DefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		Timeout:         httpClientTimeout,
+		MaxPerHost:      8,
+		MaxIdleConns:    100,
+		IdleConnTimeout: 90 * time.Second,
+	}
+}
+
+// httpClientPool shares one pooled transport between all requests made from JavaScript
+// and limits how many requests run against the same host at once.
+type httpClientPool struct {
+	config    HTTPClientConfig
+	transport *http.Transport
+	client    *http.Client
+	rules     *networkRules // Network policy, nil if every host may be reached
+	cassette  *cassette     // Recorded responses, nil if requests always go out
+
+	mu      sync.Mutex
+	hosts   map[string]chan struct{} // host -> semaphore
+	clients map[string]*http.Client  // cookie jar name -> client using that jar
+}
+
+func newHTTPClientPool(config HTTPClientConfig) (*httpClientPool, error) {
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", config.Proxy, err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	rules, err := config.Network.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	idlePerHost := config.MaxPerHost
+	if idlePerHost <= 0 {
+		idlePerHost = http.DefaultMaxIdleConnsPerHost
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	dialContext := dialer.DialContext
+	if rules != nil {
+		dialContext = (&policyDialer{dialer: dialer, rules: rules, proxies: proxyAddrs(config.Proxy)}).DialContext
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   idlePerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	pool := &httpClientPool{
+		config:    config,
+		transport: transport,
+		rules:     rules,
+		hosts:     make(map[string]chan struct{}),
+		clients:   make(map[string]*http.Client),
+	}
+	// Timeouts are applied per request through the context so the client can be shared
+	pool.client = &http.Client{Transport: transport, CheckRedirect: pool.checkRedirect}
+	return pool, nil
+}
+
+// jarClient returns the client that keeps cookies in the named jar, creating both on first use.
+// All clients share the pooled transport.
+func (p *httpClientPool) jarClient(name string) *http.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if client, ok := p.clients[name]; ok {
+		return client
+	}
+	// cookiejar.New only fails for invalid options
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Transport: p.transport, Jar: jar, CheckRedirect: p.checkRedirect}
+	p.clients[name] = client
+	return client
+}
+
+// cookies returns the cookies of the named jar that would be sent to the URL
+func (p *httpClientPool) cookies(name string, u *url.URL) []*http.Cookie {
+	p.mu.Lock()
+	client, ok := p.clients[name]
+	p.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return client.Jar.Cookies(u)
+}
+
+// clearJar drops the named jar and its cookies
+func (p *httpClientPool) clearJar(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.clients, name)
+}
+
+// acquire waits for a free slot for the host of the request; the returned function releases it
+func (p *httpClientPool) acquire(ctx context.Context, host string) (func(), error) {
+	if p.config.MaxPerHost <= 0 {
+		return func() {}, nil
+	}
+
+	p.mu.Lock()
+	sem, ok := p.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, p.config.MaxPerHost)
+		p.hosts[host] = sem
+	}
+	p.mu.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a connection slot to %s: %w", host, ctx.Err())
+	}
+}
+
+// noTimeout disables the request timeout of httpClientPool.do
+const noTimeout time.Duration = -1
+
+// do sends the request with the given timeout (or the default one) while holding a slot for its host.
+// Cookies are read from and stored in the named jar unless jar is empty. The returned cancel
+// function must be called once the response body has been read.
+func (p *httpClientPool) do(req *http.Request, timeout time.Duration, jar string) (*http.Response, context.CancelFunc, error) {
+	if err := p.checkRequest(req); err != nil {
+		return nil, nil, err
+	}
+
+	var recordKey string
+	if p.cassette != nil {
+		key, err := cassetteKey(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		if resp := p.cassette.replay(key, req); resp != nil {
+			if jar != "" {
+				p.jarClient(jar).Jar.SetCookies(req.URL, resp.Cookies())
+			}
+			return resp, func() {}, nil
+		}
+		if p.cassette.mode == CassetteReplay {
+			return nil, nil, fmt.Errorf("cassette %s has no response recorded for %s", p.cassette.name, key)
+		}
+		recordKey = key
+	}
+
+	if timeout == 0 {
+		timeout = p.config.Timeout
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(req.Context(), timeout)
+	}
+
+	release, err := p.acquire(ctx, req.URL.Host)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	client := p.client
+	if jar != "" {
+		client = p.jarClient(jar)
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		release()
+		cancel()
+		return nil, nil, err
+	}
+	if recordKey != "" {
+		p.cassette.record(recordKey, req, resp)
+	}
+
+	return resp, func() {
+		release()
+		cancel()
+	}, nil
+}
+
+// closeIdle closes the idle connections of the pool
+func (p *httpClientPool) closeIdle() {
+	p.client.CloseIdleConnections()
+}
+
+// SetHTTPClientConfig replaces the HTTP client used by fetch() and HTTP.*. It must be called
+// before the dispatcher starts running JavaScript. A cassette in record mode is cleared.
+func (e *Engine) SetHTTPClientConfig(config HTTPClientConfig) error {
+	pool, err := newHTTPClientPool(config)
+	if err != nil {
+		return err
+	}
+	if err := e.setCassette(pool, config); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	previous := e.httpClient
+	e.httpClient = pool
+	e.mu.Unlock()
+
+	for _, replica := range e.replicas {
+		replica.mu.Lock()
+		replica.httpClient = pool
+		replica.mu.Unlock()
+	}
+
+	if previous != nil {
+		previous.closeIdle()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"net/url"
+)
+
+// defaultCookieJar is used by {jar: true} outside of a script session, e.g. in route handlers
+const defaultCookieJar = "default"
+
+// sessionJarName returns the jar used by {jar: true}: one jar per script session, so the
+// requests of a multi-step flow share cookies without leaking them into other sessions
+func (e *Engine) sessionJarName() string {
+	if e.currentSession != "" {
+		return "session:" + e.currentSession
+	}
+	return defaultCookieJar
+}
+
+// jarName resolves the optional jar argument of HTTP.cookies and HTTP.clearCookies
+func (e *Engine) jarName(args []interface{}) string {
+	if len(args) > 0 {
+		if name, ok := args[0].(string); ok && name != "" {
+			return name
+		}
+	}
+	return e.sessionJarName()
+}
+
+// jsHTTPCookies implements HTTP.cookies(url, [jar]), listing the cookies a jar would send to url
+func (e *Engine) jsHTTPCookies(rawURL string, args ...interface{}) []map[string]interface{} {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+
+	e.mu.RLock()
+	pool := e.httpClient
+	e.mu.RUnlock()
+
+	cookies := make([]map[string]interface{}, 0)
+	for _, cookie := range pool.cookies(e.jarName(args), u) {
+		cookies = append(cookies, map[string]interface{}{
+			"name":  cookie.Name,
+			"value": cookie.Value,
+		})
+	}
+	return cookies
+}
+
+// jsHTTPClearCookies implements HTTP.clearCookies([jar]), dropping every cookie of a jar
+func (e *Engine) jsHTTPClearCookies(args ...interface{}) {
+	e.mu.RLock()
+	pool := e.httpClient
+	e.mu.RUnlock()
+
+	pool.clearJar(e.jarName(args))
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultRetryBackoff = 200 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
+	maxRetries          = 10
+)
+
+// defaultRetryOn are the status codes retried when retryOn is not given
+var defaultRetryOn = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// HTTPRetry configures automatic retries of fetch() and HTTP.* requests:
+//
+//	fetch(url, {retries: 3, backoff: 500, retryOn: [429, 503]})
+//
+// Network errors are always retried; responses are retried when their status is in RetryOn.
+// Only idempotent methods are retried.
+type HTTPRetry struct {
+	Retries int           `json:"retries"` // Additional attempts after the first one
+	Backoff time.Duration `json:"backoff"` // Base delay, doubled after every attempt
+	RetryOn []int         `json:"retryOn"` // Status codes that trigger a retry
+}
+
+// parseHTTPRetry reads the retries, backoff (milliseconds) and retryOn options
+func parseHTTPRetry(options map[string]interface{}) HTTPRetry {
+	retry := HTTPRetry{
+		Backoff: defaultRetryBackoff,
+		RetryOn: defaultRetryOn,
+	}
+
+	if retries, ok := numberOption(options["retries"]); ok {
+		retry.Retries = int(retries)
+	}
+	if retry.Retries > maxRetries {
+		retry.Retries = maxRetries
+	}
+	if backoff, ok := numberOption(options["backoff"]); ok && backoff >= 0 {
+		retry.Backoff = time.Duration(backoff * float64(time.Millisecond))
+	}
+	if retryOn, ok := options["retryOn"].([]interface{}); ok {
+		retry.RetryOn = nil
+		for _, code := range retryOn {
+			if status, ok := numberOption(code); ok {
+				retry.RetryOn = append(retry.RetryOn, int(status))
+			}
+		}
+	}
+
+	return retry
+}
+
+// numberOption converts a JavaScript number, exported as int64 or float64, to a float64
+func numberOption(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// shouldRetry reports whether an attempt failed in a way that is worth retrying
+func (r HTTPRetry) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	for _, status := range r.RetryOn {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the exponential backoff before the next attempt, with jitter so that
+// concurrent clients do not retry in lockstep
+func (r HTTPRetry) delay(attempt int) time.Duration {
+	backoff := r.Backoff << attempt
+	if backoff > maxRetryBackoff || backoff <= 0 {
+		backoff = maxRetryBackoff
+	}
+	if r.Backoff == 0 {
+		return 0
+	}
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isIdempotentMethod reports whether repeating a request with this method is safe
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultStreamChunkSize is the number of bytes read() and onData() return at most per chunk
+const defaultStreamChunkSize = 32 * 1024
+
+// httpStream is the body reader returned by fetch(url, {stream: true})
+type httpStream struct {
+	resp   *http.Response
+	reader *bufio.Reader
+	done   context.CancelFunc
+
+	once   sync.Once
+	closed bool
+}
+
+func newHTTPStream(resp *http.Response, done context.CancelFunc) *httpStream {
+	return &httpStream{
+		resp:   resp,
+		reader: bufio.NewReaderSize(resp.Body, defaultStreamChunkSize),
+		done:   done,
+	}
+}
+
+// close releases the connection; it is safe to call more than once
+func (s *httpStream) close() {
+	s.once.Do(func() {
+		s.closed = true
+		if err := s.resp.Body.Close(); err != nil {
+			log.Debug().Err(err).Msg("Failed to close streamed response body")
+		}
+		s.done()
+	})
+}
+
+// read returns the next chunk of at most size bytes, or nil at the end of the body
+func (s *httpStream) read(size int) (*string, error) {
+	if s.closed {
+		return nil, nil
+	}
+	if size <= 0 {
+		size = defaultStreamChunkSize
+	}
+
+	buf := make([]byte, size)
+	n, err := s.reader.Read(buf)
+	if n > 0 {
+		chunk := string(buf[:n])
+		return &chunk, nil
+	}
+	if err != nil {
+		s.close()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return s.read(size)
+}
+
+// readLine returns the next line without its line ending, or nil at the end of the body
+func (s *httpStream) readLine() (*string, error) {
+	if s.closed {
+		return nil, nil
+	}
+
+	line, err := s.reader.ReadString('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.close()
+			return nil, err
+		}
+		s.close()
+		if line == "" {
+			return nil, nil
+		}
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	return &line, nil
+}
+
+// streamObject exposes the stream to JavaScript:
+//
+//	const res = fetch(url, {stream: true});
+//	let chunk;
+//	while ((chunk = res.reader.read()) !== null) { ... }
+//	res.reader.onData(chunk => { ...; return false /* stop */ });
+//	res.reader.onLine(line => { ... });   // e.g. for server-sent events
+func (e *Engine) streamObject(s *httpStream) map[string]interface{} {
+	throw := func(err error) {
+		panic(e.rt.NewGoError(err))
+	}
+
+	// each calls fn with every item produced by next until the body ends or fn returns false
+	each := func(next func() (*string, error), fn goja.Callable) int {
+		count := 0
+		for {
+			item, err := next()
+			if err != nil {
+				throw(err)
+			}
+			if item == nil {
+				return count
+			}
+			count++
+			result, err := fn(goja.Undefined(), e.rt.ToValue(*item))
+			if err != nil {
+				s.close()
+				panic(err)
+			}
+			if result != nil && result.StrictEquals(e.rt.ToValue(false)) {
+				s.close()
+				return count
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"read": func(size ...int) goja.Value {
+			chunkSize := 0
+			if len(size) > 0 {
+				chunkSize = size[0]
+			}
+			chunk, err := s.read(chunkSize)
+			if err != nil {
+				throw(err)
+			}
+			if chunk == nil {
+				return goja.Null()
+			}
+			return e.rt.ToValue(*chunk)
+		},
+		"readLine": func() goja.Value {
+			line, err := s.readLine()
+			if err != nil {
+				throw(err)
+			}
+			if line == nil {
+				return goja.Null()
+			}
+			return e.rt.ToValue(*line)
+		},
+		"onData": func(fn goja.Callable) int {
+			return each(func() (*string, error) { return s.read(0) }, fn)
+		},
+		"onLine": func(fn goja.Callable) int {
+			return each(s.readLine, fn)
+		},
+		"close": s.close,
+	}
+}
+
+// trackStream remembers a stream so it can be closed when the current job finishes
+func (e *Engine) trackStream(s *httpStream) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.openStreams = append(e.openStreams, s)
+}
+
+// closeStreams closes the streams opened by the job that just finished, so connections
+// and per-host slots are not leaked by scripts that never read to the end
+func (e *Engine) closeStreams() {
+	e.mu.Lock()
+	streams := e.openStreams
+	e.openStreams = nil
+	e.mu.Unlock()
+
+	for _, s := range streams {
+		s.close()
+	}
+}
@@ -0,0 +1,446 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// Message catalogs
+//
+// Catalogs are read from a locales directory with one JSON or YAML file per locale, named
+// by its tag (en.json, de.yaml, fr-CA.json). Nested objects become dotted keys, and an
+// object of plural forms (zero, one, two, few, many, other) picks the form of vars.count:
+//
+//	{ "greeting": "Hello {name}!",
+//	  "cart": { "items": { "zero": "Your cart is empty", "one": "{count} item", "other": "{count} items" } } }
+//
+// t(key, [vars], [locale]) translates a key, falling back from fr-CA to fr, then to the
+// default locale, then to the key itself. i18n.middleware() negotiates the locale of a
+// request from the lang query parameter, the lang cookie and Accept-Language:
+//
+//	app.get('/', i18n.middleware(), (req, res) => res.send(t('greeting', { name: 'Ada' })))
+//	app.get('/cart', i18n.middleware(), (req, res) => res.send(req.t('cart.items', { count: 3 })))
+//
+// The middleware sets req.locale, res.locals.locale and res.locals.t, and t() uses the
+// negotiated locale until the handler returns; code running after an await uses req.t().
+
+// DefaultI18nLocale is the locale of t() when none is configured
+const DefaultI18nLocale = "en"
+
+// pluralForms names the plural forms catalogs choose between
+var pluralForms = map[plural.Form]string{
+	plural.Other: "other",
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+}
+
+// messagePlaceholder matches the {name} placeholders of messages
+var messagePlaceholder = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+// localeCatalogs holds the messages of every locale, see SetLocalesDir
+type localeCatalogs struct {
+	defaultLocale string
+	messages      map[string]map[string]interface{} // [locale][key] -> string or map[string]string of plural forms
+	locales       []string                          // Locales with a catalog, the default first
+	matcher       language.Matcher                  // Matches Accept-Language against locales
+}
+
+// i18nMiddlewareOptions is the options object of i18n.middleware(), false turning a source off
+type i18nMiddlewareOptions struct {
+	Param  string // Query parameter naming the locale, "lang" by default
+	Cookie string // Cookie naming the locale, "lang" by default
+}
+
+// LoadLocales reads the message catalogs of a locales directory, by locale
+func LoadLocales(dir string) (map[string]map[string]interface{}, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read locales directory: %w", err)
+	}
+	catalogs := make(map[string]map[string]interface{})
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		tag, err := language.Parse(strings.TrimSuffix(entry.Name(), ext))
+		if err != nil {
+			return nil, fmt.Errorf("locale file %s is not named by a locale: %w", entry.Name(), err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read locale file: %w", err)
+		}
+		var raw map[string]interface{}
+		if ext == ".json" {
+			err = json.Unmarshal(data, &raw)
+		} else {
+			err = yaml.Unmarshal(data, &raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse locale file %s: %w", entry.Name(), err)
+		}
+		locale := tag.String()
+		if catalogs[locale] == nil {
+			catalogs[locale] = make(map[string]interface{})
+		}
+		flattenMessages(catalogs[locale], "", raw)
+	}
+	return catalogs, nil
+}
+
+// flattenMessages adds the messages of a catalog object under dotted keys
+func flattenMessages(messages map[string]interface{}, prefix string, raw map[string]interface{}) {
+	for name, value := range raw {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch v := value.(type) {
+		case string:
+			messages[key] = v
+		case map[string]interface{}:
+			if forms, ok := pluralMessage(v); ok {
+				messages[key] = forms
+			} else {
+				flattenMessages(messages, key, v)
+			}
+		case nil:
+		default:
+			messages[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// pluralMessage returns the forms of an object holding only plural forms with an other form
+func pluralMessage(raw map[string]interface{}) (map[string]string, bool) {
+	if _, ok := raw["other"].(string); !ok {
+		return nil, false
+	}
+	forms := make(map[string]string, len(raw))
+	for name, value := range raw {
+		text, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		switch name {
+		case "zero", "one", "two", "few", "many", "other":
+			forms[name] = text
+		default:
+			return nil, false
+		}
+	}
+	return forms, true
+}
+
+// SetLocalesDir loads the message catalogs of t() from a directory, translating into
+// defaultLocale when no locale is negotiated. A missing directory leaves t() without
+// catalogs, answering keys as they are.
+func (e *Engine) SetLocalesDir(dir, defaultLocale string) error {
+	if defaultLocale == "" {
+		defaultLocale = DefaultI18nLocale
+	}
+	tag, err := language.Parse(defaultLocale)
+	if err != nil {
+		return fmt.Errorf("invalid default locale %q: %w", defaultLocale, err)
+	}
+	messages := map[string]map[string]interface{}{}
+	if dir != "" {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			if messages, err = LoadLocales(dir); err != nil {
+				return err
+			}
+		} else if !os.IsNotExist(statErr) {
+			return fmt.Errorf("failed to read locales directory: %w", statErr)
+		}
+	}
+
+	catalogs := &localeCatalogs{defaultLocale: tag.String(), messages: messages}
+	catalogs.locales = append(catalogs.locales, catalogs.defaultLocale)
+	others := make([]string, 0, len(messages))
+	for locale := range messages {
+		if locale != catalogs.defaultLocale {
+			others = append(others, locale)
+		}
+	}
+	sort.Strings(others)
+	catalogs.locales = append(catalogs.locales, others...)
+	tags := make([]language.Tag, len(catalogs.locales))
+	for i, locale := range catalogs.locales {
+		tags[i] = language.Make(locale)
+	}
+	catalogs.matcher = language.NewMatcher(tags)
+
+	root := e.root()
+	root.mu.Lock()
+	root.locales = catalogs
+	root.mu.Unlock()
+	if len(messages) > 0 {
+		log.Info().Str("directory", dir).Strs("locales", catalogs.locales).Msg("Message catalogs loaded")
+	}
+	return nil
+}
+
+// Locales returns the locales t() has catalogs for, the default locale first
+func (e *Engine) Locales() []string {
+	catalogs := e.localeCatalogs()
+	return append([]string(nil), catalogs.locales...)
+}
+
+// localeCatalogs returns the catalogs set with SetLocalesDir, empty ones if none were
+func (e *Engine) localeCatalogs() *localeCatalogs {
+	root := e.root()
+	root.mu.RLock()
+	catalogs := root.locales
+	root.mu.RUnlock()
+	if catalogs == nil {
+		return &localeCatalogs{
+			defaultLocale: DefaultI18nLocale,
+			locales:       []string{DefaultI18nLocale},
+			matcher:       language.NewMatcher([]language.Tag{language.Make(DefaultI18nLocale)}),
+		}
+	}
+	return catalogs
+}
+
+// Translate returns the message of key in locale with the placeholders of vars filled in
+func (e *Engine) Translate(locale, key string, vars map[string]interface{}) string {
+	catalogs := e.localeCatalogs()
+	if locale == "" {
+		locale = catalogs.defaultLocale
+	}
+	message, tag := catalogs.lookup(locale, key)
+	switch m := message.(type) {
+	case string:
+		return interpolateMessage(m, vars)
+	case map[string]string:
+		return interpolateMessage(pluralForm(m, tag, vars["count"]), vars)
+	}
+	return key
+}
+
+// lookup finds the message of key for locale, then its parent locales, then the default
+// locale, returning the locale it was found in
+func (c *localeCatalogs) lookup(locale, key string) (interface{}, language.Tag) {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		tag = language.Make(c.defaultLocale)
+	}
+	for _, start := range []language.Tag{tag, language.Make(c.defaultLocale)} {
+		for t := start; ; t = t.Parent() {
+			if message, ok := c.messages[t.String()][key]; ok {
+				return message, t
+			}
+			if t.IsRoot() {
+				break
+			}
+		}
+	}
+	return nil, tag
+}
+
+// pluralForm picks the plural form of count, an explicit zero form winning for 0
+func pluralForm(forms map[string]string, tag language.Tag, count interface{}) string {
+	n, ok := count.(float64)
+	if i, isInt := count.(int64); isInt {
+		n, ok = float64(i), true
+	}
+	if !ok {
+		return forms["other"]
+	}
+	if n == 0 {
+		if form, ok := forms["zero"]; ok {
+			return form
+		}
+	}
+	form := plural.Other
+	if n == math.Trunc(n) && math.Abs(n) < math.MaxInt32 {
+		form = plural.Cardinal.MatchPlural(tag, int(math.Abs(n)), 0, 0, 0, 0)
+	}
+	if message, ok := forms[pluralForms[form]]; ok {
+		return message
+	}
+	return forms["other"]
+}
+
+// interpolateMessage fills in the {name} placeholders of vars, leaving unknown ones as is
+func interpolateMessage(message string, vars map[string]interface{}) string {
+	if len(vars) == 0 || !strings.Contains(message, "{") {
+		return message
+	}
+	return messagePlaceholder.ReplaceAllStringFunc(message, func(placeholder string) string {
+		value, ok := vars[placeholder[1:len(placeholder)-1]]
+		if !ok || value == nil {
+			return placeholder
+		}
+		return fmt.Sprint(value)
+	})
+}
+
+// NegotiateLocale returns the locale with a catalog that best matches an Accept-Language
+// header, the default locale if none does
+func (e *Engine) NegotiateLocale(acceptLanguage string) string {
+	catalogs := e.localeCatalogs()
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return catalogs.defaultLocale
+	}
+	_, index, confidence := catalogs.matcher.Match(tags...)
+	if confidence == language.No {
+		return catalogs.defaultLocale
+	}
+	return catalogs.locales[index]
+}
+
+// supportedLocale returns the catalog locale a requested locale names, "" if there is none
+func (e *Engine) supportedLocale(requested string) string {
+	tag, err := language.Parse(requested)
+	if err != nil {
+		return ""
+	}
+	catalogs := e.localeCatalogs()
+	_, index, confidence := catalogs.matcher.Match(tag)
+	if confidence < language.High {
+		return ""
+	}
+	return catalogs.locales[index]
+}
+
+// setupI18nBindings installs t and i18n
+func (e *Engine) setupI18nBindings() {
+	if err := e.rt.Set("t", e.translate); err != nil {
+		log.Error().Err(err).Msg("Failed to set t binding")
+	}
+	if err := e.rt.Set("i18n", map[string]interface{}{
+		"middleware":    e.i18nMiddleware,
+		"negotiate":     e.i18nNegotiate,
+		"locales":       e.Locales,
+		"defaultLocale": func() string { return e.localeCatalogs().defaultLocale },
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set i18n binding")
+	}
+}
+
+// translate implements t(key, [vars], [locale])
+func (e *Engine) translate(call goja.FunctionCall) goja.Value {
+	key := call.Argument(0)
+	if goja.IsUndefined(key) || goja.IsNull(key) {
+		panic(e.rt.NewTypeError("t() takes the key of a message"))
+	}
+	vars := e.messageVars("t()", call.Argument(1))
+	locale := e.currentLocale
+	if arg := call.Argument(2); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+		locale = arg.String()
+	}
+	return e.rt.ToValue(e.Translate(locale, key.String(), vars))
+}
+
+// messageVars reads the vars object of a translation
+func (e *Engine) messageVars(name string, value goja.Value) map[string]interface{} {
+	if goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+	vars, ok := value.Export().(map[string]interface{})
+	if !ok {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s takes an object of placeholder values like { name: 'Ada' }", name)))
+	}
+	return vars
+}
+
+// i18nNegotiate implements i18n.negotiate(req, [options]), the locale middleware would pick
+func (e *Engine) i18nNegotiate(call goja.FunctionCall) goja.Value {
+	req, ok := call.Argument(0).Export().(*ExpressRequest)
+	if !ok {
+		panic(e.rt.NewTypeError("i18n.negotiate() takes a request"))
+	}
+	return e.rt.ToValue(e.negotiateRequestLocale(req, e.i18nOptions(call.Argument(1))))
+}
+
+// i18nMiddleware implements i18n.middleware([options])
+func (e *Engine) i18nMiddleware(call goja.FunctionCall) goja.Value {
+	opts := e.i18nOptions(call.Argument(0))
+	return e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		req, ok := fc.Argument(0).Export().(*ExpressRequest)
+		res, ok2 := fc.Argument(1).Export().(*ExpressResponse)
+		if !ok || !ok2 {
+			panic(e.rt.NewTypeError("i18n.middleware() is route middleware"))
+		}
+		locale := e.negotiateRequestLocale(req, opts)
+		req.Locale = locale
+		e.currentLocale = locale
+		res.Locals["locale"] = locale
+		res.Locals["t"] = func(c goja.FunctionCall) goja.Value {
+			return e.rt.ToValue(e.Translate(locale, c.Argument(0).String(), e.messageVars("t()", c.Argument(1))))
+		}
+		res.Set("Content-Language", locale)
+		return goja.Undefined()
+	})
+}
+
+// i18nOptions reads the options object of i18n.middleware() and i18n.negotiate()
+func (e *Engine) i18nOptions(value goja.Value) i18nMiddlewareOptions {
+	opts := i18nMiddlewareOptions{Param: "lang", Cookie: "lang"}
+	if goja.IsUndefined(value) || goja.IsNull(value) {
+		return opts
+	}
+	raw, ok := value.Export().(map[string]interface{})
+	if !ok {
+		panic(e.rt.NewTypeError("i18n takes an options object like { param: 'lang', cookie: 'lang' }"))
+	}
+	for name, target := range map[string]*string{"param": &opts.Param, "cookie": &opts.Cookie} {
+		switch v := raw[name].(type) {
+		case nil:
+		case string:
+			*target = v
+		case bool:
+			if !v {
+				*target = ""
+			}
+		default:
+			panic(e.rt.NewTypeError(fmt.Sprintf("i18n: %s must be a string or false, got %T", name, v)))
+		}
+	}
+	return opts
+}
+
+// negotiateRequestLocale picks the locale of a request: the query parameter, the cookie,
+// then Accept-Language, skipping values without a catalog
+func (e *Engine) negotiateRequestLocale(req *ExpressRequest, opts i18nMiddlewareOptions) string {
+	if opts.Param != "" {
+		if requested, ok := req.Query[opts.Param].(string); ok {
+			if locale := e.supportedLocale(requested); locale != "" {
+				return locale
+			}
+		}
+	}
+	if opts.Cookie != "" {
+		if locale := e.supportedLocale(req.Cookies[opts.Cookie]); locale != "" {
+			return locale
+		}
+	}
+	return e.NegotiateLocale(req.headerString("accept-language"))
+}
+
+// T implements req.t(key, [vars]), translating into the locale of i18n.middleware()
+func (r *ExpressRequest) T(key string, args ...map[string]interface{}) string {
+	var vars map[string]interface{}
+	if len(args) > 0 {
+		vars = args[0]
+	}
+	return r.engine.Translate(r.Locale, key, vars)
+}
@@ -0,0 +1,299 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultLocale is used when no locale is given or the given locale cannot be parsed
+const defaultLocale = "en-US"
+
+// setupIntlBindings installs the intl global, a small Go-backed replacement for the Intl
+// API that goja does not implement:
+//
+//	intl.formatNumber(1234.5, {locale: 'de-DE', minimumFractionDigits: 2})  // "1.234,50"
+//	intl.formatCurrency(12.5, 'EUR', {locale: 'fr-FR'})                     // "12,50 €"
+//	intl.formatPercent(0.256, {locale: 'en-US'})                            // "26%"
+//	intl.formatDate(new Date(), {locale: 'en-GB', dateStyle: 'long'})       // "2 January 2006"
+func (e *Engine) setupIntlBindings() {
+	if err := e.rt.Set("intl", map[string]interface{}{
+		"formatNumber":   e.intlFormatNumber,
+		"formatCurrency": e.intlFormatCurrency,
+		"formatPercent":  e.intlFormatPercent,
+		"formatDate":     e.intlFormatDate,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set intl binding")
+	}
+}
+
+// intlOptions reads the optional trailing options object of the intl functions
+func intlOptions(args []map[string]interface{}) map[string]interface{} {
+	if len(args) > 0 && args[0] != nil {
+		return args[0]
+	}
+	return map[string]interface{}{}
+}
+
+// intlLocale resolves the locale option, falling back to the default locale
+func intlLocale(opts map[string]interface{}) language.Tag {
+	locale, _ := opts["locale"].(string)
+	if locale == "" {
+		locale = defaultLocale
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		log.Debug().Err(err).Str("locale", locale).Msg("Unknown locale, using default")
+		return language.MustParse(defaultLocale)
+	}
+	return tag
+}
+
+// intOption reads an integer option; JavaScript numbers arrive as int64 or float64
+func intOption(opts map[string]interface{}, key string) (int, bool) {
+	switch v := opts[key].(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+// fractionDigits returns the number options for the minimum/maximum fraction digit options,
+// using the given defaults when they are not set
+func fractionDigits(opts map[string]interface{}, minDefault, maxDefault int) []number.Option {
+	minDigits, maxDigits := minDefault, maxDefault
+	if v, ok := intOption(opts, "minimumFractionDigits"); ok {
+		minDigits = v
+		if maxDigits < minDigits {
+			maxDigits = minDigits
+		}
+	}
+	if v, ok := intOption(opts, "maximumFractionDigits"); ok {
+		maxDigits = v
+		if minDigits > maxDigits {
+			minDigits = maxDigits
+		}
+	}
+	return []number.Option{number.MinFractionDigits(minDigits), number.MaxFractionDigits(maxDigits)}
+}
+
+// intlFormatNumber formats a number with the grouping and decimal separators of a locale
+func (e *Engine) intlFormatNumber(value float64, args ...map[string]interface{}) string {
+	opts := intlOptions(args)
+	p := message.NewPrinter(intlLocale(opts))
+	return p.Sprint(number.Decimal(value, fractionDigits(opts, 0, 3)...))
+}
+
+// intlFormatPercent formats a ratio (0.25) as a percentage ("25%")
+func (e *Engine) intlFormatPercent(value float64, args ...map[string]interface{}) string {
+	opts := intlOptions(args)
+	p := message.NewPrinter(intlLocale(opts))
+	return p.Sprint(number.Percent(value, fractionDigits(opts, 0, 0)...))
+}
+
+// currencySuffixLanguages place the currency symbol after the amount
+var currencySuffixLanguages = map[string]bool{
+	"cs": true, "da": true, "de": true, "es": true, "fi": true, "fr": true,
+	"it": true, "nb": true, "pl": true, "ru": true, "sv": true,
+}
+
+// intlFormatCurrency formats an amount in an ISO 4217 currency, e.g. "$1,234.50" or "1.234,50 €"
+func (e *Engine) intlFormatCurrency(value float64, code string, args ...map[string]interface{}) string {
+	opts := intlOptions(args)
+	tag := intlLocale(opts)
+	p := message.NewPrinter(tag)
+
+	unit, err := currency.ParseISO(code)
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("invalid currency code %q: %w", code, err)))
+	}
+
+	scale, _ := currency.Standard.Rounding(unit)
+	amount := p.Sprint(number.Decimal(math.Abs(value), fractionDigits(opts, scale, scale)...))
+
+	var symbol string
+	if display, _ := opts["currencyDisplay"].(string); display == "code" {
+		symbol = unit.String()
+	} else {
+		symbol = p.Sprint(currency.Symbol(unit))
+	}
+
+	sign := ""
+	if value < 0 {
+		sign = "-"
+	}
+
+	base, _ := tag.Base()
+	if currencySuffixLanguages[base.String()] {
+		return sign + amount + " " + symbol
+	}
+	if len(symbol) > 1 && symbol == unit.String() {
+		// ISO codes used as symbols read better with a space: "CHF 10.00"
+		return sign + symbol + " " + amount
+	}
+	return sign + symbol + amount
+}
+
+// dateLocale describes how dates are written in a language
+type dateLocale struct {
+	months   [12]string
+	days     [7]string
+	short    string // numeric date, with 02=day, 01=month, 2006=year placeholders as in time.Format
+	medium   string // date with abbreviated month name; "Jan" is replaced with the localized abbreviation
+	long     string // date with full month name; "January" is replaced with the localized name
+	full     string // long date with weekday; "Monday" is replaced with the localized name
+	clock12h bool
+}
+
+var englishMonths = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+var englishDays = [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+var britishDateLocale = dateLocale{englishMonths, englishDays, "02/01/2006", "2 Jan 2006", "2 January 2006", "Monday, 2 January 2006", false}
+
+var dateLocales = map[string]dateLocale{
+	"en":    {englishMonths, englishDays, "1/2/2006", "Jan 2, 2006", "January 2, 2006", "Monday, January 2, 2006", true},
+	"en-GB": britishDateLocale,
+	"en-AU": britishDateLocale,
+	"en-IE": britishDateLocale,
+	"en-IN": britishDateLocale,
+	"en-NZ": britishDateLocale,
+	"de": {
+		[12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		[7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		"02.01.2006", "02.01.2006", "2. January 2006", "Monday, 2. January 2006", false,
+	},
+	"fr": {
+		[12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		[7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		"02/01/2006", "2 Jan 2006", "2 January 2006", "Monday 2 January 2006", false,
+	},
+	"es": {
+		[12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		[7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		"2/1/2006", "2 Jan 2006", "2 de January de 2006", "Monday, 2 de January de 2006", false,
+	},
+	"it": {
+		[12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"},
+		[7]string{"domenica", "lunedì", "martedì", "mercoledì", "giovedì", "venerdì", "sabato"},
+		"02/01/2006", "2 Jan 2006", "2 January 2006", "Monday 2 January 2006", false,
+	},
+	"pt": {
+		[12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"},
+		[7]string{"domingo", "segunda-feira", "terça-feira", "quarta-feira", "quinta-feira", "sexta-feira", "sábado"},
+		"02/01/2006", "2 de Jan de 2006", "2 de January de 2006", "Monday, 2 de January de 2006", false,
+	},
+	"nl": {
+		[12]string{"januari", "februari", "maart", "april", "mei", "juni", "juli", "augustus", "september", "oktober", "november", "december"},
+		[7]string{"zondag", "maandag", "dinsdag", "woensdag", "donderdag", "vrijdag", "zaterdag"},
+		"2-1-2006", "2 Jan 2006", "2 January 2006", "Monday 2 January 2006", false,
+	},
+	"ja": {englishMonths, englishDays, "2006/01/02", "2006/01/02", "2006年1月2日", "2006年1月2日", false},
+	"zh": {englishMonths, englishDays, "2006/1/2", "2006/1/2", "2006年1月2日", "2006年1月2日", false},
+}
+
+// lookupDateLocale finds the date conventions for a locale: first by full tag, then by language
+func lookupDateLocale(tag language.Tag) dateLocale {
+	if dl, ok := dateLocales[tag.String()]; ok {
+		return dl
+	}
+	base, _ := tag.Base()
+	if dl, ok := dateLocales[base.String()]; ok {
+		return dl
+	}
+	return dateLocales["en"]
+}
+
+// toTime converts the values JavaScript passes for dates: Date objects, epoch milliseconds
+// and ISO 8601 strings
+func (e *Engine) toTime(value interface{}) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v
+	case int64:
+		return time.UnixMilli(v)
+	case float64:
+		return time.UnixMilli(int64(v))
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+		panic(e.rt.NewGoError(fmt.Errorf("cannot parse date %q", v)))
+	}
+	panic(e.rt.NewTypeError("date must be a Date, a timestamp in milliseconds or an ISO 8601 string"))
+}
+
+// intlFormatDate formats a date in a locale. Options: locale, dateStyle (short, medium, long,
+// full; default medium), timeStyle (short, medium; omitted by default) and timeZone (IANA name).
+func (e *Engine) intlFormatDate(value interface{}, args ...map[string]interface{}) string {
+	opts := intlOptions(args)
+	t := e.toTime(value)
+
+	if tz, _ := opts["timeZone"].(string); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			panic(e.rt.NewGoError(fmt.Errorf("invalid time zone %q: %w", tz, err)))
+		}
+		t = t.In(loc)
+	}
+
+	dl := lookupDateLocale(intlLocale(opts))
+
+	dateStyle, _ := opts["dateStyle"].(string)
+	timeStyle, _ := opts["timeStyle"].(string)
+	if dateStyle == "" && timeStyle == "" {
+		dateStyle = "medium"
+	}
+
+	var parts []string
+	if dateStyle != "" {
+		parts = append(parts, formatLocalDate(t, dl, dateStyle))
+	}
+	if timeStyle != "" {
+		parts = append(parts, formatLocalTime(t, dl, timeStyle))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatLocalDate(t time.Time, dl dateLocale, style string) string {
+	month := dl.months[t.Month()-1]
+	weekday := dl.days[t.Weekday()]
+
+	switch style {
+	case "short":
+		return t.Format(dl.short)
+	case "long":
+		return strings.Replace(t.Format(dl.long), t.Month().String(), month, 1)
+	case "full":
+		formatted := strings.Replace(t.Format(dl.full), t.Month().String(), month, 1)
+		return strings.Replace(formatted, t.Weekday().String(), weekday, 1)
+	default:
+		short := []rune(month)
+		if len(short) > 3 {
+			short = short[:3]
+		}
+		return strings.Replace(t.Format(dl.medium), t.Month().String()[:3], string(short), 1)
+	}
+}
+
+func formatLocalTime(t time.Time, dl dateLocale, style string) string {
+	layout := "15:04"
+	if dl.clock12h {
+		layout = "3:04 PM"
+	}
+	if style == "medium" || style == "long" {
+		layout = strings.Replace(layout, "04", "04:05", 1)
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError describes one place where data does not match a schema
+type ValidationError struct {
+	Path    string `json:"path"` // JSON pointer-like path, "" for the root ("/user/name", "/items/0")
+	Message string `json:"message"`
+}
+
+// schemaValidator validates decoded JSON values (as exported from JavaScript) against the
+// commonly used subset of JSON Schema: type, enum, const, properties, required,
+// additionalProperties, items, min/maxItems, uniqueItems, min/maxLength, pattern, format,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf, allOf, anyOf, oneOf and not.
+type schemaValidator struct {
+	errors   []ValidationError
+	patterns map[string]*regexp.Regexp
+}
+
+// validateSchema returns the validation errors of data; an empty slice means data is valid
+func validateSchema(schema map[string]interface{}, data interface{}) []ValidationError {
+	v := &schemaValidator{patterns: make(map[string]*regexp.Regexp)}
+	v.validate(schema, normalizeJSONValue(data), "")
+	return v.errors
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	v.errors = append(v.errors, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// matches reports whether data matches schema without recording errors
+func (v *schemaValidator) matches(schema interface{}, data interface{}, path string) bool {
+	sub := &schemaValidator{patterns: v.patterns}
+	sub.validate(schema, data, path)
+	return len(sub.errors) == 0
+}
+
+func (v *schemaValidator) validate(rawSchema interface{}, data interface{}, path string) {
+	switch s := rawSchema.(type) {
+	case bool:
+		if !s {
+			v.fail(path, "no value is allowed here")
+		}
+		return
+	case map[string]interface{}:
+		v.validateObjectSchema(s, data, path)
+	}
+}
+
+func (v *schemaValidator) validateObjectSchema(schema map[string]interface{}, data interface{}, path string) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		actual := jsonType(data)
+		ok := false
+		for _, t := range types {
+			if t == actual || (t == "number" && actual == "integer") {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.fail(path, "expected %s, got %s", strings.Join(types, " or "), actual)
+			// Further keywords would only produce follow-up errors
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, candidate := range enum {
+			if reflect.DeepEqual(normalizeJSONValue(candidate), data) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "must be one of %s", compactJSON(enum))
+		}
+	}
+	if constant, ok := schema["const"]; ok && !reflect.DeepEqual(normalizeJSONValue(constant), data) {
+		v.fail(path, "must be %s", compactJSON(constant))
+	}
+
+	switch value := data.(type) {
+	case string:
+		v.validateString(schema, value, path)
+	case float64:
+		v.validateNumber(schema, value, path)
+	case []interface{}:
+		v.validateArray(schema, value, path)
+	case map[string]interface{}:
+		v.validateObject(schema, value, path)
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			v.validate(sub, data, path)
+		}
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, sub := range anyOf {
+			if v.matches(sub, data, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "must match at least one of the allowed schemas")
+		}
+	}
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		count := 0
+		for _, sub := range oneOf {
+			if v.matches(sub, data, path) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas, matched %d", count)
+		}
+	}
+	if not, ok := schema["not"]; ok && v.matches(not, data, path) {
+		v.fail(path, "must not match the excluded schema")
+	}
+}
+
+func (v *schemaValidator) validateString(schema map[string]interface{}, value string, path string) {
+	length := len([]rune(value))
+	if minLength, ok := schemaNumber(schema["minLength"]); ok && float64(length) < minLength {
+		v.fail(path, "must be at least %v characters long", minLength)
+	}
+	if maxLength, ok := schemaNumber(schema["maxLength"]); ok && float64(length) > maxLength {
+		v.fail(path, "must be at most %v characters long", maxLength)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, cached := v.patterns[pattern]
+		if !cached {
+			var err error
+			re, err = regexp.Compile(pattern)
+			if err != nil {
+				v.fail(path, "schema pattern %q is invalid: %v", pattern, err)
+				return
+			}
+			v.patterns[pattern] = re
+		}
+		if !re.MatchString(value) {
+			v.fail(path, "must match pattern %s", pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !validFormat(format, value) {
+		v.fail(path, "must be a valid %s", format)
+	}
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validFormat checks the well-known string formats; unknown formats are accepted
+func validFormat(format, value string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(value)
+		return err == nil && addr.Address == value
+	case "uri", "url":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	}
+	return true
+}
+
+func (v *schemaValidator) validateNumber(schema map[string]interface{}, value float64, path string) {
+	if minimum, ok := schemaNumber(schema["minimum"]); ok && value < minimum {
+		v.fail(path, "must be >= %v", minimum)
+	}
+	if maximum, ok := schemaNumber(schema["maximum"]); ok && value > maximum {
+		v.fail(path, "must be <= %v", maximum)
+	}
+	if minimum, ok := schemaNumber(schema["exclusiveMinimum"]); ok && value <= minimum {
+		v.fail(path, "must be > %v", minimum)
+	}
+	if maximum, ok := schemaNumber(schema["exclusiveMaximum"]); ok && value >= maximum {
+		v.fail(path, "must be < %v", maximum)
+	}
+	if multiple, ok := schemaNumber(schema["multipleOf"]); ok && multiple > 0 {
+		if q := value / multiple; math.Abs(q-math.Round(q)) > 1e-9 {
+			v.fail(path, "must be a multiple of %v", multiple)
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema map[string]interface{}, value []interface{}, path string) {
+	if minItems, ok := schemaNumber(schema["minItems"]); ok && float64(len(value)) < minItems {
+		v.fail(path, "must contain at least %v items", minItems)
+	}
+	if maxItems, ok := schemaNumber(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+		v.fail(path, "must contain at most %v items", maxItems)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range value {
+			for j := i + 1; j < len(value); j++ {
+				if reflect.DeepEqual(value[i], value[j]) {
+					v.fail(path, "items %d and %d must be unique", i, j)
+				}
+			}
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s/%d", path, i))
+		}
+	}
+}
+
+func (v *schemaValidator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			key := fmt.Sprint(name)
+			if _, present := value[key]; !present {
+				v.fail(path+"/"+key, "is required")
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if propSchema, ok := properties[key]; ok {
+			v.validate(propSchema, value[key], path+"/"+key)
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(path+"/"+key, "is not an allowed property")
+			}
+		case map[string]interface{}:
+			v.validate(additional, value[key], path+"/"+key)
+		}
+	}
+}
+
+// schemaTypes reads the type keyword, which may be a string or a list of strings
+func schemaTypes(raw interface{}) []string {
+	switch t := raw.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, item := range t {
+			types = append(types, fmt.Sprint(item))
+		}
+		return types
+	}
+	return nil
+}
+
+func schemaNumber(raw interface{}) (float64, bool) {
+	switch n := raw.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// jsonType returns the JSON Schema type name of a normalized value
+func jsonType(data interface{}) string {
+	switch value := data.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) && !math.IsInf(value, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", data)
+}
+
+// normalizeJSONValue round-trips a value through JSON so numbers are float64 and containers
+// are []interface{} / map[string]interface{}, whatever Go types JavaScript exported them as
+func normalizeJSONValue(data interface{}) interface{} {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return data
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return data
+	}
+	return normalized
+}
+
+func compactJSON(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
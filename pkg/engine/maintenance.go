@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MaintenanceStatus describes whether JavaScript routes are paused. While maintenance mode
+// is on, the JS server answers 503 instead of submitting requests to the dispatcher; the
+// admin interface and /v1/execute keep working so the playground can be repaired.
+type MaintenanceStatus struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// SetMaintenance turns maintenance mode on or off; message is shown on the maintenance page
+func (e *Engine) SetMaintenance(enabled bool, message string) MaintenanceStatus {
+	e.mu.Lock()
+	if enabled {
+		if !e.maintenance.Enabled {
+			now := time.Now()
+			e.maintenance.Since = &now
+		}
+		e.maintenance.Enabled = true
+		e.maintenance.Message = message
+	} else {
+		e.maintenance = MaintenanceStatus{}
+	}
+	status := e.maintenance
+	e.mu.Unlock()
+
+	if enabled {
+		log.Warn().Str("message", message).Msg("Maintenance mode enabled, JavaScript routes are paused")
+	} else {
+		log.Info().Msg("Maintenance mode disabled, JavaScript routes are served again")
+	}
+	return status
+}
+
+// Maintenance returns the current maintenance mode status
+func (e *Engine) Maintenance() MaintenanceStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.maintenance
+}
@@ -0,0 +1,502 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Metrics
+//
+// Scripts define their own metrics with the metrics binding; they are exported next to the
+// server metrics (prefixed jesus_) in the Prometheus text format at /metrics on the admin
+// server:
+//
+//	const signups = metrics.counter('app_signups_total', 'Accounts created');
+//	const queue = metrics.gauge('app_queue_length');
+//	const latency = metrics.histogram('app_upstream_seconds', { buckets: [0.1, 0.5, 1, 5] });
+//
+//	app.post('/signup', (req, res) => {
+//	  signups.inc();                      // labeled route="POST /signup"
+//	  latency.observe(0.42, { upstream: 'billing' });
+//	});
+//
+// Values recorded in a route handler get a route label with the route pattern, and values
+// recorded in a stateful session a session label. Declaring a metric again returns the
+// existing one, so scripts can be reloaded.
+
+// Metric kinds
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+// serverMetricPrefix is reserved for the metrics of the server itself
+const serverMetricPrefix = "jesus_"
+
+// maxMetricSeries caps the label combinations of a metric, so a label fed from user input
+// cannot grow the registry without bound
+const maxMetricSeries = 1000
+
+// defaultHistogramBuckets are the Prometheus default buckets, suited to durations in seconds
+var defaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNamePattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// metricsRegistry holds the metrics of all runtimes of an engine
+type metricsRegistry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	buckets []float64 // Upper bounds of histogram buckets, ascending
+	series  map[string]*metricSeries
+}
+
+// metricSeries is the value of a metric for one combination of labels
+type metricSeries struct {
+	labels string // Rendered label set, e.g. {route="GET /",status="ok"}
+	value  float64
+	counts []uint64 // Observations per histogram bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{metrics: make(map[string]*metric)}
+}
+
+// declare returns the metric with the given name, creating it if needed
+func (reg *metricsRegistry) declare(name, kind, help string, buckets []float64) (*metric, error) {
+	if !metricNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid metric name %q", name)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if m, ok := reg.metrics[name]; ok {
+		if m.kind != kind {
+			return nil, fmt.Errorf("metric %s is already declared as a %s", name, m.kind)
+		}
+		if help != "" {
+			m.help = help
+		}
+		return m, nil
+	}
+
+	m := &metric{name: name, help: help, kind: kind, buckets: buckets, series: make(map[string]*metricSeries)}
+	reg.metrics[name] = m
+	return m, nil
+}
+
+// update applies fn to the series of a label set under the registry lock
+func (reg *metricsRegistry) update(m *metric, labels map[string]string, fn func(*metricSeries)) error {
+	rendered, err := renderLabels(labels)
+	if err != nil {
+		return fmt.Errorf("metric %s: %w", m.name, err)
+	}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	s, ok := m.series[rendered]
+	if !ok {
+		if len(m.series) >= maxMetricSeries {
+			return fmt.Errorf("metric %s has more than %d label combinations", m.name, maxMetricSeries)
+		}
+		s = &metricSeries{labels: rendered}
+		if m.kind == MetricHistogram {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[rendered] = s
+	}
+	fn(s)
+	return nil
+}
+
+// observe records a histogram observation
+func (m *metric) observe(s *metricSeries, value float64) {
+	for i, bound := range m.buckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// renderLabels renders a label set in the exposition format, sorted by name
+func renderLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__") {
+			return "", fmt.Errorf("invalid label name %q", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escapeLabelValue(labels[name]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// withLabel adds a label to a rendered label set
+func withLabel(rendered, name, value string) string {
+	label := name + `="` + escapeLabelValue(value) + `"`
+	if rendered == "" {
+		return "{" + label + "}"
+	}
+	return rendered[:len(rendered)-1] + "," + label + "}"
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// write renders all metrics in the Prometheus text exposition format, sorted by name
+func (reg *metricsRegistry) write(w io.Writer) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	names := make([]string, 0, len(reg.metrics))
+	for name := range reg.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		m := reg.metrics[name]
+		if m.help != "" {
+			fmt.Fprintf(out, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(m.help))
+		}
+		fmt.Fprintf(out, "# TYPE %s %s\n", name, m.kind)
+
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := m.series[key]
+			if m.kind != MetricHistogram {
+				fmt.Fprintf(out, "%s%s %s\n", name, s.labels, formatMetricValue(s.value))
+				continue
+			}
+			var cumulative uint64
+			for i, bound := range m.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(out, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", formatMetricValue(bound)), cumulative)
+			}
+			fmt.Fprintf(out, "%s_bucket%s %d\n", name, withLabel(s.labels, "le", "+Inf"), s.count)
+			fmt.Fprintf(out, "%s_sum%s %s\n", name, s.labels, formatMetricValue(s.sum))
+			fmt.Fprintf(out, "%s_count%s %d\n", name, s.labels, s.count)
+		}
+	}
+	return out.Flush()
+}
+
+// WriteMetrics writes the server and script metrics in the Prometheus text format
+func (e *Engine) WriteMetrics(w io.Writer) error {
+	root := e.root()
+	root.updateServerGauges()
+	return root.metrics.write(w)
+}
+
+// Server metrics
+
+// declareServerMetrics declares the metrics the engine records about itself
+func (e *Engine) declareServerMetrics() {
+	for _, m := range []struct{ name, kind, help string }{
+		{"jesus_requests_total", MetricCounter, "Requests served by JavaScript route handlers"},
+		{"jesus_request_errors_total", MetricCounter, "Requests whose handler threw or timed out"},
+		{"jesus_request_duration_seconds", MetricHistogram, "Run time of JavaScript route handlers"},
+		{"jesus_executions_total", MetricCounter, "Code executions by source"},
+		{"jesus_execution_errors_total", MetricCounter, "Code executions that threw or timed out"},
+		{"jesus_job_queue_length", MetricGauge, "Jobs waiting for the dispatcher of the primary runtime"},
+		{"jesus_routes", MetricGauge, "Registered JavaScript routes"},
+		{"jesus_runtimes", MetricGauge, "Runtimes serving requests"},
+		{"jesus_sessions", MetricGauge, "Stateful session runtimes"},
+		{"jesus_prepared_statements", MetricGauge, "Statements of db.prepare() kept prepared"},
+	} {
+		var buckets []float64
+		if m.kind == MetricHistogram {
+			buckets = defaultHistogramBuckets
+		}
+		if _, err := e.metrics.declare(m.name, m.kind, m.help, buckets); err != nil {
+			log.Error().Err(err).Str("metric", m.name).Msg("Failed to declare server metric")
+		}
+	}
+}
+
+// recordJobMetrics counts a finished request or execution
+func (e *Engine) recordJobMetrics(job EvalJob, err error, duration time.Duration) {
+	reg := e.root().metrics
+	add := func(name string, labels map[string]string, fn func(*metric, *metricSeries)) {
+		reg.mu.Lock()
+		m := reg.metrics[name]
+		reg.mu.Unlock()
+		if m == nil {
+			return
+		}
+		if updateErr := reg.update(m, labels, func(s *metricSeries) { fn(m, s) }); updateErr != nil {
+			log.Debug().Err(updateErr).Msg("Failed to record server metric")
+		}
+	}
+	inc := func(_ *metric, s *metricSeries) { s.value++ }
+
+	if job.Handler != nil {
+		labels := map[string]string{"route": job.Handler.Doc.Method + " " + job.Handler.Doc.Path}
+		add("jesus_requests_total", labels, inc)
+		add("jesus_request_duration_seconds", labels, func(m *metric, s *metricSeries) {
+			m.observe(s, duration.Seconds())
+		})
+		if err != nil {
+			add("jesus_request_errors_total", labels, inc)
+		}
+		return
+	}
+
+	labels := map[string]string{"source": job.Source}
+	add("jesus_executions_total", labels, inc)
+	if err != nil {
+		add("jesus_execution_errors_total", labels, inc)
+	}
+}
+
+// updateServerGauges refreshes the gauges read when metrics are scraped
+func (e *Engine) updateServerGauges() {
+	e.mu.RLock()
+	routes := 0
+	for _, methods := range e.handlers {
+		routes += len(methods)
+	}
+	routes += len(e.files)
+	sessions := len(e.sessions)
+	e.mu.RUnlock()
+
+	gauges := map[string]float64{
+		"jesus_job_queue_length":    float64(len(e.jobs)),
+		"jesus_routes":              float64(routes),
+		"jesus_runtimes":            float64(e.RuntimePoolSize()),
+		"jesus_sessions":            float64(sessions),
+		"jesus_prepared_statements": float64(e.PreparedStatements()),
+	}
+	reg := e.metrics
+	for name, value := range gauges {
+		reg.mu.Lock()
+		m := reg.metrics[name]
+		reg.mu.Unlock()
+		if m == nil {
+			continue
+		}
+		if err := reg.update(m, nil, func(s *metricSeries) { s.value = value }); err != nil {
+			log.Debug().Err(err).Str("metric", name).Msg("Failed to update server gauge")
+		}
+	}
+}
+
+// JavaScript bindings
+
+// scriptMetric is a metric handed to JavaScript by the metrics binding
+type scriptMetric struct {
+	engine *Engine
+	metric *metric
+}
+
+// setupMetricsBindings installs metrics.counter/gauge/histogram
+func (e *Engine) setupMetricsBindings() {
+	if err := e.rt.Set("metrics", map[string]interface{}{
+		"counter": func(name string, help ...string) (*scriptMetric, error) {
+			return e.declareScriptMetric(name, MetricCounter, firstString(help), nil)
+		},
+		"gauge": func(name string, help ...string) (*scriptMetric, error) {
+			return e.declareScriptMetric(name, MetricGauge, firstString(help), nil)
+		},
+		"histogram": func(name string, options ...map[string]interface{}) (*scriptMetric, error) {
+			help, buckets := "", defaultHistogramBuckets
+			if len(options) > 0 && options[0] != nil {
+				help, _ = options[0]["help"].(string)
+				if list, ok := options[0]["buckets"].([]interface{}); ok {
+					parsed, err := parseHistogramBuckets(list)
+					if err != nil {
+						return nil, fmt.Errorf("metrics.histogram(%s): %w", name, err)
+					}
+					buckets = parsed
+				}
+			}
+			return e.declareScriptMetric(name, MetricHistogram, help, buckets)
+		},
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set metrics binding")
+	}
+}
+
+func (e *Engine) declareScriptMetric(name, kind, help string, buckets []float64) (*scriptMetric, error) {
+	if strings.HasPrefix(name, serverMetricPrefix) {
+		return nil, fmt.Errorf("metrics.%s(%s): the %s prefix is reserved for server metrics", kind, name, serverMetricPrefix)
+	}
+	m, err := e.root().metrics.declare(name, kind, help, buckets)
+	if err != nil {
+		return nil, fmt.Errorf("metrics.%s(): %w", kind, err)
+	}
+	return &scriptMetric{engine: e, metric: m}, nil
+}
+
+func parseHistogramBuckets(list []interface{}) ([]float64, error) {
+	buckets := make([]float64, 0, len(list))
+	for _, item := range list {
+		bound, ok := numberOption(item)
+		if !ok {
+			return nil, fmt.Errorf("buckets must be numbers")
+		}
+		if len(buckets) > 0 && bound <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets must be in increasing order")
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
+}
+
+func firstString(values []string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// labels combines the labels given by the script with the route and session labels
+func (m *scriptMetric) labels(given []map[string]interface{}) map[string]string {
+	labels := make(map[string]string)
+	e := m.engine
+	// Sources of direct executions are single words, routes are "METHOD /path"
+	if strings.Contains(e.currentSource, " ") {
+		labels["route"] = e.currentSource
+	}
+	if e.activeSession != "" && !isTemplateSession(e.activeSession) {
+		labels["session"] = e.activeSession
+	}
+	if len(given) > 0 {
+		for name, value := range given[0] {
+			labels[name] = fmt.Sprint(value)
+		}
+	}
+	return labels
+}
+
+func (m *scriptMetric) update(name string, labels []map[string]interface{}, fn func(*metricSeries)) error {
+	if err := m.engine.root().metrics.update(m.metric, m.labels(labels), fn); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Inc adds to a counter or gauge: inc([value [, labels]]), 1 by default
+func (m *scriptMetric) Inc(args ...interface{}) error {
+	value, labels, err := metricArgs(args, 1)
+	if err != nil {
+		return fmt.Errorf("inc(): %w", err)
+	}
+	if m.metric.kind == MetricHistogram {
+		return fmt.Errorf("inc(): %s is a histogram, use observe()", m.metric.name)
+	}
+	if m.metric.kind == MetricCounter && value < 0 {
+		return fmt.Errorf("inc(): counter %s cannot decrease", m.metric.name)
+	}
+	return m.update("inc()", labels, func(s *metricSeries) { s.value += value })
+}
+
+// Dec subtracts from a gauge: dec([value [, labels]]), 1 by default
+func (m *scriptMetric) Dec(args ...interface{}) error {
+	value, labels, err := metricArgs(args, 1)
+	if err != nil {
+		return fmt.Errorf("dec(): %w", err)
+	}
+	if m.metric.kind != MetricGauge {
+		return fmt.Errorf("dec(): %s is a %s, only gauges decrease", m.metric.name, m.metric.kind)
+	}
+	return m.update("dec()", labels, func(s *metricSeries) { s.value -= value })
+}
+
+// Set sets a gauge: set(value [, labels])
+func (m *scriptMetric) Set(args ...interface{}) error {
+	value, labels, err := metricArgs(args, math.NaN())
+	if err != nil || math.IsNaN(value) {
+		return fmt.Errorf("set(): a number is required")
+	}
+	if m.metric.kind != MetricGauge {
+		return fmt.Errorf("set(): %s is a %s, only gauges can be set", m.metric.name, m.metric.kind)
+	}
+	return m.update("set()", labels, func(s *metricSeries) { s.value = value })
+}
+
+// Observe records a histogram value: observe(value [, labels])
+func (m *scriptMetric) Observe(args ...interface{}) error {
+	value, labels, err := metricArgs(args, math.NaN())
+	if err != nil || math.IsNaN(value) {
+		return fmt.Errorf("observe(): a number is required")
+	}
+	if m.metric.kind != MetricHistogram {
+		return fmt.Errorf("observe(): %s is a %s, use inc() or set()", m.metric.name, m.metric.kind)
+	}
+	metric := m.metric
+	return m.update("observe()", labels, func(s *metricSeries) { metric.observe(s, value) })
+}
+
+// metricArgs reads the optional value and labels of a metric update
+func metricArgs(args []interface{}, defaultValue float64) (float64, []map[string]interface{}, error) {
+	value := defaultValue
+	var labels []map[string]interface{}
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+		case map[string]interface{}:
+			labels = []map[string]interface{}{v}
+		default:
+			n, ok := numberOption(v)
+			if !ok || i > 0 {
+				return 0, nil, fmt.Errorf("expected ([value] [, labels])")
+			}
+			value = n
+		}
+	}
+	return value, labels, nil
+}
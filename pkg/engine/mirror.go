@@ -0,0 +1,289 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+)
+
+// Request mirroring
+//
+// The mirror option of a route sends a copy of its requests to a second version of the
+// endpoint once the route has answered the client, to validate a rewrite under real traffic:
+//
+//	app.get('/items/:id', getItem, { mirror: '/v2/items/:id' });                     // another route
+//	app.post('/orders', createOrder, { mirror: { to: 'http://staging:9922', sample: 0.1 } });
+//
+// A route path target gets the parameters of the request filled in; a URL target gets the
+// request path appended. The mirror's answer never reaches the client. It is compared with
+// the client's answer, status and body with JSON normalized, and the outcome is kept for
+// GET /admin/mirror. Mirrored requests run the target's handler again, side effects included,
+// so mirror writes only to handlers that can repeat them or to a staging upstream.
+
+// MaxMirrorBodyBytes bounds the bodies of mirrored requests and of the responses compared
+const MaxMirrorBodyBytes = 1 << 20
+
+const (
+	maxMirrorResults   = 200     // Mirror outcomes kept for /admin/mirror
+	maxMirrorDiffBytes = 4 << 10 // Size limit of the diff kept for a mismatch
+	mirrorTimeout      = 30 * time.Second
+)
+
+// RouteMirror is the mirror option of a route
+type RouteMirror struct {
+	Target string  // Route path, with :params filled from the request, or http(s) URL
+	Sample float64 // Share of the requests mirrored, 1 for all
+}
+
+// MirrorResult compares the answer of a route with the answer of its mirror
+type MirrorResult struct {
+	Time         time.Time `json:"time"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`   // Path of the client's request
+	Target       string    `json:"target"` // Path or URL the copy was sent to
+	Status       int       `json:"status"`
+	MirrorStatus int       `json:"mirrorStatus,omitempty"`
+	Match        bool      `json:"match"`
+	Diff         string    `json:"diff,omitempty"`  // Unified diff of the bodies, the route's first
+	Error        string    `json:"error,omitempty"` // Why the mirror gave no answer
+	DurationMs   int64     `json:"durationMs"`      // Run time of the mirror
+}
+
+// parseRouteMirror reads the mirror option: a target, or { to, sample }
+func parseRouteMirror(value interface{}) (*RouteMirror, error) {
+	m := &RouteMirror{Sample: 1}
+	switch v := value.(type) {
+	case string:
+		m.Target = v
+	case map[string]interface{}:
+		to, ok := v["to"].(string)
+		if !ok {
+			return nil, fmt.Errorf("mirror.to must be a route path or URL")
+		}
+		m.Target = to
+		if v["sample"] != nil {
+			sample, ok := numberOption(v["sample"])
+			if !ok || sample <= 0 || sample > 1 {
+				return nil, fmt.Errorf("mirror.sample must be a number in (0, 1]")
+			}
+			m.Sample = sample
+		}
+	default:
+		return nil, fmt.Errorf("mirror must be a route path, a URL or an object like { to: '/v2/items', sample: 0.1 }, got %T", value)
+	}
+
+	m.Target = strings.TrimSpace(m.Target)
+	if m.isURL() {
+		u, err := url.Parse(m.Target)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid mirror URL %q", m.Target)
+		}
+	} else if !strings.HasPrefix(m.Target, "/") {
+		return nil, fmt.Errorf("mirror must be a route path starting with / or an http(s) URL, got %q", m.Target)
+	} else if _, err := compileRoutePattern(m.Target); err != nil {
+		return nil, fmt.Errorf("mirror: %w", err)
+	}
+	return m, nil
+}
+
+func (m *RouteMirror) isURL() bool {
+	return strings.HasPrefix(m.Target, "http://") || strings.HasPrefix(m.Target, "https://")
+}
+
+// Sampled decides whether a request is mirrored
+func (m *RouteMirror) Sampled() bool {
+	return m.Sample >= 1 || rand.Float64() < m.Sample
+}
+
+// targetPath fills the parameters of a route path target
+func (m *RouteMirror) targetPath(params map[string]string) string {
+	parts := splitRoutePath(m.Target)
+	filled := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case strings.HasPrefix(part, ":"):
+			groups := routeParamRegexp.FindStringSubmatch(part)
+			if groups == nil {
+				filled = append(filled, part)
+			} else if value, ok := params[groups[1]]; ok && value != "" {
+				filled = append(filled, url.PathEscape(value))
+			}
+		case strings.HasPrefix(part, "*"):
+			name := strings.TrimPrefix(part, "*")
+			if name == "" {
+				name = "0"
+			}
+			if value := params[name]; value != "" {
+				filled = append(filled, value)
+			}
+		default:
+			filled = append(filled, part)
+		}
+	}
+	return "/" + strings.Join(filled, "/")
+}
+
+// MirrorRequest sends a copy of a request to the mirror of its route and records how the
+// mirror's answer compares with the route's. It blocks until the mirror answered; callers
+// run it in a goroutine once the client got its response.
+func (e *Engine) MirrorRequest(m *RouteMirror, r *http.Request, body []byte, status int, response []byte) {
+	result := MirrorResult{Time: time.Now(), Method: r.Method, Path: r.URL.Path, Status: status}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+	var mirrorBody []byte
+	var err error
+	start := time.Now()
+	if m.isURL() {
+		result.Target = strings.TrimSuffix(m.Target, "/") + r.URL.Path
+		result.MirrorStatus, mirrorBody, err = e.mirrorToURL(ctx, result.Target, r, body)
+	} else {
+		var params map[string]string
+		if handler, ok := e.GetHandler(r.Method, r.URL.Path); ok {
+			params = routeParams(handler, r.URL.Path)
+		}
+		result.Target = m.targetPath(params)
+		result.MirrorStatus, mirrorBody, err = e.mirrorToRoute(ctx, result.Target, r, body)
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Diff = mirrorDiff(response, mirrorBody)
+		result.Match = status == result.MirrorStatus && result.Diff == ""
+	}
+	if !result.Match {
+		log.Warn().Str("path", result.Path).Str("target", result.Target).Int("status", status).Int("mirrorStatus", result.MirrorStatus).Str("error", result.Error).Msg("Mirrored response differs")
+	}
+	e.recordMirrorResult(result)
+}
+
+// mirrorRequest builds the copy of a request sent to a mirror
+func mirrorRequest(ctx context.Context, target string, r *http.Request, body []byte) (*http.Request, error) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = r.Header.Clone()
+	req.Header.Set("X-Mirrored-From", r.URL.Path)
+	req.RemoteAddr = r.RemoteAddr
+	return req, nil
+}
+
+func (e *Engine) mirrorToURL(ctx context.Context, target string, r *http.Request, body []byte) (int, []byte, error) {
+	req, err := mirrorRequest(ctx, target, r, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	pool := e.root().httpClient
+	if err := pool.checkRequest(req); err != nil {
+		return 0, nil, err
+	}
+	resp, err := pool.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxMirrorBodyBytes))
+	return resp.StatusCode, data, err
+}
+
+func (e *Engine) mirrorToRoute(ctx context.Context, path string, r *http.Request, body []byte) (int, []byte, error) {
+	handler, ok := e.GetHandler(r.Method, path)
+	if !ok {
+		return 0, nil, fmt.Errorf("no %s route matches %s", r.Method, path)
+	}
+	req, err := mirrorRequest(ctx, path, r, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Host = r.Host
+
+	recorder := httptest.NewRecorder()
+	done := make(chan error, 1)
+	e.SubmitJob(EvalJob{Handler: handler, W: recorder, R: req, Done: done})
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return 0, nil, fmt.Errorf("mirror did not answer within %s", mirrorTimeout)
+	}
+	return recorder.Code, recorder.Body.Bytes(), nil
+}
+
+// mirrorDiff returns the unified diff of two response bodies, "" if they are equal. JSON
+// bodies are compared with sorted keys and indentation, so formatting does not count.
+func mirrorDiff(a, b []byte) string {
+	left, right := normalizeMirrorBody(a), normalizeMirrorBody(b)
+	if left == right {
+		return ""
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(left),
+		B:        difflib.SplitLines(right),
+		FromFile: "route",
+		ToFile:   "mirror",
+		Context:  2,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	if len(diff) > maxMirrorDiffBytes {
+		diff = diff[:maxMirrorDiffBytes] + "\n... diff truncated"
+	}
+	return diff
+}
+
+func normalizeMirrorBody(body []byte) string {
+	var v interface{}
+	if json.Unmarshal(body, &v) == nil {
+		if indented, err := json.MarshalIndent(v, "", "  "); err == nil {
+			return string(indented)
+		}
+	}
+	return string(body)
+}
+
+func (e *Engine) recordMirrorResult(result MirrorResult) {
+	root := e.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.mirrorResults = append(root.mirrorResults, result)
+	if len(root.mirrorResults) > maxMirrorResults {
+		root.mirrorResults = root.mirrorResults[len(root.mirrorResults)-maxMirrorResults:]
+	}
+}
+
+// MirrorResults returns the outcomes of recent mirrored requests, newest first
+func (e *Engine) MirrorResults() []MirrorResult {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	results := make([]MirrorResult, len(root.mirrorResults))
+	for i, result := range root.mirrorResults {
+		results[len(results)-1-i] = result
+	}
+	return results
+}
+
+// ClearMirrorResults forgets the recorded mirror outcomes
+func (e *Engine) ClearMirrorResults() {
+	root := e.root()
+	root.mu.Lock()
+	root.mirrorResults = nil
+	root.mu.Unlock()
+}
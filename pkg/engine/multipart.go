@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+)
+
+// File uploads
+//
+// multipart/form-data requests are parsed before the handler runs: fields go to req.body
+// and files to req.files, keyed by field name (an array when a field has several files):
+//
+//	app.post('/avatar', (req, res) => {
+//	  const file = req.files.avatar;  // { field, name, type, size, path }
+//	  db.query('INSERT INTO avatars (name, data) VALUES (?, ?)', [file.name, file.text()]);
+//	  res.json({ size: file.size });
+//	});
+//
+// Files are written to temporary files, removed once the response is finished. Requests
+// over the upload limits are answered with 413 before the handler runs.
+
+// maxFormFieldBytes is the largest value of a non-file multipart field
+const maxFormFieldBytes = 1 << 20
+
+// UploadLimits caps the files of multipart requests
+type UploadLimits struct {
+	MaxFileBytes int64  // Size limit of each file, 0 for no limit
+	MaxFiles     int    // Number of files per request, 0 for no limit
+	Dir          string // Directory of the temporary files, "" for the system temp directory
+}
+
+// DefaultUploadLimits returns the limits used unless SetUploadLimits is called
+func DefaultUploadLimits() UploadLimits {
+	return UploadLimits{
+		MaxFileBytes: 32 << 20,
+		MaxFiles:     20,
+	}
+}
+
+// SetUploadLimits configures the limits of file uploads
+func (e *Engine) SetUploadLimits(limits UploadLimits) error {
+	if limits.MaxFileBytes < 0 || limits.MaxFiles < 0 {
+		return fmt.Errorf("upload limits must not be negative")
+	}
+	if limits.Dir != "" {
+		if err := os.MkdirAll(limits.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create upload directory: %w", err)
+		}
+	}
+
+	root := e.root()
+	root.mu.Lock()
+	root.uploadLimits = limits
+	root.mu.Unlock()
+	return nil
+}
+
+// UploadedFile is a file of a multipart request, exposed in req.files
+type UploadedFile struct {
+	Field string `json:"field"` // Form field name
+	Name  string `json:"name"`  // File name sent by the client, without directories
+	Type  string `json:"type"`  // Content type sent by the client
+	Size  int64  `json:"size"`
+	Path  string `json:"path"` // Temporary file, removed once the response is finished
+}
+
+// Text reads the content of the file
+func (f *UploadedFile) Text() (string, error) {
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read uploaded file %s: %w", f.Name, err)
+	}
+	return string(data), nil
+}
+
+// UploadError rejects a multipart request before its handler runs
+type UploadError struct {
+	Status  int
+	Message string
+}
+
+func (err *UploadError) Error() string {
+	return err.Message
+}
+
+// isMultipartRequest returns the boundary of a multipart/form-data request
+func isMultipartRequest(r *http.Request) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return "", false
+	}
+	return params["boundary"], true
+}
+
+// parseMultipartBody reads the fields and files of a multipart request. Files written
+// before an error are returned so they can be removed.
+func (e *Engine) parseMultipartBody(r *http.Request, boundary string) (map[string]interface{}, []*UploadedFile, error) {
+	root := e.root()
+	root.mu.RLock()
+	limits := root.uploadLimits
+	root.mu.RUnlock()
+
+	fields := make(map[string]interface{})
+	var files []*UploadedFile
+	reader := multipart.NewReader(r.Body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return fields, files, nil
+		}
+		if err != nil {
+			return fields, files, &UploadError{Status: http.StatusBadRequest, Message: fmt.Sprintf("invalid multipart body: %v", err)}
+		}
+
+		field := part.FormName()
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFormFieldBytes+1))
+			if err != nil {
+				return fields, files, &UploadError{Status: http.StatusBadRequest, Message: fmt.Sprintf("failed to read field %s: %v", field, err)}
+			}
+			if len(value) > maxFormFieldBytes {
+				return fields, files, &UploadError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("field %s is larger than %d bytes", field, maxFormFieldBytes)}
+			}
+			addFormValue(fields, field, string(value))
+			continue
+		}
+
+		if limits.MaxFiles > 0 && len(files) >= limits.MaxFiles {
+			return fields, files, &UploadError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("more than %d files uploaded", limits.MaxFiles)}
+		}
+		file, err := saveUploadedFile(part, limits)
+		if file != nil {
+			files = append(files, file)
+		}
+		if err != nil {
+			return fields, files, err
+		}
+	}
+}
+
+// saveUploadedFile writes a file part to a temporary file
+func saveUploadedFile(part *multipart.Part, limits UploadLimits) (*UploadedFile, error) {
+	tmp, err := os.CreateTemp(limits.Dir, "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary upload file: %w", err)
+	}
+	file := &UploadedFile{
+		Field: part.FormName(),
+		Name:  filepath.Base(part.FileName()),
+		Type:  part.Header.Get("Content-Type"),
+		Path:  tmp.Name(),
+	}
+
+	var src io.Reader = part
+	if limits.MaxFileBytes > 0 {
+		src = io.LimitReader(part, limits.MaxFileBytes+1)
+	}
+	file.Size, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return file, &UploadError{Status: http.StatusBadRequest, Message: fmt.Sprintf("failed to receive file %s: %v", file.Name, err)}
+	}
+	if limits.MaxFileBytes > 0 && file.Size > limits.MaxFileBytes {
+		return file, &UploadError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("file %s is larger than %d bytes", file.Name, limits.MaxFileBytes)}
+	}
+	return file, nil
+}
+
+// addFormValue adds a value to a field map, turning repeated fields into arrays
+func addFormValue(values map[string]interface{}, name string, value interface{}) {
+	switch existing := values[name].(type) {
+	case nil:
+		values[name] = value
+	case []interface{}:
+		values[name] = append(existing, value)
+	default:
+		values[name] = []interface{}{existing, value}
+	}
+}
+
+// uploadedFilesByField builds req.files from the uploaded files
+func uploadedFilesByField(files []*UploadedFile) map[string]interface{} {
+	byField := make(map[string]interface{})
+	for _, file := range files {
+		addFormValue(byField, file.Field, file)
+	}
+	return byField
+}
+
+// removeUploads deletes the temporary files of a request; files the handler moved away are skipped
+func removeUploads(files []*UploadedFile) {
+	for _, file := range files {
+		if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("path", file.Path).Msg("Failed to remove uploaded file")
+		}
+	}
+}
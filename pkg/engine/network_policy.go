@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Outbound network policy
+//
+// NetworkPolicy limits where fetch(), HTTP.*, graphql, proxy() and route mirroring
+// may connect, so scripts written by an AI assistant or another untrusted author cannot
+// reach internal services or the cloud metadata endpoint (SSRF). Rules are hosts, like
+// api.example.com, wildcards for subdomains, like *.example.com, and networks in CIDR
+// notation, like 10.1.0.0/16 or a single address.
+//
+// Host names are checked before each request and redirect. Addresses are checked when the
+// connection is made, against every address the name resolves to; the connection goes to
+// an address that passed, so a name cannot resolve to a public address for the check and
+// to a private one for the connection. Requests through a proxy are checked against the
+// addresses the target resolves to here, since the proxy connects to it.
+
+// ErrBlockedByNetworkPolicy is returned for connections the network policy does not allow
+var ErrBlockedByNetworkPolicy = errors.New("blocked by the network policy")
+
+// NetworkPolicy configures the hosts JavaScript code may connect to
+type NetworkPolicy struct {
+	AllowHosts   []string // Hosts and networks that may be reached; empty allows all that are not denied
+	DenyHosts    []string // Hosts and networks that may not be reached, even if allowed
+	BlockPrivate bool     // Block loopback, private, link-local and shared addresses unless allowed explicitly
+}
+
+// networkRules are the parsed rules of a NetworkPolicy
+type networkRules struct {
+	allow        []hostRule
+	deny         []hostRule
+	blockPrivate bool
+}
+
+// hostRule matches a host name, the subdomains of a domain, or a network
+type hostRule struct {
+	host   string     // Exact host name
+	suffix string     // Domain suffix with leading dot, for *.example.com
+	ipNet  *net.IPNet // Network, for CIDRs and addresses
+}
+
+// sharedAddressSpace is the carrier-grade NAT network, which IsPrivate does not include
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Validate reports hosts and networks of the policy that cannot be parsed
+func (p NetworkPolicy) Validate() error {
+	_, err := p.compile()
+	return err
+}
+
+// compile parses the rules of the policy; a policy without rules compiles to nil
+func (p NetworkPolicy) compile() (*networkRules, error) {
+	if len(p.AllowHosts) == 0 && len(p.DenyHosts) == 0 && !p.BlockPrivate {
+		return nil, nil
+	}
+	rules := &networkRules{blockPrivate: p.BlockPrivate}
+	for _, list := range []struct {
+		values []string
+		rules  *[]hostRule
+	}{{p.AllowHosts, &rules.allow}, {p.DenyHosts, &rules.deny}} {
+		for _, value := range list.values {
+			rule, err := parseHostRule(value)
+			if err != nil {
+				return nil, err
+			}
+			*list.rules = append(*list.rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// parseHostRule parses a host, *.domain, address or CIDR
+func parseHostRule(value string) (hostRule, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch {
+	case value == "":
+		return hostRule{}, fmt.Errorf("empty host in network policy")
+	case strings.Contains(value, "/"):
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return hostRule{}, fmt.Errorf("invalid network %q in network policy: %w", value, err)
+		}
+		return hostRule{ipNet: ipNet}, nil
+	case net.ParseIP(value) != nil:
+		ip := net.ParseIP(value)
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return hostRule{ipNet: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	case strings.HasPrefix(value, "*."):
+		return hostRule{suffix: value[1:]}, nil
+	case strings.ContainsAny(value, ":* "):
+		return hostRule{}, fmt.Errorf("invalid host %q in network policy, use a host, *.domain or a CIDR", value)
+	}
+	return hostRule{host: strings.TrimSuffix(value, ".")}, nil
+}
+
+// matchName reports whether the rule matches a host name
+func (r hostRule) matchName(host string) bool {
+	return (r.host != "" && r.host == host) || (r.suffix != "" && strings.HasSuffix(host, r.suffix))
+}
+
+// matchIP reports whether the rule matches an address
+func (r hostRule) matchIP(ip net.IP) bool {
+	return r.ipNet != nil && r.ipNet.Contains(ip)
+}
+
+// isPrivateIP reports whether an address is not on the public internet
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip) ||
+		(ip.To4() != nil && ip.To4()[0] == 0)
+}
+
+// normalizeHost lowercases a host name and removes the trailing dot of a fully qualified name
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// checkName applies the host name rules. It reports whether an allow rule matched the name,
+// which exempts its addresses from the address rules that follow.
+func (r *networkRules) checkName(host string) (allowed bool, err error) {
+	for _, rule := range r.deny {
+		if rule.matchName(host) {
+			return false, fmt.Errorf("%s: %w", host, ErrBlockedByNetworkPolicy)
+		}
+	}
+	for _, rule := range r.allow {
+		if rule.matchName(host) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkIP applies the address rules to an address of a host. Addresses of a host whose name
+// an allow rule matched are only checked against the deny rules.
+func (r *networkRules) checkIP(host string, ip net.IP, nameAllowed bool) error {
+	for _, rule := range r.deny {
+		if rule.matchIP(ip) {
+			return fmt.Errorf("%s: %w", describeAddr(host, ip), ErrBlockedByNetworkPolicy)
+		}
+	}
+	if nameAllowed {
+		return nil
+	}
+	for _, rule := range r.allow {
+		if rule.matchIP(ip) {
+			return nil
+		}
+	}
+	if len(r.allow) > 0 {
+		return fmt.Errorf("%s is not an allowed host: %w", host, ErrBlockedByNetworkPolicy)
+	}
+	if r.blockPrivate && isPrivateIP(ip) {
+		return fmt.Errorf("%s is a private address: %w", describeAddr(host, ip), ErrBlockedByNetworkPolicy)
+	}
+	return nil
+}
+
+// describeAddr names an address of a host in errors
+func describeAddr(host string, ip net.IP) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	return fmt.Sprintf("%s (%s)", host, ip)
+}
+
+// resolve returns the addresses of a host that the policy allows connecting to
+func (r *networkRules) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	host = normalizeHost(host)
+	nameAllowed, err := r.checkName(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	var allowed []net.IP
+	for _, ip := range ips {
+		if err = r.checkIP(host, ip, nameAllowed); err == nil {
+			allowed = append(allowed, ip)
+		}
+	}
+	if len(allowed) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no addresses found for %s", host)
+		}
+		return nil, err
+	}
+	return allowed, nil
+}
+
+// policyDialer connects only to the addresses the rules allow. Connections to the proxies
+// of the pool are made as they are, since the policy is applied to the proxied requests.
+type policyDialer struct {
+	dialer  *net.Dialer
+	rules   *networkRules
+	proxies map[string]bool // host:port of the HTTP proxies
+}
+
+// DialContext resolves the host of addr and connects to the first allowed address that answers
+func (d *policyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.proxies[addr] {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := d.rules.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var conn net.Conn
+	for _, ip := range ips {
+		conn, err = d.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// proxyAddrs returns the host:port of the proxies requests may be sent through
+func proxyAddrs(proxy string) map[string]bool {
+	urls := []string{proxy}
+	if proxy == "" {
+		env := httpproxy.FromEnvironment()
+		urls = []string{env.HTTPProxy, env.HTTPSProxy}
+	}
+	addrs := make(map[string]bool)
+	for _, raw := range urls {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"https": "443", "socks5": "1080"}[u.Scheme]
+			if port == "" {
+				port = "80"
+			}
+		}
+		addrs[net.JoinHostPort(u.Hostname(), port)] = true
+	}
+	return addrs
+}
+
+// checkRequest applies the network policy to a request before it is sent or redirected.
+// The addresses of direct connections are checked again by the dialer.
+func (p *httpClientPool) checkRequest(req *http.Request) error {
+	if p.rules == nil {
+		return nil
+	}
+	host := normalizeHost(req.URL.Hostname())
+	if proxyURL, err := p.transport.Proxy(req); err == nil && proxyURL != nil {
+		_, err := p.rules.resolve(req.Context(), host)
+		return err
+	}
+	nameAllowed, err := p.rules.checkName(host)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.rules.checkIP(host, ip, nameAllowed)
+	}
+	return nil
+}
+
+// checkRedirect applies the network policy to redirects, with the redirect limit of net/http
+func (p *httpClientPool) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return p.checkRequest(req)
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"strings"
+)
+
+// GenerateOpenAPI builds an OpenAPI 3 document from the registered routes and their documentation
+func (e *Engine) GenerateOpenAPI(title, version string) map[string]interface{} {
+	paths := make(map[string]interface{})
+	usesAPIKey := false
+
+	for _, doc := range e.GetRouteDocs() {
+		// Wildcard routes are catch-alls rather than endpoints, and OpenAPI has no optional
+		// path parameters
+		if strings.Contains(doc.Path, "*") || hasOptionalSegment(doc.Path) {
+			continue
+		}
+		// OpenAPI cannot describe WebSocket routes, nor routes answering any method
+		if doc.Method == WebSocketMethod || doc.Method == AllMethods {
+			continue
+		}
+
+		openAPIPath := toOpenAPIPath(doc.Path)
+		item, ok := paths[openAPIPath].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[openAPIPath] = item
+		}
+		item[strings.ToLower(doc.Method)] = openAPIOperation(doc)
+		usesAPIKey = usesAPIKey || doc.Auth == RouteAuthAPIKey
+	}
+
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+	if usesAPIKey {
+		document["components"] = map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				RouteAuthAPIKey: map[string]interface{}{"type": "apiKey", "in": "header", "name": APIKeyHeader},
+			},
+		}
+	}
+	return document
+}
+
+func openAPIOperation(doc RouteDoc) map[string]interface{} {
+	operation := make(map[string]interface{})
+	if doc.Summary != "" {
+		operation["summary"] = doc.Summary
+	}
+	if doc.Description != "" {
+		operation["description"] = doc.Description
+	}
+	if len(doc.Tags) > 0 {
+		operation["tags"] = doc.Tags
+	}
+
+	if len(doc.Params) > 0 {
+		parameters := make([]map[string]interface{}, 0, len(doc.Params))
+		for _, param := range doc.Params {
+			typ := param.Type
+			if typ == "" {
+				typ = "string"
+			}
+			parameter := map[string]interface{}{
+				"name":     param.Name,
+				"in":       param.In,
+				"required": param.Required,
+				"schema":   map[string]interface{}{"type": typ},
+			}
+			if param.Description != "" {
+				parameter["description"] = param.Description
+			}
+			parameters = append(parameters, parameter)
+		}
+		operation["parameters"] = parameters
+	}
+
+	responses := make(map[string]interface{})
+	for code, description := range doc.Responses {
+		responses[code] = map[string]interface{}{"description": description}
+	}
+	if doc.ResponseSchema != nil {
+		success, ok := responses["200"].(map[string]interface{})
+		if !ok {
+			success = map[string]interface{}{"description": "Successful response"}
+			responses["200"] = success
+		}
+		success["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": doc.ResponseSchema},
+		}
+	}
+	if len(responses) == 0 {
+		// OpenAPI requires at least one response per operation
+		responses["default"] = map[string]interface{}{"description": "Response"}
+	}
+	operation["responses"] = responses
+
+	if doc.Auth == RouteAuthAPIKey {
+		operation["security"] = []map[string][]string{{RouteAuthAPIKey: {}}}
+	}
+
+	return operation
+}
+
+// toOpenAPIPath converts Express-style :param segments to OpenAPI {param} segments, dropping
+// their regular expressions
+func toOpenAPIPath(pattern string) string {
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		if m := routeParamRegexp.FindStringSubmatch(part); m != nil {
+			parts[i] = "{" + m[1] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// hasOptionalSegment reports whether a route pattern has an optional parameter
+func hasOptionalSegment(pattern string) bool {
+	route, err := compileRoutePattern(pattern)
+	if err != nil {
+		return false
+	}
+	for _, segment := range route.segments {
+		if segment.kind == segmentOptional {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultMaxOutputBytes is the default size limit of the stored result and console log
+const defaultMaxOutputBytes = 64 * 1024
+
+// OutputLimits caps the size of the result and console log stored per execution, so a
+// runaway console.log loop cannot bloat the system database
+type OutputLimits struct {
+	MaxResultBytes     int    // Stored result size limit, 0 for no limit
+	MaxConsoleLogBytes int    // Stored console log size limit, 0 for no limit
+	OverflowDir        string // Directory receiving the full output of truncated executions, "" to drop it; the storage takes precedence, see SetStorage
+	PageResultBytes    int    // Size above which array and object results are stored in pages, 0 to never page
+}
+
+// DefaultOutputLimits returns the limits used unless SetOutputLimits is called
+func DefaultOutputLimits() OutputLimits {
+	return OutputLimits{
+		MaxResultBytes:     defaultMaxOutputBytes,
+		MaxConsoleLogBytes: defaultMaxOutputBytes,
+		PageResultBytes:    defaultMaxOutputBytes,
+	}
+}
+
+// SetOutputLimits configures how much output is stored per execution
+func (e *Engine) SetOutputLimits(limits OutputLimits) error {
+	if limits.MaxResultBytes < 0 || limits.MaxConsoleLogBytes < 0 || limits.PageResultBytes < 0 {
+		return fmt.Errorf("output limits must not be negative")
+	}
+	if limits.OverflowDir != "" {
+		if err := os.MkdirAll(limits.OverflowDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	e.mu.Lock()
+	e.outputLimits = limits
+	e.mu.Unlock()
+	return nil
+}
+
+// outputStorage returns where the full output of truncated executions is written: the
+// output/ prefix of the engine's storage, else the overflow directory, nil for neither
+func (e *Engine) outputStorage(limits OutputLimits) (storage.Storage, error) {
+	if store := e.Storage(); store != nil {
+		return storage.WithPrefix(store, outputPrefix), nil
+	}
+	if limits.OverflowDir == "" {
+		return nil, nil
+	}
+	return storage.NewLocal(limits.OverflowDir)
+}
+
+// OpenOutputFile opens a full output file by the name recorded in an execution's truncation
+func (e *Engine) OpenOutputFile(ctx context.Context, name string) (io.ReadCloser, storage.Object, error) {
+	e.mu.RLock()
+	limits := e.outputLimits
+	e.mu.RUnlock()
+
+	store, err := e.outputStorage(limits)
+	if err != nil {
+		return nil, storage.Object{}, err
+	}
+	if store == nil {
+		return nil, storage.Object{}, fmt.Errorf("no output directory is configured")
+	}
+	if name == "" || name != path.Base(name) {
+		return nil, storage.Object{}, fmt.Errorf("invalid output file name %q", name)
+	}
+	return store.Get(ctx, name)
+}
+
+// limitExecutionOutput truncates the result and console log of an execution about to be
+// stored, records the original sizes and writes the full output to the overflow directory or
+// the storage
+func (e *Engine) limitExecutionOutput(req *repository.CreateExecutionRequest) {
+	e.mu.RLock()
+	limits := e.outputLimits
+	e.mu.RUnlock()
+
+	truncation := &repository.OutputTruncation{}
+	var fullResult, fullConsoleLog string
+	if req.Result != nil && limits.MaxResultBytes > 0 && len(*req.Result) > limits.MaxResultBytes {
+		fullResult = *req.Result
+		truncation.ResultBytes = len(fullResult)
+		truncated := TruncateOutput(fullResult, limits.MaxResultBytes)
+		req.Result = &truncated
+	}
+	if req.ConsoleLog != nil && limits.MaxConsoleLogBytes > 0 && len(*req.ConsoleLog) > limits.MaxConsoleLogBytes {
+		fullConsoleLog = *req.ConsoleLog
+		truncation.ConsoleLogBytes = len(fullConsoleLog)
+		truncated := TruncateOutput(fullConsoleLog, limits.MaxConsoleLogBytes)
+		req.ConsoleLog = &truncated
+	}
+	if truncation.ResultBytes == 0 && truncation.ConsoleLogBytes == 0 {
+		return
+	}
+	req.Truncation = truncation
+
+	log.Warn().
+		Str("sessionID", req.SessionID).
+		Int("resultBytes", truncation.ResultBytes).
+		Int("consoleLogBytes", truncation.ConsoleLogBytes).
+		Msg("Truncated stored execution output")
+
+	store, err := e.outputStorage(limits)
+	if err != nil {
+		log.Error().Err(err).Str("sessionID", req.SessionID).Msg("Failed to open the output directory")
+		return
+	}
+	if store == nil {
+		return
+	}
+
+	var content strings.Builder
+	if fullResult != "" {
+		fmt.Fprintf(&content, "=== result (%d bytes) ===\n%s\n", len(fullResult), fullResult)
+	}
+	if fullConsoleLog != "" {
+		fmt.Fprintf(&content, "=== console (%d bytes) ===\n%s\n", len(fullConsoleLog), fullConsoleLog)
+	}
+
+	name := fmt.Sprintf("%s-%d.txt", outputFileNameUnsafe.ReplaceAllString(req.SessionID, "_"), time.Now().UnixNano())
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := store.Put(ctx, name, []byte(content.String())); err != nil {
+		log.Error().Err(err).Str("sessionID", req.SessionID).Msg("Failed to write full execution output")
+		return
+	}
+	truncation.OutputFile = name
+}
+
+var outputFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// TruncateOutput keeps the first limit bytes of s, without splitting a UTF-8 character, and
+// appends a marker with the original size
+func TruncateOutput(s string, limit int) string {
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("\n... [truncated: %d of %d bytes stored]", cut, len(s))
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// ephemeralTimeout caps the run time of ephemeral jobs, so a runaway REPL line fails fast
+const ephemeralTimeout = 5 * time.Second
+
+// ExecutionPolicy restricts what a direct code execution may do. The zero value
+// places no restrictions.
+type ExecutionPolicy struct {
+	Timeout      time.Duration `json:"timeout" yaml:"timeout"`           // Interrupt the execution after this long (0 = no limit)
+	DenyRoutes   bool          `json:"denyRoutes" yaml:"denyRoutes"`     // Reject route and file handler registration
+	DenyDBWrites bool          `json:"denyDbWrites" yaml:"denyDbWrites"` // Reject db.exec()
+	DenyNetwork  bool          `json:"denyNetwork" yaml:"denyNetwork"`   // Reject fetch(), fetchAsync(), HTTP.* and graphql requests
+}
+
+// Restrict returns the policy allowing only what both policies allow, with the shorter timeout
+func (p ExecutionPolicy) Restrict(other ExecutionPolicy) ExecutionPolicy {
+	if other.Timeout > 0 && (p.Timeout <= 0 || other.Timeout < p.Timeout) {
+		p.Timeout = other.Timeout
+	}
+	p.DenyRoutes = p.DenyRoutes || other.DenyRoutes
+	p.DenyDBWrites = p.DenyDBWrites || other.DenyDBWrites
+	p.DenyNetwork = p.DenyNetwork || other.DenyNetwork
+	return p
+}
+
+// checkDBWrite returns an error if the running job may not change the database
+func (e *Engine) checkDBWrite() error {
+	if e.currentPolicy.DenyDBWrites {
+		return fmt.Errorf("db.exec is disabled by the execution policy")
+	}
+	return nil
+}
+
+// checkNetwork returns the error response of fetch and HTTP.* if the running job may not
+// make requests, nil if it may
+func (e *Engine) checkNetwork() map[string]interface{} {
+	if e.currentPolicy.DenyNetwork {
+		return map[string]interface{}{
+			"error": "network requests are disabled by the execution policy",
+			"ok":    false,
+		}
+	}
+	return nil
+}
+
+// checkRouteRegistration panics with a JavaScript error if the running job may not register routes
+func (e *Engine) checkRouteRegistration(method, path string) {
+	if err := e.CheckWritable(); err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("cannot register %s %s: %w", method, path, err)))
+	}
+	if e.currentPolicy.DenyRoutes {
+		panic(e.rt.NewGoError(fmt.Errorf("cannot register %s %s: route registration is disabled by the execution policy", method, path)))
+	}
+	if isTemplateSession(e.activeSession) {
+		panic(e.rt.NewGoError(fmt.Errorf("cannot register %s %s from an execution template runtime: execute without a template to register routes", method, path)))
+	}
+	if e.activeSession != "" {
+		// Session runtimes expire, so routes must live in the shared runtime
+		panic(e.rt.NewGoError(fmt.Errorf("cannot register %s %s from a session runtime: execute without a session ID to register routes", method, path)))
+	}
+}
+
+// interruptAfter interrupts the runtime once the timeout expires. The returned function
+// stops the timer and clears a pending interrupt; it must be called when the execution ends.
+// The timer checks under a lock that the execution is still running, so an interrupt firing
+// while the execution ends cannot land after ClearInterrupt and abort the next job.
+func (e *Engine) interruptAfter(timeout time.Duration) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+
+	rt := e.rt
+	var mu sync.Mutex
+	finished := false
+	timer := time.AfterFunc(timeout, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !finished {
+			rt.Interrupt(fmt.Sprintf("execution timed out after %s", timeout))
+		}
+	})
+	return func() {
+		timer.Stop()
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+		rt.ClearInterrupt()
+	}
+}
+
+// SetExecutionTimeout sets how long route handlers and code executions may run before they
+// are interrupted, unless the job or the route sets its own timeout (0 = no limit)
+func (e *Engine) SetExecutionTimeout(timeout time.Duration) {
+	e.mu.Lock()
+	e.executionTimeout = timeout
+	e.mu.Unlock()
+
+	for _, replica := range e.replicas {
+		replica.SetExecutionTimeout(timeout)
+	}
+}
+
+// jobTimeout returns how long a job may run: its own timeout, else the timeout option of its
+// route, else the engine default. A policy timeout and ephemeralTimeout cap the result.
+func (e *Engine) jobTimeout(job EvalJob) time.Duration {
+	timeout := job.Timeout
+	if timeout == 0 && job.Handler != nil {
+		timeout = job.Handler.Timeout
+	}
+	if timeout == 0 {
+		e.mu.RLock()
+		timeout = e.executionTimeout
+		e.mu.RUnlock()
+	}
+	if limit := e.jobPolicy(job).Timeout; limit > 0 && (timeout <= 0 || limit < timeout) {
+		timeout = limit
+	}
+	if job.Ephemeral && (timeout <= 0 || ephemeralTimeout < timeout) {
+		timeout = ephemeralTimeout
+	}
+	return timeout
+}
+
+// parseRouteTimeout reads the timeout option of a route: seconds as a number, as in fetch(),
+// or a duration string such as "500ms"
+func parseRouteTimeout(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timeout %q: %w", v, err)
+		}
+		return timeout, nil
+	default:
+		seconds, ok := numberOption(v)
+		if !ok {
+			return 0, fmt.Errorf("timeout must be a number of seconds or a duration string, got %T", value)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+}
+
+// errSettleTimeout reports a promise returned by a handler that did not settle in time
+var errSettleTimeout = errors.New("promise did not settle within the execution timeout")
+
+// IsExecutionTimeout reports whether an execution error means the script was interrupted
+// because it ran longer than its timeout
+func IsExecutionTimeout(err error) bool {
+	var interrupted *goja.InterruptedError
+	return errors.As(err, &interrupted) || errors.Is(err, errSettleTimeout)
+}
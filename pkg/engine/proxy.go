@@ -0,0 +1,356 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Reverse proxy
+//
+// proxy forwards requests to an upstream server, for API gateway style scripts that add
+// auth, rate limits or caching in front of other services:
+//
+//	app.all('/api/*', proxy('http://orders:8080', { pathRewrite: { '^/api': '' } }));
+//
+//	app.get('/search', async (req, res) => {
+//	  if (!req.cookies.session) return res.status(401).json({ error: 'login first' });
+//	  await proxy.forward(req, res, 'http://search:9200', { headers: { Cookie: null } });
+//	});
+//
+// The method, headers, query and body of the request are sent upstream, and the status,
+// headers and body of the upstream response are streamed back as they arrive. Hop-by-hop
+// headers are dropped and X-Forwarded-For, -Host and -Proto are added. proxy.forward()
+// returns a promise of { status }, which the handler returns or awaits; when the upstream
+// server cannot be reached the client gets a 502 and the promise { status: 502, error }.
+
+// hopHeaders are the headers of one connection, which a proxy must not forward
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// proxyOptions are the options of proxy() and proxy.forward()
+type proxyOptions struct {
+	pathRewrite  []proxyRewrite    // Rewrites of the request path, in order
+	headers      map[string]string // Request headers to set
+	removeHeader []string          // Request headers to remove, given as null
+	preserveHost bool              // Send the Host of the original request instead of the target's
+	xForwarded   bool              // Add the X-Forwarded-* headers
+	timeout      time.Duration     // Limit of the upstream request, 0 for the handler timeout only
+}
+
+// proxyRewrite replaces the matches of a regular expression in the request path
+type proxyRewrite struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// setupProxyBindings installs proxy
+func (e *Engine) setupProxyBindings() {
+	proxy := e.rt.ToValue(e.proxyHandler).ToObject(e.rt)
+	if err := proxy.Set("forward", e.proxyForward); err != nil {
+		log.Error().Err(err).Msg("Failed to set proxy.forward binding")
+	}
+	if err := e.rt.Set("proxy", proxy); err != nil {
+		log.Error().Err(err).Msg("Failed to set proxy binding")
+	}
+}
+
+// proxyHandler implements proxy(target, [options]): a handler forwarding every request
+func (e *Engine) proxyHandler(call goja.FunctionCall) goja.Value {
+	target, opts := e.parseProxyArguments("proxy", call.Argument(0), call.Argument(1))
+	return e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		return e.forward(fc.Argument(0), fc.Argument(1), target, opts)
+	})
+}
+
+// proxyForward implements proxy.forward(req, res, target, [options])
+func (e *Engine) proxyForward(call goja.FunctionCall) goja.Value {
+	target, opts := e.parseProxyArguments("proxy.forward", call.Argument(2), call.Argument(3))
+	return e.forward(call.Argument(0), call.Argument(1), target, opts)
+}
+
+// parseProxyArguments parses the target URL and options
+func (e *Engine) parseProxyArguments(fn string, targetValue, optionsValue goja.Value) (*url.URL, proxyOptions) {
+	if goja.IsUndefined(targetValue) || goja.IsNull(targetValue) {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s() takes the URL of the upstream server", fn)))
+	}
+	target, err := url.Parse(targetValue.String())
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s(): invalid upstream URL %q, use http://host[:port][/path]", fn, targetValue.String())))
+	}
+
+	opts := proxyOptions{xForwarded: true}
+	if goja.IsUndefined(optionsValue) || goja.IsNull(optionsValue) {
+		return target, opts
+	}
+	obj := optionsValue.ToObject(e.rt)
+	options, _ := optionsValue.Export().(map[string]interface{})
+
+	// Patterns of pathRewrite are applied in the order they were written
+	if rewrites, ok := obj.Get("pathRewrite").(*goja.Object); ok {
+		for _, key := range rewrites.Keys() {
+			pattern, err := regexp.Compile(key)
+			if err != nil {
+				panic(e.rt.NewTypeError(fmt.Sprintf("%s(): invalid pathRewrite pattern %q: %v", fn, key, err)))
+			}
+			opts.pathRewrite = append(opts.pathRewrite, proxyRewrite{pattern: pattern, replacement: rewrites.Get(key).String()})
+		}
+	}
+	if headers, ok := options["headers"].(map[string]interface{}); ok {
+		opts.headers = make(map[string]string, len(headers))
+		for name, value := range headers {
+			if value == nil {
+				opts.removeHeader = append(opts.removeHeader, name)
+				continue
+			}
+			opts.headers[name] = fmt.Sprint(value)
+		}
+	}
+	if v, ok := options["preserveHost"].(bool); ok {
+		opts.preserveHost = v
+	}
+	if v, ok := options["xForwarded"].(bool); ok {
+		opts.xForwarded = v
+	}
+	switch v := options["timeout"].(type) {
+	case int64:
+		opts.timeout = time.Duration(v) * time.Second
+	case float64:
+		opts.timeout = time.Duration(v * float64(time.Second))
+	}
+	return target, opts
+}
+
+// forward starts forwarding the request of a handler and returns the promise of its outcome
+func (e *Engine) forward(reqValue, resValue goja.Value, target *url.URL, opts proxyOptions) goja.Value {
+	res, ok := resValue.Export().(*ExpressResponse)
+	if !ok || res.request == nil || res.writer == nil {
+		panic(e.rt.NewTypeError("proxy: pass the req and res of a route handler"))
+	}
+	if res.sent {
+		panic(e.rt.NewTypeError("proxy: response already sent"))
+	}
+	path := res.request.URL.Path
+	if req, ok := reqValue.Export().(*ExpressRequest); ok && req.Path != "" {
+		path = req.Path
+	}
+
+	upstream, err := e.newUpstreamRequest(res.request, path, target, opts)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+
+	// The response now belongs to the proxy. Its writes are stopped once the handler is done,
+	// e.g. when it timed out, since the response writer must not be used after that.
+	res.sent = true
+	writer := &proxyWriter{w: res.writer}
+	for key, value := range res.Headers {
+		writer.w.Header().Set(key, value)
+	}
+	for _, cookie := range res.Cookies {
+		http.SetCookie(writer.w, cookie)
+	}
+	ctx, cancel := context.WithCancel(upstream.Context())
+	upstream = upstream.WithContext(ctx)
+	res.closeHooks = append(res.closeHooks, func() {
+		cancel()
+		writer.stop()
+	})
+
+	e.mu.RLock()
+	pool := e.httpClient
+	e.mu.RUnlock()
+	timeout := opts.timeout
+	if timeout <= 0 {
+		timeout = noTimeout
+	}
+
+	return e.rt.ToValue(e.startAsync(func() (interface{}, error) {
+		resp, done, err := pool.do(upstream, timeout, "")
+		if err != nil {
+			log.Warn().Err(err).Str("upstream", upstream.URL.String()).Msg("Proxied request failed")
+			writer.fail(http.StatusBadGateway, "Bad Gateway")
+			return map[string]interface{}{"status": http.StatusBadGateway, "error": err.Error()}, nil
+		}
+		defer done()
+		defer resp.Body.Close()
+
+		writer.relay(resp)
+		return map[string]interface{}{"status": resp.StatusCode}, nil
+	}))
+}
+
+// newUpstreamRequest builds the request sent to the upstream server
+func (e *Engine) newUpstreamRequest(r *http.Request, path string, target *url.URL, opts proxyOptions) (*http.Request, error) {
+	for _, rewrite := range opts.pathRewrite {
+		path = rewrite.pattern.ReplaceAllString(path, rewrite.replacement)
+	}
+	upstreamURL := *target
+	upstreamURL.Path = strings.TrimSuffix(target.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+	upstreamURL.RawPath = ""
+	upstreamURL.RawQuery = r.URL.RawQuery
+	if target.RawQuery != "" {
+		upstreamURL.RawQuery = target.RawQuery
+		if r.URL.RawQuery != "" {
+			upstreamURL.RawQuery += "&" + r.URL.RawQuery
+		}
+	}
+
+	// The body was buffered when the request object was created
+	var body io.Reader
+	if r.Body != nil {
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: failed to read request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		if len(data) > 0 {
+			body = bytes.NewReader(data)
+		}
+	}
+
+	upstream, err := http.NewRequestWithContext(r.Context(), r.Method, upstreamURL.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	upstream.Header = r.Header.Clone()
+	removeHopHeaders(upstream.Header)
+	if opts.preserveHost {
+		upstream.Host = r.Host
+	}
+	if opts.xForwarded {
+		clientIP := e.ClientIP(r)
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" && e.root().trustedProxy(remoteHost(r)) {
+			clientIP = prior + ", " + remoteHost(r)
+		}
+		upstream.Header.Set("X-Forwarded-For", clientIP)
+		upstream.Header.Set("X-Forwarded-Host", r.Host)
+		proto := "http"
+		if r.TLS != nil {
+			proto = "https"
+		}
+		upstream.Header.Set("X-Forwarded-Proto", proto)
+	}
+	for _, name := range opts.removeHeader {
+		upstream.Header.Del(name)
+	}
+	for name, value := range opts.headers {
+		upstream.Header.Set(name, value)
+	}
+	return upstream, nil
+}
+
+// remoteHost returns the IP of the peer of a request
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// removeHopHeaders removes the hop-by-hop headers, including those named by Connection
+func removeHopHeaders(header http.Header) {
+	for _, field := range strings.Split(header.Get("Connection"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			header.Del(field)
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// proxyWriter writes a proxied response until the handler is done with the response
+type proxyWriter struct {
+	mu          sync.Mutex
+	w           http.ResponseWriter
+	wroteHeader bool
+	stopped     bool
+}
+
+// relay sends the status, headers and body of the upstream response, flushing as data
+// arrives so event streams pass through
+func (p *proxyWriter) relay(resp *http.Response) {
+	p.mu.Lock()
+	if p.stopped {
+		p.mu.Unlock()
+		return
+	}
+	removeHopHeaders(resp.Header)
+	for key, values := range resp.Header {
+		p.w.Header()[key] = values
+	}
+	p.w.WriteHeader(resp.StatusCode)
+	p.wroteHeader = true
+	p.mu.Unlock()
+
+	flusher, _ := p.w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			p.mu.Lock()
+			if p.stopped {
+				p.mu.Unlock()
+				return
+			}
+			_, writeErr := p.w.Write(buf[:n])
+			if writeErr == nil && flusher != nil {
+				flusher.Flush()
+			}
+			p.mu.Unlock()
+			if writeErr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.Debug().Err(err).Msg("Proxied response body ended early")
+			}
+			return
+		}
+	}
+}
+
+// fail answers with an error status unless the response was started
+func (p *proxyWriter) fail(status int, message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped || p.wroteHeader {
+		return
+	}
+	http.Error(p.w, message, status)
+	p.wroteHeader = true
+}
+
+// stop ends the writes of the proxy. A response that was not started yet, because the
+// handler timed out waiting for the upstream server, is answered with 504.
+func (p *proxyWriter) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.wroteHeader && !p.stopped {
+		http.Error(p.w, "Gateway Timeout", http.StatusGatewayTimeout)
+	}
+	p.stopped = true
+}
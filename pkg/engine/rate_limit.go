@@ -0,0 +1,384 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	databasemod "github.com/go-go-golems/go-go-goja/modules/database"
+	"github.com/rs/zerolog/log"
+)
+
+// Rate limiting
+//
+// rateLimit(options) returns middleware limiting how often a client may call the routes it
+// is added to, as the express-rate-limit package does:
+//
+//	app.use(rateLimit({ windowMs: 60000, max: 100 }));                  // every route, per IP
+//	app.post('/login', rateLimit({ windowMs: 900000, max: 5 }), login); // one route
+//	app.get('/reports', rateLimit({ max: 10, keyBy: 'apiKey', store: 'db' }), reports);
+//	app.post('/orders', rateLimit({ max: 3, keyBy: req => req.headers['x-user'] }), order);
+//
+// Options: windowMs (default one minute), max requests per window (default 60), keyBy ('ip',
+// 'apiKey' for the configured API key of the request, else the IP, or a function of req
+// returning the key), store ('memory' or 'db' to count in the application database, shared
+// by the engines using it) and name (limiters with the same name share their counters; by
+// default the place rateLimit() is called from, so pool runtimes and reloads share them).
+//
+// Limits keyed by 'ip' or 'apiKey' are checked in Go before the request reaches the
+// dispatcher, like the rateLimit route option; a keyBy function runs as middleware. Requests
+// over the limit get 429 with a Retry-After header and are not counted.
+
+// rateLimitSymbol tags the middleware functions returned by rateLimit() with their limiter
+var rateLimitSymbol = goja.NewSymbol("rateLimit.limiter")
+
+// Rate limit stores
+const (
+	RateLimitStoreMemory = "memory" // Count in the memory of the engine
+	RateLimitStoreDB     = "db"     // Count in the application database
+)
+
+// rateLimitTable holds the counters of limiters using the database store
+const rateLimitTable = "_rate_limits"
+
+// RateLimiter counts requests per client in fixed windows
+type RateLimiter struct {
+	Requests int           // Requests allowed per window
+	Window   time.Duration // Length of a window
+	By       string        // RateLimitByIP or RateLimitByAPIKey
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+
+	byFunction bool                  // Whether a keyBy function of the middleware picks the key instead of By
+	db         *databasemod.DBModule // Application database counting the requests, nil to count in memory
+	name       string                // Name of the limiter, also of its rows in the database
+	lastPrune  time.Time             // When expired database rows were last deleted
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// NewRateLimiter returns a limiter counting requests per window in memory
+func NewRateLimiter(requests int, window time.Duration, by string) *RateLimiter {
+	return &RateLimiter{Requests: requests, Window: window, By: by, windows: make(map[string]*rateWindow)}
+}
+
+// ParseRateLimit reads a limit written as requests/window, e.g. "30/1m", counting per client IP
+// or per API key when the request has a valid one
+func ParseRateLimit(spec string) (*RateLimiter, error) {
+	requestsText, windowText, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return nil, fmt.Errorf("rate limit %q must look like requests/window, e.g. 30/1m", spec)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(requestsText))
+	if err != nil || requests < 1 {
+		return nil, fmt.Errorf("rate limit %q needs a positive number of requests", spec)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowText))
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("rate limit %q needs a positive window like 1m", spec)
+	}
+	return NewRateLimiter(requests, window, RateLimitByAPIKey), nil
+}
+
+// Allow counts a request of client and reports whether it is within the limit. If not, it
+// also returns how long until the window resets.
+func (l *RateLimiter) Allow(client string, now time.Time) (bool, time.Duration) {
+	if l.db != nil {
+		return l.allowInDB(client, now)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, ok := l.windows[client]
+	if !ok || now.Sub(w.start) >= l.Window {
+		if !ok && len(l.windows) >= maxRateLimitClients {
+			l.pruneLocked(now)
+		}
+		w = &rateWindow{start: now}
+		l.windows[client] = w
+	}
+	if w.count >= l.Requests {
+		return false, w.start.Add(l.Window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+// pruneLocked drops the windows that have ended
+func (l *RateLimiter) pruneLocked(now time.Time) {
+	for client, w := range l.windows {
+		if now.Sub(w.start) >= l.Window {
+			delete(l.windows, client)
+		}
+	}
+}
+
+// allowInDB counts a request in the database with one statement, so engines sharing the
+// database share the limit. The count stops one above the limit, which tells rejected
+// requests apart without counting them. If the database fails the request is allowed.
+func (l *RateLimiter) allowInDB(client string, now time.Time) (bool, time.Duration) {
+	nowMs, windowMs := now.UnixMilli(), l.Window.Milliseconds()
+	rows, err := l.db.Query(`INSERT INTO `+rateLimitTable+` (limiter, client, window_start, count) VALUES (?, ?, ?, 1)
+		ON CONFLICT (limiter, client) DO UPDATE SET
+			window_start = CASE WHEN ? - window_start >= ? THEN excluded.window_start ELSE window_start END,
+			count = CASE WHEN ? - window_start >= ? THEN 1 WHEN count <= ? THEN count + 1 ELSE count END
+		RETURNING window_start, count`,
+		l.name, client, nowMs, nowMs, windowMs, nowMs, windowMs, l.Requests)
+	if err != nil || len(rows) == 0 {
+		log.Warn().Err(err).Str("limiter", l.name).Msg("Failed to count request in the database, allowing it")
+		return true, 0
+	}
+	start, _ := numberOption(rows[0]["window_start"])
+	count, _ := numberOption(rows[0]["count"])
+
+	l.mu.Lock()
+	prune := now.Sub(l.lastPrune) >= l.Window
+	if prune {
+		l.lastPrune = now
+	}
+	l.mu.Unlock()
+	if prune {
+		if _, err := l.db.Exec("DELETE FROM "+rateLimitTable+" WHERE limiter = ? AND window_start <= ?", l.name, nowMs-windowMs); err != nil {
+			log.Warn().Err(err).Str("limiter", l.name).Msg("Failed to delete expired rate limit counters")
+		}
+	}
+
+	if int(count) > l.Requests {
+		return false, time.UnixMilli(int64(start)).Add(l.Window).Sub(now)
+	}
+	return true, 0
+}
+
+// sameAs reports whether two limiters count the same way, so one can keep the other's counters
+func (l *RateLimiter) sameAs(other *RateLimiter) bool {
+	return l.Requests == other.Requests && l.Window == other.Window && l.By == other.By &&
+		(l.db == nil) == (other.db == nil) && l.byFunction == other.byFunction
+}
+
+// RetryAfterHeader formats a wait for the Retry-After header, in whole seconds
+func RetryAfterHeader(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// ClientKey returns the client a limiter counts a request under. Only configured API keys get
+// their own bucket, so clients cannot rotate made-up keys.
+func (e *Engine) ClientKey(l *RateLimiter, r *http.Request) string {
+	if l.By == RateLimitByAPIKey {
+		if apiKey := RequestAPIKey(r); e.ValidAPIKey(apiKey) {
+			return "key:" + apiKey
+		}
+	}
+	return e.ClientIP(r)
+}
+
+// rateLimit implements rateLimit(options): middleware carrying its limiter, see rateLimiterOf
+func (e *Engine) rateLimit(options ...goja.Value) goja.Value {
+	opts := map[string]interface{}{}
+	var keyFn goja.Callable
+	if len(options) > 0 && !goja.IsUndefined(options[0]) && !goja.IsNull(options[0]) {
+		obj, ok := options[0].(*goja.Object)
+		if !ok {
+			panic(e.rt.NewTypeError("rateLimit() takes an options object"))
+		}
+		if fn, ok := goja.AssertFunction(obj.Get("keyBy")); ok {
+			keyFn = fn
+		}
+		opts, _ = obj.Export().(map[string]interface{})
+	}
+
+	limiter, err := e.parseRateLimitOptions(opts, keyFn)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("rateLimit(): %v", err)))
+	}
+	limiter = e.sharedRateLimiter(limiter)
+
+	fn := e.rt.ToValue(func(fc goja.FunctionCall) goja.Value {
+		// Limits keyed by IP or API key were checked before the handler ran
+		if keyFn == nil {
+			return goja.Undefined()
+		}
+		res, ok := fc.Argument(1).Export().(*ExpressResponse)
+		if !ok || res.request == nil {
+			return goja.Undefined()
+		}
+		key, err := keyFn(goja.Undefined(), fc.Argument(0))
+		if err != nil {
+			panic(err)
+		}
+		// Requests without a key count under their IP
+		client := e.root().ClientIP(res.request)
+		if !goja.IsUndefined(key) && !goja.IsNull(key) && key.String() != "" {
+			client = "fn:" + key.String()
+		}
+		if ok, retryAfter := limiter.Allow(client, time.Now()); !ok {
+			res.Set("Retry-After", RetryAfterHeader(retryAfter))
+			res.Status(http.StatusTooManyRequests)
+			if err := res.Json(map[string]interface{}{"error": "Rate limit exceeded"}); err != nil {
+				panic(e.rt.NewGoError(err))
+			}
+		}
+		return goja.Undefined()
+	}).ToObject(e.rt)
+	if err := fn.DefineDataPropertySymbol(rateLimitSymbol, e.rt.ToValue(limiter), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return fn
+}
+
+// parseRateLimitOptions reads the options of rateLimit()
+func (e *Engine) parseRateLimitOptions(options map[string]interface{}, keyFn goja.Callable) (*RateLimiter, error) {
+	window := time.Minute
+	if value, ok := options["windowMs"]; ok && value != nil {
+		ms, ok := numberOption(value)
+		if !ok || ms <= 0 {
+			return nil, fmt.Errorf("windowMs must be a positive number of milliseconds")
+		}
+		window = time.Duration(ms * float64(time.Millisecond))
+	}
+
+	requests := 60
+	if value, ok := options["max"]; ok && value != nil {
+		n, ok := numberOption(value)
+		if !ok || n < 1 {
+			return nil, fmt.Errorf("max must be a positive number")
+		}
+		requests = int(n)
+	}
+
+	limiter := NewRateLimiter(requests, window, RateLimitByIP)
+	switch v := options["keyBy"].(type) {
+	case nil:
+	case string:
+		if v != RateLimitByIP && v != RateLimitByAPIKey {
+			return nil, fmt.Errorf("unsupported keyBy %q, expected %q, %q or a function", v, RateLimitByIP, RateLimitByAPIKey)
+		}
+		limiter.By = v
+	default:
+		if keyFn == nil {
+			return nil, fmt.Errorf("keyBy must be a string or a function, got %T", v)
+		}
+		limiter.byFunction = true
+	}
+
+	switch store := options["store"]; store {
+	case nil, RateLimitStoreMemory:
+	case RateLimitStoreDB:
+		if e.dbModule == nil {
+			return nil, fmt.Errorf("store 'db' needs the application database")
+		}
+		if _, err := e.dbModule.Exec(`CREATE TABLE IF NOT EXISTS ` + rateLimitTable + ` (
+			limiter TEXT NOT NULL,
+			client TEXT NOT NULL,
+			window_start INTEGER NOT NULL,
+			count INTEGER NOT NULL,
+			PRIMARY KEY (limiter, client)
+		)`); err != nil {
+			return nil, fmt.Errorf("failed to create the %s table: %w", rateLimitTable, err)
+		}
+		limiter.db = e.dbModule
+	default:
+		return nil, fmt.Errorf("unsupported store %v, expected %q or %q", store, RateLimitStoreMemory, RateLimitStoreDB)
+	}
+
+	switch name := options["name"].(type) {
+	case nil:
+		limiter.name = e.callSite()
+	case string:
+		limiter.name = name
+	default:
+		return nil, fmt.Errorf("name must be a string, got %T", name)
+	}
+	return limiter, nil
+}
+
+// callSite returns the script position rateLimit() is called from
+func (e *Engine) callSite() string {
+	for _, frame := range e.rt.CaptureCallStack(10, nil) {
+		if frame.SrcName() != "<native>" {
+			return frame.Position().String()
+		}
+	}
+	return "rateLimit"
+}
+
+// sharedRateLimiter returns the limiter already registered under the name of l if it counts
+// the same way, so runtimes of the pool and reloaded scripts share the counters, else l
+func (e *Engine) sharedRateLimiter(l *RateLimiter) *RateLimiter {
+	root := e.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if existing, ok := root.rateLimiters[l.name]; ok && existing.sameAs(l) {
+		return existing
+	}
+	if root.rateLimiters == nil {
+		root.rateLimiters = make(map[string]*RateLimiter)
+	}
+	root.rateLimiters[l.name] = l
+	return l
+}
+
+// rateLimiterOf returns the limiter of a middleware function returned by rateLimit(), nil
+// for others
+func rateLimiterOf(fn goja.Value) *RateLimiter {
+	obj, ok := fn.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	value := obj.GetSymbol(rateLimitSymbol)
+	if value == nil {
+		return nil
+	}
+	limiter, _ := value.Export().(*RateLimiter)
+	return limiter
+}
+
+// setGlobalRateLimit sets the limiter app.use(rateLimit()) applies to routes without their own
+func (e *Engine) setGlobalRateLimit(limiter *RateLimiter) {
+	if limiter.byFunction {
+		panic(e.rt.NewTypeError("app.use(rateLimit()) needs keyBy 'ip' or 'apiKey'; add a limiter with a keyBy function to the routes instead"))
+	}
+	root := e.root()
+	root.mu.Lock()
+	root.globalRateLimit = limiter
+	root.mu.Unlock()
+	if e.primary == nil {
+		log.Info().Int("max", limiter.Requests).Dur("window", limiter.Window).Str("by", limiter.By).Msg("Enabled rate limit for all routes")
+	}
+}
+
+// RouteRateLimit returns the limiter the server applies to a route before its handler: the
+// route's own, else the one set with app.use(rateLimit()). Nil means unlimited.
+func (e *Engine) RouteRateLimit(m *RouteMiddleware) *RateLimiter {
+	if m != nil && m.RateLimit != nil {
+		return m.RateLimit
+	}
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.globalRateLimit
+}
+
+// SetExecuteRateLimit limits how often a client may call /v1/execute, nil for no limit. It is
+// read when the admin routes are set up.
+func (e *Engine) SetExecuteRateLimit(limiter *RateLimiter) {
+	e.mu.Lock()
+	e.executeRateLimit = limiter
+	e.mu.Unlock()
+}
+
+// ExecuteRateLimit returns the limit of /v1/execute, nil if unlimited
+func (e *Engine) ExecuteRateLimit() *RateLimiter {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.executeRateLimit
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrReadOnly is wrapped by the errors of changes refused while read-only mode is on
+var ErrReadOnly = errors.New("the server is read-only")
+
+// ReadOnlyStatus describes whether the server is frozen. While read-only mode is on, the
+// routes registered so far keep serving, but no new code runs through /v1/execute, the REPL,
+// MCP executeJS or notebooks, and no routes are registered, replaced or unloaded. Use it for
+// production freezes; maintenance mode pauses the routes instead.
+type ReadOnlyStatus struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message,omitempty"`
+	Since   *time.Time `json:"since,omitempty"`
+}
+
+// SetReadOnly turns read-only mode on or off; message tells clients why changes are refused
+func (e *Engine) SetReadOnly(enabled bool, message string) ReadOnlyStatus {
+	root := e.root()
+	root.mu.Lock()
+	if enabled {
+		if !root.readOnly.Enabled {
+			now := time.Now()
+			root.readOnly.Since = &now
+		}
+		root.readOnly.Enabled = true
+		root.readOnly.Message = message
+	} else {
+		root.readOnly = ReadOnlyStatus{}
+	}
+	status := root.readOnly
+	root.mu.Unlock()
+
+	if enabled {
+		log.Warn().Str("message", message).Msg("Read-only mode enabled, code execution and route changes are refused")
+	} else {
+		log.Info().Msg("Read-only mode disabled, code execution and route changes are allowed again")
+	}
+	return status
+}
+
+// ReadOnly returns the current read-only mode status
+func (e *Engine) ReadOnly() ReadOnlyStatus {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.readOnly
+}
+
+// CheckWritable returns an error wrapping ErrReadOnly while read-only mode is on
+func (e *Engine) CheckWritable() error {
+	status := e.ReadOnly()
+	if !status.Enabled {
+		return nil
+	}
+	if status.Message != "" {
+		return fmt.Errorf("%w: %s", ErrReadOnly, status.Message)
+	}
+	return ErrReadOnly
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Express request helpers: header lookup and content negotiation, as in Express
+//
+//	req.get('Content-Type')                 // 'application/json', undefined if absent
+//	req.accepts('json', 'html')             // the best type for the Accept header, or false
+//	req.is('json')                          // 'json' if the body is JSON, false if not, null without a body
+//
+// Types are MIME types, wildcards like 'text/*' or extensions like 'json' and 'html'.
+
+// shortMIMETypes resolves the names mime.TypeByExtension may not know on every system
+var shortMIMETypes = map[string]string{
+	"json":       "application/json",
+	"html":       "text/html",
+	"text":       "text/plain",
+	"txt":        "text/plain",
+	"xml":        "application/xml",
+	"csv":        "text/csv",
+	"form":       "application/x-www-form-urlencoded",
+	"urlencoded": "application/x-www-form-urlencoded",
+	"multipart":  "multipart/*",
+}
+
+// Get implements req.get(name): the value of a request header, undefined if it is absent.
+// Referer and Referrer are interchangeable.
+func (r *ExpressRequest) Get(name string) interface{} {
+	name = strings.ToLower(name)
+	if name == "referer" || name == "referrer" {
+		if value, ok := r.Headers["referer"]; ok {
+			return value
+		}
+		name = "referrer"
+	}
+	if value, ok := r.Headers[name]; ok {
+		return value
+	}
+	return goja.Undefined()
+}
+
+// Header implements req.header(name), an alias of req.get()
+func (r *ExpressRequest) Header(name string) interface{} {
+	return r.Get(name)
+}
+
+// headerString returns a request header, multiple values joined with commas
+func (r *ExpressRequest) headerString(name string) string {
+	switch value := r.Headers[name].(type) {
+	case string:
+		return value
+	case []string:
+		return strings.Join(value, ", ")
+	}
+	return ""
+}
+
+// Accepts implements req.accepts(types...): the type, as given, the Accept header prefers,
+// false if it accepts none of them. Types may also be passed as one array. Without types
+// it returns the accepted types, most preferred first.
+func (r *ExpressRequest) Accepts(types ...interface{}) interface{} {
+	ranges := parseAccept(r.headerString("accept"))
+	offers := flattenTypes(types)
+	if len(offers) == 0 {
+		accepted := make([]string, 0, len(ranges))
+		for _, ar := range ranges {
+			if ar.q > 0 {
+				accepted = append(accepted, ar.mediaType)
+			}
+		}
+		return accepted
+	}
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		mediaType := resolveMIMEType(offer)
+		if mediaType == "" {
+			continue
+		}
+		if q := acceptQuality(ranges, mediaType); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	if best == "" {
+		return false
+	}
+	return best
+}
+
+// Is implements req.is(types...): the matching type if the Content-Type of the body is one
+// of types, false if it is not, and null if the request has no body. A type given with a
+// wildcard returns the actual media type.
+func (r *ExpressRequest) Is(types ...interface{}) interface{} {
+	if !r.withBody {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(r.headerString("content-type"))
+	if err != nil {
+		return false
+	}
+	offers := flattenTypes(types)
+	if len(offers) == 0 {
+		return mediaType
+	}
+	for _, offer := range offers {
+		pattern := resolveMIMEType(offer)
+		if pattern == "" || !mimeMatches(pattern, mediaType) {
+			continue
+		}
+		if strings.Contains(offer, "*") {
+			return mediaType
+		}
+		return offer
+	}
+	return false
+}
+
+// acceptRange is a media range of an Accept header
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept reads an Accept header into its media ranges, highest quality first
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching a media type
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		if !mimeMatches(ar.mediaType, mediaType) {
+			continue
+		}
+		s := 2
+		if ar.mediaType == "*/*" {
+			s = 0
+		} else if strings.HasSuffix(ar.mediaType, "/*") {
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q
+}
+
+// mimeMatches reports whether a media type matches a pattern like text/*, */json or
+// application/*+json
+func mimeMatches(pattern, mediaType string) bool {
+	pType, pSub, ok := strings.Cut(pattern, "/")
+	if !ok {
+		return false
+	}
+	mType, mSub, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return false
+	}
+	if pType != "*" && pType != mType {
+		return false
+	}
+	if pSub == "*" || pSub == mSub {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(pSub, "*+"); ok {
+		return strings.HasSuffix(mSub, "+"+suffix)
+	}
+	return false
+}
+
+// resolveMIMEType turns an extension like json or .html into its media type; MIME types
+// and wildcards stay as they are
+func resolveMIMEType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if strings.Contains(t, "/") {
+		return t
+	}
+	if suffix, ok := strings.CutPrefix(t, "+"); ok {
+		return "*/*+" + suffix
+	}
+	t = strings.TrimPrefix(t, ".")
+	if mediaType, ok := shortMIMETypes[t]; ok {
+		return mediaType
+	}
+	if byExtension := mime.TypeByExtension("." + t); byExtension != "" {
+		if mediaType, _, err := mime.ParseMediaType(byExtension); err == nil {
+			return mediaType
+		}
+	}
+	return ""
+}
+
+// flattenTypes reads type arguments given one by one or as an array
+func flattenTypes(types []interface{}) []string {
+	var list []string
+	for _, t := range types {
+		switch v := t.(type) {
+		case string:
+			list = append(list, v)
+		case []interface{}:
+			list = append(list, flattenTypes(v)...)
+		}
+	}
+	return list
+}
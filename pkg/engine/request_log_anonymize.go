@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Request log anonymization
+//
+// Request logs name who sent a request: the client IP, forwarding headers, and often user
+// ids or emails in headers, query parameters and JSON bodies. With anonymization (jesus
+// serve --log-anonymize-ips, --log-anonymize-fields) these are hidden before a request is
+// logged, so the admin log views, exports and archives can be shared:
+//
+//   - IPs are truncated to their network (the /24 of IPv4, the /48 of IPv6) or hashed.
+//   - Values of the listed fields are hashed wherever the name appears as header, query
+//     parameter or key of a JSON body.
+//
+// Hashes are keyed with a salt, so the same client or user gets the same hash and can be
+// followed across requests, but a hash cannot be reversed by hashing guessed values. Without
+// a configured salt a random one is used, and hashes change when the server restarts.
+
+// IP anonymization modes
+const (
+	AnonymizeIPNone     = ""         // Log IPs as they are
+	AnonymizeIPTruncate = "truncate" // Zero the host part: the /24 of IPv4, the /48 of IPv6
+	AnonymizeIPHash     = "hash"     // Replace IPs with a keyed hash
+)
+
+// anonymizedIPHeaders are the headers naming the client IP
+var anonymizedIPHeaders = map[string]bool{"X-Forwarded-For": true, "X-Real-Ip": true, "True-Client-Ip": true, "Cf-Connecting-Ip": true}
+
+// LogAnonymization configures how request logs hide IPs and user identifiers
+type LogAnonymization struct {
+	IPs    string   // One of the AnonymizeIP* modes
+	Fields []string // Header, query parameter and JSON body field names whose values are hashed
+	Salt   string   // Key of the hashes, random if ""
+}
+
+// logAnonymizer applies a LogAnonymization to request logs
+type logAnonymizer struct {
+	ips    string
+	fields map[string]bool // Lowercase field names
+	salt   []byte
+}
+
+// newLogAnonymizer validates the configuration; nothing to anonymize gives nil
+func newLogAnonymizer(config LogAnonymization) (*logAnonymizer, error) {
+	switch config.IPs {
+	case AnonymizeIPNone, AnonymizeIPTruncate, AnonymizeIPHash:
+	default:
+		return nil, fmt.Errorf("invalid IP anonymization %q, use %s or %s", config.IPs, AnonymizeIPTruncate, AnonymizeIPHash)
+	}
+
+	fields := make(map[string]bool)
+	for _, field := range config.Fields {
+		if field = strings.TrimSpace(field); field != "" {
+			fields[strings.ToLower(field)] = true
+		}
+	}
+	if config.IPs == AnonymizeIPNone && len(fields) == 0 {
+		return nil, nil
+	}
+
+	salt := []byte(config.Salt)
+	if len(salt) == 0 {
+		salt = make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("failed to generate anonymization salt: %w", err)
+		}
+	}
+	return &logAnonymizer{ips: config.IPs, fields: fields, salt: salt}, nil
+}
+
+// SetAnonymization hides IPs and user identifiers of the requests logged from now on
+func (rl *RequestLogger) SetAnonymization(config LogAnonymization) error {
+	anonymizer, err := newLogAnonymizer(config)
+	if err != nil {
+		return err
+	}
+
+	rl.mu.Lock()
+	rl.anonymizer = anonymizer
+	rl.mu.Unlock()
+	return nil
+}
+
+// hash returns the keyed hash of a value
+func (a *logAnonymizer) hash(value string) string {
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(value))
+	return "anon-" + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// anonymizeIPs anonymizes a comma-separated list of addresses, with or without ports
+func (a *logAnonymizer) anonymizeIPs(value string) string {
+	if a.ips == AnonymizeIPNone {
+		return value
+	}
+	parts := strings.Split(value, ",")
+	for i, part := range parts {
+		parts[i] = a.anonymizeIP(strings.TrimSpace(part))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// anonymizeIP anonymizes an address; values that are no IP are hashed in either mode
+func (a *logAnonymizer) anonymizeIP(addr string) string {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || a.ips == AnonymizeIPHash {
+		if addr == "" {
+			return addr
+		}
+		return a.hash(host)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// anonymizeValue hashes the value of a field: strings and numbers are hashed, lists and
+// objects have their elements hashed
+func (a *logAnonymizer) anonymizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []string:
+		hashed := make([]string, len(v))
+		for i, s := range v {
+			hashed[i] = a.hash(s)
+		}
+		return hashed
+	case []interface{}:
+		hashed := make([]interface{}, len(v))
+		for i, item := range v {
+			hashed[i] = a.anonymizeValue(item)
+		}
+		return hashed
+	case map[string]interface{}:
+		hashed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			hashed[key] = a.anonymizeValue(item)
+		}
+		return hashed
+	}
+	return a.hash(fmt.Sprint(value))
+}
+
+// anonymizeJSON hashes the listed fields of a JSON body, at any depth. Bodies that are not
+// JSON or have no listed field are returned as they are.
+func (a *logAnonymizer) anonymizeJSON(body string) string {
+	if len(a.fields) == 0 || body == "" {
+		return body
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(body), &data); err != nil {
+		return body
+	}
+	changed := false
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, item := range v {
+				if a.fields[strings.ToLower(key)] {
+					v[key] = a.anonymizeValue(item)
+					changed = true
+				} else {
+					walk(item)
+				}
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+	walk(data)
+	if !changed {
+		return body
+	}
+	anonymized, err := json.Marshal(data)
+	if err != nil {
+		return body
+	}
+	return string(anonymized)
+}
+
+// anonymizeRequest hides the IPs and listed fields of a request log. Called before the log
+// is visible to anyone else.
+func (a *logAnonymizer) anonymizeRequest(requestLog *RequestLog, r *http.Request) {
+	requestLog.RemoteIP = a.anonymizeIPs(requestLog.RemoteIP)
+
+	for name, value := range requestLog.Headers {
+		switch {
+		case a.fields[strings.ToLower(name)]:
+			requestLog.Headers[name] = a.anonymizeValue(value)
+		case a.ips != AnonymizeIPNone && anonymizedIPHeaders[http.CanonicalHeaderKey(name)]:
+			switch v := value.(type) {
+			case string:
+				requestLog.Headers[name] = a.anonymizeIPs(v)
+			case []string:
+				anonymized := make([]string, len(v))
+				for i, s := range v {
+					anonymized[i] = a.anonymizeIPs(s)
+				}
+				requestLog.Headers[name] = anonymized
+			}
+		}
+	}
+
+	queryChanged := false
+	for key, value := range requestLog.Query {
+		if a.fields[strings.ToLower(key)] {
+			requestLog.Query[key] = a.anonymizeValue(value)
+			queryChanged = true
+		}
+	}
+	if queryChanged {
+		query := url.Values{}
+		for key, values := range r.URL.Query() {
+			if a.fields[strings.ToLower(key)] {
+				for _, value := range values {
+					query.Add(key, a.hash(value))
+				}
+			} else {
+				query[key] = values
+			}
+		}
+		u := *r.URL
+		u.RawQuery = query.Encode()
+		requestLog.URL = u.String()
+	}
+
+	requestLog.Body = a.anonymizeJSON(requestLog.Body)
+}
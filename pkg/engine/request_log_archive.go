@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// Request log archives
+//
+// The request logger keeps the last requests in memory. With an archive directory
+// (jesus serve --log-archive-dir) the requests it drops are written to disk instead of being
+// lost: one gzipped JSON Lines file per day, requests-2006-01-02.jsonl.gz, each line a
+// RequestLog. Requests are appended in batches, each batch a gzip member of its own, so a
+// file stays readable with zcat while it grows. Closing the engine archives the requests
+// still in memory. Archives older than the retention window are deleted.
+//
+// With a storage (jesus serve --storage), the archives are kept under its request-logs/
+// prefix instead of the directory.
+
+// archiveBatchSize is the number of dropped requests collected before they are written
+const archiveBatchSize = 20
+
+// archiveNameRegexp matches the names of archive files and captures their day
+var archiveNameRegexp = regexp.MustCompile(`^requests-(\d{4}-\d{2}-\d{2})\.jsonl\.gz$`)
+
+// LogArchive describes an archive file of request logs
+type LogArchive struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// requestLogArchive writes the requests dropped by a RequestLogger to a storage
+type requestLogArchive struct {
+	store     storage.Storage
+	retention time.Duration
+	closed    bool       // Whether Close archived the requests in memory, guarded by the logger's mu
+	mu        sync.Mutex // Serializes writes to the archive files
+}
+
+// SetArchive archives the requests the logger drops to dir, deleting archives older than
+// retention (0 to keep them). An empty dir turns archiving off.
+func (rl *RequestLogger) SetArchive(dir string, retention time.Duration) error {
+	if dir == "" {
+		rl.SetArchiveStorage(nil, 0)
+		return nil
+	}
+	store, err := storage.NewLocal(dir)
+	if err != nil {
+		return fmt.Errorf("failed to create log archive directory: %w", err)
+	}
+	rl.SetArchiveStorage(store, retention)
+	return nil
+}
+
+// SetArchiveStorage archives the requests the logger drops to store, deleting archives older
+// than retention (0 to keep them). A nil store turns archiving off.
+func (rl *RequestLogger) SetArchiveStorage(store storage.Storage, retention time.Duration) {
+	var archive *requestLogArchive
+	if store != nil {
+		archive = &requestLogArchive{store: store, retention: retention}
+		archive.prune()
+		log.Info().Str("storage", store.String()).Dur("retention", retention).Msg("Archiving request logs")
+	}
+
+	rl.mu.Lock()
+	rl.archive = archive
+	rl.mu.Unlock()
+}
+
+// archiveStore returns the storage of the archives, nil if archiving is off
+func (rl *RequestLogger) archiveStore() storage.Storage {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	if rl.archive == nil {
+		return nil
+	}
+	return rl.archive.store
+}
+
+// Archiving reports whether dropped requests are archived
+func (rl *RequestLogger) Archiving() bool {
+	return rl.archiveStore() != nil
+}
+
+// takePending removes the dropped requests waiting to be archived; rl.mu must be held
+func (rl *RequestLogger) takePending() []*RequestLog {
+	pending := rl.pending
+	rl.pending = nil
+	return pending
+}
+
+// writeArchive appends requests to today's archive file
+func (rl *RequestLogger) writeArchive(archive *requestLogArchive, requests []*RequestLog) error {
+	if archive == nil || len(requests) == 0 {
+		return nil
+	}
+
+	// Requests still running are updated under rl.mu
+	var lines bytes.Buffer
+	rl.mu.RLock()
+	encoder := json.NewEncoder(&lines)
+	for _, request := range requests {
+		if err := encoder.Encode(request); err != nil {
+			log.Warn().Err(err).Str("requestID", request.ID).Msg("Failed to archive request log")
+		}
+	}
+	rl.mu.RUnlock()
+
+	var member bytes.Buffer
+	gz := gzip.NewWriter(&member)
+	if _, err := gz.Write(lines.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress log archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress log archive: %w", err)
+	}
+
+	archive.mu.Lock()
+	defer archive.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	name := "requests-" + time.Now().Format(time.DateOnly) + ".jsonl.gz"
+	if err := archive.store.Append(ctx, name, member.Bytes()); err != nil {
+		return fmt.Errorf("failed to write log archive: %w", err)
+	}
+	archive.prune()
+	return nil
+}
+
+// FlushArchive writes the dropped requests still waiting for a full batch
+func (rl *RequestLogger) FlushArchive() error {
+	rl.mu.Lock()
+	archive, pending := rl.archive, rl.takePending()
+	rl.mu.Unlock()
+	return rl.writeArchive(archive, pending)
+}
+
+// Close archives the requests waiting for a batch and, once, the ones still in memory
+func (rl *RequestLogger) Close() error {
+	rl.mu.Lock()
+	archive, requests := rl.archive, rl.takePending()
+	if archive != nil && !archive.closed {
+		archive.closed = true
+		for _, id := range rl.order {
+			if request, ok := rl.requests[id]; ok {
+				requests = append(requests, request)
+			}
+		}
+	}
+	rl.mu.Unlock()
+	return rl.writeArchive(archive, requests)
+}
+
+// Archives lists the archive files, newest first
+func (rl *RequestLogger) Archives(ctx context.Context) ([]LogArchive, error) {
+	store := rl.archiveStore()
+	if store == nil {
+		return []LogArchive{}, nil
+	}
+	objects, err := store.List(ctx, "requests-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log archives: %w", err)
+	}
+
+	archives := []LogArchive{}
+	for _, object := range objects {
+		if !archiveNameRegexp.MatchString(object.Key) {
+			continue
+		}
+		archives = append(archives, LogArchive{Name: object.Key, Size: object.Size, Modified: object.Modified})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Name > archives[j].Name })
+	return archives, nil
+}
+
+// OpenArchive opens an archive file by name, refusing names that are not archives
+func (rl *RequestLogger) OpenArchive(ctx context.Context, name string) (io.ReadCloser, storage.Object, error) {
+	store := rl.archiveStore()
+	if store == nil || !archiveNameRegexp.MatchString(name) {
+		return nil, storage.Object{}, fmt.Errorf("log archive %s: %w", name, storage.ErrNotExist)
+	}
+	return store.Get(ctx, name)
+}
+
+// prune deletes the archives whose day ended before the retention window
+func (a *requestLogArchive) prune() {
+	if a.retention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	objects, err := a.store.List(ctx, "requests-")
+	if err != nil {
+		log.Warn().Err(err).Str("storage", a.store.String()).Msg("Failed to list log archives")
+		return
+	}
+	cutoff := time.Now().Add(-a.retention)
+	for _, object := range objects {
+		groups := archiveNameRegexp.FindStringSubmatch(object.Key)
+		if groups == nil {
+			continue
+		}
+		day, err := time.ParseInLocation(time.DateOnly, groups[1], time.Local)
+		if err != nil || !day.AddDate(0, 0, 1).Before(cutoff) {
+			continue
+		}
+		if err := a.store.Delete(ctx, object.Key); err != nil {
+			log.Warn().Err(err).Str("file", object.Key).Msg("Failed to delete expired log archive")
+		} else {
+			log.Info().Str("file", object.Key).Msg("Deleted expired log archive")
+		}
+	}
+}
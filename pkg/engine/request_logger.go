@@ -28,6 +28,9 @@ type RequestLog struct {
 	DatabaseOps []DatabaseOperation    `json:"databaseOps"`
 	Error       string                 `json:"error,omitempty"`
 	RemoteIP    string                 `json:"remoteIP"`
+	Handler     string                 `json:"handler,omitempty"`     // Matched route, e.g. "GET /users/:id"
+	SessionID   string                 `json:"sessionId,omitempty"`   // Execution session triggered by the request
+	ExecutionID int                    `json:"executionId,omitempty"` // Stored script execution triggered by the request
 }
 
 // LogEntry represents a single log message during request processing
@@ -57,6 +60,11 @@ type RequestLogger struct {
 	requests map[string]*RequestLog
 	maxLogs  int
 	order    []string // Keep track of insertion order for LRU
+
+	archive *requestLogArchive // Where dropped requests are written, nil to drop them
+	pending []*RequestLog      // Dropped requests waiting to be archived
+
+	anonymizer *logAnonymizer // Hides IPs and user identifiers of logged requests, nil to log them as they are
 }
 
 // NewRequestLogger creates a new request logger
@@ -75,7 +83,6 @@ func NewRequestLogger(maxLogs int) *RequestLogger {
 // StartRequest creates a new request log entry
 func (rl *RequestLogger) StartRequest(r *http.Request) *RequestLog {
 	rl.mu.Lock()
-	defer rl.mu.Unlock()
 
 	requestID := generateRequestID()
 
@@ -130,18 +137,34 @@ func (rl *RequestLogger) StartRequest(r *http.Request) *RequestLog {
 		Logs:        make([]LogEntry, 0),
 		DatabaseOps: make([]DatabaseOperation, 0),
 	}
+	if rl.anonymizer != nil {
+		rl.anonymizer.anonymizeRequest(requestLog, r)
+	}
 
 	// Add to requests map and order tracking
 	rl.requests[requestID] = requestLog
 	rl.order = append(rl.order, requestID)
 
-	// Enforce max logs limit (LRU eviction)
+	// Enforce max logs limit (LRU eviction), archiving the dropped request if configured
 	if len(rl.order) > rl.maxLogs {
 		oldestID := rl.order[0]
+		if rl.archive != nil {
+			rl.pending = append(rl.pending, rl.requests[oldestID])
+		}
 		delete(rl.requests, oldestID)
 		rl.order = rl.order[1:]
 	}
 
+	var batch []*RequestLog
+	archive := rl.archive
+	if len(rl.pending) >= archiveBatchSize {
+		batch = rl.takePending()
+	}
+	rl.mu.Unlock()
+
+	if err := rl.writeArchive(archive, batch); err != nil {
+		log.Warn().Err(err).Msg("Failed to archive request logs")
+	}
 	return requestLog
 }
 
@@ -187,6 +210,27 @@ func (rl *RequestLogger) AddDatabaseOperation(requestID string, dbOp DatabaseOpe
 	}
 }
 
+// SetHandler records which route handler processed a request
+func (rl *RequestLogger) SetHandler(requestID, handler string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if requestLog, exists := rl.requests[requestID]; exists {
+		requestLog.Handler = handler
+	}
+}
+
+// LinkExecution associates a stored script execution with the request that triggered it
+func (rl *RequestLogger) LinkExecution(requestID, sessionID string, executionID int) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if requestLog, exists := rl.requests[requestID]; exists {
+		requestLog.SessionID = sessionID
+		requestLog.ExecutionID = executionID
+	}
+}
+
 // GetAllRequests returns all request logs in reverse chronological order
 func (rl *RequestLogger) GetAllRequests() []*RequestLog {
 	rl.mu.RLock()
@@ -251,6 +295,7 @@ func (rl *RequestLogger) GetStats() map[string]interface{} {
 	stats := map[string]interface{}{
 		"totalRequests": len(rl.requests),
 		"maxLogs":       rl.maxLogs,
+		"archiving":     rl.archive != nil,
 	}
 
 	// Count by status code
@@ -363,3 +408,10 @@ func (rr *ResponseRecorder) Write(b []byte) (int, error) {
 	}
 	return rr.ResponseWriter.Write(b)
 }
+
+// Flush implements http.Flusher so streaming handlers keep working behind the recorder
+func (rr *ResponseRecorder) Flush() {
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,337 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// RequestParseError is returned by req.parse() when the request does not match the schema.
+// Handlers that do not catch it answer 400 with the field errors.
+type RequestParseError struct {
+	Errors []ValidationError
+}
+
+func (err *RequestParseError) Error() string {
+	return "invalid request: " + formatValidationErrors(err.Errors)
+}
+
+// Parse implements req.parse(schema [, source]): it reads the declared fields from the path
+// params, query string and body (JSON or form encoded), converts them to their declared types
+// and returns them as one object:
+//
+//	const { page, since, active } = req.parse({
+//	    page: { type: 'integer', minimum: 1, default: 1 },
+//	    since: 'date',
+//	    active: 'boolean',
+//	});
+//
+// Fields are either a type name (string, integer, number, boolean, date, array) or a JSON
+// Schema property with optional required and default; a full JSON Schema object with
+// properties/required is accepted too. source restricts the lookup to 'params', 'query' or
+// 'body'. Undeclared fields are dropped.
+func (r *ExpressRequest) Parse(schema map[string]interface{}, source ...string) (*goja.Object, error) {
+	fields, required := requestParseFields(schema)
+	input, err := r.parseInput(source...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := r.engine.rt.NewObject()
+	var errors []ValidationError
+	for _, name := range names {
+		field := fields[name]
+		path := "/" + name
+
+		raw, present := input[name]
+		if s, ok := raw.(string); ok && s == "" && schemaTypeName(field) != "string" {
+			present = false
+		}
+		if !present {
+			if def, ok := field["default"]; ok {
+				raw, present = def, true
+			} else if required[name] {
+				errors = append(errors, ValidationError{Path: path, Message: "is required"})
+			}
+		}
+		if !present {
+			continue
+		}
+
+		value, fieldErrors := r.parseField(field, raw, path)
+		if len(fieldErrors) > 0 {
+			errors = append(errors, fieldErrors...)
+			continue
+		}
+		if err := result.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(errors) > 0 {
+		return nil, &RequestParseError{Errors: errors}
+	}
+	return result, nil
+}
+
+// parseField converts one raw value and checks the remaining schema keywords on it
+func (r *ExpressRequest) parseField(field map[string]interface{}, raw interface{}, path string) (interface{}, []ValidationError) {
+	typeName := schemaTypeName(field)
+
+	switch typeName {
+	case "date":
+		t, ok := coerceDate(raw)
+		if !ok {
+			return nil, []ValidationError{{Path: path, Message: fmt.Sprintf("must be a date, got %q", fmt.Sprint(raw))}}
+		}
+		date, err := r.engine.rt.New(r.engine.rt.Get("Date"), r.engine.rt.ToValue(t.UnixMilli()))
+		if err != nil {
+			return nil, []ValidationError{{Path: path, Message: err.Error()}}
+		}
+		return date, nil
+
+	case "array":
+		var items []interface{}
+		switch list := raw.(type) {
+		case []interface{}:
+			items = list
+		case []string:
+			for _, item := range list {
+				items = append(items, item)
+			}
+		default:
+			items = []interface{}{raw}
+		}
+		itemSchema, _ := field["items"].(map[string]interface{})
+
+		values := make([]interface{}, 0, len(items))
+		var errors []ValidationError
+		for i, item := range items {
+			value, itemErrors := r.parseField(itemSchema, item, fmt.Sprintf("%s/%d", path, i))
+			errors = append(errors, itemErrors...)
+			values = append(values, value)
+		}
+		if len(errors) > 0 {
+			return nil, errors
+		}
+		if errors := prefixErrors(validateSchema(withoutKeys(field, "items", "type"), values), path); len(errors) > 0 {
+			return nil, errors
+		}
+		return values, nil
+	}
+
+	value := raw
+	if typeName != "" {
+		coerced, ok := coerceRequestValue(typeName, raw)
+		if !ok {
+			return nil, []ValidationError{{Path: path, Message: fmt.Sprintf("must be %s %s, got %q", article(typeName), typeName, fmt.Sprint(raw))}}
+		}
+		value = coerced
+	}
+	if errors := prefixErrors(validateSchema(field, value), path); len(errors) > 0 {
+		return nil, errors
+	}
+	return value, nil
+}
+
+// parseInput merges the values req.parse() reads from; body fields win over query fields,
+// which win over path params
+func (r *ExpressRequest) parseInput(source ...string) (map[string]interface{}, error) {
+	from := ""
+	if len(source) > 0 {
+		from = source[0]
+	}
+
+	input := make(map[string]interface{})
+	if from == "" || from == "params" {
+		for k, v := range r.Params {
+			input[k] = v
+		}
+	}
+	if from == "" || from == "query" {
+		for k, v := range r.Query {
+			input[k] = v
+		}
+	}
+	if from == "" || from == "body" {
+		switch body := r.Body.(type) {
+		case map[string]interface{}:
+			for k, v := range body {
+				input[k] = v
+			}
+		case string:
+			contentType, _ := r.Headers["content-type"].(string)
+			if strings.Contains(contentType, "application/x-www-form-urlencoded") {
+				form, err := url.ParseQuery(body)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse form body: %w", err)
+				}
+				for k, v := range form {
+					if len(v) == 1 {
+						input[k] = v[0]
+					} else {
+						input[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	switch from {
+	case "", "params", "query", "body":
+		return input, nil
+	default:
+		return nil, fmt.Errorf("unknown request part %q, expected params, query or body", from)
+	}
+}
+
+// requestParseFields turns the req.parse() schema into per-field JSON Schemas and the set of
+// required fields
+func requestParseFields(schema map[string]interface{}) (map[string]map[string]interface{}, map[string]bool) {
+	fields := make(map[string]map[string]interface{})
+	required := make(map[string]bool)
+
+	properties, isJSONSchema := schema["properties"].(map[string]interface{})
+	if isJSONSchema {
+		if list, ok := schema["required"].([]interface{}); ok {
+			for _, name := range list {
+				required[fmt.Sprint(name)] = true
+			}
+		}
+	} else {
+		properties = schema
+	}
+
+	for name, spec := range properties {
+		switch s := spec.(type) {
+		case string:
+			fields[name] = map[string]interface{}{"type": s}
+		case map[string]interface{}:
+			if req, ok := s["required"].(bool); ok {
+				required[name] = req
+				s = withoutKeys(s, "required")
+			}
+			fields[name] = s
+		default:
+			fields[name] = map[string]interface{}{}
+		}
+	}
+	return fields, required
+}
+
+// coerceRequestValue converts a query, form or JSON value to a string, integer, number or
+// boolean; ok is false when the value cannot represent that type
+func coerceRequestValue(typeName string, raw interface{}) (interface{}, bool) {
+	switch typeName {
+	case "string":
+		switch v := raw.(type) {
+		case string:
+			return v, true
+		case float64, int64, bool:
+			return fmt.Sprint(v), true
+		}
+	case "integer":
+		switch v := raw.(type) {
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			return n, err == nil
+		case float64:
+			return int64(v), v == math.Trunc(v)
+		case int64:
+			return v, true
+		}
+	case "number":
+		switch v := raw.(type) {
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return n, err == nil
+		case float64, int64:
+			return v, true
+		}
+	case "boolean":
+		switch v := raw.(type) {
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "1", "yes", "on":
+				return true, true
+			case "false", "0", "no", "off":
+				return false, true
+			}
+		case bool:
+			return v, true
+		}
+	default:
+		return raw, true
+	}
+	return nil, false
+}
+
+// requestDateLayouts are the date formats req.parse() accepts besides Unix milliseconds
+var requestDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func coerceDate(raw interface{}) (time.Time, bool) {
+	switch v := raw.(type) {
+	case string:
+		s := strings.TrimSpace(v)
+		for _, layout := range requestDateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.UnixMilli(ms), true
+		}
+	case float64:
+		return time.UnixMilli(int64(v)), true
+	case int64:
+		return time.UnixMilli(v), true
+	case time.Time:
+		return v, true
+	}
+	return time.Time{}, false
+}
+
+// schemaTypeName returns the single type of a field schema, "" when it has none
+func schemaTypeName(field map[string]interface{}) string {
+	if types := schemaTypes(field["type"]); len(types) == 1 {
+		return types[0]
+	}
+	return ""
+}
+
+func withoutKeys(schema map[string]interface{}, keys ...string) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		copied[k] = v
+	}
+	for _, k := range keys {
+		delete(copied, k)
+	}
+	return copied
+}
+
+func prefixErrors(errors []ValidationError, path string) []ValidationError {
+	for i := range errors {
+		errors[i].Path = path + errors[i].Path
+	}
+	return errors
+}
+
+func article(word string) string {
+	if strings.ContainsRune("aeiou", rune(word[0])) {
+		return "an"
+	}
+	return "a"
+}
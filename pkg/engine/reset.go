@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// VM reset
+//
+// Reset replaces the runtime of every pool runtime with a fresh one, as if the server had
+// restarted: routes, file handlers, error middleware, app.static() mounts, timers, session
+// runtimes and the cors() and rateLimit() set with app.use() are dropped, then the bootstrap
+// file and the scripts directory run again. Each runtime is reset by its own dispatcher, so
+// a request being served finishes first. globalState starts empty unless KeepState carries
+// it over; the bootstrap file sees the kept state. Open WebSocket connections keep the
+// callbacks of the old runtime until they close.
+
+// ResetOptions configures Reset
+type ResetOptions struct {
+	KeepState bool `json:"keepState"` // Carry globalState over into the new runtime
+}
+
+// ResetResult describes the runtime Reset left behind
+type ResetResult struct {
+	Bootstrap     string `json:"bootstrap,omitempty"`  // Bootstrap file that ran again
+	ScriptsDir    string `json:"scriptsDir,omitempty"` // Scripts directory that was loaded again
+	Routes        int    `json:"routes"`               // Routes registered afterwards
+	KeptState     bool   `json:"keptState"`            // Whether globalState was carried over
+	TimersCleared int    `json:"timersCleared"`        // Timers of the old runtimes that were stopped
+	Error         string `json:"error,omitempty"`      // Why the bootstrap file failed to run
+}
+
+// resetRequest is the part of a reset one runtime carries out on its dispatcher
+type resetRequest struct {
+	keepState bool
+	timers    int // Timers the runtime cleared, set by resetRuntime
+}
+
+// Reset tears down the JavaScript runtimes and starts over with the bootstrap file and the
+// scripts directory. The error is that of the bootstrap file; the runtimes are reset anyway.
+func (e *Engine) Reset(opts ResetOptions) (*ResetResult, error) {
+	// A reset drops the routes, which could not be registered again
+	if err := e.CheckWritable(); err != nil {
+		return nil, err
+	}
+	root := e.root()
+	result := &ResetResult{KeptState: opts.KeepState}
+
+	// Reset every runtime on its own dispatcher, the primary keeping globalState if asked to
+	requests := make([]*resetRequest, 0, len(root.replicas)+1)
+	for _, runtime := range root.runtimes() {
+		request := &resetRequest{keepState: opts.KeepState && runtime == root}
+		requests = append(requests, request)
+		if !runtime.dispatching {
+			runtime.resetRuntime(request)
+			continue
+		}
+		done := make(chan error, 1)
+		runtime.jobs <- EvalJob{reset: request, Done: done}
+		if err := <-done; err != nil {
+			return nil, fmt.Errorf("failed to reset runtime: %w", err)
+		}
+	}
+	for _, request := range requests {
+		result.TimersCleared += request.timers
+	}
+	log.Info().Bool("keepState", opts.KeepState).Int("runtimes", len(requests)).Msg("JavaScript runtimes reset")
+
+	var bootstrapErr error
+	if root.bootstrapFile != "" {
+		result.Bootstrap = root.bootstrapFile
+		if bootstrapErr = root.rerunBootstrap(); bootstrapErr != nil {
+			result.Error = bootstrapErr.Error()
+		}
+	}
+	if dir := root.ScriptsDir(); dir != "" {
+		result.ScriptsDir = dir
+		if err := root.LoadScripts(dir); err != nil {
+			log.Error().Err(err).Str("directory", dir).Msg("Failed to reload scripts after reset")
+		}
+	}
+
+	root.mu.RLock()
+	for _, methods := range root.handlers {
+		result.Routes += len(methods)
+	}
+	root.mu.RUnlock()
+	root.checkStateChanged()
+	return result, bootstrapErr
+}
+
+// rerunBootstrap runs the bootstrap file in every runtime of the pool, backing it up like Init
+func (e *Engine) rerunBootstrap() error {
+	data, err := os.ReadFile(e.bootstrapFile)
+	if err != nil {
+		return fmt.Errorf("failed to read bootstrap file: %w", err)
+	}
+	backup := e.backupBootstrap(e.bootstrapFile, string(data))
+
+	if !e.dispatching {
+		err = e.runReplicated(string(data))
+	} else {
+		done := make(chan error, 1)
+		e.SubmitJob(EvalJob{
+			Code:      string(data),
+			Done:      done,
+			SessionID: "startup-" + filepath.Base(e.bootstrapFile),
+			Source:    repository.SourceFile,
+			Replicate: true,
+		})
+		err = <-done
+	}
+	e.recordBootstrapResult(backup, err)
+	if err != nil {
+		log.Error().Err(err).Str("file", e.bootstrapFile).Msg("Failed to execute bootstrap file after reset")
+	}
+	return err
+}
+
+// resetRuntime replaces the runtime of this engine with a freshly bound one and forgets what
+// scripts registered in the old one. Only the dispatcher calls it, or the caller of Reset
+// before the dispatcher started.
+func (e *Engine) resetRuntime(request *resetRequest) {
+	state := ""
+	if request.keepState {
+		state = e.GetGlobalState()
+	}
+
+	e.mu.RLock()
+	timerIDs := make([]int64, 0, len(e.timers))
+	for id := range e.timers {
+		timerIDs = append(timerIDs, id)
+	}
+	e.mu.RUnlock()
+	for _, id := range timerIDs {
+		if e.cancelTimer(id) {
+			request.timers++
+		}
+	}
+
+	rt := newRuntime(e.requireRegistry)
+	e.applyRuntimeLimits(rt)
+
+	e.mu.Lock()
+	e.rt = rt
+	e.handlers = make(map[string]map[string]*HandlerInfo)
+	e.routeOrder = nil
+	e.files = make(map[string]goja.Callable)
+	e.fileScripts = make(map[string]string)
+	e.errorHandlers = nil
+	e.statics = nil
+	e.sessions = make(map[string]*sessionRuntime)
+	e.replicatedTimers = nil
+	e.mirroredTimers = nil
+	e.replicatedTimerCount = 0
+	if e.primary == nil {
+		e.globalCORS = nil
+		e.globalRateLimit = nil
+	}
+	e.mu.Unlock()
+
+	e.setupBindings()
+	if e.primary != nil {
+		e.setupReplicaGlobalState()
+	}
+	e.setupEnvironmentBinding(e.GetEnvironment())
+	e.setupDatabaseBindings(e.dbModule)
+
+	if state != "" {
+		if _, err := e.rt.RunString("globalState = " + state); err != nil {
+			log.Error().Err(err).Msg("Failed to restore globalState after reset")
+		}
+	}
+}
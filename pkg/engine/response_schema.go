@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Response schemas
+//
+// A route declares the shape of its JSON responses with the responseSchema option:
+//
+//	app.get('/users/:id', handler, {
+//	    responseSchema: {type: 'object', required: ['id', 'name'], properties: {id: {type: 'integer'}, name: {type: 'string'}}},
+//	});
+//
+// res.json() and res.send() check the JSON bodies of 2xx responses against it. What a
+// mismatch does is set for the server with SetResponseValidation (jesus serve
+// --response-validation), or for one route with the responseValidation option:
+//
+//	warn    log the errors to the server and request logs and send the response (default)
+//	strict  answer 500 with the validation errors instead of the response
+//	off     skip the check
+//
+// Strict mode catches handlers drifting from their contract, e.g. generated code renaming a
+// field, before clients see it. The schema is also published in the OpenAPI document.
+
+// Response validation modes
+const (
+	ResponseValidationWarn   = "warn"
+	ResponseValidationStrict = "strict"
+	ResponseValidationOff    = "off"
+)
+
+// ResponseSchemaError is returned by res.json() and res.send() in strict mode when the body does
+// not match the route's schema. Handlers that do not catch it answer 500 with the errors.
+type ResponseSchemaError struct {
+	Errors []ValidationError
+}
+
+func (err *ResponseSchemaError) Error() string {
+	return "response does not match the route's schema: " + formatValidationErrors(err.Errors)
+}
+
+// responseSchema is the responseSchema option of a route
+type responseSchema struct {
+	schema map[string]interface{}
+	mode   string // Mode of the route, "" for the server's
+}
+
+// checkResponseValidation refuses unknown validation modes
+func checkResponseValidation(mode string) error {
+	switch mode {
+	case "", ResponseValidationWarn, ResponseValidationStrict, ResponseValidationOff:
+		return nil
+	default:
+		return fmt.Errorf("unknown response validation mode %q, use %s, %s or %s", mode, ResponseValidationWarn, ResponseValidationStrict, ResponseValidationOff)
+	}
+}
+
+// SetResponseValidation sets what a response not matching its route's schema does, for the
+// routes without a responseValidation option: warn ("" too), strict or off
+func (e *Engine) SetResponseValidation(mode string) error {
+	if err := checkResponseValidation(mode); err != nil {
+		return err
+	}
+	root := e.root()
+	root.mu.Lock()
+	root.schemaMode = mode
+	root.mu.Unlock()
+	return nil
+}
+
+// ResponseValidation returns the response validation mode of routes without their own
+func (e *Engine) ResponseValidation() string {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	if root.schemaMode == "" {
+		return ResponseValidationWarn
+	}
+	return root.schemaMode
+}
+
+// parseResponseSchema reads the responseSchema and responseValidation options of a route,
+// nil without schema
+func parseResponseSchema(options map[string]interface{}) (*responseSchema, error) {
+	raw, ok := options["responseSchema"]
+	if !ok || raw == nil {
+		if _, ok := options["responseValidation"]; ok {
+			return nil, fmt.Errorf("responseValidation needs a responseSchema")
+		}
+		return nil, nil
+	}
+	schema, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("responseSchema must be a JSON schema object, got %T", raw)
+	}
+
+	rs := &responseSchema{schema: schema}
+	if mode, ok := options["responseValidation"]; ok && mode != nil {
+		s, ok := mode.(string)
+		if !ok {
+			return nil, fmt.Errorf("responseValidation must be a string, got %T", mode)
+		}
+		if err := checkResponseValidation(s); err != nil {
+			return nil, err
+		}
+		rs.mode = s
+	}
+	return rs, nil
+}
+
+// checkSchema validates a body about to be sent against the route's response schema. It
+// returns a ResponseSchemaError for a mismatch in strict mode; in warn mode it logs it.
+func (r *ExpressResponse) checkSchema(data interface{}) error {
+	if r.schema == nil || r.StatusCode < 200 || r.StatusCode >= 300 {
+		return nil
+	}
+	mode := r.schema.mode
+	if mode == "" {
+		mode = r.engine.ResponseValidation()
+	}
+	if mode == ResponseValidationOff {
+		return nil
+	}
+
+	var errors []ValidationError
+	switch v := data.(type) {
+	case []byte:
+		return nil
+	case string:
+		contentType := r.writer.Header().Get("Content-Type")
+		for name, value := range r.Headers {
+			if strings.EqualFold(name, "Content-Type") {
+				contentType = value
+			}
+		}
+		if contentType != "" && !strings.Contains(contentType, "json") || contentType == "" && !isJSON(v) {
+			return nil
+		}
+		var body interface{}
+		if err := json.Unmarshal([]byte(v), &body); err != nil {
+			errors = []ValidationError{{Message: "body is not valid JSON: " + err.Error()}}
+		} else {
+			errors = validateSchema(r.schema.schema, body)
+		}
+	default:
+		errors = validateSchema(r.schema.schema, data)
+	}
+	if len(errors) == 0 {
+		return nil
+	}
+	schemaErr := &ResponseSchemaError{Errors: errors}
+	if mode == ResponseValidationStrict {
+		return schemaErr
+	}
+
+	path := ""
+	if r.request != nil {
+		path = r.request.URL.Path
+	}
+	log.Warn().Str("path", path).Str("errors", formatValidationErrors(errors)).Msg("Response did not match the route's schema")
+	if r.engine.currentReqID != "" {
+		r.engine.reqLogger.AddLog(r.engine.currentReqID, "warn", schemaErr.Error(), errors)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Streamed responses
+//
+// res.write() sends the body of a response in chunks instead of buffering it, e.g. for CSV
+// exports or LLM tokens, and res.sse() builds event streams on top of it. Once streaming
+// started, the response stays open after the handler returns, so timers and async
+// callbacks can keep writing, until the handler calls res.end() or the client disconnects.
+// Either way the onClose callbacks run on the dispatcher and later writes return false.
+// A handler that returns before writing (or calling res.flush()) still gets the default
+// empty response.
+//
+//	app.get('/export.csv', (req, res) => {
+//	  res.set('Content-Type', 'text/csv');
+//	  for (const row of db.query('SELECT id, name FROM users')) {
+//	    res.write(`${row.id},${row.name}\n`);
+//	  }
+//	  res.end();
+//	});
+
+// responseStream is the open body of a streamed response
+type responseStream struct {
+	sse    bool          // Whether res.sse() started the stream
+	done   chan struct{} // Closed with the stream, stops the disconnect watcher
+	finish func()        // Completes the request, set once the handler returned
+}
+
+// startStream sends the status and headers and opens the response stream
+func (r *ExpressResponse) startStream() error {
+	if _, ok := r.writer.(http.Flusher); !ok {
+		return fmt.Errorf("response does not support streaming")
+	}
+	r.sent = true
+	r.stream = &responseStream{done: make(chan struct{})}
+
+	for key, value := range r.Headers {
+		r.writer.Header().Set(key, value)
+	}
+	for _, cookie := range r.Cookies {
+		http.SetCookie(r.writer, cookie)
+	}
+	if r.StatusCode == 0 {
+		r.StatusCode = 200
+	}
+	r.writer.WriteHeader(r.StatusCode)
+	return nil
+}
+
+// Write sends a chunk of the body (a string or bytes), sending the status and headers first
+// if needed. It returns false once the stream is closed.
+func (r *ExpressResponse) Write(chunk interface{}) (bool, error) {
+	if r.stream == nil {
+		if r.sent {
+			return false, fmt.Errorf("res.write(): response already sent")
+		}
+		if err := r.startStream(); err != nil {
+			return false, fmt.Errorf("res.write(): %w", err)
+		}
+	}
+	if r.closed {
+		return false, nil
+	}
+
+	var data []byte
+	switch v := chunk.(type) {
+	case string:
+		if err := r.engine.checkValueLimits(v); err != nil {
+			return false, err
+		}
+		data = []byte(v)
+	case []byte:
+		data = v
+	case goja.ArrayBuffer:
+		data = v.Bytes()
+	case nil:
+		return true, nil
+	default:
+		return false, fmt.Errorf("res.write(): chunk must be a string or bytes, got %T", chunk)
+	}
+
+	if _, err := r.writer.Write(data); err != nil {
+		log.Debug().Err(err).Msg("Failed to write response chunk, closing stream")
+		r.close()
+		return false, nil
+	}
+	return true, nil
+}
+
+// Flush sends the chunks written so far to the client right away. Called before any write,
+// it sends the status and headers, which keeps the response open once the handler returns.
+// It returns false once the stream is closed.
+func (r *ExpressResponse) Flush() (bool, error) {
+	if r.stream == nil {
+		if r.sent {
+			return false, fmt.Errorf("res.flush(): response already sent")
+		}
+		if err := r.startStream(); err != nil {
+			return false, fmt.Errorf("res.flush(): %w", err)
+		}
+	}
+	if r.closed {
+		return false, nil
+	}
+	r.writer.(http.Flusher).Flush()
+	return true, nil
+}
+
+// OnClose registers a callback called once the response is finished: when a handler that
+// did not stream returns, or when the stream closes
+func (r *ExpressResponse) OnClose(fn goja.Value) error {
+	callback, ok := goja.AssertFunction(fn)
+	if !ok {
+		return fmt.Errorf("res.onClose(): callback must be a function")
+	}
+	if r.closed {
+		// Already closed, call it right away
+		_, err := callback(goja.Undefined())
+		return err
+	}
+	r.onClose = append(r.onClose, callback)
+	return nil
+}
+
+// IsStreaming reports whether the response is an open stream
+func (r *ExpressResponse) IsStreaming() bool {
+	return r.stream != nil && !r.closed
+}
+
+// close finishes the response: it closes the stream, runs the close hooks and onClose
+// callbacks, removes the uploaded files and completes the request of a streamed response.
+// Only the dispatcher calls it.
+func (r *ExpressResponse) close() {
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.stream != nil {
+		close(r.stream.done)
+	}
+
+	for _, hook := range r.closeHooks {
+		hook()
+	}
+	for _, callback := range r.onClose {
+		if _, err := callback(goja.Undefined()); err != nil {
+			log.Error().Err(err).Msg("Response onClose callback failed")
+		}
+	}
+	removeUploads(r.uploads)
+	if r.stream != nil && r.stream.finish != nil {
+		r.stream.finish()
+	}
+}
+
+// awaitStream keeps the request of a handler that left its response stream open until the
+// stream closes. finish completes the job.
+func (e *Engine) awaitStream(job EvalJob, resObj *ExpressResponse, finish func(error)) {
+	stream := resObj.stream
+	stream.finish = func() {
+		finish(nil)
+	}
+
+	rt := e.rt
+	go func() {
+		select {
+		case <-stream.done:
+		case <-job.R.Context().Done():
+			// The client disconnected
+			e.jobs <- EvalJob{async: &asyncTask{
+				rt:     rt,
+				origin: job.Handler.Doc.Method + " " + job.Handler.Doc.Path,
+				run: func() error {
+					resObj.close()
+					return nil
+				},
+			}}
+		}
+	}()
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// resultPageItems is the number of array elements or object entries per stored result page
+const resultPageItems = 100
+
+// pageExecutionResult splits an array or object result larger than the page threshold into
+// pages stored next to the execution. The stored result keeps the first page as a preview,
+// so listing executions does not ship the whole result.
+func (e *Engine) pageExecutionResult(req *repository.CreateExecutionRequest) {
+	e.mu.RLock()
+	threshold := e.outputLimits.PageResultBytes
+	e.mu.RUnlock()
+
+	if req.Result == nil || threshold <= 0 || len(*req.Result) <= threshold {
+		return
+	}
+	result := []byte(*req.Result)
+
+	var kind, preview string
+	var items []json.RawMessage
+	switch firstByte(result) {
+	case '[':
+		kind = repository.ResultKindArray
+		if err := json.Unmarshal(result, &items); err != nil {
+			log.Warn().Err(err).Msg("Failed to split result into pages")
+			return
+		}
+		if len(items) > resultPageItems {
+			data, _ := json.Marshal(items[:resultPageItems])
+			preview = string(data)
+		}
+	case '{':
+		kind = repository.ResultKindObject
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(result, &object); err != nil {
+			log.Warn().Err(err).Msg("Failed to split result into pages")
+			return
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entry, _ := json.Marshal(resultEntry{Key: key, Value: object[key]})
+			items = append(items, entry)
+		}
+		if len(keys) > resultPageItems {
+			first := make(map[string]json.RawMessage, resultPageItems)
+			for _, key := range keys[:resultPageItems] {
+				first[key] = object[key]
+			}
+			data, _ := json.Marshal(first)
+			preview = string(data)
+		}
+	default:
+		// Strings and numbers are only truncated
+		return
+	}
+	if len(items) <= resultPageItems {
+		// A single page would not make the record smaller
+		return
+	}
+
+	var pages [][]json.RawMessage
+	for start := 0; start < len(items); start += resultPageItems {
+		end := min(start+resultPageItems, len(items))
+		pages = append(pages, items[start:end])
+	}
+
+	req.Paging = &repository.ResultPaging{
+		Kind:     kind,
+		Items:    len(items),
+		PageSize: resultPageItems,
+		Bytes:    len(result),
+	}
+	req.Pages = pages
+	req.Result = &preview
+
+	log.Debug().
+		Str("sessionID", req.SessionID).
+		Int("items", len(items)).
+		Int("pages", len(pages)).
+		Msg("Stored execution result in pages")
+}
+
+// resultEntry is an item of a paged object result
+type resultEntry struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// firstByte returns the first non-whitespace byte of data
+func firstByte(data []byte) byte {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return 0
+	}
+	return trimmed[0]
+}
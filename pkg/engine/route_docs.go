@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// RouteParam documents a single route parameter
+type RouteParam struct {
+	Name        string `json:"name"`
+	In          string `json:"in"` // path, query or header
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// RouteDoc is the documentation attached to a route at registration time, e.g.
+// app.get('/users/:id', handler, {summary: 'Get a user', params: {id: 'User ID'}, responses: {200: 'The user'}})
+type RouteDoc struct {
+	Method      string            `json:"method"`
+	Path        string            `json:"path"`
+	Summary     string            `json:"summary,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Params      []RouteParam      `json:"params,omitempty"`
+	Responses   map[string]string `json:"responses,omitempty"` // status code -> description
+	Auth        string            `json:"auth,omitempty"`      // Auth option of the route, e.g. "apiKey"
+
+	ResponseSchema map[string]interface{} `json:"responseSchema,omitempty"` // JSON schema of the successful responses, see parseResponseSchema
+}
+
+// parseRouteDoc extracts the documentation fields from the options passed to app.get & co.
+// Path parameters that are not documented explicitly are still listed so generated docs are complete.
+func parseRouteDoc(method, path string, options map[string]interface{}) RouteDoc {
+	doc := RouteDoc{Method: method, Path: path}
+
+	if summary, ok := options["summary"].(string); ok {
+		doc.Summary = summary
+	}
+	if auth, ok := options["auth"].(string); ok {
+		doc.Auth = auth
+	}
+	if description, ok := options["description"].(string); ok {
+		doc.Description = description
+	}
+	if tags, ok := options["tags"].([]interface{}); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				doc.Tags = append(doc.Tags, s)
+			}
+		}
+	}
+
+	doc.Params = parseRouteParams(path, options["params"])
+
+	if schema, ok := options["responseSchema"].(map[string]interface{}); ok {
+		doc.ResponseSchema = schema
+	}
+
+	if responses, ok := options["responses"].(map[string]interface{}); ok {
+		doc.Responses = make(map[string]string, len(responses))
+		for code, value := range responses {
+			switch v := value.(type) {
+			case string:
+				doc.Responses[code] = v
+			case map[string]interface{}:
+				description, _ := v["description"].(string)
+				doc.Responses[code] = description
+			default:
+				doc.Responses[code] = fmt.Sprint(v)
+			}
+		}
+	}
+
+	return doc
+}
+
+// parseRouteParams accepts either {name: 'description'}, {name: {in, type, description, required}}
+// or an array of {name, in, type, description, required} objects.
+func parseRouteParams(path string, raw interface{}) []RouteParam {
+	var params []RouteParam
+
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			param := RouteParam{Name: name}
+			switch spec := v[name].(type) {
+			case string:
+				param.Description = spec
+			case map[string]interface{}:
+				applyParamSpec(&param, spec)
+			}
+			params = append(params, param)
+		}
+	case []interface{}:
+		for _, item := range v {
+			if spec, ok := item.(map[string]interface{}); ok {
+				param := RouteParam{}
+				applyParamSpec(&param, spec)
+				if param.Name != "" {
+					params = append(params, param)
+				}
+			}
+		}
+	}
+
+	pathParams := pathParamNames(path)
+	for i := range params {
+		if params[i].In == "" {
+			params[i].In = "query"
+			for _, name := range pathParams {
+				if name == params[i].Name {
+					params[i].In = "path"
+				}
+			}
+		}
+		if params[i].In == "path" {
+			params[i].Required = true
+		}
+	}
+
+	// Add path parameters that were not documented explicitly
+	for _, name := range pathParams {
+		found := false
+		for _, param := range params {
+			if param.Name == name && param.In == "path" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			params = append(params, RouteParam{Name: name, In: "path", Required: true})
+		}
+	}
+
+	return params
+}
+
+func applyParamSpec(param *RouteParam, spec map[string]interface{}) {
+	if name, ok := spec["name"].(string); ok {
+		param.Name = name
+	}
+	if in, ok := spec["in"].(string); ok {
+		param.In = in
+	}
+	if typ, ok := spec["type"].(string); ok {
+		param.Type = typ
+	}
+	if description, ok := spec["description"].(string); ok {
+		param.Description = description
+	}
+	if required, ok := spec["required"].(bool); ok {
+		param.Required = required
+	}
+}
+
+// pathParamNames returns the names of the parameters of a route pattern
+func pathParamNames(pattern string) []string {
+	route, err := compileRoutePattern(pattern)
+	if err != nil {
+		return nil
+	}
+	return route.paramNames()
+}
+
+// GetRouteDocs returns the documentation of every registered route, sorted by path and method
+func (e *Engine) GetRouteDocs() []RouteDoc {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	docs := make([]RouteDoc, 0, len(e.handlers))
+	for path, methods := range e.handlers {
+		for method, handler := range methods {
+			doc := handler.Doc
+			if doc.Method == "" {
+				doc = RouteDoc{Method: method, Path: path}
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].Path != docs[j].Path {
+			return docs[i].Path < docs[j].Path
+		}
+		return docs[i].Method < docs[j].Method
+	})
+	return docs
+}
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+)
+
+// Route graph
+//
+// RouteGraph describes how the parts of an app relate, to help find one's way around apps
+// built up over many sessions: the script file or execution session that registered each
+// route, and the tables each route read and wrote in the requests of the request log.
+// Sessions of the MCP server are marked as AI, since an assistant wrote their code.
+
+// Kinds of route graph nodes
+const (
+	GraphNodeScript  = "script"  // Script file of the scripts directory
+	GraphNodeSession = "session" // Execution session, e.g. of /v1/execute or the MCP server
+	GraphNodeCode    = "code"    // Code of unknown origin, like the bootstrap file run on startup
+	GraphNodeRoute   = "route"   // Route or file handler
+	GraphNodeTable   = "table"   // Table of the application database
+)
+
+// Kinds of route graph edges
+const (
+	GraphEdgeRegisters = "registers" // Script or session -> route
+	GraphEdgeReads     = "reads"     // Route -> table
+	GraphEdgeWrites    = "writes"    // Route -> table
+)
+
+// graphNodeOrder sorts the nodes from the code that registers routes to the tables
+var graphNodeOrder = map[string]int{
+	GraphNodeScript:  0,
+	GraphNodeSession: 1,
+	GraphNodeCode:    2,
+	GraphNodeRoute:   3,
+	GraphNodeTable:   4,
+}
+
+var (
+	// sqlWrittenTable matches the statements writing a table
+	sqlWrittenTable = regexp.MustCompile(`(?i)\b(?:INSERT(?:\s+OR\s+\w+)?\s+INTO|REPLACE\s+INTO|UPDATE(?:\s+OR\s+\w+)?|DELETE\s+FROM|CREATE\s+TABLE(?:\s+IF\s+NOT\s+EXISTS)?|DROP\s+TABLE(?:\s+IF\s+EXISTS)?|ALTER\s+TABLE)\s+["` + "`" + `\[]?(\w+)`)
+	// sqlReadTable matches the clauses reading a table
+	sqlReadTable = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+["` + "`" + `\[]?(\w+)`)
+)
+
+// GraphNode is a script, session, route or table of the route graph
+type GraphNode struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"` // One of the GraphNode* constants
+	Label    string `json:"label"`
+	Source   string `json:"source,omitempty"`   // Execution source of a session, one of the repository.Source* constants
+	AI       bool   `json:"ai,omitempty"`       // Session whose code an AI assistant sent through the MCP server
+	Requests int    `json:"requests,omitempty"` // Requests of a route in the request log
+}
+
+// GraphEdge relates two nodes of the route graph
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`            // One of the GraphEdge* constants
+	Count int    `json:"count,omitempty"` // Database operations behind a reads or writes edge
+}
+
+// RouteGraph relates the scripts, routes and tables of an app
+type RouteGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// routeGraphBuilder collects the nodes and edges of a route graph without duplicates
+type routeGraphBuilder struct {
+	nodes map[string]*GraphNode
+	edges map[string]*GraphEdge
+}
+
+func (b *routeGraphBuilder) node(node GraphNode) *GraphNode {
+	if existing, ok := b.nodes[node.ID]; ok {
+		return existing
+	}
+	b.nodes[node.ID] = &node
+	return &node
+}
+
+func (b *routeGraphBuilder) edge(from, to, kind string, count int) {
+	key := from + "\x00" + to + "\x00" + kind
+	if existing, ok := b.edges[key]; ok {
+		existing.Count += count
+		return
+	}
+	b.edges[key] = &GraphEdge{From: from, To: to, Kind: kind, Count: count}
+}
+
+// RouteGraph builds the graph of the routes registered now and the database operations of
+// the requests in the request log
+func (e *Engine) RouteGraph() *RouteGraph {
+	root := e.root()
+	b := &routeGraphBuilder{nodes: map[string]*GraphNode{}, edges: map[string]*GraphEdge{}}
+	scriptsDir := root.ScriptsDir()
+
+	registeredBy := func(script, session, origin string) string {
+		switch {
+		case script != "":
+			label := script
+			if rel, err := filepath.Rel(scriptsDir, script); scriptsDir != "" && err == nil && !strings.HasPrefix(rel, "..") {
+				label = rel
+			}
+			return b.node(GraphNode{ID: "script:" + script, Kind: GraphNodeScript, Label: label}).ID
+		case session != "":
+			ai := origin == repository.SourceMCP || origin == repository.SourceMCPFile
+			return b.node(GraphNode{ID: "session:" + session, Kind: GraphNodeSession, Label: session, Source: origin, AI: ai}).ID
+		}
+		return b.node(GraphNode{ID: "code", Kind: GraphNodeCode, Label: "other code"}).ID
+	}
+
+	root.mu.RLock()
+	for path, methods := range root.handlers {
+		for method, handler := range methods {
+			route := b.node(GraphNode{ID: "route:" + method + " " + path, Kind: GraphNodeRoute, Label: method + " " + path})
+			b.edge(registeredBy(handler.script, handler.session, handler.origin), route.ID, GraphEdgeRegisters, 0)
+		}
+	}
+	for path := range root.files {
+		route := b.node(GraphNode{ID: "route:FILE " + path, Kind: GraphNodeRoute, Label: "FILE " + path})
+		b.edge(registeredBy(root.fileScripts[path], "", ""), route.ID, GraphEdgeRegisters, 0)
+	}
+	root.mu.RUnlock()
+
+	for _, request := range root.reqLogger.GetAllRequests() {
+		if request.Handler == "" {
+			continue
+		}
+		route := b.node(GraphNode{ID: "route:" + request.Handler, Kind: GraphNodeRoute, Label: request.Handler})
+		route.Requests++
+		for _, op := range request.DatabaseOps {
+			written, read := sqlTables(op.SQL)
+			for _, table := range written {
+				b.edge(route.ID, b.node(GraphNode{ID: "table:" + table, Kind: GraphNodeTable, Label: table}).ID, GraphEdgeWrites, 1)
+			}
+			for _, table := range read {
+				b.edge(route.ID, b.node(GraphNode{ID: "table:" + table, Kind: GraphNodeTable, Label: table}).ID, GraphEdgeReads, 1)
+			}
+		}
+	}
+
+	graph := &RouteGraph{Nodes: make([]GraphNode, 0, len(b.nodes)), Edges: make([]GraphEdge, 0, len(b.edges))}
+	for _, node := range b.nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		a, c := graph.Nodes[i], graph.Nodes[j]
+		if graphNodeOrder[a.Kind] != graphNodeOrder[c.Kind] {
+			return graphNodeOrder[a.Kind] < graphNodeOrder[c.Kind]
+		}
+		return a.Label < c.Label
+	})
+	for _, edge := range b.edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		a, c := graph.Edges[i], graph.Edges[j]
+		if a.From != c.From {
+			return a.From < c.From
+		}
+		if a.To != c.To {
+			return a.To < c.To
+		}
+		return a.Kind < c.Kind
+	})
+	return graph
+}
+
+// sqlTables returns the tables a statement writes and reads. A DELETE FROM counts as a
+// write only.
+func sqlTables(sql string) (written, read []string) {
+	for _, match := range sqlWrittenTable.FindAllStringSubmatch(sql, -1) {
+		written = appendTable(written, match[1])
+	}
+	rest := sqlWrittenTable.ReplaceAllString(sql, "")
+	for _, match := range sqlReadTable.FindAllStringSubmatch(rest, -1) {
+		read = appendTable(read, match[1])
+	}
+	return written, read
+}
+
+// appendTable adds a table name once, ignoring SQLite's own tables and the SET of an upsert
+func appendTable(tables []string, table string) []string {
+	table = strings.ToLower(table)
+	if strings.HasPrefix(table, "sqlite_") || table == "set" {
+		return tables
+	}
+	for _, t := range tables {
+		if t == table {
+			return tables
+		}
+	}
+	return append(tables, table)
+}
+
+// DOT renders the graph in the Graphviz DOT language, for `dot -Tsvg`
+func (g *RouteGraph) DOT() string {
+	shapes := map[string]string{
+		GraphNodeScript:  "note",
+		GraphNodeSession: "component",
+		GraphNodeCode:    "note",
+		GraphNodeRoute:   "box",
+		GraphNodeTable:   "cylinder",
+	}
+	var sb strings.Builder
+	sb.WriteString("digraph routes {\n\trankdir=LR;\n\tnode [fontname=\"Helvetica\"];\n")
+	for _, node := range g.Nodes {
+		label := node.Label
+		if node.AI {
+			label += " (AI)"
+		}
+		fmt.Fprintf(&sb, "\t%q [label=%q, shape=%s];\n", node.ID, label, shapes[node.Kind])
+	}
+	for _, edge := range g.Edges {
+		label := edge.Kind
+		if edge.Count > 0 {
+			label = fmt.Sprintf("%s (%d)", edge.Kind, edge.Count)
+		}
+		style := ""
+		if edge.Kind == GraphEdgeReads {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&sb, "\t%q -> %q [label=%q%s];\n", edge.From, edge.To, label, style)
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
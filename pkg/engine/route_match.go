@@ -0,0 +1,292 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Route patterns
+//
+// Route paths are matched segment by segment:
+//
+//	/users/:id          a parameter matches any one segment
+//	/users/:id(\d+)     a parameter with a regular expression the whole segment must match
+//	/posts/:slug?       an optional parameter may be left out
+//	/files/*            a wildcard matches the rest of the path, also nothing, as req.params[0]
+//	/files/*path        a named wildcard, as req.params.path
+//
+// Regular expressions cannot contain a slash, and a wildcard must be the last segment. When
+// several routes match a path, the most specific one wins: segments are compared from left to
+// right, and a literal beats a parameter with a regular expression, which beats a plain
+// parameter, then an optional parameter, then a wildcard. When one route extends the other,
+// the one with fewer optional parameters and wildcards wins.
+
+// routeSegmentKind orders segment kinds from least to most specific
+type routeSegmentKind int
+
+const (
+	segmentWildcard routeSegmentKind = iota
+	segmentOptional
+	segmentParam
+	segmentRegexp
+	segmentLiteral
+)
+
+type routeSegment struct {
+	kind    routeSegmentKind
+	literal string         // Text of a literal segment
+	name    string         // Parameter name, "0" for an unnamed wildcard
+	re      *regexp.Regexp // Constraint of a segmentRegexp parameter, also set on optional parameters with one
+}
+
+// routePattern is a compiled route path
+type routePattern struct {
+	path     string
+	segments []routeSegment
+}
+
+// routeParamRegexp parses a parameter segment: name, optional (regexp) and optional ?
+var routeParamRegexp = regexp.MustCompile(`^:([A-Za-z_$][\w$]*)(?:\((.+)\))?(\?)?$`)
+
+// compileRoutePattern parses a route path
+func compileRoutePattern(path string) (*routePattern, error) {
+	p := &routePattern{path: path}
+	parts := splitRoutePath(path)
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "*"):
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("wildcard %q must be the last segment of %s", part, path)
+			}
+			name := part[1:]
+			if name == "" {
+				name = "0"
+			}
+			p.segments = append(p.segments, routeSegment{kind: segmentWildcard, name: name})
+		case strings.HasPrefix(part, ":"):
+			m := routeParamRegexp.FindStringSubmatch(part)
+			if m == nil {
+				return nil, fmt.Errorf("invalid parameter %q in %s", part, path)
+			}
+			segment := routeSegment{kind: segmentParam, name: m[1]}
+			if m[2] != "" {
+				re, err := regexp.Compile("^(?:" + m[2] + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("invalid regular expression of parameter %s in %s: %w", m[1], path, err)
+				}
+				segment.kind, segment.re = segmentRegexp, re
+			}
+			if m[3] != "" {
+				segment.kind = segmentOptional
+			}
+			p.segments = append(p.segments, segment)
+		default:
+			p.segments = append(p.segments, routeSegment{kind: segmentLiteral, literal: part})
+		}
+	}
+	return p, nil
+}
+
+// splitRoutePath splits a path into its segments; the root path has none
+func splitRoutePath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// match reports whether the pattern matches a request path and returns its parameters
+func (p *routePattern) match(path string) (map[string]string, bool) {
+	params := make(map[string]string)
+	if !matchRouteSegments(p.segments, splitRoutePath(path), params) {
+		return nil, false
+	}
+	return params, true
+}
+
+// matchRouteSegments matches segments against path parts, trying optional parameters with
+// and without a part
+func matchRouteSegments(segments []routeSegment, parts []string, params map[string]string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+
+	segment := segments[0]
+	switch segment.kind {
+	case segmentWildcard:
+		params[segment.name] = strings.Join(parts, "/")
+		return true
+	case segmentOptional:
+		if len(parts) > 0 && (segment.re == nil || segment.re.MatchString(parts[0])) {
+			params[segment.name] = parts[0]
+			if matchRouteSegments(segments[1:], parts[1:], params) {
+				return true
+			}
+			delete(params, segment.name)
+		}
+		return matchRouteSegments(segments[1:], parts, params)
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+	switch segment.kind {
+	case segmentLiteral:
+		if segment.literal != parts[0] {
+			return false
+		}
+	case segmentRegexp:
+		if !segment.re.MatchString(parts[0]) {
+			return false
+		}
+		params[segment.name] = parts[0]
+	default:
+		params[segment.name] = parts[0]
+	}
+	return matchRouteSegments(segments[1:], parts[1:], params)
+}
+
+// paramNames returns the names of the parameters of the pattern, wildcards excluded
+func (p *routePattern) paramNames() []string {
+	var names []string
+	for _, segment := range p.segments {
+		if segment.kind != segmentWildcard && segment.kind != segmentLiteral {
+			names = append(names, segment.name)
+		}
+	}
+	return names
+}
+
+// moreSpecific reports whether p wins over q for paths both match. Segments are compared
+// from left to right; with equal segments the pattern with fewer optional parameters and
+// wildcards wins, so /users/:id beats /users/:id/:tab? for /users/5, then the longer pattern
+// wins, then the path sorts first.
+func (p *routePattern) moreSpecific(q *routePattern) bool {
+	for i := 0; i < len(p.segments) && i < len(q.segments); i++ {
+		if p.segments[i].kind != q.segments[i].kind {
+			return p.segments[i].kind > q.segments[i].kind
+		}
+	}
+	if pv, qv := p.variableSegments(), q.variableSegments(); pv != qv {
+		return pv < qv
+	}
+	if len(p.segments) != len(q.segments) {
+		return len(p.segments) > len(q.segments)
+	}
+	return p.path < q.path
+}
+
+// variableSegments counts the optional parameters and wildcards, the segments that may match
+// no part of the path
+func (p *routePattern) variableSegments() int {
+	n := 0
+	for _, segment := range p.segments {
+		if segment.kind == segmentOptional || segment.kind == segmentWildcard {
+			n++
+		}
+	}
+	return n
+}
+
+// addRoutePattern adds the pattern of a newly registered path to the match order.
+// The caller holds e.mu.
+func (e *Engine) addRoutePattern(pattern *routePattern) {
+	for _, existing := range e.routeOrder {
+		if existing.path == pattern.path {
+			return
+		}
+	}
+	e.routeOrder = append(e.routeOrder, pattern)
+	sort.Slice(e.routeOrder, func(i, j int) bool {
+		return e.routeOrder[i].moreSpecific(e.routeOrder[j])
+	})
+}
+
+// removeRoutePattern drops the pattern of a path that has no handlers left from the match
+// order. The caller holds e.mu.
+func (e *Engine) removeRoutePattern(path string) {
+	for i, existing := range e.routeOrder {
+		if existing.path == path {
+			e.routeOrder = append(e.routeOrder[:i], e.routeOrder[i+1:]...)
+			return
+		}
+	}
+}
+
+// routeParams returns the parameters of a request path for a handler's route
+func routeParams(handler *HandlerInfo, path string) map[string]string {
+	if handler.route == nil {
+		return make(map[string]string)
+	}
+	params, ok := handler.route.match(path)
+	if !ok {
+		return make(map[string]string)
+	}
+	return params
+}
+
+// AllMethods is the method app.all() routes are registered under
+const AllMethods = "ALL"
+
+// allowedAllMethods are the methods an app.all() route lists in the Allow header
+var allowedAllMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch}
+
+// handlerForMethod picks the handler of a route for a request method: the one registered for
+// the method, the GET handler for a HEAD request, then an app.all() handler. WebSocket
+// upgrades only go to app.ws() handlers.
+func handlerForMethod(methods map[string]*HandlerInfo, method string) (*HandlerInfo, bool) {
+	if handler, ok := methods[method]; ok {
+		return handler, true
+	}
+	if method == http.MethodHead {
+		if handler, ok := methods[http.MethodGet]; ok {
+			return handler, true
+		}
+	}
+	if method == WebSocketMethod {
+		return nil, false
+	}
+	handler, ok := methods[AllMethods]
+	return handler, ok
+}
+
+// AllowedMethods returns the methods of the routes matching a path, for the Allow header of an
+// OPTIONS request no route answers. GET routes also allow HEAD. Nil means no route matches.
+func (e *Engine) AllowedMethods(path string) []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	allowed := make(map[string]bool)
+	for _, pattern := range e.routeOrder {
+		if _, ok := pattern.match(path); !ok {
+			continue
+		}
+		for method := range e.handlers[pattern.path] {
+			switch method {
+			case WebSocketMethod:
+			case AllMethods:
+				for _, m := range allowedAllMethods {
+					allowed[m] = true
+				}
+			case http.MethodGet:
+				allowed[http.MethodGet] = true
+				allowed[http.MethodHead] = true
+			default:
+				allowed[method] = true
+			}
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
@@ -0,0 +1,459 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route middleware
+//
+// Besides timeout and the documentation fields, the options of app.get/post/... declare
+// features the JS web server applies in Go before a request reaches the dispatcher:
+//
+//	app.get('/reports', handler, {
+//	  auth: 'apiKey',                                    // require a configured API key
+//	  cache: { ttl: '30s' },                             // serve repeated GETs from memory
+//	  rateLimit: { requests: 10, window: '1m', by: 'ip' },
+//	  bodyLimit: '1mb',                                  // reject larger request bodies
+//	  mirror: '/v2/reports',                             // copy requests to a new version, see MirrorRequest
+//	  compress: false,                                   // opt out of gzip, see SetResponseCompression
+//	})
+//
+// Rejected requests never run JavaScript.
+
+// RouteAuthAPIKey is the auth option requiring one of the API keys set with SetAPIKeys
+const RouteAuthAPIKey = "apiKey"
+
+// APIKeyHeader names the header carrying the API key. A bearer token in the Authorization
+// header works as well.
+const APIKeyHeader = "X-API-Key"
+
+// Rate limit keys
+const (
+	RateLimitByIP     = "ip"     // Count requests per client IP
+	RateLimitByAPIKey = "apiKey" // Count requests per API key
+)
+
+// maxRateLimitClients bounds the clients a rate limiter tracks before it drops expired ones
+const maxRateLimitClients = 10000
+
+// maxCachedResponses bounds the responses a route cache keeps
+const maxCachedResponses = 1000
+
+// RouteMiddleware holds the per-route features declared in the options of a handler
+type RouteMiddleware struct {
+	Auth      string       // RouteAuthAPIKey to require an API key, "" for none
+	Cache     *RouteCache  // Response cache of GET requests, nil if disabled
+	RateLimit *RateLimiter // Request limit per client, nil if unlimited
+	BodyLimit int64        // Maximum request body size in bytes, 0 for no limit
+	CORS      *CORSPolicy  // Policy of the cors() middleware of the route, see Engine.CORSPolicy
+	Mirror    *RouteMirror // Second version of the endpoint requests are copied to, nil for none
+	Compress  *bool        // Whether responses are gzipped, nil for the server default
+}
+
+// IsEmpty reports whether the route declares no middleware
+func (m *RouteMiddleware) IsEmpty() bool {
+	return m == nil || (m.Auth == "" && m.Cache == nil && m.RateLimit == nil && m.BodyLimit == 0 && m.Mirror == nil)
+}
+
+// parseRouteMiddleware reads the auth, cache, rateLimit, bodyLimit, mirror and compress options of a route
+func parseRouteMiddleware(method string, options map[string]interface{}) (*RouteMiddleware, error) {
+	m := &RouteMiddleware{}
+
+	switch auth := options["auth"].(type) {
+	case nil:
+	case string:
+		if auth != RouteAuthAPIKey {
+			return nil, fmt.Errorf("unsupported auth %q, expected %q", auth, RouteAuthAPIKey)
+		}
+		m.Auth = auth
+	default:
+		return nil, fmt.Errorf("auth must be a string, got %T", auth)
+	}
+
+	if value, ok := options["cache"]; ok && value != nil {
+		cache, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("cache must be an object like { ttl: '30s' }, got %T", value)
+		}
+		if method != http.MethodGet {
+			return nil, fmt.Errorf("cache is only supported on GET routes")
+		}
+		ttl, err := parseDurationOption("cache.ttl", cache["ttl"])
+		if err != nil {
+			return nil, err
+		}
+		if ttl <= 0 {
+			return nil, fmt.Errorf("cache.ttl must be positive")
+		}
+		m.Cache = NewRouteCache(ttl)
+	}
+
+	if value, ok := options["rateLimit"]; ok && value != nil {
+		limit, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("rateLimit must be an object like { requests: 10, window: '1m' }, got %T", value)
+		}
+		requests, ok := numberOption(limit["requests"])
+		if !ok || requests < 1 {
+			return nil, fmt.Errorf("rateLimit.requests must be a positive number")
+		}
+		window := time.Minute
+		if limit["window"] != nil {
+			var err error
+			if window, err = parseDurationOption("rateLimit.window", limit["window"]); err != nil {
+				return nil, err
+			}
+			if window <= 0 {
+				return nil, fmt.Errorf("rateLimit.window must be positive")
+			}
+		}
+		by := RateLimitByIP
+		switch v := limit["by"].(type) {
+		case nil:
+		case string:
+			if v != RateLimitByIP && v != RateLimitByAPIKey {
+				return nil, fmt.Errorf("unsupported rateLimit.by %q, expected %q or %q", v, RateLimitByIP, RateLimitByAPIKey)
+			}
+			by = v
+		default:
+			return nil, fmt.Errorf("rateLimit.by must be a string, got %T", v)
+		}
+		m.RateLimit = NewRateLimiter(int(requests), window, by)
+	}
+
+	if value, ok := options["bodyLimit"]; ok && value != nil {
+		limit, err := parseByteSize(value)
+		if err != nil {
+			return nil, fmt.Errorf("bodyLimit: %w", err)
+		}
+		m.BodyLimit = limit
+	}
+
+	switch compress := options["compress"].(type) {
+	case nil:
+	case bool:
+		m.Compress = &compress
+	default:
+		return nil, fmt.Errorf("compress must be a boolean, got %T", compress)
+	}
+
+	if value, ok := options["mirror"]; ok && value != nil {
+		mirror, err := parseRouteMirror(value)
+		if err != nil {
+			return nil, err
+		}
+		m.Mirror = mirror
+	}
+
+	return m, nil
+}
+
+// parseDurationOption reads a duration given as seconds or as a duration string such as "30s"
+func parseDurationOption(name string, value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case nil:
+		return 0, fmt.Errorf("%s is required", name)
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: %w", name, v, err)
+		}
+		return d, nil
+	default:
+		seconds, ok := numberOption(v)
+		if !ok {
+			return 0, fmt.Errorf("%s must be a number of seconds or a duration string, got %T", name, value)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+}
+
+// parseByteSize reads a size given as bytes or as a string such as "512kb" or "1mb"
+func parseByteSize(value interface{}) (int64, error) {
+	if n, ok := numberOption(value); ok {
+		if n < 1 {
+			return 0, fmt.Errorf("size must be positive")
+		}
+		return int64(n), nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return 0, fmt.Errorf("size must be a number of bytes or a string like \"1mb\", got %T", value)
+	}
+
+	text := strings.ToLower(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		factor int64
+	}{{"kb", 1 << 10}, {"mb", 1 << 20}, {"gb", 1 << 30}, {"b", 1}} {
+		if strings.HasSuffix(text, unit.suffix) {
+			text = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix))
+			multiplier = unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// SetAPIKeys sets the keys accepted by routes with auth: 'apiKey'
+func (e *Engine) SetAPIKeys(keys []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.apiKeys = nil
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			e.apiKeys = append(e.apiKeys, key)
+		}
+	}
+}
+
+// SetResponseCompression sets whether the JS web server gzips large text responses of
+// routes for clients that accept it. The compress option of a route overrides it.
+func (e *Engine) SetResponseCompression(enabled bool) {
+	e.mu.Lock()
+	e.compression = enabled
+	e.mu.Unlock()
+}
+
+// ResponseCompression reports whether route responses are compressed by default
+func (e *Engine) ResponseCompression() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.compression
+}
+
+// HasAPIKeys reports whether any API key is configured
+func (e *Engine) HasAPIKeys() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.apiKeys) > 0
+}
+
+// SetTrustedProxies sets the proxies, as IP addresses or CIDR ranges, whose X-Forwarded-For
+// and X-Real-IP headers name the client of a request. Other requests are attributed to the
+// address they come from, so clients cannot pick their own req.ip or rate limit bucket.
+func (e *Engine) SetTrustedProxies(proxies []string) error {
+	var nets []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		nets = append(nets, ipNet)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.trustedProxies = nets
+	return nil
+}
+
+// ClientIP returns the address of the client of a request, as req.ip and rate limits see it.
+// Forwarding headers are only believed when the request comes from a trusted proxy.
+func (e *Engine) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !e.root().trustedProxy(host) {
+		return host
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		return strings.TrimSpace(xri)
+	}
+	return host
+}
+
+// trustedProxy reports whether an address belongs to a trusted proxy
+func (e *Engine) trustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, ipNet := range e.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestAPIKey returns the API key of the X-API-Key header or of a bearer token
+func RequestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// ValidAPIKey reports whether key is one of the configured API keys
+func (e *Engine) ValidAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	valid := false
+	for _, apiKey := range e.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// APIKeyActor returns the actor recorded for executions of a request authenticated with a
+// configured API key: "api-key:" and a fingerprint of the key, which is never stored itself.
+// Requests without a valid key have no actor.
+func (e *Engine) APIKeyActor(r *http.Request) string {
+	key := RequestAPIKey(r)
+	if !e.ValidAPIKey(key) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "api-key:" + hex.EncodeToString(sum[:4])
+}
+
+// cacheCredentialHeaders identify the client of a request. Responses are cached for each
+// value of them, so a response to one client is never served to another.
+var cacheCredentialHeaders = []string{"Authorization", "Cookie", "X-API-Key"}
+
+// RouteCache keeps successful responses of a GET route for a while. Responses are stored by
+// path and query string, the credentials of the request and the request headers named by the
+// Vary header of the response.
+type RouteCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*CachedResponse
+	vary    map[string][]string // Path and query string -> request headers the responses vary by
+}
+
+// NewRouteCache creates a cache keeping responses for ttl
+func NewRouteCache(ttl time.Duration) *RouteCache {
+	return &RouteCache{TTL: ttl, entries: make(map[string]*CachedResponse), vary: make(map[string][]string)}
+}
+
+// CachedResponse is a response stored by RouteCache
+type CachedResponse struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	expires time.Time
+}
+
+// Get returns the response cached for the request unless it expired
+func (c *RouteCache) Get(r *http.Request, now time.Time) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(r, c.vary[r.URL.RequestURI()])
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// Put caches the response to the request until the TTL expires. Responses marked private,
+// no-store or no-cache by their Cache-Control header, and those with Vary: *, are not cached.
+func (c *RouteCache) Put(r *http.Request, response CachedResponse, now time.Time) {
+	varied, ok := cacheableVary(response.Header)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	key := cacheKey(r, varied)
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCachedResponses {
+		return
+	}
+	response.expires = now.Add(c.TTL)
+	c.entries[key] = &response
+	c.vary[r.URL.RequestURI()] = varied
+}
+
+// cacheableVary returns the request headers named by the Vary header of a response, and
+// false if the response must not be cached
+func cacheableVary(header http.Header) ([]string, bool) {
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "private", "no-store", "no-cache":
+				return nil, false
+			}
+		}
+	}
+
+	var varied []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			switch {
+			case name == "*":
+				return nil, false
+			case name != "":
+				varied = append(varied, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return varied, true
+}
+
+// cacheKey returns what the response to a request is cached by: its path and query string
+// and a hash of its credentials and varied headers
+func cacheKey(r *http.Request, varied []string) string {
+	hash := sha256.New()
+	for _, name := range append(append([]string{}, cacheCredentialHeaders...), varied...) {
+		_, _ = fmt.Fprintf(hash, "%s:%q\n", name, r.Header.Values(name))
+	}
+	return r.URL.RequestURI() + " " + hex.EncodeToString(hash.Sum(nil))
+}
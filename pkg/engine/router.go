@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Sub-routers
+//
+// Router() returns an express.Router()-style object grouping routes of one area of an app.
+// Its routes register once the router is mounted with app.use(prefix, router):
+//
+//	const users = Router();
+//	users.use(requireUser);                  // middleware for the routes declared after it
+//	users.get('/', listUsers);               // GET /api/users
+//	users.get('/:id', getUser);              // GET /api/users/:id
+//	app.use('/api/users', users);
+//
+// Routers nest with router.use(prefix, router), and routes added to a router after it was
+// mounted register right away.
+
+// jsRouter is the object returned by Router()
+type jsRouter struct {
+	e          *Engine
+	middleware []goja.Value  // Functions passed to router.use(), in order
+	items      []routerItem  // Routes and nested routers, in declaration order
+	mounts     []routerMount // Where the router is mounted in the app
+}
+
+// routerItem is a route or a nested router. Middleware counts the router middleware declared
+// before it, which is the middleware it runs.
+type routerItem struct {
+	method     string
+	path       string
+	args       []goja.Value // Middleware, handler and options of a route, or middleware of a nested router
+	router     *jsRouter    // Nested router, nil for a route
+	middleware int
+}
+
+// routerMount is a place a router is mounted at, with the middleware of the routers above it
+type routerMount struct {
+	prefix     string
+	middleware []goja.Value
+}
+
+// newRouter implements Router()
+func (e *Engine) newRouter() *jsRouter {
+	return &jsRouter{e: e}
+}
+
+// joinRoutePath joins a mount prefix and a route path
+func joinRoutePath(prefix, path string) string {
+	joined := strings.TrimRight(prefix, "/") + "/" + strings.TrimLeft(path, "/")
+	if len(joined) > 1 {
+		joined = strings.TrimRight(joined, "/")
+	}
+	return joined
+}
+
+func (r *jsRouter) route(method, path string, args []goja.Value) *jsRouter {
+	if len(args) == 0 {
+		panic(r.e.rt.NewTypeError(method + " " + path + ": handler must be a function"))
+	}
+	r.add(routerItem{method: method, path: path, args: args, middleware: len(r.middleware)})
+	return r
+}
+
+// add records an item and registers it wherever the router is already mounted
+func (r *jsRouter) add(item routerItem) {
+	r.items = append(r.items, item)
+	for _, m := range r.mounts {
+		r.apply(m, item)
+	}
+}
+
+func (r *jsRouter) mount(m routerMount) {
+	r.mounts = append(r.mounts, m)
+	for _, item := range r.items {
+		r.apply(m, item)
+	}
+}
+
+// apply registers an item under a mount of the router
+func (r *jsRouter) apply(m routerMount, item routerItem) {
+	path := joinRoutePath(m.prefix, item.path)
+	middleware := append(append([]goja.Value{}, m.middleware...), r.middleware[:item.middleware]...)
+	if item.router != nil {
+		item.router.mount(routerMount{prefix: path, middleware: append(middleware, item.args...)})
+		return
+	}
+	fns := append(middleware, item.args...)
+	r.e.registerHandler(item.method, path, fns[0], fns[1:]...)
+}
+
+// Get adds a GET route: router.get(path, ...middleware, handler, options)
+func (r *jsRouter) Get(path string, args ...goja.Value) *jsRouter {
+	return r.route(http.MethodGet, path, args)
+}
+
+// Post adds a POST route
+func (r *jsRouter) Post(path string, args ...goja.Value) *jsRouter {
+	return r.route(http.MethodPost, path, args)
+}
+
+// Put adds a PUT route
+func (r *jsRouter) Put(path string, args ...goja.Value) *jsRouter {
+	return r.route(http.MethodPut, path, args)
+}
+
+// Delete adds a DELETE route
+func (r *jsRouter) Delete(path string, args ...goja.Value) *jsRouter {
+	return r.route(http.MethodDelete, path, args)
+}
+
+// Patch adds a PATCH route
+func (r *jsRouter) Patch(path string, args ...goja.Value) *jsRouter {
+	return r.route(http.MethodPatch, path, args)
+}
+
+// All adds a route answering every method, like app.all()
+func (r *jsRouter) All(path string, args ...goja.Value) *jsRouter {
+	return r.route(AllMethods, path, args)
+}
+
+// Use adds middleware for the routes declared after it, or mounts a nested router:
+// router.use(fn, ...), router.use(prefix, ...middleware, router) or router.use(router)
+func (r *jsRouter) Use(args ...goja.Value) *jsRouter {
+	if prefix, middleware, sub, ok := routerUseArgs(args); ok {
+		if sub == r {
+			panic(r.e.rt.NewTypeError("a router cannot be mounted in itself"))
+		}
+		for _, fn := range middleware {
+			if _, ok := goja.AssertFunction(fn); !ok {
+				panic(r.e.rt.NewTypeError("router.use() takes a path, middleware functions and a router"))
+			}
+		}
+		r.add(routerItem{path: prefix, args: middleware, router: sub, middleware: len(r.middleware)})
+		return r
+	}
+	for _, arg := range args {
+		if _, ok := goja.AssertFunction(arg); !ok {
+			panic(r.e.rt.NewTypeError("router.use() takes middleware functions, or a path and a router"))
+		}
+		r.middleware = append(r.middleware, arg)
+	}
+	return r
+}
+
+// routerUseArgs recognizes use([prefix,] ...middleware, router) calls mounting a router
+func routerUseArgs(args []goja.Value) (prefix string, middleware []goja.Value, router *jsRouter, ok bool) {
+	if len(args) == 0 {
+		return "", nil, nil, false
+	}
+	router, ok = args[len(args)-1].Export().(*jsRouter)
+	if !ok {
+		return "", nil, nil, false
+	}
+	args = args[:len(args)-1]
+	if len(args) > 0 {
+		if p, isString := args[0].Export().(string); isString {
+			prefix = p
+			args = args[1:]
+		}
+	}
+	return prefix, args, router, true
+}
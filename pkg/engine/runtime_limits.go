@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dop251/goja"
+)
+
+// RuntimeLimits bounds what a single script can allocate, so a runaway recursion or a huge
+// result cannot exhaust the server's memory. goja has no memory limit, so the string and
+// array limits are heuristics applied to values leaving the runtime: execution results and
+// res.send()/res.json() bodies.
+type RuntimeLimits struct {
+	MaxCallStackSize int // Nested function calls before the script is aborted, 0 for no limit
+	MaxStringLength  int // Length of a string leaving the runtime, 0 for no limit
+	MaxArrayLength   int // Elements of an array leaving the runtime, 0 for no limit
+}
+
+// DefaultRuntimeLimits returns the limits used unless SetRuntimeLimits is called
+func DefaultRuntimeLimits() RuntimeLimits {
+	return RuntimeLimits{
+		MaxCallStackSize: 10000,
+		MaxStringLength:  32 * 1024 * 1024,
+		MaxArrayLength:   1000000,
+	}
+}
+
+// LimitError reports a script that hit one of the runtime limits; it is recorded as the
+// error of the execution
+type LimitError struct {
+	Limit string // Which limit was hit, e.g. "string length"
+	Size  int    // Size of the offending value, 0 if unknown
+	Max   int    // Configured limit
+}
+
+func (err *LimitError) Error() string {
+	if err.Size > 0 {
+		return fmt.Sprintf("runtime limit exceeded: %s %d is over the limit of %d", err.Limit, err.Size, err.Max)
+	}
+	return fmt.Sprintf("runtime limit exceeded: %s is over the limit of %d", err.Limit, err.Max)
+}
+
+// SetRuntimeLimits configures the call stack, string and array limits. It must be called
+// before the dispatcher starts.
+func (e *Engine) SetRuntimeLimits(limits RuntimeLimits) error {
+	if limits.MaxCallStackSize < 0 || limits.MaxStringLength < 0 || limits.MaxArrayLength < 0 {
+		return fmt.Errorf("runtime limits must not be negative")
+	}
+	if e.dispatching {
+		return fmt.Errorf("runtime limits cannot be changed after the dispatcher started")
+	}
+
+	e.mu.Lock()
+	e.runtimeLimits = limits
+	e.mu.Unlock()
+	e.applyRuntimeLimits(e.rt)
+
+	for _, replica := range e.replicas {
+		if err := replica.SetRuntimeLimits(limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyRuntimeLimits sets the call stack limit of a runtime created by the engine
+func (e *Engine) applyRuntimeLimits(rt *goja.Runtime) {
+	e.mu.RLock()
+	size := e.runtimeLimits.MaxCallStackSize
+	e.mu.RUnlock()
+
+	if size == 0 {
+		size = math.MaxInt32
+	}
+	rt.SetMaxCallStackSize(size)
+}
+
+// limitError turns goja's stack overflow, which carries no message, into a LimitError
+func (e *Engine) limitError(err error) error {
+	var overflow *goja.StackOverflowError
+	if !errors.As(err, &overflow) {
+		return err
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return &LimitError{Limit: "call stack size", Max: e.runtimeLimits.MaxCallStackSize}
+}
+
+// checkValueLimits walks a value exported from the runtime and fails on the first string or
+// array longer than the limits
+func (e *Engine) checkValueLimits(value interface{}) error {
+	e.mu.RLock()
+	limits := e.runtimeLimits
+	e.mu.RUnlock()
+
+	return checkValueLimits(value, limits, 0)
+}
+
+// maxLimitCheckDepth stops checkValueLimits from following deeply nested values
+const maxLimitCheckDepth = 64
+
+func checkValueLimits(value interface{}, limits RuntimeLimits, depth int) error {
+	if depth > maxLimitCheckDepth {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		if limits.MaxStringLength > 0 && len(v) > limits.MaxStringLength {
+			return &LimitError{Limit: "string length", Size: len(v), Max: limits.MaxStringLength}
+		}
+	case []byte:
+		if limits.MaxStringLength > 0 && len(v) > limits.MaxStringLength {
+			return &LimitError{Limit: "string length", Size: len(v), Max: limits.MaxStringLength}
+		}
+	case []interface{}:
+		if limits.MaxArrayLength > 0 && len(v) > limits.MaxArrayLength {
+			return &LimitError{Limit: "array length", Size: len(v), Max: limits.MaxArrayLength}
+		}
+		for _, item := range v {
+			if err := checkValueLimits(item, limits, depth+1); err != nil {
+				return err
+			}
+		}
+	case []map[string]interface{}:
+		if limits.MaxArrayLength > 0 && len(v) > limits.MaxArrayLength {
+			return &LimitError{Limit: "array length", Size: len(v), Max: limits.MaxArrayLength}
+		}
+		for _, item := range v {
+			if err := checkValueLimits(item, limits, depth+1); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := checkValueLimits(item, limits, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Runtime pool
+//
+// A goja runtime is single-threaded, so one engine serves one request at a time. With a pool
+// size above 1 the engine (the primary runtime) gets replica runtimes that each run their own
+// dispatcher goroutine and share the database, request logger and HTTP client with it.
+//
+// Code submitted with EvalJob.Replicate (startup scripts) and the bootstrap file run in every
+// runtime, so the routes they register exist everywhere. Routes of that code registered with
+// the replicate: true option are served by the replicas: their requests go to a queue the
+// replicas consume, and the replica that picks one up calls its own copy of the handler.
+// Everything else - other routes, ad-hoc executions, session runtimes and routes registered
+// by them - stays on the primary runtime.
+//
+// There is one globalState, the primary runtime's. Replicas get a scratch globalState while
+// replicated code runs, so startup code initializing the state works, and throw whenever a
+// request reaches globalState. As replicated routes always run on a replica, a replicated
+// route using globalState fails on every request, not depending on the runtime serving it.
+
+// SetRuntimePoolSize configures how many runtimes serve requests concurrently, including the
+// primary one. It must be called before the bootstrap file and scripts are loaded and before
+// the dispatcher starts.
+func (e *Engine) SetRuntimePoolSize(size int) error {
+	if size < 1 {
+		return fmt.Errorf("runtime pool size must be at least 1, got %d", size)
+	}
+	if e.dispatching {
+		return fmt.Errorf("runtime pool size cannot be changed after the dispatcher started")
+	}
+
+	e.replicas = nil
+	e.poolJobs = nil
+	if size == 1 {
+		return nil
+	}
+
+	e.poolJobs = make(chan EvalJob, cap(e.jobs))
+	for i := 1; i < size; i++ {
+		e.replicas = append(e.replicas, e.newReplica())
+	}
+	log.Info().Int("size", size).Msg("Runtime pool configured")
+	return nil
+}
+
+// RuntimePoolSize returns the number of runtimes serving requests
+func (e *Engine) RuntimePoolSize() int {
+	return len(e.replicas) + 1
+}
+
+// newReplica creates a runtime with the same bindings that shares the engine's resources
+func (e *Engine) newReplica() *Engine {
+	replica := &Engine{
+		rt:               newRuntime(e.requireRegistry),
+		repos:            e.repos,
+		jobs:             make(chan EvalJob, cap(e.jobs)),
+		handlers:         make(map[string]map[string]*HandlerInfo),
+		files:            make(map[string]goja.Callable),
+		fileScripts:      make(map[string]string),
+		reqLogger:        e.reqLogger,
+		moduleRegistry:   e.moduleRegistry,
+		httpClient:       e.httpClient,
+		requireRegistry:  e.requireRegistry,
+		dbModule:         e.dbModule,
+		sessions:         make(map[string]*sessionRuntime),
+		env:              e.GetEnvironment(),
+		executionTimeout: e.executionTimeout,
+		runtimeLimits:    e.runtimeLimits,
+		lastGlobalState:  "{}",
+		primary:          e,
+		poolJobs:         e.poolJobs,
+	}
+	replica.applyRuntimeLimits(replica.rt)
+	replica.setupBindings()
+	replica.setupReplicaGlobalState()
+	replica.setupEnvironmentBinding(replica.env)
+	replica.setupDatabaseBindings(replica.dbModule)
+	replica.recordBindings()
+	return replica
+}
+
+// setupReplicaGlobalState replaces the globalState of a replica with one that only exists
+// while replicated code runs
+func (e *Engine) setupReplicaGlobalState() {
+	scratch := e.rt.NewObject()
+	getter := e.rt.ToValue(func(goja.FunctionCall) goja.Value {
+		if !e.replicating {
+			panic(e.rt.NewTypeError("globalState is not available in routes registered with replicate: true, which run in the pool runtimes"))
+		}
+		return scratch
+	})
+	setter := e.rt.ToValue(func(call goja.FunctionCall) goja.Value {
+		if !e.replicating {
+			panic(e.rt.NewTypeError("globalState is only available in the primary runtime"))
+		}
+		if obj, ok := call.Argument(0).(*goja.Object); ok {
+			scratch = obj
+		}
+		return goja.Undefined()
+	})
+	global := e.rt.GlobalObject()
+	_ = global.Delete("globalState")
+	if err := global.DefineAccessorProperty("globalState", getter, setter, goja.FLAG_TRUE, goja.FLAG_TRUE); err != nil {
+		log.Error().Err(err).Msg("Failed to set up globalState of pool runtime")
+	}
+}
+
+// runReplicated executes code in every runtime of the pool, so routes registered by it can
+// be served by any runtime. Only the error of the primary runtime is returned.
+func (e *Engine) runReplicated(code string) error {
+	e.replicating = true
+	err := e.executeCode(code)
+	e.replicating = false
+
+	for _, replica := range e.replicas {
+		replica.replicating = true
+		if err := replica.executeCode(code); err != nil {
+			log.Warn().Err(err).Msg("Replicated code failed in pool runtime")
+		}
+		replica.replicating = false
+	}
+	return err
+}
+
+// replicateJob queues the code of a replicated job on every replica. Nobody waits for the
+// replicas, and they neither store the execution nor capture its result.
+func (e *Engine) replicateJob(job EvalJob) {
+	for _, replica := range e.replicas {
+		replica.jobs <- EvalJob{
+			Code:      job.Code,
+			Source:    job.Source,
+			Actor:     job.Actor,
+			Replicate: true,
+			script:    job.script,
+		}
+	}
+}
+
+// localHandler returns this runtime's copy of a handler registered in every runtime
+func (e *Engine) localHandler(handler *HandlerInfo) (*HandlerInfo, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	local, ok := e.handlers[handler.Doc.Path][handler.Doc.Method]
+	return local, ok && local.replicated
+}
+
+// processPoolJob serves a request taken from the shared queue with this replica's copy of
+// the handler, or hands it to the primary runtime if this replica does not have one
+func (e *Engine) processPoolJob(job EvalJob) {
+	if e.primary != nil {
+		local, ok := e.localHandler(job.Handler)
+		if !ok {
+			e.primary.jobs <- job
+			return
+		}
+		job.Handler = local
+	}
+	e.processJob(job)
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/rs/zerolog/log"
+)
+
+// HTML sanitization
+//
+// sanitizeHTML(str, [policy]) removes the markup of user content that could run script, so
+// apps can echo comments, profiles or rich text without opening an XSS hole:
+//
+//	sanitizeHTML('<b>hi</b><script>alert(1)</script>')            // '<b>hi</b>'
+//	sanitizeHTML('<b>hi</b>', 'strict')                            // 'hi'
+//	sanitizeHTML(bio, { elements: ['b', 'i', 'a'], attributes: { a: ['href'] } })
+//
+// Named policies are 'ugc' (the default: formatting, links, images and tables of user
+// generated content) and 'strict' (no markup at all, text only). An options object starts
+// from its base policy, 'strict' unless given, and allows more elements, attributes
+// (per element, or '*' for all) and URL schemes of links. With SetSanitizeHTML, res.send()
+// sanitizes the HTML strings it sends with a named policy as well.
+
+// Named sanitization policies
+const (
+	SanitizeUGC    = "ugc"    // Formatting, links, images and tables of user generated content
+	SanitizeStrict = "strict" // Text only
+)
+
+// maxCustomPolicies bounds the policies built from options objects that are kept for reuse
+const maxCustomPolicies = 64
+
+var (
+	namedPolicies = map[string]*bluemonday.Policy{
+		SanitizeUGC:    bluemonday.UGCPolicy(),
+		SanitizeStrict: bluemonday.StrictPolicy(),
+	}
+
+	customPoliciesMu sync.Mutex
+	customPolicies   = map[string]*bluemonday.Policy{} // Policies of options objects by their JSON
+)
+
+// sanitizeOptions is the options object of sanitizeHTML()
+type sanitizeOptions struct {
+	Base       string              `json:"base"`
+	Elements   []string            `json:"elements"`
+	Attributes map[string][]string `json:"attributes"`
+	URLSchemes []string            `json:"urlSchemes"`
+}
+
+// setupSanitizeBindings installs sanitizeHTML
+func (e *Engine) setupSanitizeBindings() {
+	if err := e.rt.Set("sanitizeHTML", e.sanitizeHTML); err != nil {
+		log.Error().Err(err).Msg("Failed to set sanitizeHTML binding")
+	}
+}
+
+// sanitizeHTML implements sanitizeHTML(str, [policy])
+func (e *Engine) sanitizeHTML(call goja.FunctionCall) goja.Value {
+	policy, err := sanitizePolicy(call.Argument(1).Export())
+	if err != nil {
+		panic(e.rt.NewTypeError(err.Error()))
+	}
+	input := call.Argument(0)
+	if goja.IsUndefined(input) || goja.IsNull(input) {
+		return e.rt.ToValue("")
+	}
+	return e.rt.ToValue(policy.Sanitize(input.String()))
+}
+
+// sanitizePolicy returns the policy named by a string or described by an options object
+func sanitizePolicy(spec interface{}) (*bluemonday.Policy, error) {
+	switch v := spec.(type) {
+	case nil:
+		return namedPolicies[SanitizeUGC], nil
+	case string:
+		policy, ok := namedPolicies[v]
+		if !ok {
+			return nil, fmt.Errorf("sanitizeHTML(): unknown policy %q, use 'ugc', 'strict' or an options object", v)
+		}
+		return policy, nil
+	case map[string]interface{}:
+		return customSanitizePolicy(v)
+	}
+	return nil, fmt.Errorf("sanitizeHTML(): the policy must be 'ugc', 'strict' or an options object, got %T", spec)
+}
+
+// customSanitizePolicy builds the policy of an options object, reusing the one built for the
+// same options before
+func customSanitizePolicy(spec map[string]interface{}) (*bluemonday.Policy, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("sanitizeHTML(): invalid options: %w", err)
+	}
+	key := string(data)
+
+	customPoliciesMu.Lock()
+	defer customPoliciesMu.Unlock()
+	if policy, ok := customPolicies[key]; ok {
+		return policy, nil
+	}
+
+	var opts sanitizeOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return nil, fmt.Errorf("sanitizeHTML(): invalid options: %w", err)
+	}
+	var policy *bluemonday.Policy
+	switch opts.Base {
+	case "", SanitizeStrict:
+		policy = bluemonday.StrictPolicy()
+	case SanitizeUGC:
+		policy = bluemonday.UGCPolicy()
+	default:
+		return nil, fmt.Errorf("sanitizeHTML(): base must be 'ugc' or 'strict', got %q", opts.Base)
+	}
+	if len(opts.Elements) > 0 {
+		policy.AllowElements(opts.Elements...)
+	}
+	elements := make([]string, 0, len(opts.Attributes))
+	for element := range opts.Attributes {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	for _, element := range elements {
+		attrs := policy.AllowAttrs(opts.Attributes[element]...)
+		if element == "*" {
+			attrs.Globally()
+		} else {
+			attrs.OnElements(strings.ToLower(element))
+		}
+	}
+	if len(opts.URLSchemes) > 0 {
+		policy.AllowURLSchemes(opts.URLSchemes...)
+	} else if opts.Base == "" || opts.Base == SanitizeStrict {
+		policy.AllowStandardURLs()
+	}
+
+	if len(customPolicies) < maxCustomPolicies {
+		customPolicies[key] = policy
+	}
+	return policy, nil
+}
+
+// SetSanitizeHTML makes res.send() sanitize the HTML strings it sends with a named policy,
+// "" to send them as they are
+func (e *Engine) SetSanitizeHTML(policy string) error {
+	if policy != "" {
+		if _, ok := namedPolicies[policy]; !ok {
+			return fmt.Errorf("unknown HTML sanitization policy %q, use %s or %s", policy, SanitizeUGC, SanitizeStrict)
+		}
+	}
+	root := e.root()
+	root.mu.Lock()
+	root.htmlPolicy = policy
+	root.mu.Unlock()
+	return nil
+}
+
+// SanitizeHTML returns the policy res.send() sanitizes HTML with, "" for none
+func (e *Engine) SanitizeHTML() string {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.htmlPolicy
+}
+
+// sanitizeSentHTML applies the policy set with SetSanitizeHTML to an HTML response body
+func (e *Engine) sanitizeSentHTML(body string) string {
+	policy := e.SanitizeHTML()
+	if policy == "" {
+		return body
+	}
+	return namedPolicies[policy].Sanitize(body)
+}
@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/typescript"
+	"github.com/rs/zerolog/log"
+)
+
+// scriptLoadTimeout is how long LoadScripts waits for one file to run
+const scriptLoadTimeout = 10 * time.Second
+
+// LoadScripts runs the JavaScript and TypeScript files of a directory in every runtime of
+// the pool. TypeScript files have their type syntax stripped before they run. A file that
+// fails is logged and skipped; only an unreadable directory is returned as error. The
+// dispatcher must be running. The directory becomes the one ScriptsDir reports.
+func (e *Engine) LoadScripts(dir string) error {
+	log.Info().Str("directory", dir).Msg("Loading JavaScript files")
+
+	if abs, err := filepath.Abs(dir); err == nil {
+		root := e.root()
+		root.mu.Lock()
+		root.scriptsDir = abs
+		root.mu.Unlock()
+	}
+
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("Error accessing file")
+			return err
+		}
+
+		if !info.IsDir() && IsScriptFile(path) {
+			if err := e.LoadScriptFile(path); err != nil {
+				log.Error().Err(err).Str("file", path).Msg("Failed to execute file")
+			} else {
+				log.Info().Str("file", path).Msg("Successfully loaded JavaScript file")
+			}
+		}
+
+		return nil
+	})
+}
+
+// LoadScriptsFS runs the JavaScript and TypeScript files of a file system, such as the
+// embed.FS of a binary bundling its scripts, like LoadScripts does for a directory. The
+// scripts are identified as embedded:/path; ScriptsDir stays empty, as they cannot be edited.
+func (e *Engine) LoadScriptsFS(fsys fs.FS) error {
+	log.Info().Msg("Loading embedded JavaScript files")
+
+	return fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("Error accessing embedded file")
+			return err
+		}
+		if d.IsDir() || !IsScriptFile(path) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, path)
+		if err == nil {
+			err = e.runScript(path, "embedded:/"+path, data)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("Failed to execute embedded file")
+		} else {
+			log.Info().Str("file", path).Msg("Successfully loaded embedded JavaScript file")
+		}
+		return nil
+	})
+}
+
+// ScriptsDir returns the absolute path of the directory LoadScripts loaded, "" if none
+func (e *Engine) ScriptsDir() string {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.scriptsDir
+}
+
+// IsScriptFile reports whether LoadScripts runs a file: JavaScript or TypeScript
+func IsScriptFile(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".js") || typescript.IsTypeScript(path)
+}
+
+// LoadScriptFile runs a JavaScript or TypeScript file in every runtime of the pool, like
+// LoadScripts does for each file of its directory, and waits for it
+func (e *Engine) LoadScriptFile(path string) error {
+	log.Info().Str("file", path).Msg("Loading JavaScript file")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	log.Debug().Str("file", path).Int("bytes", len(data)).Msg("Read JavaScript file")
+	return e.runScript(path, scriptKey(path), data)
+}
+
+// runScript runs the code of a script file in every runtime of the pool, registering its
+// routes under script, and waits for it
+func (e *Engine) runScript(path, script string, data []byte) error {
+	var err error
+	code := string(data)
+	if typescript.IsTypeScript(path) {
+		if code, err = typescript.Strip(code); err != nil {
+			return fmt.Errorf("failed to strip TypeScript types: %w", err)
+		}
+	}
+
+	// Submit to engine with timeout
+	done := make(chan error, 1)
+	job := EvalJob{
+		Code:      code,
+		Done:      done,
+		SessionID: "startup-" + filepath.Base(path),
+		Source:    repository.SourceFile,
+		Replicate: true,
+		script:    script,
+	}
+
+	log.Debug().Str("file", path).Msg("Submitting job to engine")
+	e.SubmitJob(job)
+
+	// Wait for completion with timeout
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(scriptLoadTimeout):
+		return fmt.Errorf("timeout waiting for file execution")
+	}
+}
+
+// scriptKey identifies a script file on the routes it registers
+func scriptKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// UnloadScriptFile removes the routes, file handlers and error middleware a script file
+// registered when it was loaded, in every runtime of the pool, and returns how many the
+// primary runtime had. A route the file took over from an earlier definition is gone
+// afterwards, not restored.
+func (e *Engine) UnloadScriptFile(path string) int {
+	script := scriptKey(path)
+	root := e.root()
+	routes := root.scriptRoutes(script)
+	removed := 0
+	for _, runtime := range root.runtimes() {
+		n := runtime.unregisterScript(script)
+		if runtime == root {
+			removed = n
+		}
+	}
+	log.Info().Str("file", path).Int("routes", removed).Msg("Unloaded JavaScript file")
+	for _, route := range routes {
+		root.emit(Event{Type: EventRouteRemoved, Method: route[0], Path: route[1]})
+	}
+	return removed
+}
+
+// scriptRoutes returns the method and path of the routes and file handlers a script file
+// registered in this runtime, ordered by path
+func (e *Engine) scriptRoutes(script string) [][2]string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var routes [][2]string
+	for path, methods := range e.handlers {
+		for method, handler := range methods {
+			if handler.script == script {
+				routes = append(routes, [2]string{method, path})
+			}
+		}
+	}
+	for path, owner := range e.fileScripts {
+		if owner == script {
+			routes = append(routes, [2]string{"FILE", path})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i][1] != routes[j][1] {
+			return routes[i][1] < routes[j][1]
+		}
+		return routes[i][0] < routes[j][0]
+	})
+	return routes
+}
+
+// unregisterScript removes the handlers and error middleware of this runtime registered by
+// a script file
+func (e *Engine) unregisterScript(script string) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	removed := 0
+	for path, methods := range e.handlers {
+		for method, handler := range methods {
+			if handler.script == script {
+				delete(methods, method)
+				removed++
+			}
+		}
+		if len(methods) == 0 {
+			delete(e.handlers, path)
+			e.removeRoutePattern(path)
+		}
+	}
+	handlers := e.errorHandlers[:0]
+	for _, handler := range e.errorHandlers {
+		if handler.script == script {
+			removed++
+			continue
+		}
+		handlers = append(handlers, handler)
+	}
+	e.errorHandlers = handlers
+	for path, owner := range e.fileScripts {
+		if owner == script {
+			delete(e.files, path)
+			delete(e.fileScripts, path)
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Secrets
+//
+// secrets.get() reads API keys, passwords and tokens from the sources configured at serve
+// time (jesus serve --secrets-env-prefix, --secrets-file, --secrets-profile-section), so
+// scripts never read the process environment and only see the secrets they are given:
+//
+//	const key = secrets.get('OPENAI_API_KEY');
+//	const password = secrets.get('SMTP_PASSWORD', '');
+//
+// Values read from secrets are replaced with [REDACTED:NAME] in console output and in the
+// stored result and error of executions, so they do not end up in the execution log.
+
+// minRedactedSecretLength is the length below which values are not redacted, since short
+// values like "1" or "yes" would redact unrelated output
+const minRedactedSecretLength = 4
+
+// SecretSources configures where secrets come from. A name found in several sources takes
+// the value of the last one: profile, then file, then environment.
+type SecretSources struct {
+	EnvPrefix string            // Environment variables with this prefix, APP_SECRET_DB_PASSWORD for DB_PASSWORD
+	File      string            // YAML, JSON or .env file mapping names to values
+	Profile   map[string]string // Secrets of the section of the selected profile
+}
+
+// LoadSecrets reads the secrets of the configured sources
+func LoadSecrets(sources SecretSources) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for name, value := range sources.Profile {
+		secrets[name] = value
+	}
+
+	if sources.File != "" {
+		fileSecrets, err := readSecretsFile(sources.File)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range fileSecrets {
+			secrets[name] = value
+		}
+	}
+
+	if sources.EnvPrefix != "" {
+		for _, entry := range os.Environ() {
+			name, value, ok := strings.Cut(entry, "=")
+			if ok && strings.HasPrefix(name, sources.EnvPrefix) && len(name) > len(sources.EnvPrefix) {
+				secrets[strings.TrimPrefix(name, sources.EnvPrefix)] = value
+			}
+		}
+	}
+
+	return secrets, nil
+}
+
+// readSecretsFile reads a secrets file: YAML or JSON for .yaml, .yml and .json files, else
+// NAME=value lines as in .env files
+func readSecretsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse secrets file %s: %w", path, err)
+		}
+		secrets := make(map[string]string, len(raw))
+		for name, value := range raw {
+			switch v := value.(type) {
+			case string:
+				secrets[name] = v
+			case int, float64, bool:
+				secrets[name] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("secret %s in %s must be a string, got %T", name, path, value)
+			}
+		}
+		return secrets, nil
+	}
+
+	secrets := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("%s:%d: expected NAME=value", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			if value[0] == '"' {
+				if unquoted, err := strconv.Unquote(value); err == nil {
+					value = unquoted
+				} else {
+					value = value[1 : len(value)-1]
+				}
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		secrets[strings.TrimSpace(name)] = value
+	}
+	return secrets, scanner.Err()
+}
+
+// SetSecrets sets the secrets of secrets.get() and the values redacted from execution output
+func (e *Engine) SetSecrets(secrets map[string]string) {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	// Longer values first, so a secret containing another is redacted as a whole
+	sort.Slice(names, func(i, j int) bool {
+		if len(secrets[names[i]]) != len(secrets[names[j]]) {
+			return len(secrets[names[i]]) > len(secrets[names[j]])
+		}
+		return names[i] < names[j]
+	})
+
+	var pairs []string
+	for _, name := range names {
+		if len(secrets[name]) >= minRedactedSecretLength {
+			pairs = append(pairs, secrets[name], "[REDACTED:"+name+"]")
+		}
+	}
+	var redactor *strings.Replacer
+	if len(pairs) > 0 {
+		redactor = strings.NewReplacer(pairs...)
+	}
+
+	root := e.root()
+	root.mu.Lock()
+	root.secrets = secrets
+	root.secretRedactor = redactor
+	root.mu.Unlock()
+
+	log.Info().Int("secrets", len(secrets)).Msg("Engine secrets configured")
+}
+
+// setupSecretsBindings installs secrets
+func (e *Engine) setupSecretsBindings() {
+	if err := e.rt.Set("secrets", map[string]interface{}{
+		"get":   e.secretsGet,
+		"has":   e.secretsHas,
+		"names": e.secretsNames,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set secrets binding")
+	}
+}
+
+// secret returns the value of a secret
+func (e *Engine) secret(name string) (string, bool) {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	value, ok := root.secrets[name]
+	return value, ok
+}
+
+// secretsGet implements secrets.get(name, [default]): the value of the secret, else the
+// default, else undefined
+func (e *Engine) secretsGet(call goja.FunctionCall) goja.Value {
+	name := call.Argument(0)
+	if goja.IsUndefined(name) || goja.IsNull(name) {
+		panic(e.rt.NewTypeError("secrets.get() takes the name of a secret"))
+	}
+	if value, ok := e.secret(name.String()); ok {
+		return e.rt.ToValue(value)
+	}
+	return call.Argument(1)
+}
+
+// secretsHas implements secrets.has(name)
+func (e *Engine) secretsHas(name string) bool {
+	_, ok := e.secret(name)
+	return ok
+}
+
+// secretsNames implements secrets.names(): the names of the secrets, without their values
+func (e *Engine) secretsNames() []string {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	names := make([]string, 0, len(root.secrets))
+	for name := range root.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// secretsRedactor returns the replacer redacting the values of secrets, nil without secrets
+func (e *Engine) secretsRedactor() *strings.Replacer {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.secretRedactor
+}
+
+// redactSecrets replaces the values of secrets in text with their names
+func (e *Engine) redactSecrets(text string) string {
+	if redactor := e.secretsRedactor(); redactor != nil {
+		return redactor.Replace(text)
+	}
+	return text
+}
+
+// redactArgs redacts the values of secrets from console arguments. Arguments containing a
+// secret are replaced by their redacted text; the others are kept as they are.
+func (e *Engine) redactArgs(args []interface{}) []interface{} {
+	redactor := e.secretsRedactor()
+	if redactor == nil {
+		return args
+	}
+	var redacted []interface{}
+	for i, arg := range args {
+		text := fmt.Sprint(arg)
+		if clean := redactor.Replace(text); clean != text {
+			if redacted == nil {
+				redacted = append([]interface{}{}, args...)
+			}
+			redacted[i] = clean
+		}
+	}
+	if redacted == nil {
+		return args
+	}
+	return redacted
+}
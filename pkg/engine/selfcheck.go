@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+)
+
+// Self-check statuses
+const (
+	SelfCheckOK   = "ok"
+	SelfCheckWarn = "warn"
+	SelfCheckFail = "fail"
+)
+
+// SelfCheckResult is the outcome of one startup check
+type SelfCheckResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, warn or fail
+	Message string `json:"message"`
+}
+
+// selfCheckScript exercises the bindings a playground depends on. It is called with the URL
+// of the JavaScript web server for the fetch loopback check, or "" to skip it.
+const selfCheckScript = `(function (loopbackURL) {
+	const results = [];
+	const check = (name, fn) => {
+		try {
+			const outcome = fn();
+			results.push(typeof outcome === 'object' ? Object.assign({ name }, outcome) : { name, status: 'ok', message: outcome || '' });
+		} catch (e) {
+			results.push({ name, status: 'fail', message: String(e && e.message || e) });
+		}
+	};
+
+	check('console', () => {
+		console.debug('self-check: console works');
+		return 'console output is captured';
+	});
+
+	check('db', () => {
+		const rows = db.query('SELECT 1 AS one');
+		if (!rows || rows.length !== 1 || rows[0].one !== 1) {
+			throw new Error('SELECT 1 returned ' + JSON.stringify(rows));
+		}
+		return 'application database answers queries';
+	});
+
+	check('globalState', () => {
+		if (typeof globalState !== 'object' || globalState === null) {
+			throw new Error('globalState is ' + typeof globalState);
+		}
+		JSON.stringify(globalState);
+		return 'globalState is a serializable object';
+	});
+
+	check('fetch', () => {
+		if (!loopbackURL) {
+			return { status: 'warn', message: 'skipped, no loopback URL' };
+		}
+		const res = fetch(loopbackURL);
+		if (!res.ok) {
+			throw new Error('GET ' + loopbackURL + ' failed: ' + (res.error || 'status ' + res.status));
+		}
+		return 'GET ' + loopbackURL + ' returned ' + res.status;
+	});
+
+	check('ai', () => {
+		const available = ['ai', 'Conversation', 'ChatStepFactory', 'embeddings'].filter(name => typeof globalThis[name] !== 'undefined');
+		if (available.length === 0) {
+			return { status: 'warn', message: 'no AI bindings are configured, AI calls will fail' };
+		}
+		return 'available: ' + available.join(', ');
+	});
+
+	return results;
+})`
+
+// SelfCheck runs the startup verification script on the dispatcher and returns one result
+// per binding. loopbackURL is fetched to verify outgoing HTTP; pass "" to skip that check.
+func (e *Engine) SelfCheck(loopbackURL string) ([]SelfCheckResult, error) {
+	urlJSON, err := json.Marshal(loopbackURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode loopback URL: %w", err)
+	}
+
+	done := make(chan error, 1)
+	results := make(chan *EvalResult, 1)
+	e.SubmitJob(EvalJob{
+		Code:    fmt.Sprintf("%s(%s)", selfCheckScript, urlJSON),
+		Done:    done,
+		Result:  results,
+		Source:  repository.SourceSelfCheck,
+		Timeout: 30 * time.Second,
+	})
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("self-check script failed: %w", err)
+	}
+	result := <-results
+
+	data, err := json.Marshal(result.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode self-check results: %w", err)
+	}
+	var checks []SelfCheckResult
+	if err := json.Unmarshal(data, &checks); err != nil {
+		return nil, fmt.Errorf("failed to decode self-check results: %w", err)
+	}
+	return checks, nil
+}
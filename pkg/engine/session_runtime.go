@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/require"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// sessionRuntimeTTL is how long an idle session runtime is kept before it is dropped
+	sessionRuntimeTTL = 30 * time.Minute
+	// maxSessionRuntimes caps the live session runtimes; the least recently used one is dropped
+	maxSessionRuntimes = 32
+)
+
+// sessionRuntime is the dedicated runtime of a stateful /v1/execute session. Variables and
+// functions declared by one execution stay visible to the next executions of the session.
+type sessionRuntime struct {
+	rt         *goja.Runtime
+	template   string // Execution template the runtime was seeded from, if any
+	created    time.Time
+	lastUsed   time.Time
+	executions int
+}
+
+// SessionInfo describes a live session runtime
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	Template   string    `json:"template,omitempty"`
+	Created    time.Time `json:"created"`
+	LastUsed   time.Time `json:"lastUsed"`
+	Executions int       `json:"executions"`
+}
+
+// newRuntime creates a goja runtime with require() and JavaScript-style field names
+func newRuntime(registry *require.Registry) *goja.Runtime {
+	rt := goja.New()
+	registry.Enable(rt)
+	rt.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
+	return rt
+}
+
+// Sessions lists the live session runtimes, most recently used first
+func (e *Engine) Sessions() []SessionInfo {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	sessions := make([]SessionInfo, 0, len(e.sessions))
+	for id, s := range e.sessions {
+		sessions = append(sessions, SessionInfo{ID: id, Template: s.template, Created: s.created, LastUsed: s.lastUsed, Executions: s.executions})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsed.After(sessions[j].LastUsed)
+	})
+	return sessions
+}
+
+// CloseSession drops the runtime of a session; it reports whether the session existed
+func (e *Engine) CloseSession(id string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.sessions[id]; !ok {
+		return false
+	}
+	delete(e.sessions, id)
+	log.Info().Str("sessionID", id).Msg("Closed session runtime")
+	return true
+}
+
+// enterSession makes the runtime of the session the current runtime, creating it on first use,
+// and copies globalState into it. The returned function copies globalState back and switches
+// to the shared runtime again; it must be called when the execution ends.
+func (e *Engine) enterSession(id string) func() {
+	session := e.sessionRuntime(id)
+	shared := e.rt
+
+	if err := copyGlobalState(shared, session.rt); err != nil {
+		log.Warn().Err(err).Str("sessionID", id).Msg("Failed to copy globalState into session runtime")
+	}
+	e.switchRuntime(session.rt)
+	e.activeSession = id
+
+	return func() {
+		e.activeSession = ""
+		e.switchRuntime(shared)
+		if err := copyGlobalState(session.rt, shared); err != nil {
+			log.Warn().Err(err).Str("sessionID", id).Msg("Failed to copy globalState out of session runtime")
+		}
+
+		e.mu.Lock()
+		session.lastUsed = time.Now()
+		session.executions++
+		e.mu.Unlock()
+	}
+}
+
+// sessionRuntime returns the runtime of a session, creating and binding a new one if needed
+func (e *Engine) sessionRuntime(id string) *sessionRuntime {
+	e.mu.Lock()
+	e.pruneSessions(time.Now())
+	session, ok := e.sessions[id]
+	e.mu.Unlock()
+	if ok {
+		return session
+	}
+
+	// Without a template, creating the runtime cannot fail
+	session, _ = e.createSessionRuntime(id, nil)
+	return session
+}
+
+// enterTemplateSession is enterSession for a session seeded from an execution template. A
+// session keeps the template it was created with.
+func (e *Engine) enterTemplateSession(id string, template *ExecutionTemplate) (func(), error) {
+	e.mu.Lock()
+	e.pruneSessions(time.Now())
+	session, ok := e.sessions[id]
+	e.mu.Unlock()
+
+	if ok && session.template != template.Name {
+		return nil, fmt.Errorf("session %s was not created with template %s", id, template.Name)
+	}
+	if !ok {
+		if _, err := e.createSessionRuntime(id, template); err != nil {
+			return nil, err
+		}
+	}
+	return e.enterSession(id), nil
+}
+
+// createSessionRuntime creates and binds the runtime of a session, seeded from template if
+// not nil
+func (e *Engine) createSessionRuntime(id string, template *ExecutionTemplate) (*sessionRuntime, error) {
+	rt := newRuntime(e.requireRegistry)
+	e.applyRuntimeLimits(rt)
+	shared := e.rt
+	e.switchRuntime(rt)
+	e.setupBindings()
+	e.setupEnvironmentBinding(e.GetEnvironment())
+	e.setupDatabaseBindings(e.dbModule)
+	var err error
+	if template != nil {
+		err = applyTemplate(rt, template)
+	}
+	e.switchRuntime(shared)
+	if err != nil {
+		log.Error().Err(err).Str("sessionID", id).Msg("Failed to seed session runtime from template")
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &sessionRuntime{rt: rt, created: now, lastUsed: now}
+	if template != nil {
+		session.template = template.Name
+	}
+
+	e.mu.Lock()
+	e.sessions[id] = session
+	e.mu.Unlock()
+
+	log.Info().Str("sessionID", id).Str("template", session.template).Msg("Created session runtime")
+	return session, nil
+}
+
+// pruneSessions drops expired session runtimes and the least recently used ones above the
+// limit, leaving room for one more. The caller must hold e.mu.
+func (e *Engine) pruneSessions(now time.Time) {
+	for id, s := range e.sessions {
+		if now.Sub(s.lastUsed) > sessionRuntimeTTL {
+			delete(e.sessions, id)
+			log.Info().Str("sessionID", id).Msg("Dropped idle session runtime")
+		}
+	}
+
+	for len(e.sessions) >= maxSessionRuntimes {
+		oldestID := ""
+		for id, s := range e.sessions {
+			if oldestID == "" || s.lastUsed.Before(e.sessions[oldestID].lastUsed) {
+				oldestID = id
+			}
+		}
+		delete(e.sessions, oldestID)
+		log.Info().Str("sessionID", oldestID).Msg("Dropped least recently used session runtime")
+	}
+}
+
+// switchRuntime replaces the runtime the bindings operate on. Only the dispatcher calls it.
+func (e *Engine) switchRuntime(rt *goja.Runtime) {
+	e.mu.Lock()
+	e.rt = rt
+	e.mu.Unlock()
+}
+
+// copyGlobalState copies globalState from one runtime to another through JSON. The target
+// object is updated in place so handlers holding a reference to it see the changes.
+func copyGlobalState(from, to *goja.Runtime) error {
+	value := from.Get("globalState")
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil
+	}
+
+	data, err := json.Marshal(value.Export())
+	if err != nil {
+		return fmt.Errorf("globalState is not serializable: %w", err)
+	}
+
+	_, err = to.RunString(`(function (next) {
+		if (typeof globalState !== 'object' || globalState === null || typeof next !== 'object' || next === null) {
+			globalState = next;
+			return;
+		}
+		for (const key of Object.keys(globalState)) {
+			if (!(key in next)) {
+				delete globalState[key];
+			}
+		}
+		Object.assign(globalState, next);
+	})(` + string(data) + `)`)
+	return err
+}
@@ -0,0 +1,99 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// Signed cookies
+//
+// With cookie secrets configured (jesus serve --cookie-secrets), res.cookie() signs a value
+// with HMAC-SHA256 when given { signed: true }, and the request lists verified signed
+// cookies in req.signedCookies instead of req.cookies:
+//
+//	res.cookie('user', 'alice', { signed: true, httpOnly: true });
+//	app.get('/me', (req, res) => res.json({ user: req.signedCookies.user }));
+//
+// A signed cookie whose signature does not match is false in req.signedCookies, so a
+// tampered value is never mistaken for a missing one. The first secret signs; all of them
+// verify, so a secret can be rotated without logging everyone out.
+
+// signedCookiePrefix marks a signed cookie value, as Express' cookie-parser does
+const signedCookiePrefix = "s:"
+
+// SetCookieSecrets sets the secrets of signed cookies, the first one signing new cookies
+func (e *Engine) SetCookieSecrets(secrets []string) {
+	var keys [][]byte
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			keys = append(keys, []byte(secret))
+		}
+	}
+
+	root := e.root()
+	root.mu.Lock()
+	root.cookieSecrets = keys
+	root.mu.Unlock()
+}
+
+func (e *Engine) cookieKeys() [][]byte {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.cookieSecrets
+}
+
+// cookieSignature is the base64url HMAC-SHA256 of a value
+func cookieSignature(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signCookie returns the signed form of a cookie value; ok is false without a secret
+func (e *Engine) signCookie(value string) (string, bool) {
+	keys := e.cookieKeys()
+	if len(keys) == 0 {
+		return "", false
+	}
+	return signedCookiePrefix + value + "." + cookieSignature(keys[0], value), true
+}
+
+// unsignCookie checks a signed cookie value against every secret and returns the value
+func (e *Engine) unsignCookie(signed string) (string, bool) {
+	signed = strings.TrimPrefix(signed, signedCookiePrefix)
+	dot := strings.LastIndexByte(signed, '.')
+	if dot < 0 {
+		return "", false
+	}
+	value, signature := signed[:dot], signed[dot+1:]
+	for _, key := range e.cookieKeys() {
+		if hmac.Equal([]byte(signature), []byte(cookieSignature(key, value))) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// splitSignedCookies moves the signed cookies of a request out of its cookies: verified
+// ones keep their value, tampered ones become false. Without a secret nothing is signed.
+func (e *Engine) splitSignedCookies(cookies map[string]string) map[string]interface{} {
+	signed := make(map[string]interface{})
+	if len(e.cookieKeys()) == 0 {
+		return signed
+	}
+	for name, raw := range cookies {
+		if !strings.HasPrefix(raw, signedCookiePrefix) {
+			continue
+		}
+		delete(cookies, name)
+		if value, ok := e.unsignCookie(raw); ok {
+			signed[name] = value
+		} else {
+			signed[name] = false
+		}
+	}
+	return signed
+}
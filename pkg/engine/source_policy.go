@@ -0,0 +1,123 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Source policies
+//
+// Each execution source can be given its own policy, restricting what code from it may do.
+// Policies are read from a YAML file mapping sources to restrictions (jesus serve
+// --source-policies, also accepted by mcp start):
+//
+//	mcp:
+//	  denyDbWrites: true   # MCP may register routes but not call db.exec
+//	api:
+//	  denyRoutes: true
+//	  timeout: 10s
+//	playground:
+//	  denyNetwork: true    # No fetch(), HTTP.* or graphql requests
+//
+// The policy of a source applies while its code runs, on top of the policy a job brings
+// along, like the one of mcp start. Routes and file handlers keep the deny rules of the code
+// that registered them, so MCP code cannot reach db.exec through a route it registers. Timer
+// callbacks run under the policy of the scheduler source; promise continuations of executed
+// code under that of its source. Work a handler does after its first await is not restricted.
+
+// policySources are the sources a policy can be configured for
+var policySources = []string{
+	repository.SourceAPI,
+	repository.SourceREPL,
+	repository.SourcePlayground,
+	repository.SourceMCP,
+	repository.SourceMCPFile,
+	repository.SourceFile,
+	repository.SourceScheduler,
+	repository.SourceWebhook,
+	repository.SourceReplay,
+	repository.SourceNotebook,
+	repository.SourceSync,
+}
+
+// LoadSourcePolicies reads the policies of execution sources from a YAML file
+func LoadSourcePolicies(path string) (map[string]ExecutionPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source policies: %w", err)
+	}
+
+	var policies map[string]ExecutionPolicy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policies); err != nil {
+		return nil, fmt.Errorf("failed to parse source policies: %w", err)
+	}
+	return policies, nil
+}
+
+// SetSourcePolicies sets the policies of execution sources, replacing the previous ones.
+// Sources must be among the repository.Source* constants code is executed with.
+func (e *Engine) SetSourcePolicies(policies map[string]ExecutionPolicy) error {
+	for source := range policies {
+		if !isPolicySource(source) {
+			return fmt.Errorf("unknown execution source %q, use one of %s", source, strings.Join(policySources, ", "))
+		}
+	}
+
+	e.mu.Lock()
+	e.sourcePolicies = policies
+	e.mu.Unlock()
+
+	sources := make([]string, 0, len(policies))
+	for source := range policies {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	log.Info().Strs("sources", sources).Msg("Configured execution source policies")
+	return nil
+}
+
+// SourcePolicies returns the policies of the execution sources
+func (e *Engine) SourcePolicies() map[string]ExecutionPolicy {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	policies := make(map[string]ExecutionPolicy, len(root.sourcePolicies))
+	for source, policy := range root.sourcePolicies {
+		policies[source] = policy
+	}
+	return policies
+}
+
+// jobPolicy returns the policy a job runs under: its own, restricted by that of its source
+func (e *Engine) jobPolicy(job EvalJob) ExecutionPolicy {
+	root := e.root()
+	root.mu.RLock()
+	source := root.sourcePolicies[job.Source]
+	root.mu.RUnlock()
+	return job.Policy.Restrict(source)
+}
+
+// handlerPolicy returns the deny rules handlers registered under the policy keep; handlers
+// have their own timeouts
+func (p ExecutionPolicy) handlerPolicy() ExecutionPolicy {
+	p.Timeout = 0
+	return p
+}
+
+func isPolicySource(source string) bool {
+	for _, s := range policySources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
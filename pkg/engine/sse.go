@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Server-Sent Events
+//
+// res.sse() turns a response into an event stream that stays open after the handler
+// returns, so timers and async callbacks can keep pushing events:
+//
+//	app.get('/events', (req, res) => {
+//	  res.sse();
+//	  const timer = setInterval(() => res.writeEvent({ time: Date.now() }, { event: 'tick' }), 1000);
+//	  res.onClose(() => clearInterval(timer));
+//	});
+//
+// Events are written to a response stream (see response_stream.go), so the request
+// finishes when the handler calls res.end() or the client disconnects.
+
+// Sse starts an event stream. Options: retry (reconnection delay in milliseconds).
+func (r *ExpressResponse) Sse(options ...interface{}) (*ExpressResponse, error) {
+	if r.stream != nil {
+		if r.stream.sse {
+			return r, nil
+		}
+		return nil, fmt.Errorf("res.sse(): response is already streamed with res.write()")
+	}
+	if r.sent {
+		return nil, fmt.Errorf("res.sse(): response already sent")
+	}
+	r.Headers["Content-Type"] = "text/event-stream"
+	r.Headers["Cache-Control"] = "no-cache"
+	r.Headers["Connection"] = "keep-alive"
+	r.Headers["X-Accel-Buffering"] = "no" // Keep proxies like nginx from buffering events
+	if err := r.startStream(); err != nil {
+		return nil, fmt.Errorf("res.sse(): %w", err)
+	}
+	r.stream.sse = true
+
+	if len(options) > 0 {
+		if opts, ok := options[0].(map[string]interface{}); ok {
+			if retry, ok := numberOption(opts["retry"]); ok && retry > 0 {
+				if _, err := fmt.Fprintf(r.writer, "retry: %d\n\n", int64(retry)); err != nil {
+					r.close()
+					return r, nil
+				}
+			}
+		}
+	}
+	r.writer.(http.Flusher).Flush()
+	return r, nil
+}
+
+// WriteEvent sends an event: strings as they are, anything else as JSON. Options: event
+// (event name) and id. It returns false once the stream is closed.
+func (r *ExpressResponse) WriteEvent(data interface{}, options ...interface{}) (bool, error) {
+	if r.stream == nil || !r.stream.sse {
+		return false, fmt.Errorf("res.writeEvent(): call res.sse() first")
+	}
+	if r.closed {
+		return false, nil
+	}
+	if err := r.engine.checkValueLimits(data); err != nil {
+		return false, err
+	}
+
+	var event strings.Builder
+	if len(options) > 0 {
+		if opts, ok := options[0].(map[string]interface{}); ok {
+			if name, ok := opts["event"].(string); ok && name != "" {
+				event.WriteString("event: " + singleLine(name) + "\n")
+			}
+			switch id := opts["id"].(type) {
+			case nil:
+			case string:
+				event.WriteString("id: " + singleLine(id) + "\n")
+			default:
+				if n, ok := numberOption(id); ok {
+					event.WriteString("id: " + strconv.FormatInt(int64(n), 10) + "\n")
+				}
+			}
+		}
+	}
+
+	text, ok := data.(string)
+	if !ok {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return false, fmt.Errorf("res.writeEvent(): failed to encode data: %w", err)
+		}
+		text = string(encoded)
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		event.WriteString("data: " + line + "\n")
+	}
+	event.WriteString("\n")
+
+	if _, err := r.writer.Write([]byte(event.String())); err != nil {
+		log.Debug().Err(err).Msg("Failed to write event, closing stream")
+		r.close()
+		return false, nil
+	}
+	r.writer.(http.Flusher).Flush()
+	return true, nil
+}
+
+// singleLine strips line breaks, which would end an event field early
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
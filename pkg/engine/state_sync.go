@@ -0,0 +1,254 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Engine sync
+//
+// serve and the MCP server each run their own engine. Pointed at the same system database
+// with sync enabled, they keep each other up to date through its sync_events table:
+//
+//   - a globalState change is published as JSON and replaces globalState in the other engines
+//   - the routes registered by code (outside startup scripts) are published as registration
+//     calls with the source of their functions and run by the other engines, so a route added
+//     through MCP also answers on the serve port. The rest of the code, such as its database
+//     writes, runs only where it was sent.
+//
+// Engines poll the table, so changes arrive within syncPollInterval. When two engines change
+// globalState at the same time, the last published state wins. An engine joining the sync
+// adopts the newest published globalState; routes registered before it joined are not replayed.
+
+// syncPollInterval is how often engines look for events of the other engines
+const syncPollInterval = time.Second
+
+// syncEventRetention is how long events stay in the table
+const syncEventRetention = time.Hour
+
+// syncApplyTimeout is how long the sync waits for the dispatcher to apply an event before it
+// moves on, so a busy dispatcher does not hold up publishing and polling
+const syncApplyTimeout = 10 * time.Second
+
+// stateSync publishes the changes of an engine and applies those of the others
+type stateSync struct {
+	e          *Engine
+	instanceID string
+	lastID     int                  // Newest event seen
+	outbox     chan syncOutboxEvent // Events waiting to be stored, written outside the dispatcher
+	stop       chan struct{}        // Closed to stop the sync
+	stopped    chan struct{}        // Closed once the sync goroutine returned
+
+	mu      sync.Mutex
+	applied string // globalState last applied from another engine, not published back
+}
+
+type syncOutboxEvent struct {
+	kind    string
+	payload string
+}
+
+// EnableStateSync shares globalState and route registrations with the other engines using the
+// same system database, until the engine is closed. Call it once the bootstrap file and the
+// startup scripts ran, so their state does not overwrite the state the other engines share.
+func (e *Engine) EnableStateSync() error {
+	if e.syncer.Load() != nil {
+		return fmt.Errorf("state sync is already enabled")
+	}
+	if !e.dispatching {
+		return fmt.Errorf("state sync needs a running dispatcher")
+	}
+
+	ctx := context.Background()
+	latest, err := e.repos.Sync().LatestSyncEvent(ctx, "")
+	if err != nil {
+		return err
+	}
+	state, err := e.repos.Sync().LatestSyncEvent(ctx, repository.SyncKindState)
+	if err != nil {
+		return err
+	}
+
+	s := &stateSync{
+		e:          e,
+		instanceID: uuid.NewString(),
+		outbox:     make(chan syncOutboxEvent, 100),
+		stop:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+	if latest != nil {
+		s.lastID = latest.ID
+	}
+	if state != nil {
+		s.adoptState(*state)
+	}
+
+	e.OnEvent(func(event Event) {
+		s.mu.Lock()
+		applied := s.applied
+		s.mu.Unlock()
+		if event.State != applied {
+			s.publish(repository.SyncKindState, event.State)
+		}
+	}, EventStateChanged)
+	e.syncer.Store(s)
+
+	go s.run()
+	log.Info().Str("instanceID", s.instanceID).Msg("Engine state sync enabled")
+	return nil
+}
+
+// stopStateSync stops the sync goroutine and waits for it
+func (e *Engine) stopStateSync() {
+	if s := e.syncer.Load(); s != nil {
+		close(s.stop)
+		<-s.stopped
+	}
+}
+
+// publish queues an event without blocking the dispatcher; events are dropped if the
+// database cannot keep up
+func (s *stateSync) publish(kind, payload string) {
+	select {
+	case s.outbox <- syncOutboxEvent{kind: kind, payload: payload}:
+	default:
+		log.Warn().Str("kind", kind).Msg("Sync outbox full, dropping event")
+	}
+}
+
+// publishes reports whether the routes a job registers are published: not for jobs of the
+// sync itself or startup scripts, which every engine loads on its own
+func (s *stateSync) publishes(job EvalJob) bool {
+	return !job.Replicate && job.Source != repository.SourceSync && job.Source != repository.SourceFile
+}
+
+// publishRoutes publishes the registrations of a job as code for the other engines
+func (s *stateSync) publishRoutes(registrations []string) {
+	s.publish(repository.SyncKindCode, strings.Join(registrations, "\n"))
+}
+
+// syncRegistration keeps a registration of the running code for the other engines: a call of
+// the binding with functions as their source and the other arguments as JSON. Functions are
+// run from their source, so they can only use globals, not variables of the code around them.
+// Registrations with built-in functions such as cors() or with options that are not JSON
+// cannot be written as code and are left out with a warning.
+func (e *Engine) syncRegistration(route, binding string, args ...interface{}) {
+	if e.synced == nil {
+		return
+	}
+	code := make([]string, 0, len(args))
+	for _, arg := range args {
+		if fn, ok := arg.(goja.Value); ok {
+			source := fn.String()
+			if strings.Contains(source, "[native code]") {
+				log.Warn().Str("route", route).Msg("Not syncing route with a built-in function such as cors() to the other engines")
+				return
+			}
+			code = append(code, "("+source+")")
+			continue
+		}
+		data, err := json.Marshal(arg)
+		if err != nil {
+			log.Warn().Err(err).Str("route", route).Msg("Not syncing route with options that are not JSON to the other engines")
+			return
+		}
+		code = append(code, string(data))
+	}
+	*e.synced = append(*e.synced, binding+"("+strings.Join(code, ", ")+");")
+}
+
+func (s *stateSync) run() {
+	defer close(s.stopped)
+	ctx := context.Background()
+	ticker := time.NewTicker(syncPollInterval)
+	defer ticker.Stop()
+	prune := time.NewTicker(syncEventRetention / 4)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case event := <-s.outbox:
+			if _, err := s.e.repos.Sync().PublishSyncEvent(ctx, s.instanceID, event.kind, event.payload); err != nil {
+				log.Error().Err(err).Str("kind", event.kind).Msg("Failed to publish sync event")
+			}
+		case <-ticker.C:
+			s.poll(ctx)
+		case <-prune.C:
+			if err := s.e.repos.Sync().PruneSyncEvents(ctx, time.Now().Add(-syncEventRetention)); err != nil {
+				log.Warn().Err(err).Msg("Failed to prune sync events")
+			}
+		}
+	}
+}
+
+// poll applies the events other engines published since the last poll. Of several state
+// events only the newest is applied.
+func (s *stateSync) poll(ctx context.Context) {
+	events, err := s.e.repos.Sync().ListSyncEvents(ctx, s.lastID, s.instanceID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read sync events")
+		return
+	}
+
+	lastState := -1
+	for i, event := range events {
+		if event.Kind == repository.SyncKindState {
+			lastState = i
+		}
+	}
+	for i, event := range events {
+		s.lastID = event.ID
+		switch {
+		case event.Kind == repository.SyncKindCode:
+			s.apply(event, event.Payload)
+		case event.Kind == repository.SyncKindState && i == lastState:
+			s.adoptState(event)
+		}
+	}
+}
+
+// adoptState replaces globalState with the state another engine published
+func (s *stateSync) adoptState(event repository.SyncEvent) {
+	s.mu.Lock()
+	s.applied = event.Payload
+	s.mu.Unlock()
+	s.apply(event, "globalState = "+event.Payload)
+}
+
+// apply runs the code of an event on the dispatcher and waits up to syncApplyTimeout for it.
+// Route registrations are stored as an execution and runs in every pool runtime; state updates are not
+// stored. An event still queued when the wait ends is applied later without being awaited.
+func (s *stateSync) apply(event repository.SyncEvent, code string) {
+	done := make(chan error, 1)
+	job := EvalJob{Code: code, Done: done, Source: repository.SourceSync}
+	if event.Kind == repository.SyncKindCode {
+		job.SessionID = "sync-" + event.InstanceID
+		job.Replicate = true
+	}
+	s.e.SubmitJob(job)
+
+	timeout := time.NewTimer(syncApplyTimeout)
+	defer timeout.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Warn().Err(err).Int("eventID", event.ID).Str("kind", event.Kind).Str("from", event.InstanceID).Msg("Failed to apply sync event")
+			return
+		}
+		log.Debug().Int("eventID", event.ID).Str("kind", event.Kind).Str("from", event.InstanceID).Msg("Applied sync event")
+	case <-timeout.C:
+		log.Warn().Int("eventID", event.ID).Str("kind", event.Kind).Dur("timeout", syncApplyTimeout).Msg("Dispatcher busy, not waiting for sync event to apply")
+	case <-s.stop:
+	}
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSyncedEngine starts an engine on the shared databases with state sync enabled
+func newSyncedEngine(t *testing.T, appDB, systemDB string) *Engine {
+	t.Helper()
+	e := NewEngine(appDB, systemDB)
+	e.StartDispatcher()
+	if err := e.EnableStateSync(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = e.Close()
+	})
+	return e
+}
+
+// runCode executes code on the engine and fails the test if it throws
+func runCode(t *testing.T, e *Engine, code string) {
+	t.Helper()
+	done := make(chan error, 1)
+	e.SubmitJob(EvalJob{Code: code, Done: done})
+	if err := <-done; err != nil {
+		t.Fatalf("%s: %v", code, err)
+	}
+}
+
+// getRoute answers a GET request with the handler of the engine for path
+func getRoute(t *testing.T, e *Engine, path string) string {
+	t.Helper()
+	handler, ok := e.GetHandler(http.MethodGet, path)
+	if !ok {
+		t.Fatalf("no handler for GET %s", path)
+	}
+	w := httptest.NewRecorder()
+	done := make(chan error, 1)
+	e.SubmitJob(EvalJob{Handler: handler, W: w, R: httptest.NewRequest(http.MethodGet, path, nil), Done: done})
+	<-done
+	return strings.TrimSpace(w.Body.String())
+}
+
+func TestStateSyncPublishesRouteRegistrationsOnly(t *testing.T) {
+	dir := t.TempDir()
+	appDB, systemDB := filepath.Join(dir, "app.db"), filepath.Join(dir, "system.db")
+	origin := newSyncedEngine(t, appDB, systemDB)
+	peer := newSyncedEngine(t, appDB, systemDB)
+
+	runCode(t, origin, `
+		db.exec('CREATE TABLE IF NOT EXISTS visits (id INTEGER PRIMARY KEY)');
+		db.exec('INSERT INTO visits DEFAULT VALUES');
+		app.get('/visits', (req, res) => res.json(db.query('SELECT COUNT(*) AS n FROM visits')[0]));
+	`)
+
+	deadline := time.Now().Add(10 * syncPollInterval)
+	for {
+		if _, ok := peer.GetHandler(http.MethodGet, "/visits"); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("route was not synced to the peer")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	want := `{"n":1}`
+	if got := getRoute(t, peer, "/visits"); got != want {
+		t.Errorf("peer: GET /visits = %s, want %s", got, want)
+	}
+	if got := getRoute(t, origin, "/visits"); got != want {
+		t.Errorf("origin: GET /visits = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Serving files
+//
+// res.sendFile(path) and app.static(urlPrefix, dir) serve files from the files directory
+// set with SetFilesDir; without one they fail, so the server never serves its working
+// directory and the databases in it by accident. Paths are resolved inside it with os.Root,
+// so neither ".." segments nor symlinks can reach files outside of it:
+//
+//	app.static('/assets', 'public');
+//	app.get('/report', (req, res) => res.sendFile('reports/latest.pdf', { maxAge: 60 }));
+//
+// Content types come from the file extension, falling back to sniffing the content, and
+// conditional (If-Modified-Since) and Range requests are answered like net/http does.
+// Dotfiles are never served.
+
+// staticMount is a directory mounted with app.static()
+type staticMount struct {
+	prefix string // URL prefix without trailing slash, "" for the whole site
+	dir    string // Directory relative to the files directory, for logs
+	root   *os.Root
+	maxAge int    // Cache-Control max-age in seconds, 0 to send none
+	index  string // File served for directories, "" for none
+}
+
+// SetFilesDir sets the directory res.sendFile() and app.static() serve files from
+func (e *Engine) SetFilesDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve files directory: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("failed to access files directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("files directory %s is not a directory", abs)
+	}
+
+	root := e.root()
+	root.mu.Lock()
+	root.filesDir = abs
+	root.mu.Unlock()
+	return nil
+}
+
+// FilesDir returns the directory res.sendFile() and app.static() serve files from, "" if
+// none is set
+func (e *Engine) FilesDir() string {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.filesDir
+}
+
+// openFilesRoot opens a directory inside the files directory
+func (e *Engine) openFilesRoot(dir string) (*os.Root, error) {
+	filesDir := e.FilesDir()
+	if filesDir == "" {
+		return nil, fmt.Errorf("no files directory is configured: start the server with --files-dir")
+	}
+	base, err := os.OpenRoot(filesDir)
+	if err != nil {
+		return nil, err
+	}
+	if dir == "" || dir == "." {
+		return base, nil
+	}
+	defer func() {
+		if err := base.Close(); err != nil {
+			log.Debug().Err(err).Msg("Failed to close files directory")
+		}
+	}()
+	return base.OpenRoot(filepath.Clean(dir))
+}
+
+// appStatic mounts a directory under a URL prefix: app.static(urlPrefix, dir, options).
+// Options: maxAge (Cache-Control max-age in seconds) and index (file served for
+// directories, "index.html" by default, false for none).
+func (e *Engine) appStatic(prefix, dir string, options ...map[string]interface{}) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	e.checkRouteRegistration("STATIC", prefix)
+	if e.primary != nil {
+		// Static files are served by Go, so the primary runtime's mount is enough
+		return
+	}
+
+	mount := &staticMount{prefix: strings.TrimSuffix(prefix, "/"), dir: dir, index: "index.html"}
+	if len(options) > 0 && options[0] != nil {
+		opts := options[0]
+		if maxAge, ok := numberOption(opts["maxAge"]); ok && maxAge > 0 {
+			mount.maxAge = int(maxAge)
+		}
+		switch index := opts["index"].(type) {
+		case string:
+			mount.index = index
+		case bool:
+			if !index {
+				mount.index = ""
+			}
+		}
+	}
+
+	root, err := e.openFilesRoot(dir)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("app.static(%s): cannot open directory %s: %v", prefix, dir, err)))
+	}
+	mount.root = root
+	syncArgs := []interface{}{prefix, dir}
+	if len(options) > 0 {
+		syncArgs = append(syncArgs, options[0])
+	}
+	e.syncRegistration("STATIC "+prefix, "app.static", syncArgs...)
+
+	e.mu.Lock()
+	mounts := make([]*staticMount, 0, len(e.statics)+1)
+	for _, existing := range e.statics {
+		if existing.prefix == mount.prefix {
+			if err := existing.root.Close(); err != nil {
+				log.Debug().Err(err).Str("prefix", prefix).Msg("Failed to close replaced static directory")
+			}
+			continue
+		}
+		mounts = append(mounts, existing)
+	}
+	mounts = append(mounts, mount)
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].prefix) > len(mounts[j].prefix)
+	})
+	e.statics = mounts
+	e.mu.Unlock()
+
+	log.Info().Str("prefix", prefix).Str("dir", dir).Msg("Mounted static directory")
+	e.emitRouteRegistered("STATIC", prefix)
+}
+
+// ServeStatic serves a GET or HEAD request from the directories mounted with app.static().
+// It returns false if no mounted directory has the file.
+func (e *Engine) ServeStatic(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	e.mu.RLock()
+	mounts := e.statics
+	e.mu.RUnlock()
+
+	for _, mount := range mounts {
+		rest, ok := strings.CutPrefix(r.URL.Path, mount.prefix)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		file, info, err := openServedFile(mount.root, rest, mount.index)
+		if err != nil {
+			continue
+		}
+		if mount.maxAge > 0 {
+			w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(mount.maxAge))
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+		if err := file.Close(); err != nil {
+			log.Debug().Err(err).Str("path", r.URL.Path).Msg("Failed to close static file")
+		}
+		return true
+	}
+	return false
+}
+
+// openServedFile opens a file of a root for serving. Directories are served through their
+// index file; dotfiles are refused.
+func openServedFile(root *os.Root, name, index string) (*os.File, fs.FileInfo, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return nil, nil, fs.ErrNotExist
+		}
+	}
+	if name == "" {
+		name = "."
+	}
+
+	file, err := root.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err == nil && info.IsDir() {
+		_ = file.Close()
+		if index == "" {
+			return nil, nil, fs.ErrNotExist
+		}
+		return openServedFile(root, path.Join(name, index), "")
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	return file, info, nil
+}
+
+// SendFile sends a file of the files directory. Options: root (directory the path is
+// relative to, inside the files directory), maxAge (Cache-Control max-age in seconds) and
+// headers. Missing files are answered with 404.
+func (r *ExpressResponse) SendFile(name string, options ...map[string]interface{}) error {
+	if r.stream != nil {
+		return fmt.Errorf("res.sendFile(): response is already streamed")
+	}
+	if r.sent {
+		return nil
+	}
+
+	dir := ""
+	if len(options) > 0 && options[0] != nil {
+		opts := options[0]
+		if root, ok := opts["root"].(string); ok {
+			dir = root
+		}
+		if maxAge, ok := numberOption(opts["maxAge"]); ok && maxAge > 0 {
+			r.Headers["Cache-Control"] = "public, max-age=" + strconv.Itoa(int(maxAge))
+		}
+		if headers, ok := opts["headers"].(map[string]interface{}); ok {
+			for key, value := range headers {
+				r.Headers[key] = fmt.Sprint(value)
+			}
+		}
+	}
+
+	root, err := r.engine.openFilesRoot(dir)
+	if err != nil {
+		return fmt.Errorf("res.sendFile(): cannot open directory %s: %w", dir, err)
+	}
+	defer func() {
+		if err := root.Close(); err != nil {
+			log.Debug().Err(err).Msg("Failed to close files directory")
+		}
+	}()
+
+	file, info, err := openServedFile(root, name, "")
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Debug().Err(err).Str("file", name).Msg("res.sendFile(): cannot open file")
+		}
+		r.StatusCode = http.StatusNotFound
+		return r.Send("Not Found")
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Debug().Err(err).Str("file", name).Msg("Failed to close sent file")
+		}
+	}()
+
+	r.sent = true
+	for key, value := range r.Headers {
+		r.writer.Header().Set(key, value)
+	}
+	for _, cookie := range r.Cookies {
+		http.SetCookie(r.writer, cookie)
+	}
+	http.ServeContent(r.writer, r.request, info.Name(), info.ModTime(), file)
+	return nil
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// Artifact storage
+//
+// By default the files executions produce stay on the machine: attachments of output.attach()
+// in the system database, the full output of truncated executions in the output directory.
+// With a storage (jesus serve --storage s3://bucket/prefix), both go to the storage instead,
+// under attachments/ and output/, so a container that loses its disk keeps them. The system
+// database then only records the storage key of an attachment.
+//
+// Deleting executions removes their attachment records; a sweep deletes the objects no
+// record points to anymore. Engines keeping artifacts in the same place must share a system
+// database, or use different prefixes.
+
+const (
+	attachmentsPrefix = "attachments/"
+	outputPrefix      = "output/"
+
+	storageTimeout        = 2 * time.Minute // Time an upload or download of an artifact may take
+	attachmentSweepPeriod = time.Hour       // Time between two sweeps of orphaned attachments
+	attachmentSweepGrace  = time.Hour       // Age below which unreferenced objects are kept, as their execution may not be stored yet
+)
+
+// storageSweeper deletes the attachment objects of deleted executions
+type storageSweeper struct {
+	stop    chan struct{} // Closed to stop the sweeper
+	stopped chan struct{} // Closed once the sweeper returned
+}
+
+// SetStorage keeps execution attachments and full outputs in store. It can be called once,
+// before code runs.
+func (e *Engine) SetStorage(store storage.Storage) error {
+	root := e.root()
+	root.mu.Lock()
+	if root.storage != nil {
+		root.mu.Unlock()
+		return fmt.Errorf("the storage is already set")
+	}
+	root.storage = store
+	root.mu.Unlock()
+
+	if root.repos != nil {
+		sweeper := &storageSweeper{stop: make(chan struct{}), stopped: make(chan struct{})}
+		root.sweeper.Store(sweeper)
+		go root.runStorageSweeper(sweeper)
+	}
+	log.Info().Str("storage", store.String()).Msg("Keeping execution artifacts in storage")
+	return nil
+}
+
+// Storage returns the storage of execution artifacts, nil if they stay local
+func (e *Engine) Storage() storage.Storage {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return root.storage
+}
+
+// stopStorageSweeper stops the sweep of orphaned attachments
+func (e *Engine) stopStorageSweeper() {
+	if sweeper := e.sweeper.Load(); sweeper != nil {
+		close(sweeper.stop)
+		<-sweeper.stopped
+	}
+}
+
+// storeAttachments uploads the attachments of an execution about to be recorded, leaving only
+// their storage keys in the request. An attachment that cannot be uploaded stays in the database.
+func (e *Engine) storeAttachments(req *repository.CreateExecutionRequest) {
+	store := e.Storage()
+	if store == nil || len(req.Attachments) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	dir := fmt.Sprintf("%s%s-%d/", attachmentsPrefix, outputFileNameUnsafe.ReplaceAllString(req.SessionID, "_"), time.Now().UnixNano())
+	for i := range req.Attachments {
+		attachment := &req.Attachments[i]
+		key := dir + attachment.Name
+		if err := store.Put(ctx, key, attachment.Data); err != nil {
+			log.Error().Err(err).Str("sessionID", req.SessionID).Str("name", attachment.Name).Msg("Failed to store attachment, keeping it in the database")
+			continue
+		}
+		attachment.StorageKey = key
+		attachment.Data = nil
+	}
+}
+
+// AttachmentData returns the content of an attachment read with GetAttachment, fetching it
+// from the storage if it is kept there
+func (e *Engine) AttachmentData(ctx context.Context, attachment *repository.ExecutionAttachment) ([]byte, error) {
+	if attachment.StorageKey == "" {
+		return attachment.Data, nil
+	}
+	store := e.Storage()
+	if store == nil {
+		return nil, fmt.Errorf("attachment %s is kept in storage, but no storage is configured", attachment.Name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, storageTimeout)
+	defer cancel()
+	return storage.ReadAll(ctx, store, attachment.StorageKey)
+}
+
+// runStorageSweeper sweeps orphaned attachments periodically until stopped
+func (e *Engine) runStorageSweeper(sweeper *storageSweeper) {
+	defer close(sweeper.stopped)
+	ticker := time.NewTicker(attachmentSweepPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sweeper.stop:
+			return
+		case <-ticker.C:
+			if err := e.SweepAttachments(context.Background()); err != nil {
+				log.Warn().Err(err).Msg("Failed to sweep orphaned attachments")
+			}
+		}
+	}
+}
+
+// SweepAttachments deletes the attachment objects of the storage no execution refers to
+func (e *Engine) SweepAttachments(ctx context.Context) error {
+	store := e.Storage()
+	if store == nil || e.repos == nil {
+		return nil
+	}
+	objects, err := store.List(ctx, attachmentsPrefix)
+	if err != nil {
+		return err
+	}
+	keys, err := e.repos.Executions().ListAttachmentStorageKeys(ctx)
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		referenced[key] = true
+	}
+
+	cutoff := time.Now().Add(-attachmentSweepGrace)
+	deleted := 0
+	for _, object := range objects {
+		if referenced[object.Key] || object.Modified.After(cutoff) || !strings.HasPrefix(object.Key, attachmentsPrefix) {
+			continue
+		}
+		if err := store.Delete(ctx, object.Key); err != nil {
+			log.Warn().Err(err).Str("key", object.Key).Msg("Failed to delete orphaned attachment")
+			continue
+		}
+		deleted++
+	}
+	if deleted > 0 {
+		log.Info().Int("deleted", deleted).Msg("Deleted attachments of deleted executions")
+	}
+	return nil
+}
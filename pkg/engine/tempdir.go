@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// Execution temp directories
+//
+// tmp gives each job (code execution, request or callback) a private directory for the files
+// it generates, so scripts do not litter the working directory of the server:
+//
+//	tmp.writeFile('report.csv', csv)
+//	const lines = tmp.readFile('report.csv').split('\n')
+//	tmp.usage()   // { used: 1234, quota: 67108864 }
+//
+// The directory is created on first use and removed with its files once the job finishes; a
+// handler that returns a promise keeps it until its response is sent. The files a job writes
+// through tmp are limited to TempLimits.MaxBytes in total.
+
+// TempLimits configures the temp directories of executions
+type TempLimits struct {
+	Dir      string // Directory the temp directories are created in, "" for the system temp directory
+	MaxBytes int64  // Total size of the files of one temp directory, 0 for no limit
+}
+
+// DefaultTempLimits returns the limits used unless SetTempLimits is called
+func DefaultTempLimits() TempLimits {
+	return TempLimits{
+		MaxBytes: 64 << 20,
+	}
+}
+
+// SetTempLimits configures the temp directories of executions
+func (e *Engine) SetTempLimits(limits TempLimits) error {
+	if limits.MaxBytes < 0 {
+		return fmt.Errorf("the temp directory quota must not be negative")
+	}
+	if limits.Dir != "" {
+		if err := os.MkdirAll(limits.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+	}
+
+	root := e.root()
+	root.mu.Lock()
+	root.tempLimits = limits
+	root.mu.Unlock()
+	return nil
+}
+
+// tempScope is the temp directory of one job
+type tempScope struct {
+	limits TempLimits
+	dir    string // Created on first use, "" before
+}
+
+// newTempScope returns the temp scope of a job, without creating its directory yet
+func (e *Engine) newTempScope() *tempScope {
+	root := e.root()
+	root.mu.RLock()
+	defer root.mu.RUnlock()
+	return &tempScope{limits: root.tempLimits}
+}
+
+// path returns the directory of the scope, creating it on first use
+func (s *tempScope) path() (string, error) {
+	if s.dir == "" {
+		dir, err := os.MkdirTemp(s.limits.Dir, "jesus-exec-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		s.dir = dir
+	}
+	return s.dir, nil
+}
+
+// file returns the path of a file of the scope. Names may not leave the directory.
+func (s *tempScope) file(name string) (string, error) {
+	clean := filepath.Clean(name)
+	if name == "" || clean == "." || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid temp file name %q", name)
+	}
+	dir, err := s.path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// usage returns the total size of the files of the scope
+func (s *tempScope) usage() int64 {
+	if s.dir == "" {
+		return 0
+	}
+	var used int64
+	_ = filepath.WalkDir(s.dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				used += info.Size()
+			}
+		}
+		return nil
+	})
+	return used
+}
+
+// remove deletes the directory of the scope and its files
+func (s *tempScope) remove() {
+	if s == nil || s.dir == "" {
+		return
+	}
+	if err := os.RemoveAll(s.dir); err != nil {
+		log.Warn().Err(err).Str("dir", s.dir).Msg("Failed to remove execution temp directory")
+	}
+	s.dir = ""
+}
+
+// setupTempBindings installs tmp
+func (e *Engine) setupTempBindings() {
+	if err := e.rt.Set("tmp", map[string]interface{}{
+		"dir":        e.tmpDir,
+		"path":       e.tmpPath,
+		"writeFile":  e.tmpWriteFile,
+		"appendFile": e.tmpAppendFile,
+		"readFile":   e.tmpReadFile,
+		"list":       e.tmpList,
+		"remove":     e.tmpRemove,
+		"usage":      e.tmpUsage,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set tmp binding")
+	}
+}
+
+// currentTempScope returns the temp scope of the running job
+func (e *Engine) currentTempScope() *tempScope {
+	if e.tempScope == nil {
+		panic(e.rt.NewTypeError("tmp is only available while a job runs"))
+	}
+	return e.tempScope
+}
+
+// tmpDir implements tmp.dir()
+func (e *Engine) tmpDir() string {
+	dir, err := e.currentTempScope().path()
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return dir
+}
+
+// tmpPath implements tmp.path(name), the absolute path of a file of the temp directory
+func (e *Engine) tmpPath(name string) string {
+	path, err := e.currentTempScope().file(name)
+	if err != nil {
+		panic(e.rt.NewTypeError(err.Error()))
+	}
+	return path
+}
+
+// tmpWriteFile implements tmp.writeFile(name, data)
+func (e *Engine) tmpWriteFile(name string, data goja.Value) {
+	e.writeTempFile("tmp.writeFile", name, data, false)
+}
+
+// tmpAppendFile implements tmp.appendFile(name, data)
+func (e *Engine) tmpAppendFile(name string, data goja.Value) {
+	e.writeTempFile("tmp.appendFile", name, data, true)
+}
+
+// writeTempFile writes or appends a string, ArrayBuffer or Uint8Array within the quota
+func (e *Engine) writeTempFile(fn, name string, data goja.Value, appendData bool) {
+	var content []byte
+	switch v := data.Export().(type) {
+	case string:
+		content = []byte(v)
+	case []byte:
+		content = v
+	case goja.ArrayBuffer:
+		content = v.Bytes()
+	default:
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s(%s): data must be a string, ArrayBuffer or Uint8Array, got %T", fn, name, v)))
+	}
+
+	scope := e.currentTempScope()
+	path, err := scope.file(name)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("%s(): %v", fn, err)))
+	}
+	if scope.limits.MaxBytes > 0 {
+		used := scope.usage() + int64(len(content))
+		if info, err := os.Stat(path); err == nil && !appendData {
+			used -= info.Size()
+		}
+		if used > scope.limits.MaxBytes {
+			panic(e.rt.NewGoError(fmt.Errorf("%s(%s): the temp directory quota of %d bytes is exceeded", fn, name, scope.limits.MaxBytes)))
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendData {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+}
+
+// tmpReadFile implements tmp.readFile(name, [encoding]): the content as string, or as
+// ArrayBuffer with the encoding 'binary'
+func (e *Engine) tmpReadFile(name string, encoding ...string) goja.Value {
+	path, err := e.currentTempScope().file(name)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("tmp.readFile(): %v", err)))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	if len(encoding) > 0 && encoding[0] == "binary" {
+		return e.rt.ToValue(e.rt.NewArrayBuffer(content))
+	}
+	return e.rt.ToValue(string(content))
+}
+
+// TempFile is a file of an execution temp directory, listed by tmp.list()
+type TempFile struct {
+	Name string `json:"name"` // Path relative to the temp directory
+	Size int64  `json:"size"`
+}
+
+// tmpList implements tmp.list()
+func (e *Engine) tmpList() []TempFile {
+	scope := e.currentTempScope()
+	files := []TempFile{}
+	if scope.dir == "" {
+		return files
+	}
+	_ = filepath.WalkDir(scope.dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(scope.dir, path)
+		files = append(files, TempFile{Name: filepath.ToSlash(rel), Size: info.Size()})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files
+}
+
+// tmpRemove implements tmp.remove(name), which is no error for a missing file
+func (e *Engine) tmpRemove(name string) {
+	path, err := e.currentTempScope().file(name)
+	if err != nil {
+		panic(e.rt.NewTypeError(fmt.Sprintf("tmp.remove(): %v", err)))
+	}
+	if err := os.RemoveAll(path); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+}
+
+// tmpUsage implements tmp.usage()
+func (e *Engine) tmpUsage() map[string]interface{} {
+	scope := e.currentTempScope()
+	return map[string]interface{}{
+		"used":  scope.usage(),
+		"quota": scope.limits.MaxBytes,
+	}
+}
@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/jesus/pkg/typescript"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Execution templates
+//
+// A template configures the runtime an execution runs in, selected with
+// /v1/execute?env=<name>. Templates are read from a YAML file mapping names to settings:
+//
+//	data-science:
+//	  description: Number crunching without network access
+//	  bindings:          # Turn bindings off (all are on by default)
+//	    fetch: false
+//	    fetchAsync: false
+//	  preload:           # Libraries run when the runtime is created, relative to the file
+//	    - libs/stats.js
+//	  globals:           # Values defined as globals
+//	    precision: 4
+//
+// Each template gets a dedicated runtime, created from the template on first use and kept
+// like a session runtime. Stateful sessions started with a template are seeded from it.
+
+// templateSessionPrefix prefixes the session runtime IDs of templates
+const templateSessionPrefix = "template:"
+
+// ExecutionTemplate configures the runtime of executions that select it
+type ExecutionTemplate struct {
+	Name        string                 `json:"name" yaml:"-"`
+	Description string                 `json:"description,omitempty" yaml:"description"`
+	Bindings    map[string]bool        `json:"bindings,omitempty" yaml:"bindings"` // false removes the global
+	Preload     []string               `json:"preload,omitempty" yaml:"preload"`   // Files run when the runtime is created
+	Globals     map[string]interface{} `json:"globals,omitempty" yaml:"globals"`
+
+	preloaded []preloadedScript // Code of the Preload files, read by LoadExecutionTemplates
+}
+
+type preloadedScript struct {
+	file string
+	code string
+}
+
+// LoadExecutionTemplates reads execution templates from a YAML file. Preload paths are
+// relative to the file, and the files are read right away so broken templates fail early.
+func LoadExecutionTemplates(path string) (map[string]*ExecutionTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read execution templates: %w", err)
+	}
+
+	var templates map[string]*ExecutionTemplate
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("failed to parse execution templates: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	for name, template := range templates {
+		if template == nil {
+			template = &ExecutionTemplate{}
+			templates[name] = template
+		}
+		template.Name = name
+		for _, file := range template.Preload {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			source, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("template %s: failed to read preload file: %w", name, err)
+			}
+			code := string(source)
+			if typescript.IsTypeScript(file) {
+				if code, err = typescript.Strip(code); err != nil {
+					return nil, fmt.Errorf("template %s: failed to strip TypeScript types of %s: %w", name, file, err)
+				}
+			}
+			template.preloaded = append(template.preloaded, preloadedScript{file: file, code: code})
+		}
+	}
+	return templates, nil
+}
+
+// SetExecutionTemplates sets the templates executions can select. Bindings must name globals
+// of the engine.
+func (e *Engine) SetExecutionTemplates(templates map[string]*ExecutionTemplate) error {
+	for name, template := range templates {
+		for binding := range template.Bindings {
+			if binding == "console" || binding == "globalState" {
+				return fmt.Errorf("template %s: binding %q cannot be disabled", name, binding)
+			}
+			if !e.hasBinding(binding) {
+				return fmt.Errorf("template %s: unknown binding %q", name, binding)
+			}
+		}
+	}
+
+	e.mu.Lock()
+	e.templates = templates
+	e.mu.Unlock()
+	log.Info().Int("count", len(templates)).Msg("Configured execution templates")
+	return nil
+}
+
+// ExecutionTemplate returns a template by name
+func (e *Engine) ExecutionTemplate(name string) (*ExecutionTemplate, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	template, ok := e.templates[name]
+	return template, ok
+}
+
+// ExecutionTemplates lists the templates, sorted by name
+func (e *Engine) ExecutionTemplates() []*ExecutionTemplate {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	templates := make([]*ExecutionTemplate, 0, len(e.templates))
+	for _, template := range e.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// hasBinding reports whether a global was installed during engine setup
+func (e *Engine) hasBinding(name string) bool {
+	i := sort.SearchStrings(e.bindings, name)
+	return i < len(e.bindings) && e.bindings[i] == name
+}
+
+// templateSessionID returns the ID of the session runtime shared by the executions of a template
+func templateSessionID(name string) string {
+	return templateSessionPrefix + name
+}
+
+// isTemplateSession reports whether a session runtime belongs to a template rather than a client
+func isTemplateSession(id string) bool {
+	return strings.HasPrefix(id, templateSessionPrefix)
+}
+
+// enterJobTemplate makes the runtime of the template of a job the current runtime: the
+// session runtime for stateful jobs, else the runtime shared by the template's executions
+func (e *Engine) enterJobTemplate(job EvalJob) (func(), error) {
+	template, ok := e.ExecutionTemplate(job.Template)
+	if !ok {
+		return nil, fmt.Errorf("unknown execution template %q", job.Template)
+	}
+	id := templateSessionID(template.Name)
+	if job.Stateful && job.SessionID != "" {
+		id = job.SessionID
+	}
+	return e.enterTemplateSession(id, template)
+}
+
+// applyTemplate seeds a new runtime from a template: it removes disabled bindings, defines
+// the globals and runs the preload files
+func applyTemplate(rt *goja.Runtime, template *ExecutionTemplate) error {
+	global := rt.GlobalObject()
+	for binding, enabled := range template.Bindings {
+		if !enabled {
+			if err := global.Delete(binding); err != nil {
+				return fmt.Errorf("template %s: failed to remove binding %s: %w", template.Name, binding, err)
+			}
+		}
+	}
+	for name, value := range template.Globals {
+		if err := rt.Set(name, value); err != nil {
+			return fmt.Errorf("template %s: failed to set global %s: %w", template.Name, name, err)
+		}
+	}
+	for _, script := range template.preloaded {
+		if _, err := rt.RunScript(script.file, script.code); err != nil {
+			return fmt.Errorf("template %s: preload %s failed: %w", template.Name, filepath.Base(script.file), err)
+		}
+	}
+	return nil
+}
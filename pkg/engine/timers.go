@@ -0,0 +1,285 @@
+package engine
+
+import (
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/eventloop"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// Timers
+//
+// setTimeout and setInterval schedule callbacks on the engine's event loop. The loop only
+// keeps time: when a timer fires it queues a job, and the callback runs on the dispatcher of
+// the runtime that created the timer like any other job, under the execution timeout.
+// Timers created by code that runs in every pool runtime (bootstrap.js, --scripts) are only
+// scheduled in the primary runtime, so they fire once. The other runtimes get a handle that
+// stands for the primary's copy, matched by the order the replicated code set its timers in.
+// clearTimeout and clearInterval only reach the timers of the runtime calling them.
+
+// minTimerInterval is the shortest period of setInterval, so a tight interval cannot flood
+// the job queue
+const minTimerInterval = 10 * time.Millisecond
+
+// TimerInfo describes an active timer
+type TimerInfo struct {
+	ID        int64     `json:"id"`
+	Interval  bool      `json:"interval"`            // Repeats until cleared
+	DelayMs   int64     `json:"delayMs"`             // Delay or period in milliseconds
+	Created   time.Time `json:"created"`             // When the timer was set
+	Origin    string    `json:"origin"`              // Route or execution source that set the timer
+	SessionID string    `json:"sessionId,omitempty"` // Session whose runtime runs the callback
+	Runs      int       `json:"runs"`                // How often the callback ran
+	LastError string    `json:"lastError,omitempty"` // Error of the last run
+}
+
+// scriptTimer is a timer set from JavaScript
+type scriptTimer struct {
+	info     TimerInfo
+	fn       goja.Callable
+	args     []goja.Value
+	rt       *goja.Runtime // Runtime owning the callback
+	timeout  *eventloop.Timer
+	interval *eventloop.Interval
+	queued   atomic.Bool // A run is waiting in the job queue
+}
+
+// setupTimerBindings installs setTimeout, setInterval, clearTimeout and clearInterval
+func (e *Engine) setupTimerBindings() {
+	bindings := map[string]interface{}{
+		"setTimeout": func(call goja.FunctionCall) goja.Value {
+			return e.setTimer(call, false)
+		},
+		"setInterval": func(call goja.FunctionCall) goja.Value {
+			return e.setTimer(call, true)
+		},
+		"clearTimeout":  e.clearTimer,
+		"clearInterval": e.clearTimer,
+	}
+	for name, fn := range bindings {
+		if err := e.rt.Set(name, fn); err != nil {
+			log.Error().Err(err).Str("binding", name).Msg("Failed to set timer binding")
+		}
+	}
+}
+
+// root returns the primary runtime of the pool, which owns the event loop and timer IDs
+func (e *Engine) root() *Engine {
+	if e.primary != nil {
+		return e.primary
+	}
+	return e
+}
+
+// setTimer implements setTimeout(fn, delay, ...args) and setInterval(fn, delay, ...args)
+func (e *Engine) setTimer(call goja.FunctionCall, interval bool) goja.Value {
+	name := "setTimeout"
+	if interval {
+		name = "setInterval"
+	}
+	fn, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(e.rt.NewTypeError(name + ": callback must be a function"))
+	}
+
+	delay := time.Duration(0)
+	if ms := call.Argument(1).ToFloat(); !math.IsNaN(ms) && ms > 0 {
+		delay = time.Duration(ms * float64(time.Millisecond))
+	}
+	if interval && delay < minTimerInterval {
+		delay = minTimerInterval
+	}
+
+	root := e.root()
+	id := atomic.AddInt64(&root.nextTimerID, 1)
+	if e.primary != nil && e.replicating {
+		// The primary runtime schedules its own copy of this timer
+		e.mu.Lock()
+		if e.mirroredTimers == nil {
+			e.mirroredTimers = make(map[int64]int)
+		}
+		e.mirroredTimers[id] = e.replicatedTimerCount
+		e.replicatedTimerCount++
+		e.mu.Unlock()
+		return e.rt.ToValue(id)
+	}
+
+	t := &scriptTimer{
+		info: TimerInfo{
+			ID:       id,
+			Interval: interval,
+			DelayMs:  delay.Milliseconds(),
+			Created:  time.Now(),
+			Origin:   e.currentSource,
+		},
+		fn: fn,
+		rt: e.rt,
+	}
+	if len(call.Arguments) > 2 {
+		t.args = append([]goja.Value(nil), call.Arguments[2:]...)
+	}
+	if e.activeSession != "" {
+		t.info.SessionID = e.activeSession
+	}
+
+	fire := func(*goja.Runtime) {
+		// An interval whose last run is still queued skips this tick. The event loop must not
+		// block, so a run that does not fit in a full job queue is dropped.
+		if !t.queued.CompareAndSwap(false, true) {
+			return
+		}
+		select {
+		case e.jobs <- EvalJob{timer: t, SessionID: t.info.SessionID, Source: repository.SourceScheduler}:
+		default:
+			t.queued.Store(false)
+			log.Warn().Int64("timerID", t.info.ID).Str("origin", t.info.Origin).Msg("Job queue full, skipping timer run")
+		}
+	}
+
+	e.mu.Lock()
+	if e.timers == nil {
+		e.timers = make(map[int64]*scriptTimer)
+	}
+	e.timers[id] = t
+	if e.replicating {
+		e.replicatedTimers = append(e.replicatedTimers, id)
+	}
+	if interval {
+		t.interval = root.loop.SetInterval(fire, delay)
+	} else {
+		t.timeout = root.loop.SetTimeout(fire, delay)
+	}
+	e.mu.Unlock()
+
+	log.Debug().Int64("timerID", id).Bool("interval", interval).Dur("delay", delay).Msg("Timer set")
+	return e.rt.ToValue(id)
+}
+
+// clearTimer implements clearTimeout(id) and clearInterval(id). A handle of a timer set by
+// replicated code in a pool runtime clears the primary's copy of that timer.
+func (e *Engine) clearTimer(id goja.Value) {
+	if id == nil || goja.IsUndefined(id) || goja.IsNull(id) {
+		return
+	}
+	timerID := id.ToInteger()
+
+	e.mu.RLock()
+	index, mirrored := e.mirroredTimers[timerID]
+	e.mu.RUnlock()
+	if !mirrored {
+		e.cancelTimer(timerID)
+		return
+	}
+
+	root := e.root()
+	root.mu.RLock()
+	if index >= len(root.replicatedTimers) {
+		root.mu.RUnlock()
+		return
+	}
+	timerID = root.replicatedTimers[index]
+	root.mu.RUnlock()
+	root.cancelTimer(timerID)
+}
+
+// CancelTimer stops a timer of any runtime of the pool; it reports whether the timer existed
+func (e *Engine) CancelTimer(id int64) bool {
+	for _, runtime := range e.root().runtimes() {
+		if runtime.cancelTimer(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelTimer stops a timer of this runtime; it reports whether the timer existed
+func (e *Engine) cancelTimer(id int64) bool {
+	e.mu.Lock()
+	t, ok := e.timers[id]
+	delete(e.timers, id)
+	e.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	loop := e.root().loop
+	if t.interval != nil {
+		loop.ClearInterval(t.interval)
+	} else {
+		loop.ClearTimeout(t.timeout)
+	}
+	log.Debug().Int64("timerID", id).Msg("Timer cleared")
+	return true
+}
+
+// Timers lists the active timers of every runtime of the pool, oldest first
+func (e *Engine) Timers() []TimerInfo {
+	timers := []TimerInfo{}
+	for _, runtime := range e.root().runtimes() {
+		runtime.mu.RLock()
+		for _, t := range runtime.timers {
+			timers = append(timers, t.info)
+		}
+		runtime.mu.RUnlock()
+	}
+	sort.Slice(timers, func(i, j int) bool {
+		return timers[i].ID < timers[j].ID
+	})
+	return timers
+}
+
+// runtimes returns the primary runtime followed by its replicas
+func (e *Engine) runtimes() []*Engine {
+	return append([]*Engine{e}, e.replicas...)
+}
+
+// runTimer runs the callback of a fired timer. Only the dispatcher calls it.
+func (e *Engine) runTimer(t *scriptTimer) error {
+	t.queued.Store(false)
+
+	e.mu.Lock()
+	_, active := e.timers[t.info.ID]
+	if active && !t.info.Interval {
+		delete(e.timers, t.info.ID)
+	}
+	e.mu.Unlock()
+	if !active {
+		// Cleared while the run was queued
+		return nil
+	}
+
+	if t.info.SessionID != "" {
+		e.mu.RLock()
+		session, ok := e.sessions[t.info.SessionID]
+		e.mu.RUnlock()
+		if !ok || session.rt != t.rt {
+			e.cancelTimer(t.info.ID)
+			log.Debug().Int64("timerID", t.info.ID).Str("sessionID", t.info.SessionID).Msg("Dropped timer of closed session")
+			return nil
+		}
+		defer e.enterSession(t.info.SessionID)()
+	}
+
+	stopTimeout := e.interruptAfter(e.jobTimeout(EvalJob{}))
+	_, err := t.fn(goja.Undefined(), t.args...)
+	stopTimeout()
+	err = e.limitError(err)
+
+	e.mu.Lock()
+	t.info.Runs++
+	t.info.LastError = ""
+	if err != nil {
+		t.info.LastError = err.Error()
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		log.Error().Err(err).Int64("timerID", t.info.ID).Str("origin", t.info.Origin).Msg("Timer callback failed")
+	}
+	return err
+}
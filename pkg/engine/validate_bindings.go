@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// setupValidateBindings installs the validate global:
+//
+//	validate(schema, data)          // {valid: bool, errors: [{path, message}]}
+//	validate.assert(schema, data)   // throws with every error listed
+//	app.post('/users', validate.body(userSchema, (req, res) => { ... }));
+//	app.get('/search', validate.query({properties: {page: {type: 'integer'}}}, handler));
+//	app.put('/users/:id', validate({params: idSchema, body: userSchema}), handler);
+//
+// The wrapped handlers answer 400 with {error, errors} when the request does not match.
+// validate() with only schemas is middleware checking the params, query and body at once;
+// its errors say which part they are in.
+func (e *Engine) setupValidateBindings() {
+	validate, ok := e.rt.ToValue(e.jsValidate).(*goja.Object)
+	if !ok {
+		log.Error().Msg("Failed to create validate binding")
+		return
+	}
+
+	for name, fn := range map[string]interface{}{
+		"assert": e.jsValidateAssert,
+		"body":   e.validateRequestPart("body"),
+		"query":  e.validateRequestPart("query"),
+		"params": e.validateRequestPart("params"),
+	} {
+		if err := validate.Set(name, fn); err != nil {
+			log.Error().Err(err).Str("function", name).Msg("Failed to set validate function")
+		}
+	}
+
+	if err := e.rt.Set("validate", validate); err != nil {
+		log.Error().Err(err).Msg("Failed to set validate binding")
+	}
+}
+
+// jsValidate implements validate(schema, data), and validate({params, query, body}) which
+// returns middleware
+func (e *Engine) jsValidate(call goja.FunctionCall) goja.Value {
+	schema, ok := call.Argument(0).Export().(map[string]interface{})
+	if !ok {
+		panic(e.rt.NewTypeError("validate() takes a JSON Schema object"))
+	}
+	if len(call.Arguments) == 1 {
+		return e.rt.ToValue(e.validateRequestMiddleware(schema))
+	}
+
+	errors := validateSchema(schema, call.Argument(1).Export())
+	return e.rt.ToValue(map[string]interface{}{
+		"valid":  len(errors) == 0,
+		"errors": errors,
+	})
+}
+
+func (e *Engine) jsValidateAssert(schema map[string]interface{}, data interface{}) {
+	if errors := validateSchema(schema, data); len(errors) > 0 {
+		panic(e.rt.NewGoError(fmt.Errorf("validation failed: %s", formatValidationErrors(errors))))
+	}
+}
+
+// formatValidationErrors joins errors into one readable line, e.g. "/name: is required; /age: must be >= 0"
+func formatValidationErrors(errors []ValidationError) string {
+	parts := make([]string, len(errors))
+	for i, err := range errors {
+		path := err.Path
+		if path == "" {
+			path = "(root)"
+		}
+		parts[i] = path + ": " + err.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+// validateRequestPart returns validate.body / validate.query: a function wrapping a route
+// handler so it only runs when that part of the request matches the schema
+func (e *Engine) validateRequestPart(part string) func(schema map[string]interface{}, handler goja.Callable) func(goja.FunctionCall) goja.Value {
+	return func(schema map[string]interface{}, handler goja.Callable) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			req, _ := call.Argument(0).Export().(*ExpressRequest)
+			res, _ := call.Argument(1).Export().(*ExpressResponse)
+			if req == nil || res == nil {
+				panic(e.rt.NewTypeError("validate." + part + " must wrap a route handler"))
+			}
+
+			if errors := validateSchema(schema, requestPart(req, part, schema)); len(errors) > 0 {
+				e.rejectRequest(res, "Invalid request "+part, formatValidationErrors(errors), errors)
+				return goja.Undefined()
+			}
+
+			result, err := handler(call.This, call.Arguments...)
+			if err != nil {
+				panic(err)
+			}
+			return result
+		}
+	}
+}
+
+// requestValidationError is an error of validate() middleware, which checks several parts
+type requestValidationError struct {
+	In string `json:"in"` // params, query or body
+	ValidationError
+}
+
+// validateRequestMiddleware returns the middleware of validate({params, query, body}): it
+// answers 400 with the errors of every part, or lets the chain continue
+func (e *Engine) validateRequestMiddleware(schemas map[string]interface{}) func(goja.FunctionCall) goja.Value {
+	parts := []string{"params", "query", "body"}
+	found := false
+	for key, value := range schemas {
+		if key != "params" && key != "query" && key != "body" {
+			panic(e.rt.NewTypeError(fmt.Sprintf("validate(schemas) takes schemas for params, query and body, got %q; use validate(schema, data) to check a value", key)))
+		}
+		if _, ok := value.(map[string]interface{}); !ok {
+			panic(e.rt.NewTypeError(fmt.Sprintf("validate(): the %s schema must be an object", key)))
+		}
+		found = true
+	}
+	if !found {
+		panic(e.rt.NewTypeError("validate(schemas) needs a schema for params, query or body"))
+	}
+
+	return func(call goja.FunctionCall) goja.Value {
+		req, _ := call.Argument(0).Export().(*ExpressRequest)
+		res, _ := call.Argument(1).Export().(*ExpressResponse)
+		if req == nil || res == nil {
+			panic(e.rt.NewTypeError("validate(schemas) is route middleware"))
+		}
+
+		var errors []requestValidationError
+		var messages []string
+		for _, part := range parts {
+			schema, ok := schemas[part].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			partErrors := validateSchema(schema, requestPart(req, part, schema))
+			for _, err := range partErrors {
+				errors = append(errors, requestValidationError{In: part, ValidationError: err})
+			}
+			if len(partErrors) > 0 {
+				messages = append(messages, part+" "+formatValidationErrors(partErrors))
+			}
+		}
+		if len(errors) > 0 {
+			e.rejectRequest(res, "Invalid request", strings.Join(messages, "; "), errors)
+		}
+		return goja.Undefined()
+	}
+}
+
+// requestPart returns the part of a request a schema applies to. Query values and params
+// are strings, so they are converted to the types the schema declares first; converted
+// query values replace the request's, params stay strings.
+func requestPart(req *ExpressRequest, part string, schema map[string]interface{}) interface{} {
+	switch part {
+	case "query":
+		req.Query = coerceQueryValues(schema, req.Query)
+		return req.Query
+	case "params":
+		params := make(map[string]interface{}, len(req.Params))
+		for key, value := range req.Params {
+			params[key] = value
+		}
+		return coerceQueryValues(schema, params)
+	default:
+		return req.Body
+	}
+}
+
+// rejectRequest answers 400 with the validation errors of a request and logs them
+func (e *Engine) rejectRequest(res *ExpressResponse, message, details string, errors interface{}) {
+	if e.currentReqID != "" {
+		e.reqLogger.AddLog(e.currentReqID, "warn", message+": "+details, errors)
+	}
+	if err := res.Status(http.StatusBadRequest).Json(map[string]interface{}{
+		"error":  message,
+		"errors": errors,
+	}); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+}
+
+// coerceQueryValues converts query string values to the number, integer or boolean types the
+// schema declares for them, so "?page=2" validates against {type: 'integer'} and the handler
+// receives a number. Values that do not parse are left alone for validation to report.
+func coerceQueryValues(schema map[string]interface{}, query map[string]interface{}) map[string]interface{} {
+	properties, _ := schema["properties"].(map[string]interface{})
+	if len(properties) == 0 {
+		return query
+	}
+
+	coerced := make(map[string]interface{}, len(query))
+	for key, value := range query {
+		coerced[key] = value
+		s, ok := value.(string)
+		if !ok {
+			continue
+		}
+		propSchema, _ := properties[key].(map[string]interface{})
+		for _, t := range schemaTypes(propSchema["type"]) {
+			if t == "string" {
+				break
+			}
+			if converted, ok := coerceRequestValue(t, s); ok {
+				coerced[key] = converted
+				break
+			}
+		}
+	}
+	return coerced
+}
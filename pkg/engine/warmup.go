@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Route warm-up
+//
+// With warm-up enabled (jesus serve --warm-up), every GET route a script registers is called
+// once right after the script is loaded, at startup or through the script files API, so a
+// handler that throws on its first request is reported before traffic reaches it. Warm-up
+// requests run in a sandbox:
+//
+//   - the response goes to a recorder and is thrown away,
+//   - db.exec() and statement.run() throw instead of writing; routes that write are reported
+//     as skipped, not as failed,
+//   - the request is neither logged nor stored as execution,
+//   - the request carries the X-Warm-Up header, so handlers can skip other side effects.
+//
+// Routes with required path parameters cannot be called without made-up values and routes
+// requiring an API key would run without the check they rely on; both are skipped. The
+// sandbox covers the handler until it returns; work an async handler does after its first
+// await runs unsandboxed.
+
+// WarmUpHeader is set on the requests of a warm-up
+const WarmUpHeader = "X-Warm-Up"
+
+// errWarmUpWrite is thrown by the database writes of warm-up requests
+var errWarmUpWrite = errors.New("database writes are not run during warm-up requests")
+
+// warmUpTimeout is how long a warm-up request may run
+const warmUpTimeout = 5 * time.Second
+
+// WarmUpResult is the outcome of the warm-up request of a route
+type WarmUpResult struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`              // Route path as registered
+	URL        string `json:"url,omitempty"`     // Path the request was sent to
+	Status     int    `json:"status,omitempty"`  // Status of the recorded response
+	Error      string `json:"error,omitempty"`   // Error the handler threw or the 5xx status it answered with
+	Skipped    string `json:"skipped,omitempty"` // Why the route was not called
+	DurationMs int64  `json:"durationMs"`
+}
+
+// Failed reports whether the route failed its warm-up
+func (r WarmUpResult) Failed() bool {
+	return r.Error != ""
+}
+
+// SetWarmUp enables calling the GET routes of scripts once they are loaded, see WarmUp
+func (e *Engine) SetWarmUp(enabled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.warmUp = enabled
+}
+
+// WarmUpEnabled reports whether scripts have their GET routes warmed up once loaded
+func (e *Engine) WarmUpEnabled() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.warmUp
+}
+
+// WarmUp calls each GET route registered by a script file once, or each GET route for
+// script "", in the warm-up sandbox and returns the outcomes ordered by path. Failures are
+// logged. The dispatcher must be running.
+func (e *Engine) WarmUp(script string) []WarmUpResult {
+	root := e.root()
+	key := ""
+	if script != "" {
+		key = scriptKey(script)
+	}
+
+	root.mu.RLock()
+	var handlers []*HandlerInfo
+	for _, methods := range root.handlers {
+		if handler, ok := methods[http.MethodGet]; ok && (key == "" || handler.script == key) {
+			handlers = append(handlers, handler)
+		}
+	}
+	root.mu.RUnlock()
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].Doc.Path < handlers[j].Doc.Path })
+
+	results := make([]WarmUpResult, 0, len(handlers))
+	for _, handler := range handlers {
+		result := e.warmUpRoute(handler)
+		switch {
+		case result.Failed():
+			log.Warn().Str("route", "GET "+result.Path).Int("status", result.Status).Str("error", result.Error).Msg("Route failed its warm-up request")
+		case result.Skipped != "":
+			log.Debug().Str("route", "GET "+result.Path).Str("reason", result.Skipped).Msg("Skipped route warm-up")
+		default:
+			log.Debug().Str("route", "GET "+result.Path).Int("status", result.Status).Msg("Route warmed up")
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// warmUpRoute sends the warm-up request of a GET route
+func (e *Engine) warmUpRoute(handler *HandlerInfo) WarmUpResult {
+	result := WarmUpResult{Method: http.MethodGet, Path: handler.Doc.Path}
+	if handler.route != nil {
+		result.Path = handler.route.path
+	}
+	if handler.Middleware != nil && handler.Middleware.Auth != "" {
+		result.Skipped = "requires an API key"
+		return result
+	}
+	url, ok := warmUpURL(handler.route)
+	if !ok {
+		result.Skipped = "has required path parameters"
+		return result
+	}
+	result.URL = url
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set(WarmUpHeader, "1")
+	recorder := httptest.NewRecorder()
+	done := make(chan error, 1)
+	start := time.Now()
+	e.SubmitJob(EvalJob{
+		Handler: handler,
+		W:       recorder,
+		R:       req,
+		Done:    done,
+		Timeout: warmUpTimeout,
+		warmUp:  true,
+	})
+
+	select {
+	case err := <-done:
+		result.Status = recorder.Code
+		if err != nil && strings.Contains(err.Error(), errWarmUpWrite.Error()) ||
+			recorder.Code >= http.StatusInternalServerError && strings.Contains(recorder.Body.String(), errWarmUpWrite.Error()) {
+			result.Skipped = "writes to the database"
+			result.Status = 0
+		} else if err != nil {
+			result.Error = err.Error()
+		} else if recorder.Code >= http.StatusInternalServerError {
+			result.Error = "answered " + http.StatusText(recorder.Code)
+		}
+	case <-time.After(2 * warmUpTimeout):
+		result.Error = "no response within " + (2 * warmUpTimeout).String()
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// warmUpURL returns the shortest path a route pattern matches: its literal segments, with
+// optional parameters and wildcards left out. It fails for patterns with required parameters.
+func warmUpURL(pattern *routePattern) (string, bool) {
+	if pattern == nil {
+		return "", false
+	}
+	var parts []string
+	for _, segment := range pattern.segments {
+		switch segment.kind {
+		case segmentLiteral:
+			parts = append(parts, segment.literal)
+		case segmentParam, segmentRegexp:
+			return "", false
+		}
+	}
+	url := "/" + strings.Join(parts, "/")
+	if _, ok := pattern.match(url); !ok {
+		return "", false
+	}
+	return url, true
+}
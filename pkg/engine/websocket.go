@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/dop251/goja"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+// WebSockets
+//
+// app.ws(path, handler) registers a WebSocket route. The JS web server upgrades matching
+// requests and calls handler(socket, req) on the dispatcher of the primary runtime. The
+// socket delivers incoming messages to its onMessage callbacks and reports the end of the
+// connection to its onClose callbacks; both run on the dispatcher like any other job.
+
+// WebSocketMethod is the method WebSocket routes are registered under
+const WebSocketMethod = "WS"
+
+// maxWebSocketMessage is the largest message a client may send
+const maxWebSocketMessage = 1 << 20
+
+// scriptSocket is a WebSocket connection handed to JavaScript
+type scriptSocket struct {
+	id     string
+	conn   *websocket.Conn
+	origin string // Route of the connection, for timers and logs
+	sendMu sync.Mutex
+	closed atomic.Bool
+
+	// Callbacks are only touched by the dispatcher
+	onMessage []goja.Callable
+	onClose   []goja.Callable
+}
+
+// appWs registers a WebSocket route handler (Express.js style)
+func (e *Engine) appWs(path string, handler goja.Value, args ...goja.Value) {
+	e.registerHandler(WebSocketMethod, path, handler, args...)
+}
+
+// IsWebSocketRequest reports whether a request asks to upgrade to a WebSocket
+func IsWebSocketRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// ServeWebSocket upgrades a request for a WebSocket route and serves the connection until
+// either side closes it
+func (e *Engine) ServeWebSocket(handler *HandlerInfo, w http.ResponseWriter, r *http.Request) {
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			return checkWebSocketOrigin(handler, req)
+		},
+		Handler: func(conn *websocket.Conn) {
+			e.serveSocket(handler, conn, r)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkWebSocketOrigin only accepts browsers on the same host unless the origins option of
+// the route lists the origin, or contains "*"
+func checkWebSocketOrigin(handler *HandlerInfo, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser
+		return nil
+	}
+	if origins, ok := handler.Options["origins"].([]interface{}); ok {
+		for _, allowed := range origins {
+			if allowed == "*" || allowed == origin {
+				return nil
+			}
+		}
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// serveSocket runs the JavaScript handler of a new connection and forwards its messages
+func (e *Engine) serveSocket(handler *HandlerInfo, conn *websocket.Conn, r *http.Request) {
+	conn.MaxPayloadBytes = maxWebSocketMessage
+	socket := &scriptSocket{
+		id:     uuid.New().String(),
+		conn:   conn,
+		origin: WebSocketMethod + " " + handler.Doc.Path,
+	}
+	log.Debug().Str("socketID", socket.id).Str("path", r.URL.Path).Msg("WebSocket connected")
+
+	rt := e.rt
+	e.queueSocketTask(socket, func() error {
+		reqObj := e.createExpressRequestObject(r)
+		reqObj.Params = routeParams(handler, r.URL.Path)
+		_, err := handler.Fn(goja.Undefined(), e.socketObject(socket), rt.ToValue(reqObj))
+		if err != nil {
+			socket.close()
+		}
+		return err
+	})
+
+	for {
+		var message string
+		if err := websocket.Message.Receive(conn, &message); err != nil {
+			break
+		}
+		e.queueSocketTask(socket, func() error {
+			return socket.call(socket.onMessage, rt.ToValue(message))
+		})
+	}
+
+	socket.closed.Store(true)
+	e.queueSocketTask(socket, func() error {
+		return socket.call(socket.onClose)
+	})
+	log.Debug().Str("socketID", socket.id).Msg("WebSocket disconnected")
+}
+
+// queueSocketTask runs fn on the dispatcher of the runtime serving the socket
+func (e *Engine) queueSocketTask(socket *scriptSocket, fn func() error) {
+	e.jobs <- EvalJob{
+		async:  &asyncTask{rt: e.rt, origin: socket.origin, run: fn},
+		Source: socket.origin,
+	}
+}
+
+// call runs callbacks one after the other and returns the first error
+func (s *scriptSocket) call(callbacks []goja.Callable, args ...goja.Value) error {
+	for _, callback := range callbacks {
+		if _, err := callback(goja.Undefined(), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// socketObject builds the JavaScript object of a socket
+func (e *Engine) socketObject(socket *scriptSocket) *goja.Object {
+	obj := e.rt.NewObject()
+	set := func(name string, value interface{}) {
+		if err := obj.Set(name, value); err != nil {
+			log.Error().Err(err).Str("property", name).Msg("Failed to set socket property")
+		}
+	}
+
+	set("id", socket.id)
+	set("send", func(data goja.Value) {
+		if err := socket.send(data.Export()); err != nil {
+			panic(e.rt.NewGoError(err))
+		}
+	})
+	set("close", socket.close)
+	set("isOpen", func() bool {
+		return !socket.closed.Load()
+	})
+	set("onMessage", func(fn goja.Value) {
+		callback, ok := goja.AssertFunction(fn)
+		if !ok {
+			panic(e.rt.NewTypeError("onMessage: callback must be a function"))
+		}
+		socket.onMessage = append(socket.onMessage, callback)
+	})
+	set("onClose", func(fn goja.Value) {
+		callback, ok := goja.AssertFunction(fn)
+		if !ok {
+			panic(e.rt.NewTypeError("onClose: callback must be a function"))
+		}
+		socket.onClose = append(socket.onClose, callback)
+	})
+	return obj
+}
+
+// send writes a message: strings as they are, byte arrays as binary frames and everything
+// else as JSON
+func (s *scriptSocket) send(data interface{}) error {
+	if s.closed.Load() {
+		return fmt.Errorf("socket %s is closed", s.id)
+	}
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	switch v := data.(type) {
+	case string:
+		return websocket.Message.Send(s.conn, v)
+	case []byte:
+		return websocket.Message.Send(s.conn, v)
+	case goja.ArrayBuffer:
+		return websocket.Message.Send(s.conn, v.Bytes())
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+		return websocket.Message.Send(s.conn, string(encoded))
+	}
+}
+
+// close closes the connection; the onClose callbacks run once the reader notices
+func (s *scriptSocket) close() {
+	if s.closed.Swap(true) {
+		return
+	}
+	if err := s.conn.Close(); err != nil {
+		log.Debug().Err(err).Str("socketID", s.id).Msg("Failed to close WebSocket")
+	}
+}
@@ -0,0 +1,429 @@
+package engine
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/rs/zerolog/log"
+)
+
+// XML elements map to JavaScript objects: attributes are stored under "@name" keys, text
+// next to child elements or attributes under "#text", and repeated child elements become
+// arrays. Elements with only text become strings.
+const (
+	xmlAttrPrefix = "@"
+	xmlTextKey    = "#text"
+)
+
+// SOAP envelope namespaces by version
+var soapNamespaces = map[string]string{
+	"1.1": "http://schemas.xmlsoap.org/soap/envelope/",
+	"1.2": "http://www.w3.org/2003/05/soap-envelope",
+}
+
+// setupXMLBindings installs the xml global:
+//
+//	xml.parse('<user id="1"><name>Ann</name></user>')  // {user: {"@id": "1", name: "Ann"}}
+//	xml.build({user: {"@id": 1, name: "Ann"}})        // '<?xml version="1.0" encoding="UTF-8"?>\n<user id="1">...'
+//	xml.soap.envelope({"m:GetPrice": {"@xmlns:m": "urn:prices", item: "apple"}})
+//	xml.soap.parse(responseBody)                      // {header, body, fault}
+func (e *Engine) setupXMLBindings() {
+	if err := e.rt.Set("xml", map[string]interface{}{
+		"parse": e.xmlParse,
+		"build": e.xmlBuild,
+		"soap": map[string]interface{}{
+			"envelope": e.soapEnvelope,
+			"parse":    e.soapParse,
+		},
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to set xml binding")
+	}
+}
+
+// xmlNode collects an element while parsing; children keep their document order
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// xmlParse parses an XML document into nested objects. Namespace prefixes are dropped from
+// element names unless {namespaces: true} is given, in which case names keep their prefix URI
+// as "{uri}local".
+func (e *Engine) xmlParse(input string, args ...map[string]interface{}) goja.Value {
+	keepNamespaces := false
+	if len(args) > 0 {
+		keepNamespaces, _ = args[0]["namespaces"].(bool)
+	}
+
+	root, err := parseXMLTree(input, keepNamespaces)
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("xml.parse: %w", err)))
+	}
+
+	result := e.rt.NewObject()
+	if err := result.Set(root.name, e.xmlNodeValue(root)); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	return result
+}
+
+func parseXMLTree(input string, keepNamespaces bool) (*xmlNode, error) {
+	decoder := xml.NewDecoder(strings.NewReader(input))
+
+	elementName := func(name xml.Name) string {
+		if keepNamespaces && name.Space != "" {
+			return "{" + name.Space + "}" + name.Local
+		}
+		return name.Local
+	}
+
+	var stack []*xmlNode
+	var root *xmlNode
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: elementName(t.Name), attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	return root, nil
+}
+
+func (e *Engine) xmlNodeValue(node *xmlNode) goja.Value {
+	text := strings.TrimSpace(node.text.String())
+	if len(node.attrs) == 0 && len(node.children) == 0 {
+		return e.rt.ToValue(text)
+	}
+
+	obj := e.rt.NewObject()
+	set := func(key string, value interface{}) {
+		if err := obj.Set(key, value); err != nil {
+			panic(e.rt.NewGoError(err))
+		}
+	}
+
+	for _, attr := range node.attrs {
+		name := attr.Name.Local
+		if attr.Name.Space == "xmlns" {
+			name = "xmlns:" + name
+		} else if attr.Name.Space != "" {
+			// The decoder resolves prefixes to URIs; keep the local name for readability
+			name = attr.Name.Local
+		}
+		set(xmlAttrPrefix+name, attr.Value)
+	}
+
+	// Group children by name, preserving the order in which names first appear
+	var order []string
+	groups := make(map[string][]goja.Value)
+	for _, child := range node.children {
+		if _, ok := groups[child.name]; !ok {
+			order = append(order, child.name)
+		}
+		groups[child.name] = append(groups[child.name], e.xmlNodeValue(child))
+	}
+	for _, name := range order {
+		values := groups[name]
+		if len(values) == 1 {
+			set(name, values[0])
+		} else {
+			set(name, e.rt.NewArray(toInterfaces(values)...))
+		}
+	}
+
+	if text != "" {
+		set(xmlTextKey, text)
+	}
+	return obj
+}
+
+func toInterfaces(values []goja.Value) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// xmlBuild serializes an object with a single root key to XML. Options: declaration (default
+// true) and indent (default two spaces, "" for compact output).
+func (e *Engine) xmlBuild(value goja.Value, args ...map[string]interface{}) string {
+	declaration := true
+	indent := "  "
+	if len(args) > 0 && args[0] != nil {
+		if v, ok := args[0]["declaration"].(bool); ok {
+			declaration = v
+		}
+		if v, ok := args[0]["indent"].(string); ok {
+			indent = v
+		}
+	}
+
+	obj, ok := value.(*goja.Object)
+	if !ok || isJSArray(obj) {
+		panic(e.rt.NewTypeError("xml.build expects an object with a single root element"))
+	}
+	keys := obj.Keys()
+	if len(keys) != 1 {
+		panic(e.rt.NewTypeError(fmt.Sprintf("xml.build expects exactly one root element, got %d", len(keys))))
+	}
+
+	var b strings.Builder
+	if declaration {
+		b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+		if indent != "" {
+			b.WriteString("\n")
+		}
+	}
+	e.writeXMLElement(&b, keys[0], obj.Get(keys[0]), indent, 0)
+	return b.String()
+}
+
+func isJSArray(obj *goja.Object) bool {
+	return obj.ClassName() == "Array"
+}
+
+func (e *Engine) writeXMLElement(b *strings.Builder, name string, value goja.Value, indent string, depth int) {
+	// Arrays repeat the element
+	if obj, ok := value.(*goja.Object); ok && isJSArray(obj) {
+		for _, key := range obj.Keys() {
+			e.writeXMLElement(b, name, obj.Get(key), indent, depth)
+		}
+		return
+	}
+
+	pad := strings.Repeat(indent, depth)
+	newline := ""
+	if indent != "" {
+		newline = "\n"
+	}
+
+	b.WriteString(pad + "<" + name)
+
+	obj, isObject := value.(*goja.Object)
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		b.WriteString("/>" + newline)
+		return
+	}
+	if !isObject || obj.ClassName() == "Date" {
+		text := value.String()
+		if t, ok := value.Export().(time.Time); ok {
+			text = t.UTC().Format(time.RFC3339)
+		}
+		b.WriteString(">")
+		escapeXML(b, text)
+		b.WriteString("</" + name + ">" + newline)
+		return
+	}
+
+	var children []string
+	text := ""
+	for _, key := range obj.Keys() {
+		switch {
+		case strings.HasPrefix(key, xmlAttrPrefix):
+			b.WriteString(" " + strings.TrimPrefix(key, xmlAttrPrefix) + `="`)
+			escapeXML(b, obj.Get(key).String())
+			b.WriteString(`"`)
+		case key == xmlTextKey:
+			text = obj.Get(key).String()
+		default:
+			children = append(children, key)
+		}
+	}
+
+	if len(children) == 0 {
+		if text == "" {
+			b.WriteString("/>" + newline)
+			return
+		}
+		b.WriteString(">")
+		escapeXML(b, text)
+		b.WriteString("</" + name + ">" + newline)
+		return
+	}
+
+	b.WriteString(">" + newline)
+	if text != "" {
+		b.WriteString(pad + indent)
+		escapeXML(b, text)
+		b.WriteString(newline)
+	}
+	for _, key := range children {
+		e.writeXMLElement(b, key, obj.Get(key), indent, depth+1)
+	}
+	b.WriteString(pad + "</" + name + ">" + newline)
+}
+
+func escapeXML(b *strings.Builder, s string) {
+	// xml.EscapeText only fails if the writer fails, which strings.Builder never does
+	_ = xml.EscapeText(b, []byte(s))
+}
+
+// soapEnvelope wraps a body (and optional {header}) in a SOAP envelope. Options: version
+// ("1.1" or "1.2", default "1.1"), header and indent.
+func (e *Engine) soapEnvelope(body goja.Value, args ...goja.Value) string {
+	version := "1.1"
+	var header goja.Value
+	buildOptions := map[string]interface{}{}
+	if len(args) > 0 && !goja.IsUndefined(args[0]) && !goja.IsNull(args[0]) {
+		// Read the options as a JavaScript object so the header keeps its key order
+		opts := args[0].ToObject(e.rt)
+		if v := opts.Get("version"); v != nil && !goja.IsUndefined(v) {
+			version = v.String()
+		}
+		if v := opts.Get("header"); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			header = v
+		}
+		if v := opts.Get("indent"); v != nil && !goja.IsUndefined(v) {
+			buildOptions["indent"] = v.String()
+		}
+	}
+
+	namespace, ok := soapNamespaces[version]
+	if !ok {
+		panic(e.rt.NewGoError(fmt.Errorf("unsupported SOAP version %q", version)))
+	}
+
+	envelope := e.rt.NewObject()
+	content := e.rt.NewObject()
+	if err := content.Set("@xmlns:soap", namespace); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	if header != nil {
+		if err := content.Set("soap:Header", header); err != nil {
+			panic(e.rt.NewGoError(err))
+		}
+	}
+	if err := content.Set("soap:Body", body); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+	if err := envelope.Set("soap:Envelope", content); err != nil {
+		panic(e.rt.NewGoError(err))
+	}
+
+	return e.xmlBuild(envelope, buildOptions)
+}
+
+// soapParse parses a SOAP response into {header, body, fault}. fault is null unless the body
+// contains a SOAP fault, in which case it holds {code, message, detail}.
+func (e *Engine) soapParse(input string) map[string]interface{} {
+	root, err := parseXMLTree(input, false)
+	if err != nil {
+		panic(e.rt.NewGoError(fmt.Errorf("xml.soap.parse: %w", err)))
+	}
+	if root.name != "Envelope" {
+		panic(e.rt.NewGoError(fmt.Errorf("xml.soap.parse: expected a SOAP Envelope, got <%s>", root.name)))
+	}
+
+	result := map[string]interface{}{
+		"header": nil,
+		"body":   nil,
+		"fault":  nil,
+	}
+	for _, child := range root.children {
+		switch child.name {
+		case "Header":
+			result["header"] = e.xmlNodeValue(child)
+		case "Body":
+			result["body"] = e.xmlNodeValue(child)
+			for _, part := range child.children {
+				if part.name == "Fault" {
+					result["fault"] = soapFault(part)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// soapFault extracts the code and message of a SOAP 1.1 or 1.2 fault
+func soapFault(fault *xmlNode) map[string]interface{} {
+	result := map[string]interface{}{"code": "", "message": "", "detail": ""}
+	find := func(node *xmlNode, names ...string) *xmlNode {
+		for _, name := range names {
+			next := (*xmlNode)(nil)
+			for _, child := range node.children {
+				if child.name == name {
+					next = child
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			node = next
+		}
+		return node
+	}
+	text := func(node *xmlNode) string {
+		if node == nil {
+			return ""
+		}
+		return strings.TrimSpace(node.text.String())
+	}
+
+	// SOAP 1.1: faultcode/faultstring/detail; SOAP 1.2: Code/Value, Reason/Text, Detail
+	if code := text(find(fault, "faultcode")); code != "" {
+		result["code"] = code
+	} else {
+		result["code"] = text(find(fault, "Code", "Value"))
+	}
+	if message := text(find(fault, "faultstring")); message != "" {
+		result["message"] = message
+	} else {
+		result["message"] = text(find(fault, "Reason", "Text"))
+	}
+	if detail := find(fault, "detail"); detail != nil {
+		result["detail"] = xmlInnerText(detail)
+	} else if detail := find(fault, "Detail"); detail != nil {
+		result["detail"] = xmlInnerText(detail)
+	}
+	return result
+}
+
+// xmlInnerText concatenates the text of a node and its descendants
+func xmlInnerText(node *xmlNode) string {
+	parts := []string{}
+	if text := strings.TrimSpace(node.text.String()); text != "" {
+		parts = append(parts, text)
+	}
+	for _, child := range node.children {
+		if text := xmlInnerText(child); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// persistPrefix starts the names of the files executed code is saved to
+const persistPrefix = "mcp-exec-"
+
+// saveCode saves executed code to the persist directory with PersistDir and returns the file,
+// "" when the code is not saved. Files are named by time, so the oldest sort first, and the
+// session ID keeps executions within the same second apart.
+func (p ExecutionPolicy) saveCode(code, sessionID string) string {
+	if p.Persist != PersistDir {
+		return ""
+	}
+	dir := p.PersistDir
+	if dir == "" {
+		dir = defaultPersistDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("Failed to create directory for executed code")
+		return ""
+	}
+
+	suffix := sessionID
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("%s%s-%s.js", persistPrefix, time.Now().Format("2006-01-02T15-04-05.000"), suffix))
+	if err := os.WriteFile(filename, []byte(code), 0644); err != nil {
+		log.Warn().Err(err).Str("filename", filename).Msg("Failed to save code to file")
+		return ""
+	}
+	log.Info().Str("filename", filename).Msg("Saved executed code to file")
+
+	p.pruneSavedCode(dir)
+	return filename
+}
+
+// pruneSavedCode removes the oldest saved files beyond PersistKeep
+func (p ExecutionPolicy) pruneSavedCode(dir string) {
+	if p.PersistKeep <= 0 {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(dir, persistPrefix+"*.js"))
+	if err != nil || len(files) <= p.PersistKeep {
+		return
+	}
+	sort.Strings(files)
+	for _, file := range files[:len(files)-p.PersistKeep] {
+		if err := os.Remove(file); err != nil {
+			log.Warn().Err(err).Str("filename", file).Msg("Failed to remove old executed code")
+		}
+	}
+}
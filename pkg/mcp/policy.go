@@ -0,0 +1,249 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/spf13/cobra"
+)
+
+// Where the code of executeJS is kept
+const (
+	PersistOff = "off" // Nowhere: no file and no execution record
+	PersistDB  = "db"  // In the execution record of the system database only
+	PersistDir = "dir" // In the execution record and as mcp-exec-*.js file of PersistDir
+)
+
+// defaultPersistDir is where executed code is saved with PersistDir
+const defaultPersistDir = "scripts"
+
+// ExecutionPolicy controls what the MCP tools may do, so operators can run the server for
+// untrusted models. It is configured through the flags of the mcp start command.
+type ExecutionPolicy struct {
+	ReadOnlyDB       bool          `json:"readOnlyDb"`    // Open the application database read-only
+	AllowRoutes      bool          `json:"allowRoutes"`   // Allow executed code to register routes and file handlers
+	ExecutionTimeout time.Duration `json:"-"`             // Interrupt executions running longer than this (0 = no limit)
+	MaxOutputSize    int           `json:"maxOutputSize"` // Maximum bytes of result and console output returned (0 = no limit)
+	Persist          string        `json:"persist"`       // Where executed code is kept, one of the Persist* constants
+	PersistDir       string        `json:"persistDir"`    // Directory executed code is saved to with PersistDir
+	PersistKeep      int           `json:"persistKeep"`   // Number of saved files kept in PersistDir, the oldest are removed (0 = all)
+
+	AllowHosts      []string `json:"allowHosts,omitempty"` // Hosts fetch() and HTTP.* may reach (empty = all not denied)
+	DenyHosts       []string `json:"denyHosts,omitempty"`  // Hosts fetch() and HTTP.* may not reach
+	BlockPrivateIPs bool     `json:"blockPrivateIps"`      // Block requests to loopback, private and link-local addresses
+
+	SourcePolicies map[string]engine.ExecutionPolicy `json:"sourcePolicies,omitempty"` // Restrictions by execution source, from --source-policies
+}
+
+// MarshalJSON reports the execution timeout as a duration string such as "30s"
+func (p ExecutionPolicy) MarshalJSON() ([]byte, error) {
+	type policy ExecutionPolicy
+	return json.Marshal(struct {
+		policy
+		ExecutionTimeout string `json:"executionTimeout"`
+	}{policy(p), p.ExecutionTimeout.String()})
+}
+
+// DefaultExecutionPolicy returns the unrestricted policy used when no flags are given
+func DefaultExecutionPolicy() ExecutionPolicy {
+	return ExecutionPolicy{
+		AllowRoutes:      true,
+		ExecutionTimeout: defaultExecutionTimeout,
+		Persist:          PersistDir,
+		PersistDir:       defaultPersistDir,
+	}
+}
+
+// addExecutionPolicyFlags adds the execution policy flags to the MCP command
+func addExecutionPolicyFlags(cmd *cobra.Command) {
+	defaults := DefaultExecutionPolicy()
+	cmd.Flags().Bool("read-only-db", defaults.ReadOnlyDB, "Open the application database read-only (the database file must already exist)")
+	cmd.Flags().Bool("allow-routes", defaults.AllowRoutes, "Allow executed code to register HTTP routes and file handlers")
+	cmd.Flags().Duration("execution-timeout", defaults.ExecutionTimeout, "Interrupt JavaScript executions running longer than this (0 for no limit)")
+	cmd.Flags().Int("max-output-size", defaults.MaxOutputSize, "Maximum bytes of result and console output returned to the client (0 for no limit)")
+	cmd.Flags().String("persist", defaults.Persist, "Where executed code is kept: off (nowhere), db (execution record only) or dir (execution record and a file in --persist-dir)")
+	cmd.Flags().String("persist-dir", defaults.PersistDir, "Directory executed code is saved to with --persist dir")
+	cmd.Flags().Int("persist-keep", defaults.PersistKeep, "Number of saved code files kept in --persist-dir, removing the oldest (0 to keep all)")
+	cmd.Flags().Bool("persist-scripts", true, "Save executed code to the scripts directory")
+	_ = cmd.Flags().MarkDeprecated("persist-scripts", "use --persist db instead of --persist-scripts=false")
+	cmd.Flags().String("allow-hosts", "", "Comma-separated hosts fetch() and HTTP.* may reach: api.example.com, *.example.com or a CIDR (empty for all)")
+	cmd.Flags().String("deny-hosts", "", "Comma-separated hosts fetch() and HTTP.* may not reach, even if allowed")
+	cmd.Flags().Bool("block-private-ips", defaults.BlockPrivateIPs, "Block requests to loopback, private and link-local addresses such as 169.254.169.254, unless allowed with --allow-hosts")
+	cmd.Flags().String("source-policies", "", "YAML file restricting what code of each execution source (mcp, mcp-file, file, ...) may do, as with serve --source-policies")
+}
+
+// executionPolicyFromFlags parses the execution policy from the command flags, which
+// embeddable passes as their string values
+func executionPolicyFromFlags(flags map[string]interface{}) (ExecutionPolicy, error) {
+	policy := DefaultExecutionPolicy()
+
+	for name, target := range map[string]*bool{
+		"read-only-db":      &policy.ReadOnlyDB,
+		"allow-routes":      &policy.AllowRoutes,
+		"block-private-ips": &policy.BlockPrivateIPs,
+	} {
+		if value, ok := flags[name].(string); ok && value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return policy, fmt.Errorf("invalid value for --%s: %w", name, err)
+			}
+			*target = parsed
+		}
+	}
+
+	if value, ok := flags["execution-timeout"].(string); ok && value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid value for --execution-timeout: %w", err)
+		}
+		policy.ExecutionTimeout = timeout
+	}
+
+	if value, ok := flags["max-output-size"].(string); ok && value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid value for --max-output-size: %w", err)
+		}
+		policy.MaxOutputSize = size
+	}
+
+	if value, ok := flags["persist"].(string); ok && value != "" {
+		switch persist := strings.ToLower(value); persist {
+		case PersistOff, PersistDB, PersistDir:
+			policy.Persist = persist
+		default:
+			return policy, fmt.Errorf("invalid value for --persist: %q, use off, db or dir", value)
+		}
+	}
+	// --persist-scripts=false predates --persist and kept the execution record only
+	if value, ok := flags["persist-scripts"].(string); ok && value != "" {
+		persistScripts, err := strconv.ParseBool(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid value for --persist-scripts: %w", err)
+		}
+		if !persistScripts && policy.Persist == PersistDir {
+			policy.Persist = PersistDB
+		}
+	}
+	if value, ok := flags["persist-dir"].(string); ok && value != "" {
+		policy.PersistDir = value
+	}
+	if value, ok := flags["persist-keep"].(string); ok && value != "" {
+		keep, err := strconv.Atoi(value)
+		if err != nil || keep < 0 {
+			return policy, fmt.Errorf("invalid value for --persist-keep: %q, use a number of files or 0 for all", value)
+		}
+		policy.PersistKeep = keep
+	}
+
+	for name, target := range map[string]*[]string{
+		"allow-hosts": &policy.AllowHosts,
+		"deny-hosts":  &policy.DenyHosts,
+	} {
+		if value, ok := flags[name].(string); ok {
+			*target = splitHostList(value)
+		}
+	}
+	if err := policy.networkPolicy().Validate(); err != nil {
+		return policy, err
+	}
+
+	if value, ok := flags["source-policies"].(string); ok && value != "" {
+		sourcePolicies, err := engine.LoadSourcePolicies(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid value for --source-policies: %w", err)
+		}
+		policy.SourcePolicies = sourcePolicies
+	}
+
+	return policy, nil
+}
+
+// splitHostList splits a comma-separated list of hosts
+func splitHostList(value string) []string {
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// networkPolicy returns the outbound network policy of executed code
+func (p ExecutionPolicy) networkPolicy() engine.NetworkPolicy {
+	return engine.NetworkPolicy{
+		AllowHosts:   p.AllowHosts,
+		DenyHosts:    p.DenyHosts,
+		BlockPrivate: p.BlockPrivateIPs,
+	}
+}
+
+// engineJobPolicy translates the policy into the restrictions enforced by the engine
+func (p ExecutionPolicy) engineJobPolicy() engine.ExecutionPolicy {
+	return engine.ExecutionPolicy{
+		Timeout:    p.ExecutionTimeout,
+		DenyRoutes: !p.AllowRoutes,
+	}
+}
+
+// waitTimeout is how long a tool call waits for the engine. It leaves the engine time
+// to interrupt the execution and report the timeout itself.
+func (p ExecutionPolicy) waitTimeout() time.Duration {
+	if p.ExecutionTimeout <= 0 {
+		return math.MaxInt64
+	}
+	return p.ExecutionTimeout + 5*time.Second
+}
+
+// limitOutput truncates the result and console output to the maximum output size.
+// The result is truncated first; the console gets whatever budget remains.
+func (p ExecutionPolicy) limitOutput(value interface{}, consoleLog []string) (interface{}, []string) {
+	if p.MaxOutputSize <= 0 {
+		return value, consoleLog
+	}
+
+	budget := p.MaxOutputSize
+	if value != nil {
+		text, isString := value.(string)
+		if !isString {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return value, consoleLog
+			}
+			text = string(data)
+		}
+		if len(text) > budget {
+			return engine.TruncateOutput(text, budget), []string{fmt.Sprintf("[console output omitted: %d lines]", len(consoleLog))}
+		}
+		budget -= len(text)
+	}
+
+	limited := make([]string, 0, len(consoleLog))
+	for i, line := range consoleLog {
+		if len(line) > budget {
+			limited = append(limited, engine.TruncateOutput(line, budget))
+			if omitted := len(consoleLog) - i - 1; omitted > 0 {
+				limited = append(limited, fmt.Sprintf("[%d more console lines omitted]", omitted))
+			}
+			return value, limited
+		}
+		budget -= len(line)
+		limited = append(limited, line)
+	}
+
+	return value, limited
+}
+
+// readOnlyDSN turns a SQLite database path into a read-only connection string. The path is
+// escaped, so a '?' or '#' in it cannot add connection parameters.
+func readOnlyDSN(path string) string {
+	return "file:" + (&url.URL{Path: filepath.ToSlash(path)}).EscapedPath() + "?mode=ro"
+}
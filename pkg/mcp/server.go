@@ -16,12 +16,14 @@ import (
 	"github.com/go-go-golems/jesus/pkg/api"
 	"github.com/go-go-golems/jesus/pkg/doc"
 	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
 	"github.com/go-go-golems/jesus/pkg/web"
 
 	// "github.com/go-go-golems/go-go-mcp/cmd/experiments/jesus/pkg/doc"
 	"github.com/go-go-golems/go-go-mcp/pkg/embeddable"
 	"github.com/go-go-golems/go-go-mcp/pkg/protocol"
 	"github.com/google/uuid"
+	mcpserver "github.com/mark3labs/mcp-go/server"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
@@ -33,6 +35,7 @@ type WebServerMCP struct {
 	AdminPort       int
 	JSBaseURL       string
 	AdminBaseURL    string
+	Policy          ExecutionPolicy
 	jsHTTPServer    *http.Server
 	adminHTTPServer *http.Server
 	shutdownOnce    sync.Once
@@ -41,6 +44,15 @@ type WebServerMCP struct {
 // GlobalWebServerMCP is the global MCP server instance
 var GlobalWebServerMCP *WebServerMCP
 
+const (
+	// serverName and serverVersion identify the MCP server to clients
+	serverName    = "JavaScript Web Server MCP"
+	serverVersion = "1.0.0"
+
+	// defaultExecutionTimeout bounds how long a tool call may run JavaScript code
+	defaultExecutionTimeout = 30 * time.Second
+)
+
 // findFreePort finds a free port starting from the given port
 func findFreePort(startPort int) (int, error) {
 	for port := startPort; port < startPort+100; port++ {
@@ -70,6 +82,7 @@ func NewWebServerMCP() (*WebServerMCP, error) {
 		AdminPort:    adminPort,
 		JSBaseURL:    fmt.Sprintf("http://localhost:%d", jsPort),
 		AdminBaseURL: fmt.Sprintf("http://localhost:%d", adminPort),
+		Policy:       DefaultExecutionPolicy(),
 	}
 
 	return server, nil
@@ -147,13 +160,18 @@ Admin console: %s/admin/logs
 
 	// Add MCP command - expose JavaScript execution as MCP tool
 	err = embeddable.AddMCPCommand(rootCmd,
-		embeddable.WithName("JavaScript Web Server MCP"),
-		embeddable.WithVersion("1.0.0"),
+		embeddable.WithName(serverName),
+		embeddable.WithVersion(serverVersion),
 		embeddable.WithServerDescription("Execute JavaScript code and create dynamic web applications"),
 		embeddable.WithTool("executeJS", executeJSHandler,
 			embeddable.WithDescription(toolDescription),
 			embeddable.WithStringArg("code", "JavaScript code to execute", true),
 		),
+		embeddable.WithTool("getServerInfo", getServerInfoHandler,
+			embeddable.WithDescription(`Return the server version, ports and URLs, the number of registered routes, the available JavaScript bindings and require() modules (db, fetch, AI availability, ...) and execution limits.
+
+Call this before writing code to adapt to the capabilities of this server instead of assuming them.`),
+		),
 		// embeddable.WithTool("executeJSFile", executeJSFileHandler,
 		// 	embeddable.WithDescription("Execute JavaScript code from a file on the filesystem"),
 		// 	embeddable.WithStringArg("absolutePath", "Absolute path to the JavaScript file to execute", true),
@@ -163,6 +181,8 @@ Admin console: %s/admin/logs
 			cmd.Flags().String("admin-port", "9090", "HTTP port for admin/system interface")
 			cmd.Flags().String("app-db", "jesus.db", "SQLite database path for application data (accessible via db.* in JavaScript)")
 			cmd.Flags().String("system-db", "jesus-system.db", "SQLite database path for system operations (execution logs, request logs)")
+			cmd.Flags().Bool("sync-state", false, "Share globalState and route registrations with a serve instance using the same system database")
+			addExecutionPolicyFlags(cmd)
 			return nil
 		}),
 		embeddable.WithHooks(&embeddable.Hooks{
@@ -181,6 +201,14 @@ func onServerStart(ctx context.Context) error {
 	transport := "stdio"
 
 	if flags, ok := embeddable.GetCommandFlags(ctx); ok {
+		// Parse the policy now, tool invocations don't carry the command flags
+		policy, err := executionPolicyFromFlags(flags)
+		if err != nil {
+			return err
+		}
+		GlobalWebServerMCP.Policy = policy
+		log.Info().Interface("policy", policy).Msg("MCP execution policy configured")
+
 		if transportFlag, exists := flags["transport"]; exists {
 			if transportStr, isString := transportFlag.(string); isString && transportStr != "" {
 				transport = transportStr
@@ -208,6 +236,7 @@ func initializeJSEngineForMCP(ctx context.Context) error {
 	systemDBPath := "jesus-system.db"         // default
 	jsPort := GlobalWebServerMCP.JSPort       // default from NewWebServerMCP
 	adminPort := GlobalWebServerMCP.AdminPort // default from NewWebServerMCP
+	syncState := false
 
 	if flags, ok := embeddable.GetCommandFlags(ctx); ok {
 		if appDB, exists := flags["app-db"]; exists {
@@ -227,6 +256,13 @@ func initializeJSEngineForMCP(ctx context.Context) error {
 				}
 			}
 		}
+		if syncFlag, isString := flags["sync-state"].(string); isString && syncFlag != "" {
+			parsed, err := strconv.ParseBool(syncFlag)
+			if err != nil {
+				return fmt.Errorf("invalid value for --sync-state: %w", err)
+			}
+			syncState = parsed
+		}
 		if adminPortFlag, exists := flags["admin-port"]; exists {
 			if adminPortStr, isString := adminPortFlag.(string); isString {
 				if parsed, err := strconv.Atoi(adminPortStr); err == nil {
@@ -242,8 +278,26 @@ func initializeJSEngineForMCP(ctx context.Context) error {
 	GlobalWebServerMCP.JSBaseURL = fmt.Sprintf("http://localhost:%d", jsPort)
 	GlobalWebServerMCP.AdminBaseURL = fmt.Sprintf("http://localhost:%d", adminPort)
 
-	log.Info().Str("appDB", appDBPath).Str("systemDB", systemDBPath).Msg("Initializing JS engine with databases")
-	GlobalWebServerMCP.JSEngine = engine.NewEngine(appDBPath, systemDBPath)
+	appDSN := appDBPath
+	if GlobalWebServerMCP.Policy.ReadOnlyDB {
+		appDSN = readOnlyDSN(appDBPath)
+	}
+
+	log.Info().Str("appDB", appDBPath).Str("systemDB", systemDBPath).Bool("readOnly", GlobalWebServerMCP.Policy.ReadOnlyDB).Msg("Initializing JS engine with databases")
+	GlobalWebServerMCP.JSEngine = engine.NewEngine(appDSN, systemDBPath)
+	if err := GlobalWebServerMCP.JSEngine.EnableEventLog(engine.DefaultEventRetention); err != nil {
+		log.Warn().Err(err).Msg("Failed to enable the event log")
+	}
+	httpConfig := engine.DefaultHTTPClientConfig()
+	httpConfig.Network = GlobalWebServerMCP.Policy.networkPolicy()
+	if err := GlobalWebServerMCP.JSEngine.SetHTTPClientConfig(httpConfig); err != nil {
+		return fmt.Errorf("failed to configure the HTTP client: %w", err)
+	}
+	if policies := GlobalWebServerMCP.Policy.SourcePolicies; policies != nil {
+		if err := GlobalWebServerMCP.JSEngine.SetSourcePolicies(policies); err != nil {
+			return fmt.Errorf("failed to configure source policies: %w", err)
+		}
+	}
 	if err := GlobalWebServerMCP.JSEngine.Init("bootstrap.js"); err != nil {
 		log.Warn().Err(err).Msg("Failed to load bootstrap.js")
 	}
@@ -252,6 +306,12 @@ func initializeJSEngineForMCP(ctx context.Context) error {
 	go GlobalWebServerMCP.JSEngine.StartDispatcher()
 	time.Sleep(100 * time.Millisecond)
 
+	if syncState {
+		if err := GlobalWebServerMCP.JSEngine.EnableStateSync(); err != nil {
+			return fmt.Errorf("failed to enable state sync: %w", err)
+		}
+	}
+
 	// Start separate HTTP servers in background
 
 	// Start JavaScript web server
@@ -307,6 +367,16 @@ func initializeJSEngineForMCP(ctx context.Context) error {
 	return nil
 }
 
+// mcpClientName returns the name the MCP client announced on initialize, recorded as the
+// actor of its executions
+func mcpClientName(ctx context.Context) string {
+	session, ok := mcpserver.ClientSessionFromContext(ctx).(mcpserver.SessionWithClientInfo)
+	if !ok {
+		return ""
+	}
+	return session.GetClientInfo().Name
+}
+
 // executeJSHandler is the MCP tool handler for executing JavaScript code
 func executeJSHandler(ctx context.Context, args map[string]interface{}) (*protocol.ToolResult, error) {
 	// Initialize engine if not already done (for test-tool command)
@@ -318,6 +388,11 @@ func executeJSHandler(ctx context.Context, args map[string]interface{}) (*protoc
 		}
 	}
 
+	if err := GlobalWebServerMCP.JSEngine.CheckWritable(); err != nil {
+		return protocol.NewErrorToolResult(protocol.NewTextContent(
+			fmt.Sprintf("Code execution is disabled: %v", err))), nil
+	}
+
 	// Extract code from arguments
 	code, ok := args["code"].(string)
 	if !ok {
@@ -327,21 +402,10 @@ func executeJSHandler(ctx context.Context, args map[string]interface{}) (*protoc
 	// Generate session ID for tracking
 	sessionID := uuid.New().String()
 
-	// Save the code to a file with timestamp
-	timestamp := time.Now().Format("2006-01-02T15-04-05")
-	filename := fmt.Sprintf("scripts/mcp-exec-%s.js", timestamp)
-
-	// Ensure scripts directory exists
-	if err := os.MkdirAll("scripts", 0755); err != nil {
-		log.Warn().Err(err).Msg("Failed to create scripts directory")
-	} else {
-		// Save the code to file
-		if err := os.WriteFile(filename, []byte(code), 0644); err != nil {
-			log.Warn().Err(err).Str("filename", filename).Msg("Failed to save code to file")
-		} else {
-			log.Info().Str("filename", filename).Msg("Saved executed code to file")
-		}
-	}
+	policy := GlobalWebServerMCP.Policy
+
+	// Save the code according to the persistence policy
+	filename := policy.saveCode(code, sessionID)
 
 	// Execute the code with result capture
 	done := make(chan error, 1)
@@ -351,7 +415,11 @@ func executeJSHandler(ctx context.Context, args map[string]interface{}) (*protoc
 		Done:      done,
 		Result:    resultChan,
 		SessionID: sessionID,
-		Source:    "mcp",
+		Source:    repository.SourceMCP,
+		Actor:     mcpClientName(ctx),
+		Policy:    policy.engineJobPolicy(),
+		SavedAs:   filename,
+		NoRecord:  policy.Persist == PersistOff,
 	}
 
 	GlobalWebServerMCP.JSEngine.SubmitJob(job)
@@ -371,12 +439,20 @@ func executeJSHandler(ctx context.Context, args map[string]interface{}) (*protoc
 		}
 
 		// Create response with result and console output
+		resultValue, consoleLog := policy.limitOutput(result.Value, result.ConsoleLog)
+		changed := "It changed nothing."
+		if result.Changes != nil {
+			changed = "It " + result.Changes.Summary() + "."
+		}
 		responseData := map[string]interface{}{
 			"success":    true,
-			"result":     result.Value,
-			"consoleLog": result.ConsoleLog,
+			"result":     resultValue,
+			"consoleLog": consoleLog,
 			"savedAs":    filename,
-			"message":    fmt.Sprintf("JavaScript code executed successfully. Check %s for any web endpoints created. Monitor execution at %s/admin/logs", GlobalWebServerMCP.JSBaseURL, GlobalWebServerMCP.AdminBaseURL),
+			"message":    fmt.Sprintf("JavaScript code executed successfully. %s Check %s for any web endpoints created. Monitor execution at %s/admin/logs", changed, GlobalWebServerMCP.JSBaseURL, GlobalWebServerMCP.AdminBaseURL),
+		}
+		if result.Changes != nil {
+			responseData["changes"] = result.Changes
 		}
 
 		// Convert to JSON
@@ -390,11 +466,57 @@ func executeJSHandler(ctx context.Context, args map[string]interface{}) (*protoc
 			protocol.WithText(string(jsonData)),
 		), nil
 
-	case <-time.After(30 * time.Second):
+	case <-time.After(policy.waitTimeout()):
 		return protocol.NewErrorToolResult(protocol.NewTextContent("Timeout waiting for JavaScript execution")), nil
 	}
 }
 
+// ServerInfo describes the running server for MCP clients
+type ServerInfo struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"version"`
+	JSPort       int                 `json:"jsPort"`
+	AdminPort    int                 `json:"adminPort"`
+	JSBaseURL    string              `json:"jsBaseUrl"`
+	AdminBaseURL string              `json:"adminBaseUrl"`
+	AdminConsole string              `json:"adminConsole"`
+	Capabilities engine.Capabilities `json:"capabilities"`
+	Policy       ExecutionPolicy     `json:"policy"`
+}
+
+// getServerInfoHandler is the MCP tool handler reporting server capabilities
+func getServerInfoHandler(ctx context.Context, args map[string]interface{}) (*protocol.ToolResult, error) {
+	if GlobalWebServerMCP == nil || GlobalWebServerMCP.JSEngine == nil {
+		log.Info().Msg("JavaScript engine not initialized, initializing now")
+		if err := initializeJSEngineForMCP(ctx); err != nil {
+			return protocol.NewErrorToolResult(protocol.NewTextContent(
+				fmt.Sprintf("Failed to initialize JavaScript engine: %v", err))), nil
+		}
+	}
+
+	info := ServerInfo{
+		Name:         serverName,
+		Version:      serverVersion,
+		JSPort:       GlobalWebServerMCP.JSPort,
+		AdminPort:    GlobalWebServerMCP.AdminPort,
+		JSBaseURL:    GlobalWebServerMCP.JSBaseURL,
+		AdminBaseURL: GlobalWebServerMCP.AdminBaseURL,
+		AdminConsole: GlobalWebServerMCP.AdminBaseURL + "/admin/logs",
+		Capabilities: GlobalWebServerMCP.JSEngine.GetCapabilities(),
+		Policy:       GlobalWebServerMCP.Policy,
+	}
+
+	jsonData, err := json.Marshal(info)
+	if err != nil {
+		return protocol.NewErrorToolResult(protocol.NewTextContent(
+			fmt.Sprintf("Failed to marshal server info: %v", err))), nil
+	}
+
+	return protocol.NewToolResult(
+		protocol.WithText(string(jsonData)),
+	), nil
+}
+
 // executeJSFileHandler is the MCP tool handler for executing JavaScript files
 // FIXME: This function is currently unused but may be needed for future MCP tool functionality
 // nolint:unused
@@ -408,6 +530,11 @@ func executeJSFileHandler(ctx context.Context, args map[string]interface{}) (*pr
 		}
 	}
 
+	if err := GlobalWebServerMCP.JSEngine.CheckWritable(); err != nil {
+		return protocol.NewErrorToolResult(protocol.NewTextContent(
+			fmt.Sprintf("Code execution is disabled: %v", err))), nil
+	}
+
 	// Extract file path from arguments
 	filePath, ok := args["absolutePath"].(string)
 	if !ok {
@@ -446,7 +573,8 @@ func executeJSFileHandler(ctx context.Context, args map[string]interface{}) (*pr
 		Done:      done,
 		Result:    resultChan,
 		SessionID: sessionID,
-		Source:    "mcp-file",
+		Source:    repository.SourceMCPFile,
+		Actor:     mcpClientName(ctx),
 	}
 
 	GlobalWebServerMCP.JSEngine.SubmitJob(job)
@@ -485,7 +613,7 @@ func executeJSFileHandler(ctx context.Context, args map[string]interface{}) (*pr
 			protocol.WithText(string(jsonData)),
 		), nil
 
-	case <-time.After(30 * time.Second):
+	case <-time.After(defaultExecutionTimeout):
 		return protocol.NewErrorToolResult(protocol.NewTextContent("Timeout waiting for JavaScript execution")), nil
 	}
 }
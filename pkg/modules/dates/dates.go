@@ -0,0 +1,388 @@
+package dates
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	_ "time/tzdata" // Embedded zone database so time zones work on hosts without one
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/go-go-goja/modules"
+)
+
+// m is the dates module. It holds no state; every function works on the values it is given.
+type m struct{}
+
+var _ modules.NativeModule = (*m)(nil)
+
+func (m) Name() string { return "dates" }
+
+// Doc returns the documentation for the module.
+func (m) Doc() string {
+	return `
+The dates module provides time zone aware date handling backed by Go's time package.
+Dates are accepted as Date objects, timestamps in milliseconds or date strings, and
+returned as Date objects. Layouts are Go reference layouts ("2006-01-02 15:04") or one
+of the names iso, date, time, datetime, rfc1123, kitchen.
+
+Functions:
+  now(): Returns the current date.
+  parse(value, [layout], [timeZone]): Parses a string. Without a layout, ISO 8601 and
+    common formats are tried. The time zone applies to strings without an offset.
+    Example: require('dates').parse('2024-03-05 09:30:00', 'datetime', 'Europe/Berlin');
+  format(date, [layout], [timeZone]): Formats a date, in UTC unless a time zone is given.
+    Example: require('dates').format(new Date(), 'date', 'America/New_York');
+  add(date, amount): Adds a duration string ("1h30m") or {years, months, weeks, days,
+    hours, minutes, seconds, milliseconds}. Negative values subtract.
+  diff(a, b, [unit]): Returns a - b in milliseconds, seconds, minutes, hours, days,
+    weeks, months or years. Months and years count whole calendar units.
+  startOf(date, unit, [timeZone]) / endOf(date, unit, [timeZone]): Start or end of the
+    minute, hour, day, week (Monday), month or year in a time zone.
+  inTimeZone(date, timeZone): Returns the wall clock fields of a date in a time zone:
+    {year, month, day, hour, minute, second, weekday, zone, offset, iso}.
+  isValidTimeZone(name): Reports whether name is a known IANA time zone.
+`
+}
+
+var namedLayouts = map[string]string{
+	"iso":      time.RFC3339,
+	"date":     "2006-01-02",
+	"time":     "15:04:05",
+	"datetime": "2006-01-02 15:04:05",
+	"rfc1123":  time.RFC1123,
+	"kitchen":  time.Kitchen,
+}
+
+// parseLayouts are tried in order when parse() is called without a layout
+var parseLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+func resolveLayout(layout string) string {
+	if named, ok := namedLayouts[strings.ToLower(layout)]; ok {
+		return named
+	}
+	return layout
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// Loader attaches the exported Go functions to the JS `exports` object.
+func (mod m) Loader(vm *goja.Runtime, moduleObj *goja.Object) {
+	exports := moduleObj.Get("exports").(*goja.Object)
+
+	toDate := func(t time.Time) goja.Value {
+		date, err := vm.New(vm.Get("Date"), vm.ToValue(t.UnixMilli()))
+		if err != nil {
+			panic(err)
+		}
+		return date
+	}
+
+	toTime := func(value goja.Value) (time.Time, error) {
+		if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+			return time.Time{}, fmt.Errorf("date is required")
+		}
+		switch v := value.Export().(type) {
+		case time.Time:
+			return v, nil
+		case int64:
+			return time.UnixMilli(v), nil
+		case float64:
+			return time.UnixMilli(int64(v)), nil
+		case string:
+			return parseString(v, "", time.UTC)
+		}
+		return time.Time{}, fmt.Errorf("cannot convert %s to a date", value.String())
+	}
+
+	modules.SetExport(exports, mod.Name(), "now", func() goja.Value {
+		return toDate(time.Now())
+	})
+
+	// parse(value, [layout], [timeZone]) -> Date | throws
+	modules.SetExport(exports, mod.Name(), "parse", func(value string, args ...string) (goja.Value, error) {
+		layout, tz := optionalArgs(args)
+		loc, err := loadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseString(value, layout, loc)
+		if err != nil {
+			return nil, err
+		}
+		return toDate(t), nil
+	})
+
+	// format(date, [layout], [timeZone]) -> string | throws
+	modules.SetExport(exports, mod.Name(), "format", func(value goja.Value, args ...string) (string, error) {
+		t, err := toTime(value)
+		if err != nil {
+			return "", err
+		}
+		layout, tz := optionalArgs(args)
+		if layout == "" {
+			layout = "iso"
+		}
+		loc, err := loadLocation(tz)
+		if err != nil {
+			return "", err
+		}
+		return t.In(loc).Format(resolveLayout(layout)), nil
+	})
+
+	// add(date, "1h30m" | {days: 1, ...}) -> Date | throws
+	modules.SetExport(exports, mod.Name(), "add", func(value goja.Value, amount goja.Value) (goja.Value, error) {
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		t, err = addAmount(t, amount.Export())
+		if err != nil {
+			return nil, err
+		}
+		return toDate(t), nil
+	})
+
+	// diff(a, b, [unit]) -> number | throws
+	modules.SetExport(exports, mod.Name(), "diff", func(a, b goja.Value, args ...string) (float64, error) {
+		ta, err := toTime(a)
+		if err != nil {
+			return 0, err
+		}
+		tb, err := toTime(b)
+		if err != nil {
+			return 0, err
+		}
+		unit, _ := optionalArgs(args)
+		return diff(ta, tb, unit)
+	})
+
+	// startOf(date, unit, [timeZone]) -> Date | throws
+	modules.SetExport(exports, mod.Name(), "startOf", func(value goja.Value, unit string, args ...string) (goja.Value, error) {
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		tz, _ := optionalArgs(args)
+		loc, err := loadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		start, err := startOf(t.In(loc), unit)
+		if err != nil {
+			return nil, err
+		}
+		return toDate(start), nil
+	})
+
+	// endOf(date, unit, [timeZone]) -> Date | throws; the last millisecond of the unit
+	modules.SetExport(exports, mod.Name(), "endOf", func(value goja.Value, unit string, args ...string) (goja.Value, error) {
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		tz, _ := optionalArgs(args)
+		loc, err := loadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		start, err := startOf(t.In(loc), unit)
+		if err != nil {
+			return nil, err
+		}
+		next, err := addUnit(start, unit)
+		if err != nil {
+			return nil, err
+		}
+		return toDate(next.Add(-time.Millisecond)), nil
+	})
+
+	// inTimeZone(date, timeZone) -> {year, month, ...} | throws
+	modules.SetExport(exports, mod.Name(), "inTimeZone", func(value goja.Value, tz string) (map[string]interface{}, error) {
+		t, err := toTime(value)
+		if err != nil {
+			return nil, err
+		}
+		loc, err := loadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		local := t.In(loc)
+		zone, offset := local.Zone()
+		return map[string]interface{}{
+			"year":    local.Year(),
+			"month":   int(local.Month()),
+			"day":     local.Day(),
+			"hour":    local.Hour(),
+			"minute":  local.Minute(),
+			"second":  local.Second(),
+			"weekday": local.Weekday().String(),
+			"zone":    zone,
+			"offset":  offset / 60, // minutes east of UTC
+			"iso":     local.Format(time.RFC3339),
+		}, nil
+	})
+
+	modules.SetExport(exports, mod.Name(), "isValidTimeZone", func(name string) bool {
+		_, err := time.LoadLocation(name)
+		return name != "" && err == nil
+	})
+}
+
+// optionalArgs returns the first two optional string arguments
+func optionalArgs(args []string) (string, string) {
+	var first, second string
+	if len(args) > 0 {
+		first = args[0]
+	}
+	if len(args) > 1 {
+		second = args[1]
+	}
+	return first, second
+}
+
+func parseString(value, layout string, loc *time.Location) (time.Time, error) {
+	if layout != "" {
+		t, err := time.ParseInLocation(resolveLayout(layout), value, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse %q with layout %q: %w", value, layout, err)
+		}
+		return t, nil
+	}
+	for _, candidate := range parseLayouts {
+		if t, err := time.ParseInLocation(candidate, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q", value)
+}
+
+func addAmount(t time.Time, amount interface{}) (time.Time, error) {
+	switch v := amount.(type) {
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q: %w", v, err)
+		}
+		return t.Add(d), nil
+	case map[string]interface{}:
+		fields := make(map[string]int64, len(v))
+		for key, raw := range v {
+			switch n := raw.(type) {
+			case int64:
+				fields[key] = n
+			case float64:
+				fields[key] = int64(n)
+			default:
+				return time.Time{}, fmt.Errorf("%s must be a number", key)
+			}
+		}
+		t = t.AddDate(int(fields["years"]), int(fields["months"]), int(fields["weeks"]*7+fields["days"]))
+		return t.Add(time.Duration(fields["hours"])*time.Hour +
+			time.Duration(fields["minutes"])*time.Minute +
+			time.Duration(fields["seconds"])*time.Second +
+			time.Duration(fields["milliseconds"])*time.Millisecond), nil
+	}
+	return time.Time{}, fmt.Errorf("amount must be a duration string or an object")
+}
+
+func diff(a, b time.Time, unit string) (float64, error) {
+	d := a.Sub(b)
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "", "millisecond":
+		return float64(d.Milliseconds()), nil
+	case "second":
+		return d.Seconds(), nil
+	case "minute":
+		return d.Minutes(), nil
+	case "hour":
+		return d.Hours(), nil
+	case "day":
+		return d.Hours() / 24, nil
+	case "week":
+		return d.Hours() / (24 * 7), nil
+	case "month":
+		return float64(wholeMonths(a, b)), nil
+	case "year":
+		return math.Trunc(float64(wholeMonths(a, b)) / 12), nil
+	}
+	return 0, fmt.Errorf("unknown unit %q", unit)
+}
+
+// wholeMonths counts the complete calendar months between b and a (negative if a is before b)
+func wholeMonths(a, b time.Time) int {
+	sign := 1
+	if a.Before(b) {
+		a, b = b, a
+		sign = -1
+	}
+	b = b.In(a.Location())
+	months := (a.Year()-b.Year())*12 + int(a.Month()) - int(b.Month())
+	if months > 0 && b.AddDate(0, months, 0).After(a) {
+		months--
+	}
+	return sign * months
+}
+
+func startOf(t time.Time, unit string) (time.Time, error) {
+	y, mo, d := t.Date()
+	loc := t.Location()
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "minute":
+		return t.Truncate(time.Minute), nil
+	case "hour":
+		return time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc), nil
+	case "day":
+		return time.Date(y, mo, d, 0, 0, 0, 0, loc), nil
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7 // days since Monday
+		return time.Date(y, mo, d-offset, 0, 0, 0, 0, loc), nil
+	case "month":
+		return time.Date(y, mo, 1, 0, 0, 0, 0, loc), nil
+	case "year":
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, loc), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown unit %q", unit)
+}
+
+// addUnit advances t by one calendar unit
+func addUnit(t time.Time, unit string) (time.Time, error) {
+	switch strings.TrimSuffix(strings.ToLower(unit), "s") {
+	case "minute":
+		return t.Add(time.Minute), nil
+	case "hour":
+		return t.Add(time.Hour), nil
+	case "day":
+		return t.AddDate(0, 0, 1), nil
+	case "week":
+		return t.AddDate(0, 0, 7), nil
+	case "month":
+		return t.AddDate(0, 1, 0), nil
+	case "year":
+		return t.AddDate(1, 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown unit %q", unit)
+}
+
+// Each module registers itself during package initialization.
+func init() {
+	modules.Register(&m{})
+}
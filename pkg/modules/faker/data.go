@@ -0,0 +1,80 @@
+package faker
+
+// Word lists the generators pick from. They are small on purpose: enough variety for seed
+// data and load tests, without a locale database in the binary.
+
+var firstNames = []string{
+	"Alice", "Amara", "Ben", "Carla", "Chen", "Daniel", "Diego", "Elena", "Emma", "Fatima",
+	"Felix", "Grace", "Hannah", "Hiro", "Isabel", "Ivan", "Jack", "Julia", "Kai", "Karin",
+	"Leo", "Lina", "Lucas", "Maya", "Mateo", "Mia", "Nadia", "Noah", "Olivia", "Omar",
+	"Paula", "Priya", "Quinn", "Rafael", "Rosa", "Sam", "Sara", "Sofia", "Tariq", "Theo",
+	"Uma", "Victor", "Wei", "Yara", "Yusuf", "Zoe", "Anna", "David", "Laura", "Marco",
+}
+
+var lastNames = []string{
+	"Adams", "Almeida", "Becker", "Brown", "Campbell", "Chen", "Costa", "Davis", "Diaz", "Evans",
+	"Fischer", "Garcia", "Gonzalez", "Hall", "Hansen", "Ito", "Jensen", "Johnson", "Kim", "Kowalski",
+	"Lee", "Lopez", "Martin", "Meyer", "Miller", "Moreau", "Nakamura", "Nguyen", "Novak", "Okafor",
+	"Olsen", "Patel", "Perez", "Rossi", "Santos", "Schmidt", "Silva", "Singh", "Smith", "Suzuki",
+	"Taylor", "Thomas", "Walker", "Wang", "Weber", "White", "Wilson", "Wright", "Young", "Zhang",
+}
+
+var streetNames = []string{
+	"Oak", "Maple", "Cedar", "Pine", "Elm", "Birch", "Willow", "Lake", "Hill", "River",
+	"Park", "Church", "Mill", "Station", "Market", "Bridge", "Spring", "Sunset", "Highland", "Meadow",
+}
+
+var streetSuffixes = []string{"Street", "Avenue", "Road", "Lane", "Drive", "Court", "Way", "Boulevard", "Place", "Terrace"}
+
+// cities pairs a city with its state or region and country
+var cities = []struct{ city, state, country string }{
+	{"Springfield", "IL", "United States"},
+	{"Portland", "OR", "United States"},
+	{"Austin", "TX", "United States"},
+	{"Denver", "CO", "United States"},
+	{"Boston", "MA", "United States"},
+	{"Seattle", "WA", "United States"},
+	{"Madison", "WI", "United States"},
+	{"Toronto", "ON", "Canada"},
+	{"Vancouver", "BC", "Canada"},
+	{"Manchester", "England", "United Kingdom"},
+	{"Edinburgh", "Scotland", "United Kingdom"},
+	{"Dublin", "Leinster", "Ireland"},
+	{"Berlin", "Berlin", "Germany"},
+	{"Hamburg", "Hamburg", "Germany"},
+	{"Lyon", "Auvergne-Rhône-Alpes", "France"},
+	{"Barcelona", "Catalonia", "Spain"},
+	{"Milan", "Lombardy", "Italy"},
+	{"Rotterdam", "South Holland", "Netherlands"},
+	{"Gothenburg", "Västra Götaland", "Sweden"},
+	{"Melbourne", "VIC", "Australia"},
+	{"Auckland", "Auckland", "New Zealand"},
+	{"Osaka", "Osaka", "Japan"},
+	{"São Paulo", "SP", "Brazil"},
+	{"Cape Town", "Western Cape", "South Africa"},
+}
+
+var emailDomains = []string{"example.com", "example.org", "example.net", "mail.example", "test.example"}
+
+var companyPrefixes = []string{
+	"Acme", "Blue Harbor", "Brightline", "Cobalt", "Evergreen", "Foxglove", "Granite", "Helix",
+	"Ironwood", "Juniper", "Keystone", "Lumen", "Northwind", "Orbit", "Pinnacle", "Redwood",
+	"Silverline", "Summit", "Tidewater", "Vertex",
+}
+
+var companySuffixes = []string{"Inc.", "LLC", "Labs", "Group", "Systems", "Partners", "Studio", "Works", "Co.", "Technologies"}
+
+var jobTitles = []string{
+	"Software Engineer", "Product Manager", "Data Analyst", "Designer", "Account Manager",
+	"Support Specialist", "Marketing Lead", "Sales Representative", "Operations Manager", "QA Engineer",
+	"DevOps Engineer", "Technical Writer", "Recruiter", "Finance Analyst", "Customer Success Manager",
+}
+
+var loremWords = []string{
+	"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do",
+	"eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim",
+	"ad", "minim", "veniam", "quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi", "aliquip",
+	"ex", "ea", "commodo", "consequat", "duis", "aute", "irure", "in", "reprehenderit", "voluptate",
+	"velit", "esse", "cillum", "fugiat", "nulla", "pariatur", "excepteur", "sint", "occaecat", "cupidatat",
+	"non", "proident", "sunt", "culpa", "qui", "officia", "deserunt", "mollit", "anim", "id", "est", "laborum",
+}
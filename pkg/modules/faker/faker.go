@@ -0,0 +1,358 @@
+package faker
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/go-go-goja/modules"
+)
+
+// m is the faker module. Each runtime that requires it gets its own random source.
+type m struct{}
+
+var _ modules.NativeModule = (*m)(nil)
+
+func (m) Name() string { return "faker" }
+
+// Doc returns the documentation for the module.
+func (m) Doc() string {
+	return `
+The faker module generates realistic fake data for seed scripts and load tests. Values
+are random unless seed() was called, after which the same calls return the same values.
+Emails use reserved example domains.
+
+Functions:
+  seed(n): Makes the following values reproducible.
+  firstName(), lastName(), fullName(): Person names.
+  email([name]), username([name]): Derived from a name, a random one if none is given.
+  phone(): A phone number like "+1-555-0142-381".
+  streetAddress(), city(), state(), country(), zipCode(): Address parts.
+  address(): {street, city, state, zipCode, country} of one consistent place.
+  company(), jobTitle(): Company names and job titles.
+  word(), words([n]), sentence([words]), paragraph([sentences]): Lorem ipsum text.
+  number([min], [max]): Integer between min and max, inclusive (default 0 to 100).
+  float([min], [max], [decimals]): Number between min and max (default 0 to 1, 2 decimals).
+  boolean([probability]): true with the given probability (default 0.5).
+  date([from], [to]): Date between two dates (default the past year).
+  uuid(): A version 4 UUID.
+  pick(array), shuffle(array): A random element, or a shuffled copy.
+  user(): {id, firstName, lastName, name, email, username, phone, company, jobTitle, address}.
+  times(n, fn): Array of fn(i) for i from 0 to n - 1.
+    Example: const users = faker.times(100, () => faker.user());
+`
+}
+
+// generator picks fake values from a random source
+type generator struct {
+	rng *rand.Rand
+}
+
+func (g *generator) pick(list []string) string {
+	return list[g.rng.Intn(len(list))]
+}
+
+// between returns an integer in [min, max]
+func (g *generator) between(min, max int) int {
+	if max < min {
+		min, max = max, min
+	}
+	return min + g.rng.Intn(max-min+1)
+}
+
+func (g *generator) fullName() string {
+	return g.pick(firstNames) + " " + g.pick(lastNames)
+}
+
+// handle turns a name into the local part of an email or a username
+func handle(name, separator string) string {
+	var parts []string
+	for _, field := range strings.Fields(strings.ToLower(name)) {
+		field = strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return r
+			}
+			return -1
+		}, field)
+		if field != "" {
+			parts = append(parts, field)
+		}
+	}
+	if len(parts) == 0 {
+		return "user"
+	}
+	return strings.Join(parts, separator)
+}
+
+func (g *generator) email(name string) string {
+	if name == "" {
+		name = g.fullName()
+	}
+	local := handle(name, ".")
+	if g.rng.Intn(2) == 0 {
+		local += strconv.Itoa(g.between(1, 99))
+	}
+	return local + "@" + g.pick(emailDomains)
+}
+
+func (g *generator) username(name string) string {
+	if name == "" {
+		name = g.fullName()
+	}
+	return handle(name, "_") + strconv.Itoa(g.between(1, 999))
+}
+
+// phone uses the 555 exchange reserved for fiction
+func (g *generator) phone() string {
+	return fmt.Sprintf("+1-555-%04d-%03d", g.between(100, 199), g.rng.Intn(1000))
+}
+
+func (g *generator) streetAddress() string {
+	return fmt.Sprintf("%d %s %s", g.between(1, 9999), g.pick(streetNames), g.pick(streetSuffixes))
+}
+
+func (g *generator) zipCode() string {
+	return fmt.Sprintf("%05d", g.between(1000, 99999))
+}
+
+func (g *generator) address() map[string]interface{} {
+	place := cities[g.rng.Intn(len(cities))]
+	return map[string]interface{}{
+		"street":  g.streetAddress(),
+		"city":    place.city,
+		"state":   place.state,
+		"zipCode": g.zipCode(),
+		"country": place.country,
+	}
+}
+
+func (g *generator) company() string {
+	return g.pick(companyPrefixes) + " " + g.pick(companySuffixes)
+}
+
+func (g *generator) words(n int) []string {
+	words := make([]string, n)
+	for i := range words {
+		words[i] = g.pick(loremWords)
+	}
+	return words
+}
+
+func (g *generator) sentence(words int) string {
+	if words <= 0 {
+		words = g.between(6, 12)
+	}
+	s := strings.Join(g.words(words), " ")
+	return strings.ToUpper(s[:1]) + s[1:] + "."
+}
+
+func (g *generator) paragraph(sentences int) string {
+	if sentences <= 0 {
+		sentences = g.between(3, 6)
+	}
+	parts := make([]string, sentences)
+	for i := range parts {
+		parts[i] = g.sentence(0)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (g *generator) uuid() string {
+	var b [16]byte
+	_, _ = g.rng.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // Version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func (g *generator) user() map[string]interface{} {
+	first, last := g.pick(firstNames), g.pick(lastNames)
+	name := first + " " + last
+	return map[string]interface{}{
+		"id":        g.uuid(),
+		"firstName": first,
+		"lastName":  last,
+		"name":      name,
+		"email":     g.email(name),
+		"username":  g.username(name),
+		"phone":     g.phone(),
+		"company":   g.company(),
+		"jobTitle":  g.pick(jobTitles),
+		"address":   g.address(),
+	}
+}
+
+// optionalString returns the first argument, "" if there is none
+func optionalString(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+// optionalFloats returns the arguments given, the defaults for the missing ones
+func optionalFloats(args []float64, defaults ...float64) []float64 {
+	values := append([]float64(nil), defaults...)
+	copy(values, args)
+	return values
+}
+
+// Loader attaches the exported Go functions to the JS `exports` object.
+func (mod m) Loader(vm *goja.Runtime, moduleObj *goja.Object) {
+	exports := moduleObj.Get("exports").(*goja.Object)
+	g := &generator{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+	toTime := func(value goja.Value, fallback time.Time) (time.Time, error) {
+		if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+			return fallback, nil
+		}
+		switch v := value.Export().(type) {
+		case time.Time:
+			return v, nil
+		case int64:
+			return time.UnixMilli(v), nil
+		case float64:
+			return time.UnixMilli(int64(v)), nil
+		case string:
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t, nil
+			}
+			if t, err := time.Parse(time.DateOnly, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot convert %s to a date", value.String())
+	}
+
+	modules.SetExport(exports, mod.Name(), "seed", func(seed int64) {
+		g.rng.Seed(seed)
+	})
+
+	modules.SetExport(exports, mod.Name(), "firstName", func() string { return g.pick(firstNames) })
+	modules.SetExport(exports, mod.Name(), "lastName", func() string { return g.pick(lastNames) })
+	modules.SetExport(exports, mod.Name(), "fullName", g.fullName)
+	modules.SetExport(exports, mod.Name(), "email", func(args ...string) string { return g.email(optionalString(args)) })
+	modules.SetExport(exports, mod.Name(), "username", func(args ...string) string { return g.username(optionalString(args)) })
+	modules.SetExport(exports, mod.Name(), "phone", g.phone)
+
+	modules.SetExport(exports, mod.Name(), "streetAddress", g.streetAddress)
+	modules.SetExport(exports, mod.Name(), "city", func() string { return cities[g.rng.Intn(len(cities))].city })
+	modules.SetExport(exports, mod.Name(), "state", func() string { return cities[g.rng.Intn(len(cities))].state })
+	modules.SetExport(exports, mod.Name(), "country", func() string { return cities[g.rng.Intn(len(cities))].country })
+	modules.SetExport(exports, mod.Name(), "zipCode", g.zipCode)
+	modules.SetExport(exports, mod.Name(), "address", g.address)
+	modules.SetExport(exports, mod.Name(), "company", g.company)
+	modules.SetExport(exports, mod.Name(), "jobTitle", func() string { return g.pick(jobTitles) })
+
+	modules.SetExport(exports, mod.Name(), "word", func() string { return g.pick(loremWords) })
+	modules.SetExport(exports, mod.Name(), "words", func(args ...int) []string {
+		n := 3
+		if len(args) > 0 && args[0] > 0 {
+			n = args[0]
+		}
+		return g.words(n)
+	})
+	modules.SetExport(exports, mod.Name(), "sentence", func(args ...int) string {
+		if len(args) > 0 {
+			return g.sentence(args[0])
+		}
+		return g.sentence(0)
+	})
+	modules.SetExport(exports, mod.Name(), "paragraph", func(args ...int) string {
+		if len(args) > 0 {
+			return g.paragraph(args[0])
+		}
+		return g.paragraph(0)
+	})
+
+	// number([min], [max]) -> integer in [min, max]
+	modules.SetExport(exports, mod.Name(), "number", func(args ...int) int {
+		bounds := []int{0, 100}
+		copy(bounds, args)
+		return g.between(bounds[0], bounds[1])
+	})
+
+	// float([min], [max], [decimals]) -> number in [min, max)
+	modules.SetExport(exports, mod.Name(), "float", func(args ...float64) float64 {
+		values := optionalFloats(args, 0, 1, 2)
+		v := values[0] + g.rng.Float64()*(values[1]-values[0])
+		scale := math.Pow(10, math.Max(0, math.Round(values[2])))
+		return math.Round(v*scale) / scale
+	})
+
+	modules.SetExport(exports, mod.Name(), "boolean", func(args ...float64) bool {
+		return g.rng.Float64() < optionalFloats(args, 0.5)[0]
+	})
+
+	// date([from], [to]) -> Date | throws
+	modules.SetExport(exports, mod.Name(), "date", func(args ...goja.Value) (goja.Value, error) {
+		now := time.Now()
+		var fromValue, toValue goja.Value
+		if len(args) > 0 {
+			fromValue = args[0]
+		}
+		if len(args) > 1 {
+			toValue = args[1]
+		}
+		from, err := toTime(fromValue, now.AddDate(-1, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		to, err := toTime(toValue, now)
+		if err != nil {
+			return nil, err
+		}
+		if to.Before(from) {
+			from, to = to, from
+		}
+		t := from
+		if span := to.UnixMilli() - from.UnixMilli(); span > 0 {
+			t = time.UnixMilli(from.UnixMilli() + g.rng.Int63n(span+1))
+		}
+		return vm.New(vm.Get("Date"), vm.ToValue(t.UnixMilli()))
+	})
+
+	modules.SetExport(exports, mod.Name(), "uuid", g.uuid)
+
+	// pick(array) -> element | throws
+	modules.SetExport(exports, mod.Name(), "pick", func(list []interface{}) (interface{}, error) {
+		if len(list) == 0 {
+			return nil, fmt.Errorf("pick() needs a non-empty array")
+		}
+		return list[g.rng.Intn(len(list))], nil
+	})
+
+	modules.SetExport(exports, mod.Name(), "shuffle", func(list []interface{}) []interface{} {
+		shuffled := append([]interface{}(nil), list...)
+		g.rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+		return shuffled
+	})
+
+	modules.SetExport(exports, mod.Name(), "user", g.user)
+
+	// times(n, fn) -> [fn(0), ..., fn(n - 1)] | throws what fn throws
+	modules.SetExport(exports, mod.Name(), "times", func(n int, fn goja.Callable) ([]goja.Value, error) {
+		if n < 0 {
+			return nil, fmt.Errorf("times() needs a count of at least 0")
+		}
+		values := make([]goja.Value, n)
+		for i := range values {
+			value, err := fn(goja.Undefined(), vm.ToValue(i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	})
+}
+
+// Each module registers itself during package initialization.
+func init() {
+	modules.Register(&m{})
+}
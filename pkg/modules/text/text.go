@@ -0,0 +1,323 @@
+package text
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dop251/goja"
+	"github.com/go-go-golems/go-go-goja/modules"
+)
+
+// m is the text module. Tokenizers are shared by every runtime, see tokenizerFor.
+type m struct{}
+
+var _ modules.NativeModule = (*m)(nil)
+
+func (m) Name() string { return "text" }
+
+// Doc returns the documentation for the module.
+func (m) Doc() string {
+	return `
+The text module counts tokens, splits documents into chunks within a token budget and
+compares texts or embeddings, for prompts and retrieval (RAG) pipelines. Tokens are those
+of OpenAI models (gpt-4o, gpt-4, text-embedding-3-small, ... or an encoding name such as
+cl100k_base); other models are counted with cl100k_base.
+
+Functions:
+  tokens(str, [model]): Number of tokens of str.
+    Example: require('text').tokens(prompt, 'gpt-4o');
+  truncate(str, maxTokens, [model]): The longest start of str within maxTokens.
+  chunk(str, [options]): Splits str into chunks of at most size tokens, breaking between
+    sentences, then words. Options: size (default 512), overlap (tokens repeated from the
+    end of the previous chunk, default 0), model. Returns [{index, text, tokens}].
+    Example: require('text').chunk(doc, { size: 256, overlap: 32 });
+  similarity(a, b): Cosine similarity of two embeddings (arrays of numbers), or of the
+    word counts of two strings, from 0 to 1 for texts.
+  mostSimilar(query, candidates, [options]): The candidates most similar to query,
+    best first, as [{index, score, item}]. Options: k (default 5), key (field of object
+    candidates holding their text or embedding).
+  exact([model]): Whether counts for model are exact; false if its vocabulary could not
+    be loaded and counts are estimates.
+`
+}
+
+// textPiece is a piece of a document being chunked
+type textPiece struct {
+	text   string
+	tokens int
+}
+
+// sentences splits s after sentence ends and line breaks, keeping the whitespace that
+// follows with the sentence it follows
+func sentences(s string) []string {
+	var parts []string
+	start := 0
+	runes := []rune(s)
+	offset := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		offset += len(string(r))
+		end := r == '\n' || ((r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])))
+		if !end {
+			continue
+		}
+		for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			i++
+			offset += len(string(runes[i]))
+		}
+		parts = append(parts, s[start:offset])
+		start = offset
+	}
+	if start < len(s) {
+		parts = append(parts, s[start:])
+	}
+	return parts
+}
+
+// chunkText packs the sentences of s into chunks of at most size tokens, the pieces of a
+// sentence too long for a chunk being its words, and the pieces of a word too long its letters
+func chunkText(t tokenizer, s string, size, overlap int) []map[string]interface{} {
+	var pieces []textPiece
+	for _, sentence := range sentences(s) {
+		if n := t.count(sentence); n <= size {
+			pieces = append(pieces, textPiece{sentence, n})
+			continue
+		}
+		for _, word := range strings.SplitAfter(sentence, " ") {
+			if n := t.count(word); n <= size {
+				pieces = append(pieces, textPiece{word, n})
+				continue
+			}
+			for _, part := range splitLongWord(t, word, size) {
+				pieces = append(pieces, textPiece{part, t.count(part)})
+			}
+		}
+	}
+
+	chunks := []map[string]interface{}{}
+	emit := func(current []textPiece) {
+		var b strings.Builder
+		for _, p := range current {
+			b.WriteString(p.text)
+		}
+		chunk := strings.TrimSpace(b.String())
+		if chunk == "" {
+			return
+		}
+		chunks = append(chunks, map[string]interface{}{"index": len(chunks), "text": chunk, "tokens": t.count(chunk)})
+	}
+
+	var current []textPiece
+	tokens := 0
+	for _, p := range pieces {
+		if tokens+p.tokens > size && len(current) > 0 {
+			emit(current)
+			// Start the next chunk with the end of this one, never all of it
+			keep, kept := len(current), 0
+			for keep > 1 && kept+current[keep-1].tokens <= overlap {
+				keep--
+				kept += current[keep].tokens
+			}
+			current, tokens = append([]textPiece(nil), current[keep:]...), kept
+			if overlap == 0 {
+				current, tokens = nil, 0
+			}
+			for len(current) > 0 && tokens+p.tokens > size {
+				tokens -= current[0].tokens
+				current = current[1:]
+			}
+		}
+		current = append(current, p)
+		tokens += p.tokens
+	}
+	emit(current)
+	return chunks
+}
+
+// words counts the lowercased words of s
+func words(s string) map[string]float64 {
+	counts := make(map[string]float64)
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		counts[word]++
+	}
+	return counts
+}
+
+// toVector reads an array of numbers
+func toVector(value interface{}) ([]float64, bool) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	vector := make([]float64, len(list))
+	for i, item := range list {
+		switch n := item.(type) {
+		case int64:
+			vector[i] = float64(n)
+		case float64:
+			vector[i] = n
+		default:
+			return nil, false
+		}
+	}
+	return vector, true
+}
+
+// cosine returns the cosine similarity of two vectors, 0 if either is zero
+func cosine(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// similarity compares two strings by their word counts, or two embeddings
+func similarity(a, b interface{}) (float64, error) {
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return 0, fmt.Errorf("cannot compare a string with %T", b)
+		}
+		wa, wb := words(sa), words(sb)
+		var dot, normA, normB float64
+		for word, n := range wa {
+			dot += n * wb[word]
+			normA += n * n
+		}
+		for _, n := range wb {
+			normB += n * n
+		}
+		if normA == 0 || normB == 0 {
+			return 0, nil
+		}
+		return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+	}
+
+	va, okA := toVector(a)
+	vb, okB := toVector(b)
+	if !okA || !okB {
+		return 0, fmt.Errorf("similarity() compares two strings or two arrays of numbers")
+	}
+	if len(va) != len(vb) {
+		return 0, fmt.Errorf("embeddings of %d and %d dimensions cannot be compared", len(va), len(vb))
+	}
+	return cosine(va, vb), nil
+}
+
+// Loader attaches the exported Go functions to the JS `exports` object.
+func (mod m) Loader(vm *goja.Runtime, moduleObj *goja.Object) {
+	exports := moduleObj.Get("exports").(*goja.Object)
+
+	optionalModel := func(args []string) string {
+		if len(args) > 0 {
+			return args[0]
+		}
+		return ""
+	}
+
+	modules.SetExport(exports, mod.Name(), "tokens", func(s string, model ...string) int {
+		return tokenizerFor(optionalModel(model)).count(s)
+	})
+
+	modules.SetExport(exports, mod.Name(), "exact", func(model ...string) bool {
+		return tokenizerFor(optionalModel(model)).exact()
+	})
+
+	// truncate(str, maxTokens, [model]) -> string | throws
+	modules.SetExport(exports, mod.Name(), "truncate", func(s string, maxTokens int, model ...string) (string, error) {
+		if maxTokens < 0 {
+			return "", fmt.Errorf("truncate() needs a token budget of at least 0")
+		}
+		return truncateTokens(tokenizerFor(optionalModel(model)), s, maxTokens), nil
+	})
+
+	// chunk(str, [{size, overlap, model}]) -> [{index, text, tokens}] | throws
+	modules.SetExport(exports, mod.Name(), "chunk", func(s string, options ...map[string]interface{}) ([]map[string]interface{}, error) {
+		size, overlap, model := 512, 0, ""
+		if len(options) > 0 && options[0] != nil {
+			opts := options[0]
+			if v, ok := opts["size"]; ok {
+				n, ok := v.(int64)
+				if !ok || n < 1 {
+					return nil, fmt.Errorf("chunk(): size must be a positive integer")
+				}
+				size = int(n)
+			}
+			if v, ok := opts["overlap"]; ok {
+				n, ok := v.(int64)
+				if !ok || n < 0 {
+					return nil, fmt.Errorf("chunk(): overlap must be an integer of at least 0")
+				}
+				overlap = int(n)
+			}
+			if v, ok := opts["model"].(string); ok {
+				model = v
+			}
+		}
+		if overlap >= size {
+			return nil, fmt.Errorf("chunk(): overlap must be smaller than size")
+		}
+		return chunkText(tokenizerFor(model), s, size, overlap), nil
+	})
+
+	// similarity(a, b) -> number | throws
+	modules.SetExport(exports, mod.Name(), "similarity", func(a, b goja.Value) (float64, error) {
+		return similarity(a.Export(), b.Export())
+	})
+
+	// mostSimilar(query, candidates, [{k, key}]) -> [{index, score, item}] | throws
+	modules.SetExport(exports, mod.Name(), "mostSimilar", func(query goja.Value, candidates []goja.Value, options ...map[string]interface{}) ([]map[string]interface{}, error) {
+		k, key := 5, ""
+		if len(options) > 0 && options[0] != nil {
+			if v, ok := options[0]["k"]; ok {
+				n, ok := v.(int64)
+				if !ok || n < 1 {
+					return nil, fmt.Errorf("mostSimilar(): k must be a positive integer")
+				}
+				k = int(n)
+			}
+			key, _ = options[0]["key"].(string)
+		}
+
+		q := query.Export()
+		results := make([]map[string]interface{}, 0, len(candidates))
+		for i, candidate := range candidates {
+			value := candidate.Export()
+			if key != "" {
+				obj, ok := candidate.(*goja.Object)
+				if !ok {
+					return nil, fmt.Errorf("mostSimilar(): candidate %d is not an object with %s", i, key)
+				}
+				value = obj.Get(key).Export()
+			}
+			score, err := similarity(q, value)
+			if err != nil {
+				return nil, fmt.Errorf("mostSimilar(): candidate %d: %w", i, err)
+			}
+			results = append(results, map[string]interface{}{"index": i, "score": score, "item": candidate})
+		}
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i]["score"].(float64) > results[j]["score"].(float64)
+		})
+		if len(results) > k {
+			results = results[:k]
+		}
+		return results, nil
+	})
+}
+
+// Each module registers itself during package initialization.
+func init() {
+	modules.Register(&m{})
+}
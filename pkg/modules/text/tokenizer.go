@@ -0,0 +1,243 @@
+package text
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/rs/zerolog/log"
+)
+
+// Token counting
+//
+// Counts use the byte pair encodings of OpenAI models (cl100k_base, o200k_base, ...). Their
+// vocabularies are downloaded once and kept in TIKTOKEN_CACHE_DIR, by default the user's cache
+// directory; put the files there to count offline. Models of other vendors are counted with
+// cl100k_base, which comes close for English text. If a vocabulary cannot be loaded, counts
+// fall back to an estimate from the same word splitting the encodings start with.
+
+// defaultEncoding counts the tokens of models without an encoding of their own
+const defaultEncoding = tiktoken.MODEL_CL100K_BASE
+
+// vocabularyTimeout bounds the download of a vocabulary
+const vocabularyTimeout = 30 * time.Second
+
+// vocabularyRetry is how long counts stay estimates after a vocabulary failed to load
+const vocabularyRetry = 10 * time.Minute
+
+// tokenizer counts the tokens of a text
+type tokenizer interface {
+	count(s string) int
+	exact() bool
+}
+
+// bpeTokenizer counts tokens with the encoding of a model
+type bpeTokenizer struct {
+	encoding *tiktoken.Tiktoken
+}
+
+func (t bpeTokenizer) count(s string) int { return len(t.encoding.EncodeOrdinary(s)) }
+func (t bpeTokenizer) exact() bool        { return true }
+
+// estimateTokenizer estimates counts when no vocabulary is available: words of up to four
+// letters are one token, longer ones a token per four letters, and each CJK character a token
+var estimateTokenizer tokenizer = estimator{}
+
+type estimator struct{}
+
+// wordRegexp splits text like the pattern of cl100k_base
+var wordRegexp = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+func (estimator) count(s string) int {
+	n := 0
+	for _, word := range wordRegexp.FindAllString(s, -1) {
+		letters, wide := 0, 0
+		for _, r := range word {
+			if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+				wide++
+			} else if !unicode.IsSpace(r) {
+				letters++
+			}
+		}
+		n += wide + (letters+3)/4
+		if wide == 0 && letters == 0 {
+			n++ // Whitespace
+		}
+	}
+	return n
+}
+
+func (estimator) exact() bool { return false }
+
+var (
+	tokenizersMu sync.Mutex
+	tokenizers   = map[string]tokenizer{}
+	failedAt     = map[string]time.Time{} // When loading the vocabulary of an encoding last failed
+)
+
+func init() {
+	tiktoken.SetBpeLoader(vocabularyLoader{})
+}
+
+// tokenizerFor returns the tokenizer of a model or encoding name, "" for the default one
+func tokenizerFor(model string) tokenizer {
+	encodingName := encodingFor(model)
+
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	if t, ok := tokenizers[encodingName]; ok {
+		return t
+	}
+	if failed, ok := failedAt[encodingName]; ok && time.Since(failed) < vocabularyRetry {
+		return estimateTokenizer
+	}
+	encoding, err := tiktoken.GetEncoding(encodingName)
+	if err != nil {
+		log.Warn().Err(err).Str("encoding", encodingName).Msg("Failed to load token vocabulary, estimating token counts")
+		failedAt[encodingName] = time.Now()
+		return estimateTokenizer
+	}
+	t := bpeTokenizer{encoding: encoding}
+	tokenizers[encodingName] = t
+	return t
+}
+
+// encodingFor returns the encoding of a model, which may also be given as an encoding name
+func encodingFor(model string) string {
+	model = strings.ToLower(strings.TrimSpace(model))
+	switch model {
+	case tiktoken.MODEL_O200K_BASE, tiktoken.MODEL_CL100K_BASE, tiktoken.MODEL_P50K_BASE, tiktoken.MODEL_P50K_EDIT, tiktoken.MODEL_R50K_BASE:
+		return model
+	}
+	if encoding, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		return encoding
+	}
+	for prefix, encoding := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+		if strings.HasPrefix(model, prefix) {
+			return encoding
+		}
+	}
+	if strings.HasPrefix(model, "o1") || strings.HasPrefix(model, "o3") || strings.HasPrefix(model, "o4") || strings.HasPrefix(model, "gpt-5") {
+		return tiktoken.MODEL_O200K_BASE
+	}
+	return defaultEncoding
+}
+
+// vocabularyLoader reads the vocabularies of the encodings from the cache directory,
+// downloading the missing ones with a timeout
+type vocabularyLoader struct{}
+
+func (vocabularyLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	dir := strings.TrimSpace(os.Getenv("TIKTOKEN_CACHE_DIR"))
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			cacheDir = os.TempDir()
+		}
+		dir = filepath.Join(cacheDir, "jesus", "tiktoken")
+	}
+	path := filepath.Join(dir, filepath.Base(url))
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if data, err = downloadVocabulary(url); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0o755); err == nil {
+			if err := os.WriteFile(path, data, 0o644); err != nil {
+				log.Warn().Err(err).Str("path", path).Msg("Failed to cache token vocabulary")
+			}
+		}
+	}
+	return parseVocabulary(data)
+}
+
+// downloadVocabulary fetches a vocabulary file
+func downloadVocabulary(url string) ([]byte, error) {
+	client := &http.Client{Timeout: vocabularyTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseVocabulary reads the lines "<base64 token> <rank>" of a vocabulary file
+func parseVocabulary(data []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		token, rank, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token vocabulary: %w", err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token vocabulary: %w", err)
+		}
+		ranks[string(decoded)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty token vocabulary")
+	}
+	return ranks, nil
+}
+
+// truncateTokens returns the longest prefix of s within max tokens
+func truncateTokens(t tokenizer, s string, max int) string {
+	if t.count(s) <= max {
+		return s
+	}
+	// Binary search on the number of runes kept
+	runes := []rune(s)
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if t.count(string(runes[:mid])) <= max {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return string(runes[:lo])
+}
+
+// splitLongWord cuts a piece of text with more than max tokens into pieces within max
+func splitLongWord(t tokenizer, s string, max int) []string {
+	var pieces []string
+	for s != "" {
+		piece := truncateTokens(t, s, max)
+		if piece == "" {
+			// A single character over the budget still has to go somewhere
+			_, size := utf8.DecodeRuneInString(s)
+			piece = s[:size]
+		}
+		pieces = append(pieces, piece)
+		s = s[len(piece):]
+	}
+	return pieces
+}
@@ -1,6 +1,16 @@
 package repository
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is wrapped by the errors of lookups and deletes of records that do not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrInvalidFilter is wrapped by the errors of queries whose execution filter has a malformed value
+var ErrInvalidFilter = errors.New("invalid execution filter")
 
 // ExecutionRepository defines the interface for script execution storage
 type ExecutionRepository interface {
@@ -22,8 +32,26 @@ type ExecutionRepository interface {
 	// DeleteExecutionsBySessionID removes all executions for a session
 	DeleteExecutionsBySessionID(ctx context.Context, sessionID string) error
 
+	// DeleteExecutionsByIDs removes the executions with the given IDs and returns how many were deleted
+	DeleteExecutionsByIDs(ctx context.Context, ids []int) (int64, error)
+
+	// DeleteExecutions removes all executions matching the filter and returns how many were deleted
+	DeleteExecutions(ctx context.Context, filter ExecutionFilter) (int64, error)
+
 	// GetExecutionStats returns statistics about script executions
 	GetExecutionStats(ctx context.Context) (*ExecutionStats, error)
+
+	// GetResultSlice retrieves up to limit items of the paged result of an execution, starting at offset
+	GetResultSlice(ctx context.Context, executionID, offset, limit int) (*ResultSlice, error)
+
+	// GetAttachment retrieves a file attached to an execution, including its data
+	GetAttachment(ctx context.Context, executionID int, name string) (*ExecutionAttachment, error)
+
+	// ListAttachmentStorageKeys returns the storage keys of the attachments kept outside the database
+	ListAttachmentStorageKeys(ctx context.Context) ([]string, error)
+
+	// GetCodeUsage summarizes the executions that ran the code with the given hash
+	GetCodeUsage(ctx context.Context, hash string) (*CodeUsage, error)
 }
 
 // ExecutionStats contains statistics about script executions
@@ -32,11 +60,158 @@ type ExecutionStats struct {
 	SuccessfulExecutions int            `json:"successful_executions"`
 	FailedExecutions     int            `json:"failed_executions"`
 	ExecutionsBySource   map[string]int `json:"executions_by_source"`
+	DistinctCode         int            `json:"distinct_code"` // Different code blobs stored for the executions
 	AverageExecutionTime *float64       `json:"average_execution_time,omitempty"`
 }
 
+// SavedFilterRepository defines the interface for saved execution filter storage
+type SavedFilterRepository interface {
+	// SaveFilter stores a named filter, replacing any filter with the same name on the same page
+	SaveFilter(ctx context.Context, req SaveFilterRequest) (*SavedFilter, error)
+
+	// ListFilters retrieves the saved filters of a page, or of all pages if page is empty
+	ListFilters(ctx context.Context, page string) ([]SavedFilter, error)
+
+	// DeleteFilter removes a saved filter by ID
+	DeleteFilter(ctx context.Context, id int) error
+}
+
+// BootstrapBackupRepository defines the interface for bootstrap file backup storage
+type BootstrapBackupRepository interface {
+	// SaveBackup stores a backup of a file, unless its latest backup has the same content, which it returns instead
+	SaveBackup(ctx context.Context, file, content string) (*BootstrapBackup, error)
+
+	// GetBackup retrieves a backup by ID
+	GetBackup(ctx context.Context, id int) (*BootstrapBackup, error)
+
+	// ListBackups retrieves the backups of a file, newest first
+	ListBackups(ctx context.Context, file string) ([]BootstrapBackup, error)
+
+	// SetBackupStatus records whether running a backup succeeded
+	SetBackupStatus(ctx context.Context, id int, status string, errorMessage *string) error
+
+	// PruneBackups removes all but the newest keep backups of a file
+	PruneBackups(ctx context.Context, file string, keep int) error
+}
+
+// NotebookRepository defines the interface for notebook storage
+type NotebookRepository interface {
+	// CreateNotebook stores a new notebook
+	CreateNotebook(ctx context.Context, req SaveNotebookRequest) (*Notebook, error)
+
+	// UpdateNotebook replaces the name and cells of a notebook
+	UpdateNotebook(ctx context.Context, id int, req SaveNotebookRequest) (*Notebook, error)
+
+	// GetNotebook retrieves a notebook by ID
+	GetNotebook(ctx context.Context, id int) (*Notebook, error)
+
+	// ListNotebooks retrieves all notebooks, most recently updated first
+	ListNotebooks(ctx context.Context) ([]Notebook, error)
+
+	// DeleteNotebook removes a notebook by ID
+	DeleteNotebook(ctx context.Context, id int) error
+}
+
+// SyncRepository defines the interface for the channel engines sharing the system database
+// exchange globalState and route code through
+type SyncRepository interface {
+	// PublishSyncEvent stores an event for the other engines
+	PublishSyncEvent(ctx context.Context, instanceID, kind, payload string) (*SyncEvent, error)
+
+	// ListSyncEvents retrieves the events after an ID published by other engines, oldest first
+	ListSyncEvents(ctx context.Context, afterID int, excludeInstance string) ([]SyncEvent, error)
+
+	// LatestSyncEvent retrieves the newest event of a kind, or of any kind for "". It returns
+	// nil if there is none.
+	LatestSyncEvent(ctx context.Context, kind string) (*SyncEvent, error)
+
+	// PruneSyncEvents removes events published before a time
+	PruneSyncEvents(ctx context.Context, before time.Time) error
+}
+
+// FeatureFlagRepository defines the interface for feature flag storage
+type FeatureFlagRepository interface {
+	// SaveFlag stores a flag, replacing any flag with the same name
+	SaveFlag(ctx context.Context, flag FeatureFlag) (*FeatureFlag, error)
+
+	// ListFlags retrieves all flags, ordered by name
+	ListFlags(ctx context.Context) ([]FeatureFlag, error)
+
+	// DeleteFlag removes a flag by name
+	DeleteFlag(ctx context.Context, name string) error
+}
+
+// AdminAuditRepository defines the interface for the audit log of admin changes
+type AdminAuditRepository interface {
+	// RecordAction stores an admin change
+	RecordAction(ctx context.Context, entry AdminAuditEntry) (*AdminAuditEntry, error)
+
+	// ListActions retrieves admin changes matching the filter, newest first
+	ListActions(ctx context.Context, filter AdminAuditFilter) ([]AdminAuditEntry, error)
+}
+
+// EventLogRepository defines the interface for the engine event log
+type EventLogRepository interface {
+	// AppendEvents stores events in order, assigning increasing IDs
+	AppendEvents(ctx context.Context, events []EventLogEntry) error
+
+	// ListEvents retrieves the events after the cursor of the filter, oldest first
+	ListEvents(ctx context.Context, filter EventLogFilter) ([]EventLogEntry, error)
+
+	// LatestEventID returns the ID of the newest event, 0 if there is none
+	LatestEventID(ctx context.Context) (int64, error)
+
+	// PruneEvents removes events stored before a time
+	PruneEvents(ctx context.Context, before time.Time) error
+}
+
+// CassetteRepository defines the interface for the recorded responses of outbound requests
+type CassetteRepository interface {
+	// SaveInteraction appends a recorded response to its cassette
+	SaveInteraction(ctx context.Context, interaction CassetteInteraction) (*CassetteInteraction, error)
+
+	// ListInteractions retrieves the responses of a cassette in the order they were recorded
+	ListInteractions(ctx context.Context, cassette string) ([]CassetteInteraction, error)
+
+	// ListCassettes retrieves the cassettes that have responses, ordered by name
+	ListCassettes(ctx context.Context) ([]CassetteSummary, error)
+
+	// ReplaceCassette replaces the responses of a cassette, in order
+	ReplaceCassette(ctx context.Context, cassette string, interactions []CassetteInteraction) error
+
+	// DeleteCassette removes a cassette and its responses
+	DeleteCassette(ctx context.Context, cassette string) error
+}
+
+// ConversationRepository defines the interface for the AI conversations kept for replay
+type ConversationRepository interface {
+	// CreateConversation stores a new conversation without messages
+	CreateConversation(ctx context.Context, conversation Conversation) (*Conversation, error)
+
+	// AppendMessage adds a message to the end of its conversation
+	AppendMessage(ctx context.Context, message ConversationMessage) (*ConversationMessage, error)
+
+	// GetConversation retrieves a conversation with its messages in order
+	GetConversation(ctx context.Context, id int) (*Conversation, error)
+
+	// ListConversations retrieves conversations without their messages, most recently updated first
+	ListConversations(ctx context.Context, limit int) ([]Conversation, error)
+
+	// DeleteConversation removes a conversation and its messages
+	DeleteConversation(ctx context.Context, id int) error
+}
+
 // RepositoryManager manages all repositories
 type RepositoryManager interface {
 	Executions() ExecutionRepository
+	SavedFilters() SavedFilterRepository
+	BootstrapBackups() BootstrapBackupRepository
+	Notebooks() NotebookRepository
+	Sync() SyncRepository
+	FeatureFlags() FeatureFlagRepository
+	AdminAudit() AdminAuditRepository
+	EventLog() EventLogRepository
+	Cassettes() CassetteRepository
+	Conversations() ConversationRepository
 	Close() error
 }
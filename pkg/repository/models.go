@@ -1,6 +1,26 @@
 package repository
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Execution sources, recording what ran the code of an execution
+const (
+	SourceAPI        = "api"        // POST /v1/execute
+	SourceREPL       = "repl"       // REPL page and terminal REPL
+	SourcePlayground = "playground" // Code run from the playground editor
+	SourceMCP        = "mcp"        // executeJS tool of the MCP server
+	SourceMCPFile    = "mcp-file"   // executeJSFile tool of the MCP server
+	SourceFile       = "file"       // Scripts loaded on startup
+	SourceScheduler  = "scheduler"  // Scheduled jobs and timers
+	SourceWebhook    = "webhook"    // Code triggered by an incoming webhook
+	SourceReplay     = "replay"     // Re-run of a stored execution or request
+	SourceSelfCheck  = "self-check" // Startup self-check
+	SourceNotebook   = "notebook"   // Cells of an admin notebook
+	SourceSync       = "sync"       // Code replayed from another engine sharing the system database
+)
 
 // ScriptExecution represents a stored script execution record
 type ScriptExecution struct {
@@ -11,7 +31,110 @@ type ScriptExecution struct {
 	ConsoleLog *string   `json:"console_log" db:"console_log"` // Nullable
 	Error      *string   `json:"error" db:"error"`             // Nullable
 	Timestamp  time.Time `json:"timestamp" db:"timestamp"`
-	Source     string    `json:"source" db:"source"` // 'api', 'mcp', 'file'
+	Source     string    `json:"source" db:"source"`         // One of the Source* constants
+	Actor      *string   `json:"actor" db:"actor"`           // Nullable, who ran the code: API client, MCP client name, admin user
+	RequestID  *string   `json:"request_id" db:"request_id"` // Nullable, HTTP request that triggered the execution
+	SavedAs    *string   `json:"saved_as" db:"saved_as"`     // Nullable, file the code was saved to before it ran
+	CodeHash   string    `json:"code_hash" db:"code_hash"`   // SHA-256 of the code, which is stored once for all executions that ran it
+
+	Truncation *OutputTruncation `json:"truncation,omitempty" db:"truncation"` // Nullable, set when the stored output was shortened
+	Paging     *ResultPaging     `json:"paging,omitempty" db:"paging"`         // Nullable, set when the result was stored in pages
+	Changes    *ExecutionChanges `json:"changes,omitempty" db:"changes"`       // Nullable, set when the code changed routes, globalState or tables
+
+	Attachments []ExecutionAttachment `json:"attachments,omitempty"` // Files attached with output.attach(), only filled by GetExecution
+	CodeUsage   *CodeUsage            `json:"code_usage,omitempty"`  // Other runs of the same code, only filled by GetExecution
+}
+
+// CodeUsage summarizes the executions that ran the same code
+type CodeUsage struct {
+	Hash     string    `json:"hash"`
+	Size     int       `json:"size"`     // Length of the code in bytes, stored once for all runs
+	Runs     int       `json:"runs"`     // Executions that ran the code
+	Sessions int       `json:"sessions"` // Distinct sessions among them
+	Actors   []string  `json:"actors"`   // Distinct actors among them
+	FirstRun time.Time `json:"first_run"`
+	LastRun  time.Time `json:"last_run"`
+}
+
+// ExecutionAttachment is a file a script attached to its execution with output.attach()
+type ExecutionAttachment struct {
+	ExecutionID int       `json:"execution_id"`
+	Name        string    `json:"name"`
+	MimeType    string    `json:"mime_type"`
+	Size        int       `json:"size"`
+	Data        []byte    `json:"-"` // Only filled by GetAttachment, empty for attachments kept in storage
+	StorageKey  string    `json:"-"` // Key of the data in the engine's storage, "" if the data is in the database
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OutputTruncation records how the stored output of an execution was shortened
+type OutputTruncation struct {
+	ResultBytes     int    `json:"result_bytes,omitempty"`      // Size of the full result, 0 if it was stored completely
+	ConsoleLogBytes int    `json:"console_log_bytes,omitempty"` // Size of the full console log, 0 if it was stored completely
+	OutputFile      string `json:"output_file,omitempty"`       // Name of the file holding the full output, if it was kept
+}
+
+// ExecutionChanges summarizes the side effects of an execution. Routes are "METHOD /path",
+// "FILE /path" for file handlers; state keys are the top-level keys of globalState.
+type ExecutionChanges struct {
+	RoutesAdded    []string `json:"routes_added,omitempty"`
+	RoutesReplaced []string `json:"routes_replaced,omitempty"` // Registered again with another handler
+	RoutesRemoved  []string `json:"routes_removed,omitempty"`
+	StateAdded     []string `json:"state_added,omitempty"`
+	StateChanged   []string `json:"state_changed,omitempty"`
+	StateRemoved   []string `json:"state_removed,omitempty"`
+	TablesWritten  []string `json:"tables_written,omitempty"` // Application database tables inserted into, updated, deleted from or altered
+}
+
+// IsEmpty reports whether the execution changed nothing
+func (c ExecutionChanges) IsEmpty() bool {
+	return len(c.RoutesAdded) == 0 && len(c.RoutesReplaced) == 0 && len(c.RoutesRemoved) == 0 &&
+		len(c.StateAdded) == 0 && len(c.StateChanged) == 0 && len(c.StateRemoved) == 0 && len(c.TablesWritten) == 0
+}
+
+// Summary describes the changes in one line, like
+// "added routes GET /users, POST /users; changed globalState keys counter; wrote tables users"
+func (c ExecutionChanges) Summary() string {
+	var parts []string
+	add := func(what string, items []string) {
+		if len(items) > 0 {
+			parts = append(parts, what+" "+strings.Join(items, ", "))
+		}
+	}
+	add("added routes", c.RoutesAdded)
+	add("replaced routes", c.RoutesReplaced)
+	add("removed routes", c.RoutesRemoved)
+	add("added globalState keys", c.StateAdded)
+	add("changed globalState keys", c.StateChanged)
+	add("removed globalState keys", c.StateRemoved)
+	add("wrote tables", c.TablesWritten)
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Kinds of paged results
+const (
+	ResultKindArray  = "array"  // Items are the elements of the array
+	ResultKindObject = "object" // Items are {"key": ..., "value": ...} entries, sorted by key
+)
+
+// ResultPaging records how a large result was split into pages. The result column of the
+// execution then only holds the first page.
+type ResultPaging struct {
+	Kind     string `json:"kind"`      // ResultKindArray or ResultKindObject
+	Items    int    `json:"items"`     // Number of elements or entries of the full result
+	PageSize int    `json:"page_size"` // Items per page
+	Bytes    int    `json:"bytes"`     // Size of the full result as JSON
+}
+
+// ResultSlice is a range of the items of a paged result
+type ResultSlice struct {
+	Kind   string            `json:"kind"`
+	Offset int               `json:"offset"`
+	Total  int               `json:"total"`
+	Items  []json.RawMessage `json:"items"`
 }
 
 // ExecutionFilter provides filtering options for script execution queries
@@ -19,10 +142,21 @@ type ExecutionFilter struct {
 	Search    string     `json:"search,omitempty"`
 	SessionID string     `json:"session_id,omitempty"`
 	Source    string     `json:"source,omitempty"`
+	Actor     string     `json:"actor,omitempty"`
+	RequestID string     `json:"request_id,omitempty"`
+	CodeHash  string     `json:"code_hash,omitempty"` // Only executions that ran the code with this hash
+	HasError  *bool      `json:"has_error,omitempty"` // Only failed (true) or only successful (false) executions
+	Since     string     `json:"since,omitempty"`     // Relative time window such as "24h", resolved at query time
+	BeforeID  int        `json:"before_id,omitempty"` // Only executions stored before the execution with this ID
 	FromDate  *time.Time `json:"from_date,omitempty"`
 	ToDate    *time.Time `json:"to_date,omitempty"`
 }
 
+// IsEmpty reports whether the filter has no conditions set
+func (f ExecutionFilter) IsEmpty() bool {
+	return f.Search == "" && f.SessionID == "" && f.Source == "" && f.Actor == "" && f.RequestID == "" && f.CodeHash == "" && f.HasError == nil && f.Since == "" && f.BeforeID == 0 && f.FromDate == nil && f.ToDate == nil
+}
+
 // PaginationOptions provides pagination parameters
 type PaginationOptions struct {
 	Limit  int `json:"limit"`
@@ -45,4 +179,194 @@ type CreateExecutionRequest struct {
 	ConsoleLog *string `json:"console_log,omitempty"`
 	Error      *string `json:"error,omitempty"`
 	Source     string  `json:"source"`
+	Actor      *string `json:"actor,omitempty"`
+	RequestID  *string `json:"request_id,omitempty"`
+	SavedAs    *string `json:"saved_as,omitempty"`
+
+	Truncation *OutputTruncation   `json:"truncation,omitempty"`
+	Paging     *ResultPaging       `json:"paging,omitempty"`
+	Changes    *ExecutionChanges   `json:"changes,omitempty"`
+	Pages      [][]json.RawMessage `json:"-"` // Items of the result pages when Paging is set
+
+	Attachments []ExecutionAttachment `json:"-"` // Files attached with output.attach()
+}
+
+// SavedFilter is a named execution filter persisted for quick reuse in the admin viewers
+type SavedFilter struct {
+	ID        int             `json:"id" db:"id"`
+	Name      string          `json:"name" db:"name"`
+	Page      string          `json:"page" db:"page"` // Viewer the filter belongs to, e.g. 'history' or 'scripts'
+	Filter    ExecutionFilter `json:"filter" db:"filter"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// SaveFilterRequest contains data for saving a named filter
+type SaveFilterRequest struct {
+	Name   string          `json:"name"`
+	Page   string          `json:"page"`
+	Filter ExecutionFilter `json:"filter"`
+}
+
+// Notebook is a list of code cells that run one after another in a session runtime
+type Notebook struct {
+	ID        int            `json:"id" db:"id"`
+	Name      string         `json:"name" db:"name"`
+	Cells     []NotebookCell `json:"cells" db:"cells"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// NotebookCell is a code cell of a notebook. Its output is the execution of its last run.
+type NotebookCell struct {
+	ID          string `json:"id"` // Stable ID chosen by the client
+	Code        string `json:"code"`
+	ExecutionID *int   `json:"execution_id,omitempty"` // Last run of the cell, nil if it never ran
+}
+
+// SaveNotebookRequest contains the name and cells of a notebook to create or update
+type SaveNotebookRequest struct {
+	Name  string         `json:"name"`
+	Cells []NotebookCell `json:"cells"`
+}
+
+// FeatureFlag gates code paths of scripts, see flags.isEnabled()
+type FeatureFlag struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Enabled     bool      `json:"enabled" db:"enabled"`
+	Percentage  int       `json:"percentage" db:"percentage"` // Share of keys the flag is on for, 0 to 100
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AdminAuditEntry records a change made through the admin interface
+type AdminAuditEntry struct {
+	ID        int       `json:"id" db:"id"`
+	Actor     string    `json:"actor" db:"actor"`     // API key or client IP that made the change
+	Action    string    `json:"action" db:"action"`   // Kind of change, like globalstate.set or vm.reset
+	Method    string    `json:"method" db:"method"`   // HTTP method of the admin request
+	Path      string    `json:"path" db:"path"`       // Path of the admin request
+	Summary   string    `json:"summary" db:"summary"` // Start of the request payload
+	Status    int       `json:"status" db:"status"`   // HTTP status the request was answered with
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AdminAuditFilter selects admin audit entries; empty fields match all
+type AdminAuditFilter struct {
+	Actor  string
+	Action string
+	Limit  int // 100 if 0
+	Offset int
+}
+
+// EventLogEntry is an engine event kept in the event log for external consumers. Its ID is
+// the cursor of /v1/events: it grows with every event, so events after a cursor are the
+// events that happened since.
+type EventLogEntry struct {
+	ID         int64     `json:"id" db:"id"`
+	Type       string    `json:"type" db:"type"`               // Event type, like script.executed or route.registered
+	Source     string    `json:"source,omitempty" db:"source"` // Execution source (api, mcp, file, ...)
+	SessionID  string    `json:"session_id,omitempty" db:"session_id"`
+	Method     string    `json:"method,omitempty" db:"method"` // Route method, FILE for file handlers
+	Path       string    `json:"path,omitempty" db:"path"`     // Route path, or path of the request that failed
+	DurationMs int64     `json:"duration_ms,omitempty" db:"duration_ms"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// EventLogFilter selects event log entries
+type EventLogFilter struct {
+	After int64    // Cursor: only events with a greater ID
+	Types []string // Event types, all if empty
+	Limit int      // 100 if 0
+}
+
+// CassetteInteraction is a response to an outbound request, recorded so that it can be
+// replayed without the network
+type CassetteInteraction struct {
+	ID         int                 `json:"id" db:"id"`
+	Cassette   string              `json:"cassette" db:"cassette"`
+	RequestKey string              `json:"request_key" db:"request_key"` // Method, URL and body hash the request is matched by
+	Method     string              `json:"method" db:"method"`
+	URL        string              `json:"url" db:"url"` // Without the values of credential query parameters
+	Status     int                 `json:"status" db:"status"`
+	Headers    map[string][]string `json:"headers" db:"headers"`
+	Body       []byte              `json:"body" db:"body"`
+	CreatedAt  time.Time           `json:"created_at" db:"created_at"`
+}
+
+// CassetteSummary describes a cassette of recorded responses
+type CassetteSummary struct {
+	Name         string    `json:"name"`
+	Interactions int       `json:"interactions"`
+	RecordedAt   time.Time `json:"recorded_at"` // When the last response was recorded
+}
+
+// Conversation is an AI conversation recorded with conversations.create() for replay
+type Conversation struct {
+	ID           int                   `json:"id" db:"id"`
+	Title        string                `json:"title" db:"title"`
+	Model        string                `json:"model" db:"model"`
+	SessionID    string                `json:"session_id" db:"session_id"` // Execution session that created it, if any
+	MessageCount int                   `json:"message_count"`
+	Messages     []ConversationMessage `json:"messages,omitempty"` // Only filled by GetConversation
+	CreatedAt    time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time             `json:"updated_at" db:"updated_at"` // When the last message was added
+}
+
+// ConversationMessage is a message of a conversation
+type ConversationMessage struct {
+	ID             int                    `json:"id" db:"id"`
+	ConversationID int                    `json:"conversation_id" db:"conversation_id"`
+	Role           string                 `json:"role" db:"role"` // system, user, assistant or tool
+	Content        string                 `json:"content" db:"content"`
+	ToolCalls      []ConversationToolCall `json:"tool_calls,omitempty" db:"tool_calls"`     // Tools called by an assistant message
+	ToolCallID     string                 `json:"tool_call_id,omitempty" db:"tool_call_id"` // Call a tool message answers
+	Tokens         []ConversationToken    `json:"tokens,omitempty" db:"tokens"`             // Chunks of a streamed message, in order
+	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
+}
+
+// ConversationToolCall is a tool call of an assistant message
+type ConversationToolCall struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Arguments interface{} `json:"arguments"`
+}
+
+// ConversationToken is a chunk of a streamed message and when it arrived
+type ConversationToken struct {
+	Text string `json:"text"`
+	Ms   int64  `json:"ms"` // Milliseconds since the message started streaming
+}
+
+// Sync event kinds
+const (
+	SyncKindState = "state" // Payload is the globalState JSON of the publishing engine
+	SyncKindCode  = "code"  // Payload is code that registered routes in the publishing engine
+)
+
+// SyncEvent is a change one engine publishes for the other engines sharing the system database
+type SyncEvent struct {
+	ID         int       `json:"id" db:"id"`
+	InstanceID string    `json:"instance_id" db:"instance_id"` // Engine that published the event
+	Kind       string    `json:"kind" db:"kind"`               // One of the SyncKind* constants
+	Payload    string    `json:"payload" db:"payload"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// Bootstrap backup statuses
+const (
+	BootstrapStatusOK     = "ok"     // The backed up content ran without error
+	BootstrapStatusFailed = "failed" // Running the backed up content failed
+)
+
+// BootstrapBackup is a copy of a bootstrap file, taken whenever new content of the file runs
+type BootstrapBackup struct {
+	ID        int       `json:"id" db:"id"`
+	File      string    `json:"file" db:"file"`
+	Content   string    `json:"content" db:"content"`
+	Hash      string    `json:"hash" db:"hash"`     // SHA-256 of the content
+	Status    string    `json:"status" db:"status"` // BootstrapStatusOK, BootstrapStatusFailed or "" if it never ran
+	Error     *string   `json:"error,omitempty" db:"error"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
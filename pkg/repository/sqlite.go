@@ -2,18 +2,31 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
 )
 
 // sqliteRepositoryManager implements RepositoryManager for SQLite
 type sqliteRepositoryManager struct {
-	db            *sql.DB
-	executionRepo ExecutionRepository
+	db              *sql.DB
+	executionRepo   ExecutionRepository
+	savedFilterRepo SavedFilterRepository
+	bootstrapRepo   BootstrapBackupRepository
+	notebookRepo    NotebookRepository
+	syncRepo        SyncRepository
+	flagRepo        FeatureFlagRepository
+	auditRepo       AdminAuditRepository
+	eventRepo       EventLogRepository
+	cassetteRepo    CassetteRepository
+	convRepo        ConversationRepository
 }
 
 // NewSQLiteRepositoryManager creates a new SQLite repository manager
@@ -29,6 +42,15 @@ func NewSQLiteRepositoryManager(dbPath string) (RepositoryManager, error) {
 
 	// Initialize execution repository
 	manager.executionRepo = &sqliteExecutionRepository{db: db}
+	manager.savedFilterRepo = &sqliteSavedFilterRepository{db: db}
+	manager.bootstrapRepo = &sqliteBootstrapBackupRepository{db: db}
+	manager.notebookRepo = &sqliteNotebookRepository{db: db}
+	manager.syncRepo = &sqliteSyncRepository{db: db}
+	manager.flagRepo = &sqliteFeatureFlagRepository{db: db}
+	manager.auditRepo = &sqliteAdminAuditRepository{db: db}
+	manager.eventRepo = &sqliteEventLogRepository{db: db}
+	manager.cassetteRepo = &sqliteCassetteRepository{db: db}
+	manager.convRepo = &sqliteConversationRepository{db: db}
 
 	// Initialize database schema
 	if err := manager.initSchema(); err != nil {
@@ -43,6 +65,51 @@ func (m *sqliteRepositoryManager) Executions() ExecutionRepository {
 	return m.executionRepo
 }
 
+// SavedFilters returns the saved filter repository
+func (m *sqliteRepositoryManager) SavedFilters() SavedFilterRepository {
+	return m.savedFilterRepo
+}
+
+// BootstrapBackups returns the bootstrap backup repository
+func (m *sqliteRepositoryManager) BootstrapBackups() BootstrapBackupRepository {
+	return m.bootstrapRepo
+}
+
+// Notebooks returns the notebook repository
+func (m *sqliteRepositoryManager) Notebooks() NotebookRepository {
+	return m.notebookRepo
+}
+
+// Sync returns the repository of the engine sync channel
+func (m *sqliteRepositoryManager) Sync() SyncRepository {
+	return m.syncRepo
+}
+
+// FeatureFlags returns the feature flag repository
+func (m *sqliteRepositoryManager) FeatureFlags() FeatureFlagRepository {
+	return m.flagRepo
+}
+
+// AdminAudit returns the repository of the admin audit log
+func (m *sqliteRepositoryManager) AdminAudit() AdminAuditRepository {
+	return m.auditRepo
+}
+
+// EventLog returns the engine event log repository
+func (m *sqliteRepositoryManager) EventLog() EventLogRepository {
+	return m.eventRepo
+}
+
+// Cassettes returns the repository of recorded outbound responses
+func (m *sqliteRepositoryManager) Cassettes() CassetteRepository {
+	return m.cassetteRepo
+}
+
+// Conversations returns the repository of recorded AI conversations
+func (m *sqliteRepositoryManager) Conversations() ConversationRepository {
+	return m.convRepo
+}
+
 // Close closes the database connection
 func (m *sqliteRepositoryManager) Close() error {
 	return m.db.Close()
@@ -65,6 +132,148 @@ func (m *sqliteRepositoryManager) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_script_executions_session_id ON script_executions(session_id);
 	CREATE INDEX IF NOT EXISTS idx_script_executions_timestamp ON script_executions(timestamp);
 	CREATE INDEX IF NOT EXISTS idx_script_executions_source ON script_executions(source);
+
+	CREATE TABLE IF NOT EXISTS saved_filters (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		page TEXT NOT NULL,
+		filter TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(page, name)
+	);
+
+	CREATE TABLE IF NOT EXISTS bootstrap_backups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file TEXT NOT NULL,
+		content TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT '',
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_bootstrap_backups_file ON bootstrap_backups(file);
+
+	CREATE TABLE IF NOT EXISTS execution_result_pages (
+		execution_id INTEGER NOT NULL,
+		page INTEGER NOT NULL,
+		items TEXT NOT NULL,
+		PRIMARY KEY (execution_id, page)
+	);
+
+	CREATE TRIGGER IF NOT EXISTS delete_execution_result_pages AFTER DELETE ON script_executions
+	BEGIN
+		DELETE FROM execution_result_pages WHERE execution_id = OLD.id;
+	END;
+
+	CREATE TABLE IF NOT EXISTS notebooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		cells TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS sync_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		instance_id TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS execution_attachments (
+		execution_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		data BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (execution_id, name)
+	);
+
+	CREATE TRIGGER IF NOT EXISTS delete_execution_attachments AFTER DELETE ON script_executions
+	BEGIN
+		DELETE FROM execution_attachments WHERE execution_id = OLD.id;
+	END;
+
+	CREATE TABLE IF NOT EXISTS code_blobs (
+		hash TEXT PRIMARY KEY,
+		code TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS feature_flags (
+		name TEXT PRIMARY KEY,
+		description TEXT NOT NULL DEFAULT '',
+		enabled INTEGER NOT NULL DEFAULT 0,
+		percentage INTEGER NOT NULL DEFAULT 100,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS admin_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		summary TEXT NOT NULL DEFAULT '',
+		status INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_admin_audit_created_at ON admin_audit(created_at);
+
+	CREATE TABLE IF NOT EXISTS event_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		session_id TEXT NOT NULL DEFAULT '',
+		method TEXT NOT NULL DEFAULT '',
+		path TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_event_log_created_at ON event_log(created_at);
+
+	CREATE TABLE IF NOT EXISTS cassette_interactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cassette TEXT NOT NULL,
+		request_key TEXT NOT NULL,
+		method TEXT NOT NULL,
+		url TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		headers TEXT NOT NULL DEFAULT '{}',
+		body BLOB,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_cassette_interactions_cassette ON cassette_interactions(cassette, id);
+
+	CREATE TABLE IF NOT EXISTS ai_conversations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL DEFAULT '',
+		model TEXT NOT NULL DEFAULT '',
+		session_id TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS ai_conversation_messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		content TEXT NOT NULL DEFAULT '',
+		tool_calls TEXT NOT NULL DEFAULT '[]',
+		tool_call_id TEXT NOT NULL DEFAULT '',
+		tokens TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_ai_conversation_messages_conversation ON ai_conversation_messages(conversation_id, id);
 	`
 
 	_, err := m.db.Exec(query)
@@ -72,58 +281,171 @@ func (m *sqliteRepositoryManager) initSchema() error {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
 
+	// Columns added after the initial schema, for databases created by older versions
+	if err := m.ensureColumn("script_executions", "request_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("script_executions", "truncation", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("script_executions", "actor", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("script_executions", "paging", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("script_executions", "saved_as", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("script_executions", "code_hash", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("script_executions", "changes", "TEXT"); err != nil {
+		return err
+	}
+	if err := m.ensureColumn("execution_attachments", "storage_key", "TEXT"); err != nil {
+		return err
+	}
+
+	if _, err := m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_script_executions_request_id ON script_executions(request_id);`); err != nil {
+		return fmt.Errorf("failed to create request_id index: %w", err)
+	}
+	if _, err := m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_script_executions_actor ON script_executions(actor);`); err != nil {
+		return fmt.Errorf("failed to create actor index: %w", err)
+	}
+	if _, err := m.db.Exec(`CREATE INDEX IF NOT EXISTS idx_script_executions_code_hash ON script_executions(code_hash);`); err != nil {
+		return fmt.Errorf("failed to create code_hash index: %w", err)
+	}
+
+	// A code blob goes away with the last execution that ran the code
+	if _, err := m.db.Exec(`
+	CREATE TRIGGER IF NOT EXISTS delete_unused_code_blobs AFTER DELETE ON script_executions
+	WHEN OLD.code_hash IS NOT NULL
+	BEGIN
+		DELETE FROM code_blobs WHERE hash = OLD.code_hash
+			AND NOT EXISTS (SELECT 1 FROM script_executions WHERE code_hash = OLD.code_hash);
+	END;`); err != nil {
+		return fmt.Errorf("failed to create code blob trigger: %w", err)
+	}
+	if err := m.migrateExecutionCode(); err != nil {
+		return err
+	}
+
 	log.Debug().Msg("Database schema initialized")
 	return nil
 }
 
-// sqliteExecutionRepository implements ExecutionRepository for SQLite
-type sqliteExecutionRepository struct {
-	db *sql.DB
-}
+// ensureColumn adds a column to a table if it does not exist yet
+func (m *sqliteRepositoryManager) ensureColumn(table, column, definition string) error {
+	rows, err := m.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
 
-// CreateExecution stores a new script execution
-func (r *sqliteExecutionRepository) CreateExecution(ctx context.Context, req CreateExecutionRequest) (*ScriptExecution, error) {
-	query := `
-	INSERT INTO script_executions (session_id, code, result, console_log, error, source)
-	VALUES (?, ?, ?, ?, ?, ?)
-	RETURNING id, session_id, code, result, console_log, error, timestamp, source
-	`
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating table info: %w", err)
+	}
 
-	var execution ScriptExecution
-	err := r.db.QueryRowContext(ctx, query, req.SessionID, req.Code, req.Result, req.ConsoleLog, req.Error, req.Source).Scan(
-		&execution.ID,
-		&execution.SessionID,
-		&execution.Code,
-		&execution.Result,
-		&execution.ConsoleLog,
-		&execution.Error,
-		&execution.Timestamp,
-		&execution.Source,
-	)
+	if _, err := m.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	log.Info().Str("table", table).Str("column", column).Msg("Migrated database schema")
+	return nil
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to create execution: %w", err)
+// migrateExecutionCode moves the code of executions stored by older versions, which kept
+// it in the code column of every execution, into code_blobs
+func (m *sqliteRepositoryManager) migrateExecutionCode() error {
+	const batchSize = 500
+	migrated := 0
+	for {
+		rows, err := m.db.Query("SELECT id, code FROM script_executions WHERE code_hash IS NULL LIMIT ?", batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to query executions to migrate: %w", err)
+		}
+		type pending struct {
+			id   int
+			code string
+		}
+		var batch []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.code); err != nil {
+				_ = rows.Close()
+				return fmt.Errorf("failed to scan execution to migrate: %w", err)
+			}
+			batch = append(batch, p)
+		}
+		if err := rows.Close(); err != nil {
+			return fmt.Errorf("failed to close database rows: %w", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		tx, err := m.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		for _, p := range batch {
+			hash, err := storeCodeBlob(context.Background(), tx, p.code)
+			if err == nil {
+				_, err = tx.Exec("UPDATE script_executions SET code = '', code_hash = ? WHERE id = ?", hash, p.id)
+			}
+			if err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("failed to migrate code of execution %d: %w", p.id, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit code migration: %w", err)
+		}
+		migrated += len(batch)
 	}
 
-	log.Debug().
-		Str("sessionID", execution.SessionID).
-		Int("id", execution.ID).
-		Str("source", execution.Source).
-		Msg("Script execution stored")
+	if migrated > 0 {
+		log.Info().Int("executions", migrated).Msg("Moved execution code into de-duplicated code blobs")
+	}
+	return nil
+}
 
-	return &execution, nil
+// sqliteExecutionRepository implements ExecutionRepository for SQLite
+type sqliteExecutionRepository struct {
+	db *sql.DB
 }
 
-// GetExecution retrieves a script execution by ID
-func (r *sqliteExecutionRepository) GetExecution(ctx context.Context, id int) (*ScriptExecution, error) {
-	query := `
-	SELECT id, session_id, code, result, console_log, error, timestamp, source
-	FROM script_executions 
-	WHERE id = ?
-	`
+// executionCode selects the code of an execution from its code blob. Executions stored
+// before code blobs existed keep their code in the code column until they are migrated.
+const executionCode = "COALESCE((SELECT code_blobs.code FROM code_blobs WHERE code_blobs.hash = script_executions.code_hash), script_executions.code)"
 
-	var execution ScriptExecution
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+// executionColumns lists the script_executions columns in the order scanExecution expects them
+const executionColumns = "id, session_id, " + executionCode + ", result, console_log, error, timestamp, source, request_id, truncation, actor, paging, saved_as, COALESCE(code_hash, ''), changes"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanExecution scans a row selected with executionColumns into a ScriptExecution
+func scanExecution(row rowScanner, execution *ScriptExecution) error {
+	var truncation, paging, changes sql.NullString
+	if err := row.Scan(
 		&execution.ID,
 		&execution.SessionID,
 		&execution.Code,
@@ -132,107 +454,181 @@ func (r *sqliteExecutionRepository) GetExecution(ctx context.Context, id int) (*
 		&execution.Error,
 		&execution.Timestamp,
 		&execution.Source,
-	)
+		&execution.RequestID,
+		&truncation,
+		&execution.Actor,
+		&paging,
+		&execution.SavedAs,
+		&execution.CodeHash,
+		&changes,
+	); err != nil {
+		return err
+	}
 
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("execution with id %d not found", id)
+	if truncation.Valid && truncation.String != "" {
+		execution.Truncation = &OutputTruncation{}
+		if err := json.Unmarshal([]byte(truncation.String), execution.Truncation); err != nil {
+			return fmt.Errorf("failed to decode truncation of execution %d: %w", execution.ID, err)
 		}
-		return nil, fmt.Errorf("failed to get execution: %w", err)
 	}
-
-	return &execution, nil
+	if paging.Valid && paging.String != "" {
+		execution.Paging = &ResultPaging{}
+		if err := json.Unmarshal([]byte(paging.String), execution.Paging); err != nil {
+			return fmt.Errorf("failed to decode paging of execution %d: %w", execution.ID, err)
+		}
+	}
+	if changes.Valid && changes.String != "" {
+		execution.Changes = &ExecutionChanges{}
+		if err := json.Unmarshal([]byte(changes.String), execution.Changes); err != nil {
+			return fmt.Errorf("failed to decode changes of execution %d: %w", execution.ID, err)
+		}
+	}
+	return nil
 }
 
-// GetExecutionBySessionID retrieves a script execution by session ID
-func (r *sqliteExecutionRepository) GetExecutionBySessionID(ctx context.Context, sessionID string) (*ScriptExecution, error) {
+// CreateExecution stores a new script execution, along with the pages of a paged result and its attachments
+func (r *sqliteExecutionRepository) CreateExecution(ctx context.Context, req CreateExecutionRequest) (*ScriptExecution, error) {
 	query := `
-	SELECT id, session_id, code, result, console_log, error, timestamp, source
-	FROM script_executions 
-	WHERE session_id = ?
-	ORDER BY timestamp DESC
-	LIMIT 1
-	`
+	INSERT INTO script_executions (session_id, code, code_hash, result, console_log, error, source, request_id, truncation, actor, paging, saved_as, changes)
+	VALUES (?, '', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING ` + executionColumns
 
-	var execution ScriptExecution
-	err := r.db.QueryRowContext(ctx, query, sessionID).Scan(
-		&execution.ID,
-		&execution.SessionID,
-		&execution.Code,
-		&execution.Result,
-		&execution.ConsoleLog,
-		&execution.Error,
-		&execution.Timestamp,
-		&execution.Source,
-	)
+	truncation, err := encodeJSONColumn(req.Truncation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode truncation: %w", err)
+	}
+	paging, err := encodeJSONColumn(req.Paging)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode paging: %w", err)
+	}
+	changes, err := encodeJSONColumn(req.Changes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode changes: %w", err)
+	}
 
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("execution with session_id %s not found", sessionID)
-		}
-		return nil, fmt.Errorf("failed to get execution by session ID: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer func() {
+		// Rollback is a no-op once the transaction is committed
+		_ = tx.Rollback()
+	}()
 
-	return &execution, nil
-}
+	// The code is stored once, however often it runs
+	hash, err := storeCodeBlob(ctx, tx, req.Code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store code: %w", err)
+	}
 
-// ListExecutions retrieves script executions with filtering and pagination
-func (r *sqliteExecutionRepository) ListExecutions(ctx context.Context, filter ExecutionFilter, pagination PaginationOptions) (*ExecutionQueryResult, error) {
-	// Build WHERE clause
-	var whereClause string
-	var args []interface{}
-	var conditions []string
+	var execution ScriptExecution
+	err = scanExecution(tx.QueryRowContext(ctx, query, req.SessionID, hash, req.Result, req.ConsoleLog, req.Error, req.Source, req.RequestID, truncation, req.Actor, paging, req.SavedAs, changes), &execution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create execution: %w", err)
+	}
 
-	if filter.Search != "" {
-		conditions = append(conditions, "(code LIKE ? OR result LIKE ? OR console_log LIKE ?)")
-		searchTerm := "%" + filter.Search + "%"
-		args = append(args, searchTerm, searchTerm, searchTerm)
+	if req.Paging != nil {
+		for page, items := range req.Pages {
+			data, err := json.Marshal(items)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode result page %d: %w", page, err)
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO execution_result_pages (execution_id, page, items) VALUES (?, ?, ?)", execution.ID, page, string(data)); err != nil {
+				return nil, fmt.Errorf("failed to store result page %d: %w", page, err)
+			}
+		}
 	}
 
-	if filter.SessionID != "" {
-		conditions = append(conditions, "session_id = ?")
-		args = append(args, filter.SessionID)
+	for _, attachment := range req.Attachments {
+		// Attachments kept in storage leave an empty data column
+		data := attachment.Data
+		if data == nil {
+			data = []byte{}
+		}
+		var storageKey *string
+		if attachment.StorageKey != "" {
+			storageKey = &attachment.StorageKey
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO execution_attachments (execution_id, name, mime_type, size, data, storage_key) VALUES (?, ?, ?, ?, ?, ?)",
+			execution.ID, attachment.Name, attachment.MimeType, attachment.Size, data, storageKey); err != nil {
+			return nil, fmt.Errorf("failed to store attachment %s: %w", attachment.Name, err)
+		}
 	}
 
-	if filter.Source != "" {
-		conditions = append(conditions, "source = ?")
-		args = append(args, filter.Source)
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit execution: %w", err)
 	}
 
-	if filter.FromDate != nil {
-		conditions = append(conditions, "timestamp >= ?")
-		args = append(args, filter.FromDate)
+	log.Debug().
+		Str("sessionID", execution.SessionID).
+		Int("id", execution.ID).
+		Str("source", execution.Source).
+		Int("resultPages", len(req.Pages)).
+		Int("attachments", len(req.Attachments)).
+		Msg("Script execution stored")
+
+	return &execution, nil
+}
+
+// parseSQLiteTime parses a timestamp that SQLite returned as text, as it does for MIN and MAX
+func parseSQLiteTime(value string) time.Time {
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t
+		}
 	}
+	return time.Time{}
+}
 
-	if filter.ToDate != nil {
-		conditions = append(conditions, "timestamp <= ?")
-		args = append(args, filter.ToDate)
+// storeCodeBlob stores code under its SHA-256 hash unless it is stored already, and returns the hash
+func storeCodeBlob(ctx context.Context, tx *sql.Tx, code string) (string, error) {
+	sum := sha256.Sum256([]byte(code))
+	hash := hex.EncodeToString(sum[:])
+	if _, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO code_blobs (hash, code) VALUES (?, ?)", hash, code); err != nil {
+		return "", err
 	}
+	return hash, nil
+}
 
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+// encodeJSONColumn encodes v for a nullable JSON column
+func encodeJSONColumn[T any](v *T) (*string, error) {
+	if v == nil {
+		return nil, nil
 	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	return &encoded, nil
+}
 
-	// Get total count
-	countQuery := "SELECT COUNT(*) FROM script_executions " + whereClause
-	var total int
-	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+// GetResultSlice retrieves up to limit items of the paged result of an execution, starting at offset
+func (r *sqliteExecutionRepository) GetResultSlice(ctx context.Context, executionID, offset, limit int) (*ResultSlice, error) {
+	execution, err := r.GetExecution(ctx, executionID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get total count: %w", err)
+		return nil, err
+	}
+	if execution.Paging == nil {
+		return nil, fmt.Errorf("execution %d has no paged result", executionID)
 	}
+	paging := execution.Paging
 
-	// Get paginated results
-	query := fmt.Sprintf(`
-	SELECT id, session_id, code, result, console_log, error, timestamp, source 
-	FROM script_executions %s
-	ORDER BY timestamp DESC 
-	LIMIT ? OFFSET ?
-	`, whereClause)
+	slice := &ResultSlice{Kind: paging.Kind, Offset: offset, Total: paging.Items, Items: []json.RawMessage{}}
+	if offset < 0 || limit <= 0 || offset >= paging.Items || paging.PageSize <= 0 {
+		return slice, nil
+	}
+	end := offset + limit
+	if end > paging.Items {
+		end = paging.Items
+	}
 
-	paginationArgs := append(args, pagination.Limit, pagination.Offset)
-	rows, err := r.db.QueryContext(ctx, query, paginationArgs...)
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT page, items FROM execution_result_pages WHERE execution_id = ? AND page BETWEEN ? AND ? ORDER BY page",
+		executionID, offset/paging.PageSize, (end-1)/paging.PageSize)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query script executions: %w", err)
+		return nil, fmt.Errorf("failed to query result pages: %w", err)
 	}
 	defer func() {
 		if err := rows.Close(); err != nil {
@@ -240,26 +636,315 @@ func (r *sqliteExecutionRepository) ListExecutions(ctx context.Context, filter E
 		}
 	}()
 
-	var executions []ScriptExecution
 	for rows.Next() {
-		var exec ScriptExecution
-		err := rows.Scan(
-			&exec.ID,
-			&exec.SessionID,
-			&exec.Code,
-			&exec.Result,
-			&exec.ConsoleLog,
-			&exec.Error,
-			&exec.Timestamp,
-			&exec.Source,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+		var page int
+		var data string
+		if err := rows.Scan(&page, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan result page: %w", err)
 		}
+		var items []json.RawMessage
+		if err := json.Unmarshal([]byte(data), &items); err != nil {
+			return nil, fmt.Errorf("failed to decode result page %d: %w", page, err)
+		}
+		for i, item := range items {
+			index := page*paging.PageSize + i
+			if index >= offset && index < end {
+				slice.Items = append(slice.Items, item)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating result pages: %w", err)
+	}
+	return slice, nil
+}
+
+// GetExecution retrieves a script execution by ID
+func (r *sqliteExecutionRepository) GetExecution(ctx context.Context, id int) (*ScriptExecution, error) {
+	query := `
+	SELECT ` + executionColumns + `
+	FROM script_executions 
+	WHERE id = ?
+	`
+
+	var execution ScriptExecution
+	err := scanExecution(r.db.QueryRowContext(ctx, query, id), &execution)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("execution with id %d %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get execution: %w", err)
+	}
+
+	execution.Attachments, err = r.listAttachments(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if execution.CodeHash != "" {
+		execution.CodeUsage, err = r.GetCodeUsage(ctx, execution.CodeHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &execution, nil
+}
+
+// GetCodeUsage summarizes the executions that ran the code with the given hash
+func (r *sqliteExecutionRepository) GetCodeUsage(ctx context.Context, hash string) (*CodeUsage, error) {
+	usage := CodeUsage{Hash: hash, Actors: []string{}}
+	var firstRun, lastRun sql.NullString
+	err := r.db.QueryRowContext(ctx, `
+	SELECT LENGTH(CAST(b.code AS BLOB)), COUNT(e.id), COUNT(DISTINCT e.session_id), MIN(e.timestamp), MAX(e.timestamp)
+	FROM code_blobs b LEFT JOIN script_executions e ON e.code_hash = b.hash
+	WHERE b.hash = ?
+	GROUP BY b.hash`, hash).Scan(&usage.Size, &usage.Runs, &usage.Sessions, &firstRun, &lastRun)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("code with hash %s %w", hash, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get code usage: %w", err)
+	}
+	usage.FirstRun = parseSQLiteTime(firstRun.String)
+	usage.LastRun = parseSQLiteTime(lastRun.String)
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT DISTINCT actor FROM script_executions WHERE code_hash = ? AND actor IS NOT NULL AND actor != '' ORDER BY actor", hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query code actors: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+	for rows.Next() {
+		var actor string
+		if err := rows.Scan(&actor); err != nil {
+			return nil, fmt.Errorf("failed to scan code actor: %w", err)
+		}
+		usage.Actors = append(usage.Actors, actor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating code actors: %w", err)
+	}
+	return &usage, nil
+}
+
+// listAttachments retrieves the attachments of an execution without their data
+func (r *sqliteExecutionRepository) listAttachments(ctx context.Context, executionID int) ([]ExecutionAttachment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT execution_id, name, mime_type, size, created_at FROM execution_attachments WHERE execution_id = ? ORDER BY created_at, name",
+		executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	var attachments []ExecutionAttachment
+	for rows.Next() {
+		var attachment ExecutionAttachment
+		if err := rows.Scan(&attachment.ExecutionID, &attachment.Name, &attachment.MimeType, &attachment.Size, &attachment.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachments: %w", err)
+	}
+	return attachments, nil
+}
+
+// GetAttachment retrieves a file attached to an execution, including its data
+func (r *sqliteExecutionRepository) GetAttachment(ctx context.Context, executionID int, name string) (*ExecutionAttachment, error) {
+	var attachment ExecutionAttachment
+	err := r.db.QueryRowContext(ctx,
+		"SELECT execution_id, name, mime_type, size, data, COALESCE(storage_key, ''), created_at FROM execution_attachments WHERE execution_id = ? AND name = ?",
+		executionID, name).Scan(&attachment.ExecutionID, &attachment.Name, &attachment.MimeType, &attachment.Size, &attachment.Data, &attachment.StorageKey, &attachment.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attachment %s of execution %d %w", name, executionID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// ListAttachmentStorageKeys returns the storage keys of the attachments kept outside the database
+func (r *sqliteExecutionRepository) ListAttachmentStorageKeys(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT storage_key FROM execution_attachments WHERE storage_key IS NOT NULL")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachment storage keys: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close rows")
+		}
+	}()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment storage key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating attachment storage keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetExecutionBySessionID retrieves a script execution by session ID
+func (r *sqliteExecutionRepository) GetExecutionBySessionID(ctx context.Context, sessionID string) (*ScriptExecution, error) {
+	query := `
+	SELECT ` + executionColumns + `
+	FROM script_executions 
+	WHERE session_id = ?
+	ORDER BY timestamp DESC
+	LIMIT 1
+	`
+
+	var execution ScriptExecution
+	err := scanExecution(r.db.QueryRowContext(ctx, query, sessionID), &execution)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("execution with session_id %s %w", sessionID, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get execution by session ID: %w", err)
+	}
+
+	return &execution, nil
+}
+
+// buildExecutionWhereClause translates an execution filter into a SQL WHERE clause and its
+// arguments. Malformed values are an ErrInvalidFilter rather than dropped, so a typo cannot
+// widen a query or a delete to every execution.
+func buildExecutionWhereClause(filter ExecutionFilter) (string, []interface{}, error) {
+	var args []interface{}
+	var conditions []string
+
+	if filter.Search != "" {
+		conditions = append(conditions, "("+executionCode+" LIKE ? OR result LIKE ? OR console_log LIKE ?)")
+		searchTerm := "%" + filter.Search + "%"
+		args = append(args, searchTerm, searchTerm, searchTerm)
+	}
+
+	if filter.SessionID != "" {
+		conditions = append(conditions, "session_id = ?")
+		args = append(args, filter.SessionID)
+	}
+
+	if filter.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, filter.Source)
+	}
+
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+
+	if filter.RequestID != "" {
+		conditions = append(conditions, "request_id = ?")
+		args = append(args, filter.RequestID)
+	}
+
+	if filter.CodeHash != "" {
+		conditions = append(conditions, "code_hash = ?")
+		args = append(args, filter.CodeHash)
+	}
+
+	if filter.HasError != nil {
+		if *filter.HasError {
+			conditions = append(conditions, "(error IS NOT NULL AND error != '')")
+		} else {
+			conditions = append(conditions, "(error IS NULL OR error = '')")
+		}
+	}
+
+	if filter.Since != "" {
+		window, err := time.ParseDuration(filter.Since)
+		if err != nil || window <= 0 {
+			return "", nil, fmt.Errorf("%w: since %q is not a time window such as 30m or 24h", ErrInvalidFilter, filter.Since)
+		}
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, time.Now().UTC().Add(-window).Format("2006-01-02 15:04:05"))
+	}
+
+	if filter.BeforeID > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, filter.BeforeID)
+	}
+
+	if filter.FromDate != nil {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, filter.FromDate)
+	}
+
+	if filter.ToDate != nil {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, filter.ToDate)
+	}
+
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// ListExecutions retrieves script executions with filtering and pagination
+func (r *sqliteExecutionRepository) ListExecutions(ctx context.Context, filter ExecutionFilter, pagination PaginationOptions) (*ExecutionQueryResult, error) {
+	whereClause, args, err := buildExecutionWhereClause(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get total count
+	countQuery := "SELECT COUNT(*) FROM script_executions " + whereClause
+	var total int
+	err = r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	// Get paginated results
+	query := fmt.Sprintf(`
+	SELECT %s 
+	FROM script_executions %s
+	ORDER BY timestamp DESC, id DESC
+	LIMIT ? OFFSET ?
+	`, executionColumns, whereClause)
+
+	paginationArgs := append(args, pagination.Limit, pagination.Offset)
+	rows, err := r.db.QueryContext(ctx, query, paginationArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query script executions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	var executions []ScriptExecution
+	for rows.Next() {
+		var exec ScriptExecution
+		if err := scanExecution(rows, &exec); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		executions = append(executions, exec)
+	}
 
-		executions = append(executions, exec)
-	}
-
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
 	}
@@ -286,7 +971,7 @@ func (r *sqliteExecutionRepository) DeleteExecution(ctx context.Context, id int)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("execution with id %d not found", id)
+		return fmt.Errorf("execution with id %d %w", id, ErrNotFound)
 	}
 
 	return nil
@@ -303,6 +988,55 @@ func (r *sqliteExecutionRepository) DeleteExecutionsBySessionID(ctx context.Cont
 	return nil
 }
 
+// DeleteExecutionsByIDs removes the executions with the given IDs
+func (r *sqliteExecutionRepository) DeleteExecutionsByIDs(ctx context.Context, ids []int) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	query := "DELETE FROM script_executions WHERE id IN (" + strings.Join(placeholders, ", ") + ")"
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete executions by IDs: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
+
+// DeleteExecutions removes all executions matching the filter
+func (r *sqliteExecutionRepository) DeleteExecutions(ctx context.Context, filter ExecutionFilter) (int64, error) {
+	whereClause, args, err := buildExecutionWhereClause(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	query := "DELETE FROM script_executions " + whereClause
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete executions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	log.Debug().Int64("deleted", rowsAffected).Msg("Script executions deleted by filter")
+	return rowsAffected, nil
+}
+
 // GetExecutionStats returns statistics about script executions
 func (r *sqliteExecutionRepository) GetExecutionStats(ctx context.Context) (*ExecutionStats, error) {
 	stats := &ExecutionStats{
@@ -324,6 +1058,11 @@ func (r *sqliteExecutionRepository) GetExecutionStats(ctx context.Context) (*Exe
 	// Calculate failed executions
 	stats.FailedExecutions = stats.TotalExecutions - stats.SuccessfulExecutions
 
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM code_blobs").Scan(&stats.DistinctCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count code blobs: %w", err)
+	}
+
 	// Get executions by source
 	rows, err := r.db.QueryContext(ctx, "SELECT source, COUNT(*) FROM script_executions GROUP BY source")
 	if err != nil {
@@ -346,3 +1085,973 @@ func (r *sqliteExecutionRepository) GetExecutionStats(ctx context.Context) (*Exe
 
 	return stats, nil
 }
+
+// sqliteSavedFilterRepository implements SavedFilterRepository for SQLite
+type sqliteSavedFilterRepository struct {
+	db *sql.DB
+}
+
+// SaveFilter stores a named filter, replacing any filter with the same name on the same page
+func (r *sqliteSavedFilterRepository) SaveFilter(ctx context.Context, req SaveFilterRequest) (*SavedFilter, error) {
+	filterJSON, err := json.Marshal(req.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode filter: %w", err)
+	}
+
+	query := `
+	INSERT INTO saved_filters (name, page, filter)
+	VALUES (?, ?, ?)
+	ON CONFLICT(page, name) DO UPDATE SET filter = excluded.filter
+	`
+	if _, err := r.db.ExecContext(ctx, query, req.Name, req.Page, string(filterJSON)); err != nil {
+		return nil, fmt.Errorf("failed to save filter: %w", err)
+	}
+
+	row := r.db.QueryRowContext(ctx, "SELECT id, name, page, filter, created_at FROM saved_filters WHERE page = ? AND name = ?", req.Page, req.Name)
+	var saved SavedFilter
+	if err := scanSavedFilter(row, &saved); err != nil {
+		return nil, fmt.Errorf("failed to get saved filter: %w", err)
+	}
+
+	return &saved, nil
+}
+
+// ListFilters retrieves the saved filters of a page, or of all pages if page is empty
+func (r *sqliteSavedFilterRepository) ListFilters(ctx context.Context, page string) ([]SavedFilter, error) {
+	query := "SELECT id, name, page, filter, created_at FROM saved_filters"
+	var args []interface{}
+	if page != "" {
+		query += " WHERE page = ?"
+		args = append(args, page)
+	}
+	query += " ORDER BY name"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved filters: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	filters := []SavedFilter{}
+	for rows.Next() {
+		var saved SavedFilter
+		if err := scanSavedFilter(rows, &saved); err != nil {
+			return nil, fmt.Errorf("failed to scan saved filter: %w", err)
+		}
+		filters = append(filters, saved)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return filters, nil
+}
+
+// DeleteFilter removes a saved filter by ID
+func (r *sqliteSavedFilterRepository) DeleteFilter(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM saved_filters WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved filter: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("saved filter not found")
+	}
+
+	return nil
+}
+
+// scanSavedFilter scans a saved_filters row and decodes its filter JSON
+func scanSavedFilter(row rowScanner, saved *SavedFilter) error {
+	var filterJSON string
+	if err := row.Scan(&saved.ID, &saved.Name, &saved.Page, &filterJSON, &saved.CreatedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(filterJSON), &saved.Filter); err != nil {
+		return fmt.Errorf("failed to decode filter: %w", err)
+	}
+	return nil
+}
+
+// sqliteBootstrapBackupRepository implements BootstrapBackupRepository for SQLite
+type sqliteBootstrapBackupRepository struct {
+	db *sql.DB
+}
+
+const bootstrapBackupColumns = "id, file, content, hash, status, error, created_at"
+
+// SaveBackup stores a backup of a file, unless its latest backup has the same content, which it returns instead
+func (r *sqliteBootstrapBackupRepository) SaveBackup(ctx context.Context, file, content string) (*BootstrapBackup, error) {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	row := r.db.QueryRowContext(ctx, "SELECT "+bootstrapBackupColumns+" FROM bootstrap_backups WHERE file = ? ORDER BY id DESC LIMIT 1", file)
+	var latest BootstrapBackup
+	err := scanBootstrapBackup(row, &latest)
+	switch {
+	case err == nil && latest.Hash == hash:
+		return &latest, nil
+	case err != nil && err != sql.ErrNoRows:
+		return nil, fmt.Errorf("failed to get latest bootstrap backup: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, "INSERT INTO bootstrap_backups (file, content, hash) VALUES (?, ?, ?)", file, content, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save bootstrap backup: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bootstrap backup ID: %w", err)
+	}
+	return r.GetBackup(ctx, int(id))
+}
+
+// GetBackup retrieves a backup by ID
+func (r *sqliteBootstrapBackupRepository) GetBackup(ctx context.Context, id int) (*BootstrapBackup, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+bootstrapBackupColumns+" FROM bootstrap_backups WHERE id = ?", id)
+	var backup BootstrapBackup
+	if err := scanBootstrapBackup(row, &backup); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("bootstrap backup not found")
+		}
+		return nil, fmt.Errorf("failed to get bootstrap backup: %w", err)
+	}
+	return &backup, nil
+}
+
+// ListBackups retrieves the backups of a file, newest first
+func (r *sqliteBootstrapBackupRepository) ListBackups(ctx context.Context, file string) ([]BootstrapBackup, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+bootstrapBackupColumns+" FROM bootstrap_backups WHERE file = ? ORDER BY id DESC", file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query bootstrap backups: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	backups := []BootstrapBackup{}
+	for rows.Next() {
+		var backup BootstrapBackup
+		if err := scanBootstrapBackup(rows, &backup); err != nil {
+			return nil, fmt.Errorf("failed to scan bootstrap backup: %w", err)
+		}
+		backups = append(backups, backup)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return backups, nil
+}
+
+// SetBackupStatus records whether running a backup succeeded
+func (r *sqliteBootstrapBackupRepository) SetBackupStatus(ctx context.Context, id int, status string, errorMessage *string) error {
+	if _, err := r.db.ExecContext(ctx, "UPDATE bootstrap_backups SET status = ?, error = ? WHERE id = ?", status, errorMessage, id); err != nil {
+		return fmt.Errorf("failed to update bootstrap backup status: %w", err)
+	}
+	return nil
+}
+
+// PruneBackups removes all but the newest keep backups of a file
+func (r *sqliteBootstrapBackupRepository) PruneBackups(ctx context.Context, file string, keep int) error {
+	query := `
+	DELETE FROM bootstrap_backups
+	WHERE file = ? AND id NOT IN (
+		SELECT id FROM bootstrap_backups WHERE file = ? ORDER BY id DESC LIMIT ?
+	)
+	`
+	if _, err := r.db.ExecContext(ctx, query, file, file, keep); err != nil {
+		return fmt.Errorf("failed to prune bootstrap backups: %w", err)
+	}
+	return nil
+}
+
+// scanBootstrapBackup scans a bootstrap_backups row
+func scanBootstrapBackup(row rowScanner, backup *BootstrapBackup) error {
+	var errorMessage sql.NullString
+	if err := row.Scan(&backup.ID, &backup.File, &backup.Content, &backup.Hash, &backup.Status, &errorMessage, &backup.CreatedAt); err != nil {
+		return err
+	}
+	if errorMessage.Valid {
+		backup.Error = &errorMessage.String
+	}
+	return nil
+}
+
+// sqliteNotebookRepository implements NotebookRepository for SQLite
+type sqliteNotebookRepository struct {
+	db *sql.DB
+}
+
+const notebookColumns = "id, name, cells, created_at, updated_at"
+
+// CreateNotebook stores a new notebook
+func (r *sqliteNotebookRepository) CreateNotebook(ctx context.Context, req SaveNotebookRequest) (*Notebook, error) {
+	cells, err := encodeNotebookCells(req.Cells)
+	if err != nil {
+		return nil, err
+	}
+
+	var notebook Notebook
+	row := r.db.QueryRowContext(ctx, "INSERT INTO notebooks (name, cells) VALUES (?, ?) RETURNING "+notebookColumns, req.Name, cells)
+	if err := scanNotebook(row, &notebook); err != nil {
+		return nil, fmt.Errorf("failed to create notebook: %w", err)
+	}
+	return &notebook, nil
+}
+
+// UpdateNotebook replaces the name and cells of a notebook
+func (r *sqliteNotebookRepository) UpdateNotebook(ctx context.Context, id int, req SaveNotebookRequest) (*Notebook, error) {
+	cells, err := encodeNotebookCells(req.Cells)
+	if err != nil {
+		return nil, err
+	}
+
+	var notebook Notebook
+	row := r.db.QueryRowContext(ctx,
+		"UPDATE notebooks SET name = ?, cells = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? RETURNING "+notebookColumns,
+		req.Name, cells, id)
+	if err := scanNotebook(row, &notebook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("notebook with id %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to update notebook: %w", err)
+	}
+	return &notebook, nil
+}
+
+// GetNotebook retrieves a notebook by ID
+func (r *sqliteNotebookRepository) GetNotebook(ctx context.Context, id int) (*Notebook, error) {
+	var notebook Notebook
+	row := r.db.QueryRowContext(ctx, "SELECT "+notebookColumns+" FROM notebooks WHERE id = ?", id)
+	if err := scanNotebook(row, &notebook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("notebook with id %d not found", id)
+		}
+		return nil, fmt.Errorf("failed to get notebook: %w", err)
+	}
+	return &notebook, nil
+}
+
+// ListNotebooks retrieves all notebooks, most recently updated first
+func (r *sqliteNotebookRepository) ListNotebooks(ctx context.Context) ([]Notebook, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+notebookColumns+" FROM notebooks ORDER BY updated_at DESC, id DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notebooks: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	notebooks := []Notebook{}
+	for rows.Next() {
+		var notebook Notebook
+		if err := scanNotebook(rows, &notebook); err != nil {
+			return nil, fmt.Errorf("failed to scan notebook: %w", err)
+		}
+		notebooks = append(notebooks, notebook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return notebooks, nil
+}
+
+// DeleteNotebook removes a notebook by ID
+func (r *sqliteNotebookRepository) DeleteNotebook(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM notebooks WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete notebook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("notebook not found")
+	}
+	return nil
+}
+
+// encodeNotebookCells encodes the cells column; a notebook without cells stores an empty list
+func encodeNotebookCells(cells []NotebookCell) (string, error) {
+	if cells == nil {
+		cells = []NotebookCell{}
+	}
+	data, err := json.Marshal(cells)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode notebook cells: %w", err)
+	}
+	return string(data), nil
+}
+
+// scanNotebook scans a notebooks row and decodes its cells JSON
+func scanNotebook(row rowScanner, notebook *Notebook) error {
+	var cells string
+	if err := row.Scan(&notebook.ID, &notebook.Name, &cells, &notebook.CreatedAt, &notebook.UpdatedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(cells), &notebook.Cells); err != nil {
+		return fmt.Errorf("failed to decode cells of notebook %d: %w", notebook.ID, err)
+	}
+	return nil
+}
+
+// sqliteSyncRepository implements SyncRepository for SQLite
+type sqliteSyncRepository struct {
+	db *sql.DB
+}
+
+// PublishSyncEvent stores an event for the other engines
+func (r *sqliteSyncRepository) PublishSyncEvent(ctx context.Context, instanceID, kind, payload string) (*SyncEvent, error) {
+	var event SyncEvent
+	row := r.db.QueryRowContext(ctx,
+		"INSERT INTO sync_events (instance_id, kind, payload) VALUES (?, ?, ?) RETURNING id, instance_id, kind, payload, created_at",
+		instanceID, kind, payload)
+	if err := row.Scan(&event.ID, &event.InstanceID, &event.Kind, &event.Payload, &event.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to publish sync event: %w", err)
+	}
+	return &event, nil
+}
+
+// ListSyncEvents retrieves the events after an ID published by other engines, oldest first
+func (r *sqliteSyncRepository) ListSyncEvents(ctx context.Context, afterID int, excludeInstance string) ([]SyncEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, instance_id, kind, payload, created_at FROM sync_events WHERE id > ? AND instance_id != ? ORDER BY id",
+		afterID, excludeInstance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sync events: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	var events []SyncEvent
+	for rows.Next() {
+		var event SyncEvent
+		if err := rows.Scan(&event.ID, &event.InstanceID, &event.Kind, &event.Payload, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sync event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return events, nil
+}
+
+// LatestSyncEvent retrieves the newest event of a kind, or of any kind for "". It returns
+// nil if there is none.
+func (r *sqliteSyncRepository) LatestSyncEvent(ctx context.Context, kind string) (*SyncEvent, error) {
+	var event SyncEvent
+	row := r.db.QueryRowContext(ctx,
+		"SELECT id, instance_id, kind, payload, created_at FROM sync_events WHERE ? = '' OR kind = ? ORDER BY id DESC LIMIT 1",
+		kind, kind)
+	if err := row.Scan(&event.ID, &event.InstanceID, &event.Kind, &event.Payload, &event.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest sync event: %w", err)
+	}
+	return &event, nil
+}
+
+// PruneSyncEvents removes events published before a time
+func (r *sqliteSyncRepository) PruneSyncEvents(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM sync_events WHERE created_at < ?", before.UTC().Format("2006-01-02 15:04:05")); err != nil {
+		return fmt.Errorf("failed to prune sync events: %w", err)
+	}
+	return nil
+}
+
+// sqliteFeatureFlagRepository implements FeatureFlagRepository for SQLite
+type sqliteFeatureFlagRepository struct {
+	db *sql.DB
+}
+
+const featureFlagColumns = "name, description, enabled, percentage, created_at, updated_at"
+
+// SaveFlag stores a flag, replacing any flag with the same name
+func (r *sqliteFeatureFlagRepository) SaveFlag(ctx context.Context, flag FeatureFlag) (*FeatureFlag, error) {
+	var saved FeatureFlag
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO feature_flags (name, description, enabled, percentage) VALUES (?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			description = excluded.description,
+			enabled = excluded.enabled,
+			percentage = excluded.percentage,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING `+featureFlagColumns,
+		flag.Name, flag.Description, flag.Enabled, flag.Percentage)
+	if err := row.Scan(&saved.Name, &saved.Description, &saved.Enabled, &saved.Percentage, &saved.CreatedAt, &saved.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	return &saved, nil
+}
+
+// ListFlags retrieves all flags, ordered by name
+func (r *sqliteFeatureFlagRepository) ListFlags(ctx context.Context) ([]FeatureFlag, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+featureFlagColumns+" FROM feature_flags ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	flags := []FeatureFlag{}
+	for rows.Next() {
+		var flag FeatureFlag
+		if err := rows.Scan(&flag.Name, &flag.Description, &flag.Enabled, &flag.Percentage, &flag.CreatedAt, &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %w", err)
+		}
+		flags = append(flags, flag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return flags, nil
+}
+
+// DeleteFlag removes a flag by name
+func (r *sqliteFeatureFlagRepository) DeleteFlag(ctx context.Context, name string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM feature_flags WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete feature flag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("feature flag %s %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+// sqliteAdminAuditRepository implements AdminAuditRepository for SQLite
+type sqliteAdminAuditRepository struct {
+	db *sql.DB
+}
+
+const adminAuditColumns = "id, actor, action, method, path, summary, status, created_at"
+
+// RecordAction stores an admin change
+func (r *sqliteAdminAuditRepository) RecordAction(ctx context.Context, entry AdminAuditEntry) (*AdminAuditEntry, error) {
+	var saved AdminAuditEntry
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO admin_audit (actor, action, method, path, summary, status) VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+adminAuditColumns,
+		entry.Actor, entry.Action, entry.Method, entry.Path, entry.Summary, entry.Status)
+	if err := row.Scan(&saved.ID, &saved.Actor, &saved.Action, &saved.Method, &saved.Path, &saved.Summary, &saved.Status, &saved.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to record admin action: %w", err)
+	}
+	return &saved, nil
+}
+
+// ListActions retrieves admin changes matching the filter, newest first
+func (r *sqliteAdminAuditRepository) ListActions(ctx context.Context, filter AdminAuditFilter) ([]AdminAuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+
+	query := "SELECT " + adminAuditColumns + " FROM admin_audit"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query admin audit log: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	entries := []AdminAuditEntry{}
+	for rows.Next() {
+		var entry AdminAuditEntry
+		if err := rows.Scan(&entry.ID, &entry.Actor, &entry.Action, &entry.Method, &entry.Path, &entry.Summary, &entry.Status, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan admin audit entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return entries, nil
+}
+
+// sqliteEventLogRepository implements EventLogRepository for SQLite
+type sqliteEventLogRepository struct {
+	db *sql.DB
+}
+
+const eventLogColumns = "id, type, source, session_id, method, path, duration_ms, error, created_at"
+
+// AppendEvents stores events in order, assigning increasing IDs
+func (r *sqliteEventLogRepository) AppendEvents(ctx context.Context, events []EventLogEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO event_log (type, source, session_id, method, path, duration_ms, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare event insert: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	for _, event := range events {
+		createdAt := event.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		if _, err := stmt.ExecContext(ctx, event.Type, event.Source, event.SessionID, event.Method, event.Path,
+			event.DurationMs, event.Error, createdAt.UTC()); err != nil {
+			return fmt.Errorf("failed to append event: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit events: %w", err)
+	}
+	return nil
+}
+
+// ListEvents retrieves the events after the cursor of the filter, oldest first
+func (r *sqliteEventLogRepository) ListEvents(ctx context.Context, filter EventLogFilter) ([]EventLogEntry, error) {
+	query := "SELECT " + eventLogColumns + " FROM event_log WHERE id > ?"
+	args := []interface{}{filter.After}
+	if len(filter.Types) > 0 {
+		query += " AND type IN (?" + strings.Repeat(", ?", len(filter.Types)-1) + ")"
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event log: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	events := []EventLogEntry{}
+	for rows.Next() {
+		var event EventLogEntry
+		if err := rows.Scan(&event.ID, &event.Type, &event.Source, &event.SessionID, &event.Method, &event.Path,
+			&event.DurationMs, &event.Error, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return events, nil
+}
+
+// LatestEventID returns the ID of the newest event, 0 if there is none
+func (r *sqliteEventLogRepository) LatestEventID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM event_log").Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get latest event: %w", err)
+	}
+	return id, nil
+}
+
+// PruneEvents removes events stored before a time
+func (r *sqliteEventLogRepository) PruneEvents(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM event_log WHERE created_at < ?", before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune events: %w", err)
+	}
+	return nil
+}
+
+// sqliteCassetteRepository implements CassetteRepository for SQLite
+type sqliteCassetteRepository struct {
+	db *sql.DB
+}
+
+const cassetteInteractionColumns = "id, cassette, request_key, method, url, status, headers, body, created_at"
+
+// SaveInteraction appends a recorded response to its cassette
+func (r *sqliteCassetteRepository) SaveInteraction(ctx context.Context, interaction CassetteInteraction) (*CassetteInteraction, error) {
+	headers, err := json.Marshal(interaction.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response headers: %w", err)
+	}
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO cassette_interactions (cassette, request_key, method, url, status, headers, body)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+cassetteInteractionColumns,
+		interaction.Cassette, interaction.RequestKey, interaction.Method, interaction.URL, interaction.Status,
+		string(headers), interaction.Body)
+	saved, err := scanCassetteInteraction(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save cassette interaction: %w", err)
+	}
+	return saved, nil
+}
+
+// ListInteractions retrieves the responses of a cassette in the order they were recorded
+func (r *sqliteCassetteRepository) ListInteractions(ctx context.Context, cassette string) ([]CassetteInteraction, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+cassetteInteractionColumns+" FROM cassette_interactions WHERE cassette = ? ORDER BY id", cassette)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cassette interactions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	interactions := []CassetteInteraction{}
+	for rows.Next() {
+		interaction, err := scanCassetteInteraction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan cassette interaction: %w", err)
+		}
+		interactions = append(interactions, *interaction)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return interactions, nil
+}
+
+// ListCassettes retrieves the cassettes that have responses, ordered by name
+func (r *sqliteCassetteRepository) ListCassettes(ctx context.Context) ([]CassetteSummary, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT cassette, COUNT(*), MAX(created_at) FROM cassette_interactions
+		GROUP BY cassette ORDER BY cassette`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cassettes: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	cassettes := []CassetteSummary{}
+	for rows.Next() {
+		var summary CassetteSummary
+		var recordedAt string
+		if err := rows.Scan(&summary.Name, &summary.Interactions, &recordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cassette: %w", err)
+		}
+		summary.RecordedAt = parseSQLiteTime(recordedAt)
+		cassettes = append(cassettes, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return cassettes, nil
+}
+
+// ReplaceCassette replaces the responses of a cassette, in order
+func (r *sqliteCassetteRepository) ReplaceCassette(ctx context.Context, cassette string, interactions []CassetteInteraction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM cassette_interactions WHERE cassette = ?", cassette); err != nil {
+		return fmt.Errorf("failed to clear cassette: %w", err)
+	}
+	for _, interaction := range interactions {
+		headers, err := json.Marshal(interaction.Headers)
+		if err != nil {
+			return fmt.Errorf("failed to encode response headers: %w", err)
+		}
+		createdAt := interaction.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = time.Now()
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO cassette_interactions (cassette, request_key, method, url, status, headers, body, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			cassette, interaction.RequestKey, interaction.Method, interaction.URL, interaction.Status,
+			string(headers), interaction.Body, createdAt.UTC()); err != nil {
+			return fmt.Errorf("failed to save cassette interaction: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cassette: %w", err)
+	}
+	return nil
+}
+
+// DeleteCassette removes a cassette and its responses
+func (r *sqliteCassetteRepository) DeleteCassette(ctx context.Context, cassette string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM cassette_interactions WHERE cassette = ?", cassette)
+	if err != nil {
+		return fmt.Errorf("failed to delete cassette: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("cassette %s %w", cassette, ErrNotFound)
+	}
+	return nil
+}
+
+func scanCassetteInteraction(row rowScanner) (*CassetteInteraction, error) {
+	var interaction CassetteInteraction
+	var headers string
+	if err := row.Scan(&interaction.ID, &interaction.Cassette, &interaction.RequestKey, &interaction.Method,
+		&interaction.URL, &interaction.Status, &headers, &interaction.Body, &interaction.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(headers), &interaction.Headers); err != nil {
+		return nil, fmt.Errorf("failed to decode response headers: %w", err)
+	}
+	return &interaction, nil
+}
+
+// sqliteConversationRepository implements ConversationRepository for SQLite
+type sqliteConversationRepository struct {
+	db *sql.DB
+}
+
+const conversationColumns = "id, title, model, session_id, created_at, updated_at"
+
+const conversationMessageColumns = "id, conversation_id, role, content, tool_calls, tool_call_id, tokens, created_at"
+
+// CreateConversation stores a new conversation without messages
+func (r *sqliteConversationRepository) CreateConversation(ctx context.Context, conversation Conversation) (*Conversation, error) {
+	row := r.db.QueryRowContext(ctx, `
+		INSERT INTO ai_conversations (title, model, session_id) VALUES (?, ?, ?)
+		RETURNING `+conversationColumns,
+		conversation.Title, conversation.Model, conversation.SessionID)
+	saved, err := scanConversation(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create conversation: %w", err)
+	}
+	return saved, nil
+}
+
+// AppendMessage adds a message to the end of its conversation
+func (r *sqliteConversationRepository) AppendMessage(ctx context.Context, message ConversationMessage) (*ConversationMessage, error) {
+	toolCalls, err := json.Marshal(message.ToolCalls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tool calls: %w", err)
+	}
+	tokens, err := json.Marshal(message.Tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.ExecContext(ctx, "UPDATE ai_conversations SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", message.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update conversation: %w", err)
+	}
+	if rowsAffected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	} else if rowsAffected == 0 {
+		return nil, fmt.Errorf("conversation %d %w", message.ConversationID, ErrNotFound)
+	}
+	row := tx.QueryRowContext(ctx, `
+		INSERT INTO ai_conversation_messages (conversation_id, role, content, tool_calls, tool_call_id, tokens)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING `+conversationMessageColumns,
+		message.ConversationID, message.Role, message.Content, string(toolCalls), message.ToolCallID, string(tokens))
+	saved, err := scanConversationMessage(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save conversation message: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit conversation message: %w", err)
+	}
+	return saved, nil
+}
+
+// GetConversation retrieves a conversation with its messages in order
+func (r *sqliteConversationRepository) GetConversation(ctx context.Context, id int) (*Conversation, error) {
+	conversation, err := scanConversation(r.db.QueryRowContext(ctx, "SELECT "+conversationColumns+" FROM ai_conversations WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("conversation %d %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, "SELECT "+conversationMessageColumns+" FROM ai_conversation_messages WHERE conversation_id = ? ORDER BY id", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversation messages: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	conversation.Messages = []ConversationMessage{}
+	for rows.Next() {
+		message, err := scanConversationMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan conversation message: %w", err)
+		}
+		conversation.Messages = append(conversation.Messages, *message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	conversation.MessageCount = len(conversation.Messages)
+	return conversation, nil
+}
+
+// ListConversations retrieves conversations without their messages, most recently updated first
+func (r *sqliteConversationRepository) ListConversations(ctx context.Context, limit int) ([]Conversation, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.id, c.title, c.model, c.session_id, c.created_at, c.updated_at, COUNT(m.id)
+		FROM ai_conversations c LEFT JOIN ai_conversation_messages m ON m.conversation_id = c.id
+		GROUP BY c.id ORDER BY c.updated_at DESC, c.id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversations: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			log.Error().Err(err).Msg("Failed to close database rows")
+		}
+	}()
+
+	conversations := []Conversation{}
+	for rows.Next() {
+		var conversation Conversation
+		if err := rows.Scan(&conversation.ID, &conversation.Title, &conversation.Model, &conversation.SessionID,
+			&conversation.CreatedAt, &conversation.UpdatedAt, &conversation.MessageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conversations = append(conversations, conversation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return conversations, nil
+}
+
+// DeleteConversation removes a conversation and its messages
+func (r *sqliteConversationRepository) DeleteConversation(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM ai_conversations WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("conversation %d %w", id, ErrNotFound)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM ai_conversation_messages WHERE conversation_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete conversation messages: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit conversation deletion: %w", err)
+	}
+	return nil
+}
+
+func scanConversation(row rowScanner) (*Conversation, error) {
+	var conversation Conversation
+	if err := row.Scan(&conversation.ID, &conversation.Title, &conversation.Model, &conversation.SessionID,
+		&conversation.CreatedAt, &conversation.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+func scanConversationMessage(row rowScanner) (*ConversationMessage, error) {
+	var message ConversationMessage
+	var toolCalls, tokens string
+	if err := row.Scan(&message.ID, &message.ConversationID, &message.Role, &message.Content, &toolCalls,
+		&message.ToolCallID, &tokens, &message.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(toolCalls), &message.ToolCalls); err != nil {
+		return nil, fmt.Errorf("failed to decode tool calls: %w", err)
+	}
+	if err := json.Unmarshal([]byte(tokens), &message.Tokens); err != nil {
+		return nil, fmt.Errorf("failed to decode tokens: %w", err)
+	}
+	return &message, nil
+}
@@ -0,0 +1,389 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 keeps objects in a bucket of AWS S3 or of an S3 compatible service (MinIO, R2, ...).
+// Requests are signed with AWS Signature Version 4 using the credentials of the environment:
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, for temporary credentials, AWS_SESSION_TOKEN.
+type S3 struct {
+	bucket       string
+	prefix       string // Key prefix in the bucket, "" or ending with /
+	region       string
+	endpoint     *url.URL // Scheme and host requests go to
+	pathStyle    bool     // Address the bucket in the path instead of the host name
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// S3Config configures an S3 storage
+type S3Config struct {
+	Bucket       string
+	Prefix       string // Key prefix in the bucket
+	Region       string // "us-east-1" if ""
+	Endpoint     string // URL of an S3 compatible service, "" for AWS
+	PathStyle    bool   // Address the bucket in the path, implied by Endpoint
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// s3Timeout bounds a single request to the bucket
+const s3Timeout = 60 * time.Second
+
+// NewS3 returns a Storage of the objects in an S3 bucket
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 storage needs a bucket")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("S3 storage needs credentials, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	s := &S3{
+		bucket:       config.Bucket,
+		region:       config.Region,
+		pathStyle:    config.PathStyle,
+		accessKey:    config.AccessKey,
+		secretKey:    config.SecretKey,
+		sessionToken: config.SessionToken,
+		client:       &http.Client{Timeout: s3Timeout},
+	}
+	if prefix := strings.Trim(config.Prefix, "/"); prefix != "" {
+		s.prefix = prefix + "/"
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	} else {
+		s.pathStyle = true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	s.endpoint = &url.URL{Scheme: u.Scheme, Host: u.Host}
+	return s, nil
+}
+
+// newS3FromURL configures an S3 storage from s3://bucket/prefix?region=&endpoint= and the
+// credentials of the environment
+func newS3FromURL(u *url.URL) (*S3, error) {
+	query := u.Query()
+	region := query.Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	pathStyle, _ := strconv.ParseBool(query.Get("path-style"))
+	return NewS3(S3Config{
+		Bucket:       u.Host,
+		Prefix:       u.Path,
+		Region:       region,
+		Endpoint:     query.Get("endpoint"),
+		PathStyle:    pathStyle,
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	})
+}
+
+// Put uploads the object
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPut, s.prefix+key, nil, data)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// Append downloads the object and uploads it again with data added. Writers appending to the
+// same key at the same time lose data; give every instance a prefix of its own.
+func (s *S3) Append(ctx context.Context, key string, data []byte) error {
+	existing, err := ReadAll(ctx, s, key)
+	if err != nil && !errors.Is(err, ErrNotExist) {
+		return err
+	}
+	return s.Put(ctx, key, append(existing, data...))
+}
+
+// Get downloads the object; the returned reader can seek since the object is read into memory
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Object{}, err
+	}
+	resp, err := s.do(ctx, http.MethodGet, s.prefix+key, nil, nil)
+	if err != nil {
+		var statusErr *s3Error
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			return nil, Object{}, fmt.Errorf("%s: %w", key, ErrNotExist)
+		}
+		return nil, Object{}, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Object{}, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+
+	object := Object{Key: key, Size: int64(len(data))}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		object.Modified = modified
+	}
+	return readSeekNopCloser{bytes.NewReader(data)}, object, nil
+}
+
+// Delete removes the object
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodDelete, s.prefix+key, nil, nil)
+	if err != nil {
+		var statusErr *s3Error
+		if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response of ListObjectsV2
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages through ListObjectsV2
+func (s *S3) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects := []Object{}
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", s, err)
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", s, err)
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{
+				Key:      strings.TrimPrefix(content.Key, s.prefix),
+				Size:     content.Size,
+				Modified: content.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+func (s *S3) String() string {
+	return "s3://" + s.bucket + "/" + strings.TrimSuffix(s.prefix, "/")
+}
+
+// s3Error is an error response of the bucket
+type s3Error struct {
+	status int
+	code   string
+	msg    string
+}
+
+func (e *s3Error) Error() string {
+	if e.code != "" {
+		return fmt.Sprintf("S3 returned %d %s: %s", e.status, e.code, e.msg)
+	}
+	return fmt.Sprintf("S3 returned %d", e.status)
+}
+
+// do sends a signed request for an object key, or for the bucket if key is ""
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.pathStyle {
+		objectPath = "/" + s.bucket + objectPath
+	} else {
+		u.Host = s.bucket + "." + u.Host
+	}
+	u.Path = objectPath
+	u.RawPath = escapeS3Path(objectPath)
+	u.RawQuery = canonicalS3Query(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawPath = u.RawPath
+	if body == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	s.sign(req, body, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer func() { _ = resp.Body.Close() }()
+		statusErr := &s3Error{status: resp.StatusCode}
+		var payload struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024)); err == nil && xml.Unmarshal(data, &payload) == nil {
+			statusErr.code, statusErr.msg = payload.Code, payload.Message
+		}
+		return nil, statusErr
+	}
+	return resp, nil
+}
+
+// sign adds the Signature Version 4 authorization of a request, signing the host and all
+// headers set on it
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// escapeS3Path escapes every byte of a path except the unreserved characters and slashes
+func escapeS3Path(p string) string {
+	return strings.ReplaceAll(escapeS3(p), "%2F", "/")
+}
+
+// canonicalS3Query encodes a query sorted by name with the escaping signatures expect
+func canonicalS3Query(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			pairs = append(pairs, escapeS3(name)+"="+escapeS3(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escapeS3 percent-encodes everything but A-Z, a-z, 0-9, '-', '.', '_' and '~'
+func escapeS3(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// readSeekNopCloser gives a bytes.Reader a no-op Close
+type readSeekNopCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekNopCloser) Close() error { return nil }
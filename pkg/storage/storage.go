@@ -0,0 +1,146 @@
+// Package storage keeps the files jesus produces (execution attachments, full output of
+// truncated executions, request log archives) behind one interface, so a deployment can keep
+// them on local disk or in an S3 bucket. Stateless containers lose their disk on every
+// restart; pointing the storage at a bucket keeps the artifacts.
+//
+// Keys are slash separated paths like "attachments/42/report.csv". A backend is selected with
+// a location understood by Open:
+//
+//	/var/lib/jesus/artifacts                       local directory
+//	file:///var/lib/jesus/artifacts                local directory
+//	s3://bucket/prefix?region=eu-west-1            AWS S3
+//	s3://bucket/prefix?endpoint=http://minio:9000  S3 compatible service, path-style requests
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned by Get for a key without object
+var ErrNotExist = errors.New("object does not exist")
+
+// Object describes a stored object
+type Object struct {
+	Key      string    `json:"key"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Storage stores objects by key
+type Storage interface {
+	// Put stores data under key, replacing the object if it exists
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Append adds data to the end of the object under key, creating it if needed. Object
+	// stores cannot append; their Append reads the object and writes it back.
+	Append(ctx context.Context, key string, data []byte) error
+
+	// Get opens the object under key. The reader is an io.ReadSeeker when the backend can seek.
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+
+	// Delete removes the object under key; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+
+	// List returns the objects whose key starts with prefix, sorted by key
+	List(ctx context.Context, prefix string) ([]Object, error)
+
+	// String describes where the objects are kept, for logs
+	String() string
+}
+
+// Open returns the storage of a location: a directory path, a file:// URL or an s3:// URL
+func Open(location string) (Storage, error) {
+	if location == "" {
+		return nil, fmt.Errorf("storage location is empty")
+	}
+	if !strings.Contains(location, "://") {
+		return NewLocal(location)
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage location %q: %w", location, err)
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, fmt.Errorf("invalid storage location %q: file URLs must not name a host", location)
+		}
+		return NewLocal(u.Path)
+	case "s3":
+		return newS3FromURL(u)
+	default:
+		return nil, fmt.Errorf("unsupported storage location %q, expected a directory, file:// or s3://", location)
+	}
+}
+
+// ReadAll reads the whole object under key
+func ReadAll(ctx context.Context, s Storage, key string) ([]byte, error) {
+	r, _, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+	return io.ReadAll(r)
+}
+
+// prefixed stores the objects of a Storage under a key prefix
+type prefixed struct {
+	s      Storage
+	prefix string
+}
+
+// WithPrefix returns a Storage keeping its objects under prefix/ of s
+func WithPrefix(s Storage, prefix string) Storage {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return s
+	}
+	return &prefixed{s: s, prefix: prefix + "/"}
+}
+
+func (p *prefixed) Put(ctx context.Context, key string, data []byte) error {
+	return p.s.Put(ctx, p.prefix+key, data)
+}
+
+func (p *prefixed) Append(ctx context.Context, key string, data []byte) error {
+	return p.s.Append(ctx, p.prefix+key, data)
+}
+
+func (p *prefixed) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	r, object, err := p.s.Get(ctx, p.prefix+key)
+	object.Key = strings.TrimPrefix(object.Key, p.prefix)
+	return r, object, err
+}
+
+func (p *prefixed) Delete(ctx context.Context, key string) error {
+	return p.s.Delete(ctx, p.prefix+key)
+}
+
+func (p *prefixed) List(ctx context.Context, prefix string) ([]Object, error) {
+	objects, err := p.s.List(ctx, p.prefix+prefix)
+	for i := range objects {
+		objects[i].Key = strings.TrimPrefix(objects[i].Key, p.prefix)
+	}
+	return objects, err
+}
+
+func (p *prefixed) String() string {
+	return strings.TrimSuffix(p.s.String(), "/") + "/" + strings.TrimSuffix(p.prefix, "/")
+}
+
+// checkKey refuses keys that are empty, absolute or climb out of the storage with ..
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
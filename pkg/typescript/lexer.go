@@ -0,0 +1,307 @@
+package typescript
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokNumber
+	tokString
+	tokTemplate
+	tokRegex
+	tokPunct
+)
+
+// token is a lexical token of the source. Comments and whitespace are not tokens; they
+// only show up as nlBefore/spaceBefore of the following token.
+type token struct {
+	kind        tokenKind
+	text        string
+	start, end  int      // Byte offsets in the source
+	nlBefore    bool     // A line break precedes the token
+	spaceBefore bool     // Whitespace or a comment precedes the token
+	parts       [][2]int // Offsets of the ${} expressions of a template literal
+}
+
+// punctuators are matched longest first
+var punctuators = []string{
+	">>>=", "...", "===", "!==", "**=", "<<=", ">>=", ">>>", "&&=", "||=", "??=",
+	"=>", "==", "!=", "<=", ">=", "&&", "||", "??", "?.", "++", "--", "+=", "-=",
+	"*=", "/=", "%=", "&=", "|=", "^=", "**", "<<", ">>",
+}
+
+// regexAfterKeyword lists the keywords after which a slash starts a regular expression
+var regexAfterKeyword = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true, "in": true,
+	"of": true, "new": true, "delete": true, "void": true, "throw": true,
+	"instanceof": true, "yield": true, "await": true,
+}
+
+type lexer struct {
+	src    string
+	pos    int
+	tokens []token
+	parts  [][2]int // Expressions of the last template literal
+}
+
+func tokenize(src string) ([]token, error) {
+	l := &lexer{src: src}
+	for {
+		nl, space, err := l.skipSpace()
+		if err != nil {
+			return nil, err
+		}
+		if l.pos >= len(l.src) {
+			return l.tokens, nil
+		}
+
+		start := l.pos
+		kind, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		l.tokens = append(l.tokens, token{
+			kind:        kind,
+			text:        l.src[start:l.pos],
+			start:       start,
+			end:         l.pos,
+			nlBefore:    nl,
+			spaceBefore: space,
+		})
+		if kind == tokTemplate {
+			l.tokens[len(l.tokens)-1].parts = l.parts
+		}
+	}
+}
+
+// skipSpace skips whitespace and comments and reports whether they contained a line break
+func (l *lexer) skipSpace() (nl bool, space bool, err error) {
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			end := strings.IndexByte(l.src[l.pos:], '\n')
+			if end < 0 {
+				l.pos = len(l.src)
+			} else {
+				l.pos += end
+			}
+			space = true
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return nl, space, l.errorf(l.pos, "unterminated comment")
+			}
+			if strings.ContainsAny(l.src[l.pos:l.pos+2+end], "\n\u2028\u2029") {
+				nl = true
+			}
+			l.pos += end + 4
+			space = true
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			if r == '\n' || r == '\r' || r == '\u2028' || r == '\u2029' {
+				nl = true
+			} else if !unicode.IsSpace(r) && r != '\ufeff' {
+				return nl, space, nil
+			}
+			l.pos += size
+			space = true
+		}
+	}
+	return nl, space, nil
+}
+
+func (l *lexer) next() (tokenKind, error) {
+	c := l.src[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		return tokString, l.lexString(c)
+	case c == '`':
+		return tokTemplate, l.lexTemplate()
+	case c >= '0' && c <= '9', c == '.' && l.pos+1 < len(l.src) && l.src[l.pos+1] >= '0' && l.src[l.pos+1] <= '9':
+		l.lexNumber()
+		return tokNumber, nil
+	case c == '/' && l.regexAllowed():
+		return tokRegex, l.lexRegex()
+	case c == '#' || isIdentStart(l.src[l.pos:]):
+		l.pos++
+		for l.pos < len(l.src) && isIdentPart(l.src[l.pos:]) {
+			_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			l.pos += size
+		}
+		return tokIdent, nil
+	}
+
+	for _, p := range punctuators {
+		if strings.HasPrefix(l.src[l.pos:], p) {
+			// "?.5" is a conditional followed by a number, not optional chaining
+			if p == "?." && l.pos+2 < len(l.src) && l.src[l.pos+2] >= '0' && l.src[l.pos+2] <= '9' {
+				continue
+			}
+			l.pos += len(p)
+			return tokPunct, nil
+		}
+	}
+	_, size := utf8.DecodeRuneInString(l.src[l.pos:])
+	l.pos += size
+	return tokPunct, nil
+}
+
+func (l *lexer) lexString(quote byte) error {
+	start := l.pos
+	l.pos++
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+			continue
+		case quote:
+			l.pos++
+			return nil
+		case '\n':
+			return l.errorf(start, "unterminated string")
+		}
+		l.pos++
+	}
+	return l.errorf(start, "unterminated string")
+}
+
+// lexTemplate consumes a template literal including the expressions in its ${} parts
+func (l *lexer) lexTemplate() error {
+	start := l.pos
+	var parts [][2]int
+	l.pos++
+	for l.pos < len(l.src) {
+		switch {
+		case l.src[l.pos] == '\\':
+			l.pos += 2
+		case l.src[l.pos] == '`':
+			l.pos++
+			l.parts = parts
+			return nil
+		case strings.HasPrefix(l.src[l.pos:], "${"):
+			l.pos += 2
+			exprStart := l.pos
+			if err := l.skipTemplateExpression(); err != nil {
+				return err
+			}
+			parts = append(parts, [2]int{exprStart, l.pos - 1})
+		default:
+			l.pos++
+		}
+	}
+	return l.errorf(start, "unterminated template literal")
+}
+
+func (l *lexer) skipTemplateExpression() error {
+	start := l.pos
+	depth := 1
+	// The tokens of the expression only serve regexAllowed and are dropped afterwards
+	outer := len(l.tokens)
+	defer func() {
+		l.tokens = l.tokens[:outer]
+	}()
+	for {
+		if _, _, err := l.skipSpace(); err != nil {
+			return err
+		}
+		if l.pos >= len(l.src) {
+			return l.errorf(start, "unterminated template expression")
+		}
+		tokStart := l.pos
+		kind, err := l.next()
+		if err != nil {
+			return err
+		}
+		l.tokens = append(l.tokens, token{kind: kind, text: l.src[tokStart:l.pos], start: tokStart, end: l.pos})
+
+		if kind == tokPunct {
+			switch l.src[tokStart:l.pos] {
+			case "{":
+				depth++
+			case "}":
+				depth--
+				if depth == 0 {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+func (l *lexer) lexNumber() {
+	hex := strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X")
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == '.':
+			l.pos++
+		case (c == '+' || c == '-') && !hex && (l.src[l.pos-1] == 'e' || l.src[l.pos-1] == 'E'):
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) lexRegex() error {
+	start := l.pos
+	l.pos++
+	inClass := false
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; {
+		case c == '\\':
+			l.pos += 2
+			continue
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			l.pos++
+			for l.pos < len(l.src) && isIdentPart(l.src[l.pos:]) {
+				l.pos++
+			}
+			return nil
+		case c == '\n':
+			return l.errorf(start, "unterminated regular expression")
+		}
+		l.pos++
+	}
+	return l.errorf(start, "unterminated regular expression")
+}
+
+// regexAllowed reports whether a slash at the current position starts a regular expression
+// rather than a division, judging by the previous token
+func (l *lexer) regexAllowed() bool {
+	if len(l.tokens) == 0 {
+		return true
+	}
+	prev := l.tokens[len(l.tokens)-1]
+	switch prev.kind {
+	case tokNumber, tokString, tokTemplate, tokRegex:
+		return false
+	case tokIdent:
+		return regexAfterKeyword[prev.text]
+	}
+	return prev.text != ")" && prev.text != "]"
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	return newSyntaxError(l.src, pos, fmt.Sprintf(format, args...))
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\u200c' || r == '\u200d'
+}
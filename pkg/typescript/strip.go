@@ -0,0 +1,1080 @@
+// Package typescript turns TypeScript into JavaScript goja can run by erasing the type
+// syntax: annotations, interfaces, type aliases, generics, casts and modifiers are replaced
+// with whitespace, so line and column numbers in errors still match the TypeScript source.
+//
+// Only syntax that can be erased is supported. Constructs that would need code generation
+// (enum, namespace, constructor parameter properties, decorators) are reported as errors.
+package typescript
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is returned for TypeScript that cannot be stripped
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (err *SyntaxError) Error() string {
+	return fmt.Sprintf("typescript: line %d:%d: %s", err.Line, err.Column, err.Message)
+}
+
+func newSyntaxError(src string, pos int, message string) *SyntaxError {
+	line := 1 + strings.Count(src[:pos], "\n")
+	column := pos - strings.LastIndexByte(src[:pos], '\n')
+	return &SyntaxError{Line: line, Column: column, Message: message}
+}
+
+// IsTypeScript reports whether a script name or content type denotes TypeScript
+func IsTypeScript(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "ts", "typescript", "application/typescript", "application/x-typescript", "text/typescript":
+		return true
+	}
+	return strings.HasSuffix(name, ".ts") && !strings.HasSuffix(name, ".d.ts")
+}
+
+// Strip returns the JavaScript of a TypeScript source
+func Strip(src string) (string, error) {
+	tokens, err := tokenize(src)
+	if err != nil {
+		return "", err
+	}
+
+	s := &stripper{
+		src:     src,
+		out:     []byte(src),
+		toks:    tokens,
+		blanked: make([]bool, len(tokens)),
+	}
+	s.match = matchBrackets(tokens)
+	if err := s.run(); err != nil {
+		return "", err
+	}
+	return string(s.out), nil
+}
+
+// matchBrackets pairs the indexes of (), [] and {} tokens; unbalanced brackets map to -1
+func matchBrackets(tokens []token) []int {
+	match := make([]int, len(tokens))
+	var stack []int
+	for i, t := range tokens {
+		match[i] = -1
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			stack = append(stack, i)
+		case ")", "]", "}":
+			if len(stack) > 0 {
+				open := stack[len(stack)-1]
+				if closing[tokens[open].text] == t.text {
+					stack = stack[:len(stack)-1]
+					match[open], match[i] = i, open
+				}
+			}
+		}
+	}
+	return match
+}
+
+var closing = map[string]string{"(": ")", "[": "]", "{": "}"}
+
+type frameKind int
+
+const (
+	frameOther frameKind = iota
+	frameParams
+	frameClass
+)
+
+// frame is an open bracket the stripper is inside of
+type frame struct {
+	open        int // Index of the opening token
+	kind        frameKind
+	ternary     int  // Pending "?" of conditional expressions
+	decl        bool // Inside a let/const/var declaration
+	expectName  bool // The next token names a declared variable or a parameter
+	memberStart bool // At the start of a class member
+	annotate    bool // A type annotation may follow the closing bracket (destructuring pattern)
+}
+
+type stripper struct {
+	src     string
+	out     []byte
+	toks    []token
+	match   []int
+	blanked []bool
+	stack   []*frame
+}
+
+// controlKeywords are followed by parentheses that are not a parameter list
+var controlKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "with": true, "return": true,
+	"typeof": true, "void": true, "delete": true, "await": true, "yield": true, "new": true,
+	"super": true, "import": true, "case": true, "in": true, "of": true, "instanceof": true,
+	"throw": true, "else": true, "do": true,
+}
+
+// memberModifiers are erased before class members
+var memberModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "readonly": true,
+	"declare": true, "abstract": true, "override": true,
+}
+
+func (s *stripper) run() error {
+	top := &frame{open: -1}
+	s.stack = []*frame{top}
+
+	for i := 0; i < len(s.toks); {
+		next, err := s.step(i)
+		if err != nil {
+			return err
+		}
+		i = next
+	}
+	return nil
+}
+
+func (s *stripper) top() *frame {
+	return s.stack[len(s.stack)-1]
+}
+
+// step handles the token at i and returns the index of the next token to handle
+func (s *stripper) step(i int) (int, error) {
+	t := s.toks[i]
+	f := s.top()
+
+	if f.decl && t.nlBefore && s.prevText(i) != "," && t.text != "," {
+		f.decl, f.expectName = false, false
+	}
+	if f.kind == frameClass && (t.nlBefore || s.prevText(i) == ";" || s.prevText(i) == "{" || s.prevText(i) == "}") {
+		f.memberStart = true
+	}
+
+	if s.atStatementStart(i) {
+		if next, ok, err := s.statement(i); err != nil || ok {
+			return next, err
+		}
+	}
+	if f.kind == frameClass && f.memberStart {
+		f.memberStart = false
+		if next, ok, err := s.classMember(i); err != nil || ok {
+			return next, err
+		}
+	}
+	if f.expectName {
+		if next, ok, err := s.declaredName(i, f); err != nil || ok {
+			return next, err
+		}
+	}
+
+	switch t.kind {
+	case tokIdent:
+		return s.identifier(i, f)
+	case tokPunct:
+		return s.punctuator(i, f)
+	case tokTemplate:
+		return i + 1, s.template(t)
+	}
+	return i + 1, nil
+}
+
+// template strips the expressions in the ${} parts of a template literal. Each expression
+// is stripped on its own, padded so that its line and column numbers stay the same.
+func (s *stripper) template(t token) error {
+	for _, part := range t.parts {
+		start, end := part[0], part[1]
+		line := strings.Count(s.src[:start], "\n")
+		column := start - strings.LastIndexByte(s.src[:start], '\n') - 1
+		padding := strings.Repeat("\n", line) + strings.Repeat(" ", column-1) + "("
+
+		out, err := Strip(padding + s.src[start:end] + ")")
+		if err != nil {
+			return err
+		}
+		copy(s.out[start:end], out[len(padding):len(out)-1])
+	}
+	return nil
+}
+
+// statement erases type-only statements: interface, type alias, declare, import type
+func (s *stripper) statement(i int) (int, bool, error) {
+	j := i
+	for j < len(s.toks) && (s.toks[j].text == "export" || s.toks[j].text == "declare") {
+		j++
+	}
+	if j >= len(s.toks) {
+		return i, false, nil
+	}
+	declare := false
+	for k := i; k < j; k++ {
+		declare = declare || s.toks[k].text == "declare"
+	}
+	word, next := s.toks[j].text, s.text(j+1)
+
+	switch {
+	case word == "interface" && s.isIdent(j+1):
+		k := j + 2
+		for k < len(s.toks) && s.toks[k].text != "{" {
+			k++
+		}
+		if k >= len(s.toks) || s.match[k] < 0 {
+			return 0, false, s.errorf(j, "interface without body")
+		}
+		s.blank(i, s.match[k]+1)
+		return s.match[k] + 1, true, nil
+
+	case word == "type" && s.isIdent(j+1) && (s.text(j+2) == "=" || s.text(j+2) == "<") && !s.toks[j+1].nlBefore:
+		k := j + 2
+		if s.text(k) == "<" {
+			end, ok := s.skipAngles(k)
+			if !ok {
+				return 0, false, s.errorf(k, "unterminated type parameters")
+			}
+			k = end
+		}
+		if s.text(k) != "=" {
+			return i, false, nil
+		}
+		end, err := s.parseType(k + 1)
+		if err != nil {
+			return 0, false, err
+		}
+		if s.text(end) == ";" {
+			end++
+		}
+		s.blank(i, end)
+		return end, true, nil
+
+	case word == "enum" || (word == "const" && next == "enum"):
+		return 0, false, s.errorf(j, "enum declarations are not supported, use a plain object")
+
+	case (word == "namespace" || word == "module") && s.isIdent(j+1) && !s.toks[j+1].nlBefore:
+		if declare {
+			end := s.statementEnd(j)
+			s.blank(i, end)
+			return end, true, nil
+		}
+		return 0, false, s.errorf(j, "%s declarations are not supported", word)
+
+	case declare:
+		end := s.statementEnd(j)
+		s.blank(i, end)
+		return end, true, nil
+
+	case (word == "import" || word == "export") && next == "type" && (word == "import" || s.text(j+2) == "{"):
+		end := s.moduleStatementEnd(j)
+		s.blank(i, end)
+		return end, true, nil
+
+	case word == "type" && next == "{" && j > i && s.toks[j-1].text == "export":
+		// export type { T }: the loop above already took the export
+		end := s.moduleStatementEnd(j)
+		s.blank(i, end)
+		return end, true, nil
+
+	case word == "abstract" && next == "class":
+		s.blank(j, j+1)
+		return j + 1, true, nil
+	}
+	return i, false, nil
+}
+
+// classMember erases modifiers, optional marks, annotations and index signatures of a
+// class member starting at i
+func (s *stripper) classMember(i int) (int, bool, error) {
+	j := i
+	for j < len(s.toks) && memberModifiers[s.toks[j].text] && s.startsMemberName(j+1) {
+		s.blank(j, j+1)
+		j++
+	}
+
+	// Index signature: [key: string]: Type;
+	if s.text(j) == "[" && s.isIdent(j+1) && s.text(j+2) == ":" && s.match[j] > 0 {
+		end := s.match[j] + 1
+		if s.text(end) == ":" {
+			var err error
+			if end, err = s.parseType(end + 1); err != nil {
+				return 0, false, err
+			}
+		}
+		if s.text(end) == ";" {
+			end++
+		}
+		s.blank(j, end)
+		return end, true, nil
+	}
+
+	for j < len(s.toks) && s.startsMemberName(j+1) {
+		if memberModifiers[s.toks[j].text] {
+			s.blank(j, j+1)
+		} else if !(s.text(j) == "static" || s.text(j) == "async" || s.text(j) == "*" || s.text(j) == "get" || s.text(j) == "set") {
+			break
+		}
+		j++
+	}
+
+	// Member name
+	switch {
+	case s.text(j) == "[" && s.match[j] > 0:
+		// Computed names may contain expressions; let the main loop handle them
+		return j, j != i, nil
+	case j < len(s.toks) && (s.toks[j].kind == tokIdent || s.toks[j].kind == tokString || s.toks[j].kind == tokNumber):
+		j++
+	default:
+		return j, j != i, nil
+	}
+
+	if (s.text(j) == "?" || s.text(j) == "!") && s.endsMemberName(j+1) {
+		s.blank(j, j+1)
+		j++
+	}
+	switch s.text(j) {
+	case ":":
+		end, err := s.parseType(j + 1)
+		if err != nil {
+			return 0, false, err
+		}
+		s.blank(j, end)
+		return end, true, nil
+	case "<":
+		end, ok := s.skipAngles(j)
+		if !ok || s.text(end) != "(" {
+			break
+		}
+		if sigEnd, ok := s.signatureEnd(end); ok {
+			s.blank(i, sigEnd)
+			return sigEnd, true, nil
+		}
+		s.blank(j, end)
+		return end, true, nil
+	case "(":
+		// Abstract methods and overload signatures have no body
+		if end, ok := s.signatureEnd(j); ok {
+			s.blank(i, end)
+			return end, true, nil
+		}
+	}
+	return j, j != i, nil
+}
+
+// signatureEnd returns the index after a function signature without a body whose
+// parameters open at i, including its return type and semicolon
+func (s *stripper) signatureEnd(i int) (int, bool) {
+	if s.match[i] < 0 {
+		return 0, false
+	}
+	end := s.match[i] + 1
+	if s.text(end) == ":" {
+		var err error
+		if end, err = s.parseType(end + 1); err != nil {
+			return 0, false
+		}
+	}
+	switch {
+	case s.text(end) == ";":
+		return end + 1, true
+	case end >= len(s.toks) || s.text(end) == "}" || s.toks[end].nlBefore && s.text(end) != "{":
+		return end, true
+	}
+	return 0, false
+}
+
+func (s *stripper) startsMemberName(i int) bool {
+	if i >= len(s.toks) || s.toks[i].nlBefore {
+		return false
+	}
+	t := s.toks[i]
+	return t.kind == tokIdent || t.kind == tokString || t.kind == tokNumber || t.text == "[" || t.text == "*"
+}
+
+func (s *stripper) endsMemberName(i int) bool {
+	switch s.text(i) {
+	case ":", ";", "=", "(", "}", "":
+		return true
+	}
+	return s.toks[i].nlBefore
+}
+
+// declaredName handles the name of a declared variable or parameter at i: it erases an
+// optional mark, a definite assignment mark and the type annotation that follow
+func (s *stripper) declaredName(i int, f *frame) (int, bool, error) {
+	t := s.toks[i]
+	f.expectName = false
+
+	if f.kind == frameParams {
+		switch {
+		case t.text == "...":
+			f.expectName = true
+			return i + 1, true, nil
+		case (t.text == "public" || t.text == "private" || t.text == "protected" || t.text == "readonly") && s.isIdent(i+1):
+			return 0, false, s.errorf(i, "constructor parameter properties are not supported, assign the fields in the constructor")
+		case t.text == "this" && s.text(i+1) == ":":
+			end, err := s.parseType(i + 2)
+			if err != nil {
+				return 0, false, err
+			}
+			if s.text(end) == "," {
+				end++
+			}
+			s.blank(i, end)
+			f.expectName = true
+			return end, true, nil
+		}
+	}
+
+	switch {
+	case t.text == "{" || t.text == "[":
+		// Destructuring pattern; the annotation follows its closing bracket
+		next, err := s.punctuator(i, f)
+		s.top().annotate = true
+		return next, true, err
+	case t.kind != tokIdent:
+		return i, false, nil
+	}
+
+	j := i + 1
+	if (s.text(j) == "?" && f.kind == frameParams) || (s.text(j) == "!" && f.decl) {
+		if s.text(j+1) == ":" || (f.kind == frameParams && (s.text(j+1) == "," || s.text(j+1) == ")" || s.text(j+1) == "=")) {
+			s.blank(j, j+1)
+			j++
+		}
+	}
+	if s.text(j) == ":" {
+		end, err := s.parseType(j + 1)
+		if err != nil {
+			return 0, false, err
+		}
+		s.blank(j, end)
+		return end, true, nil
+	}
+	return j, true, nil
+}
+
+func (s *stripper) identifier(i int, f *frame) (int, error) {
+	t := s.toks[i]
+	prev := s.prevText(i)
+	if prev == "." || prev == "?." {
+		return s.typeArguments(i + 1)
+	}
+
+	switch t.text {
+	case "let", "const", "var":
+		if s.isIdent(i+1) || s.text(i+1) == "{" || s.text(i+1) == "[" {
+			f.decl, f.expectName = true, true
+		}
+		return i + 1, nil
+
+	case "function":
+		j := i + 1
+		if s.text(j) == "*" {
+			j++
+		}
+		if s.isIdent(j) {
+			j++
+		}
+		if s.text(j) == "<" {
+			end, ok := s.skipAngles(j)
+			if !ok {
+				return 0, s.errorf(j, "unterminated type parameters")
+			}
+			s.blank(j, end)
+			j = end
+		}
+		if s.text(j) == "(" {
+			if end, ok := s.signatureEnd(j); ok {
+				// Overload signature without a body
+				from := i
+				if p := s.prevIndex(i); s.text(p) == "export" {
+					from = p
+				}
+				s.blank(from, end)
+				return end, nil
+			}
+			return s.openParams(i, j)
+		}
+		return j, nil
+
+	case "catch":
+		if s.text(i+1) == "(" {
+			return s.openParams(i, i+1)
+		}
+
+	case "class":
+		return s.classHeader(i)
+
+	case "as", "satisfies":
+		if s.endsExpression(i-1) && s.startsType(i+1) && !t.nlBefore {
+			end := i + 2
+			if !(t.text == "as" && s.text(i+1) == "const") {
+				var err error
+				if end, err = s.parseType(i + 1); err != nil {
+					return 0, err
+				}
+			}
+			s.blank(i, end)
+			return end, nil
+		}
+	}
+
+	return s.typeArguments(i + 1)
+}
+
+// typeArguments erases explicit type arguments of a call like foo<string>(x) at i
+func (s *stripper) typeArguments(i int) (int, error) {
+	if s.text(i) != "<" {
+		return i, nil
+	}
+	end, ok := s.skipAngles(i)
+	if ok && (s.text(end) == "(" || (end < len(s.toks) && s.toks[end].kind == tokTemplate)) {
+		s.blank(i, end)
+		return end, nil
+	}
+	return i, nil
+}
+
+// classHeader erases type parameters and the implements clause of a class declaration
+func (s *stripper) classHeader(i int) (int, error) {
+	j := i + 1
+	if s.isIdent(j) && s.text(j) != "extends" && s.text(j) != "implements" {
+		j++
+	}
+	for j < len(s.toks) && s.text(j) != "{" {
+		switch s.text(j) {
+		case "<":
+			end, ok := s.skipAngles(j)
+			if !ok {
+				return 0, s.errorf(j, "unterminated type parameters")
+			}
+			s.blank(j, end)
+			j = end
+		case "implements":
+			end := j + 1
+			for end < len(s.toks) && s.text(end) != "{" {
+				end++
+			}
+			s.blank(j, end)
+			j = end
+		case "(", "[":
+			if s.match[j] < 0 {
+				return j + 1, nil
+			}
+			j = s.match[j] + 1
+		default:
+			j++
+		}
+	}
+	if j >= len(s.toks) {
+		return j, nil
+	}
+	// The main loop continues with the extends expression; the body is a class frame
+	s.pushFrame(j, frameClass)
+	s.top().memberStart = true
+	return j + 1, nil
+}
+
+func (s *stripper) punctuator(i int, f *frame) (int, error) {
+	switch t := s.toks[i]; t.text {
+	case "(":
+		if s.isParameterList(i, f) {
+			return s.openParams(i-1, i)
+		}
+		s.pushFrame(i, frameOther)
+		return i + 1, nil
+
+	case "[", "{":
+		s.pushFrame(i, frameOther)
+		return i + 1, nil
+
+	case ")", "]", "}":
+		return s.closeFrame(i)
+
+	case "<":
+		// Where no expression ends, < cannot be a comparison: it opens the type parameters of
+		// a generic arrow function, <T>(x: T) => x, or a type assertion, <string>value
+		if !s.endsExpression(i - 1) {
+			if end, ok := s.skipAngles(i); ok {
+				s.blank(i, end)
+				return end, nil
+			}
+		}
+
+	case "@":
+		return 0, s.errorf(i, "decorators are not supported")
+
+	case "?":
+		f.ternary++
+
+	case ":":
+		if f.ternary > 0 {
+			f.ternary--
+		}
+
+	case ",":
+		if f.decl || f.kind == frameParams {
+			f.expectName = true
+		}
+
+	case ";":
+		f.decl, f.expectName = false, false
+
+	case "!":
+		// Non-null assertion: value!.field
+		if !t.spaceBefore && s.endsExpression(i-1) {
+			s.blank(i, i+1)
+		}
+	}
+	return i + 1, nil
+}
+
+// isParameterList reports whether the parenthesis at i opens the parameters of a method
+// or an arrow function
+func (s *stripper) isParameterList(i int, f *frame) bool {
+	end := s.match[i]
+	if end < 0 {
+		return false
+	}
+
+	if s.arrowFollows(end+1, f) {
+		return true
+	}
+
+	// Method: name(params) { ... } or name(params): Type { ... }
+	p := s.prevIndex(i)
+	if p < 0 || s.toks[p].kind != tokIdent || controlKeywords[s.toks[p].text] {
+		return false
+	}
+	if pp := s.prevIndex(p); pp >= 0 && (s.toks[pp].text == "." || s.toks[pp].text == "?." || s.toks[pp].text == "case") {
+		return false
+	}
+	switch s.text(end + 1) {
+	case "{":
+		return true
+	case ":":
+		if f.ternary > 0 || s.toks[f.openOr(0)].text != "{" && f.kind != frameClass {
+			return false
+		}
+		typeEnd, err := s.parseType(end + 2)
+		return err == nil && s.text(typeEnd) == "{"
+	}
+	return false
+}
+
+// arrowFollows reports whether the token at i, after a closing parenthesis, continues an
+// arrow function: "=>" or a return type followed by "=>"
+func (s *stripper) arrowFollows(i int, f *frame) bool {
+	switch s.text(i) {
+	case "=>":
+		return true
+	case ":":
+		// In a conditional expression "(b) : c => d" is an alternative, unless the
+		// parameters are annotated
+		if f.ternary > 0 && !s.hasAnnotation(s.match[i-1]) {
+			return false
+		}
+		end, err := s.parseType(i + 1)
+		return err == nil && s.text(end) == "=>"
+	}
+	return false
+}
+
+// hasAnnotation reports whether the parentheses opening at i directly contain a colon
+func (s *stripper) hasAnnotation(i int) bool {
+	if i < 0 || s.match[i] < 0 {
+		return false
+	}
+	for j := i + 1; j < s.match[i]; j++ {
+		switch s.text(j) {
+		case ":":
+			return true
+		case "(", "[", "{":
+			if s.match[j] < 0 {
+				return false
+			}
+			j = s.match[j]
+		}
+	}
+	return false
+}
+
+// openParams enters the parameter list opening at j; owner is the token before it
+func (s *stripper) openParams(owner, j int) (int, error) {
+	if s.match[j] < 0 {
+		return j + 1, nil
+	}
+	s.pushFrame(j, frameParams)
+	s.top().expectName = true
+	return j + 1, nil
+}
+
+func (s *stripper) pushFrame(i int, kind frameKind) {
+	s.stack = append(s.stack, &frame{open: i, kind: kind})
+}
+
+// closeFrame leaves the bracket closed at i and erases a return type or pattern
+// annotation that follows it
+func (s *stripper) closeFrame(i int) (int, error) {
+	if len(s.stack) == 1 {
+		return i + 1, nil
+	}
+	f := s.top()
+	s.stack = s.stack[:len(s.stack)-1]
+	parent := s.top()
+
+	if parent.kind == frameClass && s.toks[i].text == "}" {
+		parent.memberStart = true
+	}
+
+	switch {
+	case f.kind == frameParams && s.text(i+1) == ":":
+		end, err := s.parseType(i + 2)
+		if err != nil {
+			return 0, err
+		}
+		s.blank(i+1, end)
+		return end, nil
+
+	case f.annotate:
+		j := i + 1
+		if s.text(j) == "?" && parent.kind == frameParams {
+			s.blank(j, j+1)
+			j++
+		}
+		if s.text(j) == ":" {
+			end, err := s.parseType(j + 1)
+			if err != nil {
+				return 0, err
+			}
+			s.blank(j, end)
+			return end, nil
+		}
+	}
+	return i + 1, nil
+}
+
+// parseType returns the index after the type starting at i
+func (s *stripper) parseType(i int) (int, error) {
+	if s.text(i) == "|" || s.text(i) == "&" {
+		i++
+	}
+	end, err := s.parseTypeMember(i)
+	if err != nil {
+		return 0, err
+	}
+	for s.text(end) == "|" || s.text(end) == "&" {
+		if end, err = s.parseTypeMember(end + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	// Conditional type: T extends U ? X : Y
+	if s.text(end) == "extends" {
+		if end, err = s.parseTypeMember(end + 1); err != nil {
+			return 0, err
+		}
+		if s.text(end) != "?" {
+			return 0, s.errorf(end, "expected ? in conditional type")
+		}
+		if end, err = s.parseType(end + 1); err != nil {
+			return 0, err
+		}
+		if s.text(end) != ":" {
+			return 0, s.errorf(end, "expected : in conditional type")
+		}
+		return s.parseType(end + 1)
+	}
+	return end, nil
+}
+
+func (s *stripper) parseTypeMember(i int) (int, error) {
+	for {
+		switch s.text(i) {
+		case "keyof", "readonly", "unique", "asserts":
+			if s.startsType(i + 1) {
+				i++
+				continue
+			}
+		case "infer":
+			if s.isIdent(i + 1) {
+				i += 2
+				if s.text(i) == "extends" {
+					i++
+				}
+				continue
+			}
+		}
+		break
+	}
+
+	if i >= len(s.toks) {
+		return 0, s.errorf(len(s.src), "expected a type")
+	}
+	t := s.toks[i]
+	end := i + 1
+
+	switch {
+	case t.text == "(" || t.text == "{" || t.text == "[":
+		if s.match[i] < 0 {
+			return 0, s.errorf(i, "unbalanced %s in type", t.text)
+		}
+		end = s.match[i] + 1
+		if t.text == "(" && s.text(end) == "=>" {
+			return s.parseType(end + 1)
+		}
+
+	case t.text == "<" || t.text == "new":
+		// Generic or constructor function type: <T>(x: T) => T, new (...) => T
+		j := i
+		if t.text == "new" {
+			j++
+		}
+		if s.text(j) == "<" {
+			var ok bool
+			if j, ok = s.skipAngles(j); !ok {
+				return 0, s.errorf(j, "unterminated type parameters")
+			}
+		}
+		if s.text(j) != "(" || s.match[j] < 0 || s.text(s.match[j]+1) != "=>" {
+			return 0, s.errorf(i, "expected a function type")
+		}
+		return s.parseType(s.match[j] + 2)
+
+	case t.text == "-" && s.toks[end-1].kind == tokPunct && end < len(s.toks) && s.toks[end].kind == tokNumber:
+		end++
+
+	case t.kind == tokString || t.kind == tokNumber || t.kind == tokTemplate:
+
+	case t.text == "typeof":
+		end = i + 2
+		for s.text(end) == "." && s.isIdent(end+1) {
+			end += 2
+		}
+
+	case t.kind == tokIdent:
+		for s.text(end) == "." && s.isIdent(end+1) {
+			end += 2
+		}
+		if s.text(end) == "is" && s.startsType(end+1) {
+			// Type predicate: value is Type
+			return s.parseType(end + 1)
+		}
+
+	default:
+		return 0, s.errorf(i, "unexpected %q in type", t.text)
+	}
+
+	// Type arguments and array suffixes
+	if s.text(end) == "<" {
+		j, ok := s.skipAngles(end)
+		if !ok {
+			return 0, s.errorf(end, "unterminated type arguments")
+		}
+		end = j
+	}
+	for s.text(end) == "[" && !s.toks[end].nlBefore && s.match[end] > 0 {
+		end = s.match[end] + 1
+	}
+	return end, nil
+}
+
+// startsType reports whether a type can start at i
+func (s *stripper) startsType(i int) bool {
+	if i >= len(s.toks) {
+		return false
+	}
+	t := s.toks[i]
+	switch t.kind {
+	case tokIdent, tokString, tokNumber, tokTemplate:
+		return true
+	case tokPunct:
+		switch t.text {
+		case "(", "{", "[", "<", "|", "&", "-":
+			return true
+		}
+	}
+	return false
+}
+
+// skipAngles returns the index after the angle brackets opening at i. Only tokens that may
+// appear in type parameters are accepted, so comparisons are not mistaken for generics.
+func (s *stripper) skipAngles(i int) (int, bool) {
+	depth := 0
+	for j := i; j < len(s.toks); j++ {
+		t := s.toks[j]
+		switch {
+		case t.text == "<":
+			depth++
+		case strings.Trim(t.text, ">") == "" && t.kind == tokPunct:
+			depth -= len(t.text)
+			if depth < 0 {
+				return 0, false
+			}
+			if depth == 0 {
+				return j + 1, true
+			}
+		case t.text == "(" || t.text == "[" || t.text == "{":
+			if s.match[j] < 0 {
+				return 0, false
+			}
+			j = s.match[j]
+		case t.kind == tokIdent || t.kind == tokString || t.kind == tokNumber || t.kind == tokTemplate:
+		default:
+			switch t.text {
+			case ",", ".", "|", "&", "=>", "?", ":", "=", "-":
+			default:
+				return 0, false
+			}
+		}
+	}
+	return 0, false
+}
+
+// statementEnd returns the index after the statement starting at i, which ends at a
+// semicolon, after a block, or at a line break outside brackets
+func (s *stripper) statementEnd(i int) int {
+	for j := i; j < len(s.toks); j++ {
+		t := s.toks[j]
+		if j > i && t.nlBefore && !strings.ContainsAny(s.prevText(j), ",|&=:.({[<") {
+			return j
+		}
+		switch t.text {
+		case ";":
+			return j + 1
+		case "{":
+			if s.match[j] < 0 {
+				return len(s.toks)
+			}
+			if s.text(j-1) == "=" || s.text(j-1) == ":" {
+				j = s.match[j]
+				continue
+			}
+			return s.match[j] + 1
+		case "(", "[":
+			if s.match[j] < 0 {
+				return len(s.toks)
+			}
+			j = s.match[j]
+		}
+	}
+	return len(s.toks)
+}
+
+// moduleStatementEnd returns the index after the import or export statement starting at i,
+// whose braces list names rather than open a block
+func (s *stripper) moduleStatementEnd(i int) int {
+	j := i
+	for j < len(s.toks) && s.toks[j].kind != tokString {
+		if j > i && s.toks[j].nlBefore && s.text(j) != "from" && s.prevText(j) != "," && s.prevText(j) != "{" {
+			return j
+		}
+		if s.text(j) == "{" && s.match[j] > 0 {
+			j = s.match[j]
+		}
+		j++
+	}
+	if j < len(s.toks) {
+		j++
+	}
+	if s.text(j) == ";" {
+		j++
+	}
+	return j
+}
+
+// atStatementStart reports whether the token at i starts a statement
+func (s *stripper) atStatementStart(i int) bool {
+	f := s.top()
+	if f.kind != frameOther || (f.open >= 0 && s.toks[f.open].text != "{") {
+		return false
+	}
+	switch s.prevText(i) {
+	case "", ";", "{", "}":
+		return true
+	}
+	return s.toks[i].nlBefore && !strings.ContainsAny(s.prevText(i), ",=(:.[?+-*/%&|^!<>")
+}
+
+// endsExpression reports whether the token at i can end an expression
+func (s *stripper) endsExpression(i int) bool {
+	i = s.lastVisible(i)
+	if i < 0 {
+		return false
+	}
+	t := s.toks[i]
+	switch t.kind {
+	case tokIdent:
+		return !controlKeywords[t.text] && !regexAfterKeyword[t.text]
+	case tokNumber, tokString, tokTemplate, tokRegex:
+		return true
+	}
+	return t.text == ")" || t.text == "]" || t.text == "}"
+}
+
+// blank replaces tokens [from, to) and the space between them with whitespace, keeping
+// line breaks
+func (s *stripper) blank(from, to int) {
+	if from >= to {
+		return
+	}
+	if to > len(s.toks) {
+		to = len(s.toks)
+	}
+	for k := s.toks[from].start; k < s.toks[to-1].end; k++ {
+		if s.out[k] != '\n' && s.out[k] != '\r' {
+			s.out[k] = ' '
+		}
+	}
+	for k := from; k < to; k++ {
+		s.blanked[k] = true
+	}
+}
+
+func (s *stripper) text(i int) string {
+	if i < 0 || i >= len(s.toks) {
+		return ""
+	}
+	return s.toks[i].text
+}
+
+func (s *stripper) isIdent(i int) bool {
+	return i >= 0 && i < len(s.toks) && s.toks[i].kind == tokIdent
+}
+
+// lastVisible returns the last token at or before i that was not erased
+func (s *stripper) lastVisible(i int) int {
+	for i >= 0 && i < len(s.toks) && s.blanked[i] {
+		i--
+	}
+	return i
+}
+
+// prevIndex returns the last token before i that was not erased
+func (s *stripper) prevIndex(i int) int {
+	return s.lastVisible(i - 1)
+}
+
+func (s *stripper) prevText(i int) string {
+	return s.text(s.prevIndex(i))
+}
+
+func (s *stripper) errorf(i int, format string, args ...interface{}) error {
+	pos := len(s.src)
+	if i < len(s.toks) {
+		pos = s.toks[i].start
+	}
+	return newSyntaxError(s.src, pos, fmt.Sprintf(format, args...))
+}
+
+// openOr returns the index of the frame's opening token, or def at the top level
+func (f *frame) openOr(def int) int {
+	if f.open < 0 {
+		return def
+	}
+	return f.open
+}
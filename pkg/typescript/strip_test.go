@@ -0,0 +1,110 @@
+package typescript
+
+import (
+	"strings"
+	"testing"
+)
+
+// squash collapses whitespace runs, so expectations ignore the blanks left by erased types
+func squash(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"plain javascript", "const a = b < c && d > e ? 1 : 2;", "const a = b < c && d > e ? 1 : 2;"},
+		{"annotations", "let u: string | undefined = undefined;", "let u = undefined;"},
+		{"definite assignment", "let v!: string;", "let v ;"},
+		{"function signature", "function f(this: Window, a?: number, ...rest: string[]): void {}", "function f( a , ...rest ) {}"},
+		{"overload signature", "function f(a: string): string;\nfunction f(a: any) { return a }", "function f(a ) { return a }"},
+
+		{"generic function", "function id<T>(x: T): T { return x }", "function id (x ) { return x }"},
+		{"generic constructor call", "const m = new Map<string, number[]>();", "const m = new Map ();"},
+		{"generic call", "const a = f<number>(1);", "const a = f (1);"},
+		{"generic arrow function", "const f = async <T,>(x: T): Promise<T> => x;", "const f = async (x ) => x;"},
+		{"generic class", "class C<T> extends B<T> implements I<T> {}", "class C extends B {}"},
+
+		{"as cast", "const x = y as unknown as string;", "const x = y ;"},
+		{"as cast in parentheses", "const o = { a: (b as any).c };", "const o = { a: (b ).c };"},
+		{"angle bracket cast", "let t = <string>x;", "let t = x;"},
+		{"satisfies", "const c = { a: 1 } satisfies Record<string, number>;", "const c = { a: 1 } ;"},
+
+		{"non-null member access", "const n = maybe!.value;", "const n = maybe .value;"},
+		{"non-null index", "const k = list[0]!;", "const k = list[0] ;"},
+		{"non-null operand", "let v = a! + b;", "let v = a + b;"},
+		{"logical not kept", "let v = !a && !b;", "let v = !a && !b;"},
+
+		{"interface", "interface I { a: string; b?: number }\nconst z = 1;", "const z = 1;"},
+		{"type alias", "type T<K> = { [P in keyof K]: K[P] };\nconst z = 2;", "const z = 2;"},
+		{"declare", "declare const g: number;\ndeclare namespace D { const x: number }\nconst z = 3;", "const z = 3;"},
+		{"import type", "import type { X } from './x';\nconst z = 4;", "const z = 4;"},
+		{"export type", "export type { Y };\nconst z = 5;", "const z = 5;"},
+
+		{"class members", "class C { private a: T; static readonly b: number = 1; m?(): void {} }", "class C { a ; static b = 1; m () {} }"},
+		{"abstract class", "abstract class Q { abstract m(): void; }", "class Q { }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Strip(tt.src)
+			if err != nil {
+				t.Fatalf("Strip(%q) failed: %v", tt.src, err)
+			}
+			if squash(got) != tt.want {
+				t.Errorf("Strip(%q) = %q, want %q", tt.src, squash(got), tt.want)
+			}
+			if len(got) != len(tt.src) || strings.Count(got, "\n") != strings.Count(tt.src, "\n") {
+				t.Errorf("Strip(%q) moved the code: %q", tt.src, got)
+			}
+		})
+	}
+}
+
+func TestStripRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"enum", "enum Color { Red, Green }", "line 1:1: enum declarations are not supported"},
+		{"const enum", "const enum E { A }", "line 1:1: enum declarations are not supported"},
+		{"class decorator", "@sealed class A {}", "line 1:1: decorators are not supported"},
+		{"member decorator", "class A {\n  @log m() {}\n}", "line 2:3: decorators are not supported"},
+		{"namespace", "namespace NS { export const x = 1 }", "line 1:1: namespace declarations are not supported"},
+		{"module", "module M { }", "line 1:1: module declarations are not supported"},
+		{"parameter property", "class P { constructor(private x: number) {} }", "constructor parameter properties are not supported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Strip(tt.src)
+			if err == nil {
+				t.Fatalf("Strip(%q) succeeded, want an error", tt.src)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Strip(%q) error = %q, want it to contain %q", tt.src, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsTypeScript(t *testing.T) {
+	tests := map[string]bool{
+		"api/users.ts":           true,
+		"API/USERS.TS":           true,
+		"types.d.ts":             false,
+		"users.js":               false,
+		"ts":                     true,
+		"application/typescript": true,
+		"text/javascript":        false,
+	}
+	for name, want := range tests {
+		if got := IsTypeScript(name); got != want {
+			t.Errorf("IsTypeScript(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -20,18 +20,49 @@ type AdminHandler struct {
 	jsEngine         *engine.Engine
 	logsHandler      *admin.LogsHandler
 	globalHandler    *admin.GlobalStateHandler
+	maintenance      *admin.MaintenanceHandler
+	readOnly         *admin.ReadOnlyHandler
+	timers           *admin.TimersHandler
+	cron             *admin.CronHandler
+	mirror           *admin.MirrorHandler
+	bootstrap        *admin.BootstrapHandler
+	reset            *admin.ResetHandler
+	notebooks        *admin.NotebooksHandler
+	flags            *admin.FlagsHandler
+	cassettes        *admin.CassettesHandler
+	conversations    *admin.ConversationsHandler
+	graph            *admin.GraphHandler
+	audit            *admin.AuditHandler
+	scriptFiles      *admin.ScriptFilesHandler
+	scriptEditor     *admin.ScriptEditorHandler
 	sseHandler       *admin.SSEHandler
 	staticFileServer http.Handler
 }
 
 // NewAdminHandler creates a new admin handler
 func NewAdminHandler(logger *engine.RequestLogger, repos repository.RepositoryManager, jsEngine *engine.Engine) *AdminHandler {
+	scriptEditor := admin.NewScriptEditorHandler(jsEngine)
 	ah := &AdminHandler{
 		logger:           logger,
 		repos:            repos,
 		jsEngine:         jsEngine,
-		logsHandler:      admin.NewLogsHandler(logger, repos),
+		logsHandler:      admin.NewLogsHandler(logger, repos, jsEngine),
 		globalHandler:    admin.NewGlobalStateHandler(jsEngine),
+		maintenance:      admin.NewMaintenanceHandler(jsEngine),
+		readOnly:         admin.NewReadOnlyHandler(jsEngine),
+		timers:           admin.NewTimersHandler(jsEngine),
+		cron:             admin.NewCronHandler(),
+		mirror:           admin.NewMirrorHandler(jsEngine),
+		bootstrap:        admin.NewBootstrapHandler(jsEngine),
+		reset:            admin.NewResetHandler(jsEngine),
+		notebooks:        admin.NewNotebooksHandler(repos, jsEngine),
+		flags:            admin.NewFlagsHandler(jsEngine),
+		cassettes:        admin.NewCassettesHandler(jsEngine),
+		conversations:    admin.NewConversationsHandler(jsEngine),
+		graph:            admin.NewGraphHandler(jsEngine),
+		audit:            admin.NewAuditHandler(jsEngine),
+		scriptFiles:      admin.NewScriptFilesHandler(jsEngine, scriptEditor),
+		scriptEditor:     scriptEditor,
 		sseHandler:       admin.NewSSEHandler(logger, repos),
 		staticFileServer: http.FileServer(http.FS(adminStaticFiles)),
 	}
@@ -53,6 +84,28 @@ func (ah *AdminHandler) HandleAdminLogs(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if r.URL.Path == "/admin/logs/request" {
+		content, err := adminStaticFiles.ReadFile("static/admin/request.html")
+		if err != nil {
+			http.Error(w, "Failed to read request.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/logs/compare" {
+		content, err := adminStaticFiles.ReadFile("static/admin/compare.html")
+		if err != nil {
+			http.Error(w, "Failed to read compare.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
 	if r.URL.Path == "/admin/logs/api" || strings.HasPrefix(r.URL.Path, "/admin/logs/api/") {
 		ah.logsHandler.HandleLogsAPI(w, r)
 		return
@@ -84,6 +137,182 @@ func (ah *AdminHandler) HandleGlobalState(w http.ResponseWriter, r *http.Request
 	ah.globalHandler.HandleGlobalState(w, r)
 }
 
+// HandleMaintenance serves the maintenance mode API
+func (ah *AdminHandler) HandleMaintenance(w http.ResponseWriter, r *http.Request) {
+	ah.maintenance.HandleMaintenance(w, r)
+}
+
+// HandleReadOnly serves the read-only mode API
+func (ah *AdminHandler) HandleReadOnly(w http.ResponseWriter, r *http.Request) {
+	ah.readOnly.HandleReadOnly(w, r)
+}
+
+// HandleTimers serves the timers API
+func (ah *AdminHandler) HandleTimers(w http.ResponseWriter, r *http.Request) {
+	ah.timers.HandleTimers(w, r)
+}
+
+// HandleCron serves the cron expression preview
+func (ah *AdminHandler) HandleCron(w http.ResponseWriter, r *http.Request) {
+	ah.cron.HandleCron(w, r)
+}
+
+// HandleCassettes serves the recorded responses of outbound requests
+func (ah *AdminHandler) HandleCassettes(w http.ResponseWriter, r *http.Request) {
+	ah.cassettes.HandleCassettes(w, r)
+}
+
+// HandleMirror serves the outcomes of mirrored requests
+func (ah *AdminHandler) HandleMirror(w http.ResponseWriter, r *http.Request) {
+	ah.mirror.HandleMirror(w, r)
+}
+
+// HandleBootstrap serves the bootstrap backups API
+func (ah *AdminHandler) HandleBootstrap(w http.ResponseWriter, r *http.Request) {
+	ah.bootstrap.HandleBootstrap(w, r)
+}
+
+// HandleBootstrapRollback rolls the bootstrap file back to a backup
+func (ah *AdminHandler) HandleBootstrapRollback(w http.ResponseWriter, r *http.Request) {
+	ah.bootstrap.HandleRollback(w, r)
+}
+
+// HandleReset resets the JavaScript runtimes
+func (ah *AdminHandler) HandleReset(w http.ResponseWriter, r *http.Request) {
+	ah.reset.HandleReset(w, r)
+}
+
+// HandleScriptFiles serves the files of the scripts directory to API key holders
+func (ah *AdminHandler) HandleScriptFiles(w http.ResponseWriter, r *http.Request) {
+	ah.scriptFiles.HandleScriptFiles(w, r)
+}
+
+// HandleScriptEditor serves the collaborative script editor page and its WebSocket
+func (ah *AdminHandler) HandleScriptEditor(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/scripts/edit" {
+		content, err := adminStaticFiles.ReadFile("static/admin/script-editor.html")
+		if err != nil {
+			http.Error(w, "Failed to read script-editor.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/scripts/edit/ws" {
+		ah.scriptEditor.HandleWebSocket(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// HandleNotebooks serves the notebook interface and API
+func (ah *AdminHandler) HandleNotebooks(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/notebooks" {
+		content, err := adminStaticFiles.ReadFile("static/admin/notebooks.html")
+		if err != nil {
+			http.Error(w, "Failed to read notebooks.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/notebooks/api" || strings.HasPrefix(r.URL.Path, "/admin/notebooks/api/") {
+		ah.notebooks.HandleNotebooksAPI(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// HandleFlags serves the feature flags page and API
+func (ah *AdminHandler) HandleFlags(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/flags" {
+		content, err := adminStaticFiles.ReadFile("static/admin/flags.html")
+		if err != nil {
+			http.Error(w, "Failed to read flags.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/flags/api" || strings.HasPrefix(r.URL.Path, "/admin/flags/api/") {
+		ah.flags.HandleFlagsAPI(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// HandleAudit serves the admin audit log page and API
+func (ah *AdminHandler) HandleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/audit" {
+		content, err := adminStaticFiles.ReadFile("static/admin/audit.html")
+		if err != nil {
+			http.Error(w, "Failed to read audit.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/audit/api" {
+		ah.audit.HandleAuditAPI(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// HandleConversations serves the AI conversation replay page and API
+func (ah *AdminHandler) HandleConversations(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/conversations" {
+		content, err := adminStaticFiles.ReadFile("static/admin/conversations.html")
+		if err != nil {
+			http.Error(w, "Failed to read conversations.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/conversations/api" || strings.HasPrefix(r.URL.Path, "/admin/conversations/api/") {
+		ah.conversations.HandleConversationsAPI(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
+// HandleGraph serves the route graph page and API
+func (ah *AdminHandler) HandleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/graph" {
+		content, err := adminStaticFiles.ReadFile("static/admin/graph.html")
+		if err != nil {
+			http.Error(w, "Failed to read graph.html: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(content)
+		return
+	}
+
+	if r.URL.Path == "/admin/graph/api" {
+		ah.graph.HandleGraph(w, r)
+		return
+	}
+
+	http.NotFound(w, r)
+}
+
 // HandleStaticFiles serves admin static files
 func (ah *AdminHandler) HandleStaticFiles(w http.ResponseWriter, r *http.Request) {
 	// Strip /static prefix to match embedded filesystem structure
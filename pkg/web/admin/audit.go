@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditHandler serves the admin audit log behind the audit page
+//
+//	GET /admin/audit/api?actor=&action=&limit=&offset=   recorded admin changes, newest first
+type AuditHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewAuditHandler creates a new audit log handler
+func NewAuditHandler(jsEngine *engine.Engine) *AuditHandler {
+	return &AuditHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// HandleAuditAPI lists the recorded admin changes
+func (ah *AuditHandler) HandleAuditAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.AdminAuditFilter{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+	}
+	for name, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid "+name, http.StatusBadRequest)
+			return
+		}
+		*target = n
+	}
+
+	entries, err := ah.jsEngine.AdminActions(filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list admin actions")
+		http.Error(w, "Failed to list admin actions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, entries)
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// BootstrapHandler lists the backups of the bootstrap file and rolls it back
+type BootstrapHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewBootstrapHandler creates a new bootstrap handler
+func NewBootstrapHandler(jsEngine *engine.Engine) *BootstrapHandler {
+	return &BootstrapHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// BootstrapRollbackRequest is the body of POST /admin/bootstrap/rollback
+type BootstrapRollbackRequest struct {
+	ID int `json:"id"` // Backup to restore, 0 for the latest working one
+}
+
+// BootstrapRollbackResponse is the answer of POST /admin/bootstrap/rollback
+type BootstrapRollbackResponse struct {
+	Restored *repository.BootstrapBackup `json:"restored,omitempty"`
+	Error    string                      `json:"error,omitempty"`
+}
+
+// HandleBootstrap returns the bootstrap file and its backups, newest first
+func (bh *BootstrapHandler) HandleBootstrap(w http.ResponseWriter, r *http.Request) {
+	backups, err := bh.jsEngine.BootstrapBackups()
+	if err != nil {
+		http.Error(w, "Failed to list bootstrap backups: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"file":    bh.jsEngine.BootstrapFile(),
+		"backups": backups,
+	}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode bootstrap backups")
+	}
+}
+
+// HandleRollback restores a bootstrap backup and runs it
+func (bh *BootstrapHandler) HandleRollback(w http.ResponseWriter, r *http.Request) {
+	var req BootstrapRollbackRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	restored, err := bh.jsEngine.RollbackBootstrap(req.ID)
+	response := BootstrapRollbackResponse{Restored: restored}
+	status := http.StatusOK
+	if err != nil {
+		response.Error = err.Error()
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode bootstrap rollback response")
+	}
+}
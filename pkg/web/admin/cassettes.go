@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// CassettesHandler serves the recorded responses of outbound requests, see --http-cassette.
+// A cassette downloads as a fixture file that another server can import.
+//
+//	GET    /admin/cassettes          list cassettes and the one in use
+//	GET    /admin/cassettes/{name}   download a cassette as fixture
+//	PUT    /admin/cassettes/{name}   replace a cassette with a fixture
+//	DELETE /admin/cassettes/{name}   delete a cassette
+type CassettesHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewCassettesHandler creates a new cassettes handler
+func NewCassettesHandler(jsEngine *engine.Engine) *CassettesHandler {
+	return &CassettesHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// CassetteFixture is a cassette as downloaded and imported
+type CassetteFixture struct {
+	Name         string                           `json:"name"`
+	Interactions []repository.CassetteInteraction `json:"interactions"`
+}
+
+// activeCassette is the cassette outbound requests use
+type activeCassette struct {
+	Name string `json:"name"`
+	Mode string `json:"mode"`
+}
+
+// HandleCassettes dispatches the cassette requests
+func (ch *CassettesHandler) HandleCassettes(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/cassettes"), "/")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		cassettes, err := ch.jsEngine.Cassettes()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list cassettes")
+			http.Error(w, "Failed to list cassettes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var active *activeCassette
+		if name, mode := ch.jsEngine.ActiveCassette(); name != "" {
+			active = &activeCassette{Name: name, Mode: mode}
+		}
+		writeJSON(w, map[string]interface{}{"active": active, "cassettes": cassettes})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		interactions, err := ch.jsEngine.CassetteInteractions(name)
+		if err != nil {
+			log.Error().Err(err).Str("cassette", name).Msg("Failed to read cassette")
+			http.Error(w, "Failed to read cassette: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(interactions) == 0 {
+			http.Error(w, "Cassette not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(name, `"`, "")+`.json"`)
+		writeJSON(w, CassetteFixture{Name: name, Interactions: interactions})
+	case http.MethodPut:
+		var fixture CassetteFixture
+		if err := json.NewDecoder(r.Body).Decode(&fixture); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ch.jsEngine.ImportCassette(name, fixture.Interactions); err != nil {
+			log.Error().Err(err).Str("cassette", name).Msg("Failed to import cassette")
+			http.Error(w, "Failed to import cassette: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"success": true, "interactions": len(fixture.Interactions)})
+	case http.MethodDelete:
+		if err := ch.jsEngine.DeleteCassette(name); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				http.Error(w, "Cassette not found", http.StatusNotFound)
+				return
+			}
+			log.Error().Err(err).Str("cassette", name).Msg("Failed to delete cassette")
+			http.Error(w, "Failed to delete cassette: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,186 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/rs/zerolog/log"
+)
+
+// DiffRow is one row of a side-by-side line diff
+type DiffRow struct {
+	Kind      string `json:"kind"` // "equal", "changed", "removed", "added"
+	Left      string `json:"left"`
+	Right     string `json:"right"`
+	LeftLine  int    `json:"leftLine,omitempty"`
+	RightLine int    `json:"rightLine,omitempty"`
+}
+
+// FieldDiff is the side-by-side diff of one execution field
+type FieldDiff struct {
+	Identical bool      `json:"identical"`
+	Rows      []DiffRow `json:"rows"`
+}
+
+// ExecutionComparison shows two executions side by side
+type ExecutionComparison struct {
+	Left    *repository.ScriptExecution `json:"left"`
+	Right   *repository.ScriptExecution `json:"right"`
+	Code    FieldDiff                   `json:"code"`
+	Result  FieldDiff                   `json:"result"`
+	Console FieldDiff                   `json:"console"`
+	Error   FieldDiff                   `json:"error"`
+}
+
+// handleCompareExecutionsAPI compares two executions given as ?left=ID&right=ID.
+// right=previous picks the execution stored before left, preferring the same session.
+func (lh *LogsHandler) handleCompareExecutionsAPI(w http.ResponseWriter, r *http.Request) {
+	leftID, err := strconv.Atoi(r.URL.Query().Get("left"))
+	if err != nil {
+		http.Error(w, "Invalid left execution ID", http.StatusBadRequest)
+		return
+	}
+
+	left, err := lh.repos.Executions().GetExecution(r.Context(), leftID)
+	if err != nil {
+		log.Error().Err(err).Int("executionID", leftID).Msg("Failed to fetch execution for comparison")
+		http.NotFound(w, r)
+		return
+	}
+
+	var right *repository.ScriptExecution
+	if rightParam := r.URL.Query().Get("right"); rightParam == "previous" {
+		right, err = lh.findPreviousExecution(r.Context(), left)
+		if err != nil {
+			log.Error().Err(err).Int("executionID", leftID).Msg("Failed to find previous execution")
+			http.Error(w, "Failed to find previous execution", http.StatusInternalServerError)
+			return
+		}
+		if right == nil {
+			http.Error(w, "No previous execution to compare with", http.StatusNotFound)
+			return
+		}
+	} else {
+		rightID, err := strconv.Atoi(rightParam)
+		if err != nil {
+			http.Error(w, "Invalid right execution ID", http.StatusBadRequest)
+			return
+		}
+		right, err = lh.repos.Executions().GetExecution(r.Context(), rightID)
+		if err != nil {
+			log.Error().Err(err).Int("executionID", rightID).Msg("Failed to fetch execution for comparison")
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	// The older execution goes on the left so the diff reads as "what changed"
+	if right.ID < left.ID {
+		left, right = right, left
+	}
+
+	comparison := ExecutionComparison{
+		Left:    left,
+		Right:   right,
+		Code:    diffLines(left.Code, right.Code),
+		Result:  diffLines(stringValue(left.Result), stringValue(right.Result)),
+		Console: diffLines(stringValue(left.ConsoleLog), stringValue(right.ConsoleLog)),
+		Error:   diffLines(stringValue(left.Error), stringValue(right.Error)),
+	}
+
+	if err := json.NewEncoder(w).Encode(comparison); err != nil {
+		log.Error().Err(err).Msg("Failed to encode execution comparison response")
+	}
+}
+
+// findPreviousExecution returns the execution stored before the given one, looking in the
+// same session first and falling back to the same source (MCP and API calls use one session per execution)
+func (lh *LogsHandler) findPreviousExecution(ctx context.Context, execution *repository.ScriptExecution) (*repository.ScriptExecution, error) {
+	filters := []repository.ExecutionFilter{
+		{SessionID: execution.SessionID, BeforeID: execution.ID},
+		{Source: execution.Source, BeforeID: execution.ID},
+	}
+
+	for _, filter := range filters {
+		result, err := lh.repos.Executions().ListExecutions(ctx, filter, repository.PaginationOptions{Limit: 1, Offset: 0})
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Executions) > 0 {
+			return &result.Executions[0], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// diffLines computes a side-by-side line diff of two texts
+func diffLines(left, right string) FieldDiff {
+	leftLines := splitLines(left)
+	rightLines := splitLines(right)
+
+	diff := FieldDiff{Identical: left == right}
+	matcher := difflib.NewMatcher(leftLines, rightLines)
+
+	for _, op := range matcher.GetOpCodes() {
+		switch op.Tag {
+		case 'e':
+			for i := 0; i < op.I2-op.I1; i++ {
+				diff.Rows = append(diff.Rows, DiffRow{
+					Kind:      "equal",
+					Left:      leftLines[op.I1+i],
+					Right:     rightLines[op.J1+i],
+					LeftLine:  op.I1 + i + 1,
+					RightLine: op.J1 + i + 1,
+				})
+			}
+		case 'd':
+			for i := op.I1; i < op.I2; i++ {
+				diff.Rows = append(diff.Rows, DiffRow{Kind: "removed", Left: leftLines[i], LeftLine: i + 1})
+			}
+		case 'i':
+			for j := op.J1; j < op.J2; j++ {
+				diff.Rows = append(diff.Rows, DiffRow{Kind: "added", Right: rightLines[j], RightLine: j + 1})
+			}
+		case 'r':
+			// Pair up replaced lines, then emit the surplus of the longer side
+			for i, j := op.I1, op.J1; i < op.I2 || j < op.J2; i, j = i+1, j+1 {
+				row := DiffRow{Kind: "changed"}
+				if i < op.I2 {
+					row.Left, row.LeftLine = leftLines[i], i+1
+				} else {
+					row.Kind = "added"
+				}
+				if j < op.J2 {
+					row.Right, row.RightLine = rightLines[j], j+1
+				} else {
+					row.Kind = "removed"
+				}
+				diff.Rows = append(diff.Rows, row)
+			}
+		}
+	}
+
+	return diff
+}
+
+// splitLines splits text into lines, treating the empty string as no lines
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// stringValue dereferences a nullable string column
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// ConversationsHandler serves the AI conversation API behind the conversations page
+//
+//	GET    /admin/conversations/api        list conversations (?limit=, 100 by default)
+//	GET    /admin/conversations/api/{id}   a conversation with its messages
+//	DELETE /admin/conversations/api/{id}   delete a conversation
+type ConversationsHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewConversationsHandler creates a new conversations handler
+func NewConversationsHandler(jsEngine *engine.Engine) *ConversationsHandler {
+	return &ConversationsHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// HandleConversationsAPI dispatches the conversation API requests
+func (ch *ConversationsHandler) HandleConversationsAPI(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/conversations/api"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		conversations, err := ch.jsEngine.Conversations(limit)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list conversations")
+			http.Error(w, "Failed to list conversations: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, conversations)
+		return
+	}
+
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		conversation, err := ch.jsEngine.Conversation(id)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				http.Error(w, "Conversation not found", http.StatusNotFound)
+				return
+			}
+			log.Error().Err(err).Int("conversation", id).Msg("Failed to read conversation")
+			http.Error(w, "Failed to read conversation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, conversation)
+	case http.MethodDelete:
+		if err := ch.jsEngine.DeleteConversation(id); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				http.Error(w, "Conversation not found", http.StatusNotFound)
+				return
+			}
+			log.Error().Err(err).Int("conversation", id).Msg("Failed to delete conversation")
+			http.Error(w, "Failed to delete conversation: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
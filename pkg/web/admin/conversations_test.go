@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+)
+
+func TestConversationsAPI(t *testing.T) {
+	dir := t.TempDir()
+	jsEngine := engine.NewEngine(filepath.Join(dir, "app.db"), filepath.Join(dir, "system.db"))
+	jsEngine.StartDispatcher()
+	t.Cleanup(func() {
+		_ = jsEngine.Close()
+	})
+	done := make(chan error, 1)
+	jsEngine.SubmitJob(engine.EvalJob{Code: `
+		const conv = conversations.create({ title: 'Support' });
+		conversations.append(conv.id, { role: 'user', content: 'Hello' });
+		const reply = conversations.stream(conv.id);
+		reply.write('Hi');
+		reply.end();
+	`, Done: done})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewConversationsHandler(jsEngine)
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.HandleConversationsAPI(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	var list []repository.Conversation
+	if w := request(http.MethodGet, "/admin/conversations/api"); w.Code != http.StatusOK {
+		t.Fatalf("list: %d %s", w.Code, w.Body)
+	} else if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Title != "Support" || list[0].MessageCount != 2 || list[0].Messages != nil {
+		t.Fatalf("list = %+v, want the conversation without its messages", list)
+	}
+
+	path := "/admin/conversations/api/" + strconv.Itoa(list[0].ID)
+	var conversation repository.Conversation
+	if w := request(http.MethodGet, path); w.Code != http.StatusOK {
+		t.Fatalf("get: %d %s", w.Code, w.Body)
+	} else if err := json.Unmarshal(w.Body.Bytes(), &conversation); err != nil {
+		t.Fatal(err)
+	}
+	if len(conversation.Messages) != 2 || len(conversation.Messages[1].Tokens) != 1 || conversation.Messages[1].Content != "Hi" {
+		t.Errorf("messages = %+v", conversation.Messages)
+	}
+
+	if w := request(http.MethodDelete, path); w.Code != http.StatusOK {
+		t.Errorf("delete: %d %s", w.Code, w.Body)
+	}
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, path, http.StatusNotFound},
+		{http.MethodDelete, path, http.StatusNotFound},
+		{http.MethodGet, "/admin/conversations/api/latest", http.StatusBadRequest},
+		{http.MethodPost, "/admin/conversations/api", http.StatusMethodNotAllowed},
+	} {
+		if w := request(tt.method, tt.path); w.Code != tt.want {
+			t.Errorf("%s %s: %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
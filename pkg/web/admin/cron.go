@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// CronHandler validates cron expressions and previews their run times, for scheduling UIs
+type CronHandler struct{}
+
+// NewCronHandler creates a new cron handler
+func NewCronHandler() *CronHandler {
+	return &CronHandler{}
+}
+
+// HandleCron answers GET ?expr=...&count=N&tz=... with whether the expression is valid and
+// its next count (default 5) run times, in the time zone tz or else the server's
+func (ch *CronHandler) HandleCron(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	expr := query.Get("expr")
+	if expr == "" {
+		http.Error(w, "expr is required", http.StatusBadRequest)
+		return
+	}
+
+	count := 5
+	if value := query.Get("count"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "Invalid count", http.StatusBadRequest)
+			return
+		}
+		count = n
+	}
+
+	preview, err := engine.PreviewCron(expr, count, query.Get("tz"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		log.Error().Err(err).Msg("Failed to encode cron preview")
+	}
+}
@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// ExecutionFilterFromQuery builds an execution filter from the query parameters shared by
+// the history and scripts viewers: search, sessionId, source, actor, codeHash, hasError and since
+func ExecutionFilterFromQuery(values url.Values) repository.ExecutionFilter {
+	filter := repository.ExecutionFilter{
+		Search:    strings.TrimSpace(values.Get("search")),
+		SessionID: strings.TrimSpace(values.Get("sessionId")),
+		Source:    values.Get("source"),
+		Actor:     strings.TrimSpace(values.Get("actor")),
+		CodeHash:  strings.TrimSpace(values.Get("codeHash")),
+		Since:     values.Get("since"),
+	}
+
+	if hasError, err := strconv.ParseBool(values.Get("hasError")); err == nil {
+		filter.HasError = &hasError
+	}
+
+	return filter
+}
+
+// handleSavedFiltersAPI lists (GET) or saves (POST) named execution filters
+func (lh *LogsHandler) handleSavedFiltersAPI(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filters, err := lh.repos.SavedFilters().ListFilters(r.Context(), r.URL.Query().Get("page"))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list saved filters")
+			http.Error(w, "Failed to list saved filters", http.StatusInternalServerError)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(filters); err != nil {
+			log.Error().Err(err).Msg("Failed to encode saved filters response")
+		}
+
+	case http.MethodPost:
+		var req repository.SaveFilterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" || req.Page == "" {
+			http.Error(w, "Filter name and page are required", http.StatusBadRequest)
+			return
+		}
+		if req.Filter.Since != "" {
+			if _, err := time.ParseDuration(req.Filter.Since); err != nil {
+				http.Error(w, "Invalid time window: "+req.Filter.Since, http.StatusBadRequest)
+				return
+			}
+		}
+
+		saved, err := lh.repos.SavedFilters().SaveFilter(r.Context(), req)
+		if err != nil {
+			log.Error().Err(err).Str("name", req.Name).Msg("Failed to save filter")
+			http.Error(w, "Failed to save filter", http.StatusInternalServerError)
+			return
+		}
+		log.Info().Str("name", saved.Name).Str("page", saved.Page).Msg("Saved execution filter via admin interface")
+
+		if err := json.NewEncoder(w).Encode(saved); err != nil {
+			log.Error().Err(err).Msg("Failed to encode saved filter response")
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeleteSavedFilterAPI deletes a saved filter
+func (lh *LogsHandler) handleDeleteSavedFilterAPI(w http.ResponseWriter, r *http.Request, filterIDStr string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filterID, err := strconv.Atoi(filterIDStr)
+	if err != nil {
+		http.Error(w, "Invalid filter ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := lh.repos.SavedFilters().DeleteFilter(r.Context(), filterID); err != nil {
+		log.Error().Err(err).Int("filterID", filterID).Msg("Failed to delete saved filter")
+		http.NotFound(w, r)
+		return
+	}
+
+	response := map[string]interface{}{
+		"success": true,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode delete filter response")
+	}
+}
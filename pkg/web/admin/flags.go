@@ -0,0 +1,101 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// FlagsHandler serves the feature flag API behind the flags page
+//
+//	GET    /admin/flags/api          list flags
+//	PUT    /admin/flags/api/{name}   create or change a flag
+//	DELETE /admin/flags/api/{name}   delete a flag
+type FlagsHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewFlagsHandler creates a new feature flags handler
+func NewFlagsHandler(jsEngine *engine.Engine) *FlagsHandler {
+	return &FlagsHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// FlagRequest is the body of PUT /admin/flags/api/{name}
+type FlagRequest struct {
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Percentage  *int   `json:"percentage"` // 100 if not set
+}
+
+// HandleFlagsAPI dispatches the feature flag API requests
+func (fh *FlagsHandler) HandleFlagsAPI(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/flags/api"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flags, err := fh.jsEngine.FeatureFlags()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to list feature flags")
+			http.Error(w, "Failed to list feature flags: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, flags)
+		return
+	}
+
+	name := rest
+	switch r.Method {
+	case http.MethodPut:
+		fh.handleSave(w, r, name)
+	case http.MethodDelete:
+		if err := fh.jsEngine.DeleteFeatureFlag(name); err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				http.Error(w, "Feature flag not found", http.StatusNotFound)
+				return
+			}
+			log.Error().Err(err).Str("flag", name).Msg("Failed to delete feature flag")
+			http.Error(w, "Failed to delete feature flag: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (fh *FlagsHandler) handleSave(w http.ResponseWriter, r *http.Request, name string) {
+	var req FlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	flag := repository.FeatureFlag{
+		Name:        name,
+		Description: req.Description,
+		Enabled:     req.Enabled,
+		Percentage:  100,
+	}
+	if req.Percentage != nil {
+		flag.Percentage = *req.Percentage
+	}
+	if flag.Percentage < 0 || flag.Percentage > 100 {
+		http.Error(w, "The percentage must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	saved, err := fh.jsEngine.SaveFeatureFlag(flag)
+	if err != nil {
+		log.Error().Err(err).Str("flag", name).Msg("Failed to save feature flag")
+		http.Error(w, "Failed to save feature flag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, saved)
+}
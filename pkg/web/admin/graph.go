@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// GraphHandler serves the graph of which scripts registered which routes and which routes
+// use which tables, as JSON for the graph page or as Graphviz DOT with ?format=dot
+type GraphHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewGraphHandler creates a new route graph handler
+func NewGraphHandler(jsEngine *engine.Engine) *GraphHandler {
+	return &GraphHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// HandleGraph returns the route graph
+func (gh *GraphHandler) HandleGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	graph := gh.jsEngine.RouteGraph()
+	switch r.URL.Query().Get("format") {
+	case "", "json":
+		writeJSON(w, graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="routes.dot"`)
+		if _, err := w.Write([]byte(graph.DOT())); err != nil {
+			log.Error().Err(err).Msg("Failed to write route graph")
+		}
+	default:
+		http.Error(w, "Unknown format, use json or dot", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/storage"
+	"github.com/rs/zerolog/log"
+)
+
+// handleArchivesAPI lists the archive files of request logs. Dropped requests waiting for a
+// full batch are written first, so the newest archive includes them.
+func (lh *LogsHandler) handleArchivesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := lh.logger.FlushArchive(); err != nil {
+		log.Warn().Err(err).Msg("Failed to archive pending request logs")
+	}
+	archives, err := lh.logger.Archives(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list log archives")
+		http.Error(w, "Failed to list log archives", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"enabled":  lh.logger.Archiving(),
+		"archives": archives,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode archives response")
+	}
+}
+
+// handleArchiveDownloadAPI sends an archive file of request logs
+func (lh *LogsHandler) handleArchiveDownloadAPI(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, info, err := lh.logger.OpenArchive(r.Context(), name)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Error().Err(err).Str("name", name).Msg("Failed to open log archive")
+		http.Error(w, "Failed to read log archive", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	serveObject(w, r, f, info)
+}
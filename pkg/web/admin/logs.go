@@ -1,28 +1,36 @@
 package admin
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/go-go-golems/jesus/pkg/engine"
 	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/storage"
 	"github.com/rs/zerolog/log"
 )
 
 // LogsHandler handles log-related admin endpoints
 type LogsHandler struct {
-	logger *engine.RequestLogger
-	repos  repository.RepositoryManager
+	logger   *engine.RequestLogger
+	repos    repository.RepositoryManager
+	jsEngine *engine.Engine
 }
 
 // NewLogsHandler creates a new logs handler
-func NewLogsHandler(logger *engine.RequestLogger, repos repository.RepositoryManager) *LogsHandler {
+func NewLogsHandler(logger *engine.RequestLogger, repos repository.RepositoryManager, jsEngine *engine.Engine) *LogsHandler {
 	return &LogsHandler{
-		logger: logger,
-		repos:  repos,
+		logger:   logger,
+		repos:    repos,
+		jsEngine: jsEngine,
 	}
 }
 
@@ -35,14 +43,46 @@ func (lh *LogsHandler) HandleLogsAPI(w http.ResponseWriter, r *http.Request) {
 		lh.handleStatsAPI(w, r)
 	case r.URL.Path == "/admin/logs/api/requests":
 		lh.handleRequestsAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/requests/") && strings.HasSuffix(r.URL.Path, "/timeline"):
+		requestID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/logs/api/requests/"), "/timeline")
+		lh.handleRequestTimelineAPI(w, r, requestID)
 	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/requests/"):
 		requestID := strings.TrimPrefix(r.URL.Path, "/admin/logs/api/requests/")
 		lh.handleRequestDetailsAPI(w, r, requestID)
 	case r.URL.Path == "/admin/logs/api/executions":
 		lh.handleExecutionsAPI(w, r)
+	case r.URL.Path == "/admin/logs/api/executions/compare":
+		lh.handleCompareExecutionsAPI(w, r)
+	case r.URL.Path == "/admin/logs/api/executions/delete":
+		lh.handleBulkDeleteExecutionsAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/executions/") && strings.HasSuffix(r.URL.Path, "/result"):
+		executionID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/logs/api/executions/"), "/result")
+		lh.handleExecutionResultAPI(w, r, executionID)
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/executions/") && strings.Contains(r.URL.Path, "/attachments/"):
+		executionID, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/logs/api/executions/"), "/attachments/")
+		lh.handleExecutionAttachmentAPI(w, r, executionID, name)
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/executions/") && strings.HasSuffix(r.URL.Path, "/output"):
+		executionID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/logs/api/executions/"), "/output")
+		lh.handleExecutionOutputAPI(w, r, executionID)
 	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/executions/"):
 		executionID := strings.TrimPrefix(r.URL.Path, "/admin/logs/api/executions/")
-		lh.handleExecutionDetailsAPI(w, r, executionID)
+		if r.Method == http.MethodDelete {
+			lh.handleDeleteExecutionAPI(w, r, executionID)
+		} else {
+			lh.handleExecutionDetailsAPI(w, r, executionID)
+		}
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/sessions/"):
+		sessionID := strings.TrimPrefix(r.URL.Path, "/admin/logs/api/sessions/")
+		lh.handleDeleteSessionAPI(w, r, sessionID)
+	case r.URL.Path == "/admin/logs/api/filters":
+		lh.handleSavedFiltersAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/filters/"):
+		filterID := strings.TrimPrefix(r.URL.Path, "/admin/logs/api/filters/")
+		lh.handleDeleteSavedFilterAPI(w, r, filterID)
+	case r.URL.Path == "/admin/logs/api/archives":
+		lh.handleArchivesAPI(w, r)
+	case strings.HasPrefix(r.URL.Path, "/admin/logs/api/archives/"):
+		lh.handleArchiveDownloadAPI(w, r, strings.TrimPrefix(r.URL.Path, "/admin/logs/api/archives/"))
 	case r.URL.Path == "/admin/logs/api/clear":
 		lh.handleClearLogsAPI(w, r)
 	default:
@@ -122,9 +162,7 @@ func (lh *LogsHandler) handleExecutionsAPI(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	filter := repository.ExecutionFilter{
-		Search: r.URL.Query().Get("search"),
-	}
+	filter := ExecutionFilterFromQuery(r.URL.Query())
 
 	pagination := repository.PaginationOptions{
 		Limit:  limit,
@@ -132,6 +170,10 @@ func (lh *LogsHandler) handleExecutionsAPI(w http.ResponseWriter, r *http.Reques
 	}
 
 	result, err := lh.repos.Executions().ListExecutions(context.Background(), filter, pagination)
+	if errors.Is(err, repository.ErrInvalidFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch script executions")
 		http.Error(w, "Failed to fetch executions", http.StatusInternalServerError)
@@ -153,8 +195,12 @@ func (lh *LogsHandler) handleExecutionDetailsAPI(w http.ResponseWriter, r *http.
 
 	execution, err := lh.repos.Executions().GetExecution(context.Background(), executionID)
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
 		log.Error().Err(err).Int("executionID", executionID).Msg("Failed to fetch script execution")
-		http.NotFound(w, r)
+		http.Error(w, "Failed to fetch execution", http.StatusInternalServerError)
 		return
 	}
 
@@ -162,3 +208,243 @@ func (lh *LogsHandler) handleExecutionDetailsAPI(w http.ResponseWriter, r *http.
 		log.Error().Err(err).Msg("Failed to encode execution details response")
 	}
 }
+
+// handleExecutionOutputAPI downloads the full output of an execution whose stored output was truncated
+func (lh *LogsHandler) handleExecutionOutputAPI(w http.ResponseWriter, r *http.Request, executionIDStr string) {
+	executionID, err := strconv.Atoi(executionIDStr)
+	if err != nil {
+		http.Error(w, "Invalid execution ID", http.StatusBadRequest)
+		return
+	}
+
+	execution, err := lh.repos.Executions().GetExecution(r.Context(), executionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Error().Err(err).Int("executionID", executionID).Msg("Failed to fetch script execution")
+		http.Error(w, "Failed to fetch execution", http.StatusInternalServerError)
+		return
+	}
+	if execution.Truncation == nil || execution.Truncation.OutputFile == "" {
+		http.Error(w, "No full output was kept for this execution", http.StatusNotFound)
+		return
+	}
+
+	file, info, err := lh.jsEngine.OpenOutputFile(r.Context(), execution.Truncation.OutputFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="execution-%d-output.txt"`, executionID))
+	serveObject(w, r, file, info)
+}
+
+// serveObject sends an object of the engine's storage, with range requests if it can seek
+func serveObject(w http.ResponseWriter, r *http.Request, file io.Reader, info storage.Object) {
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, "", info.Modified, seeker)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		log.Debug().Err(err).Str("key", info.Key).Msg("Failed to send stored file")
+	}
+}
+
+// inlineAttachmentTypes are the raster image types sent inline. SVG and everything else is
+// always a download, since it can carry script.
+var inlineAttachmentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// handleExecutionAttachmentAPI downloads a file a script attached to its execution. Raster
+// images are sent inline so the execution details can show them.
+func (lh *LogsHandler) handleExecutionAttachmentAPI(w http.ResponseWriter, r *http.Request, executionIDStr, name string) {
+	executionID, err := strconv.Atoi(executionIDStr)
+	if err != nil {
+		http.Error(w, "Invalid execution ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := lh.repos.Executions().GetAttachment(r.Context(), executionID, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Error().Err(err).Int("executionID", executionID).Str("name", name).Msg("Failed to fetch attachment")
+		http.Error(w, "Failed to fetch attachment", http.StatusInternalServerError)
+		return
+	}
+	data, err := lh.jsEngine.AttachmentData(r.Context(), attachment)
+	if err != nil {
+		log.Error().Err(err).Int("executionID", executionID).Str("name", name).Msg("Failed to read attachment from storage")
+		http.Error(w, "Failed to fetch attachment", http.StatusInternalServerError)
+		return
+	}
+
+	disposition := "attachment"
+	if inlineAttachmentTypes[attachment.MimeType] && r.URL.Query().Get("download") == "" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.MimeType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	http.ServeContent(w, r, "", attachment.CreatedAt, bytes.NewReader(data))
+}
+
+// handleExecutionResultAPI returns a slice of the paged result of an execution, selected with
+// the offset and limit query parameters
+func (lh *LogsHandler) handleExecutionResultAPI(w http.ResponseWriter, r *http.Request, executionIDStr string) {
+	executionID, err := strconv.Atoi(executionIDStr)
+	if err != nil {
+		http.Error(w, "Invalid execution ID", http.StatusBadRequest)
+		return
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		if offset, err = strconv.Atoi(offsetStr); err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit <= 0 || limit > 1000 {
+			http.Error(w, "Invalid limit (1-1000)", http.StatusBadRequest)
+			return
+		}
+	}
+
+	slice, err := lh.repos.Executions().GetResultSlice(r.Context(), executionID, offset, limit)
+	if err != nil {
+		log.Debug().Err(err).Int("executionID", executionID).Msg("Failed to fetch result slice")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(slice); err != nil {
+		log.Error().Err(err).Msg("Failed to encode result slice response")
+	}
+}
+
+// handleDeleteExecutionAPI deletes a single script execution
+func (lh *LogsHandler) handleDeleteExecutionAPI(w http.ResponseWriter, r *http.Request, executionIDStr string) {
+	executionID, err := strconv.Atoi(executionIDStr)
+	if err != nil {
+		http.Error(w, "Invalid execution ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := lh.repos.Executions().DeleteExecution(r.Context(), executionID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		log.Error().Err(err).Int("executionID", executionID).Msg("Failed to delete script execution")
+		http.Error(w, "Failed to delete execution", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Int("executionID", executionID).Msg("Script execution deleted via admin interface")
+
+	response := map[string]interface{}{
+		"success": true,
+		"deleted": 1,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode delete execution response")
+	}
+}
+
+// handleDeleteSessionAPI deletes all script executions of a session
+func (lh *LogsHandler) handleDeleteSessionAPI(w http.ResponseWriter, r *http.Request, sessionID string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if sessionID == "" {
+		http.Error(w, "Missing session ID", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := lh.repos.Executions().DeleteExecutions(r.Context(), repository.ExecutionFilter{SessionID: sessionID})
+	if err != nil {
+		log.Error().Err(err).Str("sessionID", sessionID).Msg("Failed to delete session executions")
+		http.Error(w, "Failed to delete session", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Str("sessionID", sessionID).Int64("deleted", deleted).Msg("Session executions deleted via admin interface")
+
+	response := map[string]interface{}{
+		"success": true,
+		"deleted": deleted,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode delete session response")
+	}
+}
+
+// BulkDeleteRequest selects executions to delete, either by ID or by filter
+type BulkDeleteRequest struct {
+	IDs    []int                      `json:"ids,omitempty"`
+	Filter repository.ExecutionFilter `json:"filter"`
+	// All must be set to delete with an empty filter, to avoid wiping history by accident
+	All bool `json:"all,omitempty"`
+}
+
+// handleBulkDeleteExecutionsAPI deletes executions by ID list or by filter
+func (lh *LogsHandler) handleBulkDeleteExecutionsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req BulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var deleted int64
+	var err error
+	switch {
+	case len(req.IDs) > 0:
+		deleted, err = lh.repos.Executions().DeleteExecutionsByIDs(r.Context(), req.IDs)
+	case !req.Filter.IsEmpty() || req.All:
+		deleted, err = lh.repos.Executions().DeleteExecutions(r.Context(), req.Filter)
+	default:
+		http.Error(w, "Refusing to delete without ids or filter (set all=true to delete everything)", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, repository.ErrInvalidFilter) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to bulk delete script executions")
+		http.Error(w, "Failed to delete executions", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Int64("deleted", deleted).Interface("filter", req.Filter).Int("ids", len(req.IDs)).Msg("Script executions bulk deleted via admin interface")
+
+	response := map[string]interface{}{
+		"success": true,
+		"deleted": deleted,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode bulk delete response")
+	}
+}
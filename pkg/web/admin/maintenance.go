@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// MaintenanceHandler toggles maintenance mode, which pauses the JavaScript routes
+type MaintenanceHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewMaintenanceHandler creates a new maintenance handler
+func NewMaintenanceHandler(jsEngine *engine.Engine) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// MaintenanceRequest is the body of POST /admin/maintenance
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// HandleMaintenance returns the maintenance status on GET and changes it on POST
+func (mh *MaintenanceHandler) HandleMaintenance(w http.ResponseWriter, r *http.Request) {
+	var status engine.MaintenanceStatus
+	switch r.Method {
+	case "GET":
+		status = mh.jsEngine.Maintenance()
+	case "POST":
+		var req MaintenanceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		status = mh.jsEngine.SetMaintenance(req.Enabled, req.Message)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Error().Err(err).Msg("Failed to encode maintenance status")
+	}
+}
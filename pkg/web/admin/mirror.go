@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// MirrorHandler lists how the mirrors of routes answered compared with the routes
+type MirrorHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewMirrorHandler creates a new mirror handler
+func NewMirrorHandler(jsEngine *engine.Engine) *MirrorHandler {
+	return &MirrorHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// mirrorReport is the answer of GET /admin/mirror
+type mirrorReport struct {
+	Total      int                   `json:"total"`
+	Mismatches int                   `json:"mismatches"`
+	Results    []engine.MirrorResult `json:"results"`
+}
+
+// HandleMirror returns the recent mirror outcomes on GET, ?mismatches=1 for the differing
+// ones only, and forgets them on DELETE
+func (mh *MirrorHandler) HandleMirror(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "DELETE":
+		mh.jsEngine.ClearMirrorResults()
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	results := mh.jsEngine.MirrorResults()
+	report := mirrorReport{Total: len(results), Results: []engine.MirrorResult{}}
+	onlyMismatches := r.URL.Query().Get("mismatches") != ""
+	for _, result := range results {
+		if !result.Match {
+			report.Mismatches++
+		}
+		if !onlyMismatches || !result.Match {
+			report.Results = append(report.Results, result)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Error().Err(err).Msg("Failed to encode mirror results")
+	}
+}
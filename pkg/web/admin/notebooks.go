@@ -0,0 +1,469 @@
+package admin
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// NotebooksHandler serves the notebook API. The cells of a notebook run one after another
+// in the session runtime of the notebook, so variables carry over from cell to cell. Each
+// run is stored as a script execution, which holds the output and attachments of the cell.
+//
+//	GET    /admin/notebooks/api                      list notebooks
+//	POST   /admin/notebooks/api                      create a notebook
+//	GET    /admin/notebooks/api/{id}                 notebook with the output of its cells
+//	PUT    /admin/notebooks/api/{id}                 save name and cells
+//	DELETE /admin/notebooks/api/{id}                 delete the notebook and its session
+//	POST   /admin/notebooks/api/{id}/run             run a cell, or all cells in a fresh session
+//	POST   /admin/notebooks/api/{id}/restart         drop the session runtime
+//	GET    /admin/notebooks/api/{id}/export?format=  markdown, script or bundle (zip)
+type NotebooksHandler struct {
+	repos    repository.RepositoryManager
+	jsEngine *engine.Engine
+	runMu    sync.Mutex // Runs one notebook request at a time, keeping cell order
+}
+
+// NewNotebooksHandler creates a new notebooks handler
+func NewNotebooksHandler(repos repository.RepositoryManager, jsEngine *engine.Engine) *NotebooksHandler {
+	return &NotebooksHandler{
+		repos:    repos,
+		jsEngine: jsEngine,
+	}
+}
+
+// NotebookCellView is a cell with the execution of its last run
+type NotebookCellView struct {
+	repository.NotebookCell
+	Output *repository.ScriptExecution `json:"output,omitempty"`
+}
+
+// NotebookView is a notebook as shown in the notebook UI
+type NotebookView struct {
+	ID        int                `json:"id"`
+	Name      string             `json:"name"`
+	SessionID string             `json:"session_id"`
+	Cells     []NotebookCellView `json:"cells"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// NotebookRunRequest is the body of POST /admin/notebooks/api/{id}/run
+type NotebookRunRequest struct {
+	Name  *string                   `json:"name,omitempty"`  // Saved before running, if set
+	Cells []repository.NotebookCell `json:"cells,omitempty"` // Saved before running, if set
+	Cell  string                    `json:"cell,omitempty"`  // Cell to run, "" to run all cells in a fresh session
+}
+
+// notebookSessionID names the session runtime of a notebook
+func notebookSessionID(id int) string {
+	return "notebook-" + strconv.Itoa(id)
+}
+
+// HandleNotebooksAPI dispatches the notebook API requests
+func (nh *NotebooksHandler) HandleNotebooksAPI(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/notebooks/api"), "/")
+	if rest == "" {
+		switch r.Method {
+		case http.MethodGet:
+			nh.handleList(w, r)
+		case http.MethodPost:
+			nh.handleCreate(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	idStr, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid notebook ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		nh.handleGet(w, r, id)
+	case action == "" && r.Method == http.MethodPut:
+		nh.handleUpdate(w, r, id)
+	case action == "" && r.Method == http.MethodDelete:
+		nh.handleDelete(w, r, id)
+	case action == "run" && r.Method == http.MethodPost:
+		nh.handleRun(w, r, id)
+	case action == "restart" && r.Method == http.MethodPost:
+		nh.jsEngine.CloseSession(notebookSessionID(id))
+		writeJSON(w, map[string]interface{}{"success": true})
+	case action == "export" && r.Method == http.MethodGet:
+		nh.handleExport(w, r, id)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (nh *NotebooksHandler) handleList(w http.ResponseWriter, r *http.Request) {
+	notebooks, err := nh.repos.Notebooks().ListNotebooks(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list notebooks")
+		http.Error(w, "Failed to list notebooks", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, notebooks)
+}
+
+func (nh *NotebooksHandler) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req repository.SaveNotebookRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := normalizeNotebook(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notebook, err := nh.repos.Notebooks().CreateNotebook(r.Context(), req)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create notebook")
+		http.Error(w, "Failed to create notebook", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Int("id", notebook.ID).Str("name", notebook.Name).Msg("Created notebook via admin interface")
+	nh.writeView(r.Context(), w, notebook)
+}
+
+func (nh *NotebooksHandler) handleGet(w http.ResponseWriter, r *http.Request, id int) {
+	notebook, err := nh.repos.Notebooks().GetNotebook(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	nh.writeView(r.Context(), w, notebook)
+}
+
+func (nh *NotebooksHandler) handleUpdate(w http.ResponseWriter, r *http.Request, id int) {
+	var req repository.SaveNotebookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := normalizeNotebook(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notebook, err := nh.repos.Notebooks().UpdateNotebook(r.Context(), id, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	nh.writeView(r.Context(), w, notebook)
+}
+
+func (nh *NotebooksHandler) handleDelete(w http.ResponseWriter, r *http.Request, id int) {
+	if err := nh.repos.Notebooks().DeleteNotebook(r.Context(), id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	nh.jsEngine.CloseSession(notebookSessionID(id))
+	log.Info().Int("id", id).Msg("Deleted notebook via admin interface")
+	writeJSON(w, map[string]interface{}{"success": true})
+}
+
+// handleRun runs one cell in the notebook's session, or all cells in order in a fresh
+// session, stopping at the first cell that fails
+func (nh *NotebooksHandler) handleRun(w http.ResponseWriter, r *http.Request, id int) {
+	if err := nh.jsEngine.CheckWritable(); err != nil {
+		http.Error(w, "Code execution is disabled: "+err.Error(), http.StatusForbidden)
+		return
+	}
+
+	var req NotebookRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nh.runMu.Lock()
+	defer nh.runMu.Unlock()
+
+	// The cells run to the end even if the client goes away, so their outputs are saved
+	ctx := context.WithoutCancel(r.Context())
+	notebook, err := nh.repos.Notebooks().GetNotebook(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	save := repository.SaveNotebookRequest{Name: notebook.Name, Cells: notebook.Cells}
+	if req.Name != nil {
+		save.Name = *req.Name
+	}
+	if req.Cells != nil {
+		save.Cells = req.Cells
+	}
+	if err := normalizeNotebook(&save); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessionID := notebookSessionID(id)
+	run := make([]int, 0, len(save.Cells))
+	if req.Cell == "" {
+		nh.jsEngine.CloseSession(sessionID)
+		for i := range save.Cells {
+			run = append(run, i)
+		}
+	} else {
+		for i, cell := range save.Cells {
+			if cell.ID == req.Cell {
+				run = append(run, i)
+			}
+		}
+		if len(run) == 0 {
+			http.Error(w, fmt.Sprintf("notebook has no cell %q", req.Cell), http.StatusNotFound)
+			return
+		}
+	}
+
+	for _, i := range run {
+		result := nh.runCell(r, sessionID, save.Cells[i].Code)
+		if result.ExecutionID != 0 {
+			executionID := result.ExecutionID
+			save.Cells[i].ExecutionID = &executionID
+		}
+		if result.Error != nil {
+			break
+		}
+	}
+
+	notebook, err = nh.repos.Notebooks().UpdateNotebook(ctx, id, save)
+	if err != nil {
+		log.Error().Err(err).Int("id", id).Msg("Failed to save notebook after run")
+		http.Error(w, "Failed to save notebook", http.StatusInternalServerError)
+		return
+	}
+	nh.writeView(ctx, w, notebook)
+}
+
+// runCell executes the code of a cell in the notebook session and waits for its result
+func (nh *NotebooksHandler) runCell(r *http.Request, sessionID, code string) *engine.EvalResult {
+	done := make(chan error, 1)
+	resultChan := make(chan *engine.EvalResult, 1)
+	nh.jsEngine.SubmitJob(engine.EvalJob{
+		Code:      code,
+		Done:      done,
+		Result:    resultChan,
+		SessionID: sessionID,
+		Stateful:  true,
+		Source:    repository.SourceNotebook,
+		Actor:     nh.jsEngine.APIKeyActor(r),
+	})
+	result := <-resultChan
+	<-done
+	return result
+}
+
+// notebookView loads the output of the cells of a notebook
+func (nh *NotebooksHandler) notebookView(ctx context.Context, notebook *repository.Notebook) *NotebookView {
+	view := &NotebookView{
+		ID:        notebook.ID,
+		Name:      notebook.Name,
+		SessionID: notebookSessionID(notebook.ID),
+		Cells:     make([]NotebookCellView, 0, len(notebook.Cells)),
+		CreatedAt: notebook.CreatedAt,
+		UpdatedAt: notebook.UpdatedAt,
+	}
+	for _, cell := range notebook.Cells {
+		cellView := NotebookCellView{NotebookCell: cell}
+		if cell.ExecutionID != nil {
+			execution, err := nh.repos.Executions().GetExecution(ctx, *cell.ExecutionID)
+			if err != nil {
+				// The execution was deleted from the history; the cell shows no output
+				log.Debug().Err(err).Int("executionID", *cell.ExecutionID).Msg("Notebook cell output not found")
+			} else {
+				cellView.Output = execution
+			}
+		}
+		view.Cells = append(view.Cells, cellView)
+	}
+	return view
+}
+
+func (nh *NotebooksHandler) writeView(ctx context.Context, w http.ResponseWriter, notebook *repository.Notebook) {
+	writeJSON(w, nh.notebookView(ctx, notebook))
+}
+
+// normalizeNotebook names untitled notebooks and gives cells without an ID a new one
+func normalizeNotebook(req *repository.SaveNotebookRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = "Untitled notebook"
+	}
+	seen := make(map[string]bool, len(req.Cells))
+	for i := range req.Cells {
+		if req.Cells[i].ID == "" {
+			req.Cells[i].ID = uuid.New().String()
+		}
+		if seen[req.Cells[i].ID] {
+			return fmt.Errorf("duplicate cell ID %q", req.Cells[i].ID)
+		}
+		seen[req.Cells[i].ID] = true
+	}
+	return nil
+}
+
+// handleExport downloads a notebook as markdown with the cell outputs, as a script with the
+// code of all cells, or as a zip bundle of both plus the attachments
+func (nh *NotebooksHandler) handleExport(w http.ResponseWriter, r *http.Request, id int) {
+	notebook, err := nh.repos.Notebooks().GetNotebook(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	view := nh.notebookView(r.Context(), notebook)
+	name := exportFileName(notebook.Name)
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "markdown":
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.md"`, name))
+		_, _ = w.Write([]byte(notebookMarkdown(view, "")))
+	case "script":
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.js"`, name))
+		_, _ = w.Write([]byte(notebookScript(view)))
+	case "bundle":
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+		if err := nh.writeBundle(r.Context(), w, view, name); err != nil {
+			log.Error().Err(err).Int("id", id).Msg("Failed to write notebook bundle")
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown export format %q, use markdown, script or bundle", format), http.StatusBadRequest)
+	}
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFileName turns a notebook name into a file name without directories or quotes
+func exportFileName(name string) string {
+	fileName := strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "-"), "-.")
+	if fileName == "" {
+		return "notebook"
+	}
+	return fileName
+}
+
+// cellAttachmentPath is the path of an attachment in an export bundle
+func cellAttachmentPath(cell int, name string) string {
+	return fmt.Sprintf("attachments/cell-%d/%s", cell+1, name)
+}
+
+// notebookMarkdown renders the cells and their outputs as markdown. With a bundle name,
+// attachments link to their copies in the bundle.
+func notebookMarkdown(view *NotebookView, bundle string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", view.Name)
+	for i, cell := range view.Cells {
+		fmt.Fprintf(&b, "\n## Cell %d\n\n```javascript\n%s\n```\n", i+1, strings.TrimRight(cell.Code, "\n"))
+		output := cell.Output
+		if output == nil {
+			continue
+		}
+		if output.ConsoleLog != nil && *output.ConsoleLog != "" {
+			fmt.Fprintf(&b, "\nConsole:\n\n```\n%s\n```\n", *output.ConsoleLog)
+		}
+		if output.Result != nil {
+			fmt.Fprintf(&b, "\nResult:\n\n```json\n%s\n```\n", *output.Result)
+		}
+		if output.Error != nil {
+			fmt.Fprintf(&b, "\nError:\n\n```\n%s\n```\n", *output.Error)
+		}
+		if len(output.Attachments) > 0 {
+			b.WriteString("\nAttachments:\n\n")
+			for _, attachment := range output.Attachments {
+				if bundle != "" {
+					fmt.Fprintf(&b, "- [%s](%s) (%s, %d bytes)\n", attachment.Name, cellAttachmentPath(i, attachment.Name), attachment.MimeType, attachment.Size)
+				} else {
+					fmt.Fprintf(&b, "- %s (%s, %d bytes)\n", attachment.Name, attachment.MimeType, attachment.Size)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// notebookScript joins the code of the cells into one script, which runs like the notebook
+// when executed in a single runtime
+func notebookScript(view *NotebookView) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Notebook: %s\n", view.Name)
+	for i, cell := range view.Cells {
+		fmt.Fprintf(&b, "\n// %%%% Cell %d\n%s\n", i+1, strings.TrimRight(cell.Code, "\n"))
+	}
+	return b.String()
+}
+
+// writeBundle writes a zip with the markdown and script exports and the cell attachments
+func (nh *NotebooksHandler) writeBundle(ctx context.Context, w http.ResponseWriter, view *NotebookView, name string) error {
+	archive := zip.NewWriter(w)
+	files := []struct{ name, content string }{
+		{name + ".md", notebookMarkdown(view, name)},
+		{name + ".js", notebookScript(view)},
+	}
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write([]byte(file.content)); err != nil {
+			return err
+		}
+	}
+
+	for i, cell := range view.Cells {
+		if cell.Output == nil {
+			continue
+		}
+		for _, info := range cell.Output.Attachments {
+			attachment, err := nh.repos.Executions().GetAttachment(ctx, cell.Output.ID, info.Name)
+			if err != nil {
+				return err
+			}
+			data, err := nh.jsEngine.AttachmentData(ctx, attachment)
+			if err != nil {
+				return err
+			}
+			f, err := archive.Create(cellAttachmentPath(i, attachment.Name))
+			if err != nil {
+				return err
+			}
+			if _, err := f.Write(data); err != nil {
+				return err
+			}
+		}
+	}
+	return archive.Close()
+}
+
+// writeJSON encodes a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
@@ -0,0 +1,52 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadOnlyHandler toggles read-only mode, which refuses code execution and route changes
+// while the registered routes keep serving
+type ReadOnlyHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewReadOnlyHandler creates a new read-only mode handler
+func NewReadOnlyHandler(jsEngine *engine.Engine) *ReadOnlyHandler {
+	return &ReadOnlyHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// ReadOnlyRequest is the body of POST /admin/readonly
+type ReadOnlyRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// HandleReadOnly returns the read-only status on GET and changes it on POST
+func (rh *ReadOnlyHandler) HandleReadOnly(w http.ResponseWriter, r *http.Request) {
+	var status engine.ReadOnlyStatus
+	switch r.Method {
+	case "GET":
+		status = rh.jsEngine.ReadOnly()
+	case "POST":
+		var req ReadOnlyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		status = rh.jsEngine.SetReadOnly(req.Enabled, req.Message)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Error().Err(err).Msg("Failed to encode read-only status")
+	}
+}
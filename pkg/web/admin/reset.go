@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// ResetHandler resets the JavaScript runtimes to a fresh start
+type ResetHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewResetHandler creates a new reset handler
+func NewResetHandler(jsEngine *engine.Engine) *ResetHandler {
+	return &ResetHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// ResetResponse is the answer of POST /admin/reset
+type ResetResponse struct {
+	Success bool `json:"success"`
+	*engine.ResetResult
+}
+
+// HandleReset resets the runtimes and runs the bootstrap file and scripts again. The body
+// {"keepState": true}, or ?keepState=1, carries globalState over.
+func (rh *ResetHandler) HandleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var opts engine.ResetOptions
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	switch r.URL.Query().Get("keepState") {
+	case "1", "true":
+		opts.KeepState = true
+	}
+
+	result, err := rh.jsEngine.Reset(opts)
+	status := http.StatusOK
+	if errors.Is(err, engine.ErrReadOnly) {
+		http.Error(w, "Failed to reset: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	if result == nil {
+		http.Error(w, "Failed to reset: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// The runtimes were reset, but the bootstrap file failed
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(ResetResponse{Success: err == nil, ResetResult: result}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode reset response")
+	}
+}
@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/collab"
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	editorJoinTimeout = 10 * time.Second // Time a client has to send its join message
+	editorQueueSize   = 256              // Messages waiting to be sent to a client before it is dropped
+	maxEditorName     = 40               // Length of a participant name, in bytes
+)
+
+// ScriptEditorHandler serves the collaborative editor of the script files. Everybody
+// editing a file shares one document: edits show up in the other editors as they are
+// typed, along with the cursors of the other participants, and saving writes the shared
+// text, so nobody's save overwrites edits they had not seen. Uploads to a file being edited
+// are merged into its document, so an agent writing a script through the API and a human
+// editing it in the browser do not clobber each other either.
+//
+//	GET /admin/scripts/edit                     the editor page
+//	GET /admin/scripts/edit/ws?path=api/users.js   WebSocket of the document of a file
+//
+// The WebSocket speaks the protocol of collab.Document; its first message must be a join
+// message carrying one of the API keys set with --api-keys.
+type ScriptEditorHandler struct {
+	jsEngine *engine.Engine
+
+	mu        sync.Mutex
+	documents map[string]*collab.Document // Files being edited, by path relative to the scripts directory
+	saveMu    sync.Mutex                  // Serializes saves, so an older text never overwrites a newer one
+}
+
+// NewScriptEditorHandler creates a new script editor handler
+func NewScriptEditorHandler(jsEngine *engine.Engine) *ScriptEditorHandler {
+	return &ScriptEditorHandler{
+		jsEngine:  jsEngine,
+		documents: make(map[string]*collab.Document),
+	}
+}
+
+// HandleWebSocket serves /admin/scripts/edit/ws
+func (eh *ScriptEditorHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !eh.jsEngine.HasAPIKeys() {
+		http.Error(w, "Script editing is disabled: start the server with --api-keys", http.StatusForbidden)
+		return
+	}
+	dir := eh.jsEngine.ScriptsDir()
+	if dir == "" {
+		http.Error(w, "No scripts directory is loaded: start the server with --scripts", http.StatusNotFound)
+		return
+	}
+	rel := strings.TrimPrefix(path.Clean("/"+r.URL.Query().Get("path")), "/")
+	if !engine.IsScriptFile(rel) {
+		http.Error(w, "Only .js and .ts files can be edited", http.StatusBadRequest)
+		return
+	}
+	if !engine.IsWebSocketRequest(r) {
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			return checkSameOrigin(req)
+		},
+		Handler: func(conn *websocket.Conn) {
+			eh.serveEditor(conn, r, rel, filepath.Join(dir, filepath.FromSlash(rel)))
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkSameOrigin refuses WebSockets opened by pages of other sites, which would edit the
+// scripts with the credentials of the browser
+func checkSameOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		return fmt.Errorf("origin %s is not allowed", origin)
+	}
+	return nil
+}
+
+// serveEditor authenticates a client and relays its messages to the document of the file
+func (eh *ScriptEditorHandler) serveEditor(conn *websocket.Conn, r *http.Request, rel, file string) {
+	conn.MaxPayloadBytes = 2 * maxScriptFileSize
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var join collab.Message
+	_ = conn.SetReadDeadline(time.Now().Add(editorJoinTimeout))
+	if err := websocket.JSON.Receive(conn, &join); err != nil || join.Type != collab.MessageJoin {
+		_ = websocket.JSON.Send(conn, collab.Message{Type: collab.MessageError, Error: "expected a join message"})
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	if !eh.jsEngine.ValidAPIKey(join.Key) {
+		_ = websocket.JSON.Send(conn, collab.Message{Type: collab.MessageError, Error: "a valid API key is required"})
+		return
+	}
+	// Admin actions of the client are recorded as if it had sent its key with the upgrade
+	authed := r.Clone(context.Background())
+	authed.Method = engine.WebSocketMethod
+	authed.Header.Set(engine.APIKeyHeader, join.Key)
+	name := strings.TrimSpace(join.Name)
+	if name == "" {
+		name = eh.jsEngine.AdminActor(authed)
+	}
+	if len(name) > maxEditorName {
+		name = name[:maxEditorName]
+	}
+
+	// Messages are sent by a writer of their own, so a slow client does not hold up the
+	// document; one falling too far behind is dropped and has to join again
+	out := make(chan collab.Message, editorQueueSize)
+	var dropped sync.Once
+	send := func(msg collab.Message) {
+		select {
+		case out <- msg:
+		default:
+			dropped.Do(func() {
+				log.Warn().Str("file", rel).Str("name", name).Msg("Script editor client fell behind, disconnecting it")
+				_ = conn.Close()
+			})
+		}
+	}
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for msg := range out {
+			if err := websocket.JSON.Send(conn, msg); err != nil {
+				_ = conn.Close()
+				for range out {
+				}
+				return
+			}
+		}
+	}()
+
+	doc, participant, err := eh.join(rel, file, name, send)
+	if err != nil {
+		send(collab.Message{Type: collab.MessageError, Error: err.Error()})
+		close(out)
+		<-writerDone
+		return
+	}
+	log.Info().Str("file", rel).Str("name", name).Msg("Joined script editor")
+
+	for {
+		var msg collab.Message
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			break
+		}
+		if err := eh.handleMessage(authed, doc, participant, rel, file, msg); err != nil {
+			send(collab.Message{Type: collab.MessageError, Error: err.Error()})
+		}
+	}
+
+	eh.leave(rel, doc, participant)
+	close(out)
+	<-writerDone
+	log.Info().Str("file", rel).Str("name", name).Msg("Left script editor")
+}
+
+// handleMessage applies a message of a participant to the document
+func (eh *ScriptEditorHandler) handleMessage(r *http.Request, doc *collab.Document, p *collab.Participant, rel, file string, msg collab.Message) error {
+	switch msg.Type {
+	case collab.MessageOp:
+		if msg.Op == nil {
+			return fmt.Errorf("op message without operation")
+		}
+		if err := eh.jsEngine.CheckWritable(); err != nil {
+			return fmt.Errorf("scripts cannot be changed: %w", err)
+		}
+		return doc.Edit(p, msg.Revision, *msg.Op, msg.Selection)
+	case collab.MessageSelection:
+		return doc.Select(p, msg.Revision, msg.Selection)
+	case collab.MessageSave:
+		return eh.save(r, doc, p, rel, file)
+	default:
+		return fmt.Errorf("unknown message type %q", msg.Type)
+	}
+}
+
+// save writes the text of the document to the file and runs it like an upload
+func (eh *ScriptEditorHandler) save(r *http.Request, doc *collab.Document, p *collab.Participant, rel, file string) error {
+	if err := eh.jsEngine.CheckWritable(); err != nil {
+		return fmt.Errorf("scripts cannot be changed: %w", err)
+	}
+
+	eh.saveMu.Lock()
+	defer eh.saveMu.Unlock()
+	text, revision := doc.Text()
+	if len(text) > maxScriptFileSize {
+		return fmt.Errorf("script exceeds %d bytes", maxScriptFileSize)
+	}
+	if err := writeFileAtomic(file, []byte(text)); err != nil {
+		log.Error().Err(err).Str("file", file).Msg("Failed to write edited script")
+		eh.jsEngine.RecordAdminAction(r, "script.save", []byte(rel), http.StatusInternalServerError)
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+	log.Info().Str("file", file).Str("name", p.Name).Int("revision", revision).Msg("Saved script from the editor")
+
+	status, errMessage := http.StatusOK, ""
+	if err := eh.jsEngine.LoadScriptFile(file); err != nil {
+		status, errMessage = http.StatusUnprocessableEntity, err.Error()
+	} else if eh.jsEngine.WarmUpEnabled() {
+		eh.jsEngine.WarmUp(file)
+	}
+	doc.Saved(text, revision, p.Name, errMessage)
+	eh.jsEngine.RecordAdminAction(r, "script.save", []byte(rel), status)
+	return nil
+}
+
+// join adds a participant to the document of a file, opening it if nobody edits the file
+func (eh *ScriptEditorHandler) join(rel, file, name string, send func(collab.Message)) (*collab.Document, *collab.Participant, error) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	doc, ok := eh.documents[rel]
+	if !ok {
+		data, err := os.ReadFile(file)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, fmt.Errorf("failed to read script: %w", err)
+		}
+		doc = collab.NewDocument(string(data))
+		eh.documents[rel] = doc
+	}
+	return doc, doc.Join(name, send), nil
+}
+
+// leave removes a participant from the document of a file, closing it once nobody edits
+// the file anymore. Unsaved edits are lost then.
+func (eh *ScriptEditorHandler) leave(rel string, doc *collab.Document, p *collab.Participant) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	if doc.Leave(p) && eh.documents[rel] == doc {
+		delete(eh.documents, rel)
+	}
+}
+
+// document returns the document of a file being edited, nil if nobody edits it
+func (eh *ScriptEditorHandler) document(rel string) *collab.Document {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	return eh.documents[rel]
+}
+
+// FileWritten merges a file written through the script files API into its document, if it
+// is being edited
+func (eh *ScriptEditorHandler) FileWritten(rel string, data []byte, who string) {
+	doc := eh.document(rel)
+	if doc == nil {
+		return
+	}
+	if _, err := doc.Merge(string(data), who); err != nil {
+		log.Error().Err(err).Str("file", rel).Msg("Failed to merge uploaded script into the editor")
+		doc.Broadcast(collab.Message{Type: collab.MessageError, Error: "the file was replaced by " + who + ", reload it to see the change"})
+	}
+}
+
+// FileDeleted tells the participants editing a file that it was deleted
+func (eh *ScriptEditorHandler) FileDeleted(rel, who string) {
+	if doc := eh.document(rel); doc != nil {
+		doc.Broadcast(collab.Message{Type: collab.MessageError, Error: "the file was deleted by " + who + ", saving creates it again"})
+	}
+}
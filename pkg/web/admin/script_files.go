@@ -0,0 +1,230 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// maxScriptFileSize bounds the size of an uploaded script
+const maxScriptFileSize = 5 << 20
+
+// ScriptFilesHandler lets editors and deployment pipelines read and write the files of the
+// scripts directory over HTTP. Every request needs one of the API keys set with --api-keys.
+//
+//	GET    /admin/scripts/files/            list the script files
+//	GET    /admin/scripts/files/api/users.js
+//	PUT    /admin/scripts/files/api/users.js   write the file and run it (?run=false to only write),
+//	                                           then warm up its GET routes with --warm-up
+//	DELETE /admin/scripts/files/api/users.js   delete the file and unregister its routes
+//
+// Files open in the script editor get the uploads merged into their document.
+type ScriptFilesHandler struct {
+	jsEngine *engine.Engine
+	editor   *ScriptEditorHandler
+}
+
+// NewScriptFilesHandler creates a new script files handler, telling editor about the files
+// it writes
+func NewScriptFilesHandler(jsEngine *engine.Engine, editor *ScriptEditorHandler) *ScriptFilesHandler {
+	return &ScriptFilesHandler{
+		jsEngine: jsEngine,
+		editor:   editor,
+	}
+}
+
+// ScriptFile describes a file of the scripts directory
+type ScriptFile struct {
+	Path     string    `json:"path"` // Slash-separated, relative to the scripts directory
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// ScriptUploadResponse is the answer of a PUT
+type ScriptUploadResponse struct {
+	ScriptFile
+	Created bool   `json:"created"`         // The file did not exist before
+	Ran     bool   `json:"ran"`             // The file was run after writing it
+	Error   string `json:"error,omitempty"` // Error thrown while running the file, which is kept
+
+	WarmUp []engine.WarmUpResult `json:"warmUp,omitempty"` // Warm-up requests of the GET routes of the file, with --warm-up
+}
+
+// HandleScriptFiles serves /admin/scripts/files/
+func (sh *ScriptFilesHandler) HandleScriptFiles(w http.ResponseWriter, r *http.Request) {
+	if !sh.jsEngine.HasAPIKeys() {
+		http.Error(w, "Script file access is disabled: start the server with --api-keys", http.StatusForbidden)
+		return
+	}
+	if !sh.jsEngine.ValidAPIKey(engine.RequestAPIKey(r)) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="jesus"`)
+		http.Error(w, "A valid API key is required", http.StatusUnauthorized)
+		return
+	}
+
+	dir := sh.jsEngine.ScriptsDir()
+	if dir == "" {
+		http.Error(w, "No scripts directory is loaded: start the server with --scripts", http.StatusNotFound)
+		return
+	}
+
+	// Cleaning the rooted path drops .. segments, so the file stays inside the directory
+	rel := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/admin/scripts/files")), "/")
+	if rel == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		sh.handleList(w, dir)
+		return
+	}
+	if !engine.IsScriptFile(rel) {
+		http.Error(w, "Only .js and .ts files can be accessed", http.StatusBadRequest)
+		return
+	}
+	file := filepath.Join(dir, filepath.FromSlash(rel))
+
+	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+		if err := sh.jsEngine.CheckWritable(); err != nil {
+			http.Error(w, "Scripts cannot be changed: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		http.ServeFile(w, r, file)
+	case http.MethodPut:
+		sh.handleUpload(w, r, rel, file)
+	case http.MethodDelete:
+		if err := os.Remove(file); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				http.Error(w, "Script not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to delete script: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		removed := sh.jsEngine.UnloadScriptFile(file)
+		sh.editor.FileDeleted(rel, sh.jsEngine.AdminActor(r))
+		log.Info().Str("file", file).Int("routes", removed).Msg("Deleted script via admin interface")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (sh *ScriptFilesHandler) handleList(w http.ResponseWriter, dir string) {
+	files := []ScriptFile{}
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || !engine.IsScriptFile(file) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, ScriptFile{Path: filepath.ToSlash(rel), Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to list scripts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(files); err != nil {
+		log.Error().Err(err).Msg("Failed to encode script files")
+	}
+}
+
+// handleUpload replaces the file with the request body, then runs it like a startup script
+func (sh *ScriptFilesHandler) handleUpload(w http.ResponseWriter, r *http.Request, rel, file string) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxScriptFileSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxScriptFileSize {
+		http.Error(w, "Script exceeds "+strconv.Itoa(maxScriptFileSize)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	_, statErr := os.Stat(file)
+	created := errors.Is(statErr, fs.ErrNotExist)
+	if err := writeFileAtomic(file, body); err != nil {
+		log.Error().Err(err).Str("file", file).Msg("Failed to write uploaded script")
+		http.Error(w, "Failed to write script: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Info().Str("file", file).Int("bytes", len(body)).Msg("Wrote script via admin interface")
+	sh.editor.FileWritten(rel, body, sh.jsEngine.AdminActor(r))
+
+	response := ScriptUploadResponse{
+		ScriptFile: ScriptFile{Path: rel, Size: int64(len(body)), Modified: time.Now()},
+		Created:    created,
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	if r.URL.Query().Get("run") != "false" {
+		response.Ran = true
+		if err := sh.jsEngine.LoadScriptFile(file); err != nil {
+			response.Error = err.Error()
+			status = http.StatusUnprocessableEntity
+		} else if sh.jsEngine.WarmUpEnabled() {
+			response.WarmUp = sh.jsEngine.WarmUp(file)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode script upload response")
+	}
+}
+
+// writeFileAtomic writes a file through a temporary file, so the scripts directory never
+// holds a half-written script
+func writeFileAtomic(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
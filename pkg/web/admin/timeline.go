@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/rs/zerolog/log"
+)
+
+// TimelineEvent is a single step in the processing of a request
+type TimelineEvent struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Kind      string      `json:"kind"` // "request", "handler", "log", "db", "execution", "response"
+	Summary   string      `json:"summary"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// RequestTimeline correlates a request log with the executions it triggered
+type RequestTimeline struct {
+	Request    *engine.RequestLog           `json:"request"`
+	Executions []repository.ScriptExecution `json:"executions"`
+	Events     []TimelineEvent              `json:"events"`
+}
+
+// handleRequestTimelineAPI returns the request → handler → db ops → response timeline of a request
+func (lh *LogsHandler) handleRequestTimelineAPI(w http.ResponseWriter, r *http.Request, requestID string) {
+	request, exists := lh.logger.GetRequestByID(requestID)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	result, err := lh.repos.Executions().ListExecutions(r.Context(),
+		repository.ExecutionFilter{RequestID: requestID},
+		repository.PaginationOptions{Limit: 100, Offset: 0})
+	if err != nil {
+		log.Error().Err(err).Str("requestID", requestID).Msg("Failed to fetch executions for request")
+		http.Error(w, "Failed to fetch executions", http.StatusInternalServerError)
+		return
+	}
+
+	timeline := RequestTimeline{
+		Request:    request,
+		Executions: result.Executions,
+		Events:     buildTimelineEvents(request, result.Executions),
+	}
+
+	if err := json.NewEncoder(w).Encode(timeline); err != nil {
+		log.Error().Err(err).Msg("Failed to encode request timeline response")
+	}
+}
+
+// buildTimelineEvents merges request, console, database and execution records into one ordered list
+func buildTimelineEvents(request *engine.RequestLog, executions []repository.ScriptExecution) []TimelineEvent {
+	var events []TimelineEvent
+
+	for _, entry := range request.Logs {
+		events = append(events, TimelineEvent{
+			Timestamp: entry.Timestamp,
+			Kind:      "log",
+			Summary:   fmt.Sprintf("console.%s %s", entry.Level, entry.Message),
+			Data:      entry.Data,
+		})
+	}
+
+	for _, op := range request.DatabaseOps {
+		events = append(events, TimelineEvent{
+			Timestamp: op.Timestamp,
+			Kind:      "db",
+			Summary:   fmt.Sprintf("%s %s (%s)", op.Type, op.SQL, op.Duration),
+			Data:      op,
+		})
+	}
+
+	for _, execution := range executions {
+		events = append(events, TimelineEvent{
+			Timestamp: execution.Timestamp,
+			Kind:      "execution",
+			Summary:   fmt.Sprintf("Execution #%d stored (session %s)", execution.ID, execution.SessionID),
+			Data:      execution,
+		})
+	}
+
+	// Execution timestamps come from SQLite with second precision, so only the
+	// steps between the request and its response are ordered by time
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	head := []TimelineEvent{{
+		Timestamp: request.StartTime,
+		Kind:      "request",
+		Summary:   request.Method + " " + request.URL,
+	}}
+	if request.Handler != "" {
+		head = append(head, TimelineEvent{
+			Timestamp: request.StartTime,
+			Kind:      "handler",
+			Summary:   "Matched " + request.Handler,
+		})
+	}
+	events = append(head, events...)
+
+	if !request.EndTime.IsZero() {
+		summary := fmt.Sprintf("%d in %s", request.Status, request.Duration)
+		if request.Error != "" {
+			summary += " - " + request.Error
+		}
+		events = append(events, TimelineEvent{
+			Timestamp: request.EndTime,
+			Kind:      "response",
+			Summary:   summary,
+			Data:      request.Response,
+		})
+	}
+
+	return events
+}
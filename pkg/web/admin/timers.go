@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// TimersHandler lists and cancels the setTimeout/setInterval timers of the engine
+type TimersHandler struct {
+	jsEngine *engine.Engine
+}
+
+// NewTimersHandler creates a new timers handler
+func NewTimersHandler(jsEngine *engine.Engine) *TimersHandler {
+	return &TimersHandler{
+		jsEngine: jsEngine,
+	}
+}
+
+// HandleTimers returns the active timers on GET and cancels the timer ?id= on DELETE
+func (th *TimersHandler) HandleTimers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "DELETE":
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid timer id", http.StatusBadRequest)
+			return
+		}
+		if !th.jsEngine.CancelTimer(id) {
+			http.Error(w, "Timer not found", http.StatusNotFound)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(th.jsEngine.Timers()); err != nil {
+		log.Error().Err(err).Msg("Failed to encode timers")
+	}
+}
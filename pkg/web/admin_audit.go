@@ -0,0 +1,125 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/gorilla/mux"
+)
+
+// maxAuditPayload bounds how much of a request body is read for the audit log summary
+const maxAuditPayload = 4096
+
+// adminAuditAction names the admin changes made with a method on a path. Paths ending in a
+// slash match everything below them, an empty method matches every mutating method, and an
+// empty action marks a request that changes nothing.
+type adminAuditAction struct {
+	method string
+	path   string
+	action string
+}
+
+// adminAuditActions are checked in order; mutating requests matching none are recorded
+// with their method and path as action
+var adminAuditActions = []adminAuditAction{
+	{http.MethodPost, "/admin/scripts", ""}, // Lists executions with form parameters
+	{http.MethodPost, "/admin/logs/api/clear", "logs.clear"},
+	{http.MethodPost, "/admin/logs/api/executions/delete", "executions.delete"},
+	{http.MethodDelete, "/admin/logs/api/executions/", "executions.delete"},
+	{http.MethodDelete, "/admin/logs/api/sessions/", "executions.delete"},
+	{http.MethodPost, "/admin/logs/api/filters", "filter.save"},
+	{http.MethodDelete, "/admin/logs/api/filters/", "filter.delete"},
+	{http.MethodPost, "/admin/globalstate", "globalstate.set"},
+	{http.MethodPost, "/admin/maintenance", "maintenance.set"},
+	{http.MethodPost, "/admin/readonly", "readonly.set"},
+	{http.MethodDelete, "/admin/timers", "timer.cancel"},
+	{http.MethodDelete, "/admin/mirror", "mirror.clear"},
+	{http.MethodPost, "/admin/bootstrap/rollback", "bootstrap.rollback"},
+	{http.MethodPost, "/admin/reset", "vm.reset"},
+	{http.MethodPost, "/api/reset-vm", "vm.reset"},
+	{http.MethodPut, "/admin/scripts/files/", "script.upload"},
+	{http.MethodDelete, "/admin/scripts/files/", "script.delete"},
+	{http.MethodPost, "/admin/notebooks/api", "notebook.create"},
+	{http.MethodPut, "/admin/notebooks/api/", "notebook.save"},
+	{http.MethodDelete, "/admin/notebooks/api/", "notebook.delete"},
+	{http.MethodPost, "/admin/notebooks/api/", "notebook.run"}, // Runs cells or restarts the session
+	{http.MethodPut, "/admin/flags/api/", "flag.save"},
+	{http.MethodDelete, "/admin/flags/api/", "flag.delete"},
+	{http.MethodPut, "/admin/cassettes/", "cassette.import"},
+	{http.MethodDelete, "/admin/cassettes/", "cassette.delete"},
+	{http.MethodDelete, "/admin/conversations/api/", "conversation.delete"},
+	{http.MethodPost, "/v1/execute", "execute"},
+	{http.MethodDelete, "/v1/execute", "session.close"},
+	{http.MethodPost, "/api/repl/execute", "repl.execute"},
+	{http.MethodDelete, "/api/repl/execute", "session.close"},
+	{http.MethodPost, "/api/playground/execute", "execute"},
+	{http.MethodDelete, "/api/playground/execute", "session.close"},
+}
+
+// adminAuditActionFor returns the audit action of a request, "" if it changes nothing
+func adminAuditActionFor(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ""
+	}
+	for _, a := range adminAuditActions {
+		if a.method != "" && a.method != r.Method {
+			continue
+		}
+		if r.URL.Path == a.path || (strings.HasSuffix(a.path, "/") && strings.HasPrefix(r.URL.Path, a.path)) {
+			return a.action
+		}
+	}
+	return strings.ToLower(r.Method) + " " + r.URL.Path
+}
+
+// setupAdminAudit records the changes made through the admin server in the audit log of
+// the engine, with the start of their payload and the status they were answered with
+func setupAdminAudit(r *mux.Router, jsEngine *engine.Engine) {
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			action := adminAuditActionFor(r)
+			if action == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var payload []byte
+			if r.Body != nil {
+				payload, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditPayload))
+				r.Body = readCloser{io.MultiReader(bytes.NewReader(payload), r.Body), r.Body}
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			jsEngine.RecordAdminAction(r, action, payload, sw.status)
+		})
+	})
+}
+
+// readCloser reads a replayed body and closes the original one
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// statusWriter remembers the status a request was answered with
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Flush implements http.Flusher so streaming handlers keep working behind the audit log
+func (sw *statusWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
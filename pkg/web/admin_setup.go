@@ -25,6 +25,67 @@ func SetupAdminRoutes(r *mux.Router, jsEngine *engine.Engine) {
 	r.HandleFunc("/admin/globalstate", adminHandler.HandleGlobalState).Methods("GET", "POST")
 	log.Debug().Msg("Registered admin endpoint: GET/POST /admin/globalstate")
 
+	// Maintenance mode (pauses the JavaScript routes)
+	r.HandleFunc("/admin/maintenance", adminHandler.HandleMaintenance).Methods("GET", "POST")
+	log.Debug().Msg("Registered admin endpoint: GET/POST /admin/maintenance")
+
+	// Read-only mode (refuses code execution and route changes)
+	r.HandleFunc("/admin/readonly", adminHandler.HandleReadOnly).Methods("GET", "POST")
+	log.Debug().Msg("Registered admin endpoint: GET/POST /admin/readonly")
+
+	// Timers set with setTimeout/setInterval
+	r.HandleFunc("/admin/timers", adminHandler.HandleTimers).Methods("GET", "DELETE")
+	log.Debug().Msg("Registered admin endpoint: GET/DELETE /admin/timers")
+
+	// Cron expression validation and the next run times
+	r.HandleFunc("/admin/cron", adminHandler.HandleCron).Methods("GET")
+	log.Debug().Msg("Registered admin endpoint: GET /admin/cron")
+
+	// How the mirrors of routes answered, see the mirror route option
+	r.HandleFunc("/admin/mirror", adminHandler.HandleMirror).Methods("GET", "DELETE")
+	log.Debug().Msg("Registered admin endpoint: GET/DELETE /admin/mirror")
+
+	// Bootstrap file backups and rollback
+	r.HandleFunc("/admin/bootstrap", adminHandler.HandleBootstrap).Methods("GET")
+	r.HandleFunc("/admin/bootstrap/rollback", adminHandler.HandleBootstrapRollback).Methods("POST")
+	log.Debug().Msg("Registered admin endpoints: GET /admin/bootstrap, POST /admin/bootstrap/rollback")
+
+	// Fresh runtimes running the bootstrap file and scripts again
+	r.HandleFunc("/admin/reset", adminHandler.HandleReset).Methods("POST")
+	log.Debug().Msg("Registered admin endpoint: POST /admin/reset")
+
+	// Upload and download of the scripts directory, for editors and deployment pipelines
+	r.PathPrefix("/admin/scripts/files/").HandlerFunc(adminHandler.HandleScriptFiles)
+	log.Debug().Msg("Registered admin endpoint: /admin/scripts/files/")
+
+	// Collaborative editor of the scripts directory
+	r.PathPrefix("/admin/scripts/edit").HandlerFunc(adminHandler.HandleScriptEditor)
+	log.Debug().Msg("Registered admin endpoint: /admin/scripts/edit")
+
+	// Notebooks: cells run in order in one session runtime
+	r.PathPrefix("/admin/notebooks").HandlerFunc(adminHandler.HandleNotebooks)
+	log.Debug().Msg("Registered admin endpoint: /admin/notebooks")
+
+	// Feature flags of flags.isEnabled(), toggled at runtime
+	r.PathPrefix("/admin/flags").HandlerFunc(adminHandler.HandleFlags)
+	log.Debug().Msg("Registered admin endpoint: /admin/flags")
+
+	// Recorded responses of outbound requests, downloaded and imported as fixtures
+	r.PathPrefix("/admin/cassettes").HandlerFunc(adminHandler.HandleCassettes)
+	log.Debug().Msg("Registered admin endpoint: /admin/cassettes")
+
+	// AI conversations recorded with conversations.*, replayed and continued in the playground
+	r.PathPrefix("/admin/conversations").HandlerFunc(adminHandler.HandleConversations)
+	log.Debug().Msg("Registered admin endpoint: /admin/conversations")
+
+	// Which scripts registered which routes and which routes use which tables
+	r.PathPrefix("/admin/graph").HandlerFunc(adminHandler.HandleGraph)
+	log.Debug().Msg("Registered admin endpoint: /admin/graph")
+
+	// Changes made through the admin server, recorded by the audit log
+	r.PathPrefix("/admin/audit").HandlerFunc(adminHandler.HandleAudit)
+	log.Debug().Msg("Registered admin endpoint: /admin/audit")
+
 	// Admin static files (CSS, JS) - serve under /static/admin/
 	r.PathPrefix("/static/admin/").HandlerFunc(adminHandler.HandleStaticFiles)
 	log.Debug().Msg("Registered admin static files: /static/admin/")
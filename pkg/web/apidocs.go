@@ -0,0 +1,132 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+const apiDocsTitle = "JavaScript API"
+
+// APIDocsPath and OpenAPIPath are served by the JavaScript web server next to the dynamic routes
+const (
+	APIDocsPath = "/_docs"
+	OpenAPIPath = "/_docs/openapi.json"
+)
+
+var apiDocsTemplate = template.Must(template.New("apidocs").Funcs(template.FuncMap{
+	"sortedCodes": func(responses map[string]string) []string {
+		codes := make([]string, 0, len(responses))
+		for code := range responses {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		return codes
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.1.3/dist/css/bootstrap.min.css" rel="stylesheet">
+    <style>
+        .method { display: inline-block; min-width: 70px; text-align: center; }
+        .method-GET { background-color: #0d6efd; }
+        .method-POST { background-color: #198754; }
+        .method-PUT { background-color: #fd7e14; }
+        .method-PATCH { background-color: #6f42c1; }
+        .method-DELETE { background-color: #dc3545; }
+        .route-path { font-family: monospace; font-size: 1.05rem; }
+    </style>
+</head>
+<body>
+<div class="container my-4">
+    <div class="d-flex justify-content-between align-items-center mb-4">
+        <h1 class="h3 mb-0">{{.Title}}</h1>
+        <a class="btn btn-outline-secondary btn-sm" href="{{.OpenAPIPath}}">OpenAPI JSON</a>
+    </div>
+    {{if not .Routes}}
+    <div class="alert alert-info">No routes are registered yet.</div>
+    {{end}}
+    {{range .Routes}}
+    <div class="card mb-3">
+        <div class="card-header">
+            <span class="badge method method-{{.Method}}">{{.Method}}</span>
+            <span class="route-path ms-2">{{.Path}}</span>
+            {{if .Summary}}<span class="text-muted ms-2">{{.Summary}}</span>{{end}}
+            {{range .Tags}}<span class="badge bg-light text-dark ms-1">{{.}}</span>{{end}}
+        </div>
+        {{if or .Description .Params .Responses}}
+        <div class="card-body">
+            {{if .Description}}<p>{{.Description}}</p>{{end}}
+            {{if .Params}}
+            <h6>Parameters</h6>
+            <table class="table table-sm">
+                <thead><tr><th>Name</th><th>In</th><th>Type</th><th>Required</th><th>Description</th></tr></thead>
+                <tbody>
+                {{range .Params}}
+                <tr>
+                    <td><code>{{.Name}}</code></td>
+                    <td>{{.In}}</td>
+                    <td>{{if .Type}}{{.Type}}{{else}}string{{end}}</td>
+                    <td>{{if .Required}}yes{{else}}no{{end}}</td>
+                    <td>{{.Description}}</td>
+                </tr>
+                {{end}}
+                </tbody>
+            </table>
+            {{end}}
+            {{if .Responses}}
+            <h6>Responses</h6>
+            <table class="table table-sm mb-0">
+                <thead><tr><th>Status</th><th>Description</th></tr></thead>
+                <tbody>
+                {{$responses := .Responses}}
+                {{range sortedCodes $responses}}
+                <tr><td><code>{{.}}</code></td><td>{{index $responses .}}</td></tr>
+                {{end}}
+                </tbody>
+            </table>
+            {{end}}
+        </div>
+        {{end}}
+    </div>
+    {{end}}
+</div>
+</body>
+</html>`))
+
+// APIDocsHandler renders the documentation of the routes registered by JavaScript
+func APIDocsHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := struct {
+			Title       string
+			OpenAPIPath string
+			Routes      []engine.RouteDoc
+		}{
+			Title:       apiDocsTitle,
+			OpenAPIPath: OpenAPIPath,
+			Routes:      jsEngine.GetRouteDocs(),
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := apiDocsTemplate.Execute(w, data); err != nil {
+			log.Error().Err(err).Msg("Failed to render API docs")
+		}
+	}
+}
+
+// OpenAPIHandler serves an OpenAPI document generated from the registered routes
+func OpenAPIHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(jsEngine.GenerateOpenAPI(apiDocsTitle, "1.0.0")); err != nil {
+			log.Error().Err(err).Msg("Failed to encode OpenAPI document")
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// defaultRobotsTxt keeps crawlers away from playground apps; a route for /robots.txt replaces it
+const defaultRobotsTxt = "User-agent: *\nDisallow: /\n"
+
+// browserDefaultMaxAge is how long browsers may cache the default answers, in seconds
+const browserDefaultMaxAge = 24 * 60 * 60
+
+// serveBrowserDefault answers the files browsers and crawlers ask every site for, when no
+// route, file handler or static directory provides them, instead of a 404 for each page
+// view. It reports whether it answered.
+func serveBrowserDefault(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	switch r.URL.Path {
+	case "/favicon.ico":
+		// No icon: browsers show their default one and do not ask again for a day
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(browserDefaultMaxAge))
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	case "/robots.txt":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(browserDefaultMaxAge))
+		w.Header().Set("Content-Length", strconv.Itoa(len(defaultRobotsTxt)))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(defaultRobotsTxt))
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package web
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// minCompressBytes is the response size from which compression pays off
+const minCompressBytes = 1024
+
+// compressResponse wraps the writer of a route's response in a compressWriter if the route's
+// compress option, else the server default, enables compression and the client accepts gzip.
+// finish must be called once the handler is done.
+func compressResponse(jsEngine *engine.Engine, handler *engine.HandlerInfo, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	enabled := jsEngine.ResponseCompression()
+	if handler.Middleware != nil && handler.Middleware.Compress != nil {
+		enabled = *handler.Middleware.Compress
+	}
+	if !enabled || r.Method == http.MethodHead {
+		return w, func() {}
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		return w, func() {}
+	}
+	cw := &compressWriter{ResponseWriter: w, header: make(http.Header), status: http.StatusOK}
+	return cw, func() {
+		if err := cw.Close(); err != nil {
+			log.Debug().Err(err).Str("path", r.URL.Path).Msg("Failed to finish compressed response")
+		}
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of r allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding = strings.TrimSpace(coding); coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if value, err := strconv.ParseFloat(q, 64); err == nil && value == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressibleType reports whether a content type is worth compressing
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml", "application/x-ndjson":
+		return true
+	}
+	return false
+}
+
+// compressWriter gzips a response once it is known to be large enough and of a compressible
+// type. It holds back the status and the first bytes until then. Handlers and the route
+// cache see their own header map, without the Content-Encoding compression adds.
+type compressWriter struct {
+	http.ResponseWriter
+	header  http.Header
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (cw *compressWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		if cw.gz != nil {
+			return cw.gz.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= minCompressBytes {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide sends the held back status and bytes, compressed if the response qualifies
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	h := cw.ResponseWriter.Header()
+	for name, values := range cw.header {
+		if name == "Vary" {
+			h[name] = append(h[name], values...)
+		} else {
+			h[name] = values
+		}
+	}
+
+	compress := large &&
+		cw.status == http.StatusOK &&
+		h.Get("Content-Encoding") == "" &&
+		h.Get("Content-Range") == "" &&
+		compressibleType(h.Get("Content-Type"))
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		cw.ResponseWriter.WriteHeader(cw.status)
+		cw.gz = gzip.NewWriter(cw.ResponseWriter)
+		_, err := cw.gz.Write(cw.buf)
+		cw.buf = nil
+		return err
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	_, err := cw.ResponseWriter.Write(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+// Flush sends what was written so far; streamed responses are compressed as they go
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		_ = cw.decide(true)
+	}
+	if cw.gz != nil {
+		_ = cw.gz.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the response; small responses are sent as they are
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		return cw.decide(false)
+	}
+	if cw.gz != nil {
+		return cw.gz.Close()
+	}
+	return nil
+}
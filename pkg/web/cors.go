@@ -0,0 +1,64 @@
+package web
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/gorilla/mux"
+)
+
+// servePreflight answers a CORS preflight request for the route its method names, if the
+// route has a CORS policy, and reports whether it did
+func servePreflight(jsEngine *engine.Engine, w http.ResponseWriter, r *http.Request) bool {
+	if !engine.IsPreflight(r) {
+		return false
+	}
+	handler, exists := jsEngine.GetHandler(r.Header.Get("Access-Control-Request-Method"), r.URL.Path)
+	if !exists {
+		return false
+	}
+	policy := jsEngine.CORSPolicy(handler)
+	if policy == nil {
+		return false
+	}
+	policy.Preflight(w, r)
+	return true
+}
+
+// setupAdminCORS applies the admin CORS policy of the engine to the admin server: allowed
+// origins get CORS headers and their preflights answered, other cross-origin requests are
+// refused with 403, so other sites cannot post code to /v1/execute. Without a policy the
+// admin server sends no CORS headers and refuses nothing.
+func setupAdminCORS(r *mux.Router, jsEngine *engine.Engine) {
+	policy := jsEngine.AdminCORS()
+	if policy == nil {
+		return
+	}
+
+	r.Methods(http.MethodOptions).MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		return engine.IsPreflight(r)
+	}).HandlerFunc(policy.Preflight)
+
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || sameOrigin(origin, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !policy.Apply(w.Header(), r) {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// sameOrigin reports whether origin names the host a request was sent to
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
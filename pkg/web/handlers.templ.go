@@ -3,6 +3,8 @@ package web
 import (
 	"context"
 	"embed"
+	"encoding/json"
+	"errors"
 	"io"
 	"mime"
 	"net/http"
@@ -14,6 +16,7 @@ import (
 	"github.com/go-go-golems/jesus/pkg/api"
 	"github.com/go-go-golems/jesus/pkg/engine"
 	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/web/admin"
 	"github.com/go-go-golems/jesus/pkg/web/templates"
 	"github.com/rs/zerolog/log"
 )
@@ -54,9 +57,7 @@ func REPLHandler() http.HandlerFunc {
 func HistoryHandler(jsEngine *engine.Engine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Parse query parameters
-		search := r.URL.Query().Get("search")
-		sessionID := r.URL.Query().Get("sessionId")
-		source := r.URL.Query().Get("source")
+		filter := admin.ExecutionFilterFromQuery(r.URL.Query())
 
 		limit := 20
 		if l := r.URL.Query().Get("limit"); l != "" {
@@ -72,13 +73,6 @@ func HistoryHandler(jsEngine *engine.Engine) http.HandlerFunc {
 			}
 		}
 
-		// Build filter
-		filter := repository.ExecutionFilter{
-			Search:    search,
-			SessionID: sessionID,
-			Source:    source,
-		}
-
 		pagination := repository.PaginationOptions{
 			Limit:  limit,
 			Offset: offset,
@@ -86,6 +80,10 @@ func HistoryHandler(jsEngine *engine.Engine) http.HandlerFunc {
 
 		// Query executions
 		result, err := jsEngine.GetRepositoryManager().Executions().ListExecutions(r.Context(), filter, pagination)
+		if errors.Is(err, repository.ErrInvalidFilter) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to get execution history")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -233,37 +231,35 @@ func HomeHandler() http.HandlerFunc {
 
 // ExecuteREPLHandler handles REPL execution (non-persistent)
 func ExecuteREPLHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	replHandler := api.ExecuteREPLHandler(jsEngine)
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
+		if r.Method != http.MethodPost && r.Method != http.MethodDelete {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-
-		// This would execute JavaScript without storing to database
-		// For now, we'll reuse the existing execute endpoint
-		// In the future, we could add a separate REPL execution path
-
-		// For now, redirect to the main execute endpoint
-		// but we could implement a separate non-persistent execution here
-		api.ExecuteHandler(jsEngine)(w, r)
+		replHandler(w, r)
 	}
 }
 
-// ResetVMHandler resets the JavaScript VM state
-func ResetVMHandler(jsEngine *engine.Engine) http.HandlerFunc {
+// EnvironmentHandler returns the name of the engine environment for admin and playground banners
+func EnvironmentHandler(jsEngine *engine.Engine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		// This would reset the VM state
-		// For now, we'll just return success
-		// In the future, we could implement actual VM reset
+		env := jsEngine.GetEnvironment()
 
+		// The config stays in the engine: it can hold credentials, and the route is served
+		// to every caller of the playground
 		w.Header().Set("Content-Type", "application/json")
-		if _, err := w.Write([]byte(`{"success": true, "message": "VM reset (not implemented)"}`)); err != nil {
-			log.Error().Err(err).Msg("Failed to write response")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":       env.Name,
+			"production": env.IsProduction(),
+		}); err != nil {
+			log.Error().Err(err).Msg("Failed to encode environment response")
 		}
 	}
 }
+
+// ResetVMHandler resets the JavaScript VM: fresh runtimes, then the bootstrap file and scripts
+// run again, see engine.Reset
+func ResetVMHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return admin.NewResetHandler(jsEngine).HandleReset
+}
@@ -0,0 +1,54 @@
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+var maintenancePage = template.Must(template.New("maintenance").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Under maintenance</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; background: #f8f9fa; color: #212529; display: flex; align-items: center; justify-content: center; min-height: 100vh; margin: 0; }
+        .box { max-width: 32rem; padding: 2rem; background: #fff; border: 1px solid #dee2e6; border-radius: 8px; text-align: center; }
+        h1 { font-size: 1.5rem; margin-top: 0; }
+        p { color: #6c757d; }
+    </style>
+</head>
+<body>
+    <div class="box">
+        <h1>Under maintenance</h1>
+        <p>{{if .Message}}{{.Message}}{{else}}This service is temporarily unavailable. Please try again later.{{end}}</p>
+    </div>
+</body>
+</html>
+`))
+
+// serveMaintenancePage answers a JavaScript route request with 503 while maintenance mode is on
+func serveMaintenancePage(w http.ResponseWriter, r *http.Request, status engine.MaintenanceStatus) {
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Service under maintenance",
+			"message": status.Message,
+		}); err != nil {
+			log.Error().Err(err).Msg("Failed to encode maintenance response")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := maintenancePage.Execute(w, status); err != nil {
+		log.Error().Err(err).Msg("Failed to render maintenance page")
+	}
+}
@@ -0,0 +1,21 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// MetricsPath is served by the admin server for Prometheus scrapes
+const MetricsPath = "/metrics"
+
+// MetricsHandler serves the server and script metrics in the Prometheus text format
+func MetricsHandler(jsEngine *engine.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := jsEngine.WriteMetrics(w); err != nil {
+			log.Error().Err(err).Msg("Failed to write metrics")
+		}
+	}
+}
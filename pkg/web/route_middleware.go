@@ -0,0 +1,160 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/rs/zerolog/log"
+)
+
+// serveRoute applies the auth, rateLimit, bodyLimit and cache options of a route, or the limit
+// of app.use(rateLimit()), before serve submits the request to the dispatcher, and mirrors the
+// request once it is answered
+func serveRoute(jsEngine *engine.Engine, m *engine.RouteMiddleware, w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	if m.IsEmpty() && jsEngine.RouteRateLimit(m) == nil {
+		serve(w, r)
+		return
+	}
+	if m == nil {
+		// Routes without options still get the limit of app.use(rateLimit())
+		m = &engine.RouteMiddleware{}
+	}
+
+	apiKey := engine.RequestAPIKey(r)
+	if m.Auth == engine.RouteAuthAPIKey && !jsEngine.ValidAPIKey(apiKey) {
+		if !jsEngine.HasAPIKeys() {
+			log.Warn().Str("path", r.URL.Path).Msg("Route requires an API key but none is configured")
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="jesus"`)
+		writeRouteError(w, http.StatusUnauthorized, "A valid API key is required")
+		return
+	}
+
+	if limiter := jsEngine.RouteRateLimit(m); limiter != nil {
+		if ok, retryAfter := limiter.Allow(jsEngine.ClientKey(limiter, r), time.Now()); !ok {
+			w.Header().Set("Retry-After", engine.RetryAfterHeader(retryAfter))
+			writeRouteError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+	}
+
+	if m.BodyLimit > 0 && r.Body != nil {
+		if r.ContentLength > m.BodyLimit {
+			writeRouteError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", m.BodyLimit))
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, m.BodyLimit+1))
+		if err != nil {
+			writeRouteError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		if int64(len(body)) > m.BodyLimit {
+			writeRouteError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds %d bytes", m.BodyLimit))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if mirror := m.Mirror; mirror != nil && mirror.Sampled() {
+		if body, ok := mirrorBody(r); ok {
+			capture := &cachingWriter{ResponseWriter: w, status: http.StatusOK}
+			serveCached(m, capture, r, serve)
+			if !capture.flushed && capture.body.Len() <= engine.MaxMirrorBodyBytes {
+				go jsEngine.MirrorRequest(mirror, r.Clone(context.Background()), body, capture.status, capture.body.Bytes())
+			}
+			return
+		}
+	}
+
+	serveCached(m, w, r, serve)
+}
+
+// serveCached serves GET requests of a route with a cache from memory where possible
+func serveCached(m *engine.RouteMiddleware, w http.ResponseWriter, r *http.Request, serve func(http.ResponseWriter, *http.Request)) {
+	if cache := m.Cache; cache != nil && r.Method == http.MethodGet {
+		if cached, ok := cache.Get(r, time.Now()); ok {
+			for name, values := range cached.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("X-Cache", "HIT")
+			w.WriteHeader(cached.Status)
+			_, _ = w.Write(cached.Body)
+			return
+		}
+
+		w.Header().Set("X-Cache", "MISS")
+		recorder := &cachingWriter{ResponseWriter: w, status: http.StatusOK}
+		serve(recorder, r)
+		if recorder.status == http.StatusOK && !recorder.flushed {
+			header := w.Header().Clone()
+			header.Del("X-Cache")
+			header.Del("Set-Cookie")
+			for name := range header {
+				// CORS headers answer the origin of this request, Apply sets them for each one
+				if strings.HasPrefix(name, "Access-Control-") {
+					header.Del(name)
+				}
+			}
+			cache.Put(r, engine.CachedResponse{Status: recorder.status, Header: header, Body: recorder.body.Bytes()}, time.Now())
+		}
+		return
+	}
+
+	serve(w, r)
+}
+
+// mirrorBody reads the body of a request to mirror and puts it back for the route. Requests
+// with larger bodies are not mirrored.
+func mirrorBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, engine.MaxMirrorBodyBytes+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil || len(body) > engine.MaxMirrorBodyBytes {
+		return nil, false
+	}
+	return body, true
+}
+
+// writeRouteError answers a request rejected by a route middleware
+func writeRouteError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"error": message}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode route error response")
+	}
+}
+
+// cachingWriter keeps a copy of the response for the route cache and the mirror
+type cachingWriter struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	flushed bool // Streamed responses are not cached
+}
+
+func (cw *cachingWriter) WriteHeader(status int) {
+	cw.status = status
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *cachingWriter) Write(b []byte) (int, error) {
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher so streaming handlers keep working behind the cache
+func (cw *cachingWriter) Flush() {
+	cw.flushed = true
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
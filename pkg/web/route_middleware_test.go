@@ -0,0 +1,86 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-go-golems/jesus/pkg/engine"
+)
+
+// cachedRoute returns the middleware of a GET route with a cache and a handler answering
+// with the Authorization header of the request, counting its calls
+func cachedRoute(t *testing.T, header http.Header) (*engine.RouteMiddleware, func(http.ResponseWriter, *http.Request), *int) {
+	t.Helper()
+	m := &engine.RouteMiddleware{Cache: engine.NewRouteCache(time.Minute)}
+	calls := 0
+	serve := func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		_, _ = w.Write([]byte(r.Header.Get("Authorization")))
+	}
+	return m, serve, &calls
+}
+
+func get(m *engine.RouteMiddleware, serve func(http.ResponseWriter, *http.Request), header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/me?x=1", nil)
+	r.Header = header
+	w := httptest.NewRecorder()
+	serveCached(m, w, r, serve)
+	return w
+}
+
+func TestServeCachedKeepsClientsApart(t *testing.T) {
+	m, serve, calls := cachedRoute(t, nil)
+
+	for _, token := range []string{"Bearer alice", "Bearer bob", "Bearer alice"} {
+		w := get(m, serve, http.Header{"Authorization": {token}})
+		if got := w.Body.String(); got != token {
+			t.Errorf("request with %q got the response %q", token, got)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("handler ran %d times, want 2", *calls)
+	}
+
+	for _, cookie := range []string{"session=a", "session=b"} {
+		get(m, serve, http.Header{"Cookie": {cookie}})
+	}
+	if *calls != 4 {
+		t.Errorf("handler ran %d times with two cookies, want 4", *calls)
+	}
+}
+
+func TestServeCachedHonoursCacheControlAndVary(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		calls  int // Handler calls for two requests differing in Accept-Language
+	}{
+		{"cacheable", http.Header{}, 1},
+		{"private", http.Header{"Cache-Control": {"private, max-age=60"}}, 2},
+		{"no-store", http.Header{"Cache-Control": {"no-store"}}, 2},
+		{"vary star", http.Header{"Vary": {"*"}}, 2},
+		{"vary on the differing header", http.Header{"Vary": {"Accept-Encoding, accept-language"}}, 2},
+		{"vary on another header", http.Header{"Vary": {"Accept-Encoding"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, serve, calls := cachedRoute(t, tt.header)
+			first := get(m, serve, http.Header{"Accept-Language": {"en"}})
+			second := get(m, serve, http.Header{"Accept-Language": {"de"}})
+			if *calls != tt.calls {
+				t.Errorf("handler ran %d times, want %d", *calls, tt.calls)
+			}
+			if first.Header().Get("X-Cache") != "MISS" {
+				t.Errorf("first request: X-Cache = %q, want MISS", first.Header().Get("X-Cache"))
+			}
+			if want := map[int]string{1: "HIT", 2: "MISS"}[tt.calls]; second.Header().Get("X-Cache") != want {
+				t.Errorf("second request: X-Cache = %q, want %s", second.Header().Get("X-Cache"), want)
+			}
+		})
+	}
+}
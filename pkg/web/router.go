@@ -2,6 +2,7 @@ package web
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/go-go-golems/jesus/pkg/engine"
 )
@@ -11,23 +12,63 @@ func HandleDynamicRoute(jsEngine *engine.Engine, w http.ResponseWriter, r *http.
 	path := r.URL.Path
 	method := r.Method
 
+	// Maintenance mode pauses every JavaScript route; the admin server stays available
+	if status := jsEngine.Maintenance(); status.Enabled {
+		serveMaintenancePage(w, r, status)
+		return
+	}
+
+	// WebSocket upgrades go to app.ws routes
+	if engine.IsWebSocketRequest(r) {
+		if handler, exists := jsEngine.GetHandler(engine.WebSocketMethod, path); exists {
+			serveRoute(jsEngine, handler.Middleware, w, r, func(w http.ResponseWriter, r *http.Request) {
+				jsEngine.ServeWebSocket(handler, w, r)
+			})
+			return
+		}
+	}
+
+	// Preflights of routes with a cors() policy are answered without running JavaScript
+	if servePreflight(jsEngine, w, r) {
+		return
+	}
+
 	// Check for registered HTTP handler
 	if handler, exists := jsEngine.GetHandler(method, path); exists {
-		done := make(chan error, 1)
-		job := engine.EvalJob{
-			Handler: handler,
-			W:       w,
-			R:       r,
-			Done:    done,
+		if policy := jsEngine.CORSPolicy(handler); policy != nil {
+			policy.Apply(w.Header(), r)
 		}
+		w, finish := compressResponse(jsEngine, handler, w, r)
+		defer finish()
+		// The auth, cache, rateLimit and bodyLimit options of the route run before the handler
+		serveRoute(jsEngine, handler.Middleware, w, r, func(w http.ResponseWriter, r *http.Request) {
+			done := make(chan error, 1)
+			job := engine.EvalJob{
+				Handler: handler,
+				W:       w,
+				R:       r,
+				Done:    done,
+			}
 
-		jsEngine.SubmitJob(job)
+			jsEngine.SubmitJob(job)
 
-		// Wait for completion
-		<-done
+			// Wait for completion
+			<-done
+		})
 		return
 	}
 
+	// OPTIONS requests no route answers list the methods of the routes matching the path
+	if method == http.MethodOptions {
+		if allowed := jsEngine.AllowedMethods(path); len(allowed) > 0 {
+			allow := strings.Join(allowed, ",")
+			w.Header().Set("Allow", allow)
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			_, _ = w.Write([]byte(allow))
+			return
+		}
+	}
+
 	// Check for registered file handler
 	if fileHandler, exists := jsEngine.GetFileHandler(path); exists {
 		done := make(chan error, 1)
@@ -45,6 +86,16 @@ func HandleDynamicRoute(jsEngine *engine.Engine, w http.ResponseWriter, r *http.
 		return
 	}
 
+	// Check the directories mounted with app.static()
+	if jsEngine.ServeStatic(w, r) {
+		return
+	}
+
+	// /favicon.ico and /robots.txt get defaults unless a script provides them
+	if serveBrowserDefault(w, r) {
+		return
+	}
+
 	// No handler found
 	http.NotFound(w, r)
 }
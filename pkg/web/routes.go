@@ -2,8 +2,11 @@ package web
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/go-go-golems/jesus/pkg/api"
 	"github.com/go-go-golems/jesus/pkg/engine"
+	"github.com/go-go-golems/jesus/pkg/repository"
 	"github.com/gorilla/mux"
 )
 
@@ -11,6 +14,10 @@ import (
 func SetupJSRoutes(jsEngine *engine.Engine) *mux.Router {
 	r := mux.NewRouter()
 
+	// Generated documentation of the JavaScript routes
+	r.HandleFunc(APIDocsPath, APIDocsHandler(jsEngine)).Methods("GET")
+	r.HandleFunc(OpenAPIPath, OpenAPIHandler(jsEngine)).Methods("GET")
+
 	// Dynamic routes (registered by JavaScript) - catch all for JS server
 	r.PathPrefix("/").HandlerFunc(DynamicRouteHandler(jsEngine))
 
@@ -21,14 +28,23 @@ func SetupJSRoutes(jsEngine *engine.Engine) *mux.Router {
 func SetupAdminServerRoutes(jsEngine *engine.Engine) *mux.Router {
 	r := mux.NewRouter()
 
+	// Cross-origin access from external dashboards, see --admin-cors-origins
+	setupAdminCORS(r, jsEngine)
+
+	// Changes made through the admin server go to the audit log
+	setupAdminAudit(r, jsEngine)
+
 	// Static files - highest priority
 	r.PathPrefix("/static/").Handler(StaticHandler())
 
 	// API endpoints - these need to be registered early
-	r.HandleFunc("/api/repl/execute", ExecuteREPLHandler(jsEngine)).Methods("POST")
+	r.HandleFunc("/api/repl/execute", ExecuteREPLHandler(jsEngine)).Methods("POST", "DELETE")
 	r.HandleFunc("/api/reset-vm", ResetVMHandler(jsEngine)).Methods("POST")
 	r.HandleFunc("/api/preset", PresetHandler()).Methods("GET")
 	r.HandleFunc("/api/docs", DocsAPIHandler()).Methods("GET")
+	r.HandleFunc("/api/env", EnvironmentHandler(jsEngine)).Methods("GET")
+	r.HandleFunc(MetricsPath, MetricsHandler(jsEngine)).Methods("GET")
+	r.HandleFunc("/v1/events", api.EventsHandler(jsEngine)).Methods("GET")
 
 	// Main application pages
 	r.HandleFunc("/", PlaygroundHandler()).Methods("GET") // Default to playground
@@ -56,11 +72,32 @@ func SetupRoutesWithAPI(jsEngine *engine.Engine, executeHandler http.HandlerFunc
 	r := SetupAdminServerRoutes(jsEngine)
 
 	// Add the execute API handler
-	r.HandleFunc("/v1/execute", executeHandler).Methods("POST")
+	r.HandleFunc("/v1/execute", limitExecute(jsEngine, executeHandler)).Methods("POST", "DELETE")
+
+	// The playground editor runs code like /v1/execute, recorded with its own source so it
+	// can have its own policy
+	r.HandleFunc("/api/playground/execute", limitExecute(jsEngine, api.ExecuteHandlerWithSource(jsEngine, repository.SourcePlayground))).Methods("POST", "DELETE")
 
 	return r
 }
 
+// limitExecute applies the rate limit of the engine to /v1/execute, per API key when the
+// request has a valid one, else per client IP
+func limitExecute(jsEngine *engine.Engine, next http.HandlerFunc) http.HandlerFunc {
+	limiter := jsEngine.ExecuteRateLimit()
+	if limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := limiter.Allow(jsEngine.ClientKey(limiter, r), time.Now()); !ok {
+			w.Header().Set("Retry-After", engine.RetryAfterHeader(retryAfter))
+			writeRouteError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+			return
+		}
+		next(w, r)
+	}
+}
+
 // DynamicRouteHandler wraps the existing HandleDynamicRoute function
 func DynamicRouteHandler(jsEngine *engine.Engine) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
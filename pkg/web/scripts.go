@@ -2,12 +2,13 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/go-go-golems/jesus/pkg/engine"
 	"github.com/go-go-golems/jesus/pkg/repository"
+	"github.com/go-go-golems/jesus/pkg/web/admin"
 	"github.com/rs/zerolog/log"
 )
 
@@ -36,6 +37,7 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
     <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/prism/1.29.0/themes/prism.min.css">
     <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/prism/1.29.0/themes/prism-okaidia.min.css">
     <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/prism/1.29.0/plugins/line-numbers/prism-line-numbers.min.css">
+    <link rel="stylesheet" href="/static/admin/saved-filters.css">
     <style>
         .code-snippet {
             max-height: 150px;
@@ -195,9 +197,48 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
                                 <div>
                                     <button type="submit" class="btn btn-primary">Search</button>
                                     <button type="button" class="btn btn-secondary" onclick="clearForm()">Clear</button>
+                                    <button type="button" class="btn btn-outline-danger" onclick="deleteMatching()">Delete Matching</button>
                                 </div>
                             </div>
+                            <div class="col-md-2">
+                                <label for="source" class="form-label">Source</label>
+                                <select class="form-control" id="source" name="source">
+                                    <option value="">All Sources</option>
+                                    <option value="api">API</option>
+                                    <option value="mcp">MCP</option>
+                                    <option value="mcp-file">MCP File</option>
+                                    <option value="repl">REPL</option>
+                                    <option value="playground">Playground</option>
+                                    <option value="file">File</option>
+                                    <option value="scheduler">Scheduler</option>
+                                    <option value="webhook">Webhook</option>
+                                    <option value="replay">Replay</option>
+                                </select>
+                            </div>
+                            <div class="col-md-2">
+                                <label for="hasError" class="form-label">Status</label>
+                                <select class="form-control" id="hasError" name="hasError">
+                                    <option value="">Any</option>
+                                    <option value="true">With errors</option>
+                                    <option value="false">Without errors</option>
+                                </select>
+                            </div>
+                            <div class="col-md-2">
+                                <label for="since" class="form-label">Time Range</label>
+                                <select class="form-control" id="since" name="since">
+                                    <option value="">All time</option>
+                                    <option value="1h">Last hour</option>
+                                    <option value="24h">Last 24 hours</option>
+                                    <option value="168h">Last 7 days</option>
+                                </select>
+                            </div>
+                            <div class="col-md-2">
+                                <label for="actor" class="form-label">Actor</label>
+                                <input type="text" class="form-control" id="actor" name="actor"
+                                       placeholder="API client, MCP client">
+                            </div>
                         </form>
+                        <div id="savedFilters"></div>
                     </div>
                 </div>
 
@@ -221,6 +262,7 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
     <script src="https://cdnjs.cloudflare.com/ajax/libs/prism/1.29.0/components/prism-javascript.min.js"></script>
     <script src="https://cdnjs.cloudflare.com/ajax/libs/prism/1.29.0/components/prism-json.min.js"></script>
     <script src="https://cdnjs.cloudflare.com/ajax/libs/prism/1.29.0/plugins/line-numbers/prism-line-numbers.min.js"></script>
+    <script src="/static/admin/saved-filters.js"></script>
     <script>
         let currentPage = 1;
         let totalPages = 1;
@@ -228,6 +270,8 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
         // Load initial data
         document.addEventListener('DOMContentLoaded', function() {
             loadScripts();
+            initSavedFilters('scripts', document.getElementById('searchForm'),
+                document.getElementById('savedFilters'), () => loadScripts(1));
         });
 
         // Handle form submission
@@ -240,6 +284,10 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
         function clearForm() {
             document.getElementById('search').value = '';
             document.getElementById('sessionId').value = '';
+            document.getElementById('source').value = '';
+            document.getElementById('actor').value = '';
+            document.getElementById('hasError').value = '';
+            document.getElementById('since').value = '';
             currentPage = 1;
             loadScripts();
         }
@@ -290,8 +338,16 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
                 html += '<div>';
                 html += '<span class="session-id">' + escapeHtml(exec.session_id) + '</span>';
                 html += '<span class="badge bg-secondary ms-2">' + escapeHtml(exec.source) + '</span>';
+                if (exec.actor) {
+                    html += '<span class="badge bg-info text-dark ms-1" title="Actor">' + escapeHtml(exec.actor) + '</span>';
+                }
+                html += '</div>';
+                html += '<div>';
+                html += '<span class="timestamp">' + timestamp + '</span>';
+                html += '<a class="btn btn-sm btn-outline-secondary ms-2" href="/admin/logs/compare?left=' + exec.id + '&right=previous">Compare</a>';
+                html += '<button class="btn btn-sm btn-outline-danger ms-1" onclick="deleteExecution(' + exec.id + ')">Delete</button>';
+                html += '<button class="btn btn-sm btn-outline-danger ms-1 delete-session" data-session-id="' + escapeAttribute(exec.session_id) + '">Delete Session</button>';
                 html += '</div>';
-                html += '<div class="timestamp">' + timestamp + '</div>';
                 html += '</div>';
                 html += '<div class="card-body">';
                 
@@ -313,7 +369,7 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
                 html += '<div class="code-snippet" id="code-' + exec.id + '">';
                 html += '<button class="expand-btn" onclick="toggleExpand(\'code-' + exec.id + '\')">⛶</button>';
                 html += '<button class="action-btn copy-btn" onclick="copyRawContent(\'' + codeKey + '\')">📋</button>';
-                html += '<button class="action-btn download-btn" onclick="downloadRawContent(\'' + codeKey + '\', \'script-' + exec.session_id + '.js\', \'text/javascript\')">💾</button>';
+                html += '<button class="action-btn download-btn" data-content-key="' + codeKey + '" data-filename="' + escapeAttribute('script-' + exec.session_id + '.js') + '" data-mime-type="text/javascript">💾</button>';
                 html += '<pre class="line-numbers"><code class="language-javascript">' + escapeHtml(exec.code) + '</code></pre>';
                 html += '</div>';
                 
@@ -323,7 +379,7 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
                     html += '<div class="result-snippet" id="result-' + exec.id + '">';
                     html += '<button class="expand-btn" onclick="toggleExpand(\'result-' + exec.id + '\')">⛶</button>';
                     html += '<button class="action-btn copy-btn" onclick="copyRawContent(\'' + resultKey + '\')">📋</button>';
-                    html += '<button class="action-btn download-btn" onclick="downloadRawContent(\'' + resultKey + '\', \'result-' + exec.session_id + '.json\', \'application/json\')">💾</button>';
+                    html += '<button class="action-btn download-btn" data-content-key="' + resultKey + '" data-filename="' + escapeAttribute('result-' + exec.session_id + '.json') + '" data-mime-type="application/json">💾</button>';
                     html += '<pre class="line-numbers"><code class="language-json">' + formatJson(exec.result) + '</code></pre>';
                     html += '</div>';
                 }
@@ -334,7 +390,7 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
                     html += '<div class="console-log" id="console-' + exec.id + '">';
                     html += '<button class="expand-btn" onclick="toggleExpand(\'console-' + exec.id + '\')">⛶</button>';
                     html += '<button class="action-btn copy-btn" onclick="copyRawContent(\'' + consoleKey + '\')">📋</button>';
-                    html += '<button class="action-btn download-btn" onclick="downloadRawContent(\'' + consoleKey + '\', \'console-' + exec.session_id + '.log\', \'text/plain\')">💾</button>';
+                    html += '<button class="action-btn download-btn" data-content-key="' + consoleKey + '" data-filename="' + escapeAttribute('console-' + exec.session_id + '.log') + '" data-mime-type="text/plain">💾</button>';
                     html += '<pre class="line-numbers"><code class="language-none">' + escapeHtml(exec.console_log) + '</code></pre>';
                     html += '</div>';
                 }
@@ -345,7 +401,7 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
                     html += '<div class="error-text" id="error-' + exec.id + '">';
                     html += '<button class="expand-btn" onclick="toggleExpand(\'error-' + exec.id + '\')">⛶</button>';
                     html += '<button class="action-btn copy-btn" onclick="copyRawContent(\'' + errorKey + '\')">📋</button>';
-                    html += '<button class="action-btn download-btn" onclick="downloadRawContent(\'' + errorKey + '\', \'error-' + exec.session_id + '.txt\', \'text/plain\')">💾</button>';
+                    html += '<button class="action-btn download-btn" data-content-key="' + errorKey + '" data-filename="' + escapeAttribute('error-' + exec.session_id + '.txt') + '" data-mime-type="text/plain">💾</button>';
                     html += '<pre class="line-numbers"><code class="language-none">' + escapeHtml(exec.error) + '</code></pre>';
                     html += '</div>';
                 }
@@ -355,6 +411,14 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
             });
 
             container.innerHTML = html;
+
+            // Session IDs come from clients, so they never go into inline handlers
+            container.querySelectorAll('.delete-session').forEach(button => {
+                button.addEventListener('click', () => deleteSession(button.dataset.sessionId));
+            });
+            container.querySelectorAll('.download-btn').forEach(button => {
+                button.addEventListener('click', () => downloadRawContent(button.dataset.contentKey, button.dataset.filename, button.dataset.mimeType));
+            });
             
             // Apply syntax highlighting
             Prism.highlightAll();
@@ -400,12 +464,60 @@ func serveScriptsPage(w http.ResponseWriter, r *http.Request, jsEngine *engine.E
             container.innerHTML = html;
         }
 
+        function deleteExecution(id) {
+            if (!confirm('Delete execution #' + id + '?')) return;
+            fetch('/admin/logs/api/executions/' + id, { method: 'DELETE' })
+                .then(response => {
+                    if (!response.ok) throw new Error('HTTP ' + response.status);
+                    loadScripts(currentPage);
+                })
+                .catch(error => alert('Failed to delete execution: ' + error.message));
+        }
+
+        function deleteSession(sessionId) {
+            if (!confirm('Delete all executions of session ' + sessionId + '?')) return;
+            fetch('/admin/logs/api/sessions/' + encodeURIComponent(sessionId), { method: 'DELETE' })
+                .then(response => {
+                    if (!response.ok) throw new Error('HTTP ' + response.status);
+                    loadScripts(1);
+                })
+                .catch(error => alert('Failed to delete session: ' + error.message));
+        }
+
+        function deleteMatching() {
+            const filter = filterFromForm(document.getElementById('searchForm'));
+            if (Object.keys(filter).length === 0) {
+                alert('Set at least one filter to select executions to delete');
+                return;
+            }
+            if (!confirm('Delete all executions matching the current filter?')) return;
+            fetch('/admin/logs/api/executions/delete', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ filter: filter })
+            })
+                .then(response => {
+                    if (!response.ok) throw new Error('HTTP ' + response.status);
+                    return response.json();
+                })
+                .then(data => {
+                    alert('Deleted ' + data.deleted + ' executions');
+                    loadScripts(1);
+                })
+                .catch(error => alert('Failed to delete executions: ' + error.message));
+        }
+
         function escapeHtml(text) {
             const div = document.createElement('div');
             div.textContent = text;
             return div.innerHTML;
         }
 
+        // escapeAttribute escapes text for a quoted HTML attribute value
+        function escapeAttribute(text) {
+            return escapeHtml(text).replace(/"/g, '&quot;').replace(/'/g, '&#39;');
+        }
+
         function toggleExpand(elementId) {
             const element = document.getElementById(elementId);
             if (element) {
@@ -502,8 +614,6 @@ func serveScriptsAPI(w http.ResponseWriter, r *http.Request, jsEngine *engine.En
 	}
 
 	// Get parameters
-	search := strings.TrimSpace(r.FormValue("search"))
-	sessionID := strings.TrimSpace(r.FormValue("sessionId"))
 	limitStr := r.FormValue("limit")
 	pageStr := r.FormValue("page")
 
@@ -525,8 +635,8 @@ func serveScriptsAPI(w http.ResponseWriter, r *http.Request, jsEngine *engine.En
 	offset := (page - 1) * limit
 
 	log.Info().
-		Str("search", search).
-		Str("sessionID", sessionID).
+		Str("search", r.FormValue("search")).
+		Str("sessionID", r.FormValue("sessionId")).
 		Str("limitStr", limitStr).
 		Str("pageStr", pageStr).
 		Int("limit", limit).
@@ -535,10 +645,7 @@ func serveScriptsAPI(w http.ResponseWriter, r *http.Request, jsEngine *engine.En
 		Msg("Scripts API request")
 
 	// Query via repository
-	filter := repository.ExecutionFilter{
-		Search:    search,
-		SessionID: sessionID,
-	}
+	filter := admin.ExecutionFilterFromQuery(r.Form)
 	pagination := repository.PaginationOptions{
 		Limit:  limit,
 		Offset: offset,
@@ -546,12 +653,17 @@ func serveScriptsAPI(w http.ResponseWriter, r *http.Request, jsEngine *engine.En
 
 	result, err := jsEngine.GetRepositoryManager().Executions().ListExecutions(r.Context(), filter, pagination)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to get script executions")
+		status, message := http.StatusInternalServerError, "Repository error"
+		if errors.Is(err, repository.ErrInvalidFilter) {
+			status, message = http.StatusBadRequest, err.Error()
+		} else {
+			log.Error().Err(err).Msg("Failed to get script executions")
+		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(status)
 		if encodeErr := json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
-			"error":   "Repository error",
+			"error":   message,
 		}); encodeErr != nil {
 			log.Error().Err(encodeErr).Msg("Failed to encode error response")
 		}
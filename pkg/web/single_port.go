@@ -0,0 +1,55 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AdminPaths are the paths of the admin interface when it shares a port with the JavaScript
+// routes. Paths ending in a slash match everything below them as well. Requests for them go
+// to the admin interface, all others to the JavaScript routes, so "/" and "/api/..." stay
+// free for apps.
+var AdminPaths = []string{
+	"/admin",
+	"/admin/",
+	"/static/admin/",
+	"/static/css/",
+	"/static/js/",
+	"/api/repl/execute",
+	"/api/playground/execute",
+	"/api/reset-vm",
+	"/api/preset",
+	"/api/docs",
+	"/api/env",
+	"/v1/execute",
+	"/v1/events",
+	"/playground",
+	"/repl",
+	"/history",
+	"/docs",
+	"/scripts",
+	MetricsPath,
+}
+
+// SinglePortHandler serves the admin interface and the JavaScript routes from one listener,
+// for deployments that can only expose one port. JavaScript routes under AdminPaths are
+// shadowed by the admin interface.
+func SinglePortHandler(jsHandler, adminHandler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsAdminPath(r.URL.Path) {
+			adminHandler.ServeHTTP(w, r)
+			return
+		}
+		jsHandler.ServeHTTP(w, r)
+	})
+}
+
+// IsAdminPath reports whether a path belongs to the admin interface in single-port mode
+func IsAdminPath(path string) bool {
+	for _, p := range AdminPaths {
+		if path == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(path, p)) {
+			return true
+		}
+	}
+	return false
+}
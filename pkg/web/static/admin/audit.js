@@ -0,0 +1,73 @@
+const auditPageSize = 100;
+let auditOffset = 0;
+
+async function refreshAudit(offset) {
+    auditOffset = Math.max(0, offset || 0);
+    const params = new URLSearchParams({ limit: auditPageSize, offset: auditOffset });
+    const actor = document.getElementById('actorFilter').value.trim();
+    const action = document.getElementById('actionFilter').value.trim();
+    if (actor) {
+        params.set('actor', actor);
+    }
+    if (action) {
+        params.set('action', action);
+    }
+
+    const body = document.getElementById('auditBody');
+    try {
+        const response = await fetch('/admin/audit/api?' + params);
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        renderAudit(await response.json());
+    } catch (error) {
+        console.error('Failed to refresh audit log:', error);
+        body.innerHTML = '<tr><td colspan="6" class="timers-empty">Failed to load audit log</td></tr>';
+    }
+}
+
+function renderAudit(entries) {
+    const body = document.getElementById('auditBody');
+    document.getElementById('newerButton').disabled = auditOffset === 0;
+    document.getElementById('olderButton').disabled = entries.length < auditPageSize;
+
+    if (entries.length === 0) {
+        body.innerHTML = '<tr><td colspan="6" class="timers-empty">No admin changes recorded</td></tr>';
+        return;
+    }
+
+    body.innerHTML = '';
+    for (const entry of entries) {
+        const row = document.createElement('tr');
+        const cells = [
+            new Date(entry.created_at).toLocaleString(),
+            entry.actor,
+            entry.action,
+            entry.method + ' ' + entry.path,
+            String(entry.status),
+            entry.summary
+        ];
+        for (const text of cells) {
+            const cell = document.createElement('td');
+            cell.textContent = text;
+            row.appendChild(cell);
+        }
+        row.children[1].onclick = () => filterBy('actorFilter', entry.actor);
+        row.children[2].onclick = () => filterBy('actionFilter', entry.action);
+        row.children[1].style.cursor = 'pointer';
+        row.children[2].style.cursor = 'pointer';
+        row.children[5].style.fontFamily = 'monospace';
+        row.children[5].style.wordBreak = 'break-all';
+        if (entry.status >= 400) {
+            row.children[4].style.color = '#dc3545';
+        }
+        body.appendChild(row);
+    }
+}
+
+function filterBy(inputId, value) {
+    document.getElementById(inputId).value = value;
+    refreshAudit(0);
+}
+
+refreshAudit(0);
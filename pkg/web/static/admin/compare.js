@@ -0,0 +1,83 @@
+// Execution comparison - shows two executions side by side with line diffs
+// of their code, result, console output and error
+
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text == null ? '' : String(text);
+    return div.innerHTML;
+}
+
+async function loadComparison(left, right) {
+    const container = document.getElementById('comparison');
+    try {
+        const response = await fetch('/admin/logs/api/executions/compare?left=' + encodeURIComponent(left) + '&right=' + encodeURIComponent(right));
+        if (!response.ok) {
+            container.innerHTML = '<p>' + escapeHtml(await response.text()) + '</p>';
+            return;
+        }
+        const data = await response.json();
+        renderHeader(data.left, data.right);
+
+        let html = '';
+        html += renderField('JavaScript Code', data.code);
+        html += renderField('Result', data.result);
+        html += renderField('Console Output', data.console);
+        html += renderField('Error', data.error);
+        container.innerHTML = html;
+    } catch (error) {
+        console.error('Failed to load comparison:', error);
+    }
+}
+
+function renderHeader(left, right) {
+    let html = '<div class="compare-grid compare-header">';
+    [left, right].forEach(execution => {
+        html += '<div class="details-meta">';
+        html += '  <h2>Execution #' + execution.id + ' ' + (execution.error
+            ? '<span class="status error">ERROR</span>'
+            : '<span class="status success">SUCCESS</span>') + '</h2>';
+        html += '  <div>Source: ' + escapeHtml(execution.source) + '</div>';
+        html += '  <div>Time: ' + new Date(execution.timestamp).toLocaleString() + '</div>';
+        html += '  <div>Session: ' + escapeHtml(execution.session_id) + '</div>';
+        html += '</div>';
+    });
+    html += '</div>';
+    document.getElementById('comparisonHeader').innerHTML = html;
+}
+
+function renderField(title, diff) {
+    if (!diff.rows || diff.rows.length === 0) return '';
+
+    let html = '<div class="section">';
+    html += '  <h3>' + title + (diff.identical ? ' <span class="compare-identical">identical</span>' : '') + '</h3>';
+    html += '  <table class="compare-table">';
+    diff.rows.forEach(row => {
+        html += '<tr class="diff-' + row.kind + '">';
+        html += '  <td class="diff-line-number">' + (row.leftLine || '') + '</td>';
+        html += '  <td class="diff-left"><pre>' + escapeHtml(row.left) + '</pre></td>';
+        html += '  <td class="diff-line-number">' + (row.rightLine || '') + '</td>';
+        html += '  <td class="diff-right"><pre>' + escapeHtml(row.right) + '</pre></td>';
+        html += '</tr>';
+    });
+    html += '  </table>';
+    html += '</div>';
+    return html;
+}
+
+document.getElementById('compareForm').addEventListener('submit', function(e) {
+    e.preventDefault();
+    const left = document.getElementById('leftId').value;
+    const right = document.getElementById('rightId').value || 'previous';
+    history.replaceState(null, '', '?left=' + encodeURIComponent(left) + '&right=' + encodeURIComponent(right));
+    loadComparison(left, right);
+});
+
+(function() {
+    const params = new URLSearchParams(window.location.search);
+    const left = params.get('left');
+    if (!left) return;
+    const right = params.get('right') || 'previous';
+    document.getElementById('leftId').value = left;
+    document.getElementById('rightId').value = right;
+    loadComparison(left, right);
+})();
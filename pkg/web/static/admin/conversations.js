@@ -0,0 +1,273 @@
+// Pause before a message without recorded chunks appears during a replay, at 1× speed
+const messagePause = 400;
+
+let conversation = null;
+let replayRun = 0; // Incremented to stop a running replay
+
+async function refreshConversations() {
+    const body = document.getElementById('conversationsBody');
+    try {
+        const response = await fetch('/admin/conversations/api');
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        renderConversations(await response.json());
+    } catch (error) {
+        console.error('Failed to refresh conversations:', error);
+        body.innerHTML = '<tr><td colspan="5" class="timers-empty">Failed to load conversations</td></tr>';
+    }
+}
+
+function renderConversations(conversations) {
+    const body = document.getElementById('conversationsBody');
+    if (conversations.length === 0) {
+        body.innerHTML = '<tr><td colspan="5" class="timers-empty">No conversations yet</td></tr>';
+        return;
+    }
+
+    body.innerHTML = '';
+    for (const item of conversations) {
+        const row = document.createElement('tr');
+        row.dataset.id = item.id;
+        if (conversation && conversation.id === item.id) {
+            row.classList.add('selected');
+        }
+        row.onclick = () => selectConversation(item.id);
+
+        const title = document.createElement('td');
+        title.textContent = item.title || 'Conversation ' + item.id;
+        row.appendChild(title);
+
+        const model = document.createElement('td');
+        model.textContent = item.model;
+        row.appendChild(model);
+
+        const count = document.createElement('td');
+        count.textContent = item.message_count;
+        row.appendChild(count);
+
+        const updated = document.createElement('td');
+        updated.textContent = new Date(item.updated_at).toLocaleString();
+        row.appendChild(updated);
+
+        const action = document.createElement('td');
+        const remove = document.createElement('button');
+        remove.textContent = 'Delete';
+        remove.onclick = (event) => {
+            event.stopPropagation();
+            deleteConversation(item);
+        };
+        action.appendChild(remove);
+        row.appendChild(action);
+
+        body.appendChild(row);
+    }
+}
+
+async function selectConversation(id) {
+    stopReplay();
+    try {
+        const response = await fetch('/admin/conversations/api/' + encodeURIComponent(id));
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        conversation = await response.json();
+    } catch (error) {
+        console.error('Failed to load conversation:', error);
+        showNotification('Failed to load conversation', 'error');
+        return;
+    }
+
+    for (const row of document.querySelectorAll('#conversationsBody tr')) {
+        row.classList.toggle('selected', row.dataset.id === String(id));
+    }
+    const title = document.getElementById('conversationTitle');
+    title.textContent = (conversation.title || 'Conversation ' + conversation.id) + (conversation.model ? ' · ' + conversation.model : '');
+    document.getElementById('replayButton').disabled = conversation.messages.length === 0;
+    renderMessages();
+}
+
+// renderMessages shows the whole conversation at once
+function renderMessages() {
+    const container = document.getElementById('messages');
+    container.innerHTML = '';
+    if (conversation.messages.length === 0) {
+        container.textContent = 'No messages yet';
+        return;
+    }
+    conversation.messages.forEach((message, index) => {
+        const element = messageElement(message, index);
+        element.querySelector('.message-content').textContent = message.content;
+        showToolCalls(element, message);
+        container.appendChild(element);
+    });
+}
+
+// messageElement creates the element of a message with an empty content
+function messageElement(message, index) {
+    const element = document.createElement('div');
+    element.className = 'message role-' + message.role;
+
+    const header = document.createElement('div');
+    header.className = 'message-header';
+    const role = document.createElement('span');
+    role.className = 'message-role';
+    role.textContent = message.role;
+    header.appendChild(role);
+    if (message.tool_call_id) {
+        const answers = document.createElement('span');
+        answers.textContent = 'result of ' + message.tool_call_id;
+        header.appendChild(answers);
+    }
+    if (message.tokens && message.tokens.length > 0) {
+        const timing = document.createElement('span');
+        const duration = message.tokens[message.tokens.length - 1].ms;
+        timing.textContent = message.tokens.length + ' chunks in ' + (duration / 1000).toFixed(1) + 's';
+        header.appendChild(timing);
+    }
+    const time = document.createElement('span');
+    time.textContent = new Date(message.created_at).toLocaleTimeString();
+    header.appendChild(time);
+    const continueButton = document.createElement('button');
+    continueButton.textContent = 'Continue from here';
+    continueButton.onclick = () => continueInPlayground(index);
+    header.appendChild(continueButton);
+    element.appendChild(header);
+
+    const content = document.createElement('div');
+    content.className = 'message-content';
+    element.appendChild(content);
+    return element;
+}
+
+function showToolCalls(element, message) {
+    for (const call of message.tool_calls || []) {
+        const block = document.createElement('div');
+        block.className = 'tool-call';
+        const name = document.createElement('strong');
+        name.textContent = '🔧 ' + call.name + (call.id ? ' (' + call.id + ')' : '');
+        block.appendChild(name);
+        const args = document.createElement('pre');
+        args.textContent = JSON.stringify(call.arguments, null, 2);
+        block.appendChild(args);
+        element.appendChild(block);
+    }
+}
+
+// replayConversation plays the messages back, streamed ones chunk by chunk with their
+// recorded timing divided by the selected speed
+async function replayConversation() {
+    if (!conversation) {
+        return;
+    }
+    const run = ++replayRun;
+    const speed = Number(document.getElementById('replaySpeed').value);
+    const wait = (ms) => new Promise(resolve => setTimeout(resolve, speed > 0 ? ms / speed : 0));
+    const container = document.getElementById('messages');
+    container.innerHTML = '';
+    document.getElementById('stopButton').disabled = false;
+
+    for (const [index, message] of conversation.messages.entries()) {
+        await wait(messagePause);
+        if (run !== replayRun) {
+            return;
+        }
+        const element = messageElement(message, index);
+        const content = element.querySelector('.message-content');
+        container.appendChild(element);
+        element.scrollIntoView({ block: 'nearest' });
+
+        if (message.tokens && message.tokens.length > 0) {
+            content.classList.add('streaming');
+            let previous = 0;
+            for (const token of message.tokens) {
+                await wait(token.ms - previous);
+                if (run !== replayRun) {
+                    return;
+                }
+                previous = token.ms;
+                content.textContent += token.text;
+            }
+            content.classList.remove('streaming');
+        } else {
+            content.textContent = message.content;
+        }
+        showToolCalls(element, message);
+    }
+    document.getElementById('stopButton').disabled = true;
+}
+
+// stopReplay ends a running replay and shows the whole conversation
+function stopReplay() {
+    const running = !document.getElementById('stopButton').disabled;
+    replayRun++;
+    document.getElementById('stopButton').disabled = true;
+    if (running && conversation) {
+        renderMessages();
+    }
+}
+
+// continueInPlayground opens the playground with code recording a copy of the conversation
+// up to the message at index, to be continued from there
+function continueInPlayground(index) {
+    const messages = conversation.messages.slice(0, index + 1).map(message => {
+        const copy = { role: message.role, content: message.content };
+        if (message.tool_calls && message.tool_calls.length > 0) {
+            copy.tool_calls = message.tool_calls;
+        }
+        if (message.tool_call_id) {
+            copy.tool_call_id = message.tool_call_id;
+        }
+        return copy;
+    });
+    const title = conversation.title || 'Conversation ' + conversation.id;
+    const code = `// Continue "${title.replace(/[\r\n]/g, ' ')}" after message ${index + 1} of ${conversation.messages.length}
+const messages = ${JSON.stringify(messages, null, 2)};
+
+const conv = conversations.create(${JSON.stringify({ title: title + ' (continued)', model: conversation.model })});
+for (const message of messages) {
+    conversations.append(conv.id, message);
+}
+
+// Send the messages to the model and record its reply, for example:
+// const reply = conversations.stream(conv.id);
+// reply.write(text);
+// reply.end();
+console.log('Conversation', conv.id, 'has', messages.length, 'messages');
+`;
+    localStorage.setItem('playgroundCode', code);
+    window.location.href = '/playground';
+}
+
+async function deleteConversation(item) {
+    const title = item.title || 'conversation ' + item.id;
+    if (!confirm('Delete ' + title + ' and its messages?')) {
+        return;
+    }
+    const response = await fetch('/admin/conversations/api/' + encodeURIComponent(item.id), { method: 'DELETE' });
+    if (response.ok) {
+        showNotification('Deleted ' + title, 'success');
+        if (conversation && conversation.id === item.id) {
+            stopReplay();
+            conversation = null;
+            document.getElementById('conversationTitle').textContent = 'Select a conversation';
+            document.getElementById('messages').innerHTML = '';
+            document.getElementById('replayButton').disabled = true;
+        }
+    } else {
+        showNotification('Failed to delete conversation: ' + await response.text(), 'error');
+    }
+    refreshConversations();
+}
+
+function showNotification(message, type) {
+    const notification = document.getElementById('notification');
+    notification.textContent = message;
+    notification.className = 'notification ' + type + ' show';
+
+    setTimeout(() => {
+        notification.classList.remove('show');
+    }, 3000);
+}
+
+refreshConversations();
@@ -0,0 +1,18 @@
+// Environment banner - shows which engine environment (dev, prod, ...) the admin console is operating on
+
+async function loadEnvironmentBanner() {
+    try {
+        const response = await fetch('/api/env');
+        if (!response.ok) return;
+        const env = await response.json();
+
+        const banner = document.createElement('div');
+        banner.className = 'env-banner' + (env.production ? ' env-production' : '');
+        banner.textContent = 'Environment: ' + env.name + (env.production ? ' (production data)' : '');
+        document.body.insertBefore(banner, document.body.firstChild);
+    } catch (error) {
+        console.error('Failed to load environment:', error);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', loadEnvironmentBanner);
@@ -0,0 +1,126 @@
+async function refreshFlags() {
+    const body = document.getElementById('flagsBody');
+    try {
+        const response = await fetch('/admin/flags/api');
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        renderFlags(await response.json());
+    } catch (error) {
+        console.error('Failed to refresh flags:', error);
+        body.innerHTML = '<tr><td colspan="6" class="timers-empty">Failed to load flags</td></tr>';
+    }
+}
+
+function renderFlags(flags) {
+    const body = document.getElementById('flagsBody');
+    if (flags.length === 0) {
+        body.innerHTML = '<tr><td colspan="6" class="timers-empty">No flags yet</td></tr>';
+        return;
+    }
+
+    body.innerHTML = '';
+    for (const flag of flags) {
+        const row = document.createElement('tr');
+
+        const name = document.createElement('td');
+        name.textContent = flag.name;
+        row.appendChild(name);
+
+        const description = document.createElement('td');
+        description.textContent = flag.description;
+        row.appendChild(description);
+
+        const enabled = document.createElement('td');
+        const toggle = document.createElement('input');
+        toggle.type = 'checkbox';
+        toggle.checked = flag.enabled;
+        toggle.onchange = () => saveFlag(flag, { enabled: toggle.checked });
+        enabled.appendChild(toggle);
+        row.appendChild(enabled);
+
+        const rollout = document.createElement('td');
+        const percentage = document.createElement('input');
+        percentage.type = 'number';
+        percentage.min = 0;
+        percentage.max = 100;
+        percentage.value = flag.percentage;
+        percentage.style.width = '4rem';
+        percentage.onchange = () => saveFlag(flag, { percentage: Number(percentage.value) });
+        rollout.appendChild(percentage);
+        rollout.appendChild(document.createTextNode(' %'));
+        row.appendChild(rollout);
+
+        const updated = document.createElement('td');
+        updated.textContent = new Date(flag.updated_at).toLocaleString();
+        row.appendChild(updated);
+
+        const action = document.createElement('td');
+        const remove = document.createElement('button');
+        remove.textContent = 'Delete';
+        remove.onclick = () => deleteFlag(flag.name);
+        action.appendChild(remove);
+        row.appendChild(action);
+
+        body.appendChild(row);
+    }
+}
+
+async function saveFlag(flag, changes) {
+    const update = Object.assign({
+        description: flag.description,
+        enabled: flag.enabled,
+        percentage: flag.percentage
+    }, changes);
+    try {
+        const response = await fetch('/admin/flags/api/' + encodeURIComponent(flag.name), {
+            method: 'PUT',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(update)
+        });
+        if (!response.ok) {
+            showNotification('Failed to save flag: ' + await response.text(), 'error');
+        } else {
+            const saved = await response.json();
+            showNotification('Flag ' + saved.name + (saved.enabled ? ' on for ' + saved.percentage + '%' : ' off'), 'success');
+        }
+    } catch (error) {
+        console.error('Failed to save flag:', error);
+        showNotification('Failed to save flag', 'error');
+    }
+    refreshFlags();
+}
+
+async function createFlag() {
+    const name = prompt('Name of the new flag:');
+    if (!name) {
+        return;
+    }
+    const description = prompt('Description (optional):') || '';
+    await saveFlag({ name, description, enabled: false, percentage: 100 }, {});
+}
+
+async function deleteFlag(name) {
+    if (!confirm('Delete flag ' + name + '? Code checking it sees it as off.')) {
+        return;
+    }
+    const response = await fetch('/admin/flags/api/' + encodeURIComponent(name), { method: 'DELETE' });
+    if (response.ok) {
+        showNotification('Flag ' + name + ' deleted', 'success');
+    } else {
+        showNotification('Failed to delete flag: ' + await response.text(), 'error');
+    }
+    refreshFlags();
+}
+
+function showNotification(message, type) {
+    const notification = document.getElementById('notification');
+    notification.textContent = message;
+    notification.className = 'notification ' + type + ' show';
+
+    setTimeout(() => {
+        notification.classList.remove('show');
+    }, 3000);
+}
+
+refreshFlags();
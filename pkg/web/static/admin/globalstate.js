@@ -20,6 +20,63 @@ async function refreshGlobalState() {
     }
 }
 
+async function refreshTimers() {
+    const body = document.getElementById('timersBody');
+    try {
+        const response = await fetch('/admin/timers');
+        renderTimers(await response.json());
+    } catch (error) {
+        console.error('Failed to refresh timers:', error);
+        body.innerHTML = '<tr><td colspan="7" class="timers-empty">Failed to load timers</td></tr>';
+    }
+}
+
+function renderTimers(timers) {
+    const body = document.getElementById('timersBody');
+    if (timers.length === 0) {
+        body.innerHTML = '<tr><td colspan="7" class="timers-empty">No active timers</td></tr>';
+        return;
+    }
+
+    body.innerHTML = '';
+    for (const timer of timers) {
+        const row = document.createElement('tr');
+        const cells = [
+            timer.id,
+            timer.interval ? 'interval' : 'timeout',
+            timer.delayMs + ' ms',
+            (timer.origin || '-') + (timer.sessionId ? ' (session ' + timer.sessionId + ')' : ''),
+            timer.runs,
+            timer.lastError || ''
+        ];
+        for (const value of cells) {
+            const cell = document.createElement('td');
+            cell.textContent = value;
+            row.appendChild(cell);
+        }
+        row.lastChild.className = 'timer-error';
+
+        const action = document.createElement('td');
+        const cancel = document.createElement('button');
+        cancel.textContent = 'Cancel';
+        cancel.onclick = () => cancelTimer(timer.id);
+        action.appendChild(cancel);
+        row.appendChild(action);
+        body.appendChild(row);
+    }
+}
+
+async function cancelTimer(id) {
+    const response = await fetch('/admin/timers?id=' + encodeURIComponent(id), { method: 'DELETE' });
+    if (response.ok) {
+        renderTimers(await response.json());
+        showNotification('Timer ' + id + ' cancelled', 'success');
+    } else {
+        showNotification('Failed to cancel timer: ' + await response.text(), 'error');
+        refreshTimers();
+    }
+}
+
 async function saveGlobalState() {
     const editor = document.getElementById('globalStateEditor');
     const jsonData = editor.value;
@@ -59,6 +116,35 @@ async function resetGlobalState() {
     }
 }
 
+async function resetVM() {
+    if (!confirm('Reset the JavaScript VM? Routes, timers and sessions are dropped, then the bootstrap file and scripts run again.')) {
+        return;
+    }
+    const keepState = confirm('Keep globalState? Cancel starts from an empty globalState.');
+    try {
+        const response = await fetch('/admin/reset', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ keepState })
+        });
+        if (!response.ok && response.status !== 422) {
+            showNotification('Failed to reset VM: ' + await response.text(), 'error');
+            return;
+        }
+        const result = await response.json();
+        if (result.error) {
+            showNotification('VM reset, but the bootstrap file failed: ' + result.error, 'error');
+        } else {
+            showNotification('VM reset: ' + result.routes + ' routes registered', 'success');
+        }
+        refreshGlobalState();
+        refreshTimers();
+    } catch (error) {
+        console.error('Failed to reset VM:', error);
+        showNotification('Failed to reset VM', 'error');
+    }
+}
+
 function validateJSON() {
     const editor = document.getElementById('globalStateEditor');
     const status = document.getElementById('validationStatus');
@@ -81,7 +167,10 @@ function validateJSON() {
 function toggleAutoRefresh() {
     const checkbox = document.getElementById('autoRefresh');
     if (checkbox.checked) {
-        autoRefreshInterval = setInterval(refreshGlobalState, 5000);
+        autoRefreshInterval = setInterval(() => {
+            refreshGlobalState();
+            refreshTimers();
+        }, 5000);
     } else {
         if (autoRefreshInterval) {
             clearInterval(autoRefreshInterval);
@@ -118,3 +207,4 @@ window.addEventListener('beforeunload', (e) => {
 
 // Load initial data
 refreshGlobalState();
+refreshTimers();
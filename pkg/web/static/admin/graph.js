@@ -0,0 +1,156 @@
+const columns = { script: 0, session: 0, code: 0, route: 1, table: 2 };
+const nodeColors = { script: '#6f42c1', session: '#0d6efd', code: '#495057', route: '#198754', table: '#6c757d' };
+const edgeColors = { registers: '#6ea8fe', writes: '#dc3545', reads: '#adb5bd' };
+
+const nodeWidth = 260;
+const nodeHeight = 28;
+const rowGap = 12;
+const columnGap = 160;
+const svgNS = 'http://www.w3.org/2000/svg';
+
+let graph = { nodes: [], edges: [] };
+let selected = null;
+
+async function refreshGraph() {
+    const status = document.getElementById('graphStatus');
+    try {
+        const response = await fetch('/admin/graph/api');
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        graph = await response.json();
+        selected = null;
+        renderGraph();
+    } catch (error) {
+        console.error('Failed to load graph:', error);
+        status.textContent = 'Failed to load graph: ' + error.message;
+        status.style.display = '';
+    }
+}
+
+function svgElement(name, attributes) {
+    const element = document.createElementNS(svgNS, name);
+    for (const [key, value] of Object.entries(attributes)) {
+        element.setAttribute(key, value);
+    }
+    return element;
+}
+
+function nodeLabel(node) {
+    let label = node.label;
+    if (node.ai) {
+        label += ' (AI)';
+    } else if (node.source) {
+        label += ' (' + node.source + ')';
+    }
+    if (node.requests) {
+        label += ' · ' + node.requests + ' req';
+    }
+    return label.length > 40 ? label.slice(0, 39) + '…' : label;
+}
+
+function renderGraph() {
+    const svg = document.getElementById('graph');
+    const status = document.getElementById('graphStatus');
+    svg.innerHTML = '';
+    if (graph.nodes.length === 0) {
+        status.textContent = 'No routes registered yet';
+        status.style.display = '';
+        svg.setAttribute('width', 0);
+        svg.setAttribute('height', 0);
+        return;
+    }
+    status.style.display = 'none';
+
+    // Lay the nodes out in columns: registering code, routes, tables
+    const positions = {};
+    const rows = [0, 0, 0];
+    for (const node of graph.nodes) {
+        const column = columns[node.kind] || 0;
+        positions[node.id] = {
+            x: column * (nodeWidth + columnGap),
+            y: rows[column] * (nodeHeight + rowGap)
+        };
+        rows[column]++;
+    }
+    const height = Math.max(...rows) * (nodeHeight + rowGap);
+    svg.setAttribute('width', 3 * nodeWidth + 2 * columnGap + 2);
+    svg.setAttribute('height', height);
+
+    for (const edge of graph.edges) {
+        const from = positions[edge.from];
+        const to = positions[edge.to];
+        if (!from || !to) {
+            continue;
+        }
+        const x1 = from.x + nodeWidth;
+        const y1 = from.y + nodeHeight / 2;
+        const x2 = to.x;
+        const y2 = to.y + nodeHeight / 2;
+        const bend = (x2 - x1) / 2;
+        const path = svgElement('path', {
+            d: `M ${x1} ${y1} C ${x1 + bend} ${y1}, ${x2 - bend} ${y2}, ${x2} ${y2}`,
+            class: 'edge',
+            stroke: edgeColors[edge.kind] || '#adb5bd'
+        });
+        if (edge.kind === 'reads') {
+            path.setAttribute('stroke-dasharray', '4 3');
+        }
+        path.dataset.from = edge.from;
+        path.dataset.to = edge.to;
+        const title = svgElement('title', {});
+        title.textContent = edge.kind + (edge.count ? ' (' + edge.count + ' operations)' : '');
+        path.appendChild(title);
+        svg.appendChild(path);
+    }
+
+    for (const node of graph.nodes) {
+        const position = positions[node.id];
+        const group = svgElement('g', { class: 'node', transform: `translate(${position.x}, ${position.y})` });
+        group.dataset.id = node.id;
+        const rect = svgElement('rect', {
+            width: nodeWidth,
+            height: nodeHeight,
+            rx: 4,
+            fill: node.ai ? '#fd7e14' : (nodeColors[node.kind] || '#495057')
+        });
+        rect.addEventListener('click', () => selectNode(node.id));
+        const title = svgElement('title', {});
+        title.textContent = node.label;
+        rect.appendChild(title);
+        group.appendChild(rect);
+        const text = svgElement('text', { x: 8, y: nodeHeight / 2 + 4 });
+        text.textContent = nodeLabel(node);
+        group.appendChild(text);
+        svg.appendChild(group);
+    }
+    highlight();
+}
+
+function selectNode(id) {
+    selected = selected === id ? null : id;
+    highlight();
+}
+
+// highlight dims what is not connected to the selected node
+function highlight() {
+    const connected = new Set();
+    if (selected) {
+        connected.add(selected);
+        for (const edge of graph.edges) {
+            if (edge.from === selected || edge.to === selected) {
+                connected.add(edge.from);
+                connected.add(edge.to);
+            }
+        }
+    }
+    for (const path of document.querySelectorAll('#graph .edge')) {
+        const active = !selected || path.dataset.from === selected || path.dataset.to === selected;
+        path.classList.toggle('dimmed', !active);
+    }
+    for (const group of document.querySelectorAll('#graph .node')) {
+        group.classList.toggle('dimmed', selected !== null && !connected.has(group.dataset.id));
+    }
+}
+
+refreshGraph();
@@ -3,6 +3,17 @@ let selectedRequestId = null;
 let eventSource = null;
 let isRealTimeEnabled = false;
 
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text == null ? '' : String(text);
+    return div.innerHTML;
+}
+
+// escapeAttribute escapes text for a quoted HTML attribute value
+function escapeAttribute(text) {
+    return escapeHtml(text).replace(/"/g, '&quot;').replace(/'/g, '&#39;');
+}
+
 async function loadStats() {
     try {
         const response = await fetch('/admin/logs/api/stats');
@@ -115,6 +126,15 @@ async function selectRequest(requestId) {
         html += '    <div>Started: ' + startTime + '</div>';
         html += '    <div>Duration: ' + duration + '</div>';
         html += '    <div>Remote IP: ' + (request.remoteIP || 'N/A') + '</div>';
+        if (request.handler) {
+            html += '    <div>Handler: ' + request.handler + '</div>';
+        }
+        html += '  </div>';
+        html += '  <div class="details-actions">';
+        html += '    <button onclick="window.location.href=\'/admin/logs/request?id=' + encodeURIComponent(request.id) + '\'">View Timeline</button>';
+        if (request.executionId) {
+            html += '    <button onclick="switchTab(\'executions\'); loadExecutionDetails(' + request.executionId + ')">Execution #' + request.executionId + '</button>';
+        }
         html += '  </div>';
         html += '</div>';
         
@@ -411,9 +431,37 @@ async function loadExecutionDetails(executionId) {
         html += '  </div>';
         html += '  <div class="details-meta">';
         html += '    <span>Source: ' + (execution.source || 'unknown') + '</span>';
+        if (execution.actor) {
+            html += '    <span>Actor: ' + escapeHtml(execution.actor) + '</span>';
+        }
         html += '    <span>Time: ' + new Date(execution.timestamp).toLocaleString() + '</span>';
         if (execution.session_id) {
-            html += '    <span>Session: ' + execution.session_id + '</span>';
+            html += '    <span>Session: ' + escapeHtml(execution.session_id) + '</span>';
+        }
+        if (execution.saved_as) {
+            html += '    <span>Saved as: ' + escapeHtml(execution.saved_as) + '</span>';
+        }
+        const usage = execution.code_usage;
+        if (usage && usage.runs > 1) {
+            let ranBy = usage.runs + ' runs in ' + usage.sessions + (usage.sessions === 1 ? ' session' : ' sessions');
+            if (usage.actors.length > 0) {
+                ranBy += ' by ' + usage.actors.map(escapeHtml).join(', ');
+            }
+            html += '    <span>Same code: ' + ranBy + '</span>';
+        }
+        html += '  </div>';
+        html += '  <div class="details-actions">';
+        if (execution.request_id) {
+            html += '    <button onclick="window.location.href=\'/admin/logs/request?id=' + encodeURIComponent(execution.request_id) + '\'">Request Timeline</button>';
+        }
+        html += '    <button onclick="window.location.href=\'/admin/logs/compare?left=' + execution.id + '&right=previous\'">Compare with Previous</button>';
+        if (usage && usage.runs > 1) {
+            html += '    <button onclick="window.location.href=\'/history?codeHash=' + encodeURIComponent(usage.hash) + '\'">Other Runs of This Code</button>';
+        }
+        html += '    <button class="danger" onclick="deleteExecution(' + execution.id + ')">Delete Execution</button>';
+        if (execution.session_id) {
+            // Session IDs come from clients, so they never go into inline handlers
+            html += '    <button class="danger delete-session" data-session-id="' + escapeAttribute(execution.session_id) + '">Delete Session</button>';
         }
         html += '  </div>';
         html += '</div>';
@@ -427,7 +475,16 @@ async function loadExecutionDetails(executionId) {
         html += '</div>';
         
         // Result section
-        if (execution.result) {
+        if (execution.paging) {
+            // Large results are stored in pages and fetched on demand
+            const p = execution.paging;
+            html += '<div class="section">';
+            html += '  <h3>Result</h3>';
+            html += '  <p>' + p.items + ' ' + (p.kind === 'array' ? 'elements' : 'entries') + ' (' + p.bytes + ' bytes), stored in pages.</p>';
+            html += '  <div class="json-display" id="result-items"></div>';
+            html += '  <div class="details-actions"><button id="result-more" onclick="loadResultItems(' + execution.id + ')">Load More</button></div>';
+            html += '</div>';
+        } else if (execution.result) {
             html += '<div class="section">';
             html += '  <h3>Result</h3>';
             html += '  <div class="json-display">' + execution.result + '</div>';
@@ -444,6 +501,37 @@ async function loadExecutionDetails(executionId) {
             html += '</div>';
         }
         
+        // Truncated output
+        if (execution.truncation) {
+            const t = execution.truncation;
+            const parts = [];
+            if (t.result_bytes) parts.push('result was ' + t.result_bytes + ' bytes');
+            if (t.console_log_bytes) parts.push('console output was ' + t.console_log_bytes + ' bytes');
+            html += '<div class="section">';
+            html += '  <h3>Truncated Output</h3>';
+            html += '  <p>The stored output was shortened (' + parts.join(', ') + ').</p>';
+            if (t.output_file) {
+                html += '  <div class="details-actions"><button onclick="window.location.href=\'/admin/logs/api/executions/' + execution.id + '/output\'">Download Full Output</button></div>';
+            }
+            html += '</div>';
+        }
+        
+        // Files attached with output.attach()
+        if (execution.attachments && execution.attachments.length > 0) {
+            html += '<div class="section">';
+            html += '  <h3>Attachments</h3>';
+            execution.attachments.forEach(a => {
+                const url = '/admin/logs/api/executions/' + execution.id + '/attachments/' + encodeURIComponent(a.name);
+                html += '  <div class="attachment">';
+                html += '    <a href="' + url + '?download=1" download>' + escapeHtml(a.name) + '</a> (' + escapeHtml(a.mime_type) + ', ' + a.size + ' bytes)';
+                if (a.mime_type.startsWith('image/')) {
+                    html += '    <div><img src="' + url + '" alt="' + escapeHtml(a.name) + '" style="max-width: 100%; margin-top: 8px;"></div>';
+                }
+                html += '  </div>';
+            });
+            html += '</div>';
+        }
+        
         // Error section
         if (execution.error) {
             html += '<div class="section">';
@@ -453,11 +541,76 @@ async function loadExecutionDetails(executionId) {
         }
         
         executionDetails.innerHTML = html;
+        executionDetails.querySelectorAll('.delete-session').forEach(button => {
+            button.addEventListener('click', () => deleteSession(button.dataset.sessionId));
+        });
+        if (execution.paging) {
+            resultItemsLoaded = 0;
+            loadResultItems(execution.id);
+        }
     } catch (error) {
         console.error('Failed to load execution details:', error);
     }
 }
 
+let resultItemsLoaded = 0;
+
+async function loadResultItems(executionId) {
+    try {
+        const response = await fetch('/admin/logs/api/executions/' + executionId + '/result?offset=' + resultItemsLoaded + '&limit=100');
+        if (!response.ok) throw new Error(await response.text());
+        const slice = await response.json();
+        const container = document.getElementById('result-items');
+        let html = '';
+        slice.items.forEach((item, i) => {
+            const index = slice.offset + i;
+            const text = slice.kind === 'array'
+                ? '[' + index + '] ' + JSON.stringify(item)
+                : JSON.stringify(item.key) + ': ' + JSON.stringify(item.value);
+            html += '<div>' + escapeHtml(text) + '</div>';
+        });
+        container.insertAdjacentHTML('beforeend', html);
+        resultItemsLoaded = slice.offset + slice.items.length;
+        if (resultItemsLoaded >= slice.total) {
+            document.getElementById('result-more').style.display = 'none';
+        }
+    } catch (error) {
+        console.error('Failed to load result items:', error);
+    }
+}
+
+async function deleteExecution(executionId) {
+    if (!confirm('Delete execution #' + executionId + '?')) return;
+    try {
+        const response = await fetch('/admin/logs/api/executions/' + executionId, { method: 'DELETE' });
+        if (!response.ok) throw new Error(await response.text());
+        clearExecutionSelection();
+        await Promise.all([loadExecutionStats(), loadExecutions()]);
+    } catch (error) {
+        console.error('Failed to delete execution:', error);
+        alert('Failed to delete execution: ' + error.message);
+    }
+}
+
+async function deleteSession(sessionId) {
+    if (!confirm('Delete all executions of session ' + sessionId + '?')) return;
+    try {
+        const response = await fetch('/admin/logs/api/sessions/' + encodeURIComponent(sessionId), { method: 'DELETE' });
+        if (!response.ok) throw new Error(await response.text());
+        clearExecutionSelection();
+        await Promise.all([loadExecutionStats(), loadExecutions()]);
+    } catch (error) {
+        console.error('Failed to delete session:', error);
+        alert('Failed to delete session: ' + error.message);
+    }
+}
+
+function clearExecutionSelection() {
+    selectedRequestId = null;
+    document.getElementById('noSelection').style.display = 'flex';
+    document.getElementById('executionDetails').style.display = 'none';
+}
+
 function switchTab(tabName) {
     // Update tab buttons
     document.querySelectorAll('.tab-button').forEach(btn => btn.classList.remove('active'));
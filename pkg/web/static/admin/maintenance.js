@@ -0,0 +1,62 @@
+// Maintenance mode - shows a banner while the JavaScript routes are paused and lets the admin toggle it
+
+async function fetchMaintenance() {
+    const response = await fetch('/admin/maintenance');
+    if (!response.ok) throw new Error('HTTP ' + response.status);
+    return response.json();
+}
+
+function renderMaintenance(status) {
+    let banner = document.getElementById('maintenanceBanner');
+    if (status.enabled) {
+        if (!banner) {
+            banner = document.createElement('div');
+            banner.id = 'maintenanceBanner';
+            banner.className = 'maintenance-banner';
+            document.body.insertBefore(banner, document.body.firstChild);
+        }
+        const since = status.since ? ' since ' + new Date(status.since).toLocaleString() : '';
+        banner.textContent = 'Maintenance mode: JavaScript routes answer 503' + since +
+            (status.message ? ' - ' + status.message : '');
+    } else if (banner) {
+        banner.remove();
+    }
+
+    const toggle = document.getElementById('maintenanceToggle');
+    if (toggle) {
+        toggle.textContent = status.enabled ? 'Resume Routes' : 'Maintenance Mode';
+        toggle.classList.toggle('danger', !status.enabled);
+    }
+}
+
+async function toggleMaintenance() {
+    try {
+        const current = await fetchMaintenance();
+        let message = '';
+        if (!current.enabled) {
+            message = prompt('Pause all JavaScript routes? They will answer 503 until maintenance mode is turned off.\n\nMessage shown to visitors (optional):', '');
+            if (message === null) return;
+        }
+
+        const response = await fetch('/admin/maintenance', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ enabled: !current.enabled, message: message })
+        });
+        if (!response.ok) throw new Error('HTTP ' + response.status);
+        renderMaintenance(await response.json());
+    } catch (error) {
+        console.error('Failed to toggle maintenance mode:', error);
+        alert('Failed to toggle maintenance mode');
+    }
+}
+
+async function loadMaintenance() {
+    try {
+        renderMaintenance(await fetchMaintenance());
+    } catch (error) {
+        console.error('Failed to load maintenance status:', error);
+    }
+}
+
+document.addEventListener('DOMContentLoaded', loadMaintenance);
@@ -0,0 +1,237 @@
+let notebook = null;
+let running = false;
+
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text == null ? '' : String(text);
+    return div.innerHTML;
+}
+
+function newCellId() {
+    return Date.now().toString(36) + Math.random().toString(36).slice(2, 8);
+}
+
+async function api(path, options) {
+    const response = await fetch('/admin/notebooks/api' + path, options);
+    if (!response.ok) {
+        throw new Error((await response.text()) || response.statusText);
+    }
+    return response.json();
+}
+
+function jsonBody(method, body) {
+    return {
+        method: method,
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(body)
+    };
+}
+
+async function loadNotebooks() {
+    const list = document.getElementById('notebookList');
+    try {
+        const notebooks = await api('');
+        if (!notebooks || notebooks.length === 0) {
+            list.innerHTML = '<p class="text-muted">No notebooks yet</p>';
+            return;
+        }
+        list.innerHTML = notebooks.map(nb => {
+            const selected = notebook && notebook.id === nb.id ? ' selected' : '';
+            return '<div class="request-item' + selected + '" onclick="openNotebook(' + nb.id + ')">' +
+                '<div class="request-method">' + escapeHtml(nb.name) + '</div>' +
+                '<div class="request-time">' + (nb.cells ? nb.cells.length : 0) + ' cells, updated ' +
+                new Date(nb.updated_at).toLocaleString() + '</div>' +
+                '</div>';
+        }).join('');
+    } catch (error) {
+        list.innerHTML = '<p class="text-muted">Error loading notebooks: ' + escapeHtml(error.message) + '</p>';
+    }
+}
+
+async function createNotebook() {
+    try {
+        notebook = await api('', jsonBody('POST', { name: 'Untitled notebook', cells: [{ id: newCellId(), code: '' }] }));
+        renderNotebook();
+        loadNotebooks();
+    } catch (error) {
+        alert('Error creating notebook: ' + error.message);
+    }
+}
+
+async function openNotebook(id) {
+    try {
+        notebook = await api('/' + id);
+        renderNotebook();
+        loadNotebooks();
+    } catch (error) {
+        alert('Error loading notebook: ' + error.message);
+    }
+}
+
+// collectEdits copies the name and cell code from the page into the notebook
+function collectEdits() {
+    notebook.name = document.getElementById('notebookName').value;
+    for (const cell of notebook.cells) {
+        const textarea = document.getElementById('code-' + cell.id);
+        if (textarea) {
+            cell.code = textarea.value;
+        }
+    }
+}
+
+function savedCells() {
+    return notebook.cells.map(cell => ({ id: cell.id, code: cell.code, execution_id: cell.execution_id }));
+}
+
+async function saveNotebook() {
+    collectEdits();
+    try {
+        notebook = await api('/' + notebook.id, jsonBody('PUT', { name: notebook.name, cells: savedCells() }));
+        renderNotebook();
+        loadNotebooks();
+    } catch (error) {
+        alert('Error saving notebook: ' + error.message);
+    }
+}
+
+async function deleteNotebook() {
+    if (!confirm('Delete notebook "' + notebook.name + '"?')) {
+        return;
+    }
+    try {
+        await api('/' + notebook.id, { method: 'DELETE' });
+        notebook = null;
+        document.getElementById('notebook').className = 'no-selection';
+        document.getElementById('notebook').innerHTML = 'Select or create a notebook';
+        loadNotebooks();
+    } catch (error) {
+        alert('Error deleting notebook: ' + error.message);
+    }
+}
+
+// runNotebook runs one cell, or all cells in a fresh session when cellId is empty
+async function runNotebook(cellId) {
+    if (running) {
+        return;
+    }
+    collectEdits();
+    running = true;
+    const cells = cellId ? [cellId] : notebook.cells.map(cell => cell.id);
+    cells.forEach(id => {
+        const el = document.getElementById('cell-' + id);
+        if (el) el.classList.add('running');
+    });
+    try {
+        notebook = await api('/' + notebook.id + '/run', jsonBody('POST', {
+            name: notebook.name,
+            cells: savedCells(),
+            cell: cellId || ''
+        }));
+    } catch (error) {
+        alert('Error running notebook: ' + error.message);
+    } finally {
+        running = false;
+        renderNotebook();
+        loadNotebooks();
+    }
+}
+
+async function restartSession() {
+    try {
+        await api('/' + notebook.id + '/restart', { method: 'POST' });
+    } catch (error) {
+        alert('Error restarting session: ' + error.message);
+    }
+}
+
+function addCell(afterId) {
+    collectEdits();
+    const index = notebook.cells.findIndex(cell => cell.id === afterId);
+    notebook.cells.splice(index + 1, 0, { id: newCellId(), code: '' });
+    renderNotebook();
+}
+
+function deleteCell(id) {
+    collectEdits();
+    notebook.cells = notebook.cells.filter(cell => cell.id !== id);
+    renderNotebook();
+}
+
+function renderOutput(output) {
+    if (!output) {
+        return '';
+    }
+    let html = '<div class="cell-output">';
+    if (output.console_log) {
+        html += '<div class="console">' + escapeHtml(output.console_log) + '</div>';
+    }
+    if (output.result && output.result !== 'undefined') {
+        if (output.result.startsWith('data:image/')) {
+            html += '<img src="' + escapeHtml(output.result) + '" alt="Result">';
+        } else {
+            html += '<div>' + escapeHtml(output.result) + '</div>';
+        }
+    }
+    if (output.error) {
+        html += '<div class="error">' + escapeHtml(output.error) + '</div>';
+    }
+    for (const a of output.attachments || []) {
+        const url = '/admin/logs/api/executions/' + output.id + '/attachments/' + encodeURIComponent(a.name);
+        html += '<div><a href="' + url + '?download=1" download>' + escapeHtml(a.name) + '</a> (' +
+            escapeHtml(a.mime_type) + ', ' + a.size + ' bytes)';
+        if (a.mime_type.startsWith('image/')) {
+            html += '<img src="' + url + '" alt="' + escapeHtml(a.name) + '">';
+        }
+        html += '</div>';
+    }
+    html += '<div class="text-muted" style="margin-top: 0.5rem; font-size: 0.75rem;">Execution #' + output.id +
+        ' at ' + new Date(output.timestamp).toLocaleString() + '</div>';
+    return html + '</div>';
+}
+
+function renderNotebook() {
+    const container = document.getElementById('notebook');
+    container.className = 'request-details';
+
+    const exportUrl = '/admin/notebooks/api/' + notebook.id + '/export?format=';
+    let html = '<div class="details-header">';
+    html += '  <input id="notebookName" class="notebook-name" value="' + escapeHtml(notebook.name) + '">';
+    html += '</div>';
+    html += '<div class="details-actions" style="margin-bottom: 1rem;">';
+    html += '  <button onclick="saveNotebook()">Save</button>';
+    html += '  <button onclick="runNotebook(\'\')" class="success">Run All</button>';
+    html += '  <button onclick="restartSession()">Restart Session</button>';
+    html += '  <a href="' + exportUrl + 'markdown">Markdown</a>';
+    html += '  <a href="' + exportUrl + 'script">Script</a>';
+    html += '  <a href="' + exportUrl + 'bundle">Bundle</a>';
+    html += '  <button onclick="deleteNotebook()" class="danger">Delete</button>';
+    html += '</div>';
+
+    notebook.cells.forEach((cell, i) => {
+        html += '<div class="notebook-cell" id="cell-' + escapeHtml(cell.id) + '">';
+        html += '  <div class="cell-toolbar">';
+        html += '    <span class="cell-label">[' + (i + 1) + ']</span>';
+        html += '    <button class="run" onclick="runNotebook(\'' + escapeHtml(cell.id) + '\')">Run</button>';
+        html += '    <button onclick="addCell(\'' + escapeHtml(cell.id) + '\')">Add Below</button>';
+        html += '    <button onclick="deleteCell(\'' + escapeHtml(cell.id) + '\')">Delete</button>';
+        html += '  </div>';
+        html += '  <textarea class="cell-code" id="code-' + escapeHtml(cell.id) + '" spellcheck="false">' + escapeHtml(cell.code) + '</textarea>';
+        html += renderOutput(cell.output);
+        html += '</div>';
+    });
+    if (notebook.cells.length === 0) {
+        html += '<button onclick="addCell(null)">Add Cell</button>';
+    }
+
+    container.innerHTML = html;
+}
+
+document.addEventListener('keydown', event => {
+    // Shift+Enter runs the focused cell
+    if (event.key === 'Enter' && event.shiftKey && event.target.classList.contains('cell-code')) {
+        event.preventDefault();
+        runNotebook(event.target.id.slice('code-'.length));
+    }
+});
+
+loadNotebooks();
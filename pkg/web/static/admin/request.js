@@ -0,0 +1,99 @@
+// Request timeline - shows request → handler → console/db ops → executions → response
+
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text == null ? '' : String(text);
+    return div.innerHTML;
+}
+
+function formatOffset(timestamp, start) {
+    const ms = new Date(timestamp) - new Date(start);
+    return (ms >= 0 ? '+' : '') + ms + 'ms';
+}
+
+async function loadTimeline() {
+    const requestId = new URLSearchParams(window.location.search).get('id');
+    if (!requestId) {
+        document.getElementById('timeline').innerHTML = '<p>No request ID given</p>';
+        return;
+    }
+
+    try {
+        const response = await fetch('/admin/logs/api/requests/' + encodeURIComponent(requestId) + '/timeline');
+        if (response.status === 404) {
+            document.getElementById('timeline').innerHTML = '<p>Request not found (it may have been evicted from the log)</p>';
+            return;
+        }
+        const data = await response.json();
+        renderSummary(data.request);
+        renderEvents(data.request, data.events || []);
+        renderExecutions(data.executions || []);
+    } catch (error) {
+        console.error('Failed to load request timeline:', error);
+    }
+}
+
+function renderSummary(request) {
+    const statusClass = 'status-' + Math.floor(request.status / 100) + 'xx';
+    let html = '<div class="details-header">';
+    html += '  <div class="details-title">';
+    html += '    <span class="request-method method-' + escapeHtml(request.method) + '">' + escapeHtml(request.method) + '</span>';
+    html += '    <span class="request-path">' + escapeHtml(request.path) + '</span>';
+    html += '    <span class="request-status ' + statusClass + '">' + request.status + '</span>';
+    html += '  </div>';
+    html += '  <div class="details-meta">';
+    html += '    <div>Request ID: ' + escapeHtml(request.id) + '</div>';
+    if (request.handler) {
+        html += '    <div>Handler: ' + escapeHtml(request.handler) + '</div>';
+    }
+    if (request.sessionId) {
+        html += '    <div>Session: ' + escapeHtml(request.sessionId) + '</div>';
+    }
+    html += '    <div>Duration: ' + Math.round((request.duration || 0) / 1000000) + 'ms</div>';
+    html += '  </div>';
+    html += '</div>';
+    document.getElementById('requestSummary').innerHTML = html;
+}
+
+function renderEvents(request, events) {
+    let html = '';
+    events.forEach(event => {
+        html += '<div class="timeline-event timeline-' + event.kind + '">';
+        html += '  <span class="timeline-offset">' + formatOffset(event.timestamp, request.startTime) + '</span>';
+        html += '  <span class="timeline-kind">' + escapeHtml(event.kind) + '</span>';
+        html += '  <span class="timeline-summary">' + escapeHtml(event.summary) + '</span>';
+        if (event.kind === 'response' && event.data) {
+            html += '  <pre class="timeline-data">' + escapeHtml(event.data) + '</pre>';
+        }
+        html += '</div>';
+    });
+    document.getElementById('timeline').innerHTML = html || '<p>No events recorded</p>';
+}
+
+function renderExecutions(executions) {
+    if (executions.length === 0) return;
+
+    let html = '';
+    executions.forEach(execution => {
+        html += '<div class="db-operation ' + (execution.error ? 'error' : 'success') + '">';
+        html += '  <div class="db-op-header">';
+        html += '    <span class="db-op-type">#' + execution.id + '</span>';
+        html += '    <span class="db-op-time">' + escapeHtml(execution.source) + '</span>';
+        html += '  </div>';
+        html += '  <div class="db-op-sql"><pre>' + escapeHtml(execution.code) + '</pre></div>';
+        if (execution.result) {
+            html += '  <div class="db-op-result">Result: <code>' + escapeHtml(execution.result) + '</code></div>';
+        }
+        if (execution.console_log) {
+            html += '  <div class="db-op-params"><pre>' + escapeHtml(execution.console_log) + '</pre></div>';
+        }
+        if (execution.error) {
+            html += '  <div class="db-op-error-msg">Error: ' + escapeHtml(execution.error) + '</div>';
+        }
+        html += '</div>';
+    });
+    document.getElementById('executions').innerHTML = html;
+    document.getElementById('executionsSection').style.display = 'block';
+}
+
+loadTimeline();
@@ -0,0 +1,129 @@
+// Saved filters - named execution filter combinations shown as quick-apply chips
+// in the history and scripts viewers. Filters are persisted on the server.
+
+// Maps form field names to the JSON fields of repository.ExecutionFilter
+const SAVED_FILTER_FIELDS = {
+    search: 'search',
+    sessionId: 'session_id',
+    source: 'source',
+    actor: 'actor',
+    hasError: 'has_error',
+    since: 'since'
+};
+
+// filterFromForm reads the filter fields present in a form
+function filterFromForm(form) {
+    const filter = {};
+    Object.entries(SAVED_FILTER_FIELDS).forEach(([field, key]) => {
+        const input = form.elements[field];
+        if (!input || !input.value.trim()) return;
+        filter[key] = field === 'hasError' ? input.value === 'true' : input.value.trim();
+    });
+    return filter;
+}
+
+// applyFilterToForm fills a form from a saved filter, clearing fields the filter does not set
+function applyFilterToForm(form, filter) {
+    Object.entries(SAVED_FILTER_FIELDS).forEach(([field, key]) => {
+        const input = form.elements[field];
+        if (!input) return;
+        const value = filter[key];
+        input.value = value === undefined || value === null ? '' : String(value);
+    });
+}
+
+// describeFilter summarizes a filter for the chip tooltip
+function describeFilter(filter) {
+    const parts = [];
+    if (filter.source) parts.push('source=' + filter.source);
+    if (filter.actor) parts.push('actor=' + filter.actor);
+    if (filter.has_error === true) parts.push('with errors');
+    if (filter.has_error === false) parts.push('without errors');
+    if (filter.since) parts.push('last ' + filter.since);
+    if (filter.session_id) parts.push('session=' + filter.session_id);
+    if (filter.search) parts.push('"' + filter.search + '"');
+    return parts.join(' AND ') || 'all executions';
+}
+
+// initSavedFilters renders the saved filter chips of a page into a container.
+// onApply is called with the form after a chip filled it in.
+function initSavedFilters(page, form, container, onApply) {
+    async function load() {
+        try {
+            const response = await fetch('/admin/logs/api/filters?page=' + encodeURIComponent(page));
+            if (!response.ok) throw new Error('HTTP ' + response.status);
+            render(await response.json());
+        } catch (error) {
+            console.error('Failed to load saved filters:', error);
+        }
+    }
+
+    function render(filters) {
+        container.innerHTML = '';
+        container.className = 'saved-filters';
+
+        filters.forEach(saved => {
+            const chip = document.createElement('span');
+            chip.className = 'saved-filter-chip';
+            chip.title = describeFilter(saved.filter);
+
+            const apply = document.createElement('button');
+            apply.type = 'button';
+            apply.className = 'saved-filter-apply';
+            apply.textContent = saved.name;
+            apply.onclick = () => {
+                applyFilterToForm(form, saved.filter);
+                onApply(form);
+            };
+
+            const remove = document.createElement('button');
+            remove.type = 'button';
+            remove.className = 'saved-filter-remove';
+            remove.textContent = '×';
+            remove.title = 'Delete saved filter';
+            remove.onclick = () => deleteSaved(saved);
+
+            chip.appendChild(apply);
+            chip.appendChild(remove);
+            container.appendChild(chip);
+        });
+
+        const save = document.createElement('button');
+        save.type = 'button';
+        save.className = 'saved-filter-save';
+        save.textContent = '+ Save current filter';
+        save.onclick = saveCurrent;
+        container.appendChild(save);
+    }
+
+    async function saveCurrent() {
+        const filter = filterFromForm(form);
+        const name = prompt('Name for this filter (' + describeFilter(filter) + '):');
+        if (!name || !name.trim()) return;
+
+        try {
+            const response = await fetch('/admin/logs/api/filters', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name: name.trim(), page: page, filter: filter })
+            });
+            if (!response.ok) throw new Error(await response.text());
+            load();
+        } catch (error) {
+            alert('Failed to save filter: ' + error.message);
+        }
+    }
+
+    async function deleteSaved(saved) {
+        if (!confirm('Delete saved filter "' + saved.name + '"?')) return;
+        try {
+            const response = await fetch('/admin/logs/api/filters/' + saved.id, { method: 'DELETE' });
+            if (!response.ok) throw new Error('HTTP ' + response.status);
+            load();
+        } catch (error) {
+            alert('Failed to delete filter: ' + error.message);
+        }
+    }
+
+    load();
+}
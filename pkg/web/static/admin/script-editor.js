@@ -0,0 +1,579 @@
+// Collaborative script editor. Edits are operations in the format of ot.js, transformed
+// against the ones of the other participants: see pkg/collab for the protocol.
+
+// TextOperation: positive numbers retain, negative numbers delete, strings insert
+class TextOperation {
+    constructor() {
+        this.ops = [];
+        this.baseLength = 0;
+        this.targetLength = 0;
+    }
+
+    static fromJSON(ops) {
+        const op = new TextOperation();
+        for (const c of ops) {
+            if (typeof c === 'string') {
+                op.insert(c);
+            } else if (c > 0) {
+                op.retain(c);
+            } else {
+                op.delete(-c);
+            }
+        }
+        return op;
+    }
+
+    retain(n) {
+        if (n <= 0) return this;
+        this.baseLength += n;
+        this.targetLength += n;
+        const last = this.ops.length - 1;
+        if (last >= 0 && isRetain(this.ops[last])) {
+            this.ops[last] += n;
+        } else {
+            this.ops.push(n);
+        }
+        return this;
+    }
+
+    insert(s) {
+        if (s === '') return this;
+        this.targetLength += s.length;
+        const ops = this.ops;
+        const last = ops.length - 1;
+        if (last >= 0 && isInsert(ops[last])) {
+            ops[last] += s;
+        } else if (last >= 0 && isDelete(ops[last])) {
+            // Inserts go before deletes, so equal edits have the same components
+            if (last > 0 && isInsert(ops[last - 1])) {
+                ops[last - 1] += s;
+            } else {
+                ops.push(ops[last]);
+                ops[last] = s;
+            }
+        } else {
+            ops.push(s);
+        }
+        return this;
+    }
+
+    delete(n) {
+        if (n <= 0) return this;
+        this.baseLength += n;
+        const last = this.ops.length - 1;
+        if (last >= 0 && isDelete(this.ops[last])) {
+            this.ops[last] -= n;
+        } else {
+            this.ops.push(-n);
+        }
+        return this;
+    }
+
+    isNoop() {
+        return this.ops.length === 0 || (this.ops.length === 1 && isRetain(this.ops[0]));
+    }
+
+    // compose returns the operation doing this, then other
+    compose(other) {
+        if (this.targetLength !== other.baseLength) {
+            throw new Error('cannot compose operations of different lengths');
+        }
+        const result = new TextOperation();
+        const ops1 = this.ops, ops2 = other.ops;
+        let i1 = 0, i2 = 0;
+        let op1 = ops1[i1++], op2 = ops2[i2++];
+        while (op1 !== undefined || op2 !== undefined) {
+            if (isDelete(op1)) {
+                result.delete(-op1);
+                op1 = ops1[i1++];
+                continue;
+            }
+            if (isInsert(op2)) {
+                result.insert(op2);
+                op2 = ops2[i2++];
+                continue;
+            }
+            if (op1 === undefined || op2 === undefined) {
+                throw new Error('cannot compose operations of different lengths');
+            }
+
+            if (isRetain(op1) && isRetain(op2)) {
+                const n = Math.min(op1, op2);
+                result.retain(n);
+                op1 -= n;
+                op2 -= n;
+            } else if (isInsert(op1) && isDelete(op2)) {
+                const n = Math.min(op1.length, -op2);
+                op1 = op1.slice(n);
+                op2 += n;
+            } else if (isInsert(op1) && isRetain(op2)) {
+                const n = Math.min(op1.length, op2);
+                result.insert(op1.slice(0, n));
+                op1 = op1.slice(n);
+                op2 -= n;
+            } else { // op1 retains, op2 deletes
+                const n = Math.min(op1, -op2);
+                result.delete(n);
+                op1 -= n;
+                op2 += n;
+            }
+            if (op1 === 0 || op1 === '') op1 = ops1[i1++];
+            if (op2 === 0) op2 = ops2[i2++];
+        }
+        return result;
+    }
+
+    // transform returns [a', b'] so that a then b' equals b then a'; at the same position
+    // the insert of a goes first
+    static transform(a, b) {
+        if (a.baseLength !== b.baseLength) {
+            throw new Error('cannot transform operations of different lengths');
+        }
+        const aPrime = new TextOperation(), bPrime = new TextOperation();
+        const ops1 = a.ops, ops2 = b.ops;
+        let i1 = 0, i2 = 0;
+        let op1 = ops1[i1++], op2 = ops2[i2++];
+        while (op1 !== undefined || op2 !== undefined) {
+            if (isInsert(op1)) {
+                aPrime.insert(op1);
+                bPrime.retain(op1.length);
+                op1 = ops1[i1++];
+                continue;
+            }
+            if (isInsert(op2)) {
+                aPrime.retain(op2.length);
+                bPrime.insert(op2);
+                op2 = ops2[i2++];
+                continue;
+            }
+            if (op1 === undefined || op2 === undefined) {
+                throw new Error('operations do not cover the same text');
+            }
+
+            if (isRetain(op1) && isRetain(op2)) {
+                const n = Math.min(op1, op2);
+                aPrime.retain(n);
+                bPrime.retain(n);
+                op1 -= n;
+                op2 -= n;
+            } else if (isDelete(op1) && isDelete(op2)) {
+                const n = Math.min(-op1, -op2);
+                op1 += n;
+                op2 += n;
+            } else if (isDelete(op1) && isRetain(op2)) {
+                const n = Math.min(-op1, op2);
+                aPrime.delete(n);
+                op1 += n;
+                op2 -= n;
+            } else { // op1 retains, op2 deletes
+                const n = Math.min(op1, -op2);
+                bPrime.delete(n);
+                op1 -= n;
+                op2 += n;
+            }
+            if (op1 === 0) op1 = ops1[i1++];
+            if (op2 === 0) op2 = ops2[i2++];
+        }
+        return [aPrime, bPrime];
+    }
+
+    // transformIndex moves a position of the text to where it is once the operation applied
+    transformIndex(index) {
+        let newIndex = index;
+        for (const op of this.ops) {
+            if (isRetain(op)) {
+                index -= op;
+            } else if (isInsert(op)) {
+                newIndex += op.length;
+            } else {
+                newIndex -= Math.min(index, -op);
+                index += op;
+            }
+            if (index < 0) break;
+        }
+        return newIndex;
+    }
+}
+
+function isRetain(op) {
+    return typeof op === 'number' && op > 0;
+}
+
+function isDelete(op) {
+    return typeof op === 'number' && op < 0;
+}
+
+function isInsert(op) {
+    return typeof op === 'string';
+}
+
+function escapeHtml(text) {
+    const div = document.createElement('div');
+    div.textContent = text == null ? '' : String(text);
+    return div.innerHTML;
+}
+
+// The API key of the script files API authenticates the editor too
+function apiKey() {
+    let key = sessionStorage.getItem('jesusApiKey');
+    if (!key) {
+        key = prompt('API key (one of --api-keys):');
+        if (key) sessionStorage.setItem('jesusApiKey', key);
+    }
+    return key;
+}
+
+function participantName() {
+    let name = localStorage.getItem('jesusEditorName');
+    if (name === null) {
+        name = prompt('Your name, shown to the other editors:') || '';
+        localStorage.setItem('jesusEditorName', name);
+    }
+    return name;
+}
+
+let editor = null;
+let session = null;
+
+// Session is the connection to the document of one file
+class Session {
+    constructor(path) {
+        this.path = path;
+        this.id = null;
+        this.revision = 0;
+        this.outstanding = null; // Edit sent, waiting for its ack
+        this.buffer = null;      // Edits made meanwhile
+        this.participants = [];
+        this.dirty = false;
+        this.closed = false;
+        this.applyingRemote = false;
+        this.marks = [];
+        this.connect();
+    }
+
+    connect() {
+        const protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
+        this.ws = new WebSocket(protocol + '//' + location.host + '/admin/scripts/edit/ws?path=' + encodeURIComponent(this.path));
+        this.ws.onopen = () => {
+            this.ws.send(JSON.stringify({ type: 'join', key: apiKey(), name: participantName() }));
+        };
+        this.ws.onmessage = (event) => this.receive(JSON.parse(event.data));
+        this.ws.onclose = () => {
+            if (this.closed) return;
+            setStatus('Disconnected, reconnecting...', 'error');
+            this.outstanding = null;
+            this.buffer = null;
+            setTimeout(() => { if (!this.closed) this.connect(); }, 2000);
+        };
+    }
+
+    close() {
+        this.closed = true;
+        this.ws.close();
+        this.clearMarks();
+    }
+
+    send(message) {
+        if (this.ws.readyState === WebSocket.OPEN) {
+            this.ws.send(JSON.stringify(message));
+        }
+    }
+
+    receive(msg) {
+        switch (msg.type) {
+        case 'init':
+            this.id = msg.id;
+            this.revision = msg.revision;
+            this.outstanding = null;
+            this.buffer = null;
+            this.applyingRemote = true;
+            editor.setValue(msg.text);
+            editor.clearHistory();
+            this.applyingRemote = false;
+            editor.setOption('readOnly', false);
+            document.getElementById('saveButton').disabled = false;
+            this.participants = msg.participants || [];
+            this.setDirty(msg.dirty);
+            this.renderPresence();
+            break;
+        case 'ack':
+            this.revision = msg.revision;
+            this.outstanding = this.buffer;
+            this.buffer = null;
+            if (this.outstanding) {
+                this.sendOperation(this.outstanding);
+            } else {
+                this.sendSelection();
+            }
+            this.setDirty(msg.dirty);
+            break;
+        case 'op':
+            this.revision = msg.revision;
+            this.applyServer(TextOperation.fromJSON(msg.op));
+            for (const p of this.participants) {
+                if (p.id === msg.id) p.selection = msg.selection;
+            }
+            this.setDirty(msg.dirty);
+            this.renderPresence();
+            break;
+        case 'presence':
+            this.participants = msg.participants || [];
+            this.renderPresence();
+            break;
+        case 'saved':
+            this.setDirty(msg.dirty);
+            if (msg.error) {
+                setStatus('Saved by ' + msg.name + ', but it failed to run: ' + msg.error, 'error');
+            } else {
+                setStatus('Saved by ' + msg.name, this.dirty ? 'dirty' : '');
+            }
+            break;
+        case 'error':
+            setStatus(msg.error, 'error');
+            if (this.id === null) {
+                // Refused to join, e.g. a wrong API key
+                if (/API key/.test(msg.error)) sessionStorage.removeItem('jesusApiKey');
+                this.closed = true;
+                this.ws.close();
+            } else if (this.outstanding) {
+                // An edit was refused: the text may differ from the others, start over
+                this.ws.close();
+            }
+            break;
+        }
+    }
+
+    // applyClient sends a local edit, or buffers it until the edit sent before is acknowledged
+    applyClient(op) {
+        if (op.isNoop()) return;
+        this.transformPresence(op);
+        if (!this.outstanding) {
+            this.outstanding = op;
+            this.sendOperation(op);
+        } else if (!this.buffer) {
+            this.buffer = op;
+        } else {
+            this.buffer = this.buffer.compose(op);
+        }
+        this.renderPresence();
+    }
+
+    // applyServer applies an edit of somebody else, transformed against the local edits the
+    // server has not applied yet
+    applyServer(op) {
+        if (this.outstanding) {
+            [this.outstanding, op] = TextOperation.transform(this.outstanding, op);
+        }
+        if (this.buffer) {
+            [this.buffer, op] = TextOperation.transform(this.buffer, op);
+        }
+        this.applyToEditor(op);
+        this.transformPresence(op);
+    }
+
+    sendOperation(op) {
+        this.send({ type: 'op', revision: this.revision, op: op.ops, selection: currentSelection() });
+    }
+
+    sendSelection() {
+        if (!this.outstanding && this.id !== null) {
+            this.send({ type: 'selection', revision: this.revision, selection: currentSelection() });
+        }
+    }
+
+    applyToEditor(op) {
+        this.applyingRemote = true;
+        editor.operation(() => {
+            const doc = editor.getDoc();
+            let index = 0;
+            for (const c of op.ops) {
+                if (isRetain(c)) {
+                    index += c;
+                } else if (isInsert(c)) {
+                    doc.replaceRange(c, doc.posFromIndex(index));
+                    index += c.length;
+                } else {
+                    doc.replaceRange('', doc.posFromIndex(index), doc.posFromIndex(index - c));
+                }
+            }
+        });
+        this.applyingRemote = false;
+    }
+
+    transformPresence(op) {
+        for (const p of this.participants) {
+            if (p.id !== this.id && p.selection) {
+                p.selection = { anchor: op.transformIndex(p.selection.anchor), head: op.transformIndex(p.selection.head) };
+            }
+        }
+    }
+
+    setDirty(dirty) {
+        this.dirty = dirty;
+        setStatus(dirty ? 'Unsaved changes' : 'Saved', dirty ? 'dirty' : '');
+    }
+
+    clearMarks() {
+        for (const mark of this.marks) mark.clear();
+        this.marks = [];
+    }
+
+    renderPresence() {
+        const list = document.getElementById('participants');
+        list.innerHTML = this.participants.map(p =>
+            '<span class="participant" title="' + escapeHtml(p.name) + '">' +
+            '<span class="dot" style="background:' + escapeHtml(p.color) + '"></span>' +
+            escapeHtml(p.name) + (p.id === this.id ? ' (you)' : '') + '</span>'
+        ).join('');
+
+        this.clearMarks();
+        const doc = editor.getDoc();
+        const length = doc.getValue().length;
+        for (const p of this.participants) {
+            if (p.id === this.id || !p.selection) continue;
+            const anchor = Math.min(p.selection.anchor, length);
+            const head = Math.min(p.selection.head, length);
+            if (anchor !== head) {
+                this.marks.push(doc.markText(doc.posFromIndex(Math.min(anchor, head)), doc.posFromIndex(Math.max(anchor, head)), {
+                    css: 'background: ' + p.color + '33'
+                }));
+            }
+            const cursor = document.createElement('span');
+            cursor.className = 'remote-cursor';
+            cursor.style.borderColor = p.color;
+            const label = document.createElement('span');
+            label.className = 'remote-name';
+            label.style.background = p.color;
+            label.textContent = p.name;
+            cursor.appendChild(label);
+            this.marks.push(doc.setBookmark(doc.posFromIndex(head), { widget: cursor, insertLeft: true }));
+        }
+    }
+}
+
+function currentSelection() {
+    const doc = editor.getDoc();
+    return {
+        anchor: doc.indexFromPos(doc.getCursor('anchor')),
+        head: doc.indexFromPos(doc.getCursor('head'))
+    };
+}
+
+function setStatus(text, kind) {
+    const status = document.getElementById('editorStatus');
+    status.textContent = text;
+    status.className = 'editor-status' + (kind ? ' ' + kind : '');
+}
+
+function createEditor() {
+    const host = document.getElementById('editorHost');
+    host.innerHTML = '';
+    editor = CodeMirror(host, {
+        mode: 'javascript',
+        theme: 'darcula',
+        lineNumbers: true,
+        matchBrackets: true,
+        indentUnit: 4,
+        readOnly: true,
+        lineSeparator: '\n', // Positions count characters like the server does
+        extraKeys: {
+            'Ctrl-S': () => saveFile(),
+            'Cmd-S': () => saveFile()
+        }
+    });
+
+    // Every change is one replacement, read before it applies
+    editor.on('beforeChange', (cm, change) => {
+        if (!session || session.applyingRemote) return;
+        const doc = cm.getDoc();
+        const from = doc.indexFromPos(change.from);
+        const to = doc.indexFromPos(change.to);
+        const length = doc.getValue().length;
+        session.applyClient(new TextOperation().retain(from).delete(to - from).insert(change.text.join('\n')).retain(length - to));
+    });
+
+    let selectionTimer = null;
+    editor.on('cursorActivity', () => {
+        if (!session || session.applyingRemote) return;
+        clearTimeout(selectionTimer);
+        selectionTimer = setTimeout(() => session.sendSelection(), 100);
+    });
+}
+
+async function loadFiles() {
+    const list = document.getElementById('fileList');
+    const key = apiKey();
+    if (!key) {
+        list.innerHTML = '<p class="text-muted">An API key is needed to edit scripts</p>';
+        return;
+    }
+    try {
+        const response = await fetch('/admin/scripts/files/', { headers: { 'X-API-Key': key } });
+        if (response.status === 401) {
+            sessionStorage.removeItem('jesusApiKey');
+        }
+        if (!response.ok) {
+            throw new Error((await response.text()) || response.statusText);
+        }
+        const files = await response.json();
+        if (files.length === 0) {
+            list.innerHTML = '<p class="text-muted">No scripts yet</p>';
+            return;
+        }
+        list.innerHTML = files.map(f => {
+            const selected = session && session.path === f.path ? ' selected' : '';
+            return '<div class="request-item' + selected + '" data-path="' + escapeHtml(f.path) + '">' +
+                '<div class="request-method">' + escapeHtml(f.path) + '</div>' +
+                '<div class="request-time">' + f.size + ' bytes, modified ' + new Date(f.modified).toLocaleString() + '</div>' +
+                '</div>';
+        }).join('');
+        for (const item of list.querySelectorAll('.request-item')) {
+            item.onclick = () => openFile(item.dataset.path);
+        }
+    } catch (error) {
+        list.innerHTML = '<p class="text-muted">Error loading scripts: ' + escapeHtml(error.message) + '</p>';
+    }
+}
+
+function openFile(path) {
+    if (session && session.dirty && session.participants.length === 1 &&
+        !confirm('Nobody else has ' + session.path + ' open, its unsaved changes will be lost. Continue?')) {
+        return;
+    }
+    if (session) session.close();
+    if (!editor) createEditor();
+    editor.setOption('readOnly', true);
+    document.getElementById('editorPath').textContent = path;
+    setStatus('Connecting...', '');
+    history.replaceState(null, '', '/admin/scripts/edit?path=' + encodeURIComponent(path));
+    session = new Session(path);
+    loadFiles();
+}
+
+function newFile() {
+    const path = prompt('Path of the new script, relative to the scripts directory (e.g. api/users.js):');
+    if (!path) return;
+    if (!/\.(js|ts)$/.test(path)) {
+        alert('Scripts end in .js or .ts');
+        return;
+    }
+    openFile(path);
+}
+
+function saveFile() {
+    if (session && session.id !== null) {
+        setStatus('Saving...', session.dirty ? 'dirty' : '');
+        session.send({ type: 'save' });
+    }
+}
+
+window.addEventListener('beforeunload', (event) => {
+    if (session && session.dirty && session.participants.length === 1) {
+        event.preventDefault();
+    }
+});
+
+loadFiles().then(() => {
+    const path = new URLSearchParams(location.search).get('path');
+    if (path) openFile(path);
+});
@@ -4,6 +4,7 @@ class JSPlaygroundApp {
         this.editor = null;
         this.replHistory = [];
         this.replHistoryIndex = -1;
+        this.replSessionID = null;
         this.vimMode = true;
         this.init();
     }
@@ -20,6 +21,27 @@ class JSPlaygroundApp {
         // Initialize common components
         this.initToasts();
         this.loadFromLocalStorage();
+        this.loadEnvironmentBanner();
+    }
+
+    // Show which engine environment (dev, prod, ...) this playground is connected to
+    async loadEnvironmentBanner() {
+        const main = document.querySelector('main');
+        if (!main) return;
+
+        try {
+            const response = await fetch('/api/env');
+            if (!response.ok) return;
+            const env = await response.json();
+
+            const banner = document.createElement('div');
+            banner.className = 'alert py-1 text-center ' + (env.production ? 'alert-danger' : 'alert-info');
+            banner.innerHTML = '<i class="bi bi-hdd-network"></i> Environment: <strong>' + this.escapeHtml(env.name) + '</strong>' +
+                (env.production ? ' &mdash; changes affect production data' : '');
+            main.insertBefore(banner, main.firstChild);
+        } catch (error) {
+            console.error('Failed to load environment:', error);
+        }
     }
 
     // Playground functionality
@@ -114,6 +136,29 @@ console.log("Total executions:", users[0].count);
         this.autoResizeTextarea(replInput);
     }
 
+    // Code execution endpoints require the execute token when the server sets one
+    // (--execute-token). Ask for it on 401, keep it in localStorage and retry once.
+    async executeFetch(url, options) {
+        const send = () => {
+            const headers = Object.assign({}, options.headers);
+            const token = localStorage.getItem('executeToken');
+            if (token) {
+                headers['Authorization'] = 'Bearer ' + token;
+            }
+            return fetch(url, Object.assign({}, options, { headers }));
+        };
+
+        let response = await send();
+        if (response.status === 401) {
+            const token = prompt('This server requires an execute token to run code:');
+            if (token) {
+                localStorage.setItem('executeToken', token.trim());
+                response = await send();
+            }
+        }
+        return response;
+    }
+
     // Code execution
     async runCode() {
         if (!this.editor) return;
@@ -126,7 +171,7 @@ console.log("Total executions:", users[0].count);
 
         try {
             // For "run" we just execute without storing
-            const response = await fetch('/v1/execute', {
+            const response = await this.executeFetch('/api/playground/execute', {
                 method: 'POST',
                 headers: { 'Content-Type': 'text/plain' },
                 body: code
@@ -159,7 +204,7 @@ console.log("Total executions:", users[0].count);
         const startTime = Date.now();
 
         try {
-            const response = await fetch('/v1/execute', {
+            const response = await this.executeFetch('/api/playground/execute', {
                 method: 'POST',
                 headers: { 'Content-Type': 'text/plain' },
                 body: code
@@ -197,13 +242,21 @@ console.log("Total executions:", users[0].count);
         this.replHistoryIndex = this.replHistory.length;
 
         try {
-            const response = await fetch('/v1/execute', {
+            // REPL lines are not stored; the session keeps variables between lines
+            const headers = { 'Content-Type': 'text/plain' };
+            if (this.replSessionID) {
+                headers['X-Session-ID'] = this.replSessionID;
+            }
+            const response = await this.executeFetch('/api/repl/execute', {
                 method: 'POST',
-                headers: { 'Content-Type': 'text/plain' },
+                headers: headers,
                 body: code
             });
 
             const result = await response.json();
+            if (result.sessionID) {
+                this.replSessionID = result.sessionID;
+            }
 
             if (result.success) {
                 if (result.consoleLog && result.consoleLog.length > 0) {
@@ -282,12 +335,32 @@ console.log("Total executions:", users[0].count);
         `;
         this.replHistory = [];
         this.replHistoryIndex = -1;
+
+        // Start over with a fresh runtime
+        if (this.replSessionID) {
+            this.executeFetch(`/api/repl/execute?sessionId=${encodeURIComponent(this.replSessionID)}`, { method: 'DELETE' });
+            this.replSessionID = null;
+        }
     }
 
     async resetVM() {
+        const keepState = confirm('Keep globalState across the reset? Cancel starts from an empty globalState.');
         try {
-            await fetch('/api/reset-vm', { method: 'POST' });
-            this.addReplEntry('log', 'VM reset successfully');
+            const response = await fetch('/api/reset-vm', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ keepState })
+            });
+            if (!response.ok && response.status !== 422) {
+                throw new Error(await response.text());
+            }
+            const result = await response.json();
+            if (result.error) {
+                this.addReplEntry('error', `VM reset, but the bootstrap file failed: ${result.error}`);
+                this.showToast('Bootstrap failed after reset', 'error');
+                return;
+            }
+            this.addReplEntry('log', `VM reset successfully: ${result.routes} routes registered`);
             this.showToast('VM reset', 'info');
         } catch (error) {
             this.addReplEntry('error', `Failed to reset VM: ${error.message}`);
@@ -310,6 +383,9 @@ console.log("Total executions:", users[0].count);
         const resultOutput = document.getElementById('resultOutput');
         if (error) {
             resultOutput.innerHTML = `<div class="repl-error">${this.escapeHtml(error)}</div>`;
+        } else if (typeof result === 'string' && /^data:image\/(png|jpeg|gif|svg\+xml);base64,/.test(result)) {
+            // Images returned by the code, e.g. chart.render() output
+            resultOutput.innerHTML = `<div class="repl-result"><img src="${this.escapeHtml(result)}" alt="Result image" style="max-width: 100%;"></div>`;
         } else if (result !== undefined) {
             resultOutput.innerHTML = `<div class="repl-result">${this.escapeHtml(this.formatValue(result))}</div>`;
         } else {
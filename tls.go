@@ -0,0 +1,55 @@
+package jesus
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// defaultAutocertCacheDir keeps the certificates of Let's Encrypt when no directory is set
+const defaultAutocertCacheDir = "autocert"
+
+// tlsEnabled reports whether the options serve HTTPS
+func (o Options) tlsEnabled() bool {
+	return o.TLSCertFile != "" || o.TLSKeyFile != "" || len(o.AutocertDomains) > 0
+}
+
+// newTLSConfig returns the TLS configuration of the options, nil to serve plain HTTP. A
+// certificate and key pair is loaded right away, so a broken one fails New. Certificates of
+// Let's Encrypt are obtained on the first request for a domain, answering the TLS-ALPN-01
+// challenge, so the JavaScript web server must be reachable on port 443.
+func newTLSConfig(opts Options) (*tls.Config, error) {
+	switch {
+	case !opts.tlsEnabled():
+		return nil, nil
+	case len(opts.AutocertDomains) > 0 && (opts.TLSCertFile != "" || opts.TLSKeyFile != ""):
+		return nil, errors.New("use either a TLS certificate and key or autocert domains, not both")
+	case len(opts.AutocertDomains) > 0:
+		cacheDir := opts.AutocertCacheDir
+		if cacheDir == "" {
+			cacheDir = defaultAutocertCacheDir
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.AutocertDomains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      opts.AutocertEmail,
+		}
+		config := manager.TLSConfig()
+		config.MinVersion = tls.VersionTLS12
+		return config, nil
+	case opts.TLSCertFile == "" || opts.TLSKeyFile == "":
+		return nil, errors.New("a TLS certificate needs both a certificate file and a key file")
+	}
+
+	cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}